@@ -0,0 +1,27 @@
+-- Перевод api_config из формата {"<chat_id>": "<token>"} в версионированный:
+-- {"version": 1, "telegram": {...}} / {"version": 1, "vk": {...}}
+WITH legacy AS (
+    SELECT DISTINCT ON (p.id) p.id, p.platform_name, e.key, e.value
+    FROM platforms p, jsonb_each_text(p.api_config) e
+    WHERE p.api_config IS NOT NULL AND NOT p.api_config ? 'version'
+    ORDER BY p.id
+)
+UPDATE platforms
+SET api_config = CASE legacy.platform_name
+    WHEN 'Telegram' THEN jsonb_build_object(
+        'version', 1,
+        'telegram', jsonb_build_object(
+            'bot_token', legacy.value,
+            'chat_id', legacy.key,
+            'silent', false,
+            'disable_link_previews', false))
+    WHEN 'VK' THEN jsonb_build_object(
+        'version', 1,
+        'vk', jsonb_build_object(
+            'access_token', legacy.value,
+            'owner_id', legacy.key,
+            'from_group', false))
+    ELSE platforms.api_config
+END
+FROM legacy
+WHERE platforms.id = legacy.id;
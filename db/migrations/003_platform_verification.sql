@@ -0,0 +1,2 @@
+-- Результат последней проверки учётных данных платформы (domain.VerificationReport)
+ALTER TABLE platforms ADD COLUMN verification JSONB;
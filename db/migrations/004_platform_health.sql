@@ -0,0 +1,23 @@
+-- Состояние платформы по результатам фоновых проверок учётных данных
+ALTER TABLE platforms ADD COLUMN health_status VARCHAR(20) NOT NULL DEFAULT 'healthy'
+    CHECK (health_status IN ('healthy', 'degraded', 'inactive'));
+ALTER TABLE platforms ADD COLUMN auth_failures INTEGER NOT NULL DEFAULT 0;
+
+-- held: публикация отложена, пока платформа неактивна
+ALTER TABLE post_destinations DROP CONSTRAINT IF EXISTS post_destinations_status_check;
+ALTER TABLE post_destinations ADD CONSTRAINT post_destinations_status_check
+    CHECK (status IN ('scheduled', 'published', 'failed', 'processing', 'held'));
+
+-- Уведомления владельцу
+CREATE TABLE notifications (
+    id SERIAL PRIMARY KEY,
+    user_id VARCHAR(255) NOT NULL,
+    kind VARCHAR(50) NOT NULL,
+    message TEXT NOT NULL,
+    platform_id INTEGER,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT fk_notifications_platform FOREIGN KEY (platform_id) REFERENCES platforms(id) ON DELETE SET NULL
+);
+
+CREATE INDEX idx_notifications_user_id ON notifications(user_id, created_at DESC);
@@ -0,0 +1,7 @@
+-- Ручная пауза платформы: is_active = false, paused_at — момент постановки на паузу
+ALTER TABLE platforms ADD COLUMN paused_at TIMESTAMP WITH TIME ZONE;
+
+-- skipped: просроченная за время паузы публикация пропущена
+ALTER TABLE post_destinations DROP CONSTRAINT IF EXISTS post_destinations_status_check;
+ALTER TABLE post_destinations ADD CONSTRAINT post_destinations_status_check
+    CHECK (status IN ('scheduled', 'published', 'failed', 'processing', 'held', 'skipped'));
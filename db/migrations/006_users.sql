@@ -0,0 +1,42 @@
+-- Аккаунты пользователей. id — то, что лежит в user_id JWT и во всех таблицах.
+CREATE TABLE users (
+    id VARCHAR(255) PRIMARY KEY DEFAULT gen_random_uuid()::text,
+    email VARCHAR(255),
+    name VARCHAR(255),
+    avatar_url TEXT,
+    timezone VARCHAR(64) NOT NULL DEFAULT 'UTC',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Способы входа: к одному аккаунту можно привязать несколько провайдеров
+CREATE TABLE user_identities (
+    provider VARCHAR(50) NOT NULL,
+    provider_user_id VARCHAR(255) NOT NULL,
+    user_id VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY (provider, provider_user_id),
+    CONSTRAINT fk_user_identities_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_user_identities_user_id ON user_identities(user_id);
+
+-- Раньше user_id был id пользователя Google, переносим существующих пользователей как есть
+INSERT INTO users (id)
+SELECT user_id FROM posts
+UNION SELECT user_id FROM platforms
+UNION SELECT user_id FROM post_destinations
+UNION SELECT user_id FROM notifications;
+
+INSERT INTO user_identities (provider, provider_user_id, user_id)
+SELECT 'google', id, id FROM users;
+
+ALTER TABLE posts ADD CONSTRAINT fk_posts_user
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+ALTER TABLE platforms ADD CONSTRAINT fk_platforms_user
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+ALTER TABLE post_destinations ADD CONSTRAINT fk_post_destinations_user
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+ALTER TABLE notifications ADD CONSTRAINT fk_notifications_user
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
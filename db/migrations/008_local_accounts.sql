@@ -0,0 +1,20 @@
+-- Вход по email/паролю: identity с provider = 'local', provider_user_id = email в нижнем регистре
+ALTER TABLE users ADD COLUMN password_hash TEXT;
+ALTER TABLE users ADD COLUMN email_verified_at TIMESTAMP WITH TIME ZONE;
+-- Защита от перебора: после нескольких неудачных попыток вход блокируется до locked_until
+ALTER TABLE users ADD COLUMN failed_logins INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN locked_until TIMESTAMP WITH TIME ZONE;
+
+-- Одноразовые токены из писем. Хранится только sha256.
+CREATE TABLE auth_tokens (
+    token_hash CHAR(64) PRIMARY KEY,
+    user_id VARCHAR(255) NOT NULL,
+    purpose VARCHAR(20) NOT NULL CHECK (purpose IN ('verify_email', 'reset_password')),
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT fk_auth_tokens_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_auth_tokens_user_id ON auth_tokens(user_id);
@@ -0,0 +1,77 @@
+-- Рабочие пространства: платформы и посты принадлежат пространству, а не пользователю.
+-- user_id в posts/platforms/post_destinations остаётся автором записи.
+CREATE TABLE workspaces (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE workspace_members (
+    workspace_id INTEGER NOT NULL,
+    user_id VARCHAR(255) NOT NULL,
+    role VARCHAR(20) NOT NULL CHECK (role IN ('owner', 'admin', 'editor', 'viewer')),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY (workspace_id, user_id),
+    CONSTRAINT fk_workspace_members_workspace FOREIGN KEY (workspace_id) REFERENCES workspaces(id) ON DELETE CASCADE,
+    CONSTRAINT fk_workspace_members_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_workspace_members_user_id ON workspace_members(user_id);
+
+-- Приглашения по ссылке из письма. Хранится только хэш токена.
+CREATE TABLE workspace_invitations (
+    token_hash CHAR(64) PRIMARY KEY,
+    workspace_id INTEGER NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    role VARCHAR(20) NOT NULL CHECK (role IN ('owner', 'admin', 'editor', 'viewer')),
+    invited_by VARCHAR(255) NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    accepted_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT fk_workspace_invitations_workspace FOREIGN KEY (workspace_id) REFERENCES workspaces(id) ON DELETE CASCADE,
+    CONSTRAINT fk_workspace_invitations_user FOREIGN KEY (invited_by) REFERENCES users(id) ON DELETE CASCADE
+);
+
+ALTER TABLE posts ADD COLUMN workspace_id INTEGER;
+ALTER TABLE platforms ADD COLUMN workspace_id INTEGER;
+ALTER TABLE post_destinations ADD COLUMN workspace_id INTEGER;
+
+-- У каждого существующего пользователя появляется личное пространство, все его данные переезжают туда.
+-- id пространства временно храним в users, чтобы связать пары без цикла.
+ALTER TABLE users ADD COLUMN personal_workspace_id INTEGER;
+
+DO $$
+DECLARE
+    u RECORD;
+    ws_id INTEGER;
+BEGIN
+    FOR u IN SELECT id FROM users LOOP
+        INSERT INTO workspaces (name) VALUES ('Personal') RETURNING id INTO ws_id;
+        INSERT INTO workspace_members (workspace_id, user_id, role) VALUES (ws_id, u.id, 'owner');
+        UPDATE users SET personal_workspace_id = ws_id WHERE id = u.id;
+    END LOOP;
+END $$;
+
+UPDATE posts p SET workspace_id = u.personal_workspace_id FROM users u WHERE u.id = p.user_id;
+UPDATE platforms p SET workspace_id = u.personal_workspace_id FROM users u WHERE u.id = p.user_id;
+UPDATE post_destinations d SET workspace_id = u.personal_workspace_id FROM users u WHERE u.id = d.user_id;
+
+ALTER TABLE users DROP COLUMN personal_workspace_id;
+
+ALTER TABLE posts ALTER COLUMN workspace_id SET NOT NULL;
+ALTER TABLE platforms ALTER COLUMN workspace_id SET NOT NULL;
+ALTER TABLE post_destinations ALTER COLUMN workspace_id SET NOT NULL;
+
+ALTER TABLE posts ADD CONSTRAINT fk_posts_workspace
+    FOREIGN KEY (workspace_id) REFERENCES workspaces(id) ON DELETE CASCADE;
+ALTER TABLE platforms ADD CONSTRAINT fk_platforms_workspace
+    FOREIGN KEY (workspace_id) REFERENCES workspaces(id) ON DELETE CASCADE;
+ALTER TABLE post_destinations ADD CONSTRAINT fk_post_destinations_workspace
+    FOREIGN KEY (workspace_id) REFERENCES workspaces(id) ON DELETE CASCADE;
+
+CREATE INDEX idx_posts_workspace_id ON posts(workspace_id);
+CREATE INDEX idx_platforms_workspace_id ON platforms(workspace_id);
+CREATE INDEX idx_post_destinations_workspace_id ON post_destinations(workspace_id);
@@ -0,0 +1,36 @@
+-- Редакционный процесс: draft -> in_review -> approved -> scheduled.
+-- Планировщик берёт только направления постов в статусе scheduled.
+ALTER TABLE posts ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'draft'
+    CHECK (status IN ('draft', 'in_review', 'approved', 'scheduled'));
+-- Одобрения засчитываются только после последней отправки на проверку
+ALTER TABLE posts ADD COLUMN submitted_at TIMESTAMP WITH TIME ZONE;
+
+-- Существующие посты уже запланированы
+UPDATE posts SET status = 'scheduled', submitted_at = created_at;
+
+-- Направления неодобренного поста ждут в статусе draft
+ALTER TABLE post_destinations DROP CONSTRAINT IF EXISTS post_destinations_status_check;
+ALTER TABLE post_destinations ADD CONSTRAINT post_destinations_status_check
+    CHECK (status IN ('draft', 'scheduled', 'published', 'failed', 'processing', 'held', 'skipped'));
+
+-- Сколько одобрений нужно посту. Для платформы NULL — как в пространстве,
+-- платформа может только повысить требование пространства.
+ALTER TABLE workspaces ADD COLUMN required_approvals INTEGER NOT NULL DEFAULT 0
+    CHECK (required_approvals >= 0);
+ALTER TABLE platforms ADD COLUMN required_approvals INTEGER
+    CHECK (required_approvals >= 0);
+
+-- История проверки: отправки, одобрения и отклонения с комментарием
+CREATE TABLE post_reviews (
+    id SERIAL PRIMARY KEY,
+    post_id INTEGER NOT NULL,
+    user_id VARCHAR(255) NOT NULL,
+    action VARCHAR(20) NOT NULL CHECK (action IN ('submitted', 'approved', 'rejected')),
+    comment TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT fk_post_reviews_post FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
+    CONSTRAINT fk_post_reviews_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_post_reviews_post_id ON post_reviews(post_id, created_at);
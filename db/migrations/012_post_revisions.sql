@@ -0,0 +1,27 @@
+-- Неизменяемые ревизии поста: каждая правка сохраняет снимок полей и список изменённых
+CREATE TABLE post_revisions (
+    post_id INTEGER NOT NULL,
+    revision INTEGER NOT NULL,
+    user_id VARCHAR(255) NOT NULL,
+    title VARCHAR(255) NOT NULL,
+    content TEXT NOT NULL,
+    scheduled_for TIMESTAMP WITH TIME ZONE,
+    changed_fields TEXT[] NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY (post_id, revision),
+    CONSTRAINT fk_post_revisions_post FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE
+);
+
+-- Текущая ревизия поста и ревизия, ушедшая на платформу
+ALTER TABLE posts ADD COLUMN revision INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE post_destinations ADD COLUMN revision INTEGER;
+
+-- Существующие посты получают первую ревизию из текущего состояния
+INSERT INTO post_revisions (post_id, revision, user_id, title, content, scheduled_for, changed_fields, created_at)
+SELECT p.id, 1, p.user_id, p.title, p.content,
+    (SELECT MIN(d.scheduled_for) FROM post_destinations d WHERE d.post_id = p.id),
+    ARRAY['title', 'content', 'sheduled_for'], p.created_at
+FROM posts p;
+
+UPDATE post_destinations SET revision = 1 WHERE status = 'published';
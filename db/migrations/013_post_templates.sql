@@ -0,0 +1,19 @@
+-- Шаблоны постов пространства. В title/content могут быть переменные {{name}}.
+CREATE TABLE post_templates (
+    id SERIAL PRIMARY KEY,
+    workspace_id INTEGER NOT NULL,
+    user_id VARCHAR(255) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    title VARCHAR(255) NOT NULL,
+    content TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT fk_post_templates_workspace FOREIGN KEY (workspace_id) REFERENCES workspaces(id) ON DELETE CASCADE,
+    CONSTRAINT uq_post_templates_name UNIQUE (workspace_id, name)
+);
+
+-- Пост из шаблона хранит значения переменных, которые зависят от платформы:
+-- они подставляются при публикации на каждую платформу.
+ALTER TABLE posts ADD COLUMN template_id INTEGER REFERENCES post_templates(id) ON DELETE SET NULL;
+ALTER TABLE posts ADD COLUMN variables JSONB;
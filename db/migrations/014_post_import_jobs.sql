@@ -0,0 +1,20 @@
+-- Фоновый импорт постов из больших файлов. Ошибки хранятся по строкам файла.
+CREATE TABLE post_import_jobs (
+    id UUID PRIMARY KEY,
+    workspace_id INTEGER NOT NULL,
+    user_id VARCHAR(255) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    all_or_nothing BOOLEAN NOT NULL DEFAULT FALSE,
+    total INTEGER NOT NULL DEFAULT 0,
+    created INTEGER NOT NULL DEFAULT 0,
+    failed INTEGER NOT NULL DEFAULT 0,
+    errors JSONB NOT NULL DEFAULT '[]',
+    post_ids INTEGER[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    finished_at TIMESTAMP WITH TIME ZONE,
+
+    CONSTRAINT fk_post_import_jobs_workspace FOREIGN KEY (workspace_id) REFERENCES workspaces(id) ON DELETE CASCADE,
+    CONSTRAINT chk_post_import_jobs_status CHECK (status IN ('pending', 'running', 'done', 'failed'))
+);
+
+CREATE INDEX idx_post_import_jobs_workspace ON post_import_jobs(workspace_id, created_at DESC);
@@ -0,0 +1,2 @@
+-- Идентификатор публикации на платформе: message_id в Telegram, <owner>_<post_id> в VK
+ALTER TABLE post_destinations ADD COLUMN remote_id VARCHAR(255);
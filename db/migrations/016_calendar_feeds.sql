@@ -0,0 +1,9 @@
+-- Секретная ссылка на календарь публикаций пользователя. Хранится только sha256 токена.
+CREATE TABLE calendar_feeds (
+    user_id VARCHAR(255) PRIMARY KEY,
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    last_used_at TIMESTAMP WITH TIME ZONE,
+
+    CONSTRAINT fk_calendar_feeds_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
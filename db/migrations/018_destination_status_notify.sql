@@ -0,0 +1,23 @@
+-- Смена статуса публикации рассылается через NOTIFY destination_status всем экземплярам API,
+-- они передают её в поток GET /events. Текст ошибки обрезается: payload NOTIFY не больше 8000 байт.
+CREATE OR REPLACE FUNCTION notify_destination_status() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_notify('destination_status', json_build_object(
+        'id_destination', NEW.id,
+        'id_post', NEW.post_id,
+        'id_workspace', NEW.workspace_id,
+        'id_platform', NEW.platform_id,
+        'status', NEW.status,
+        'previous_status', OLD.status,
+        'error_message', left(NEW.error_message, 1000),
+        'changed_at', NOW()
+    )::text);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_destination_status
+    AFTER UPDATE OF status ON post_destinations
+    FOR EACH ROW
+    WHEN (OLD.status IS DISTINCT FROM NEW.status)
+    EXECUTE FUNCTION notify_destination_status();
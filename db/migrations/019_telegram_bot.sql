@@ -0,0 +1,14 @@
+-- Чат Telegram, привязанный к пользователю для бота управления расписанием.
+-- Привязка: одноразовый код из POST /me/telegram передаётся боту командой /start <код>.
+CREATE TABLE telegram_chats (
+    user_id VARCHAR(255) PRIMARY KEY,
+    chat_id BIGINT NOT NULL UNIQUE,
+    username VARCHAR(255) NOT NULL DEFAULT '',
+    linked_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT fk_telegram_chats_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+ALTER TABLE auth_tokens DROP CONSTRAINT auth_tokens_purpose_check;
+ALTER TABLE auth_tokens ADD CONSTRAINT auth_tokens_purpose_check
+    CHECK (purpose IN ('verify_email', 'reset_password', 'link_telegram'));
@@ -0,0 +1,40 @@
+-- Уведомления рассылаются по каналам владельца (email, Telegram, webhook) согласно его настройкам.
+-- dispatched_at — уведомление забрано рассыльщиком; NULL — ждёт отправки.
+ALTER TABLE notifications ADD COLUMN workspace_id INTEGER;
+ALTER TABLE notifications ADD COLUMN post_id INTEGER;
+ALTER TABLE notifications ADD COLUMN destination_id INTEGER;
+ALTER TABLE notifications ADD COLUMN dispatched_at TIMESTAMP WITH TIME ZONE;
+
+-- прежние уведомления уже видны в GET /notifications, по каналам не рассылаются
+UPDATE notifications SET dispatched_at = created_at;
+UPDATE notifications n SET workspace_id = p.workspace_id FROM platforms p WHERE p.id = n.platform_id;
+
+ALTER TABLE notifications ADD CONSTRAINT fk_notifications_workspace
+    FOREIGN KEY (workspace_id) REFERENCES workspaces(id) ON DELETE SET NULL;
+ALTER TABLE notifications ADD CONSTRAINT fk_notifications_post
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE SET NULL;
+ALTER TABLE notifications ADD CONSTRAINT fk_notifications_destination
+    FOREIGN KEY (destination_id) REFERENCES post_destinations(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_notifications_pending ON notifications(user_id, created_at) WHERE dispatched_at IS NULL;
+CREATE INDEX idx_notifications_destination ON notifications(destination_id, kind);
+
+-- Настройки каналов. Нет строки — настройки по умолчанию (domain.DefaultNotificationSettings).
+-- delivery: immediate — сразу, собирая уведомления за batch_minutes в одно сообщение;
+-- hourly — сводка в начале часа; daily — сводка в digest_hour по часовому поясу пользователя.
+CREATE TABLE notification_settings (
+    user_id VARCHAR(255) PRIMARY KEY,
+    email BOOLEAN NOT NULL,
+    telegram BOOLEAN NOT NULL,
+    webhook BOOLEAN NOT NULL,
+    kinds TEXT[] NOT NULL,
+    delivery VARCHAR(20) NOT NULL CHECK (delivery IN ('immediate', 'hourly', 'daily')),
+    batch_minutes INTEGER NOT NULL CHECK (batch_minutes BETWEEN 0 AND 60),
+    digest_hour INTEGER NOT NULL CHECK (digest_hour BETWEEN 0 AND 23),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT fk_notification_settings_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Начало обработки публикации: по нему находятся зависшие в processing
+ALTER TABLE post_destinations ADD COLUMN processing_started_at TIMESTAMP WITH TIME ZONE;
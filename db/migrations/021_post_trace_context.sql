@@ -0,0 +1,3 @@
+-- Контекст трассы запроса, создавшего пост (W3C traceparent).
+-- Публикация идёт в отдельной трассе со ссылкой на эту.
+ALTER TABLE posts ADD COLUMN trace_parent TEXT;
@@ -0,0 +1,3 @@
+-- Пространство, выбранное через /workspaces/:id/switch. Сохраняется в сессии,
+-- чтобы обновлённый access токен не возвращался к пространству по умолчанию.
+ALTER TABLE sessions ADD COLUMN workspace_id INTEGER;
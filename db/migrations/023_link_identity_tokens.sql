@@ -0,0 +1,5 @@
+-- Одноразовые токены привязки провайдера входа: ссылка из POST /me/identities/:provider
+-- и state OAuth, по которому callback узнаёт аккаунт.
+ALTER TABLE auth_tokens DROP CONSTRAINT auth_tokens_purpose_check;
+ALTER TABLE auth_tokens ADD CONSTRAINT auth_tokens_purpose_check
+    CHECK (purpose IN ('verify_email', 'reset_password', 'link_telegram', 'link_identity'));
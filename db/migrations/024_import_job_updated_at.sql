@@ -0,0 +1,3 @@
+-- Время последнего сохранения прогресса задачи импорта. Задачи, которые давно
+-- не обновлялись (экземпляр перезапустили посреди импорта), при старте помечаются failed.
+ALTER TABLE post_import_jobs ADD COLUMN updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP;
@@ -0,0 +1,3 @@
+-- Версия учётных данных платформы, растёт при каждом изменении api_config.
+-- Результат фоновой проверки записывается, только если проверялась текущая версия.
+ALTER TABLE platforms ADD COLUMN config_version INTEGER NOT NULL DEFAULT 0;
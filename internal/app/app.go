@@ -3,16 +3,30 @@ package app
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"hexlet/internal/auth"
+	"hexlet/internal/config"
 	"hexlet/internal/domain"
 	"hexlet/internal/handler" //docker-compose logs hexlet-project -f
-	"hexlet/internal/kafka"   //docker-compose up -d --build
+	"hexlet/internal/health"
+	"hexlet/internal/kafka" //docker-compose up -d --build
+	"hexlet/internal/mailer"
+	"hexlet/internal/metrics"
+	"hexlet/internal/posttemplate"
 	"hexlet/internal/repository"
+	"hexlet/internal/secrets"
 	"hexlet/internal/service"
+	"hexlet/internal/statusfeed"
+	"hexlet/internal/tgbot"
+	"hexlet/internal/tracing"
+	"hexlet/internal/verification"
+	"hexlet/internal/webhook"
 	"log"
 	"net/http"
 	"net/url"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
 	"sync"
 	"syscall"
@@ -22,32 +36,106 @@ import (
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/jackc/pgx/v4/pgxpool"
 	kf "github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
+const (
+	publicationsTopic = "publications.pending"
+	publicationsGroup = "hexlet-publications-worker"
+	// оркестратор успевает заметить неготовность и снять экземпляр с балансировки
+	readinessDrain = 5 * time.Second
+	// запросы в работе успевают завершиться до закрытия соединений
+	shutdownTimeout = 15 * time.Second
+)
+
 type App struct {
 	Ctx       context.Context
 	Repo      *repository.Repository
 	Handler   *handler.App
 	Scheduler *service.SchedulerService
+	Health    *service.HealthMonitor
+	Webhooks  *service.WebhookDispatcher
+	Status    *service.StatusListener
+	Bot       *tgbot.Bot
+	Notifier  *service.NotificationDispatcher
+	Readiness *health.Checker
+	Brokers   []string
+	ClientID  string
 	Counter   int
 	Wg        sync.WaitGroup
 	Cancel    context.CancelFunc
+	// закрывает потоки GET /events при остановке HTTP-сервера
+	CloseStreams context.CancelFunc
 }
 
-func NewApp(ctx context.Context, masterdbpool *pgxpool.Pool, slavedbpool *pgxpool.Pool, logger *zap.Logger) *App {
-	repo := repository.NewRepository(masterdbpool, slavedbpool, logger)
+func NewApp(
+	ctx context.Context,
+	masterdbpool *pgxpool.Pool,
+	slavedbpool *pgxpool.Pool,
+	keyring *secrets.Keyring,
+	tokens *auth.TokenIssuer,
+	authConfig *config.AuthConfig,
+	mailConfig *config.MailConfig,
+	botConfig *config.BotConfig,
+	logger *zap.Logger,
+) *App {
+	// отменяется в WaitForShutdown и останавливает все фоновые задачи
+	ctx, cancel := context.WithCancel(ctx)
+	repo := repository.NewRepository(masterdbpool, slavedbpool, keyring, logger)
+	if err := metrics.RegisterPools(map[string]*pgxpool.Pool{"master": masterdbpool, "replica": slavedbpool}); err != nil {
+		log.Printf("Pool metrics are disabled: %v", err)
+	}
+	verifier := verification.NewClient(
+		&http.Client{Timeout: 10 * time.Second},
+		verification.DefaultTelegramAPI,
+		verification.DefaultVKAPI,
+	)
+	var mail mailer.Mailer = mailer.LogMailer{ShowBody: mailConfig.LogBodies}
+	if mailConfig.SMTPHost != "" {
+		mail = mailer.NewSMTPMailer(mailConfig.SMTPHost, mailConfig.SMTPPort, mailConfig.From, mailConfig.SMTPUsername, mailConfig.SMTPPassword)
+	}
+	events := statusfeed.NewHub(64)
+	kafkaBrokers := getKafkaBrokers()
+	clientID := consumerClientID()
+	readiness := health.NewChecker(3 * time.Second)
+	readiness.Add("master", health.PostgresCheck(masterdbpool))
+	readiness.Add("replica", health.ReplicaCheck(slavedbpool, 30*time.Second))
+	readiness.Add("kafka", health.KafkaCheck(kafkaBrokers, publicationsTopic))
+	readiness.Add("consumer_group", health.ConsumerGroupCheck(kafkaBrokers, publicationsGroup, clientID))
+	// потоки GET /events — активные соединения, без отмены Shutdown ждал бы их до таймаута
+	streams, closeStreams := context.WithCancel(ctx)
 	handlerApp := &handler.App{
-		Ctx:  ctx,
-		Repo: repo,
+		Ctx:       ctx,
+		Streams:   streams,
+		Repo:      repo,
+		Verifier:  verifier,
+		Mailer:    mail,
+		PublicURL: authConfig.PublicURL,
+		Tokens:    tokens,
+		Events:    events,
+		Readiness: readiness,
+	}
+	var bot *tgbot.Bot
+	var telegram service.TelegramNotifier
+	if botConfig.Token != "" {
+		// клиент ждёт дольше, чем long polling getUpdates
+		var err error
+		bot, err = tgbot.New(botConfig.Token, botConfig.APIEndpoint, &http.Client{Timeout: 40 * time.Second}, repo)
+		if err != nil {
+			log.Printf("Telegram bot is disabled: %v", err)
+		} else {
+			handlerApp.TelegramBot = bot.Username()
+			telegram = bot
+		}
 	}
 	var scheduler *service.SchedulerService
-	kafkaBrokers := getKafkaBrokers()
 
 	if len(kafkaBrokers) > 0 {
 		kafkaConfig := kafka.NewConfig(
 			kafkaBrokers,
-			"publications.pending",
+			publicationsTopic,
 		)
 		kafkaProducer := kafka.NewProducer(kafkaConfig)
 		scheduler = service.NewSchedulerService(
@@ -57,15 +145,22 @@ func NewApp(ctx context.Context, masterdbpool *pgxpool.Pool, slavedbpool *pgxpoo
 			100,
 		)
 	}
-	_, cancel := context.WithCancel(context.Background())
-
 	return &App{
-		Ctx:       ctx,
-		Repo:      repo,
-		Handler:   handlerApp,
-		Scheduler: scheduler,
-		Cancel:    cancel,
-		Counter:   1,
+		Ctx:          ctx,
+		Repo:         repo,
+		Handler:      handlerApp,
+		Scheduler:    scheduler,
+		Health:       service.NewHealthMonitor(repo, verifier, 30*time.Minute, 1, 3),
+		Webhooks:     service.NewWebhookDispatcher(repo, webhook.NewClient(10*time.Second), 10*time.Second, 50),
+		Status:       service.NewStatusListener(repo, events, 5*time.Second),
+		Bot:          bot,
+		Notifier:     service.NewNotificationDispatcher(repo, mail, telegram, events, time.Minute, domain.StuckProcessingAfter, 100),
+		Readiness:    readiness,
+		Brokers:      kafkaBrokers,
+		ClientID:     clientID,
+		Cancel:       cancel,
+		CloseStreams: closeStreams,
+		Counter:      1,
 	}
 }
 
@@ -73,6 +168,19 @@ func (a *App) Routes(r *gin.Engine) {
 	a.Handler.Routes(r)
 }
 
+// FailStaleImports помечает failed задачи импорта, оборванные остановкой экземпляра.
+// idle с запасом больше времени между сохранениями прогресса живой задачи.
+func (a *App) FailStaleImports() {
+	n, err := a.Repo.FailStaleImportJobs(a.Ctx, 30*time.Minute)
+	if err != nil {
+		log.Printf("Error failing stale import jobs: %v", err)
+		return
+	}
+	if n > 0 {
+		log.Printf("Marked %d interrupted import job(s) as failed", n)
+	}
+}
+
 func (a *App) StartScheduler() {
 	if a.Scheduler != nil {
 		go a.Scheduler.Start(a.Ctx)
@@ -82,6 +190,78 @@ func (a *App) StartScheduler() {
 	}
 }
 
+func (a *App) StartHealthMonitor() {
+	go a.Health.Start(a.Ctx)
+}
+
+func (a *App) StartWebhookDispatcher() {
+	go a.Webhooks.Start(a.Ctx)
+}
+
+func (a *App) StartStatusListener() {
+	go a.Status.Start(a.Ctx)
+}
+
+// StartTelegramBot запускает бот на одном экземпляре: getUpdates не допускает
+// нескольких читателей одного токена
+func (a *App) StartTelegramBot() {
+	if a.Bot == nil {
+		log.Println("Telegram bot not configured")
+		return
+	}
+	go a.Bot.Run(a.Ctx)
+}
+
+func (a *App) StartNotificationDispatcher() {
+	go a.Notifier.Start(a.Ctx)
+}
+
+// StartConsumer читает публикации из Kafka в группе потребителей и отдаёт их воркерам
+func (a *App) StartConsumer() {
+	go func() {
+		time.Sleep(30 * time.Second)
+		readerConfig := kf.ReaderConfig{
+			Brokers:     a.Brokers,
+			Topic:       publicationsTopic,
+			GroupID:     publicationsGroup,
+			Dialer:      &kf.Dialer{ClientID: a.ClientID, Timeout: 10 * time.Second, DualStack: true},
+			MinBytes:    10e3,
+			MaxBytes:    10e6,
+			MaxWait:     5 * time.Second,
+			StartOffset: kf.FirstOffset,
+		}
+		reader := kf.NewReader(readerConfig)
+		defer reader.Close()
+		log.Println("Kafka consumer started. Waiting for messages...")
+		for {
+			msg, err := reader.ReadMessage(a.Ctx)
+			if err != nil {
+				if err == context.Canceled {
+					log.Println("Kafka consumer stopped")
+					return
+				}
+				log.Printf("Error reading from Kafka: %v", err)
+				time.Sleep(2 * time.Second)
+				continue
+			}
+			log.Printf("Received Kafka message: %s", string(msg.Value))
+			metrics.KafkaConsumed.WithLabelValues(msg.Topic).Inc()
+			metrics.KafkaLag.WithLabelValues(msg.Topic, strconv.Itoa(msg.Partition)).Set(float64(msg.HighWaterMark - msg.Offset - 1))
+			a.StartBackgroundWorker(msg)
+		}
+	}()
+}
+
+// consumerClientID различает экземпляры в группе потребителей:
+// по нему проверка готовности находит этот экземпляр среди участников группы
+func consumerClientID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = strconv.Itoa(os.Getpid())
+	}
+	return "hexlet-" + host
+}
+
 func getKafkaBrokers() []string {
 	brokersEnv := os.Getenv("KAFKA_BROKERS")
 	if brokersEnv == "" {
@@ -103,6 +283,8 @@ func (a *App) StartBackgroundWorker(msg kf.Message) {
 
 func (a *App) backgroundWorker(msg kf.Message) {
 	defer a.Wg.Done()
+	metrics.WorkersInFlight.Inc()
+	defer metrics.WorkersInFlight.Dec()
 
 	ticker := time.NewTicker(10 * time.Second)
 	defer ticker.Stop()
@@ -119,6 +301,8 @@ func (a *App) backgroundWorker(msg kf.Message) {
 }
 
 func (a *App) proccesProcessing(msg kf.Message) {
+	ctx, span := kafka.StartConsumerSpan(a.Ctx, msg)
+	defer span.End()
 	value := string(msg.Value)
 	var msg1 domain.PublicationEvent
 	log.Print(value)
@@ -127,84 +311,225 @@ func (a *App) proccesProcessing(msg kf.Message) {
 		log.Print(err)
 		return
 	}
-	message, err3 := a.Repo.GetTitleANDContent(a.Ctx, msg1.PostID)
+	span.SetAttributes(
+		attribute.Int("publication.destination_id", msg1.DestinationID),
+		attribute.Int("publication.post_id", msg1.PostID),
+	)
+	started, err := a.Repo.StartProcessing(ctx, msg1.DestinationID)
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	if !started {
+		// публикацию уже отправили, сняли или повторно поставили в очередь
+		log.Printf("Destination %d is not scheduled, skipped", msg1.DestinationID)
+		return
+	}
+	message, err3 := a.Repo.GetTitleANDContent(ctx, msg1.PostID)
 	if err3 != nil {
-		log.Print(err3)
+		a.failProcessing(ctx, msg1.DestinationID, err3)
 		return
 	}
-	text := message.Title + "\n" + message.Content
-	platformTG, err2 := a.Repo.GetPlatformsByUserID(a.Ctx, "Telegram", msg1.UserID)
+	platform, err2 := a.Repo.GetPlatformConfigByID(ctx, msg1.PlatformID)
 	if err2 != nil {
-		log.Print(err2)
-	} else {
-		for chatID, botToken := range platformTG.APIConfig {
-			err := SentToTelegram(chatID, botToken, text)
-			if err != nil {
-				err1 := a.Repo.ErrorMessage(a.Ctx, msg1.DestinationID, err)
-				if err1 != nil {
-					log.Print(err1)
-				}
-			}
+		a.failProcessing(ctx, msg1.DestinationID, err2)
+		return
+	}
+	if !platform.IsActive {
+		// платформа отключена: публикация ждёт её включения
+		err = a.Repo.HoldDestination(ctx, msg1.DestinationID)
+		if err != nil {
+			a.failProcessing(ctx, msg1.DestinationID, err)
 		}
+		return
 	}
-	platformVK, err2 := a.Repo.GetPlatformsByUserID(a.Ctx, "VK", msg1.UserID)
-	if err2 != nil {
-		log.Print(err2)
-	} else {
-		for groupID, token := range platformVK.APIConfig {
-			err := SentToVK(groupID, token, text)
-			if err != nil {
-				err1 := a.Repo.ErrorMessage(a.Ctx, msg1.DestinationID, err)
-				if err1 != nil {
-					log.Print(err1)
-				}
-			}
+	var remoteID string
+	start := time.Now()
+	text, err := render(message, platform.PlatformName)
+	if err == nil {
+		remoteID, err = publish(ctx, platform, text)
+	}
+	outcome := metrics.OutcomePublished
+	if err != nil {
+		outcome = metrics.OutcomeFailed
+	}
+	metrics.PublishDuration.WithLabelValues(platform.PlatformName, outcome).Observe(metrics.Since(start))
+	if err != nil {
+		// уведомление пишется до смены статуса: событие failed будит рассылку уведомлений
+		a.notifyFailure(ctx, msg1, platform.PlatformName, message.Title, err)
+		err1 := a.Repo.ErrorMessage(ctx, msg1.DestinationID, err)
+		if err1 != nil {
+			log.Print(err1)
+			return
 		}
+		a.destinationEvent(ctx, domain.EventDestinationFailed, msg1.DestinationID)
+		return
 	}
-	err4 := a.Repo.MarkAsSent(a.Ctx, msg1.DestinationID)
+	err4 := a.Repo.MarkAsSent(ctx, msg1.DestinationID, message.Revision, remoteID)
 	if err4 != nil {
-		log.Print(err4)
+		// публикация уже на платформе, повторять её стоит только после проверки
+		a.failProcessing(ctx, msg1.DestinationID, fmt.Errorf("published as %q, but the status is not saved: %w", remoteID, err4))
+		return
 	}
+	a.destinationEvent(ctx, domain.EventDestinationPublished, msg1.DestinationID)
 }
 
-func (a *App) WaitForShutdown() {
+// failProcessing снимает публикацию с processing, когда её не удалось довести до конца.
+// Если не записалась и ошибка, публикация остаётся зависшей: её можно повторить
+// после domain.StuckProcessingAfter.
+func (a *App) failProcessing(ctx context.Context, destinationID int, err error) {
+	log.Print(err)
+	if err := a.Repo.ErrorMessage(ctx, destinationID, err); err != nil {
+		log.Print(err)
+		return
+	}
+	a.destinationEvent(ctx, domain.EventDestinationFailed, destinationID)
+}
+
+// destinationEvent рассылает подписчикам новое состояние публикации
+func (a *App) destinationEvent(ctx context.Context, event string, destinationID int) {
+	data, err := a.Repo.GetDestinationEvent(ctx, destinationID)
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	_, err = a.Repo.EnqueueWebhookEvent(ctx, domain.WebhookEvent{
+		Event:        event,
+		ID_workspace: data.ID_workspace,
+		ID_post:      data.ID_post,
+		ID_author:    data.ID_user,
+		Data:         data,
+	})
+	if err != nil {
+		log.Print(err)
+	}
+}
+
+// notifyFailure сообщает автору поста о неудачной публикации по его каналам уведомлений
+func (a *App) notifyFailure(ctx context.Context, e domain.PublicationEvent, platformName string, title string, failure error) {
+	platformID, postID, destinationID := e.PlatformID, e.PostID, e.DestinationID
+	err := a.Repo.CreateNotification(ctx, domain.Notification{
+		ID_user:        e.UserID,
+		Kind:           domain.NotificationPublicationFailed,
+		Message:        fmt.Sprintf("%s publication of post %d %q failed: %v", platformName, postID, title, failure),
+		ID_platform:    &platformID,
+		ID_post:        &postID,
+		ID_destination: &destinationID,
+	})
+	if err != nil {
+		log.Print(err)
+	}
+}
+
+// WaitForShutdown после сигнала снимает экземпляр с балансировки,
+// дожидается запросов в работе и только затем останавливает фоновые задачи.
+func (a *App) WaitForShutdown(servers ...*http.Server) {
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
 	<-stop
 	log.Println("Shutdown")
+	a.Readiness.Drain()
+	time.Sleep(readinessDrain)
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	for _, srv := range servers {
+		srv.RegisterOnShutdown(a.CloseStreams)
+		if err := srv.Shutdown(ctx); err != nil {
+			log.Printf("http shutdown: %v", err)
+		}
+	}
 	a.Cancel()
 	a.Wg.Wait()
 	log.Println("Shutdown complete")
 }
 
-func SentToTelegram(chatID string, botToken string, text string) error {
-	bot, err := tgbotapi.NewBotAPI(botToken)
+// render собирает текст публикации. В посте из шаблона переменные
+// подставляются для конкретной платформы в момент публикации, дата и время — в поясе автора.
+func render(message domain.Message, platformName string) (string, error) {
+	text := message.Title + "\n" + message.Content
+	if message.Variables == nil {
+		return text, nil
+	}
+	loc, err := time.LoadLocation(message.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	return posttemplate.Render(text, message.Variables.For(platformName), platformName, time.Now(), loc)
+}
+
+// publish возвращает идентификатор публикации на платформе
+func publish(ctx context.Context, platform domain.PlatformSQL, text string) (remoteID string, err error) {
+	_, span := tracing.Start(ctx, "publish "+platform.PlatformName,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.Int("platform.id", platform.ID)),
+	)
+	defer func() {
+		span.SetAttributes(attribute.String("publication.remote_id", remoteID))
+		tracing.End(span, err)
+	}()
+	switch {
+	case platform.PlatformName == domain.PlatformTelegram && platform.Config.Telegram != nil:
+		return SentToTelegram(*platform.Config.Telegram, text)
+	case platform.PlatformName == domain.PlatformVK && platform.Config.VK != nil:
+		return SentToVK(*platform.Config.VK, text)
+	default:
+		return "", fmt.Errorf("platform %d has no %s config", platform.ID, platform.PlatformName)
+	}
+}
+
+func SentToTelegram(cfg domain.TelegramConfig, text string) (string, error) {
+	bot, err := tgbotapi.NewBotAPI(cfg.BotToken)
 	if err != nil {
 		log.Println("Ошибка создания бота(Telegramm):", err)
-		return err
+		return "", err
 	}
 	log.Printf("Авторизован как %s", bot.Self.UserName)
-	msg := tgbotapi.NewMessageToChannel(chatID, text)
-	_, err = bot.Send(msg)
+	msg := tgbotapi.NewMessageToChannel(cfg.ChatID, text)
+	msg.DisableNotification = cfg.Silent
+	msg.DisableWebPagePreview = cfg.DisableLinkPreviews
+	sent, err := bot.Send(msg)
 	if err != nil {
 		log.Println("Ошибка отправки(Telegramm):", err)
-		return err
+		return "", err
 	}
-	return nil
+	return strconv.Itoa(sent.MessageID), nil
 }
 
-func SentToVK(groupID string, token string, text string) error {
+func SentToVK(cfg domain.VKConfig, text string) (string, error) {
 	apiURL := "https://api.vk.com/method/wall.post"
 	params := url.Values{}
-	params.Add("owner_id", groupID)
+	params.Add("owner_id", cfg.OwnerID)
 	params.Add("message", text)
-	params.Add("access_token", token)
+	if cfg.FromGroup {
+		params.Add("from_group", "1")
+	}
+	params.Add("access_token", cfg.AccessToken)
 	params.Add("v", "5.131")
 	resp, err := http.PostForm(apiURL, params)
 	if err != nil {
 		log.Println("Ошибка отправки(VK):", err)
-		return err
+		return "", err
 	}
 	defer resp.Body.Close()
-	return nil
+	// VK сообщает об ошибках с кодом 200 в поле error
+	var res struct {
+		Response struct {
+			PostID int `json:"post_id"`
+		} `json:"response"`
+		Error *struct {
+			Code    int    `json:"error_code"`
+			Message string `json:"error_msg"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		log.Println("Ошибка ответа(VK):", err)
+		return "", fmt.Errorf("vk: decode response (HTTP %d): %w", resp.StatusCode, err)
+	}
+	if res.Error != nil {
+		return "", fmt.Errorf("vk: %d %s", res.Error.Code, res.Error.Message)
+	}
+	if res.Response.PostID == 0 {
+		return "", fmt.Errorf("vk: no post_id in response (HTTP %d)", resp.StatusCode)
+	}
+	return fmt.Sprintf("%s_%d", cfg.OwnerID, res.Response.PostID), nil
 }
@@ -0,0 +1,24 @@
+package auth
+
+import "strings"
+
+// По префиксу AuthMiddleware отличает API ключ от JWT
+const (
+	ApiKeyPrefix      = "hxk_"
+	apiKeyVisiblePart = 12
+)
+
+// NewApiKey возвращает ключ и его видимое начало для списка ключей.
+// В БД хранится только HashToken от ключа.
+func NewApiKey() (key string, prefix string, err error) {
+	token, err := NewOpaqueToken()
+	if err != nil {
+		return "", "", err
+	}
+	key = ApiKeyPrefix + token
+	return key, key[:apiKeyVisiblePart], nil
+}
+
+func IsApiKey(token string) bool {
+	return strings.HasPrefix(token, ApiKeyPrefix)
+}
@@ -1,13 +1,18 @@
 package auth
 
 import (
+	"fmt"
+	"hexlet/internal/config"
 	"net/http"
-	"os"
+	"sort"
 
 	"github.com/gorilla/sessions"
 	"github.com/markbates/goth"
 	"github.com/markbates/goth/gothic"
+	"github.com/markbates/goth/providers/github"
 	"github.com/markbates/goth/providers/google"
+	"github.com/markbates/goth/providers/vk"
+	"github.com/markbates/goth/providers/yandex"
 )
 
 const (
@@ -16,7 +21,23 @@ const (
 	IsProd = false
 )
 
-func NewAuth() {
+// Поддерживаемые провайдеры входа, включаются через AUTH_PROVIDERS
+var providerFactories = map[string]func(key, secret, callbackURL string) goth.Provider{
+	"google": func(key, secret, callbackURL string) goth.Provider {
+		return google.New(key, secret, callbackURL, "email", "profile")
+	},
+	"github": func(key, secret, callbackURL string) goth.Provider {
+		return github.New(key, secret, callbackURL, "read:user", "user:email")
+	},
+	"yandex": func(key, secret, callbackURL string) goth.Provider {
+		return yandex.New(key, secret, callbackURL, "login:email", "login:info", "login:avatar")
+	},
+	"vk": func(key, secret, callbackURL string) goth.Provider {
+		return vk.New(key, secret, callbackURL, "email")
+	},
+}
+
+func NewAuth(cfg *config.AuthConfig) error {
 	store := sessions.NewCookieStore([]byte(key))
 	store.MaxAge(MaxAge)
 	store.Options.Path = "/"
@@ -24,15 +45,26 @@ func NewAuth() {
 	store.Options.Secure = IsProd
 	store.Options.SameSite = http.SameSiteLaxMode
 	gothic.Store = store
-	goth.UseProviders(
-		google.New(getEnv("GOOGLE_KEY"), getEnv("GOOGLE_SECRET"), "http://localhost:8080/auth/google/callback"),
-	)
-}
 
-func getEnv(key string) string {
-	value, exists := os.LookupEnv(key)
-	if !exists || value == "" {
-		return " "
+	names := make([]string, 0, len(cfg.Providers))
+	for name := range cfg.Providers {
+		names = append(names, name)
 	}
-	return value
+	sort.Strings(names)
+	var providers []goth.Provider
+	for _, name := range names {
+		factory, ok := providerFactories[name]
+		if !ok {
+			return fmt.Errorf("unsupported auth provider %q", name)
+		}
+		p := cfg.Providers[name]
+		providers = append(providers, factory(p.Key, p.Secret, CallbackURL(cfg.PublicURL, name)))
+	}
+	goth.ClearProviders()
+	goth.UseProviders(providers...)
+	return nil
+}
+
+func CallbackURL(publicURL string, provider string) string {
+	return fmt.Sprintf("%s/auth/%s/callback", publicURL, provider)
 }
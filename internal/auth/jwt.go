@@ -1,32 +1,230 @@
 package auth
 
 import (
-	"os"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
+const (
+	AccessTTL  = 15 * time.Minute
+	RefreshTTL = 30 * 24 * time.Hour
+	// срок одноразового токена привязки провайдера входа
+	LinkTTL = 10 * time.Minute
+
+	// refresh токены принимает только этот сервис
+	refreshAudience = "refresh"
+)
+
+var (
+	ErrUnknownKeyID  = errors.New("auth: unknown jwt key id")
+	ErrNoSigningKey  = errors.New("auth: jwt signing key is not configured")
+	ErrInvalidClaims = errors.New("auth: invalid token claims")
+)
+
+// WorkspaceID — выбранное рабочее пространство, 0 — пространство по умолчанию
 type MyClaims struct {
-	UserID string `json:"user_id"`
+	UserID      string `json:"user_id"`
+	SessionID   string `json:"sid,omitempty"`
+	WorkspaceID int    `json:"wid,omitempty"`
 	jwt.RegisteredClaims
 }
 
-func GenerateTokens(userID string) (string, string, error) {
-	// Access Token
-	accessToken := jwt.NewWithClaims(jwt.SigningMethodHS256, MyClaims{
-		UserID: userID,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(15 * time.Minute)),
-		},
-	})
-	aToken, err := accessToken.SignedString([]byte(os.Getenv("JWT_ACCESS_SECRET")))
-	// Refresh Token
-	refreshToken := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
-		Subject:   userID,
-		ExpiresAt: jwt.NewNumericDate(time.Now().Add(30 * 24 * time.Hour)),
+// RefreshClaims: Subject — пользователь, ID (jti) — конкретный токен, SessionID — цепочка токенов одного входа
+type RefreshClaims struct {
+	SessionID string `json:"sid"`
+	jwt.RegisteredClaims
+}
+
+type Tokens struct {
+	Access           string
+	Refresh          string
+	RefreshJTI       string
+	RefreshExpiresAt time.Time
+}
+
+// TokenIssuer подписывает токены EdDSA (Ed25519) активным ключом и проверяет
+// любым из известных ключей по kid. Для ротации новый ключ ставится первым,
+// старый остаётся в списке, пока не истекут выданные им токены.
+type TokenIssuer struct {
+	activeID string
+	keys     map[string]ed25519.PrivateKey
+	issuer   string
+	audience string
+}
+
+func NewTokenIssuer(activeID string, keys map[string]ed25519.PrivateKey, issuer string, audience string) (*TokenIssuer, error) {
+	if _, ok := keys[activeID]; !ok {
+		return nil, ErrNoSigningKey
+	}
+	return &TokenIssuer{activeID: activeID, keys: keys, issuer: issuer, audience: audience}, nil
+}
+
+// ParseSigningKeys разбирает строку вида "k2:<base64 seed>,k1:<base64 seed>",
+// seed — 32 байта Ed25519. Первый ключ в списке используется для подписи.
+func ParseSigningKeys(spec string) (string, map[string]ed25519.PrivateKey, error) {
+	keys := make(map[string]ed25519.PrivateKey)
+	activeID := ""
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		id, encoded, ok := strings.Cut(part, ":")
+		if !ok || id == "" {
+			return "", nil, fmt.Errorf("auth: invalid jwt key entry %q", part)
+		}
+		seed, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return "", nil, fmt.Errorf("auth: jwt key %q is not valid base64: %w", id, err)
+		}
+		if len(seed) != ed25519.SeedSize {
+			return "", nil, fmt.Errorf("auth: jwt key %q must be a %d byte Ed25519 seed", id, ed25519.SeedSize)
+		}
+		keys[id] = ed25519.NewKeyFromSeed(seed)
+		if activeID == "" {
+			activeID = id
+		}
+	}
+	if activeID == "" {
+		return "", nil, ErrNoSigningKey
+	}
+	return activeID, keys, nil
+}
+
+func (i *TokenIssuer) sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+	token.Header["kid"] = i.activeID
+	return token.SignedString(i.keys[i.activeID])
+}
+
+func (i *TokenIssuer) parse(tokenStr string, claims jwt.Claims, audience string) error {
+	_, err := jwt.ParseWithClaims(tokenStr, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		key, ok := i.keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownKeyID, kid)
+		}
+		return key.Public(), nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodEdDSA.Alg()}),
+		jwt.WithIssuer(i.issuer),
+		jwt.WithAudience(audience),
+		jwt.WithExpirationRequired(),
+	)
+	return err
+}
+
+func (i *TokenIssuer) registered(subject string, audience string, ttl time.Duration) jwt.RegisteredClaims {
+	now := time.Now()
+	return jwt.RegisteredClaims{
+		Issuer:    i.issuer,
+		Subject:   subject,
+		Audience:  jwt.ClaimStrings{audience},
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+	}
+}
+
+func (i *TokenIssuer) GenerateTokens(userID string, sessionID string) (Tokens, error) {
+	access, err := i.GenerateAccessToken(userID, sessionID, 0)
+	if err != nil {
+		return Tokens{}, err
+	}
+	res := Tokens{
+		Access:           access,
+		RefreshJTI:       uuid.NewString(),
+		RefreshExpiresAt: time.Now().Add(RefreshTTL),
+	}
+	refresh := RefreshClaims{
+		SessionID:        sessionID,
+		RegisteredClaims: i.registered(userID, refreshAudience, RefreshTTL),
+	}
+	refresh.ID = res.RefreshJTI
+	refresh.ExpiresAt = jwt.NewNumericDate(res.RefreshExpiresAt)
+	res.Refresh, err = i.sign(refresh)
+	if err != nil {
+		return Tokens{}, err
+	}
+	return res, nil
+}
+
+func (i *TokenIssuer) GenerateAccessToken(userID string, sessionID string, workspaceID int) (string, error) {
+	return i.sign(MyClaims{
+		UserID:           userID,
+		SessionID:        sessionID,
+		WorkspaceID:      workspaceID,
+		RegisteredClaims: i.registered(userID, i.audience, AccessTTL),
 	})
-	rToken, err := refreshToken.SignedString([]byte(os.Getenv("JWT_REFRESH_SECRET")))
+}
+
+func (i *TokenIssuer) ParseAccessToken(token string) (*MyClaims, error) {
+	claims := &MyClaims{}
+	if err := i.parse(token, claims, i.audience); err != nil {
+		return nil, err
+	}
+	if claims.UserID == "" {
+		return nil, ErrInvalidClaims
+	}
+	return claims, nil
+}
+
+func (i *TokenIssuer) ParseRefreshToken(token string) (*RefreshClaims, error) {
+	claims := &RefreshClaims{}
+	if err := i.parse(token, claims, refreshAudience); err != nil {
+		return nil, err
+	}
+	if claims.Subject == "" || claims.ID == "" || claims.SessionID == "" {
+		return nil, ErrInvalidClaims
+	}
+	return claims, nil
+}
+
+type JWK struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS — публичные ключи для проверки токенов другими сервисами (RFC 8037)
+func (i *TokenIssuer) JWKS() JWKS {
+	ids := make([]string, 0, len(i.keys))
+	for id := range i.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	res := JWKS{Keys: []JWK{}}
+	for _, id := range ids {
+		res.Keys = append(res.Keys, JWK{
+			Kty: "OKP",
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(i.keys[id].Public().(ed25519.PublicKey)),
+			Kid: id,
+			Use: "sig",
+			Alg: jwt.SigningMethodEdDSA.Alg(),
+		})
+	}
+	return res
+}
 
-	return aToken, rToken, err
+// HashToken — в БД хранится только sha256 от refresh токена
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
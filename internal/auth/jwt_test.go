@@ -0,0 +1,143 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testSeed(b byte) []byte {
+	seed := make([]byte, ed25519.SeedSize)
+	seed[0] = b
+	return seed
+}
+
+func testIssuer(t *testing.T, spec string) *TokenIssuer {
+	activeID, keys, err := ParseSigningKeys(spec)
+	require.NoError(t, err)
+	issuer, err := NewTokenIssuer(activeID, keys, "https://hexlet.test", "hexlet-api")
+	require.NoError(t, err)
+	return issuer
+}
+
+func keySpec(id string, b byte) string {
+	return id + ":" + base64.StdEncoding.EncodeToString(testSeed(b))
+}
+
+func TestParseSigningKeys(t *testing.T) {
+	activeID, keys, err := ParseSigningKeys(keySpec("k2", 2) + ", " + keySpec("k1", 1))
+	require.NoError(t, err)
+	assert.Equal(t, "k2", activeID)
+	assert.Len(t, keys, 2)
+
+	_, _, err = ParseSigningKeys("")
+	assert.ErrorIs(t, err, ErrNoSigningKey)
+	_, _, err = ParseSigningKeys("k1:" + base64.StdEncoding.EncodeToString([]byte("short")))
+	assert.Error(t, err)
+	_, _, err = ParseSigningKeys("no-separator")
+	assert.Error(t, err)
+}
+
+func TestGenerateTokens(t *testing.T) {
+	issuer := testIssuer(t, keySpec("k1", 1))
+	tokens, err := issuer.GenerateTokens("user-1", "session-1")
+	require.NoError(t, err)
+
+	claims, err := issuer.ParseAccessToken(tokens.Access)
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", claims.UserID)
+	assert.Equal(t, "session-1", claims.SessionID)
+	assert.Equal(t, "https://hexlet.test", claims.Issuer)
+
+	refresh, err := issuer.ParseRefreshToken(tokens.Refresh)
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", refresh.Subject)
+	assert.Equal(t, tokens.RefreshJTI, refresh.ID)
+
+	// токены не взаимозаменяемы: у каждого своя aud
+	_, err = issuer.ParseAccessToken(tokens.Refresh)
+	assert.Error(t, err)
+	_, err = issuer.ParseRefreshToken(tokens.Access)
+	assert.Error(t, err)
+}
+
+func TestTokenIssuer_KeyRotation(t *testing.T) {
+	old := testIssuer(t, keySpec("k1", 1))
+	token, err := old.GenerateAccessToken("user-1", "", 0)
+	require.NoError(t, err)
+
+	// новый ключ подписывает, старый ещё принимается
+	rotated := testIssuer(t, keySpec("k2", 2)+","+keySpec("k1", 1))
+	_, err = rotated.ParseAccessToken(token)
+	assert.NoError(t, err)
+
+	fresh, err := rotated.GenerateAccessToken("user-1", "", 0)
+	require.NoError(t, err)
+	parsed, _, err := jwt.NewParser().ParseUnverified(fresh, &MyClaims{})
+	require.NoError(t, err)
+	assert.Equal(t, "k2", parsed.Header["kid"])
+
+	// старый ключ выведен из списка
+	retired := testIssuer(t, keySpec("k2", 2))
+	_, err = retired.ParseAccessToken(token)
+	assert.ErrorIs(t, err, ErrUnknownKeyID)
+}
+
+func TestParseAccessToken_Rejects(t *testing.T) {
+	issuer := testIssuer(t, keySpec("k1", 1))
+	_, keys, err := ParseSigningKeys(keySpec("k1", 1))
+	require.NoError(t, err)
+
+	other, err := NewTokenIssuer("k1", keys, "https://other.test", "hexlet-api")
+	require.NoError(t, err)
+	token, err := other.GenerateAccessToken("user-1", "", 0)
+	require.NoError(t, err)
+	_, err = issuer.ParseAccessToken(token)
+	assert.ErrorIs(t, err, jwt.ErrTokenInvalidIssuer)
+
+	other, err = NewTokenIssuer("k1", keys, "https://hexlet.test", "another-api")
+	require.NoError(t, err)
+	token, err = other.GenerateAccessToken("user-1", "", 0)
+	require.NoError(t, err)
+	_, err = issuer.ParseAccessToken(token)
+	assert.ErrorIs(t, err, jwt.ErrTokenInvalidAudience)
+
+	// HS256 с тем же kid не принимается
+	hs := jwt.NewWithClaims(jwt.SigningMethodHS256, MyClaims{
+		UserID: "user-1",
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    "https://hexlet.test",
+			Audience:  jwt.ClaimStrings{"hexlet-api"},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+		},
+	})
+	hs.Header["kid"] = "k1"
+	signed, err := hs.SignedString([]byte("secret"))
+	require.NoError(t, err)
+	_, err = issuer.ParseAccessToken(signed)
+	assert.ErrorIs(t, err, jwt.ErrTokenSignatureInvalid)
+}
+
+func TestJWKS(t *testing.T) {
+	issuer := testIssuer(t, keySpec("k2", 2)+","+keySpec("k1", 1))
+	jwks := issuer.JWKS()
+	require.Len(t, jwks.Keys, 2)
+	assert.Equal(t, "k1", jwks.Keys[0].Kid)
+	assert.Equal(t, "k2", jwks.Keys[1].Kid)
+	for _, key := range jwks.Keys {
+		assert.Equal(t, "OKP", key.Kty)
+		assert.Equal(t, "Ed25519", key.Crv)
+		assert.Equal(t, "EdDSA", key.Alg)
+		assert.Equal(t, "sig", key.Use)
+		x, err := base64.RawURLEncoding.DecodeString(key.X)
+		require.NoError(t, err)
+		assert.Len(t, x, ed25519.PublicKeySize)
+		assert.False(t, strings.ContainsAny(key.X, "+/="))
+	}
+}
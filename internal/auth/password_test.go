@@ -0,0 +1,38 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHashPassword(t *testing.T) {
+	hash, err := HashPassword("correct horse battery staple")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(hash, "$argon2id$v=19$m=65536,t=1,p=4$"))
+
+	ok, err := VerifyPassword("correct horse battery staple", hash)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = VerifyPassword("wrong password", hash)
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestHashPassword_UniqueSalt(t *testing.T) {
+	first, err := HashPassword("password")
+	require.NoError(t, err)
+	second, err := HashPassword("password")
+	require.NoError(t, err)
+	assert.NotEqual(t, first, second)
+}
+
+func TestVerifyPassword_InvalidHash(t *testing.T) {
+	for _, hash := range []string{"", "plaintext", "$2a$10$bcrypthash", "$argon2id$v=19$m=x$salt$hash"} {
+		_, err := VerifyPassword("password", hash)
+		assert.ErrorIs(t, err, ErrInvalidHash, hash)
+	}
+}
@@ -1,7 +1,11 @@
 package config
 
 import (
+	"fmt"
+	"log"
 	"os"
+	"strconv"
+	"strings"
 )
 
 type Config struct {
@@ -12,6 +16,54 @@ type Config struct {
 	DBName     string
 }
 
+type CredentialsConfig struct {
+	MasterKeys string
+}
+
+type JWTConfig struct {
+	SigningKeys string
+	Issuer      string
+	Audience    string
+}
+
+type MailConfig struct {
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+	From         string
+	// тело письма в логе без SMTP; только для локальной разработки, в письмах одноразовые токены
+	LogBodies bool
+}
+
+// Token пустой — бот управления не запускается
+type BotConfig struct {
+	Token string
+	// адрес Bot API в формате tgbotapi: "https://api.telegram.org/bot%s/%s"
+	APIEndpoint string
+}
+
+// Endpoint пустой или Disabled — трассировка выключена, спаны не создаются
+type TracingConfig struct {
+	// адрес OTLP/HTTP коллектора, например http://otel-collector:4318
+	Endpoint    string
+	ServiceName string
+	// доля трасс, начатых в сервисе; входящий контекст сохраняет решение вызывающего
+	SampleRatio float64
+	Disabled    bool
+}
+
+type OAuthProviderConfig struct {
+	Key    string
+	Secret string
+}
+
+type AuthConfig struct {
+	// Публичный адрес сервиса, из него строятся callback URL провайдеров
+	PublicURL string
+	Providers map[string]OAuthProviderConfig
+}
+
 func LoadConfigMaster() (*Config, error) {
 	cfg := &Config{
 		DBHost:     getEnv("MASTER_HOST", ""),
@@ -32,6 +84,101 @@ func LoadConfigSlave() (*Config, error) {
 	}
 	return cfg, nil
 }
+
+// CREDENTIALS_MASTER_KEYS: "k2:<base64>,k1:<base64>", первый ключ активный
+func LoadCredentialsConfig() (*CredentialsConfig, error) {
+	cfg := &CredentialsConfig{
+		MasterKeys: getEnv("CREDENTIALS_MASTER_KEYS", ""),
+	}
+	if cfg.MasterKeys == "" {
+		return nil, fmt.Errorf("CREDENTIALS_MASTER_KEYS is not set")
+	}
+	return cfg, nil
+}
+
+// JWT_SIGNING_KEYS: "k2:<base64 seed>,k1:<base64 seed>", Ed25519, первый ключ подписывает
+// (seed: openssl rand -base64 32).
+// iss по умолчанию — публичный адрес сервиса
+func LoadJWTConfig(publicURL string) (*JWTConfig, error) {
+	cfg := &JWTConfig{
+		SigningKeys: getEnv("JWT_SIGNING_KEYS", ""),
+		Issuer:      getEnv("JWT_ISSUER", publicURL),
+		Audience:    getEnv("JWT_AUDIENCE", "hexlet-api"),
+	}
+	if cfg.SigningKeys == "" {
+		return nil, fmt.Errorf("JWT_SIGNING_KEYS is not set")
+	}
+	return cfg, nil
+}
+
+// AUTH_PROVIDERS: "google,github,yandex,vk", ключи провайдера в <NAME>_KEY / <NAME>_SECRET.
+// Провайдер без ключей пропускается: сервис работает со входом по паролю.
+func LoadAuthConfig() (*AuthConfig, error) {
+	cfg := &AuthConfig{
+		PublicURL: strings.TrimRight(getEnv("AUTH_PUBLIC_URL", "http://localhost:8080"), "/"),
+		Providers: make(map[string]OAuthProviderConfig),
+	}
+	for _, name := range strings.Split(getEnv("AUTH_PROVIDERS", "google"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		prefix := strings.ToUpper(name)
+		provider := OAuthProviderConfig{
+			Key:    getEnv(prefix+"_KEY", ""),
+			Secret: getEnv(prefix+"_SECRET", ""),
+		}
+		if provider.Key == "" && provider.Secret == "" {
+			log.Printf("auth provider %q is disabled: %s_KEY and %s_SECRET are not set", name, prefix, prefix)
+			continue
+		}
+		if provider.Key == "" || provider.Secret == "" {
+			return nil, fmt.Errorf("%s_KEY and %s_SECRET must be set for provider %q", prefix, prefix, name)
+		}
+		cfg.Providers[name] = provider
+	}
+	return cfg, nil
+}
+
+// SMTP_HOST не задан — письма только пишутся в лог, тело письма — при MAIL_LOG_BODIES=true
+func LoadMailConfig() *MailConfig {
+	return &MailConfig{
+		SMTPHost:     getEnv("SMTP_HOST", ""),
+		SMTPPort:     getEnv("SMTP_PORT", "25"),
+		SMTPUsername: getEnv("SMTP_USERNAME", ""),
+		SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+		From:         getEnv("SMTP_FROM", "noreply@localhost"),
+		LogBodies:    getEnv("MAIL_LOG_BODIES", "false") == "true",
+	}
+}
+
+// METRICS_ADDR — адрес внутреннего сервера /metrics, наружу не публикуется
+func LoadMetricsAddr() string {
+	return getEnv("METRICS_ADDR", ":9464")
+}
+
+// TELEGRAM_BOT_API_ENDPOINT переопределяет адрес Bot API, например для локального сервера или тестов
+func LoadBotConfig() *BotConfig {
+	return &BotConfig{
+		Token:       getEnv("TELEGRAM_BOT_TOKEN", ""),
+		APIEndpoint: getEnv("TELEGRAM_BOT_API_ENDPOINT", "https://api.telegram.org/bot%s/%s"),
+	}
+}
+
+// Переменные в духе стандартных OTEL_*; OTEL_SDK_DISABLED=true выключает трассировку
+func LoadTracingConfig() (*TracingConfig, error) {
+	ratio, err := strconv.ParseFloat(getEnv("OTEL_TRACES_SAMPLER_ARG", "1"), 64)
+	if err != nil || ratio < 0 || ratio > 1 {
+		return nil, fmt.Errorf("OTEL_TRACES_SAMPLER_ARG must be a number from 0 to 1")
+	}
+	return &TracingConfig{
+		Endpoint:    getEnv("OTEL_EXPORTER_OTLP_ENDPOINT", ""),
+		ServiceName: getEnv("OTEL_SERVICE_NAME", "autoposting"),
+		SampleRatio: ratio,
+		Disabled:    getEnv("OTEL_SDK_DISABLED", "false") == "true",
+	}, nil
+}
+
 func getEnv(key, defaultValue string) string {
 	value, exists := os.LookupEnv(key)
 	if !exists || value == "" {
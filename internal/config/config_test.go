@@ -0,0 +1,29 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadAuthConfigSkipsProviderWithoutKeys(t *testing.T) {
+	t.Setenv("AUTH_PROVIDERS", "google,github")
+	t.Setenv("GOOGLE_KEY", "")
+	t.Setenv("GOOGLE_SECRET", "")
+	t.Setenv("GITHUB_KEY", "key")
+	t.Setenv("GITHUB_SECRET", "secret")
+
+	cfg, err := LoadAuthConfig()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]OAuthProviderConfig{"github": {Key: "key", Secret: "secret"}}, cfg.Providers)
+}
+
+func TestLoadAuthConfigHalfConfiguredProvider(t *testing.T) {
+	t.Setenv("AUTH_PROVIDERS", "google")
+	t.Setenv("GOOGLE_KEY", "key")
+	t.Setenv("GOOGLE_SECRET", "")
+
+	_, err := LoadAuthConfig()
+	assert.Error(t, err)
+}
@@ -0,0 +1,51 @@
+package domain
+
+import (
+	"strings"
+	"time"
+)
+
+// Права API ключа. Сессия пользователя (JWT) имеет все права.
+const (
+	ScopePostsRead         = "posts:read"
+	ScopePostsWrite        = "posts:write"
+	ScopePlatformsRead     = "platforms:read"
+	ScopePlatformsWrite    = "platforms:write"
+	ScopeNotificationsRead = "notifications:read"
+	ScopeWebhooksRead      = "webhooks:read"
+	ScopeWebhooksWrite     = "webhooks:write"
+)
+
+var ApiKeyScopes = []string{
+	ScopePostsRead,
+	ScopePostsWrite,
+	ScopePlatformsRead,
+	ScopePlatformsWrite,
+	ScopeNotificationsRead,
+	ScopeWebhooksRead,
+	ScopeWebhooksWrite,
+}
+
+type ApiKey struct {
+	ID_key       string     `json:"id_key"`
+	ID_user      string     `json:"-"`
+	Name         string     `json:"name"`
+	Prefix       string     `json:"prefix"`
+	Scopes       []string   `json:"scopes"`
+	Expires_at   *time.Time `json:"expires_at"`
+	Last_used_at *time.Time `json:"last_used_at"`
+	Created_at   time.Time  `json:"created_at"`
+}
+
+// HasScope: write включает read того же ресурса
+func (k ApiKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+		if resource, ok := strings.CutSuffix(scope, ":read"); ok && s == resource+":write" {
+			return true
+		}
+	}
+	return false
+}
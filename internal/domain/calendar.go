@@ -0,0 +1,18 @@
+package domain
+
+import "time"
+
+// CalendarEvent — публикация поста на платформу в календаре пользователя
+type CalendarEvent struct {
+	ID_destination int
+	ID_post        int
+	ID_workspace   int
+	Workspace_name string
+	Title          string
+	PlatformName   string
+	Status         string
+	// фактическое время публикации по расписанию, без сдвига API
+	Sheduled_for time.Time
+	Published_at *time.Time
+	ErrorMessage *string
+}
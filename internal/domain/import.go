@@ -0,0 +1,31 @@
+package domain
+
+import "time"
+
+// Статусы фонового импорта постов
+const (
+	ImportPending = "pending"
+	ImportRunning = "running"
+	ImportDone    = "done"
+	ImportFailed  = "failed"
+)
+
+// ImportLineError — ошибки одной строки файла импорта (нумерация с 1, заголовок CSV тоже строка)
+type ImportLineError struct {
+	Line   int      `json:"line"`
+	Errors []string `json:"errors"`
+}
+
+type ImportJob struct {
+	ID_job         string            `json:"id_job"`
+	ID_user        string            `json:"id_user"`
+	Status         string            `json:"status"`
+	All_or_nothing bool              `json:"all_or_nothing"`
+	Total          int               `json:"total"`
+	Created        int               `json:"created"`
+	Failed         int               `json:"failed"`
+	Errors         []ImportLineError `json:"errors"`
+	Posts          []int             `json:"posts"`
+	Created_at     time.Time         `json:"created_at"`
+	Finished_at    *time.Time        `json:"finished_at"`
+}
@@ -0,0 +1,84 @@
+package domain
+
+import "time"
+
+const (
+	NotificationPlatformDegraded    = "platform_degraded"
+	NotificationPlatformDeactivated = "platform_deactivated"
+	NotificationPublicationFailed   = "publication_failed"
+	NotificationPublicationStuck    = "publication_stuck"
+)
+
+// Публикация в processing дольше этого считается зависшей: о ней уведомляют, её можно повторить
+const StuckProcessingAfter = 30 * time.Minute
+
+var NotificationKinds = []string{
+	NotificationPlatformDegraded,
+	NotificationPlatformDeactivated,
+	NotificationPublicationFailed,
+	NotificationPublicationStuck,
+}
+
+// Режимы доставки уведомлений по каналам
+const (
+	NotifyImmediate = "immediate"
+	NotifyHourly    = "hourly"
+	NotifyDaily     = "daily"
+)
+
+type Notification struct {
+	ID_notification int       `json:"id_notification"`
+	ID_user         string    `json:"id_user"`
+	Kind            string    `json:"kind"`
+	Message         string    `json:"message"`
+	ID_platform     *int      `json:"id_platform"`
+	ID_workspace    *int      `json:"id_workspace"`
+	ID_post         *int      `json:"id_post"`
+	ID_destination  *int      `json:"id_destination"`
+	Created_at      time.Time `json:"created_at"`
+}
+
+// NotificationSettings — каналы и расписание рассылки уведомлений пользователя.
+// Kinds пустой — все виды уведомлений.
+type NotificationSettings struct {
+	Email         bool     `json:"email"`
+	Telegram      bool     `json:"telegram"`
+	Webhook       bool     `json:"webhook"`
+	Kinds         []string `json:"kinds"`
+	Delivery      string   `json:"delivery"`
+	Batch_minutes int      `json:"batch_minutes"`
+	Digest_hour   int      `json:"digest_hour"`
+}
+
+func DefaultNotificationSettings() NotificationSettings {
+	return NotificationSettings{
+		Email:         true,
+		Telegram:      true,
+		Kinds:         []string{},
+		Delivery:      NotifyImmediate,
+		Batch_minutes: 5,
+		Digest_hour:   9,
+	}
+}
+
+// Wants: уведомление вида kind рассылается по каналам
+func (s NotificationSettings) Wants(kind string) bool {
+	if len(s.Kinds) == 0 {
+		return true
+	}
+	for _, k := range s.Kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// NotificationBatch — неразосланные уведомления пользователя и его адрес email
+type NotificationBatch struct {
+	ID_user  string
+	Email    *string
+	Timezone string
+	Settings NotificationSettings
+	Items    []Notification
+}
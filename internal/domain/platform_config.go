@@ -0,0 +1,91 @@
+package domain
+
+import "time"
+
+// Текущая версия формата platforms.api_config
+const PlatformConfigVersion = 1
+
+const (
+	PlatformTelegram = "Telegram"
+	PlatformVK       = "VK"
+)
+
+type TelegramConfig struct {
+	BotToken            string `json:"bot_token" validate:"required,contains=:"`
+	ChatID              string `json:"chat_id" validate:"required,max=255"`
+	Silent              bool   `json:"silent"`
+	DisableLinkPreviews bool   `json:"disable_link_previews"`
+}
+
+type VKConfig struct {
+	AccessToken string `json:"access_token" validate:"required"`
+	OwnerID     string `json:"owner_id" validate:"required,numeric,excludesall=+."` // numeric без «+» и «.» — ^-?[0-9]+$ из схемы
+	FromGroup   bool   `json:"from_group"`
+}
+
+// PlatformConfig хранится в platforms.api_config (JSONB).
+// Заполнена ровно одна секция в зависимости от platform_name.
+type PlatformConfig struct {
+	Version  int             `json:"version"`
+	Telegram *TelegramConfig `json:"telegram,omitempty"`
+	VK       *VKConfig       `json:"vk,omitempty"`
+}
+
+func (c PlatformConfig) Clone() PlatformConfig {
+	res := PlatformConfig{Version: c.Version}
+	if c.Telegram != nil {
+		tg := *c.Telegram
+		res.Telegram = &tg
+	}
+	if c.VK != nil {
+		vk := *c.VK
+		res.VK = &vk
+	}
+	return res
+}
+
+// SecretFields возвращает указатели на поля с учётными данными
+// (их шифруем в БД и маскируем в ответах).
+func (c *PlatformConfig) SecretFields() []*string {
+	var res []*string
+	if c.Telegram != nil {
+		res = append(res, &c.Telegram.BotToken)
+	}
+	if c.VK != nil {
+		res = append(res, &c.VK.AccessToken)
+	}
+	return res
+}
+
+type VerificationCheck struct {
+	Name    string `json:"name"`
+	OK      bool   `json:"ok"`
+	Message string `json:"message,omitempty"`
+}
+
+// VerificationReport — результат проверки учётных данных платформы,
+// последний хранится в platforms.verification.
+type VerificationReport struct {
+	OK         bool                `json:"ok"`
+	AuthFailed bool                `json:"auth_failed"`
+	Checks     []VerificationCheck `json:"checks"`
+	CheckedAt  time.Time           `json:"checked_at"`
+}
+
+func (r *VerificationReport) Add(name string, ok bool, message string) {
+	r.Checks = append(r.Checks, VerificationCheck{Name: name, OK: ok, Message: message})
+}
+
+// Состояние платформы по результатам фоновых проверок (platforms.health_status)
+const (
+	HealthHealthy  = "healthy"
+	HealthDegraded = "degraded"
+	HealthInactive = "inactive"
+)
+
+// Что делать с публикациями, время которых наступило за время паузы
+const (
+	ResumePublish = "publish"
+	ResumeShift   = "shift"
+	ResumeSkip    = "skip"
+)
@@ -0,0 +1,35 @@
+package domain
+
+import "encoding/json"
+
+// JSON Schema конфигураций платформ, по ним клиенты строят формы.
+// Должны совпадать с validate-тегами TelegramConfig и VKConfig.
+var PlatformConfigSchemas = map[string]json.RawMessage{
+	PlatformTelegram: json.RawMessage(`{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "$id": "/platforms/schemas/Telegram",
+  "title": "Telegram",
+  "type": "object",
+  "properties": {
+    "bot_token": {"type": "string", "title": "Bot token", "pattern": ".*:.*", "writeOnly": true},
+    "chat_id": {"type": "string", "title": "Chat ID", "description": "@channelname or numeric chat ID", "maxLength": 255},
+    "silent": {"type": "boolean", "title": "Silent mode", "default": false},
+    "disable_link_previews": {"type": "boolean", "title": "Disable link previews", "default": false}
+  },
+  "required": ["bot_token", "chat_id"],
+  "additionalProperties": false
+}`),
+	PlatformVK: json.RawMessage(`{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "$id": "/platforms/schemas/VK",
+  "title": "VK",
+  "type": "object",
+  "properties": {
+    "access_token": {"type": "string", "title": "Access token", "writeOnly": true},
+    "owner_id": {"type": "string", "title": "Owner ID", "description": "wall owner, negative for communities", "pattern": "^-?[0-9]+$"},
+    "from_group": {"type": "boolean", "title": "Post on behalf of the community", "default": false}
+  },
+  "required": ["access_token", "owner_id"],
+  "additionalProperties": false
+}`),
+}
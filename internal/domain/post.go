@@ -2,26 +2,100 @@ package domain
 
 import "time"
 
+// Статусы поста в редакционном процессе: draft -> in_review -> approved -> scheduled.
+// Пост без времени публикации после одобрения остаётся approved.
+const (
+	PostDraft     = "draft"
+	PostInReview  = "in_review"
+	PostApproved  = "approved"
+	PostScheduled = "scheduled"
+)
+
+// Действия в истории проверки поста
+const (
+	ReviewSubmitted = "submitted"
+	ReviewApproved  = "approved"
+	ReviewRejected  = "rejected"
+)
+
 type Post struct {
-	ID_post      int       `json:"id_post"`
-	ID_user      string    `json:"id_user"`
-	ID_platform  int       `json:"id_platform"`
-	Title        string    `json:"title"`
-	Content      string    `json:"content"`
-	Status       string    `json:"status"`
-	Created_at   time.Time `json:"created_at"`
-	Sheduled_for time.Time `json:"sheduled_for"`
-	PlatformName string    `json:"platform_name"`
-	ErrorMessage *string   `json:"error_message"`
+	ID_post       int       `json:"id_post"`
+	ID_user       string    `json:"id_user"`
+	ID_platform   int       `json:"id_platform"`
+	Title         string    `json:"title"`
+	Content       string    `json:"content"`
+	Status        string    `json:"status"`
+	Review_status string    `json:"review_status"`
+	Revision      int       `json:"revision"`
+	Created_at    time.Time `json:"created_at"`
+	Sheduled_for  time.Time `json:"sheduled_for"`
+	PlatformName  string    `json:"platform_name"`
+	ErrorMessage  *string   `json:"error_message"`
+	// ревизия, ушедшая на платформу
+	Published_revision *int `json:"published_revision"`
+	// id публикации на платформе
+	Remote_id *string `json:"remote_id"`
+}
+
+// ExportRow — публикация поста на одну платформу для выгрузки
+type ExportRow struct {
+	ID_post            int        `json:"id_post"`
+	ID_user            string     `json:"id_user"`
+	Title              string     `json:"title"`
+	Content            string     `json:"content"`
+	Review_status      string     `json:"review_status"`
+	Revision           int        `json:"revision"`
+	Created_at         time.Time  `json:"created_at"`
+	ID_destination     int        `json:"id_destination"`
+	ID_platform        int        `json:"id_platform"`
+	PlatformName       string     `json:"platform_name"`
+	Status             string     `json:"status"`
+	Sheduled_for       *time.Time `json:"sheduled_for"`
+	Published_at       *time.Time `json:"published_at"`
+	Published_revision *int       `json:"published_revision"`
+	Remote_id          *string    `json:"remote_id"`
+	ErrorMessage       *string    `json:"error_message"`
 }
 
 type Platform struct {
-	ID_platform int               `json:"id_platform"`
-	Name        string            `json:"name"`
-	Api_config  map[string]string `json:"api_config"`
-	Is_active   bool              `json:"is_active"`
-	Created_at  time.Time         `json:"created_at"`
-	Updated_at  time.Time         `json:"updated_at"`
+	ID_platform        int                 `json:"id_platform"`
+	Name               string              `json:"name"`
+	Api_config         PlatformConfig      `json:"api_config"`
+	Is_active          bool                `json:"is_active"`
+	Health_status      string              `json:"health_status"`
+	Paused_at          *time.Time          `json:"paused_at"`
+	Verification       *VerificationReport `json:"verification"`
+	Required_approvals *int                `json:"required_approvals"`
+	Config_version     int                 `json:"-"` // растёт при каждом изменении учётных данных
+	Created_at         time.Time           `json:"created_at"`
+	Updated_at         time.Time           `json:"updated_at"`
+}
+
+// Поля поста, изменения которых фиксируются в ревизиях
+const (
+	FieldTitle       = "title"
+	FieldContent     = "content"
+	FieldSheduledFor = "sheduled_for"
+)
+
+type PostRevision struct {
+	ID_post        int        `json:"id_post"`
+	Revision       int        `json:"revision"`
+	ID_user        string     `json:"id_user"`
+	Title          string     `json:"title"`
+	Content        string     `json:"content"`
+	Sheduled_for   *time.Time `json:"sheduled_for"`
+	Changed_fields []string   `json:"changed_fields"`
+	Created_at     time.Time  `json:"created_at"`
+}
+
+type PostReview struct {
+	ID_review  int       `json:"id_review"`
+	ID_post    int       `json:"id_post"`
+	ID_user    string    `json:"id_user"`
+	Action     string    `json:"action"`
+	Comment    string    `json:"comment"`
+	Created_at time.Time `json:"created_at"`
 }
 
 type PostDestination struct {
@@ -41,9 +115,11 @@ type ScheduledPublication struct {
 	ID_user        string `json:"id_user"`
 	Title          string `json:"title"`
 	Content        string `json:"content"`
-	ID_platform    int `json:"id_platform"`
+	ID_platform    int    `json:"id_platform"`
 	Platform_name  string `json:"platform_name"`
 	Api_config     string `json:"api_config"`
+	// traceparent запроса, создавшего пост
+	Trace_parent *string `json:"-"`
 }
 
 type PublicationEvent struct {
@@ -54,12 +130,34 @@ type PublicationEvent struct {
 	UserID        string    `json:"user_id"`
 }
 
+// StatusEvent — смена статуса публикации, приходит из NOTIFY destination_status
+type StatusEvent struct {
+	ID_destination  int       `json:"id_destination"`
+	ID_post         int       `json:"id_post"`
+	ID_workspace    int       `json:"id_workspace"`
+	ID_platform     int       `json:"id_platform"`
+	Status          string    `json:"status"`
+	Previous_status string    `json:"previous_status"`
+	ErrorMessage    *string   `json:"error_message"`
+	Changed_at      time.Time `json:"changed_at"`
+}
+
 type PlatformSQL struct {
+	ID           int
+	UserID       string
 	PlatformName string
-	APIConfig    map[string]string
+	Config       PlatformConfig
 	IsActive     bool
+	HealthStatus string
+	AuthFailures int
+	// версия api_config, с которой прочитана платформа
+	ConfigVersion int
 }
 type Message struct {
-	Title   string
-	Content string
+	Title     string
+	Content   string
+	Revision  int
+	Variables *PostVariables
+	// часовой пояс автора для даты и времени в шаблоне
+	Timezone string
 }
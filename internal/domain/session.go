@@ -0,0 +1,20 @@
+package domain
+
+import "time"
+
+type Session struct {
+	ID_session   string    `json:"id_session"`
+	ID_user      string    `json:"-"`
+	User_agent   string    `json:"user_agent"`
+	Ip           string    `json:"ip"`
+	Created_at   time.Time `json:"created_at"`
+	Last_used_at time.Time `json:"last_used_at"`
+	Expires_at   time.Time `json:"expires_at"`
+	Current      bool      `json:"current"`
+}
+
+type RefreshToken struct {
+	JTI        string
+	Hash       string
+	Expires_at time.Time
+}
@@ -0,0 +1,33 @@
+package domain
+
+import "time"
+
+type PostTemplate struct {
+	ID_template int    `json:"id_template"`
+	Name        string `json:"name"`
+	Title       string `json:"title"`
+	Content     string `json:"content"`
+	// переменные шаблона, кроме встроенных date, time и platform
+	Variables  []string  `json:"variables"`
+	Created_at time.Time `json:"created_at"`
+	Updated_at time.Time `json:"updated_at"`
+}
+
+// PostVariables — значения переменных поста из шаблона.
+// Platforms переопределяет Values для отдельной платформы (ключ — имя платформы).
+type PostVariables struct {
+	Values    map[string]string            `json:"values"`
+	Platforms map[string]map[string]string `json:"platforms"`
+}
+
+// For — значения переменных для публикации на платформу
+func (v PostVariables) For(platform string) map[string]string {
+	res := map[string]string{}
+	for k, val := range v.Values {
+		res[k] = val
+	}
+	for k, val := range v.Platforms[platform] {
+		res[k] = val
+	}
+	return res
+}
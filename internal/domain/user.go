@@ -0,0 +1,46 @@
+package domain
+
+import "time"
+
+type User struct {
+	ID_user    string    `json:"id_user"`
+	Email      *string   `json:"email"`
+	Name       *string   `json:"name"`
+	Avatar_url *string   `json:"avatar_url"`
+	Timezone   string    `json:"timezone"`
+	Created_at time.Time `json:"created_at"`
+	Updated_at time.Time `json:"updated_at"`
+}
+
+// Identity — данные пользователя от провайдера входа (goth.User)
+type Identity struct {
+	Provider       string
+	ProviderUserID string
+	Email          string
+	Name           string
+	AvatarURL      string
+}
+
+const (
+	ProviderLocal = "local"
+
+	TokenVerifyEmail   = "verify_email"
+	TokenResetPassword = "reset_password"
+	TokenLinkTelegram  = "link_telegram"
+	TokenLinkIdentity  = "link_identity"
+)
+
+// LocalCredentials — данные для входа по email/паролю
+type LocalCredentials struct {
+	ID_user        string
+	Email          string
+	Password_hash  string
+	Email_verified bool
+	Locked_until   *time.Time
+}
+
+type UserIdentity struct {
+	Provider         string    `json:"provider"`
+	Provider_user_id string    `json:"provider_user_id"`
+	Created_at       time.Time `json:"created_at"`
+}
@@ -0,0 +1,115 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// События, на которые можно подписаться
+const (
+	EventPostCreated          = "post.created"
+	EventPostUpdated          = "post.updated"
+	EventPostDeleted          = "post.deleted"
+	EventPostApproved         = "post.approved"
+	EventPostRejected         = "post.rejected"
+	EventDestinationPublished = "destination.published"
+	EventDestinationFailed    = "destination.failed"
+	// уведомление владельцу, если он включил канал webhook (data — domain.Notification)
+	EventNotificationCreated = "notification.created"
+)
+
+var WebhookEvents = []string{
+	EventPostCreated,
+	EventPostUpdated,
+	EventPostDeleted,
+	EventPostApproved,
+	EventPostRejected,
+	EventDestinationPublished,
+	EventDestinationFailed,
+	EventNotificationCreated,
+}
+
+const (
+	WebhookScopeWorkspace = "workspace"
+	WebhookScopeUser      = "user"
+)
+
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+type Webhook struct {
+	ID_webhook int      `json:"id_webhook"`
+	ID_user    string   `json:"id_user"`
+	Url        string   `json:"url"`
+	Events     []string `json:"events"`
+	Scope      string   `json:"scope"`
+	Is_active  bool     `json:"is_active"`
+	// секрет показывается только при создании
+	Secret     string    `json:"secret,omitempty"`
+	Created_at time.Time `json:"created_at"`
+}
+
+// WebhookEvent — событие для рассылки подписчикам пространства.
+// По автору поста отбираются подписки со scope user; если ID_author пустой,
+// автор берётся из поста ID_post.
+type WebhookEvent struct {
+	ID_event     string
+	Event        string
+	ID_workspace int
+	ID_post      int
+	ID_author    string
+	Data         any
+}
+
+// WebhookPayload — тело запроса к подписчику
+type WebhookPayload struct {
+	ID_event     string    `json:"id_event"`
+	Event        string    `json:"event"`
+	ID_workspace int       `json:"id_workspace"`
+	Created_at   time.Time `json:"created_at"`
+	Data         any       `json:"data"`
+}
+
+type WebhookDelivery struct {
+	ID_delivery     int             `json:"id_delivery"`
+	ID_webhook      int             `json:"id_webhook"`
+	ID_event        string          `json:"id_event"`
+	Event           string          `json:"event"`
+	Payload         json.RawMessage `json:"payload" swaggertype:"object"`
+	Status          string          `json:"status"`
+	Attempts        int             `json:"attempts"`
+	Next_attempt_at *time.Time      `json:"next_attempt_at"`
+	Response_code   *int            `json:"response_code"`
+	Last_error      *string         `json:"last_error"`
+	Created_at      time.Time       `json:"created_at"`
+	Delivered_at    *time.Time      `json:"delivered_at"`
+	// для отправки, в журнал не выводятся
+	Url    string `json:"-"`
+	Secret string `json:"-"`
+}
+
+// PostEventData — data событий post.*; ID_user — кто выполнил действие
+type PostEventData struct {
+	ID_post int    `json:"id_post"`
+	ID_user string `json:"id_user"`
+	Status  string `json:"status,omitempty"`
+	Comment string `json:"comment,omitempty"`
+}
+
+// DestinationEventData — data событий destination.*
+type DestinationEventData struct {
+	ID_destination int        `json:"id_destination"`
+	ID_post        int        `json:"id_post"`
+	ID_user        string     `json:"id_user"`
+	ID_workspace   int        `json:"-"`
+	ID_platform    int        `json:"id_platform"`
+	PlatformName   string     `json:"platform_name"`
+	Status         string     `json:"status"`
+	Revision       *int       `json:"revision"`
+	Remote_id      *string    `json:"remote_id"`
+	Published_at   *time.Time `json:"published_at"`
+	ErrorMessage   *string    `json:"error_message"`
+}
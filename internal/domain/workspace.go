@@ -0,0 +1,48 @@
+package domain
+
+import "time"
+
+// Роли участников пространства, от старшей к младшей
+const (
+	RoleOwner  = "owner"
+	RoleAdmin  = "admin"
+	RoleEditor = "editor"
+	RoleViewer = "viewer"
+)
+
+var roleRank = map[string]int{
+	RoleViewer: 1,
+	RoleEditor: 2,
+	RoleAdmin:  3,
+	RoleOwner:  4,
+}
+
+// RoleAtLeast: роль role даёт права не меньше, чем min
+func RoleAtLeast(role string, min string) bool {
+	return roleRank[role] >= roleRank[min] && roleRank[role] > 0
+}
+
+type Workspace struct {
+	ID_workspace       int       `json:"id_workspace"`
+	Name               string    `json:"name"`
+	Role               string    `json:"role"`
+	Required_approvals int       `json:"required_approvals"`
+	Created_at         time.Time `json:"created_at"`
+}
+
+type WorkspaceMember struct {
+	ID_workspace int       `json:"id_workspace"`
+	ID_user      string    `json:"id_user"`
+	Email        *string   `json:"email"`
+	Name         *string   `json:"name"`
+	Role         string    `json:"role"`
+	Created_at   time.Time `json:"created_at"`
+}
+
+type Invitation struct {
+	ID_workspace int       `json:"id_workspace"`
+	Email        string    `json:"email"`
+	Role         string    `json:"role"`
+	Invited_by   string    `json:"invited_by"`
+	Expires_at   time.Time `json:"expires_at"`
+}
@@ -1,15 +1,49 @@
 package dto
 
-import "time"
+import (
+	"hexlet/internal/domain"
+	"time"
+)
 
 // posts
 type (
+	// draft: true — сохранить черновик, иначе пост сразу отправляется на проверку.
+	// sheduled_for можно не указывать: одобренный пост ждёт, пока время не зададут.
 	CreatePostRequest struct {
-		ID_user      string    `json:"id_user"`
+		ID_user      string    `json:"-"`
+		ID_workspace int       `json:"-"`
 		Title        string    `json:"title" validate:"required,min=3,max=255"`
 		Content      string    `json:"content" validate:"required"`
-		Sheduled_for time.Time `json:"sheduled_for" validate:"required"`
+		Sheduled_for time.Time `json:"sheduled_for"`
+		Draft        bool      `json:"draft"`
 		Status       string    `json:"-"`
+		// заполняются при создании из шаблона
+		ID_template int                   `json:"-"`
+		Variables   *domain.PostVariables `json:"-"`
+		// заполняется при импорте, пусто — все платформы пространства
+		Platforms []int `json:"-"`
+	}
+
+	// фильтры списка и выгрузки постов, все необязательные; from/to — по времени публикации
+	PostFilter struct {
+		Status        string    `form:"status" validate:"omitempty,oneof=draft scheduled processing published failed held skipped"`
+		Review_status string    `form:"review_status" validate:"omitempty,oneof=draft in_review approved scheduled"`
+		ID_platform   int       `form:"platform_id" validate:"omitempty,min=1"`
+		From          time.Time `form:"from"`
+		To            time.Time `form:"to"`
+	}
+	ExportPostsRequest struct {
+		PostFilter
+		Format string `form:"format" validate:"omitempty,oneof=csv ndjson xlsx"`
+	}
+
+	// параметры импорта; timezone — пояс по умолчанию для строк без своего
+	ImportPostsRequest struct {
+		Format         string `form:"format"`
+		Dry_run        bool   `form:"dry_run"`
+		All_or_nothing bool   `form:"all_or_nothing"`
+		Timezone       string `form:"timezone"`
+		Draft          bool   `form:"draft"`
 	}
 
 	DeletePostRequest struct {
@@ -18,7 +52,8 @@ type (
 	}
 
 	PutPostRequest struct {
-		ID_user      string    `json:"id_user"`
+		ID_user      string    `json:"-"`
+		ID_workspace int       `json:"-"`
 		ID_post      int       `json:"id_post"`
 		Title        string    `json:"title"`
 		Content      string    `json:"content"`
@@ -26,28 +61,160 @@ type (
 	}
 )
 
+// templates
+type (
+	CreateTemplateRequest struct {
+		ID_user      string `json:"-"`
+		ID_workspace int    `json:"-"`
+		Name         string `json:"name" validate:"required,max=255"`
+		Title        string `json:"title" validate:"required,max=255"`
+		Content      string `json:"content" validate:"required"`
+	}
+	PutTemplateRequest struct {
+		ID_template  int    `json:"-"`
+		ID_workspace int    `json:"-"`
+		Name         string `json:"name" validate:"required,max=255"`
+		Title        string `json:"title" validate:"required,max=255"`
+		Content      string `json:"content" validate:"required"`
+	}
+	// values — общие значения переменных, platform_values — значения для отдельных платформ
+	// (ключ — имя платформы), они подставляются при публикации.
+	CreatePostFromTemplateRequest struct {
+		Values          map[string]string            `json:"values"`
+		Platform_values map[string]map[string]string `json:"platform_values"`
+		Sheduled_for    time.Time                    `json:"sheduled_for"`
+		Draft           bool                         `json:"draft"`
+	}
+)
+
+// review
+type (
+	ApprovePostRequest struct {
+		Comment string `json:"comment" validate:"max=2000"`
+	}
+	// Отклонение без объяснения автору не принимается
+	RejectPostRequest struct {
+		Comment string `json:"comment" validate:"required,max=2000"`
+	}
+	// null для платформы — требование пространства
+	ApprovalRuleRequest struct {
+		Required_approvals *int `json:"required_approvals" validate:"omitempty,min=0,max=10"`
+	}
+)
+
 // platforms
 type (
 	CreatePlatformRequest struct {
-		ID_user      string `json:"id_user"`
-		PlatformName string `json:"platfromname" validate:"required"`
-		Bot_name     string `json:"bot_name" validate:"required"`
-		Config       string `json:"config" validate:"required"`
+		ID_user      string                 `json:"-"`
+		ID_workspace int                    `json:"-"`
+		PlatformName string                 `json:"platfromname" validate:"required,oneof=Telegram VK"`
+		Telegram     *domain.TelegramConfig `json:"telegram" validate:"required_if=PlatformName Telegram,excluded_unless=PlatformName Telegram"`
+		VK           *domain.VKConfig       `json:"vk" validate:"required_if=PlatformName VK,excluded_unless=PlatformName VK"`
 	}
 	DeletePlatformRequest struct {
 		ID_user     string `json:"id_user"`
 		ID_platform int    `json:"id_platform" validate:"required"`
 	}
 
+	// Пустые bot_token/access_token при обновлении означают "оставить текущий"
 	PutPlatformRequest struct {
-		ID_user      string `json:"id_user"`
-		ID_platform  int    `json:"id_platform" validate:"required"`
-		PlatformName string `json:"platfromname" validate:"required"`
-		Bot_name     string `json:"content" validate:"required"`
-		Config       string `json:"config" validate:"required"`
+		ID_user      string                 `json:"-"`
+		ID_workspace int                    `json:"-"`
+		ID_platform  int                    `json:"id_platform"`
+		Telegram     *domain.TelegramConfig `json:"telegram"`
+		VK           *domain.VKConfig       `json:"vk"`
+		Config       domain.PlatformConfig  `json:"-"`
+	}
+)
+
+// overdue: publish — опубликовать сразу, shift — сдвинуть на длительность паузы, skip — пропустить
+type ResumeRequest struct {
+	Overdue string `json:"overdue" validate:"required,oneof=publish shift skip"`
+}
+
+// users
+// Поля, которые не переданы (null), не меняются
+type PatchMeRequest struct {
+	ID_user    string  `json:"-"`
+	Name       *string `json:"name" validate:"omitempty,min=1,max=255"`
+	Avatar_url *string `json:"avatar_url" validate:"omitempty,url"`
+	Timezone   *string `json:"timezone" validate:"omitempty,timezone"`
+}
+
+// email/password
+type (
+	RegisterRequest struct {
+		Email    string `json:"email" validate:"required,email,max=255"`
+		Password string `json:"password" validate:"required,min=8,max=128"`
+		Name     string `json:"name" validate:"max=255"`
+	}
+	LoginRequest struct {
+		Email    string `json:"email" validate:"required,email"`
+		Password string `json:"password" validate:"required"`
+	}
+	ForgotPasswordRequest struct {
+		Email string `json:"email" validate:"required,email"`
+	}
+	ResetPasswordRequest struct {
+		Token    string `json:"token" validate:"required"`
+		Password string `json:"password" validate:"required,min=8,max=128"`
 	}
 )
 
+// api keys
+// expires_at не задан — ключ бессрочный
+type CreateApiKeyRequest struct {
+	ID_user    string     `json:"-"`
+	Name       string     `json:"name" validate:"required,max=255"`
+	Scopes     []string   `json:"scopes" validate:"required,min=1,dive,oneof=posts:read posts:write platforms:read platforms:write notifications:read webhooks:read webhooks:write"`
+	Expires_at *time.Time `json:"expires_at"`
+}
+
+// workspaces
+type (
+	CreateWorkspaceRequest struct {
+		Name string `json:"name" validate:"required,max=255"`
+	}
+	InviteMemberRequest struct {
+		Email string `json:"email" validate:"required,email,max=255"`
+		Role  string `json:"role" validate:"required,oneof=owner admin editor viewer"`
+	}
+	UpdateMemberRequest struct {
+		Role string `json:"role" validate:"required,oneof=owner admin editor viewer"`
+	}
+	AcceptInvitationRequest struct {
+		Token string `json:"token" validate:"required"`
+	}
+)
+
+// webhooks
+// scope user — только события постов автора подписки
+type (
+	CreateWebhookRequest struct {
+		Url    string   `json:"url" validate:"required,http_url,max=2048"`
+		Events []string `json:"events" validate:"required,min=1,unique,dive,oneof=post.created post.updated post.deleted post.approved post.rejected destination.published destination.failed notification.created"`
+		Scope  string   `json:"scope" validate:"omitempty,oneof=workspace user"`
+	}
+	// Поля, которые не переданы (null), не меняются
+	PatchWebhookRequest struct {
+		Url       *string  `json:"url" validate:"omitempty,http_url,max=2048"`
+		Events    []string `json:"events" validate:"omitempty,min=1,unique,dive,oneof=post.created post.updated post.deleted post.approved post.rejected destination.published destination.failed notification.created"`
+		Is_active *bool    `json:"is_active"`
+	}
+)
+
+// notifications
+// Настройки заменяются целиком. kinds пустой — все виды уведомлений.
+type NotificationSettingsRequest struct {
+	Email         bool     `json:"email"`
+	Telegram      bool     `json:"telegram"`
+	Webhook       bool     `json:"webhook"`
+	Kinds         []string `json:"kinds" validate:"unique,dive,oneof=platform_degraded platform_deactivated publication_failed publication_stuck"`
+	Delivery      string   `json:"delivery" validate:"required,oneof=immediate hourly daily"`
+	Batch_minutes int      `json:"batch_minutes" validate:"min=0,max=60"`
+	Digest_hour   int      `json:"digest_hour" validate:"min=0,max=23"`
+}
+
 // request для получения платформ/постов от пользователя
 type GetByUserIDRequest struct {
 	ID_user string `json:"id_user"`
@@ -2,6 +2,7 @@ package dto
 
 import (
 	"hexlet/internal/domain"
+	"hexlet/internal/textdiff"
 	"time"
 )
 
@@ -22,10 +23,42 @@ type (
 		Processing []domain.Post `json:"processing"`
 		Published  []domain.Post `json:"published"`
 		Failed     []domain.Post `json:"failed"`
+		Held       []domain.Post `json:"held"`
+		Skipped    []domain.Post `json:"skipped"`
+		Draft      []domain.Post `json:"draft"`
 	}
 	GetPostResponce struct {
 		Posts []domain.Post `json:"post"`
 	}
+	PostStatusResponce struct {
+		ID_post int    `json:"id_post"`
+		Status  string `json:"status"`
+	}
+	// Построчное сравнение ревизий from и to
+	RevisionDiffResponce struct {
+		ID_post           int             `json:"id_post"`
+		From              int             `json:"from"`
+		To                int             `json:"to"`
+		Changed_fields    []string        `json:"changed_fields"`
+		Title             []textdiff.Line `json:"title"`
+		Content           []textdiff.Line `json:"content"`
+		Sheduled_for_from *time.Time      `json:"sheduled_for_from"`
+		Sheduled_for_to   *time.Time      `json:"sheduled_for_to"`
+	}
+	RestoreRevisionResponce struct {
+		ID_post  int `json:"id_post"`
+		Revision int `json:"revision"`
+	}
+	// Valid — строки без ошибок, Created — созданные посты (в dry run всегда 0)
+	ImportPostsResponce struct {
+		Dry_run bool                     `json:"dry_run"`
+		Total   int                      `json:"total"`
+		Valid   int                      `json:"valid"`
+		Created int                      `json:"created"`
+		Failed  int                      `json:"failed"`
+		Errors  []domain.ImportLineError `json:"errors"`
+		Posts   []int                    `json:"posts"`
+	}
 )
 
 // platform
@@ -36,15 +69,51 @@ type (
 		Created_at  time.Time `json:"created_at"`
 	}
 	PutPlatformResponce struct {
-		ID_platform int       `json:"id_platform"`
-		ID_user     string    `json:"id_user"`
-		Updated_at  time.Time `json:"updated_at"`
+		ID_platform    int       `json:"id_platform"`
+		ID_user        string    `json:"id_user"`
+		Updated_at     time.Time `json:"updated_at"`
+		Config_version int       `json:"-"` // версия сохранённых учётных данных
 	}
 	GetPlatformResponce struct {
 		Platfroms []domain.Platform `json:"plstforms"`
 	}
+	PauseResponce struct {
+		Platforms []int `json:"platforms"`
+		Held      int64 `json:"held"`
+	}
+	ResumeResponce struct {
+		Platforms   []int  `json:"platforms"`
+		Overdue     string `json:"overdue"`
+		OverdueHeld int64  `json:"overdue_held"`
+		Released    int64  `json:"released"`
+	}
 )
 
+// Key показывается только при создании, в БД хранится его хэш
+type CreateApiKeyResponce struct {
+	Key     string        `json:"key"`
+	Api_key domain.ApiKey `json:"api_key"`
+}
+
+// Missing: платформа -> незаполненные переменные
+type UnfilledTemplateResponse struct {
+	Error   string              `json:"error"`
+	Missing map[string][]string `json:"missing"`
+}
+
+// url — ссылка для подписки в календаре, токен показывается один раз
+type CalendarTokenResponce struct {
+	Token string `json:"token"`
+	Url   string `json:"url"`
+}
+
+// url — ссылка t.me на бота с кодом привязки
+type TelegramLinkResponce struct {
+	Code       string    `json:"code"`
+	Url        string    `json:"url"`
+	Expires_at time.Time `json:"expires_at"`
+}
+
 type ErrorResponse struct {
 	Error string `json:"error" example:"error message"`
 }
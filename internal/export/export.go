@@ -0,0 +1,150 @@
+// Package export пишет выгрузку постов построчно в CSV, NDJSON или XLSX,
+// не собирая файл в памяти.
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"hexlet/internal/domain"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+	FormatXLSX   = "xlsx"
+)
+
+var ErrUnknownFormat = errors.New("unknown export format, expected csv, ndjson or xlsx")
+
+// Columns — колонки CSV и XLSX в порядке вывода
+var Columns = []string{
+	"post_id", "user_id", "title", "content", "review_status", "revision", "created_at",
+	"destination_id", "platform_id", "platform_name", "status", "scheduled_for", "published_at",
+	"published_revision", "remote_id", "error_message",
+}
+
+type Writer interface {
+	Write(row domain.ExportRow) error
+	// Flush отправляет накопленное клиенту
+	Flush() error
+	// Close дописывает конец файла
+	Close() error
+}
+
+func NewWriter(format string, w io.Writer) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVWriter(w)
+	case FormatNDJSON:
+		return &ndjsonWriter{enc: json.NewEncoder(w)}, nil
+	case FormatXLSX:
+		return newXLSXWriter(w)
+	}
+	return nil, ErrUnknownFormat
+}
+
+func ContentType(format string) string {
+	switch format {
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	case FormatNDJSON:
+		return "application/x-ndjson"
+	case FormatXLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "application/octet-stream"
+}
+
+// cells — значения строки в порядке Columns; пустая строка вместо NULL
+func cells(row domain.ExportRow) []string {
+	return []string{
+		strconv.Itoa(row.ID_post),
+		row.ID_user,
+		escapeFormula(row.Title),
+		escapeFormula(row.Content),
+		row.Review_status,
+		strconv.Itoa(row.Revision),
+		row.Created_at.Format(time.RFC3339),
+		strconv.Itoa(row.ID_destination),
+		strconv.Itoa(row.ID_platform),
+		row.PlatformName,
+		row.Status,
+		formatTime(row.Sheduled_for),
+		formatTime(row.Published_at),
+		formatInt(row.Published_revision),
+		formatString(row.Remote_id),
+		escapeFormula(formatString(row.ErrorMessage)),
+	}
+}
+
+// escapeFormula не даёт табличному редактору принять текст пользователя за формулу
+func escapeFormula(v string) string {
+	if v != "" && strings.ContainsRune("=+-@", rune(v[0])) {
+		return "'" + v
+	}
+	return v
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+func formatInt(v *int) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.Itoa(*v)
+}
+
+func formatString(v *string) string {
+	if v == nil {
+		return ""
+	}
+	return *v
+}
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func newCSVWriter(w io.Writer) (*csvWriter, error) {
+	cw := &csvWriter{w: csv.NewWriter(w)}
+	return cw, cw.w.Write(Columns)
+}
+
+func (c *csvWriter) Write(row domain.ExportRow) error {
+	return c.w.Write(cells(row))
+}
+
+func (c *csvWriter) Flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func (c *csvWriter) Close() error {
+	return c.Flush()
+}
+
+type ndjsonWriter struct {
+	enc *json.Encoder
+}
+
+func (n *ndjsonWriter) Write(row domain.ExportRow) error {
+	return n.enc.Encode(row)
+}
+
+func (n *ndjsonWriter) Flush() error {
+	return nil
+}
+
+func (n *ndjsonWriter) Close() error {
+	return nil
+}
@@ -0,0 +1,118 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"hexlet/internal/domain"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testRow() domain.ExportRow {
+	at := time.Date(2026, 11, 1, 10, 0, 0, 0, time.UTC)
+	remote := "42"
+	return domain.ExportRow{
+		ID_post:        7,
+		ID_user:        "1",
+		Title:          "Launch",
+		Content:        "Line one\nLine <two> & \"three\"",
+		Review_status:  domain.PostScheduled,
+		Revision:       2,
+		Created_at:     at,
+		ID_destination: 11,
+		ID_platform:    3,
+		PlatformName:   domain.PlatformTelegram,
+		Status:         "published",
+		Sheduled_for:   &at,
+		Published_at:   &at,
+		Remote_id:      &remote,
+	}
+}
+
+func write(t *testing.T, format string) []byte {
+	var buf bytes.Buffer
+	w, err := NewWriter(format, &buf)
+	assert.NoError(t, err)
+	assert.NoError(t, w.Write(testRow()))
+	assert.NoError(t, w.Flush())
+	assert.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestCSV(t *testing.T) {
+	records, err := csv.NewReader(bytes.NewReader(write(t, FormatCSV))).ReadAll()
+	assert.NoError(t, err)
+	assert.Len(t, records, 2)
+	assert.Equal(t, Columns, records[0])
+	assert.Equal(t, "Line one\nLine <two> & \"three\"", records[1][3])
+	assert.Equal(t, "2026-11-01T10:00:00Z", records[1][11])
+	assert.Equal(t, "", records[1][13])
+	assert.Equal(t, "42", records[1][14])
+}
+
+func TestCSV_EscapesFormulas(t *testing.T) {
+	row := testRow()
+	row.Title = "=HYPERLINK(\"http://example.com\")"
+	row.Content = "@SUM(A1:A2)"
+	message := "-1+1"
+	row.ErrorMessage = &message
+	var buf bytes.Buffer
+	w, err := NewWriter(FormatCSV, &buf)
+	assert.NoError(t, err)
+	assert.NoError(t, w.Write(row))
+	assert.NoError(t, w.Close())
+	records, err := csv.NewReader(&buf).ReadAll()
+	assert.NoError(t, err)
+	assert.Equal(t, "'=HYPERLINK(\"http://example.com\")", records[1][2])
+	assert.Equal(t, "'@SUM(A1:A2)", records[1][3])
+	assert.Equal(t, "'-1+1", records[1][15])
+}
+
+func TestNDJSON(t *testing.T) {
+	var row domain.ExportRow
+	assert.NoError(t, json.Unmarshal(write(t, FormatNDJSON), &row))
+	assert.Equal(t, 11, row.ID_destination)
+	assert.Nil(t, row.ErrorMessage)
+}
+
+func TestXLSX(t *testing.T) {
+	data := write(t, FormatXLSX)
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	assert.NoError(t, err)
+	files := map[string]string{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		assert.NoError(t, err)
+		body, _ := io.ReadAll(rc)
+		rc.Close()
+		files[f.Name] = string(body)
+	}
+	assert.Contains(t, files, "[Content_Types].xml")
+	assert.Contains(t, files, "xl/workbook.xml")
+	sheet := files["xl/worksheets/sheet1.xml"]
+	assert.True(t, strings.HasSuffix(sheet, xlsxSheetTail))
+	assert.Contains(t, sheet, `<c r="A1" t="inlineStr"><is><t xml:space="preserve">post_id</t></is></c>`)
+	assert.Contains(t, sheet, `<c r="A2"><v>7</v></c>`)
+	assert.Contains(t, sheet, "Line &lt;two&gt; &amp; &#34;three&#34;")
+	// пустые значения не пишутся
+	assert.NotContains(t, sheet, `r="N2"`)
+}
+
+func TestUnknownFormat(t *testing.T) {
+	_, err := NewWriter("pdf", io.Discard)
+	assert.ErrorIs(t, err, ErrUnknownFormat)
+}
+
+func TestColumnName(t *testing.T) {
+	assert.Equal(t, "A", columnName(0))
+	assert.Equal(t, "Z", columnName(25))
+	assert.Equal(t, "AA", columnName(26))
+	assert.Equal(t, "AZ", columnName(51))
+	assert.Equal(t, "BA", columnName(52))
+}
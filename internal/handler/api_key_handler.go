@@ -0,0 +1,131 @@
+package handler
+
+import (
+	"hexlet/internal/auth"
+	"hexlet/internal/domain"
+	"hexlet/internal/dto"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// requireScope пропускает запрос с API ключом, только если у ключа есть scope.
+// Запросы с JWT сессией имеют все права.
+func requireScope(scope string) gin.HandlerFunc {
+	return func(rw *gin.Context) {
+		if val, ok := rw.Get("currentApiKey"); ok && !val.(domain.ApiKey).HasScope(scope) {
+			rw.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "api key does not have scope " + scope})
+			return
+		}
+		rw.Next()
+	}
+}
+
+// sessionOnly закрывает от API ключей управление аккаунтом: профиль, входы, сами ключи
+func sessionOnly() gin.HandlerFunc {
+	return func(rw *gin.Context) {
+		if _, ok := rw.Get("currentApiKey"); ok {
+			rw.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "not available for api keys"})
+			return
+		}
+		rw.Next()
+	}
+}
+
+// CreateApiKey godoc
+// @Summary      Create API key
+// @Description  creates a named scoped key for scripts and CI, the key is shown only once
+// @Tags         api-keys
+// @Accept       json
+// @Produce      json
+// @Param        request body dto.CreateApiKeyRequest true "key name, scopes and expiry"
+// @Success      201  {object}  dto.CreateApiKeyResponce
+// @Failure      400  {object}  dto.ErrorResponse
+// @Failure      500  {object}  dto.ErrorResponse
+// @Router       /api-keys [post]
+func (a *App) CreateApiKey(rw *gin.Context) {
+	val, exists := rw.Get("currentUserID")
+	if !exists {
+		rw.JSON(500, gin.H{"error": "User not found"})
+		return
+	}
+	var request dto.CreateApiKeyRequest
+	if err := rw.ShouldBindJSON(&request); err != nil {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validate(&request); err != nil {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if request.Expires_at != nil && !request.Expires_at.After(time.Now()) {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": "expires_at must be in the future"})
+		return
+	}
+	request.ID_user = val.(string)
+	key, prefix, err := auth.NewApiKey()
+	if err != nil {
+		rw.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	apiKey, err := a.Repo.CreateApiKey(rw.Request.Context(), uuid.NewString(), prefix, auth.HashToken(key), request)
+	if err != nil {
+		rw.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	rw.JSON(http.StatusCreated, dto.CreateApiKeyResponce{
+		Key:     key,
+		Api_key: apiKey,
+	})
+}
+
+// GetApiKeys godoc
+// @Summary      Get API keys
+// @Description  getting not revoked API keys of the user, without the keys themselves
+// @Tags         api-keys
+// @Produce      json
+// @Success      200  {array}   domain.ApiKey
+// @Failure      500  {object}  dto.ErrorResponse
+// @Router       /api-keys [get]
+func (a *App) GetApiKeys(rw *gin.Context) {
+	val, exists := rw.Get("currentUserID")
+	if !exists {
+		rw.JSON(500, gin.H{"error": "User not found"})
+		return
+	}
+	keys, err := a.Repo.GetApiKeys(rw.Request.Context(), val.(string))
+	if err != nil {
+		rw.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	rw.JSON(http.StatusOK, keys)
+}
+
+// DeleteApiKey godoc
+// @Summary      Revoke API key
+// @Description  revokes the key, requests with it are rejected right away
+// @Tags         api-keys
+// @Param        id path string true "API key ID"
+// @Success      204  "No Content"
+// @Failure      404  {object}  dto.ErrorResponse
+// @Failure      500  {object}  dto.ErrorResponse
+// @Router       /api-keys/{id} [delete]
+func (a *App) DeleteApiKey(rw *gin.Context) {
+	val, exists := rw.Get("currentUserID")
+	if !exists {
+		rw.JSON(500, gin.H{"error": "User not found"})
+		return
+	}
+	revoked, err := a.Repo.RevokeApiKey(rw.Request.Context(), rw.Param("id"), val.(string))
+	if err != nil {
+		rw.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !revoked {
+		rw.JSON(http.StatusNotFound, gin.H{"error": "api key not found"})
+		return
+	}
+	rw.Status(http.StatusNoContent)
+}
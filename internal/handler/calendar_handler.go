@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"hexlet/internal/auth"
+	"hexlet/internal/domain"
+	"hexlet/internal/dto"
+	"hexlet/internal/ical"
+	"hexlet/internal/repository"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// длительность события в календаре: у публикации нет конца
+	calendarEventLength = 15 * time.Minute
+	calendarRefresh     = 15 * time.Minute
+)
+
+// CreateCalendarToken godoc
+// @Summary      Create calendar link
+// @Description  creates a secret link to the publishing schedule of all workspaces of the user for calendar clients; a new link replaces the previous one
+// @Tags         calendar
+// @Produce      json
+// @Success      201  {object}  dto.CalendarTokenResponce
+// @Failure      500  {object}  dto.ErrorResponse
+// @Router       /me/calendar [post]
+func (a *App) CreateCalendarToken(rw *gin.Context) {
+	token, err := auth.NewOpaqueToken()
+	if err != nil {
+		rw.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	if err := a.Repo.CreateCalendarToken(rw.Request.Context(), rw.GetString("currentUserID"), auth.HashToken(token)); err != nil {
+		rw.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	rw.JSON(http.StatusCreated, dto.CalendarTokenResponce{
+		Token: token,
+		Url:   a.PublicURL + "/calendar.ics?token=" + url.QueryEscape(token),
+	})
+}
+
+// DeleteCalendarToken godoc
+// @Summary      Revoke calendar link
+// @Tags         calendar
+// @Success      204  "No Content"
+// @Failure      404  {object}  dto.ErrorResponse
+// @Failure      500  {object}  dto.ErrorResponse
+// @Router       /me/calendar [delete]
+func (a *App) DeleteCalendarToken(rw *gin.Context) {
+	deleted, err := a.Repo.DeleteCalendarToken(rw.Request.Context(), rw.GetString("currentUserID"))
+	if err != nil {
+		rw.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !deleted {
+		rw.JSON(http.StatusNotFound, gin.H{"error": repository.ErrCalendarNotFound.Error()})
+		return
+	}
+	rw.Status(http.StatusNoContent)
+}
+
+// GetCalendar godoc
+// @Summary      Publishing calendar
+// @Description  iCalendar feed with one event per scheduled destination. Published posts are CONFIRMED, failed and skipped are CANCELLED, the rest are TENTATIVE; the status is also in categories.
+// @Tags         calendar
+// @Produce      text/calendar
+// @Param        token query string true "calendar token"
+// @Success      200  {string}  string
+// @Failure      404  {object}  dto.ErrorResponse
+// @Failure      500  {object}  dto.ErrorResponse
+// @Router       /calendar.ics [get]
+func (a *App) GetCalendar(rw *gin.Context) {
+	token := rw.Query("token")
+	if token == "" {
+		rw.JSON(http.StatusNotFound, gin.H{"error": repository.ErrCalendarNotFound.Error()})
+		return
+	}
+	events, err := a.Repo.GetCalendarEvents(rw.Request.Context(), auth.HashToken(token))
+	if errors.Is(err, repository.ErrCalendarNotFound) {
+		rw.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		rw.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	cal := ical.Calendar{Name: "Content plan", Refresh: calendarRefresh}
+	for _, e := range events {
+		cal.Events = append(cal.Events, a.calendarEvent(e))
+	}
+	rw.Header("Content-Type", "text/calendar; charset=utf-8")
+	rw.Header("Cache-Control", "private, max-age=300")
+	rw.Status(http.StatusOK)
+	ical.Write(rw.Writer, cal)
+}
+
+func (a *App) calendarEvent(e domain.CalendarEvent) ical.Event {
+	start := e.Sheduled_for
+	status := ical.StatusTentative
+	switch e.Status {
+	case "published":
+		status = ical.StatusConfirmed
+		if e.Published_at != nil {
+			start = *e.Published_at
+		}
+	case "failed", "skipped":
+		status = ical.StatusCancelled
+	}
+	link := fmt.Sprintf("%s/posts/%d", a.PublicURL, e.ID_post)
+	description := []string{
+		"Status: " + e.Status,
+		"Platform: " + e.PlatformName,
+		"Workspace: " + e.Workspace_name,
+	}
+	if e.ErrorMessage != nil && *e.ErrorMessage != "" {
+		description = append(description, "Error: "+*e.ErrorMessage)
+	}
+	description = append(description, link)
+	host := "hexlet"
+	if u, err := url.Parse(a.PublicURL); err == nil && u.Host != "" {
+		host = u.Host
+	}
+	return ical.Event{
+		UID:         fmt.Sprintf("destination-%d@%s", e.ID_destination, host),
+		Start:       start,
+		End:         start.Add(calendarEventLength),
+		Summary:     fmt.Sprintf("[%s] %s", e.PlatformName, e.Title),
+		Description: strings.Join(description, "\n"),
+		URL:         link,
+		Status:      status,
+		Categories:  []string{e.PlatformName, e.Status},
+	}
+}
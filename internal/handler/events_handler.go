@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// комментарий раз в eventsHeartbeat не даёт прокси закрыть простаивающее соединение
+	eventsHeartbeat = 25 * time.Second
+	// через сколько EventSource переподключается после обрыва
+	eventsRetry = 5 * time.Second
+)
+
+// GetEvents godoc
+// @Summary      Status stream
+// @Description  Server-Sent Events stream of destination status changes in all workspaces of the user. Each event is "destination.status" with domain.StatusEvent as data.
+// @Description  A comment is sent every 25 seconds to keep the connection open. If the client reads too slowly the stream is closed; after reconnecting reload GET /posts to catch up.
+// @Tags         events
+// @Produce      text/event-stream
+// @Success      200  {object}  domain.StatusEvent
+// @Failure      500  {object}  dto.ErrorResponse
+// @Failure      503  {object}  dto.ErrorResponse
+// @Router       /events [get]
+func (a *App) GetEvents(rw *gin.Context) {
+	if a.Events == nil {
+		rw.JSON(http.StatusServiceUnavailable, gin.H{"error": "event stream is not available"})
+		return
+	}
+	workspaces, err := a.Repo.GetWorkspaces(rw.Request.Context(), rw.GetString("currentUserID"))
+	if err != nil {
+		rw.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ids := make([]int, 0, len(workspaces))
+	for _, w := range workspaces {
+		ids = append(ids, w.ID_workspace)
+	}
+	events, cancel := a.Events.Subscribe(ids)
+	defer cancel()
+
+	rw.Header("Content-Type", "text/event-stream")
+	rw.Header("Cache-Control", "no-cache")
+	rw.Header("Connection", "keep-alive")
+	// nginx не должен буферизовать поток
+	rw.Header("X-Accel-Buffering", "no")
+	rw.Status(http.StatusOK)
+	fmt.Fprintf(rw.Writer, "retry: %d\n\n", eventsRetry.Milliseconds())
+	rw.Writer.Flush()
+
+	streams := a.Streams
+	if streams == nil {
+		streams = a.Ctx
+	}
+	heartbeat := time.NewTicker(eventsHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-rw.Request.Context().Done():
+			return
+		case <-streams.Done():
+			return
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+			rw.SSEvent("destination.status", e)
+			rw.Writer.Flush()
+		case <-heartbeat.C:
+			fmt.Fprint(rw.Writer, ": ping\n\n")
+			rw.Writer.Flush()
+		}
+	}
+}
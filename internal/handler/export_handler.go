@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"bufio"
+	"fmt"
+	"hexlet/internal/domain"
+	"hexlet/internal/dto"
+	"hexlet/internal/export"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// через столько строк выгрузка отправляется клиенту
+const exportFlushRows = 500
+
+// ExportPosts godoc
+// @Summary      Export posts
+// @Description  streams posts with every destination: statuses, remote ids, errors and timestamps. Accepts the same filters as GET /posts.
+// @Description  The response is sent while rows are read, so an error after the first chunk cannot change the status code: the X-Export-Status trailer is "complete" only for a full file, X-Export-Rows holds the number of rows.
+// @Tags         posts
+// @Produce      text/csv
+// @Produce      application/x-ndjson
+// @Produce      application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Param        format query string false "csv (default), ndjson or xlsx"
+// @Param        status query string false "destination status"
+// @Param        review_status query string false "review status of the post"
+// @Param        platform_id query int false "platform"
+// @Param        from query string false "scheduled at or after, RFC3339"
+// @Param        to query string false "scheduled before, RFC3339"
+// @Success      200  {file}    file
+// @Failure      400  {object}  dto.ErrorResponse
+// @Failure      500  {object}  dto.ErrorResponse
+// @Router       /posts/export [get]
+func (a *App) ExportPosts(rw *gin.Context) {
+	var request dto.ExportPostsRequest
+	if err := rw.ShouldBindQuery(&request); err != nil {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validate(&request); err != nil {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	format := request.Format
+	if format == "" {
+		format = export.FormatCSV
+	}
+	rw.Header("Content-Type", export.ContentType(format))
+	rw.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="posts-%s.%s"`, time.Now().Format("20060102-150405"), format))
+	rw.Header("Trailer", "X-Export-Status, X-Export-Rows")
+
+	// пока буфер не отправлен, ошибку ещё можно вернуть обычным ответом
+	buf := bufio.NewWriterSize(rw.Writer, 64<<10)
+	rows := 0
+	w, err := export.NewWriter(format, buf)
+	if err == nil {
+		err = a.Repo.ExportPosts(rw.Request.Context(), rw.GetInt("currentWorkspaceID"), request.PostFilter, func(row domain.ExportRow) error {
+			if err := w.Write(row); err != nil {
+				return err
+			}
+			rows++
+			if rows%exportFlushRows == 0 {
+				return flushExport(rw, w, buf)
+			}
+			return nil
+		})
+	}
+	if err == nil {
+		err = w.Close()
+	}
+	if err != nil && !rw.Writer.Written() {
+		for _, h := range []string{"Content-Type", "Content-Disposition", "Trailer"} {
+			rw.Writer.Header().Del(h)
+		}
+		rw.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err == nil {
+		err = flushExport(rw, nil, buf)
+	}
+	status := "complete"
+	if err != nil {
+		status = "error"
+	}
+	rw.Writer.Header().Set("X-Export-Status", status)
+	rw.Writer.Header().Set("X-Export-Rows", strconv.Itoa(rows))
+}
+
+func flushExport(rw *gin.Context, w export.Writer, buf *bufio.Writer) error {
+	if w != nil {
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+	if err := buf.Flush(); err != nil {
+		return err
+	}
+	rw.Writer.Flush()
+	return nil
+}
@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"hexlet/internal/auth"
+	"hexlet/internal/domain"
+	"hexlet/internal/repository"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/markbates/goth"
+)
+
+// GetIdentities godoc
+// @Summary      Get linked identities
+// @Description  getting login providers linked to the account
+// @Tags         users
+// @Produce      json
+// @Success      200  {array}   domain.UserIdentity
+// @Failure      500  {object}  dto.ErrorResponse
+// @Router       /me/identities [get]
+func (a *App) GetIdentities(rw *gin.Context) {
+	val, exists := rw.Get("currentUserID")
+	if !exists {
+		rw.JSON(500, gin.H{"error": "User not found"})
+		return
+	}
+	res, err := a.Repo.GetIdentities(rw.Request.Context(), val.(string))
+	if err != nil {
+		rw.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	rw.JSON(http.StatusOK, res)
+}
+
+// LinkIdentity godoc
+// @Summary      Start linking a login provider
+// @Description  returns the URL to open in the browser to link one more login provider to the account.
+// @Description  The URL works once and expires in 10 minutes.
+// @Tags         users
+// @Produce      json
+// @Param        provider path string true "Provider name (google, github, yandex, vk)"
+// @Success      200  {object}  map[string]string
+// @Failure      404  {object}  dto.ErrorResponse
+// @Failure      500  {object}  dto.ErrorResponse
+// @Router       /me/identities/{provider} [post]
+func (a *App) LinkIdentity(rw *gin.Context) {
+	val, exists := rw.Get("currentUserID")
+	if !exists {
+		rw.JSON(500, gin.H{"error": "User not found"})
+		return
+	}
+	provider := rw.Param("provider")
+	if _, err := goth.GetProvider(provider); err != nil {
+		rw.JSON(http.StatusNotFound, gin.H{"error": "provider is not enabled"})
+		return
+	}
+	token, err := a.newLinkToken(rw.Request.Context(), val.(string))
+	if err != nil {
+		rw.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	rw.JSON(http.StatusOK, gin.H{"url": "/auth/" + provider + "?link_token=" + url.QueryEscape(token)})
+}
+
+// newLinkToken — одноразовый токен привязки провайдера к аккаунту, в БД хранится только хэш
+func (a *App) newLinkToken(ctx context.Context, userID string) (string, error) {
+	token, err := auth.NewOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	err = a.Repo.CreateAuthToken(ctx, userID, domain.TokenLinkIdentity, auth.HashToken(token), time.Now().Add(auth.LinkTTL))
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// UnlinkIdentity godoc
+// @Summary      Unlink a login provider
+// @Description  unlinks the login provider from the account, the last login method cannot be unlinked
+// @Tags         users
+// @Param        provider path string true "Provider name"
+// @Success      204  "No Content"
+// @Failure      404  {object}  dto.ErrorResponse
+// @Failure      409  {object}  dto.ErrorResponse
+// @Failure      500  {object}  dto.ErrorResponse
+// @Router       /me/identities/{provider} [delete]
+func (a *App) UnlinkIdentity(rw *gin.Context) {
+	val, exists := rw.Get("currentUserID")
+	if !exists {
+		rw.JSON(500, gin.H{"error": "User not found"})
+		return
+	}
+	deleted, err := a.Repo.UnlinkIdentity(rw.Request.Context(), val.(string), rw.Param("provider"))
+	if errors.Is(err, repository.ErrLastIdentity) {
+		rw.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		rw.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !deleted {
+		rw.JSON(http.StatusNotFound, gin.H{"error": "identity not found"})
+		return
+	}
+	rw.Status(http.StatusNoContent)
+}
+
+// linkIdentity завершает привязку провайдера после OAuth callback
+func (a *App) linkIdentity(rw *gin.Context, userID string, identity domain.Identity) {
+	err := a.Repo.LinkIdentity(rw.Request.Context(), userID, identity)
+	if errors.Is(err, repository.ErrIdentityTaken) {
+		rw.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		rw.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	rw.JSON(http.StatusOK, gin.H{"linked": identity.Provider})
+}
@@ -0,0 +1,301 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"hexlet/internal/domain"
+	"hexlet/internal/dto"
+	"hexlet/internal/postimport"
+	"hexlet/internal/repository"
+	"hexlet/internal/tracing"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	importMaxSize = 10 << 20
+	// файлы длиннее обрабатываются в фоне, прогресс сохраняется с тем же шагом
+	importSyncRows = 100
+)
+
+// ImportPosts godoc
+// @Summary      Import posts
+// @Description  creates posts from CSV (header row required) or NDJSON with title, content, scheduled_for, platforms and timezone.
+// @Description  The file is sent as the request body or as multipart field "file". Platforms are names or ids separated by comma or semicolon, empty means all platforms of the workspace.
+// @Description  scheduled_for without offset is read in the row timezone, then in the timezone parameter, then in UTC.
+// @Description  dry_run only validates rows; all_or_nothing creates nothing if any row fails. Files with more than 100 rows are imported in background, the response is the job to poll.
+// @Tags         posts
+// @Accept       text/csv
+// @Accept       application/x-ndjson
+// @Accept       multipart/form-data
+// @Produce      json
+// @Param        format query string false "csv or ndjson, detected from Content-Type or file name by default"
+// @Param        dry_run query bool false "validate only"
+// @Param        all_or_nothing query bool false "create posts only if every row is valid"
+// @Param        timezone query string false "default IANA timezone, e.g. Europe/Moscow"
+// @Param        draft query bool false "create posts as drafts instead of submitting them for review"
+// @Success      200  {object}  dto.ImportPostsResponce
+// @Success      202  {object}  domain.ImportJob
+// @Failure      400  {object}  dto.ErrorResponse
+// @Failure      413  {object}  dto.ErrorResponse
+// @Failure      422  {object}  dto.ImportPostsResponce
+// @Failure      500  {object}  dto.ErrorResponse
+// @Router       /posts/import [post]
+func (a *App) ImportPosts(rw *gin.Context) {
+	var request dto.ImportPostsRequest
+	if err := rw.ShouldBindQuery(&request); err != nil {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	loc := time.UTC
+	if request.Timezone != "" {
+		var err error
+		if loc, err = time.LoadLocation(request.Timezone); err != nil {
+			rw.JSON(http.StatusBadRequest, gin.H{"error": "unknown timezone"})
+			return
+		}
+	}
+	file, filename, err := importFile(rw)
+	if err != nil {
+		importReadError(rw, err)
+		return
+	}
+	defer file.Close()
+	format, err := postimport.Format(request.Format, rw.ContentType(), filename)
+	if err != nil {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	records, err := postimport.Parse(file, format)
+	if err != nil {
+		importReadError(rw, err)
+		return
+	}
+
+	workspaceID := rw.GetInt("currentWorkspaceID")
+	platforms, err := a.Repo.GetPlatform(rw.Request.Context(), workspaceID)
+	if err != nil {
+		rw.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	res := dto.ImportPostsResponce{
+		Dry_run: request.Dry_run,
+		Total:   len(records),
+		Errors:  []domain.ImportLineError{},
+		Posts:   []int{},
+	}
+	opts := postimport.Options{Location: loc, Now: time.Now()}
+	var posts []postimport.Post
+	for _, rec := range records {
+		post, problems := postimport.Check(rec, platforms.Platfroms, opts)
+		if len(problems) > 0 {
+			res.Errors = append(res.Errors, domain.ImportLineError{Line: rec.Line, Errors: problems})
+			continue
+		}
+		post.Request.ID_user = rw.GetString("currentUserID")
+		post.Request.ID_workspace = workspaceID
+		post.Request.Draft = request.Draft
+		post.Request.Status = domain.PostInReview
+		if request.Draft {
+			post.Request.Status = domain.PostDraft
+		}
+		posts = append(posts, post)
+	}
+	res.Valid = len(posts)
+	res.Failed = len(res.Errors)
+	if request.Dry_run {
+		rw.JSON(http.StatusOK, res)
+		return
+	}
+	if request.All_or_nothing && len(res.Errors) > 0 {
+		rw.JSON(http.StatusUnprocessableEntity, res)
+		return
+	}
+
+	if len(records) > importSyncRows {
+		job := domain.ImportJob{
+			ID_job:         uuid.NewString(),
+			ID_user:        rw.GetString("currentUserID"),
+			Status:         domain.ImportPending,
+			All_or_nothing: request.All_or_nothing,
+			Total:          len(records),
+			Failed:         len(res.Errors),
+			Errors:         res.Errors,
+			Posts:          []int{},
+			Created_at:     time.Now(),
+		}
+		if err := a.Repo.CreateImportJob(rw.Request.Context(), workspaceID, job); err != nil {
+			rw.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		go a.runImport(tracing.Detach(a.Ctx, rw.Request.Context()), job, posts)
+		rw.Header("Location", "/posts/import/"+job.ID_job)
+		rw.JSON(http.StatusAccepted, job)
+		return
+	}
+
+	ids, failed, err := a.importPosts(rw.Request.Context(), posts, request.All_or_nothing, nil)
+	if err != nil {
+		rw.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	res.Posts = ids
+	res.Created = len(ids)
+	res.Errors = mergeLineErrors(res.Errors, failed)
+	res.Failed = len(res.Errors)
+	rw.JSON(http.StatusOK, res)
+}
+
+// GetImportJob godoc
+// @Summary      Import job status
+// @Description  progress of a background import with per line errors
+// @Tags         posts
+// @Produce      json
+// @Param        id path string true "Job ID"
+// @Success      200  {object}  domain.ImportJob
+// @Failure      404  {object}  dto.ErrorResponse
+// @Failure      500  {object}  dto.ErrorResponse
+// @Router       /posts/import/{id} [get]
+func (a *App) GetImportJob(rw *gin.Context) {
+	id := rw.Param("id")
+	if _, err := uuid.Parse(id); err != nil {
+		rw.JSON(http.StatusNotFound, gin.H{"error": repository.ErrImportJobNotFound.Error()})
+		return
+	}
+	job, err := a.Repo.GetImportJob(rw.Request.Context(), id, rw.GetInt("currentWorkspaceID"))
+	if errors.Is(err, repository.ErrImportJobNotFound) {
+		rw.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		rw.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	rw.JSON(http.StatusOK, job)
+}
+
+// importFile — тело запроса или поле file из multipart
+func importFile(rw *gin.Context) (io.ReadCloser, string, error) {
+	rw.Request.Body = http.MaxBytesReader(rw.Writer, rw.Request.Body, importMaxSize)
+	if !strings.HasPrefix(rw.ContentType(), "multipart/form-data") {
+		return rw.Request.Body, "", nil
+	}
+	header, err := rw.FormFile("file")
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return nil, "", err
+	}
+	if err != nil {
+		return nil, "", errors.New("multipart field file is required")
+	}
+	file, err := header.Open()
+	if err != nil {
+		return nil, "", err
+	}
+	return file, header.Filename, nil
+}
+
+func importReadError(rw *gin.Context, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		rw.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "file is too large"})
+		return
+	}
+	rw.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+}
+
+// importPosts создаёт проверенные строки. В режиме all_or_nothing все посты создаются одной транзакцией,
+// иначе ошибка одной строки не мешает остальным. progress вызывается после каждых importSyncRows строк.
+func (a *App) importPosts(ctx context.Context, posts []postimport.Post, atomic bool, progress func(ids []int, failed []domain.ImportLineError)) ([]int, []domain.ImportLineError, error) {
+	ids := []int{}
+	failed := []domain.ImportLineError{}
+	if atomic {
+		requests := make([]dto.CreatePostRequest, 0, len(posts))
+		for _, post := range posts {
+			requests = append(requests, post.Request)
+		}
+		if len(requests) == 0 {
+			return ids, failed, nil
+		}
+		created, err := a.Repo.CreatePosts(ctx, requests)
+		if err != nil {
+			return nil, nil, err
+		}
+		for i, ID := range created {
+			a.importedEvent(ctx, requests[i], ID)
+		}
+		return created, failed, nil
+	}
+	for i, post := range posts {
+		ID, _, err := a.Repo.CreatePost(ctx, post.Request)
+		if err != nil {
+			failed = append(failed, domain.ImportLineError{Line: post.Line, Errors: []string{err.Error()}})
+		} else {
+			ids = append(ids, ID)
+			a.importedEvent(ctx, post.Request, ID)
+		}
+		if progress != nil && (i+1)%importSyncRows == 0 {
+			progress(ids, failed)
+		}
+	}
+	return ids, failed, nil
+}
+
+func (a *App) importedEvent(ctx context.Context, post dto.CreatePostRequest, ID_post int) {
+	a.enqueuePostEvent(ctx, post.ID_workspace, post.ID_user, domain.EventPostCreated, ID_post, post.ID_user, domain.PostEventData{Status: post.Status})
+}
+
+// runImport выполняет задачу в фоне и сохраняет результат; трасса продолжает трассу запроса
+func (a *App) runImport(ctx context.Context, job domain.ImportJob, posts []postimport.Post) {
+	ctx, span := tracing.Start(ctx, "import.run")
+	defer span.End()
+	checked := job.Errors
+	job.Status = domain.ImportRunning
+	a.saveImportJob(ctx, job)
+
+	ids, failed, err := a.importPosts(ctx, posts, job.All_or_nothing, func(ids []int, failed []domain.ImportLineError) {
+		job.Posts = ids
+		job.Created = len(ids)
+		job.Errors = mergeLineErrors(checked, failed)
+		job.Failed = len(job.Errors)
+		a.saveImportJob(ctx, job)
+	})
+	if err != nil {
+		job.Status = domain.ImportFailed
+		job.Posts = []int{}
+		job.Created = 0
+		job.Errors = []domain.ImportLineError{{Errors: []string{"import failed: " + err.Error()}}}
+		job.Failed = job.Total
+		a.saveImportJob(ctx, job)
+		return
+	}
+	job.Status = domain.ImportDone
+	job.Posts = ids
+	job.Created = len(ids)
+	job.Errors = mergeLineErrors(checked, failed)
+	job.Failed = len(job.Errors)
+	a.saveImportJob(ctx, job)
+}
+
+// saveImportJob сохраняет состояние задачи. Если сохранить не удалось, задача
+// остаётся в прежнем статусе и после простоя помечается failed (FailStaleImportJobs).
+func (a *App) saveImportJob(ctx context.Context, job domain.ImportJob) {
+	if err := a.Repo.UpdateImportJob(ctx, job); err != nil {
+		log.Printf("Error saving import job %s (%s): %v", job.ID_job, job.Status, err)
+	}
+}
+
+func mergeLineErrors(a, b []domain.ImportLineError) []domain.ImportLineError {
+	res := make([]domain.ImportLineError, 0, len(a)+len(b))
+	res = append(res, a...)
+	res = append(res, b...)
+	sort.Slice(res, func(i, j int) bool { return res[i].Line < res[j].Line })
+	return res
+}
@@ -0,0 +1,319 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hexlet/internal/auth"
+	"hexlet/internal/domain"
+	"hexlet/internal/dto"
+	"hexlet/internal/mailer"
+	"hexlet/internal/repository"
+	"hexlet/internal/tracing"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v4"
+)
+
+const (
+	maxLoginFailures = 5
+	loginLockout     = 15 * time.Minute
+	verifyEmailTTL   = 24 * time.Hour
+	resetPasswordTTL = time.Hour
+)
+
+// Хэш для несуществующих email: проверка пароля занимает столько же времени,
+// и по задержке нельзя узнать, зарегистрирован ли адрес.
+var dummyPasswordHash = sync.OnceValue(func() string {
+	hash, _ := auth.HashPassword("dummy password")
+	return hash
+})
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// Register godoc
+// @Summary      Register with email and password
+// @Description  creates a local account and sends an email verification link.
+// @Description  The response is the same whether or not the email is already registered.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        request body dto.RegisterRequest true "account info"
+// @Success      202  "Accepted"
+// @Failure      400  {object}  dto.ErrorResponse
+// @Failure      500  {object}  dto.ErrorResponse
+// @Router       /auth/register [post]
+func (a *App) Register(rw *gin.Context) {
+	var request dto.RegisterRequest
+	if err := rw.ShouldBindJSON(&request); err != nil {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	request.Email = normalizeEmail(request.Email)
+	if err := validate(&request); err != nil {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	hash, err := auth.HashPassword(request.Password)
+	if err != nil {
+		rw.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	user, err := a.Repo.CreateLocalUser(rw.Request.Context(), request.Email, request.Name, hash)
+	// ответ не выдаёт, зарегистрирован ли email
+	if errors.Is(err, repository.ErrEmailTaken) {
+		rw.Status(http.StatusAccepted)
+		return
+	}
+	if err != nil {
+		rw.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	a.sendAuthEmailAsync(rw.Request.Context(), user.ID_user, request.Email, domain.TokenVerifyEmail)
+	rw.Status(http.StatusAccepted)
+}
+
+// VerifyEmail godoc
+// @Summary      Verify email
+// @Description  confirms the email by the token from the verification letter
+// @Tags         auth
+// @Produce      json
+// @Param        token query string true "Verification token"
+// @Success      200  {object}  map[string]bool
+// @Failure      400  {object}  dto.ErrorResponse
+// @Failure      500  {object}  dto.ErrorResponse
+// @Router       /auth/verify-email [get]
+func (a *App) VerifyEmail(rw *gin.Context) {
+	token := rw.Query("token")
+	if token == "" {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": "token is required"})
+		return
+	}
+	err := a.Repo.VerifyEmail(rw.Request.Context(), auth.HashToken(token))
+	if errors.Is(err, repository.ErrAuthTokenInvalid) {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		rw.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	rw.JSON(http.StatusOK, gin.H{"verified": true})
+}
+
+// ResendVerification godoc
+// @Summary      Resend verification email
+// @Description  sends a new verification link if the email is registered and not verified yet
+// @Tags         auth
+// @Accept       json
+// @Param        request body dto.ForgotPasswordRequest true "email"
+// @Success      202  "Accepted"
+// @Failure      400  {object}  dto.ErrorResponse
+// @Router       /auth/verify-email/resend [post]
+func (a *App) ResendVerification(rw *gin.Context) {
+	var request dto.ForgotPasswordRequest
+	if err := rw.ShouldBindJSON(&request); err != nil {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	request.Email = normalizeEmail(request.Email)
+	if err := validate(&request); err != nil {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// ответ не зависит от того, есть ли такой email
+	creds, err := a.Repo.GetLocalCredentials(rw.Request.Context(), request.Email)
+	if err == nil && !creds.Email_verified {
+		a.sendAuthEmailAsync(rw.Request.Context(), creds.ID_user, request.Email, domain.TokenVerifyEmail)
+	}
+	rw.Status(http.StatusAccepted)
+}
+
+// Login godoc
+// @Summary      Login with email and password
+// @Description  issues the same access / refresh tokens as OAuth login
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        request body dto.LoginRequest true "credentials"
+// @Success      200  {object}  map[string]string
+// @Failure      400  {object}  dto.ErrorResponse
+// @Failure      401  {object}  dto.ErrorResponse
+// @Failure      403  {object}  dto.ErrorResponse
+// @Failure      429  {object}  dto.ErrorResponse
+// @Failure      500  {object}  dto.ErrorResponse
+// @Router       /auth/login [post]
+func (a *App) Login(rw *gin.Context) {
+	var request dto.LoginRequest
+	if err := rw.ShouldBindJSON(&request); err != nil {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	request.Email = normalizeEmail(request.Email)
+	if err := validate(&request); err != nil {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	creds, err := a.Repo.GetLocalCredentials(rw.Request.Context(), request.Email)
+	if errors.Is(err, pgx.ErrNoRows) {
+		auth.VerifyPassword(request.Password, dummyPasswordHash())
+		rw.JSON(http.StatusUnauthorized, gin.H{"error": "invalid email or password"})
+		return
+	}
+	if err != nil {
+		rw.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	if creds.Locked_until != nil && time.Now().Before(*creds.Locked_until) {
+		tooManyAttempts(rw, *creds.Locked_until)
+		return
+	}
+	ok, err := auth.VerifyPassword(request.Password, creds.Password_hash)
+	if err != nil {
+		rw.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	if !ok {
+		lockedUntil, err := a.Repo.RecordLoginFailure(rw.Request.Context(), creds.ID_user, maxLoginFailures, loginLockout)
+		if err == nil && lockedUntil != nil && time.Now().Before(*lockedUntil) {
+			tooManyAttempts(rw, *lockedUntil)
+			return
+		}
+		rw.JSON(http.StatusUnauthorized, gin.H{"error": "invalid email or password"})
+		return
+	}
+	if !creds.Email_verified {
+		rw.JSON(http.StatusForbidden, gin.H{"error": "email is not verified"})
+		return
+	}
+	if err := a.Repo.ResetLoginFailures(rw.Request.Context(), creds.ID_user); err != nil {
+		rw.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	accessToken, err := a.startSession(rw, creds.ID_user)
+	if err != nil {
+		rw.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	rw.JSON(http.StatusOK, gin.H{
+		"access_token": accessToken,
+	})
+}
+
+func tooManyAttempts(rw *gin.Context, lockedUntil time.Time) {
+	retryAfter := int(time.Until(lockedUntil).Seconds()) + 1
+	rw.Header("Retry-After", strconv.Itoa(retryAfter))
+	rw.JSON(http.StatusTooManyRequests, gin.H{"error": "too many failed login attempts, try again later"})
+}
+
+// ForgotPassword godoc
+// @Summary      Request password reset
+// @Description  sends a password reset token to the email if it is registered
+// @Tags         auth
+// @Accept       json
+// @Param        request body dto.ForgotPasswordRequest true "email"
+// @Success      202  "Accepted"
+// @Failure      400  {object}  dto.ErrorResponse
+// @Router       /auth/password/forgot [post]
+func (a *App) ForgotPassword(rw *gin.Context) {
+	var request dto.ForgotPasswordRequest
+	if err := rw.ShouldBindJSON(&request); err != nil {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	request.Email = normalizeEmail(request.Email)
+	if err := validate(&request); err != nil {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// ответ не зависит от того, есть ли такой email
+	if creds, err := a.Repo.GetLocalCredentials(rw.Request.Context(), request.Email); err == nil {
+		a.sendAuthEmailAsync(rw.Request.Context(), creds.ID_user, request.Email, domain.TokenResetPassword)
+	}
+	rw.Status(http.StatusAccepted)
+}
+
+// ResetPassword godoc
+// @Summary      Reset password
+// @Description  sets a new password by the reset token, all sessions of the user are revoked
+// @Tags         auth
+// @Accept       json
+// @Param        request body dto.ResetPasswordRequest true "token and new password"
+// @Success      204  "No Content"
+// @Failure      400  {object}  dto.ErrorResponse
+// @Failure      500  {object}  dto.ErrorResponse
+// @Router       /auth/password/reset [post]
+func (a *App) ResetPassword(rw *gin.Context) {
+	var request dto.ResetPasswordRequest
+	if err := rw.ShouldBindJSON(&request); err != nil {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validate(&request); err != nil {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	hash, err := auth.HashPassword(request.Password)
+	if err != nil {
+		rw.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	err = a.Repo.ResetPassword(rw.Request.Context(), auth.HashToken(request.Token), hash)
+	if errors.Is(err, repository.ErrAuthTokenInvalid) {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		rw.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	rw.Status(http.StatusNoContent)
+}
+
+// sendAuthEmailAsync отправляет письмо в фоне: время ответа не должно выдавать,
+// есть ли аккаунт с таким email
+func (a *App) sendAuthEmailAsync(ctx context.Context, userID string, email string, purpose string) {
+	go a.sendAuthEmail(tracing.Detach(a.Ctx, ctx), userID, email, purpose)
+}
+
+// sendAuthEmail создаёт одноразовый токен и отправляет письмо со ссылкой.
+// Ошибки только логируются: ответ клиенту не должен зависеть от доставки.
+func (a *App) sendAuthEmail(ctx context.Context, userID string, email string, purpose string) {
+	token, err := auth.NewOpaqueToken()
+	if err != nil {
+		log.Printf("failed to generate %s token: %v", purpose, err)
+		return
+	}
+	ttl := verifyEmailTTL
+	msg := mailer.Message{
+		To:      email,
+		Subject: "Подтверждение email",
+		Body: fmt.Sprintf("Чтобы подтвердить email, откройте ссылку:\n%s/auth/verify-email?token=%s\n\nСсылка действует %v.",
+			a.PublicURL, url.QueryEscape(token), ttl),
+	}
+	if purpose == domain.TokenResetPassword {
+		ttl = resetPasswordTTL
+		msg.Subject = "Сброс пароля"
+		msg.Body = fmt.Sprintf("Токен для сброса пароля (POST /auth/password/reset):\n%s\n\nТокен действует %v. Если вы не запрашивали сброс, просто проигнорируйте письмо.",
+			token, ttl)
+	}
+	if err := a.Repo.CreateAuthToken(ctx, userID, purpose, auth.HashToken(token), time.Now().Add(ttl)); err != nil {
+		return
+	}
+	if a.Mailer == nil {
+		log.Printf("mailer is not configured, %s email to %s is not sent", purpose, email)
+		return
+	}
+	if err := a.Mailer.Send(ctx, msg); err != nil {
+		log.Printf("failed to send %s email: %v", purpose, err)
+	}
+}
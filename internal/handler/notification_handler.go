@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"hexlet/internal/domain"
+	"hexlet/internal/dto"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetNotifications godoc
+// @Summary      Get notifications
+// @Description  getting latest notifications of user (platform degraded / deactivated, ...)
+// @Tags         notifications
+// @Produce      json
+// @Success      200  {array}   domain.Notification
+// @Failure      500  {object}  dto.ErrorResponse
+// @Router       /notifications [get]
+func (a *App) GetNotifications(rw *gin.Context) {
+	val, exists := rw.Get("currentUserID")
+	if !exists {
+		rw.JSON(500, gin.H{"error": "User not found"})
+		return
+	}
+	userID := val.(string)
+	res, err := a.Repo.GetNotifications(rw.Request.Context(), userID)
+	if err != nil {
+		rw.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	rw.JSON(http.StatusOK, res)
+}
+
+// GetNotificationSettings godoc
+// @Summary      Get notification settings
+// @Description  channels and schedule of notifications of the authorized user; defaults are returned until settings are saved
+// @Tags         notifications
+// @Produce      json
+// @Success      200  {object}  domain.NotificationSettings
+// @Failure      500  {object}  dto.ErrorResponse
+// @Router       /me/notification-settings [get]
+func (a *App) GetNotificationSettings(rw *gin.Context) {
+	res, err := a.Repo.GetNotificationSettings(rw.Request.Context(), rw.GetString("currentUserID"))
+	if err != nil {
+		rw.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	rw.JSON(http.StatusOK, res)
+}
+
+// PutNotificationSettings godoc
+// @Summary      Update notification settings
+// @Description  replaces channels and schedule of notifications (publication failed or stuck in processing, platform credentials invalid).
+// @Description  Channels: email (verified address), telegram (chat linked via POST /me/telegram, failed publications get retry buttons), webhook (notification.created event to webhooks of the workspace).
+// @Description  Delivery: immediate collects notifications for batch_minutes into one message, hourly sends a digest at the start of every hour, daily at digest_hour in the user timezone.
+// @Tags         notifications
+// @Accept       json
+// @Produce      json
+// @Param        request body dto.NotificationSettingsRequest true "settings"
+// @Success      200  {object}  domain.NotificationSettings
+// @Failure      400  {object}  dto.ErrorResponse
+// @Failure      500  {object}  dto.ErrorResponse
+// @Router       /me/notification-settings [put]
+func (a *App) PutNotificationSettings(rw *gin.Context) {
+	var request dto.NotificationSettingsRequest
+	if err := rw.ShouldBindJSON(&request); err != nil {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validate(&request); err != nil {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	res, err := a.Repo.UpdateNotificationSettings(rw.Request.Context(), rw.GetString("currentUserID"), domain.NotificationSettings{
+		Email:         request.Email,
+		Telegram:      request.Telegram,
+		Webhook:       request.Webhook,
+		Kinds:         request.Kinds,
+		Delivery:      request.Delivery,
+		Batch_minutes: request.Batch_minutes,
+		Digest_hour:   request.Digest_hour,
+	})
+	if err != nil {
+		rw.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	rw.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,138 @@
+package handler
+
+import (
+	"hexlet/internal/dto"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PausePlatform godoc
+// @Summary      Pause platform
+// @Description  stops publishing to the platform, scheduled publications are held until resume
+// @Tags         platforms
+// @Produce      json
+// @Param        id path int true "Platform ID"
+// @Success      200  {object}  dto.PauseResponce
+// @Failure      400  {object}  dto.ErrorResponse
+// @Failure      404  {object}  dto.ErrorResponse
+// @Failure      409  {object}  dto.ErrorResponse
+// @Failure      500  {object}  dto.ErrorResponse
+// @Router       /platforms/{id}/pause [post]
+func (a *App) PausePlatform(rw *gin.Context) {
+	id, err := strconv.Atoi(rw.Param("id"))
+	if err != nil {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	workspaceID := rw.GetInt("currentWorkspaceID")
+	if _, err := a.Repo.GetPlatformByID(rw.Request.Context(), id, workspaceID); err != nil {
+		rw.JSON(http.StatusNotFound, gin.H{"error": "platform not found"})
+		return
+	}
+	res, err := a.Repo.PausePlatforms(rw.Request.Context(), workspaceID, id)
+	if err != nil {
+		rw.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if len(res.Platforms) == 0 {
+		rw.JSON(http.StatusConflict, gin.H{"error": "platform is already paused"})
+		return
+	}
+	rw.JSON(http.StatusOK, res)
+}
+
+// ResumePlatform godoc
+// @Summary      Resume platform
+// @Description  resumes publishing to the paused platform; overdue publications are published now, shifted by the pause duration or skipped
+// @Tags         platforms
+// @Accept       json
+// @Produce      json
+// @Param        id path int true "Platform ID"
+// @Param        request body dto.ResumeRequest true "what to do with overdue publications"
+// @Success      200  {object}  dto.ResumeResponce
+// @Failure      400  {object}  dto.ErrorResponse
+// @Failure      404  {object}  dto.ErrorResponse
+// @Failure      409  {object}  dto.ErrorResponse
+// @Failure      500  {object}  dto.ErrorResponse
+// @Router       /platforms/{id}/resume [post]
+func (a *App) ResumePlatform(rw *gin.Context) {
+	id, err := strconv.Atoi(rw.Param("id"))
+	if err != nil {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	workspaceID := rw.GetInt("currentWorkspaceID")
+	var request dto.ResumeRequest
+	if err := rw.ShouldBindJSON(&request); err != nil {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validate(&request); err != nil {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := a.Repo.GetPlatformByID(rw.Request.Context(), id, workspaceID); err != nil {
+		rw.JSON(http.StatusNotFound, gin.H{"error": "platform not found"})
+		return
+	}
+	res, err := a.Repo.ResumePlatforms(rw.Request.Context(), workspaceID, id, request.Overdue)
+	if err != nil {
+		rw.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if len(res.Platforms) == 0 {
+		rw.JSON(http.StatusConflict, gin.H{"error": "platform is not paused"})
+		return
+	}
+	rw.JSON(http.StatusOK, res)
+}
+
+// PauseAccount godoc
+// @Summary      Pause account
+// @Description  pauses all platforms of the current workspace
+// @Tags         account
+// @Produce      json
+// @Success      200  {object}  dto.PauseResponce
+// @Failure      500  {object}  dto.ErrorResponse
+// @Router       /account/pause [post]
+func (a *App) PauseAccount(rw *gin.Context) {
+	workspaceID := rw.GetInt("currentWorkspaceID")
+	res, err := a.Repo.PausePlatforms(rw.Request.Context(), workspaceID, 0)
+	if err != nil {
+		rw.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	rw.JSON(http.StatusOK, res)
+}
+
+// ResumeAccount godoc
+// @Summary      Resume account
+// @Description  resumes all paused platforms of the current workspace
+// @Tags         account
+// @Accept       json
+// @Produce      json
+// @Param        request body dto.ResumeRequest true "what to do with overdue publications"
+// @Success      200  {object}  dto.ResumeResponce
+// @Failure      400  {object}  dto.ErrorResponse
+// @Failure      500  {object}  dto.ErrorResponse
+// @Router       /account/resume [post]
+func (a *App) ResumeAccount(rw *gin.Context) {
+	workspaceID := rw.GetInt("currentWorkspaceID")
+	var request dto.ResumeRequest
+	if err := rw.ShouldBindJSON(&request); err != nil {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validate(&request); err != nil {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	res, err := a.Repo.ResumePlatforms(rw.Request.Context(), workspaceID, 0, request.Overdue)
+	if err != nil {
+		rw.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	rw.JSON(http.StatusOK, res)
+}
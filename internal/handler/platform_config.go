@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"fmt"
+	"hexlet/internal/domain"
+	"hexlet/internal/dto"
+	"hexlet/internal/secrets"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetPlatformSchemas godoc
+// @Summary      Get platform config schemas
+// @Description  JSON Schema documents of all platform configs
+// @Tags         platforms
+// @Produce      json
+// @Success      200  {object}  map[string]interface{}
+// @Router       /platforms/schemas [get]
+func (a *App) GetPlatformSchemas(rw *gin.Context) {
+	rw.JSON(http.StatusOK, domain.PlatformConfigSchemas)
+}
+
+// GetPlatformSchema godoc
+// @Summary      Get platform config schema
+// @Description  JSON Schema document of a platform config (Telegram, VK)
+// @Tags         platforms
+// @Produce      json
+// @Param        type path string true "Platform type"
+// @Success      200  {object}  map[string]interface{}
+// @Failure      404  {object}  dto.ErrorResponse
+// @Router       /platforms/schemas/{type} [get]
+func (a *App) GetPlatformSchema(rw *gin.Context) {
+	schema, ok := domain.PlatformConfigSchemas[rw.Param("type")]
+	if !ok {
+		rw.JSON(http.StatusNotFound, gin.H{"error": "unknown platform type"})
+		return
+	}
+	rw.Data(http.StatusOK, "application/schema+json", schema)
+}
+
+func maskPlatformConfig(cfg domain.PlatformConfig) domain.PlatformConfig {
+	res := cfg.Clone()
+	for _, field := range res.SecretFields() {
+		*field = secrets.Mask(*field)
+	}
+	return res
+}
+
+// mergePlatformConfig накладывает секцию из запроса на текущую конфигурацию платформы.
+// Пустой токен в запросе оставляет сохранённый.
+func mergePlatformConfig(platform domain.Platform, request dto.PutPlatformRequest) (domain.PlatformConfig, error) {
+	res := platform.Api_config.Clone()
+	res.Version = domain.PlatformConfigVersion
+	switch platform.Name {
+	case domain.PlatformTelegram:
+		if request.Telegram == nil || request.VK != nil {
+			return res, fmt.Errorf("telegram config is required")
+		}
+		tg := *request.Telegram
+		if tg.BotToken == "" && res.Telegram != nil {
+			tg.BotToken = res.Telegram.BotToken
+		}
+		if err := validate(&tg); err != nil {
+			return res, err
+		}
+		res.Telegram = &tg
+	case domain.PlatformVK:
+		if request.VK == nil || request.Telegram != nil {
+			return res, fmt.Errorf("vk config is required")
+		}
+		vk := *request.VK
+		if vk.AccessToken == "" && res.VK != nil {
+			vk.AccessToken = res.VK.AccessToken
+		}
+		if err := validate(&vk); err != nil {
+			return res, err
+		}
+		res.VK = &vk
+	default:
+		return res, fmt.Errorf("unsupported platform %q", platform.Name)
+	}
+	return res, nil
+}
@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"hexlet/internal/domain"
+	"hexlet/internal/repository"
+	"hexlet/internal/tracing"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// VerifyPlatform godoc
+// @Summary      Verify platform credentials
+// @Description  checks bot token / access token and rights in the target channel, stores the report
+// @Tags         platforms
+// @Produce      json
+// @Param        id path int true "Platform ID"
+// @Success      200  {object}  domain.VerificationReport
+// @Failure      400  {object}  dto.ErrorResponse
+// @Failure      404  {object}  dto.ErrorResponse
+// @Failure      409  {object}  dto.ErrorResponse
+// @Failure      500  {object}  dto.ErrorResponse
+// @Failure      501  {object}  dto.ErrorResponse
+// @Router       /platforms/{id}/verify [post]
+func (a *App) VerifyPlatform(rw *gin.Context) {
+	id, err := strconv.Atoi(rw.Param("id"))
+	if err != nil {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	workspaceID := rw.GetInt("currentWorkspaceID")
+	if a.Verifier == nil {
+		rw.JSON(http.StatusNotImplemented, gin.H{"error": "verification is not configured"})
+		return
+	}
+	platform, err := a.Repo.GetPlatformByID(rw.Request.Context(), id, workspaceID)
+	if err != nil {
+		rw.JSON(http.StatusNotFound, gin.H{"error": "platform not found"})
+		return
+	}
+	report := a.Verifier.Verify(rw.Request.Context(), platform.Name, platform.Api_config)
+	err = a.Repo.UpdatePlatformVerification(rw.Request.Context(), id, platform.Config_version, report)
+	if errors.Is(err, repository.ErrPlatformChanged) {
+		rw.JSON(http.StatusConflict, gin.H{"error": "platform credentials changed during verification, retry"})
+		return
+	}
+	if err != nil {
+		rw.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	rw.JSON(http.StatusOK, report)
+}
+
+// verifyPlatformAsync проверяет учётные данные после создания/обновления платформы в фоне:
+// проверка ходит в API Telegram и VK и не должна задерживать ответ. Отчёт сохраняется
+// в платформе и виден в GET /platforms/{id}. Проверки идут в любом порядке, поэтому отчёт
+// сохраняется, только если учётные данные всё ещё версии configVersion.
+func (a *App) verifyPlatformAsync(ctx context.Context, id int, configVersion int, platformName string, cfg domain.PlatformConfig) {
+	if a.Verifier == nil {
+		return
+	}
+	ctx = tracing.Detach(a.Ctx, ctx)
+	go func() {
+		report := a.Verifier.Verify(ctx, platformName, cfg)
+		err := a.Repo.UpdatePlatformVerification(ctx, id, configVersion, report)
+		if errors.Is(err, repository.ErrPlatformChanged) {
+			log.Printf("verification of platform %d is outdated, skipped", id)
+			return
+		}
+		if err != nil {
+			log.Printf("failed to store verification of platform %d: %v", id, err)
+		}
+	}()
+}
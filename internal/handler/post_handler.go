@@ -2,57 +2,170 @@ package handler
 
 import (
 	"context"
-	"fmt"
+	"errors"
 	_ "hexlet/docs"
 	"hexlet/internal/auth"
 	"hexlet/internal/domain"
 	"hexlet/internal/dto"
+	"hexlet/internal/health"
+	"hexlet/internal/mailer"
+	"hexlet/internal/metrics"
 	"hexlet/internal/repository"
+	"hexlet/internal/statusfeed"
+	"hexlet/internal/tracing"
+	"hexlet/internal/verification"
+	"hexlet/internal/webhook"
 	"log"
 	"net/http"
-	"os"
 	"strconv"
 	"strings"
-	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/markbates/goth/gothic"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 )
 
 type App struct {
-	Ctx  context.Context
-	Repo repository.PostRepository
+	Ctx       context.Context
+	Repo      repository.PostRepository
+	Verifier  verification.Verifier
+	Mailer    mailer.Mailer
+	PublicURL string
+	Tokens    *auth.TokenIssuer
+	// смены статусов для GET /events; nil — поток недоступен
+	Events *statusfeed.Hub
+	// отменяется перед остановкой HTTP-сервера и закрывает потоки GET /events; nil — Ctx
+	Streams context.Context
+	// имя бота управления для ссылки привязки; пусто — бот не запущен
+	TelegramBot string
+	// проверки GET /readyz; nil — сервис считается готовым
+	Readiness *health.Checker
+	// DNS для проверки адресов вебхуков; nil — системный
+	Resolver webhook.Resolver
 }
 
 func (a *App) Routes(r *gin.Engine) {
+	// до регистрации маршрутов: middleware действует только на маршруты, добавленные после
+	r.Use(tracing.Middleware(), metrics.Middleware())
 	authGroup := r.Group("/auth")
 	{
 		authGroup.GET("/:provider", a.beginAuthFunction)
 		authGroup.GET("/:provider/callback", a.getAuthCallbackFunction)
 		authGroup.POST("/refresh", a.refreshTokensHandler)
-	}
+		authGroup.POST("/logout", a.Logout)
+		authGroup.POST("/register", a.Register)
+		authGroup.POST("/login", a.Login)
+		authGroup.GET("/verify-email", a.VerifyEmail)
+		authGroup.POST("/verify-email/resend", a.ResendVerification)
+		authGroup.POST("/password/forgot", a.ForgotPassword)
+		authGroup.POST("/password/reset", a.ResetPassword)
+		authGroup.GET("/sessions", a.AuthMiddleware(), sessionOnly(), a.GetSessions)
+		authGroup.DELETE("/sessions/:id", a.AuthMiddleware(), sessionOnly(), a.DeleteSession)
+	}
+	r.GET("/.well-known/jwks.json", a.GetJWKS)
+	// календарные клиенты не передают заголовки, доступ по токену в ссылке
+	r.GET("/calendar.ics", a.GetCalendar)
+	// пробы оркестратора, без авторизации
+	r.GET("/healthz", a.Healthz)
+	r.GET("/readyz", a.Readyz)
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler)) //http://localhost:8080/swagger/index.html
 	api := r.Group("/")
 	api.Use(a.AuthMiddleware())
+	// данные текущего рабочего пространства
+	ws := api.Group("/", a.WorkspaceMiddleware())
 	{
 		// posts
-		api.POST("/posts", a.CreatePost)
-		api.GET("/posts", a.GetPosts)
-		api.GET("/posts/:id", a.GetPost)
-		api.PUT("/posts/:id", a.PutPost)
-		api.DELETE("/posts/:id", a.DeletePost)
+		ws.POST("/posts", requireScope(domain.ScopePostsWrite), requireRole(domain.RoleEditor), a.CreatePost)
+		ws.GET("/posts", requireScope(domain.ScopePostsRead), requireRole(domain.RoleViewer), a.GetPosts)
+		ws.GET("/posts/export", requireScope(domain.ScopePostsRead), requireRole(domain.RoleViewer), a.ExportPosts)
+		ws.POST("/posts/import", requireScope(domain.ScopePostsWrite), requireRole(domain.RoleEditor), a.ImportPosts)
+		ws.GET("/posts/import/:id", requireScope(domain.ScopePostsRead), requireRole(domain.RoleViewer), a.GetImportJob)
+		ws.GET("/posts/:id", requireScope(domain.ScopePostsRead), requireRole(domain.RoleViewer), a.GetPost)
+		ws.PUT("/posts/:id", requireScope(domain.ScopePostsWrite), requireRole(domain.RoleEditor), a.PutPost)
+		ws.DELETE("/posts/:id", requireScope(domain.ScopePostsWrite), requireRole(domain.RoleEditor), a.DeletePost)
+		ws.POST("/posts/:id/submit", requireScope(domain.ScopePostsWrite), requireRole(domain.RoleEditor), a.SubmitPost)
+		ws.POST("/posts/:id/approve", requireScope(domain.ScopePostsWrite), requireRole(domain.RoleAdmin), a.ApprovePost)
+		ws.POST("/posts/:id/reject", requireScope(domain.ScopePostsWrite), requireRole(domain.RoleAdmin), a.RejectPost)
+		ws.GET("/posts/:id/reviews", requireScope(domain.ScopePostsRead), requireRole(domain.RoleViewer), a.GetPostReviews)
+		ws.GET("/posts/:id/revisions", requireScope(domain.ScopePostsRead), requireRole(domain.RoleViewer), a.GetPostRevisions)
+		ws.GET("/posts/:id/revisions/diff", requireScope(domain.ScopePostsRead), requireRole(domain.RoleViewer), a.DiffPostRevisions)
+		ws.POST("/posts/:id/revisions/:rev/restore", requireScope(domain.ScopePostsWrite), requireRole(domain.RoleEditor), a.RestorePostRevision)
+
+		// templates
+		ws.POST("/templates", requireScope(domain.ScopePostsWrite), requireRole(domain.RoleEditor), a.CreateTemplate)
+		ws.GET("/templates", requireScope(domain.ScopePostsRead), requireRole(domain.RoleViewer), a.GetTemplates)
+		ws.GET("/templates/:id", requireScope(domain.ScopePostsRead), requireRole(domain.RoleViewer), a.GetTemplate)
+		ws.PUT("/templates/:id", requireScope(domain.ScopePostsWrite), requireRole(domain.RoleEditor), a.PutTemplate)
+		ws.DELETE("/templates/:id", requireScope(domain.ScopePostsWrite), requireRole(domain.RoleEditor), a.DeleteTemplate)
+		ws.POST("/templates/:id/posts", requireScope(domain.ScopePostsWrite), requireRole(domain.RoleEditor), a.CreatePostFromTemplate)
 
 		// platforms
-		api.POST("/platforms", a.CreatePlatform)
-		api.GET("/platforms", a.GetPlatforms)
-		api.GET("/platforms/:id", a.GetPlatform)
-		api.PUT("/platforms/:id", a.PutPlatform)
-		api.DELETE("/platforms/:id", a.DeletePlatform)
+		ws.POST("/platforms", requireScope(domain.ScopePlatformsWrite), requireRole(domain.RoleAdmin), a.CreatePlatform)
+		ws.GET("/platforms", requireScope(domain.ScopePlatformsRead), requireRole(domain.RoleViewer), a.GetPlatforms)
+		ws.GET("/platforms/:id", requireScope(domain.ScopePlatformsRead), requireRole(domain.RoleViewer), a.GetPlatform)
+		ws.PUT("/platforms/:id", requireScope(domain.ScopePlatformsWrite), requireRole(domain.RoleAdmin), a.PutPlatform)
+		ws.DELETE("/platforms/:id", requireScope(domain.ScopePlatformsWrite), requireRole(domain.RoleAdmin), a.DeletePlatform)
+		ws.POST("/platforms/:id/verify", requireScope(domain.ScopePlatformsWrite), requireRole(domain.RoleAdmin), a.VerifyPlatform)
+		ws.POST("/platforms/:id/pause", requireScope(domain.ScopePlatformsWrite), requireRole(domain.RoleAdmin), a.PausePlatform)
+		ws.POST("/platforms/:id/resume", requireScope(domain.ScopePlatformsWrite), requireRole(domain.RoleAdmin), a.ResumePlatform)
+		ws.PUT("/platforms/:id/approvals", requireScope(domain.ScopePlatformsWrite), requireRole(domain.RoleAdmin), a.UpdatePlatformApprovals)
+
+		// webhooks
+		ws.POST("/webhooks", requireScope(domain.ScopeWebhooksWrite), requireRole(domain.RoleAdmin), a.CreateWebhook)
+		ws.GET("/webhooks", requireScope(domain.ScopeWebhooksRead), requireRole(domain.RoleAdmin), a.GetWebhooks)
+		ws.PATCH("/webhooks/:id", requireScope(domain.ScopeWebhooksWrite), requireRole(domain.RoleAdmin), a.PatchWebhook)
+		ws.DELETE("/webhooks/:id", requireScope(domain.ScopeWebhooksWrite), requireRole(domain.RoleAdmin), a.DeleteWebhook)
+		ws.GET("/webhooks/:id/deliveries", requireScope(domain.ScopeWebhooksRead), requireRole(domain.RoleAdmin), a.GetWebhookDeliveries)
+		ws.POST("/webhooks/:id/deliveries/:delivery_id/redeliver", requireScope(domain.ScopeWebhooksWrite), requireRole(domain.RoleAdmin), a.RedeliverWebhook)
+
+		// account
+		ws.POST("/account/pause", requireScope(domain.ScopePlatformsWrite), requireRole(domain.RoleAdmin), a.PauseAccount)
+		ws.POST("/account/resume", requireScope(domain.ScopePlatformsWrite), requireRole(domain.RoleAdmin), a.ResumeAccount)
+	}
+	// notifications
+	api.GET("/notifications", requireScope(domain.ScopeNotificationsRead), a.GetNotifications)
+	api.GET("/events", requireScope(domain.ScopePostsRead), a.GetEvents)
+
+	// управление аккаунтом только из сессии пользователя
+	account := api.Group("/", sessionOnly())
+	{
+		// users
+		account.GET("/me", a.GetMe)
+		account.PATCH("/me", a.PatchMe)
+		account.GET("/me/identities", a.GetIdentities)
+		account.POST("/me/identities/:provider", a.LinkIdentity)
+		account.DELETE("/me/identities/:provider", a.UnlinkIdentity)
+		account.POST("/me/calendar", a.CreateCalendarToken)
+		account.DELETE("/me/calendar", a.DeleteCalendarToken)
+		account.POST("/me/telegram", a.LinkTelegram)
+		account.DELETE("/me/telegram", a.UnlinkTelegram)
+		account.GET("/me/notification-settings", a.GetNotificationSettings)
+		account.PUT("/me/notification-settings", a.PutNotificationSettings)
+
+		// api keys
+		account.POST("/api-keys", a.CreateApiKey)
+		account.GET("/api-keys", a.GetApiKeys)
+		account.DELETE("/api-keys/:id", a.DeleteApiKey)
+
+		// workspaces
+		account.POST("/workspaces", a.CreateWorkspace)
+		account.GET("/workspaces", a.GetWorkspaces)
+		account.POST("/workspaces/invitations/accept", a.AcceptInvitation)
 	}
+	workspace := account.Group("/workspaces/:id", a.PathWorkspaceMiddleware())
+	{
+		workspace.POST("/switch", a.SwitchWorkspace)
+		workspace.GET("/members", a.GetMembers)
+		workspace.PATCH("/members/:user_id", requireRole(domain.RoleAdmin), a.UpdateMember)
+		// выйти из пространства может любой участник, проверка роли внутри
+		workspace.DELETE("/members/:user_id", a.RemoveMember)
+		workspace.POST("/invitations", requireRole(domain.RoleAdmin), a.InviteMember)
+		workspace.PUT("/approvals", requireRole(domain.RoleAdmin), a.UpdateWorkspaceApprovals)
+	}
+	r.GET("/platforms/schemas", a.GetPlatformSchemas)
+	r.GET("/platforms/schemas/:type", a.GetPlatformSchema)
 	//not found
 	r.NoRoute(func(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{
@@ -78,22 +191,48 @@ func (a *App) AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 		tokenStr := strings.TrimPrefix(authHeader, "Bearer ")
-		claims := &auth.MyClaims{}
-		token, err := jwt.ParseWithClaims(tokenStr, claims, func(t *jwt.Token) (interface{}, error) {
-			return []byte(os.Getenv("JWT_ACCESS_SECRET")), nil
-		})
-		if err != nil || !token.Valid {
+		if auth.IsApiKey(tokenStr) {
+			key, err := a.Repo.UseApiKey(rw.Request.Context(), auth.HashToken(tokenStr))
+			if errors.Is(err, repository.ErrApiKeyInvalid) {
+				rw.AbortWithStatusJSON(401, gin.H{"error": "Invalid api key"})
+				return
+			}
+			if err != nil {
+				rw.AbortWithStatusJSON(500, gin.H{"error": "internal server error"})
+				return
+			}
+			rw.Set("currentUserID", key.ID_user)
+			rw.Set("currentApiKey", key)
+			rw.Next()
+			return
+		}
+		claims, err := a.Tokens.ParseAccessToken(tokenStr)
+		if err != nil {
 			rw.AbortWithStatusJSON(401, gin.H{"error": "Invalid token"})
 			return
 		}
+		// access токен входа живёт не дольше своей сессии (logout, DELETE /auth/sessions, сброс пароля)
+		if claims.SessionID != "" {
+			active, err := a.Repo.IsSessionActive(rw.Request.Context(), claims.SessionID, claims.UserID)
+			if err != nil {
+				rw.AbortWithStatusJSON(500, gin.H{"error": "internal server error"})
+				return
+			}
+			if !active {
+				rw.AbortWithStatusJSON(401, gin.H{"error": "Session revoked"})
+				return
+			}
+		}
 		rw.Set("currentUserID", claims.UserID)
+		rw.Set("currentSessionID", claims.SessionID)
+		rw.Set("currentWorkspaceClaim", claims.WorkspaceID)
 		rw.Next()
 	}
 }
 
 // CreatePost godoc
 // @Summary      Create a post
-// @Description  creating a post
+// @Description  creating a post; it is submitted for review unless draft is true
 // @Tags         posts
 // @Accept       json
 // @Produce      json
@@ -112,6 +251,7 @@ func (a *App) CreatePost(rw *gin.Context) {
 	userID := val.(string)
 	var request dto.CreatePostRequest
 	request.ID_user = userID
+	request.ID_workspace = rw.GetInt("currentWorkspaceID")
 	err := rw.ShouldBindJSON(&request)
 	if err != nil {
 		rw.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -121,15 +261,19 @@ func (a *App) CreatePost(rw *gin.Context) {
 		rw.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	request.Sheduled_for = request.Sheduled_for.Add(-3 * time.Hour)
-	request.Status = "scheduled"
+	request.Sheduled_for = repository.FromAPITime(request.Sheduled_for)
+	request.Status = domain.PostInReview
+	if request.Draft {
+		request.Status = domain.PostDraft
+	}
 	var responce dto.CreatePostResponce
-	responce.ID_post, responce.Created_at, err = a.Repo.CreatePost(a.Ctx, request)
+	responce.ID_post, responce.Created_at, err = a.Repo.CreatePost(rw.Request.Context(), request)
 	if err != nil {
 		rw.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 	responce.ID_user = request.ID_user
+	a.postEvent(rw, domain.EventPostCreated, responce.ID_post, request.ID_user, domain.PostEventData{Status: request.Status})
 	rw.JSON(http.StatusOK, responce)
 }
 
@@ -140,6 +284,11 @@ func (a *App) CreatePost(rw *gin.Context) {
 // @Accept       json
 // @Produce      json
 // @Param        request body dto.GetByUserIDRequest true "user info"
+// @Param        status query string false "destination status"
+// @Param        review_status query string false "review status of the post"
+// @Param        platform_id query int false "platform"
+// @Param        from query string false "scheduled at or after, RFC3339"
+// @Param        to query string false "scheduled before, RFC3339"
 // @Success      200  {object}  dto.GetPostsResponce
 // @Failure      400  {object}  dto.ErrorResponse
 // @Failure      404  {object}  dto.ErrorResponse
@@ -163,7 +312,16 @@ func (a *App) GetPosts(rw *gin.Context) {
 		rw.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	responce, err := a.Repo.GetPost(a.Ctx, request.ID_user)
+	var filter dto.PostFilter
+	if err := rw.ShouldBindQuery(&filter); err != nil {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validate(&filter); err != nil {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	responce, err := a.Repo.GetPost(rw.Request.Context(), rw.GetInt("currentWorkspaceID"), filter)
 	if err != nil {
 		rw.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -204,7 +362,7 @@ func (a *App) GetPost(rw *gin.Context) {
 		return
 	}
 	log.Print(id, request.ID_user)
-	post, err := a.Repo.GetPostByID(a.Ctx, id, request.ID_user)
+	post, err := a.Repo.GetPostByID(rw.Request.Context(), id, rw.GetInt("currentWorkspaceID"))
 	if err != nil {
 		rw.JSON(http.StatusNotFound, gin.H{"error": err})
 		return
@@ -214,14 +372,14 @@ func (a *App) GetPost(rw *gin.Context) {
 
 // PutPost godoc
 // @Summary      Update post
-// @Description  updating a post by ID
+// @Description  updating a post by ID; changing the text of a submitted post sends it for review again. In a post created from a template every variable of the new text must have a value.
 // @Tags         posts
 // @Accept       json
 // @Produce      json
 // @Param        id path int true "Post ID"
 // @Param        request body dto.PutPostRequest true "post update info"
 // @Success      200  {object}  dto.PutPostResponce
-// @Failure      400  {object}  dto.ErrorResponse
+// @Failure      400  {object}  dto.UnfilledTemplateResponse
 // @Failure      404  {object}  dto.ErrorResponse
 // @Failure      500  {object}  dto.ErrorResponse
 // @Router       /posts/{id} [put]
@@ -240,13 +398,14 @@ func (a *App) PutPost(rw *gin.Context) {
 	}
 	userID := val.(string)
 	request.ID_user = userID
+	request.ID_workspace = rw.GetInt("currentWorkspaceID")
 	err := rw.ShouldBindJSON(&request)
 	if err != nil {
 		rw.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 	var post dto.GetPostResponce
-	post, err = a.Repo.GetPostByID(a.Ctx, id, request.ID_user)
+	post, err = a.Repo.GetPostByID(rw.Request.Context(), id, request.ID_workspace)
 	if err != nil {
 		rw.JSON(http.StatusNotFound, gin.H{"error": "post not found"})
 		return
@@ -257,12 +416,38 @@ func (a *App) PutPost(rw *gin.Context) {
 	if request.Title == "" {
 		request.Title = post.Posts[0].Title
 	}
+	// в посте из шаблона новый текст проверяется так же, как при создании
+	vars, err := a.Repo.GetPostVariables(rw.Request.Context(), id, request.ID_workspace)
+	if err != nil {
+		rw.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	if vars != nil {
+		names := []string{}
+		for _, p := range post.Posts {
+			names = append(names, p.PlatformName)
+		}
+		if missing := unfilledVariables(*vars, names, request.Title, request.Content); len(missing) > 0 {
+			rw.JSON(http.StatusBadRequest, dto.UnfilledTemplateResponse{
+				Error:   "template variables are not filled",
+				Missing: missing,
+			})
+			return
+		}
+	}
 	if request.Sheduled_for.IsZero() {
 		request.Sheduled_for = post.Posts[0].Sheduled_for
 	}
+	// GetPostByID отдаёт время по Москве, как и клиент; в базе хранится UTC
+	request.Sheduled_for = repository.FromAPITime(request.Sheduled_for)
 	request.ID_post = id
 	var responce dto.PutPostResponce
-	responce, err = a.Repo.UpdatePostByID(a.Ctx, request)
+	responce, err = a.Repo.UpdatePostByID(rw.Request.Context(), request)
+	if err != nil {
+		reviewError(rw, err)
+		return
+	}
+	a.postEvent(rw, domain.EventPostUpdated, id, "", domain.PostEventData{})
 	rw.JSON(http.StatusOK, responce)
 }
 
@@ -303,22 +488,30 @@ func (a *App) DeletePost(rw *gin.Context) {
 		rw.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	_, err = a.Repo.GetPostByID(a.Ctx, id, request.ID_user)
+	workspaceID := rw.GetInt("currentWorkspaceID")
+	post, err := a.Repo.GetPostByID(rw.Request.Context(), id, workspaceID)
 	if err != nil {
 		rw.JSON(http.StatusNotFound, gin.H{"error": "post not found"})
 		return
 	}
-	err = a.Repo.DeletePostByID(a.Ctx, id)
+	err = a.Repo.DeletePostByID(rw.Request.Context(), id, workspaceID)
 	if err != nil {
 		rw.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
 		return
 	}
+	// после удаления автора уже не найти по посту
+	author := rw.GetString("currentUserID")
+	if len(post.Posts) > 0 && post.Posts[0].ID_user != "" {
+		author = post.Posts[0].ID_user
+	}
+	a.postEvent(rw, domain.EventPostDeleted, id, author, domain.PostEventData{})
 	rw.Status(204)
 }
 
 // CreatePlatform godoc
 // @Summary      Create platform
-// @Description  creating a platform for user
+// @Description  creating a platform for user, credentials are verified in the background
+// @Description  and the report is stored in the platform verification
 // @Tags         platforms
 // @Accept       json
 // @Produce      json
@@ -333,6 +526,7 @@ func (a *App) CreatePlatform(rw *gin.Context) {
 	val, _ := rw.Get("currentUserID")
 	userID := val.(string)
 	request.ID_user = userID
+	request.ID_workspace = rw.GetInt("currentWorkspaceID")
 	err := rw.ShouldBindJSON(&request)
 	if err != nil {
 		rw.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -343,11 +537,17 @@ func (a *App) CreatePlatform(rw *gin.Context) {
 		return
 	}
 	var responce dto.CreatePlatformResponce
-	responce.ID_platform, responce.Created_at, err = a.Repo.CreatePlatform(a.Ctx, request)
+	responce.ID_platform, responce.Created_at, err = a.Repo.CreatePlatform(rw.Request.Context(), request)
 	if err != nil {
 		rw.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	// новая платформа создаётся с config_version = 0
+	a.verifyPlatformAsync(rw.Request.Context(), responce.ID_platform, 0, request.PlatformName, domain.PlatformConfig{
+		Version:  domain.PlatformConfigVersion,
+		Telegram: request.Telegram,
+		VK:       request.VK,
+	})
 	responce.ID_user = request.ID_user
 	rw.JSON(http.StatusOK, responce)
 }
@@ -382,11 +582,14 @@ func (a *App) GetPlatforms(rw *gin.Context) {
 		rw.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	responce, err := a.Repo.GetPlatform(a.Ctx, request.ID_user)
+	responce, err := a.Repo.GetPlatform(rw.Request.Context(), rw.GetInt("currentWorkspaceID"))
 	if err != nil {
 		rw.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	for i := range responce.Platfroms {
+		responce.Platfroms[i].Api_config = maskPlatformConfig(responce.Platfroms[i].Api_config)
+	}
 	rw.JSON(http.StatusOK, responce)
 }
 
@@ -427,17 +630,19 @@ func (a *App) GetPlatform(rw *gin.Context) {
 		rw.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	post, err := a.Repo.GetPlatformByID(a.Ctx, id, request.ID_user)
+	post, err := a.Repo.GetPlatformByID(rw.Request.Context(), id, rw.GetInt("currentWorkspaceID"))
 	if err != nil {
 		rw.JSON(http.StatusNotFound, gin.H{"error": "platform not found"})
 		return
 	}
+	post.Api_config = maskPlatformConfig(post.Api_config)
 	rw.JSON(http.StatusOK, post)
 }
 
 // PutPlatform godoc
 // @Summary      Update platform
-// @Description  updating a platform by ID
+// @Description  updating a platform by ID, credentials are verified in the background
+// @Description  and the report is stored in the platform verification
 // @Tags         platforms
 // @Accept       json
 // @Produce      json
@@ -463,30 +668,31 @@ func (a *App) PutPlatform(rw *gin.Context) {
 	}
 	userID := val.(string)
 	request.ID_user = userID
+	request.ID_workspace = rw.GetInt("currentWorkspaceID")
 	err := rw.ShouldBindJSON(&request)
 	if err != nil {
 		rw.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 	var platform domain.Platform
-	platform, err = a.Repo.GetPlatformByID(a.Ctx, id, request.ID_user)
+	platform, err = a.Repo.GetPlatformByID(rw.Request.Context(), id, request.ID_workspace)
 	if err != nil {
 		rw.JSON(http.StatusNotFound, gin.H{"error": "platform not found"})
 		return
 	}
-	if request.Bot_name == "" {
-		for key := range platform.Api_config {
-			request.Bot_name = key
-		}
-	}
-	if request.Config == "" {
-		for _, value := range platform.Api_config {
-			request.Config = value
-		}
+	request.Config, err = mergePlatformConfig(platform, request)
+	if err != nil {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 	request.ID_platform = id
 	var responce dto.PutPlatformResponce
-	responce, err = a.Repo.UpdatePlatformByID(a.Ctx, request)
+	responce, err = a.Repo.UpdatePlatformByID(rw.Request.Context(), request)
+	if err != nil {
+		rw.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	a.verifyPlatformAsync(rw.Request.Context(), id, responce.Config_version, platform.Name, request.Config)
 	rw.JSON(http.StatusOK, responce)
 }
 
@@ -527,12 +733,13 @@ func (a *App) DeletePlatform(rw *gin.Context) {
 		rw.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	_, err = a.Repo.GetPlatformByID(a.Ctx, id, request.ID_user)
+	workspaceID := rw.GetInt("currentWorkspaceID")
+	_, err = a.Repo.GetPlatformByID(rw.Request.Context(), id, workspaceID)
 	if err != nil {
 		rw.JSON(http.StatusNotFound, gin.H{"error": "platform not found"})
 		return
 	}
-	err = a.Repo.DeletePlatformByID(a.Ctx, id)
+	err = a.Repo.DeletePlatformByID(rw.Request.Context(), id, workspaceID)
 	if err != nil {
 		rw.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
 		return
@@ -545,11 +752,36 @@ func (a *App) getAuthCallbackFunction(rw *gin.Context) {
 	req := rw.Request.WithContext(context.WithValue(rw.Request.Context(), "provider", provider))
 	user, err := gothic.CompleteUserAuth(rw.Writer, req)
 	if err != nil {
-		rw.AbortWithStatusJSON(401, gin.H{"error": "Google Auth failed"})
+		rw.AbortWithStatusJSON(401, gin.H{"error": provider + " auth failed"})
+		return
+	}
+	identity := domain.Identity{
+		Provider:       user.Provider,
+		ProviderUserID: user.UserID,
+		Email:          user.Email,
+		Name:           user.Name,
+		AvatarURL:      user.AvatarURL,
+	}
+	// state уже сверен с сессией gothic; токеном привязки он бывает, только если его выдал beginAuthFunction
+	linkUserID, err := a.Repo.UseAuthToken(rw.Request.Context(), domain.TokenLinkIdentity, auth.HashToken(gothic.GetState(req)))
+	if err == nil {
+		a.linkIdentity(rw, linkUserID, identity)
+		return
+	}
+	if !errors.Is(err, repository.ErrAuthTokenInvalid) {
+		rw.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	account, err := a.Repo.FindOrCreateUser(rw.Request.Context(), identity)
+	if err != nil {
+		rw.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	accessToken, err := a.startSession(rw, account.ID_user)
+	if err != nil {
+		rw.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
 		return
 	}
-	accessToken, refreshToken, _ := auth.GenerateTokens(user.UserID)
-	rw.SetCookie("refresh_token", refreshToken, 3600*24*30, "/auth/refresh", "", true, true)
 	rw.JSON(200, gin.H{
 		"access_token": accessToken,
 	})
@@ -558,39 +790,74 @@ func (a *App) getAuthCallbackFunction(rw *gin.Context) {
 func (a *App) beginAuthFunction(rw *gin.Context) {
 	provider := rw.Param("provider")
 	req := rw.Request.WithContext(context.WithValue(rw.Request.Context(), "provider", provider))
+	// state задаёт только сервер: state из запроса мог подставить кто угодно
+	query := req.URL.Query()
+	query.Del("state")
+	// вход для привязки провайдера к уже существующему аккаунту
+	if linkToken := query.Get("link_token"); linkToken != "" {
+		query.Del("link_token")
+		userID, err := a.Repo.UseAuthToken(rw.Request.Context(), domain.TokenLinkIdentity, auth.HashToken(linkToken))
+		if errors.Is(err, repository.ErrAuthTokenInvalid) {
+			rw.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "not valid link token"})
+			return
+		}
+		if err != nil {
+			rw.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return
+		}
+		// ссылка погашена, до callback аккаунт передаётся новым одноразовым токеном в state
+		state, err := a.newLinkToken(rw.Request.Context(), userID)
+		if err != nil {
+			rw.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return
+		}
+		query.Set("state", state)
+	}
+	u := *req.URL
+	u.RawQuery = query.Encode()
+	req.URL = &u
 	gothic.BeginAuthHandler(rw.Writer, req)
 }
 
 func (a *App) refreshTokensHandler(rw *gin.Context) {
-	cookie, err := rw.Cookie("refresh_token")
+	cookie, err := rw.Cookie(refreshCookie)
 	if err != nil {
 		rw.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "session expired"})
 		return
 	}
-	token, err := jwt.Parse(cookie, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-
-		}
-		return []byte(os.Getenv("JWT_REFRESH_SECRET")), nil
-	})
-	if err != nil || !token.Valid {
+	claims, err := a.Tokens.ParseRefreshToken(cookie)
+	if err != nil {
 		rw.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "not valid refresh token"})
 		return
 	}
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok {
+	tokens, err := a.Tokens.GenerateTokens(claims.Subject, claims.SessionID)
+	if err != nil {
 		rw.AbortWithStatus(http.StatusInternalServerError)
 		return
 	}
-	userID := claims["sub"].(string)
-	newAccessToken, newRefreshToken, err := auth.GenerateTokens(userID)
+	workspaceID, err := a.Repo.RotateRefreshToken(rw.Request.Context(), claims.SessionID, claims.Subject,
+		domain.RefreshToken{JTI: claims.ID, Hash: auth.HashToken(cookie)},
+		domain.RefreshToken{JTI: tokens.RefreshJTI, Hash: auth.HashToken(tokens.Refresh), Expires_at: tokens.RefreshExpiresAt},
+	)
+	if errors.Is(err, repository.ErrRefreshTokenInvalid) || errors.Is(err, repository.ErrRefreshTokenReused) {
+		clearRefreshCookie(rw)
+		rw.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		rw.AbortWithStatus(http.StatusInternalServerError)
 		return
 	}
-	rw.SetCookie("refresh_token", newRefreshToken, 3600*24*30, "/auth/refresh", "", true, true)
+	setRefreshCookie(rw, tokens)
+	// пространство, выбранное в сессии, переживает обновление токена
+	if workspaceID != 0 {
+		tokens.Access, err = a.Tokens.GenerateAccessToken(claims.Subject, claims.SessionID, workspaceID)
+		if err != nil {
+			rw.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+	}
 	rw.JSON(http.StatusOK, gin.H{
-		"access_token": newAccessToken,
+		"access_token": tokens.Access,
 	})
 }
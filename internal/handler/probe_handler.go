@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"hexlet/internal/health"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Healthz godoc
+// @Summary      Liveness probe
+// @Description  the process is running and serves HTTP; dependencies are not checked, so a failing database does not restart the service
+// @Tags         health
+// @Produce      json
+// @Success      200  {object}  health.Report
+// @Router       /healthz [get]
+func (a *App) Healthz(rw *gin.Context) {
+	rw.JSON(http.StatusOK, health.Report{Status: health.StatusUp, Checks: map[string]health.CheckResult{}})
+}
+
+// Readyz godoc
+// @Summary      Readiness probe
+// @Description  checks master and replica connectivity, replica lag, Kafka brokers and topic, and membership in the consumer group.
+// @Description  Every check is reported with its status, duration and details. During graceful shutdown the service is not ready.
+// @Tags         health
+// @Produce      json
+// @Success      200  {object}  health.Report
+// @Failure      503  {object}  health.Report
+// @Router       /readyz [get]
+func (a *App) Readyz(rw *gin.Context) {
+	if a.Readiness == nil {
+		rw.JSON(http.StatusOK, health.Report{Status: health.StatusUp, Checks: map[string]health.CheckResult{}})
+		return
+	}
+	report := a.Readiness.Ready(rw.Request.Context())
+	if !report.Ready() {
+		rw.JSON(http.StatusServiceUnavailable, report)
+		return
+	}
+	rw.JSON(http.StatusOK, report)
+}
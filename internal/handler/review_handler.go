@@ -0,0 +1,242 @@
+package handler
+
+import (
+	"errors"
+	"hexlet/internal/domain"
+	"hexlet/internal/dto"
+	"hexlet/internal/repository"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// reviewError переводит ошибки редакционного процесса в ответ
+func reviewError(rw *gin.Context, err error) {
+	switch {
+	case errors.Is(err, repository.ErrPostNotFound):
+		rw.JSON(http.StatusNotFound, gin.H{"error": "post not found"})
+	case errors.Is(err, repository.ErrInvalidTransition):
+		rw.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, repository.ErrSelfReview):
+		rw.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		rw.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+	}
+}
+
+// SubmitPost godoc
+// @Summary      Submit post for review
+// @Description  moves a draft to in_review; without required approvals the post is approved (and scheduled, if the time is set) at once
+// @Tags         posts
+// @Produce      json
+// @Param        id path int true "Post ID"
+// @Success      200  {object}  dto.PostStatusResponce
+// @Failure      400  {object}  dto.ErrorResponse
+// @Failure      404  {object}  dto.ErrorResponse
+// @Failure      409  {object}  dto.ErrorResponse
+// @Failure      500  {object}  dto.ErrorResponse
+// @Router       /posts/{id}/submit [post]
+func (a *App) SubmitPost(rw *gin.Context) {
+	id, err := strconv.Atoi(rw.Param("id"))
+	if err != nil {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	status, err := a.Repo.SubmitPost(rw.Request.Context(), id, rw.GetInt("currentWorkspaceID"), rw.GetString("currentUserID"))
+	if err != nil {
+		reviewError(rw, err)
+		return
+	}
+	rw.JSON(http.StatusOK, dto.PostStatusResponce{ID_post: id, Status: status})
+}
+
+// ApprovePost godoc
+// @Summary      Approve post
+// @Description  approves a post in review; once enough approvals are collected the post is approved or scheduled
+// @Tags         posts
+// @Accept       json
+// @Produce      json
+// @Param        id path int true "Post ID"
+// @Param        request body dto.ApprovePostRequest true "optional comment"
+// @Success      200  {object}  dto.PostStatusResponce
+// @Failure      400  {object}  dto.ErrorResponse
+// @Failure      403  {object}  dto.ErrorResponse
+// @Failure      404  {object}  dto.ErrorResponse
+// @Failure      409  {object}  dto.ErrorResponse
+// @Failure      500  {object}  dto.ErrorResponse
+// @Router       /posts/{id}/approve [post]
+func (a *App) ApprovePost(rw *gin.Context) {
+	id, err := strconv.Atoi(rw.Param("id"))
+	if err != nil {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	var request dto.ApprovePostRequest
+	if err := rw.ShouldBindJSON(&request); err != nil {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validate(&request); err != nil {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	a.reviewPost(rw, id, domain.ReviewApproved, request.Comment)
+}
+
+// RejectPost godoc
+// @Summary      Reject post
+// @Description  rejects a post in review with a comment, the post returns to drafts
+// @Tags         posts
+// @Accept       json
+// @Produce      json
+// @Param        id path int true "Post ID"
+// @Param        request body dto.RejectPostRequest true "reason"
+// @Success      200  {object}  dto.PostStatusResponce
+// @Failure      400  {object}  dto.ErrorResponse
+// @Failure      403  {object}  dto.ErrorResponse
+// @Failure      404  {object}  dto.ErrorResponse
+// @Failure      409  {object}  dto.ErrorResponse
+// @Failure      500  {object}  dto.ErrorResponse
+// @Router       /posts/{id}/reject [post]
+func (a *App) RejectPost(rw *gin.Context) {
+	id, err := strconv.Atoi(rw.Param("id"))
+	if err != nil {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	var request dto.RejectPostRequest
+	if err := rw.ShouldBindJSON(&request); err != nil {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validate(&request); err != nil {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	a.reviewPost(rw, id, domain.ReviewRejected, request.Comment)
+}
+
+func (a *App) reviewPost(rw *gin.Context, id int, action string, comment string) {
+	status, err := a.Repo.ReviewPost(rw.Request.Context(), rw.GetInt("currentWorkspaceID"), domain.PostReview{
+		ID_post: id,
+		ID_user: rw.GetString("currentUserID"),
+		Action:  action,
+		Comment: comment,
+	})
+	if err != nil {
+		reviewError(rw, err)
+		return
+	}
+	event := domain.EventPostApproved
+	if action == domain.ReviewRejected {
+		event = domain.EventPostRejected
+	}
+	a.postEvent(rw, event, id, "", domain.PostEventData{Status: status, Comment: comment})
+	rw.JSON(http.StatusOK, dto.PostStatusResponce{ID_post: id, Status: status})
+}
+
+// GetPostReviews godoc
+// @Summary      Post review history
+// @Description  submissions, approvals and rejections of the post with comments
+// @Tags         posts
+// @Produce      json
+// @Param        id path int true "Post ID"
+// @Success      200  {array}   domain.PostReview
+// @Failure      400  {object}  dto.ErrorResponse
+// @Failure      500  {object}  dto.ErrorResponse
+// @Router       /posts/{id}/reviews [get]
+func (a *App) GetPostReviews(rw *gin.Context) {
+	id, err := strconv.Atoi(rw.Param("id"))
+	if err != nil {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	reviews, err := a.Repo.GetPostReviews(rw.Request.Context(), id, rw.GetInt("currentWorkspaceID"))
+	if err != nil {
+		rw.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	rw.JSON(http.StatusOK, reviews)
+}
+
+// UpdateWorkspaceApprovals godoc
+// @Summary      Set required approvals of workspace
+// @Description  number of approvals every post of the workspace needs before it is scheduled; at most the number of owners and admins minus one, so that a post of any reviewer can be approved
+// @Tags         workspaces
+// @Accept       json
+// @Param        id path int true "Workspace ID"
+// @Param        request body dto.ApprovalRuleRequest true "required approvals"
+// @Success      204  "No Content"
+// @Failure      400  {object}  dto.ErrorResponse
+// @Failure      403  {object}  dto.ErrorResponse
+// @Failure      500  {object}  dto.ErrorResponse
+// @Router       /workspaces/{id}/approvals [put]
+func (a *App) UpdateWorkspaceApprovals(rw *gin.Context) {
+	var request dto.ApprovalRuleRequest
+	if err := rw.ShouldBindJSON(&request); err != nil {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validate(&request); err != nil {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if request.Required_approvals == nil {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": "required_approvals is required"})
+		return
+	}
+	err := a.Repo.UpdateWorkspaceApprovals(rw.Request.Context(), rw.GetInt("currentWorkspaceID"), *request.Required_approvals)
+	if errors.Is(err, repository.ErrTooFewReviewers) {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		rw.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	rw.Status(http.StatusNoContent)
+}
+
+// UpdatePlatformApprovals godoc
+// @Summary      Set required approvals of platform
+// @Description  raises the number of approvals for posts to this platform, at most the number of owners and admins minus one; null falls back to the workspace setting
+// @Tags         platforms
+// @Accept       json
+// @Param        id path int true "Platform ID"
+// @Param        request body dto.ApprovalRuleRequest true "required approvals"
+// @Success      204  "No Content"
+// @Failure      400  {object}  dto.ErrorResponse
+// @Failure      404  {object}  dto.ErrorResponse
+// @Failure      500  {object}  dto.ErrorResponse
+// @Router       /platforms/{id}/approvals [put]
+func (a *App) UpdatePlatformApprovals(rw *gin.Context) {
+	id, err := strconv.Atoi(rw.Param("id"))
+	if err != nil {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	var request dto.ApprovalRuleRequest
+	if err := rw.ShouldBindJSON(&request); err != nil {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validate(&request); err != nil {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	updated, err := a.Repo.UpdatePlatformApprovals(rw.Request.Context(), id, rw.GetInt("currentWorkspaceID"), request.Required_approvals)
+	if errors.Is(err, repository.ErrTooFewReviewers) {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		rw.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !updated {
+		rw.JSON(http.StatusNotFound, gin.H{"error": "platform not found"})
+		return
+	}
+	rw.Status(http.StatusNoContent)
+}
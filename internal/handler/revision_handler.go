@@ -0,0 +1,143 @@
+package handler
+
+import (
+	"errors"
+	"hexlet/internal/domain"
+	"hexlet/internal/dto"
+	"hexlet/internal/repository"
+	"hexlet/internal/textdiff"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetPostRevisions godoc
+// @Summary      Post revisions
+// @Description  every change of the post, newest first, with author and changed fields
+// @Tags         posts
+// @Produce      json
+// @Param        id path int true "Post ID"
+// @Success      200  {array}   domain.PostRevision
+// @Failure      400  {object}  dto.ErrorResponse
+// @Failure      500  {object}  dto.ErrorResponse
+// @Router       /posts/{id}/revisions [get]
+func (a *App) GetPostRevisions(rw *gin.Context) {
+	id, err := strconv.Atoi(rw.Param("id"))
+	if err != nil {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	revisions, err := a.Repo.GetPostRevisions(rw.Request.Context(), id, rw.GetInt("currentWorkspaceID"))
+	if err != nil {
+		rw.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	rw.JSON(http.StatusOK, revisions)
+}
+
+// DiffPostRevisions godoc
+// @Summary      Compare revisions
+// @Description  line by line diff of title and content between two revisions
+// @Tags         posts
+// @Produce      json
+// @Param        id path int true "Post ID"
+// @Param        from query int true "older revision"
+// @Param        to query int true "newer revision"
+// @Success      200  {object}  dto.RevisionDiffResponce
+// @Failure      400  {object}  dto.ErrorResponse
+// @Failure      404  {object}  dto.ErrorResponse
+// @Failure      500  {object}  dto.ErrorResponse
+// @Router       /posts/{id}/revisions/diff [get]
+func (a *App) DiffPostRevisions(rw *gin.Context) {
+	id, err := strconv.Atoi(rw.Param("id"))
+	if err != nil {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	from, err1 := strconv.Atoi(rw.Query("from"))
+	to, err2 := strconv.Atoi(rw.Query("to"))
+	if err1 != nil || err2 != nil {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": "from and to revisions are required"})
+		return
+	}
+	workspaceID := rw.GetInt("currentWorkspaceID")
+	older, err := a.Repo.GetPostRevision(rw.Request.Context(), id, workspaceID, from)
+	if err != nil {
+		revisionError(rw, err)
+		return
+	}
+	newer, err := a.Repo.GetPostRevision(rw.Request.Context(), id, workspaceID, to)
+	if err != nil {
+		revisionError(rw, err)
+		return
+	}
+	res := dto.RevisionDiffResponce{
+		ID_post:           id,
+		From:              from,
+		To:                to,
+		Changed_fields:    []string{},
+		Title:             textdiff.Diff(older.Title, newer.Title),
+		Content:           textdiff.Diff(older.Content, newer.Content),
+		Sheduled_for_from: older.Sheduled_for,
+		Sheduled_for_to:   newer.Sheduled_for,
+	}
+	if textdiff.Changed(res.Title) {
+		res.Changed_fields = append(res.Changed_fields, domain.FieldTitle)
+	}
+	if textdiff.Changed(res.Content) {
+		res.Changed_fields = append(res.Changed_fields, domain.FieldContent)
+	}
+	if !sameTime(older.Sheduled_for, newer.Sheduled_for) {
+		res.Changed_fields = append(res.Changed_fields, domain.FieldSheduledFor)
+	}
+	rw.JSON(http.StatusOK, res)
+}
+
+// RestorePostRevision godoc
+// @Summary      Restore revision
+// @Description  brings back title and content of the revision as a new revision; a submitted post goes to review again
+// @Tags         posts
+// @Produce      json
+// @Param        id path int true "Post ID"
+// @Param        rev path int true "Revision"
+// @Success      200  {object}  dto.RestoreRevisionResponce
+// @Failure      400  {object}  dto.ErrorResponse
+// @Failure      404  {object}  dto.ErrorResponse
+// @Failure      500  {object}  dto.ErrorResponse
+// @Router       /posts/{id}/revisions/{rev}/restore [post]
+func (a *App) RestorePostRevision(rw *gin.Context) {
+	id, err := strconv.Atoi(rw.Param("id"))
+	if err != nil {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	rev, err := strconv.Atoi(rw.Param("rev"))
+	if err != nil {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": "invalid revision"})
+		return
+	}
+	current, err := a.Repo.RestorePostRevision(rw.Request.Context(), id, rw.GetInt("currentWorkspaceID"), rev, rw.GetString("currentUserID"))
+	if err != nil {
+		revisionError(rw, err)
+		return
+	}
+	a.postEvent(rw, domain.EventPostUpdated, id, "", domain.PostEventData{})
+	rw.JSON(http.StatusOK, dto.RestoreRevisionResponce{ID_post: id, Revision: current})
+}
+
+func revisionError(rw *gin.Context, err error) {
+	if errors.Is(err, repository.ErrRevisionNotFound) {
+		rw.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	reviewError(rw, err)
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
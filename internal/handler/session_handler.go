@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"hexlet/internal/auth"
+	"hexlet/internal/domain"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// refresh токен доступен только эндпоинтам /auth (refresh, logout).
+// Раньше cookie ставилась на /auth/refresh: браузер отправляет её туда первой,
+// поэтому старая cookie стирается при каждой выдаче и очистке новой.
+const (
+	refreshCookie           = "refresh_token"
+	refreshCookiePath       = "/auth"
+	legacyRefreshCookiePath = "/auth/refresh"
+)
+
+func setRefreshCookie(rw *gin.Context, tokens auth.Tokens) {
+	rw.SetCookie(refreshCookie, "", -1, legacyRefreshCookiePath, "", true, true)
+	rw.SetCookie(refreshCookie, tokens.Refresh, int(auth.RefreshTTL.Seconds()), refreshCookiePath, "", true, true)
+}
+
+func clearRefreshCookie(rw *gin.Context) {
+	rw.SetCookie(refreshCookie, "", -1, legacyRefreshCookiePath, "", true, true)
+	rw.SetCookie(refreshCookie, "", -1, refreshCookiePath, "", true, true)
+}
+
+// startSession создаёт сессию для нового входа, ставит refresh cookie и возвращает access токен
+func (a *App) startSession(rw *gin.Context, userID string) (string, error) {
+	session := domain.Session{
+		ID_session: uuid.NewString(),
+		ID_user:    userID,
+		User_agent: rw.Request.UserAgent(),
+		Ip:         rw.ClientIP(),
+	}
+	tokens, err := a.Tokens.GenerateTokens(userID, session.ID_session)
+	if err != nil {
+		return "", err
+	}
+	err = a.Repo.CreateSession(rw.Request.Context(), session, domain.RefreshToken{
+		JTI:        tokens.RefreshJTI,
+		Hash:       auth.HashToken(tokens.Refresh),
+		Expires_at: tokens.RefreshExpiresAt,
+	})
+	if err != nil {
+		return "", err
+	}
+	setRefreshCookie(rw, tokens)
+	return tokens.Access, nil
+}
+
+// Logout godoc
+// @Summary      Logout
+// @Description  revokes the session of the refresh token cookie (its access tokens stop working) and clears the cookie
+// @Tags         auth
+// @Success      204  "No Content"
+// @Failure      500  {object}  dto.ErrorResponse
+// @Router       /auth/logout [post]
+func (a *App) Logout(rw *gin.Context) {
+	cookie, err := rw.Cookie(refreshCookie)
+	if err == nil {
+		if claims, err := a.Tokens.ParseRefreshToken(cookie); err == nil {
+			if _, err := a.Repo.RevokeSession(rw.Request.Context(), claims.SessionID, claims.Subject); err != nil {
+				rw.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+				return
+			}
+		}
+	}
+	clearRefreshCookie(rw)
+	rw.Status(http.StatusNoContent)
+}
+
+// GetSessions godoc
+// @Summary      Get sessions
+// @Description  getting active sessions (devices) of the user
+// @Tags         auth
+// @Produce      json
+// @Success      200  {array}   domain.Session
+// @Failure      500  {object}  dto.ErrorResponse
+// @Router       /auth/sessions [get]
+func (a *App) GetSessions(rw *gin.Context) {
+	val, exists := rw.Get("currentUserID")
+	if !exists {
+		rw.JSON(500, gin.H{"error": "User not found"})
+		return
+	}
+	sessions, err := a.Repo.GetSessions(rw.Request.Context(), val.(string))
+	if err != nil {
+		rw.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	current := rw.GetString("currentSessionID")
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID_session == current
+	}
+	rw.JSON(http.StatusOK, sessions)
+}
+
+// DeleteSession godoc
+// @Summary      Revoke session
+// @Description  revokes the session, its refresh and access tokens stop working
+// @Tags         auth
+// @Param        id path string true "Session ID"
+// @Success      204  "No Content"
+// @Failure      404  {object}  dto.ErrorResponse
+// @Failure      500  {object}  dto.ErrorResponse
+// @Router       /auth/sessions/{id} [delete]
+func (a *App) DeleteSession(rw *gin.Context) {
+	val, exists := rw.Get("currentUserID")
+	if !exists {
+		rw.JSON(500, gin.H{"error": "User not found"})
+		return
+	}
+	revoked, err := a.Repo.RevokeSession(rw.Request.Context(), rw.Param("id"), val.(string))
+	if err != nil {
+		rw.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !revoked {
+		rw.JSON(http.StatusNotFound, gin.H{"error": "session not found"})
+		return
+	}
+	rw.Status(http.StatusNoContent)
+}
+
+// GetJWKS godoc
+// @Summary      JSON Web Key Set
+// @Description  public keys for verifying access tokens, selected by the kid header
+// @Tags         auth
+// @Produce      json
+// @Success      200  {object}  auth.JWKS
+// @Router       /.well-known/jwks.json [get]
+func (a *App) GetJWKS(rw *gin.Context) {
+	// ключи меняются редко, но после ротации клиенты должны подхватить новый kid
+	rw.Header("Cache-Control", "public, max-age=300")
+	rw.JSON(http.StatusOK, a.Tokens.JWKS())
+}
@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"hexlet/internal/auth"
+	"hexlet/internal/domain"
+	"hexlet/internal/dto"
+	"hexlet/internal/repository"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const linkTelegramTTL = 10 * time.Minute
+
+// LinkTelegram godoc
+// @Summary      Link Telegram chat
+// @Description  creates a one-time code for the management bot; open the link or send /start <code> to the bot. Linking a new chat replaces the previous one.
+// @Tags         telegram
+// @Produce      json
+// @Success      201  {object}  dto.TelegramLinkResponce
+// @Failure      500  {object}  dto.ErrorResponse
+// @Failure      503  {object}  dto.ErrorResponse
+// @Router       /me/telegram [post]
+func (a *App) LinkTelegram(rw *gin.Context) {
+	if a.TelegramBot == "" {
+		rw.JSON(http.StatusServiceUnavailable, gin.H{"error": "telegram bot is not configured"})
+		return
+	}
+	code, err := auth.NewOpaqueToken()
+	if err != nil {
+		rw.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	expiresAt := time.Now().Add(linkTelegramTTL)
+	if err := a.Repo.CreateAuthToken(rw.Request.Context(), rw.GetString("currentUserID"), domain.TokenLinkTelegram, auth.HashToken(code), expiresAt); err != nil {
+		rw.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	rw.JSON(http.StatusCreated, dto.TelegramLinkResponce{
+		Code:       code,
+		Url:        "https://t.me/" + a.TelegramBot + "?start=" + code,
+		Expires_at: expiresAt,
+	})
+}
+
+// UnlinkTelegram godoc
+// @Summary      Unlink Telegram chat
+// @Tags         telegram
+// @Success      204  "No Content"
+// @Failure      404  {object}  dto.ErrorResponse
+// @Failure      500  {object}  dto.ErrorResponse
+// @Router       /me/telegram [delete]
+func (a *App) UnlinkTelegram(rw *gin.Context) {
+	deleted, err := a.Repo.UnlinkTelegram(rw.Request.Context(), rw.GetString("currentUserID"))
+	if err != nil {
+		rw.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !deleted {
+		rw.JSON(http.StatusNotFound, gin.H{"error": repository.ErrTelegramNotLinked.Error()})
+		return
+	}
+	rw.Status(http.StatusNoContent)
+}
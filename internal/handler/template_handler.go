@@ -0,0 +1,280 @@
+package handler
+
+import (
+	"errors"
+	"hexlet/internal/domain"
+	"hexlet/internal/dto"
+	"hexlet/internal/posttemplate"
+	"hexlet/internal/repository"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+func templateError(rw *gin.Context, err error) {
+	switch {
+	case errors.Is(err, repository.ErrTemplateNotFound):
+		rw.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, repository.ErrTemplateNameTaken):
+		rw.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		rw.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+	}
+}
+
+// CreateTemplate godoc
+// @Summary      Create template
+// @Description  creates a post template; title and content may contain {{variables}}, date, time and platform are filled at publish time
+// @Tags         templates
+// @Accept       json
+// @Produce      json
+// @Param        request body dto.CreateTemplateRequest true "template"
+// @Success      201  {object}  domain.PostTemplate
+// @Failure      400  {object}  dto.ErrorResponse
+// @Failure      409  {object}  dto.ErrorResponse
+// @Failure      500  {object}  dto.ErrorResponse
+// @Router       /templates [post]
+func (a *App) CreateTemplate(rw *gin.Context) {
+	var request dto.CreateTemplateRequest
+	if err := rw.ShouldBindJSON(&request); err != nil {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validate(&request); err != nil {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	request.ID_user = rw.GetString("currentUserID")
+	request.ID_workspace = rw.GetInt("currentWorkspaceID")
+	template, err := a.Repo.CreateTemplate(rw.Request.Context(), request)
+	if err != nil {
+		templateError(rw, err)
+		return
+	}
+	rw.JSON(http.StatusCreated, template)
+}
+
+// GetTemplates godoc
+// @Summary      List templates
+// @Description  templates of the current workspace
+// @Tags         templates
+// @Produce      json
+// @Success      200  {array}   domain.PostTemplate
+// @Failure      500  {object}  dto.ErrorResponse
+// @Router       /templates [get]
+func (a *App) GetTemplates(rw *gin.Context) {
+	templates, err := a.Repo.GetTemplates(rw.Request.Context(), rw.GetInt("currentWorkspaceID"))
+	if err != nil {
+		rw.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	rw.JSON(http.StatusOK, templates)
+}
+
+// GetTemplate godoc
+// @Summary      Get template
+// @Tags         templates
+// @Produce      json
+// @Param        id path int true "Template ID"
+// @Success      200  {object}  domain.PostTemplate
+// @Failure      400  {object}  dto.ErrorResponse
+// @Failure      404  {object}  dto.ErrorResponse
+// @Failure      500  {object}  dto.ErrorResponse
+// @Router       /templates/{id} [get]
+func (a *App) GetTemplate(rw *gin.Context) {
+	id, err := strconv.Atoi(rw.Param("id"))
+	if err != nil {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	template, err := a.Repo.GetTemplateByID(rw.Request.Context(), id, rw.GetInt("currentWorkspaceID"))
+	if err != nil {
+		templateError(rw, err)
+		return
+	}
+	rw.JSON(http.StatusOK, template)
+}
+
+// PutTemplate godoc
+// @Summary      Update template
+// @Description  replaces name, title and content; posts created earlier are not changed
+// @Tags         templates
+// @Accept       json
+// @Produce      json
+// @Param        id path int true "Template ID"
+// @Param        request body dto.PutTemplateRequest true "template"
+// @Success      200  {object}  domain.PostTemplate
+// @Failure      400  {object}  dto.ErrorResponse
+// @Failure      404  {object}  dto.ErrorResponse
+// @Failure      409  {object}  dto.ErrorResponse
+// @Failure      500  {object}  dto.ErrorResponse
+// @Router       /templates/{id} [put]
+func (a *App) PutTemplate(rw *gin.Context) {
+	id, err := strconv.Atoi(rw.Param("id"))
+	if err != nil {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	var request dto.PutTemplateRequest
+	if err := rw.ShouldBindJSON(&request); err != nil {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validate(&request); err != nil {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	request.ID_template = id
+	request.ID_workspace = rw.GetInt("currentWorkspaceID")
+	template, err := a.Repo.UpdateTemplate(rw.Request.Context(), request)
+	if err != nil {
+		templateError(rw, err)
+		return
+	}
+	rw.JSON(http.StatusOK, template)
+}
+
+// DeleteTemplate godoc
+// @Summary      Delete template
+// @Description  deletes the template, posts created from it are kept
+// @Tags         templates
+// @Param        id path int true "Template ID"
+// @Success      204  "No Content"
+// @Failure      400  {object}  dto.ErrorResponse
+// @Failure      404  {object}  dto.ErrorResponse
+// @Failure      500  {object}  dto.ErrorResponse
+// @Router       /templates/{id} [delete]
+func (a *App) DeleteTemplate(rw *gin.Context) {
+	id, err := strconv.Atoi(rw.Param("id"))
+	if err != nil {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	deleted, err := a.Repo.DeleteTemplate(rw.Request.Context(), id, rw.GetInt("currentWorkspaceID"))
+	if err != nil {
+		rw.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !deleted {
+		rw.JSON(http.StatusNotFound, gin.H{"error": repository.ErrTemplateNotFound.Error()})
+		return
+	}
+	rw.Status(http.StatusNoContent)
+}
+
+// CreatePostFromTemplate godoc
+// @Summary      Create post from template
+// @Description  fills template variables and creates a post; every variable must be resolvable for every platform of the workspace. Platform specific values and values containing {{…}} are substituted at publish time, as plain text.
+// @Tags         templates
+// @Accept       json
+// @Produce      json
+// @Param        id path int true "Template ID"
+// @Param        request body dto.CreatePostFromTemplateRequest true "variable values"
+// @Success      200  {object}  dto.CreatePostResponce
+// @Failure      400  {object}  dto.UnfilledTemplateResponse
+// @Failure      404  {object}  dto.ErrorResponse
+// @Failure      500  {object}  dto.ErrorResponse
+// @Router       /templates/{id}/posts [post]
+func (a *App) CreatePostFromTemplate(rw *gin.Context) {
+	id, err := strconv.Atoi(rw.Param("id"))
+	if err != nil {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	var request dto.CreatePostFromTemplateRequest
+	if err := rw.ShouldBindJSON(&request); err != nil {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	workspaceID := rw.GetInt("currentWorkspaceID")
+	template, err := a.Repo.GetTemplateByID(rw.Request.Context(), id, workspaceID)
+	if err != nil {
+		templateError(rw, err)
+		return
+	}
+	platforms, err := a.Repo.GetPlatform(rw.Request.Context(), workspaceID)
+	if err != nil {
+		rw.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	vars := domain.PostVariables{Values: request.Values, Platforms: request.Platform_values}
+	names := []string{}
+	for _, p := range platforms.Platfroms {
+		names = append(names, p.Name)
+	}
+	if missing := unfilledVariables(vars, names, template.Title, template.Content); len(missing) > 0 {
+		rw.JSON(http.StatusBadRequest, dto.UnfilledTemplateResponse{
+			Error:   "template variables are not filled",
+			Missing: missing,
+		})
+		return
+	}
+	// общие значения подставляются сразу, значения платформ и встроенные — при публикации.
+	// Значение с {{…}} тоже ждёт публикации: там все переменные заполняются за один проход,
+	// и текст значения не раскрывается как переменная.
+	shared := map[string]string{}
+	for k, v := range request.Values {
+		if !overridden(k, request.Platform_values) && len(posttemplate.Placeholders(v)) == 0 {
+			shared[k] = v
+		}
+	}
+	post := dto.CreatePostRequest{
+		ID_user:      rw.GetString("currentUserID"),
+		ID_workspace: workspaceID,
+		Title:        posttemplate.Fill(template.Title, shared),
+		Content:      posttemplate.Fill(template.Content, shared),
+		Sheduled_for: request.Sheduled_for,
+		Draft:        request.Draft,
+		ID_template:  template.ID_template,
+		Variables:    &vars,
+	}
+	if err := validate(&post); err != nil {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	post.Sheduled_for = repository.FromAPITime(post.Sheduled_for)
+	post.Status = domain.PostInReview
+	if post.Draft {
+		post.Status = domain.PostDraft
+	}
+	var responce dto.CreatePostResponce
+	responce.ID_post, responce.Created_at, err = a.Repo.CreatePost(rw.Request.Context(), post)
+	if err != nil {
+		rw.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	responce.ID_user = post.ID_user
+	a.postEvent(rw, domain.EventPostCreated, responce.ID_post, post.ID_user, domain.PostEventData{Status: post.Status})
+	rw.JSON(http.StatusOK, responce)
+}
+
+// unfilledVariables проверяет, что для каждой платформы platforms заполнены все переменные texts.
+// Без платформ проверяются только общие значения (ключ default).
+func unfilledVariables(vars domain.PostVariables, platforms []string, texts ...string) map[string][]string {
+	names := platforms
+	if len(names) == 0 {
+		names = []string{""}
+	}
+	res := map[string][]string{}
+	for _, name := range names {
+		missing := posttemplate.Missing(vars.For(name), texts...)
+		if len(missing) == 0 {
+			continue
+		}
+		if name == "" {
+			name = "default"
+		}
+		res[name] = missing
+	}
+	return res
+}
+
+func overridden(name string, platforms map[string]map[string]string) bool {
+	for _, values := range platforms {
+		if _, ok := values[name]; ok {
+			return true
+		}
+	}
+	return false
+}
@@ -1,22 +1,36 @@
 package handler
 
 import (
+	"bufio"
 	"bytes"
 	"context"
+	"crypto/ed25519"
 	"encoding/json"
 	"errors"
 	"net/http"
 	"net/http/httptest"
-	"os"
+	"net/netip"
+	"net/url"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"hexlet/internal/auth"
 	"hexlet/internal/domain"
 	"hexlet/internal/dto"
+	"hexlet/internal/health"
+	"hexlet/internal/mailer"
+	"hexlet/internal/repository"
+	"hexlet/internal/statusfeed"
+	"hexlet/internal/textdiff"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/sessions"
+	"github.com/jackc/pgx/v4"
+	"github.com/markbates/goth"
+	"github.com/markbates/goth/gothic"
+	"github.com/markbates/goth/providers/faux"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -31,18 +45,18 @@ func (m *MockPostRepository) CreatePost(ctx context.Context, post dto.CreatePost
 	return args.Int(0), args.Get(1).(time.Time), args.Error(2)
 }
 
-func (m *MockPostRepository) GetPost(ctx context.Context, ID_user string) (dto.GetPostsResponce, error) {
-	args := m.Called(ctx, ID_user)
+func (m *MockPostRepository) GetPost(ctx context.Context, ID_workspace int, filter dto.PostFilter) (dto.GetPostsResponce, error) {
+	args := m.Called(ctx, ID_workspace, filter)
 	return args.Get(0).(dto.GetPostsResponce), args.Error(1)
 }
 
-func (m *MockPostRepository) GetPostByID(ctx context.Context, ID_post int, ID_user string) (dto.GetPostResponce, error) {
-	args := m.Called(ctx, ID_post, ID_user)
+func (m *MockPostRepository) GetPostByID(ctx context.Context, ID_post int, ID_workspace int) (dto.GetPostResponce, error) {
+	args := m.Called(ctx, ID_post, ID_workspace)
 	return args.Get(0).(dto.GetPostResponce), args.Error(1)
 }
 
-func (m *MockPostRepository) DeletePostByID(ctx context.Context, ID_post int) error {
-	args := m.Called(ctx, ID_post)
+func (m *MockPostRepository) DeletePostByID(ctx context.Context, ID_post int, ID_workspace int) error {
+	args := m.Called(ctx, ID_post, ID_workspace)
 	return args.Error(0)
 }
 
@@ -56,18 +70,18 @@ func (m *MockPostRepository) CreatePlatform(ctx context.Context, platform dto.Cr
 	return args.Int(0), args.Get(1).(time.Time), args.Error(2)
 }
 
-func (m *MockPostRepository) GetPlatform(ctx context.Context, ID_user string) (dto.GetPlatformResponce, error) {
-	args := m.Called(ctx, ID_user)
+func (m *MockPostRepository) GetPlatform(ctx context.Context, ID_workspace int) (dto.GetPlatformResponce, error) {
+	args := m.Called(ctx, ID_workspace)
 	return args.Get(0).(dto.GetPlatformResponce), args.Error(1)
 }
 
-func (m *MockPostRepository) GetPlatformByID(ctx context.Context, ID_platform int, ID_user string) (domain.Platform, error) {
-	args := m.Called(ctx, ID_platform, ID_user)
+func (m *MockPostRepository) GetPlatformByID(ctx context.Context, ID_platform int, ID_workspace int) (domain.Platform, error) {
+	args := m.Called(ctx, ID_platform, ID_workspace)
 	return args.Get(0).(domain.Platform), args.Error(1)
 }
 
-func (m *MockPostRepository) DeletePlatformByID(ctx context.Context, ID_platform int) error {
-	args := m.Called(ctx, ID_platform)
+func (m *MockPostRepository) DeletePlatformByID(ctx context.Context, ID_platform int, ID_workspace int) error {
+	args := m.Called(ctx, ID_platform, ID_workspace)
 	return args.Error(0)
 }
 
@@ -76,27 +90,421 @@ func (m *MockPostRepository) UpdatePlatformByID(ctx context.Context, req dto.Put
 	return args.Get(0).(dto.PutPlatformResponce), args.Error(1)
 }
 
+func (m *MockPostRepository) UpdatePlatformVerification(ctx context.Context, ID_platform int, configVersion int, report domain.VerificationReport) error {
+	args := m.Called(ctx, ID_platform, configVersion, report)
+	return args.Error(0)
+}
+
+func (m *MockPostRepository) PausePlatforms(ctx context.Context, ID_workspace int, ID_platform int) (dto.PauseResponce, error) {
+	args := m.Called(ctx, ID_workspace, ID_platform)
+	return args.Get(0).(dto.PauseResponce), args.Error(1)
+}
+
+func (m *MockPostRepository) ResumePlatforms(ctx context.Context, ID_workspace int, ID_platform int, overdue string) (dto.ResumeResponce, error) {
+	args := m.Called(ctx, ID_workspace, ID_platform, overdue)
+	return args.Get(0).(dto.ResumeResponce), args.Error(1)
+}
+
+func (m *MockPostRepository) FindOrCreateUser(ctx context.Context, identity domain.Identity) (domain.User, error) {
+	args := m.Called(ctx, identity)
+	return args.Get(0).(domain.User), args.Error(1)
+}
+
+func (m *MockPostRepository) GetUserByID(ctx context.Context, ID_user string) (domain.User, error) {
+	args := m.Called(ctx, ID_user)
+	return args.Get(0).(domain.User), args.Error(1)
+}
+
+func (m *MockPostRepository) UpdateUser(ctx context.Context, req dto.PatchMeRequest) (domain.User, error) {
+	args := m.Called(ctx, req)
+	return args.Get(0).(domain.User), args.Error(1)
+}
+
+func (m *MockPostRepository) LinkIdentity(ctx context.Context, ID_user string, identity domain.Identity) error {
+	args := m.Called(ctx, ID_user, identity)
+	return args.Error(0)
+}
+
+func (m *MockPostRepository) GetIdentities(ctx context.Context, ID_user string) ([]domain.UserIdentity, error) {
+	args := m.Called(ctx, ID_user)
+	return args.Get(0).([]domain.UserIdentity), args.Error(1)
+}
+
+func (m *MockPostRepository) UnlinkIdentity(ctx context.Context, ID_user string, provider string) (bool, error) {
+	args := m.Called(ctx, ID_user, provider)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockPostRepository) CreateLocalUser(ctx context.Context, email string, name string, passwordHash string) (domain.User, error) {
+	args := m.Called(ctx, email, name, passwordHash)
+	return args.Get(0).(domain.User), args.Error(1)
+}
+
+func (m *MockPostRepository) GetLocalCredentials(ctx context.Context, email string) (domain.LocalCredentials, error) {
+	args := m.Called(ctx, email)
+	return args.Get(0).(domain.LocalCredentials), args.Error(1)
+}
+
+func (m *MockPostRepository) RecordLoginFailure(ctx context.Context, ID_user string, maxFailures int, lockFor time.Duration) (*time.Time, error) {
+	args := m.Called(ctx, ID_user, maxFailures, lockFor)
+	return args.Get(0).(*time.Time), args.Error(1)
+}
+
+func (m *MockPostRepository) ResetLoginFailures(ctx context.Context, ID_user string) error {
+	args := m.Called(ctx, ID_user)
+	return args.Error(0)
+}
+
+func (m *MockPostRepository) CreateAuthToken(ctx context.Context, ID_user string, purpose string, tokenHash string, expiresAt time.Time) error {
+	args := m.Called(ctx, ID_user, purpose, tokenHash, expiresAt)
+	return args.Error(0)
+}
+
+func (m *MockPostRepository) UseAuthToken(ctx context.Context, purpose string, tokenHash string) (string, error) {
+	args := m.Called(ctx, purpose, tokenHash)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockPostRepository) VerifyEmail(ctx context.Context, tokenHash string) error {
+	args := m.Called(ctx, tokenHash)
+	return args.Error(0)
+}
+
+func (m *MockPostRepository) ResetPassword(ctx context.Context, tokenHash string, passwordHash string) error {
+	args := m.Called(ctx, tokenHash, passwordHash)
+	return args.Error(0)
+}
+
+func (m *MockPostRepository) CreateSession(ctx context.Context, s domain.Session, token domain.RefreshToken) error {
+	args := m.Called(ctx, s, token)
+	return args.Error(0)
+}
+
+func (m *MockPostRepository) RotateRefreshToken(ctx context.Context, ID_session string, ID_user string, old domain.RefreshToken, next domain.RefreshToken) (int, error) {
+	args := m.Called(ctx, ID_session, ID_user, old, next)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockPostRepository) SetSessionWorkspace(ctx context.Context, ID_session string, ID_user string, ID_workspace int) error {
+	args := m.Called(ctx, ID_session, ID_user, ID_workspace)
+	return args.Error(0)
+}
+
+func (m *MockPostRepository) GetSessions(ctx context.Context, ID_user string) ([]domain.Session, error) {
+	args := m.Called(ctx, ID_user)
+	return args.Get(0).([]domain.Session), args.Error(1)
+}
+
+func (m *MockPostRepository) IsSessionActive(ctx context.Context, ID_session string, ID_user string) (bool, error) {
+	args := m.Called(ctx, ID_session, ID_user)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockPostRepository) RevokeSession(ctx context.Context, ID_session string, ID_user string) (bool, error) {
+	args := m.Called(ctx, ID_session, ID_user)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockPostRepository) CreateApiKey(ctx context.Context, ID_key string, prefix string, keyHash string, req dto.CreateApiKeyRequest) (domain.ApiKey, error) {
+	args := m.Called(ctx, ID_key, prefix, keyHash, req)
+	return args.Get(0).(domain.ApiKey), args.Error(1)
+}
+
+func (m *MockPostRepository) GetApiKeys(ctx context.Context, ID_user string) ([]domain.ApiKey, error) {
+	args := m.Called(ctx, ID_user)
+	return args.Get(0).([]domain.ApiKey), args.Error(1)
+}
+
+func (m *MockPostRepository) RevokeApiKey(ctx context.Context, ID_key string, ID_user string) (bool, error) {
+	args := m.Called(ctx, ID_key, ID_user)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockPostRepository) UseApiKey(ctx context.Context, keyHash string) (domain.ApiKey, error) {
+	args := m.Called(ctx, keyHash)
+	return args.Get(0).(domain.ApiKey), args.Error(1)
+}
+
+func (m *MockPostRepository) CreateWorkspace(ctx context.Context, name string, ID_user string) (domain.Workspace, error) {
+	args := m.Called(ctx, name, ID_user)
+	return args.Get(0).(domain.Workspace), args.Error(1)
+}
+
+func (m *MockPostRepository) GetWorkspaces(ctx context.Context, ID_user string) ([]domain.Workspace, error) {
+	args := m.Called(ctx, ID_user)
+	return args.Get(0).([]domain.Workspace), args.Error(1)
+}
+
+func (m *MockPostRepository) GetDefaultWorkspace(ctx context.Context, ID_user string) (int, error) {
+	args := m.Called(ctx, ID_user)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockPostRepository) GetMemberRole(ctx context.Context, ID_workspace int, ID_user string) (string, error) {
+	args := m.Called(ctx, ID_workspace, ID_user)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockPostRepository) GetMembers(ctx context.Context, ID_workspace int) ([]domain.WorkspaceMember, error) {
+	args := m.Called(ctx, ID_workspace)
+	return args.Get(0).([]domain.WorkspaceMember), args.Error(1)
+}
+
+func (m *MockPostRepository) UpdateMemberRole(ctx context.Context, ID_workspace int, ID_user string, role string) (bool, error) {
+	args := m.Called(ctx, ID_workspace, ID_user, role)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockPostRepository) RemoveMember(ctx context.Context, ID_workspace int, ID_user string) (bool, error) {
+	args := m.Called(ctx, ID_workspace, ID_user)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockPostRepository) CreateInvitation(ctx context.Context, inv domain.Invitation, tokenHash string) error {
+	args := m.Called(ctx, inv, tokenHash)
+	return args.Error(0)
+}
+
+func (m *MockPostRepository) AcceptInvitation(ctx context.Context, tokenHash string, ID_user string) (domain.Workspace, error) {
+	args := m.Called(ctx, tokenHash, ID_user)
+	return args.Get(0).(domain.Workspace), args.Error(1)
+}
+
+func (m *MockPostRepository) SubmitPost(ctx context.Context, ID_post int, ID_workspace int, ID_user string) (string, error) {
+	args := m.Called(ctx, ID_post, ID_workspace, ID_user)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockPostRepository) ReviewPost(ctx context.Context, ID_workspace int, review domain.PostReview) (string, error) {
+	args := m.Called(ctx, ID_workspace, review)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockPostRepository) GetPostReviews(ctx context.Context, ID_post int, ID_workspace int) ([]domain.PostReview, error) {
+	args := m.Called(ctx, ID_post, ID_workspace)
+	return args.Get(0).([]domain.PostReview), args.Error(1)
+}
+
+func (m *MockPostRepository) UpdateWorkspaceApprovals(ctx context.Context, ID_workspace int, required int) error {
+	args := m.Called(ctx, ID_workspace, required)
+	return args.Error(0)
+}
+
+func (m *MockPostRepository) UpdatePlatformApprovals(ctx context.Context, ID_platform int, ID_workspace int, required *int) (bool, error) {
+	args := m.Called(ctx, ID_platform, ID_workspace, required)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockPostRepository) GetPostRevisions(ctx context.Context, ID_post int, ID_workspace int) ([]domain.PostRevision, error) {
+	args := m.Called(ctx, ID_post, ID_workspace)
+	return args.Get(0).([]domain.PostRevision), args.Error(1)
+}
+
+func (m *MockPostRepository) GetPostRevision(ctx context.Context, ID_post int, ID_workspace int, revision int) (domain.PostRevision, error) {
+	args := m.Called(ctx, ID_post, ID_workspace, revision)
+	return args.Get(0).(domain.PostRevision), args.Error(1)
+}
+
+func (m *MockPostRepository) RestorePostRevision(ctx context.Context, ID_post int, ID_workspace int, revision int, ID_user string) (int, error) {
+	args := m.Called(ctx, ID_post, ID_workspace, revision, ID_user)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockPostRepository) CreateTemplate(ctx context.Context, req dto.CreateTemplateRequest) (domain.PostTemplate, error) {
+	args := m.Called(ctx, req)
+	return args.Get(0).(domain.PostTemplate), args.Error(1)
+}
+
+func (m *MockPostRepository) GetTemplates(ctx context.Context, ID_workspace int) ([]domain.PostTemplate, error) {
+	args := m.Called(ctx, ID_workspace)
+	return args.Get(0).([]domain.PostTemplate), args.Error(1)
+}
+
+func (m *MockPostRepository) GetTemplateByID(ctx context.Context, ID_template int, ID_workspace int) (domain.PostTemplate, error) {
+	args := m.Called(ctx, ID_template, ID_workspace)
+	return args.Get(0).(domain.PostTemplate), args.Error(1)
+}
+
+func (m *MockPostRepository) UpdateTemplate(ctx context.Context, req dto.PutTemplateRequest) (domain.PostTemplate, error) {
+	args := m.Called(ctx, req)
+	return args.Get(0).(domain.PostTemplate), args.Error(1)
+}
+
+func (m *MockPostRepository) DeleteTemplate(ctx context.Context, ID_template int, ID_workspace int) (bool, error) {
+	args := m.Called(ctx, ID_template, ID_workspace)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockPostRepository) GetPostVariables(ctx context.Context, ID_post int, ID_workspace int) (*domain.PostVariables, error) {
+	args := m.Called(ctx, ID_post, ID_workspace)
+	vars, _ := args.Get(0).(*domain.PostVariables)
+	return vars, args.Error(1)
+}
+
+func (m *MockPostRepository) CreatePosts(ctx context.Context, posts []dto.CreatePostRequest) ([]int, error) {
+	args := m.Called(ctx, posts)
+	return args.Get(0).([]int), args.Error(1)
+}
+
+func (m *MockPostRepository) CreateImportJob(ctx context.Context, ID_workspace int, job domain.ImportJob) error {
+	args := m.Called(ctx, ID_workspace, job)
+	return args.Error(0)
+}
+
+func (m *MockPostRepository) UpdateImportJob(ctx context.Context, job domain.ImportJob) error {
+	args := m.Called(ctx, job)
+	return args.Error(0)
+}
+
+func (m *MockPostRepository) GetImportJob(ctx context.Context, ID_job string, ID_workspace int) (domain.ImportJob, error) {
+	args := m.Called(ctx, ID_job, ID_workspace)
+	return args.Get(0).(domain.ImportJob), args.Error(1)
+}
+
+func (m *MockPostRepository) ExportPosts(ctx context.Context, ID_workspace int, filter dto.PostFilter, fn func(domain.ExportRow) error) error {
+	args := m.Called(ctx, ID_workspace, filter)
+	if rows, ok := args.Get(0).([]domain.ExportRow); ok {
+		for _, row := range rows {
+			if err := fn(row); err != nil {
+				return err
+			}
+		}
+	}
+	return args.Error(1)
+}
+
+func (m *MockPostRepository) CreateCalendarToken(ctx context.Context, ID_user string, tokenHash string) error {
+	args := m.Called(ctx, ID_user, tokenHash)
+	return args.Error(0)
+}
+
+func (m *MockPostRepository) DeleteCalendarToken(ctx context.Context, ID_user string) (bool, error) {
+	args := m.Called(ctx, ID_user)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockPostRepository) GetCalendarEvents(ctx context.Context, tokenHash string) ([]domain.CalendarEvent, error) {
+	args := m.Called(ctx, tokenHash)
+	return args.Get(0).([]domain.CalendarEvent), args.Error(1)
+}
+
+func (m *MockPostRepository) CreateWebhook(ctx context.Context, ID_workspace int, hook domain.Webhook) (domain.Webhook, error) {
+	args := m.Called(ctx, ID_workspace, hook)
+	return args.Get(0).(domain.Webhook), args.Error(1)
+}
+
+func (m *MockPostRepository) GetWebhooks(ctx context.Context, ID_workspace int) ([]domain.Webhook, error) {
+	args := m.Called(ctx, ID_workspace)
+	return args.Get(0).([]domain.Webhook), args.Error(1)
+}
+
+func (m *MockPostRepository) UpdateWebhook(ctx context.Context, ID_webhook int, ID_workspace int, url *string, events []string, isActive *bool) (domain.Webhook, error) {
+	args := m.Called(ctx, ID_webhook, ID_workspace, url, events, isActive)
+	return args.Get(0).(domain.Webhook), args.Error(1)
+}
+
+func (m *MockPostRepository) DeleteWebhook(ctx context.Context, ID_webhook int, ID_workspace int) (bool, error) {
+	args := m.Called(ctx, ID_webhook, ID_workspace)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockPostRepository) GetWebhookDeliveries(ctx context.Context, ID_webhook int, ID_workspace int) ([]domain.WebhookDelivery, error) {
+	args := m.Called(ctx, ID_webhook, ID_workspace)
+	return args.Get(0).([]domain.WebhookDelivery), args.Error(1)
+}
+
+func (m *MockPostRepository) RedeliverWebhook(ctx context.Context, ID_webhook int, ID_delivery int, ID_workspace int) (domain.WebhookDelivery, error) {
+	args := m.Called(ctx, ID_webhook, ID_delivery, ID_workspace)
+	return args.Get(0).(domain.WebhookDelivery), args.Error(1)
+}
+
+func (m *MockPostRepository) EnqueueWebhookEvent(ctx context.Context, event domain.WebhookEvent) (int, error) {
+	args := m.Called(ctx, event)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockPostRepository) UnlinkTelegram(ctx context.Context, ID_user string) (bool, error) {
+	args := m.Called(ctx, ID_user)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockPostRepository) GetNotificationSettings(ctx context.Context, ID_user string) (domain.NotificationSettings, error) {
+	args := m.Called(ctx, ID_user)
+	return args.Get(0).(domain.NotificationSettings), args.Error(1)
+}
+
+func (m *MockPostRepository) UpdateNotificationSettings(ctx context.Context, ID_user string, s domain.NotificationSettings) (domain.NotificationSettings, error) {
+	args := m.Called(ctx, ID_user, s)
+	return args.Get(0).(domain.NotificationSettings), args.Error(1)
+}
+
+func (m *MockPostRepository) GetNotifications(ctx context.Context, ID_user string) ([]domain.Notification, error) {
+	args := m.Called(ctx, ID_user)
+	return args.Get(0).([]domain.Notification), args.Error(1)
+}
+
+type fakeMailer struct {
+	mu   sync.Mutex
+	sent []mailer.Message
+}
+
+func (f *fakeMailer) Send(ctx context.Context, msg mailer.Message) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sent = append(f.sent, msg)
+	return nil
+}
+
+// waitSent дожидается писем, отправленных в фоне
+func (f *fakeMailer) waitSent(t *testing.T, n int) []mailer.Message {
+	assert.Eventually(t, func() bool {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		return len(f.sent) >= n
+	}, time.Second, 10*time.Millisecond)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]mailer.Message(nil), f.sent...)
+}
+
+type fakeVerifier struct {
+	report domain.VerificationReport
+}
+
+func (f *fakeVerifier) Verify(ctx context.Context, platformName string, cfg domain.PlatformConfig) domain.VerificationReport {
+	return f.report
+}
+
+// testTokens подписывает токены тестовым ключом, тем же, что проверяет AuthMiddleware
+var testTokens = func() *auth.TokenIssuer {
+	seed := make([]byte, ed25519.SeedSize)
+	issuer, err := auth.NewTokenIssuer("test", map[string]ed25519.PrivateKey{"test": ed25519.NewKeyFromSeed(seed)}, "http://localhost:8080", "hexlet-api")
+	if err != nil {
+		panic(err)
+	}
+	return issuer
+}()
+
 func setupTest() (*gin.Engine, *MockPostRepository, *App) {
 	gin.SetMode(gin.TestMode)
-	os.Setenv("JWT_ACCESS_SECRET", "test-secret")
 	mockRepo := new(MockPostRepository)
 	app := &App{
-		Ctx:  context.Background(),
-		Repo: mockRepo,
+		Ctx:      context.Background(),
+		Repo:     mockRepo,
+		Tokens:   testTokens,
+		Resolver: testResolver{},
 	}
+	// по умолчанию пользователь "1" — владелец своего пространства 1
+	mockRepo.On("GetDefaultWorkspace", mock.Anything, "1").Return(1, nil).Maybe()
+	mockRepo.On("GetMemberRole", mock.Anything, 1, "1").Return(domain.RoleOwner, nil).Maybe()
+	// события для вебхуков; проверяются через AssertCalled
+	mockRepo.On("EnqueueWebhookEvent", mock.Anything, mock.Anything).Return(0, nil).Maybe()
 	router := gin.New()
 	app.Routes(router)
 	return router, mockRepo, app
 }
 func generateTestToken(userID string) string {
-	// Access Token
-	accessToken := jwt.NewWithClaims(jwt.SigningMethodHS256, auth.MyClaims{
-		UserID: userID,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(15 * time.Minute)),
-		},
-	})
-	aToken, _ := accessToken.SignedString([]byte(os.Getenv("JWT_ACCESS_SECRET")))
+	aToken, _ := testTokens.GenerateAccessToken(userID, "", 0)
 	return aToken
 }
 
@@ -121,7 +529,7 @@ func TestCreatePost_Success(t *testing.T) {
 
 		return req.ID_user == "1" &&
 			req.Title == "Test Post" &&
-			req.Status == "scheduled" &&
+			req.Status == domain.PostInReview &&
 			req.Sheduled_for.Unix() == expectedTimeInRepo
 	})).Return(1, expectedCreatedAt, nil)
 	req, _ := http.NewRequest("POST", "/posts", bytes.NewBuffer(jsonBody))
@@ -260,7 +668,7 @@ func TestGetPosts_Success(t *testing.T) {
 		},
 	}
 
-	mockRepo.On("GetPost", mock.Anything, "1").Return(expectedResponse, nil)
+	mockRepo.On("GetPost", mock.Anything, 1, dto.PostFilter{}).Return(expectedResponse, nil)
 
 	jsonBody, _ := json.Marshal(reqBody)
 	req, _ := http.NewRequest("GET", "/posts", bytes.NewBuffer(jsonBody))
@@ -294,7 +702,7 @@ func TestGetPosts_RepositoryError(t *testing.T) {
 		ID_user: "1",
 	}
 
-	mockRepo.On("GetPost", mock.Anything, "1").Return(dto.GetPostsResponce{}, errors.New("database error"))
+	mockRepo.On("GetPost", mock.Anything, 1, dto.PostFilter{}).Return(dto.GetPostsResponce{}, errors.New("database error"))
 
 	jsonBody, _ := json.Marshal(reqBody)
 	req, _ := http.NewRequest("GET", "/posts", bytes.NewBuffer(jsonBody))
@@ -337,7 +745,7 @@ func TestGetPost_Success(t *testing.T) {
 		},
 	}
 
-	mockRepo.On("GetPostByID", mock.Anything, 1, "1").Return(expectedPost, nil)
+	mockRepo.On("GetPostByID", mock.Anything, 1, 1).Return(expectedPost, nil)
 
 	jsonBody, _ := json.Marshal(reqBody)
 	req, _ := http.NewRequest("GET", "/posts/1", bytes.NewBuffer(jsonBody))
@@ -404,7 +812,8 @@ func TestPutPost_Success(t *testing.T) {
 		Updated_at: time.Now().Round(0),
 	}
 
-	mockRepo.On("GetPostByID", mock.Anything, 1, "1").Return(existingPost, nil)
+	mockRepo.On("GetPostByID", mock.Anything, 1, 1).Return(existingPost, nil)
+	mockRepo.On("GetPostVariables", mock.Anything, 1, 1).Return(nil, nil)
 	mockRepo.On("UpdatePostByID", mock.Anything, mock.MatchedBy(func(req dto.PutPostRequest) bool {
 		return req.Title == "Updated Title" && req.Content == "Updated Content"
 	})).Return(expectedResponse, nil)
@@ -447,12 +856,13 @@ func TestPutPost_WithEmptyFields(t *testing.T) {
 		Updated_at: time.Now().Round(0),
 	}
 
-	mockRepo.On("GetPostByID", mock.Anything, 1, "1").Return(existingPost, nil)
+	mockRepo.On("GetPostByID", mock.Anything, 1, 1).Return(existingPost, nil)
+	mockRepo.On("GetPostVariables", mock.Anything, 1, 1).Return(nil, nil)
 	mockRepo.On("UpdatePostByID", mock.Anything, mock.MatchedBy(func(req dto.PutPostRequest) bool {
 		return req.ID_user == "1" &&
 			req.Title == "Original Title" &&
 			req.Content == "Original Content" &&
-			compareTime(req.Sheduled_for, scheduledTime)
+			compareTime(req.Sheduled_for, scheduledTime.Add(-3*time.Hour))
 	})).Return(expectedResponse, nil)
 
 	jsonBody, _ := json.Marshal(reqBody)
@@ -467,6 +877,64 @@ func TestPutPost_WithEmptyFields(t *testing.T) {
 	mockRepo.AssertExpectations(t)
 }
 
+func TestPutPost_TitleOnlyKeepsSchedule(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+
+	stored := time.Date(2026, 11, 1, 7, 0, 0, 0, time.UTC)
+	existingPost := dto.GetPostResponce{
+		Posts: []domain.Post{
+			{
+				ID_post:      1,
+				ID_user:      "1",
+				Title:        "Original Title",
+				Content:      "Original Content",
+				Sheduled_for: stored.Add(3 * time.Hour),
+			},
+		},
+	}
+	mockRepo.On("GetPostByID", mock.Anything, 1, 1).Return(existingPost, nil)
+	mockRepo.On("GetPostVariables", mock.Anything, 1, 1).Return(nil, nil)
+	var update dto.PutPostRequest
+	mockRepo.On("UpdatePostByID", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		update = args.Get(1).(dto.PutPostRequest)
+	}).Return(dto.PutPostResponce{ID_post: 1}, nil)
+
+	jsonBody, _ := json.Marshal(dto.PutPostRequest{Title: "Updated Title"})
+	req, _ := http.NewRequest("PUT", "/posts/1", bytes.NewBuffer(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "Updated Title", update.Title)
+	assert.Equal(t, "Original Content", update.Content)
+	assert.True(t, stored.Equal(update.Sheduled_for), "scheduled_for must stay %v, got %v", stored, update.Sheduled_for)
+}
+
+func TestPutPost_TemplateUnfilled(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+	existingPost := dto.GetPostResponce{
+		Posts: []domain.Post{
+			{ID_post: 1, ID_user: "1", Title: "Meetup", Content: "Join us", PlatformName: "Telegram"},
+		},
+	}
+	mockRepo.On("GetPostByID", mock.Anything, 1, 1).Return(existingPost, nil)
+	mockRepo.On("GetPostVariables", mock.Anything, 1, 1).Return(&domain.PostVariables{Values: map[string]string{"event": "Meetup"}}, nil)
+
+	jsonBody, _ := json.Marshal(dto.PutPostRequest{Content: "{{event}} at {{link}} on {{date}}"})
+	req, _ := http.NewRequest("PUT", "/posts/1", bytes.NewBuffer(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.JSONEq(t, `{"error":"template variables are not filled","missing":{"Telegram":["link"]}}`, w.Body.String())
+	mockRepo.AssertNotCalled(t, "UpdatePostByID", mock.Anything, mock.Anything)
+}
+
 func TestPutPost_InvalidID(t *testing.T) {
 	router, mockRepo, _ := setupTest()
 
@@ -509,8 +977,8 @@ func TestDeletePost_Success(t *testing.T) {
 		},
 	}
 
-	mockRepo.On("GetPostByID", mock.Anything, 1, "1").Return(expectedPost, nil)
-	mockRepo.On("DeletePostByID", mock.Anything, 1).Return(nil)
+	mockRepo.On("GetPostByID", mock.Anything, 1, 1).Return(expectedPost, nil)
+	mockRepo.On("DeletePostByID", mock.Anything, 1, 1).Return(nil)
 
 	jsonBody, _ := json.Marshal(reqBody)
 	req, _ := http.NewRequest("DELETE", "/posts/1", bytes.NewBuffer(jsonBody))
@@ -531,7 +999,7 @@ func TestDeletePost_NotFound(t *testing.T) {
 		ID_user: "1",
 	}
 
-	mockRepo.On("GetPostByID", mock.Anything, 1, "1").Return(dto.GetPostResponce{}, errors.New("not found"))
+	mockRepo.On("GetPostByID", mock.Anything, 1, 1).Return(dto.GetPostResponce{}, errors.New("not found"))
 
 	jsonBody, _ := json.Marshal(reqBody)
 	req, _ := http.NewRequest("DELETE", "/posts/1", bytes.NewBuffer(jsonBody))
@@ -578,17 +1046,23 @@ func TestCreatePlatform_Success(t *testing.T) {
 
 	reqBody := dto.CreatePlatformRequest{
 		ID_user:      "1",
-		PlatformName: "Test Platform",
-		Bot_name:     "test_bot",
-		Config:       "test_config",
+		PlatformName: "Telegram",
+		Telegram: &domain.TelegramConfig{
+			BotToken: "123:test_token",
+			ChatID:   "@test_channel",
+			Silent:   true,
+		},
 	}
 
 	expectedTime := time.Now().Round(0)
 	mockRepo.On("CreatePlatform", mock.Anything, mock.MatchedBy(func(req dto.CreatePlatformRequest) bool {
 		return req.ID_user == "1" &&
-			req.PlatformName == "Test Platform" &&
-			req.Bot_name == "test_bot" &&
-			req.Config == "test_config"
+			req.PlatformName == "Telegram" &&
+			req.Telegram != nil &&
+			req.Telegram.BotToken == "123:test_token" &&
+			req.Telegram.ChatID == "@test_channel" &&
+			req.Telegram.Silent &&
+			req.VK == nil
 	})).Return(1, expectedTime, nil)
 
 	jsonBody, _ := json.Marshal(reqBody)
@@ -628,54 +1102,116 @@ func TestCreatePlatform_ValidationError(t *testing.T) {
 	mockRepo.AssertNotCalled(t, "CreatePlatform")
 }
 
-// Тесты для GetPlatforms
-func TestGetPlatforms_Success(t *testing.T) {
+func TestCreatePlatform_MissingSection(t *testing.T) {
 	router, mockRepo, _ := setupTest()
 
-	reqBody := dto.GetByUserIDRequest{
-		ID_user: "1",
-	}
-
-	expectedResponse := dto.GetPlatformResponce{
-		Platfroms: []domain.Platform{
-			{
-				ID_platform: 1,
-				Name:        "Platform 1",
-				Api_config: map[string]string{
-					"bot1": "config1",
-				},
-				Is_active:  true,
-				Created_at: time.Now().Round(0),
-				Updated_at: time.Now().Round(0),
-			},
-			{
-				ID_platform: 2,
-				Name:        "Platform 2",
-				Api_config: map[string]string{
-					"bot2": "config2",
-				},
-				Is_active:  true,
-				Created_at: time.Now().Round(0),
-				Updated_at: time.Now().Round(0),
-			},
-		},
+	reqBody := dto.CreatePlatformRequest{
+		PlatformName: "VK",
+		Telegram:     &domain.TelegramConfig{BotToken: "123:token", ChatID: "@channel"},
 	}
 
-	mockRepo.On("GetPlatform", mock.Anything, "1").Return(expectedResponse, nil)
-
 	jsonBody, _ := json.Marshal(reqBody)
-	req, _ := http.NewRequest("GET", "/platforms", bytes.NewBuffer(jsonBody))
+	req, _ := http.NewRequest("POST", "/platforms", bytes.NewBuffer(jsonBody))
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
 
 	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
 
-	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockRepo.AssertNotCalled(t, "CreatePlatform")
+}
 
-	var response dto.GetPlatformResponce
-	err := json.Unmarshal(w.Body.Bytes(), &response)
-	assert.NoError(t, err)
+func TestCreatePlatform_InvalidVKOwner(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+
+	for _, owner := range []string{"club1", "1.5", "+3", "-"} {
+		reqBody := dto.CreatePlatformRequest{
+			PlatformName: "VK",
+			VK:           &domain.VKConfig{AccessToken: "token", OwnerID: owner},
+		}
+
+		jsonBody, _ := json.Marshal(reqBody)
+		req, _ := http.NewRequest("POST", "/platforms", bytes.NewBuffer(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, owner)
+	}
+	mockRepo.AssertNotCalled(t, "CreatePlatform")
+}
+
+func TestGetPlatformSchema(t *testing.T) {
+	router, _, _ := setupTest()
+
+	req, _ := http.NewRequest("GET", "/platforms/schemas/Telegram", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var schema map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &schema))
+	assert.Equal(t, []interface{}{"bot_token", "chat_id"}, schema["required"])
+
+	req, _ = http.NewRequest("GET", "/platforms/schemas/Unknown", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+// Тесты для GetPlatforms
+func TestGetPlatforms_Success(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+
+	reqBody := dto.GetByUserIDRequest{
+		ID_user: "1",
+	}
+
+	expectedResponse := dto.GetPlatformResponce{
+		Platfroms: []domain.Platform{
+			{
+				ID_platform: 1,
+				Name:        "Platform 1",
+				Api_config: domain.PlatformConfig{
+					Version:  domain.PlatformConfigVersion,
+					Telegram: &domain.TelegramConfig{BotToken: "123:config1", ChatID: "@bot1"},
+				},
+				Is_active:  true,
+				Created_at: time.Now().Round(0),
+				Updated_at: time.Now().Round(0),
+			},
+			{
+				ID_platform: 2,
+				Name:        "Platform 2",
+				Api_config: domain.PlatformConfig{
+					Version: domain.PlatformConfigVersion,
+					VK:      &domain.VKConfig{AccessToken: "config2", OwnerID: "-2"},
+				},
+				Is_active:  true,
+				Created_at: time.Now().Round(0),
+				Updated_at: time.Now().Round(0),
+			},
+		},
+	}
+
+	mockRepo.On("GetPlatform", mock.Anything, 1).Return(expectedResponse, nil)
+
+	jsonBody, _ := json.Marshal(reqBody)
+	req, _ := http.NewRequest("GET", "/platforms", bytes.NewBuffer(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response dto.GetPlatformResponce
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
 	assert.Len(t, response.Platfroms, 2)
 	assert.Equal(t, "Platform 1", response.Platfroms[0].Name)
 	assert.Equal(t, "Platform 2", response.Platfroms[1].Name)
@@ -690,7 +1226,7 @@ func TestGetPlatforms_RepositoryError(t *testing.T) {
 		ID_user: "1",
 	}
 
-	mockRepo.On("GetPlatform", mock.Anything, "1").Return(dto.GetPlatformResponce{}, errors.New("database error"))
+	mockRepo.On("GetPlatform", mock.Anything, 1).Return(dto.GetPlatformResponce{}, errors.New("database error"))
 
 	jsonBody, _ := json.Marshal(reqBody)
 	req, _ := http.NewRequest("GET", "/platforms", bytes.NewBuffer(jsonBody))
@@ -721,15 +1257,16 @@ func TestGetPlatform_Success(t *testing.T) {
 	expectedPlatform := domain.Platform{
 		ID_platform: 1,
 		Name:        "Test Platform",
-		Api_config: map[string]string{
-			"bot1": "config1",
+		Api_config: domain.PlatformConfig{
+			Version:  domain.PlatformConfigVersion,
+			Telegram: &domain.TelegramConfig{BotToken: "123:config1", ChatID: "@bot1"},
 		},
 		Is_active:  true,
 		Created_at: time.Now().Round(0),
 		Updated_at: time.Now().Round(0),
 	}
 
-	mockRepo.On("GetPlatformByID", mock.Anything, 1, "1").Return(expectedPlatform, nil)
+	mockRepo.On("GetPlatformByID", mock.Anything, 1, 1).Return(expectedPlatform, nil)
 
 	jsonBody, _ := json.Marshal(reqBody)
 	req, _ := http.NewRequest("GET", "/platforms/1", bytes.NewBuffer(jsonBody))
@@ -750,6 +1287,44 @@ func TestGetPlatform_Success(t *testing.T) {
 	mockRepo.AssertExpectations(t)
 }
 
+func TestGetPlatform_MasksCredentials(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+
+	expectedPlatform := domain.Platform{
+		ID_platform: 1,
+		Name:        "Telegram",
+		Api_config: domain.PlatformConfig{
+			Version: domain.PlatformConfigVersion,
+			Telegram: &domain.TelegramConfig{
+				BotToken: "1234567890:AAHdqTcvCH1vGWJxfSeofSAs0K5PALDsxyz",
+				ChatID:   "@channel",
+			},
+		},
+		Is_active: true,
+	}
+
+	mockRepo.On("GetPlatformByID", mock.Anything, 1, 1).Return(expectedPlatform, nil)
+
+	jsonBody, _ := json.Marshal(dto.GetByUserIDRequest{ID_user: "1"})
+	req, _ := http.NewRequest("GET", "/platforms/1", bytes.NewBuffer(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "AAHdqTcvCH1vGWJxfSeofSAs0K5PALDs")
+
+	var response domain.Platform
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, "123***xyz", response.Api_config.Telegram.BotToken)
+	assert.Equal(t, "@channel", response.Api_config.Telegram.ChatID)
+
+	mockRepo.AssertExpectations(t)
+}
+
 func TestGetPlatform_InvalidID(t *testing.T) {
 	router, mockRepo, _ := setupTest()
 
@@ -782,7 +1357,7 @@ func TestGetPlatform_NotFound(t *testing.T) {
 		ID_user: "1",
 	}
 
-	mockRepo.On("GetPlatformByID", mock.Anything, 1, "1").Return(domain.Platform{}, errors.New("not found"))
+	mockRepo.On("GetPlatformByID", mock.Anything, 1, 1).Return(domain.Platform{}, errors.New("not found"))
 
 	jsonBody, _ := json.Marshal(reqBody)
 	req, _ := http.NewRequest("GET", "/platforms/1", bytes.NewBuffer(jsonBody))
@@ -807,18 +1382,20 @@ func TestPutPlatform_Success(t *testing.T) {
 	router, mockRepo, _ := setupTest()
 
 	reqBody := dto.PutPlatformRequest{
-		ID_user:      "1",
-		ID_platform:  1,
-		PlatformName: "Updated Platform",
-		Bot_name:     "updated_bot",
-		Config:       "updated_config",
+		ID_user:     "1",
+		ID_platform: 1,
+		Telegram: &domain.TelegramConfig{
+			BotToken: "123:updated_token",
+			ChatID:   "@updated_channel",
+		},
 	}
 
 	existingPlatform := domain.Platform{
 		ID_platform: 1,
-		Name:        "Old Platform",
-		Api_config: map[string]string{
-			"old_bot": "old_config",
+		Name:        "Telegram",
+		Api_config: domain.PlatformConfig{
+			Version:  domain.PlatformConfigVersion,
+			Telegram: &domain.TelegramConfig{BotToken: "123:old_token", ChatID: "@old_channel"},
 		},
 		Is_active: true,
 	}
@@ -829,13 +1406,13 @@ func TestPutPlatform_Success(t *testing.T) {
 		Updated_at:  time.Now().Round(0),
 	}
 
-	mockRepo.On("GetPlatformByID", mock.Anything, 1, "1").Return(existingPlatform, nil)
+	mockRepo.On("GetPlatformByID", mock.Anything, 1, 1).Return(existingPlatform, nil)
 	mockRepo.On("UpdatePlatformByID", mock.Anything, mock.MatchedBy(func(req dto.PutPlatformRequest) bool {
 		return req.ID_user == "1" &&
 			req.ID_platform == 1 &&
-			req.PlatformName == "Updated Platform" &&
-			req.Bot_name == "updated_bot" &&
-			req.Config == "updated_config"
+			req.Config.Telegram != nil &&
+			req.Config.Telegram.BotToken == "123:updated_token" &&
+			req.Config.Telegram.ChatID == "@updated_channel"
 	})).Return(expectedResponse, nil)
 
 	jsonBody, _ := json.Marshal(reqBody)
@@ -850,15 +1427,113 @@ func TestPutPlatform_Success(t *testing.T) {
 	mockRepo.AssertExpectations(t)
 }
 
+func TestPutPlatform_VerifiesSavedVersion(t *testing.T) {
+	router, mockRepo, app := setupTest()
+	report := domain.VerificationReport{OK: true}
+	app.Verifier = &fakeVerifier{report: report}
+
+	existingPlatform := domain.Platform{
+		ID_platform: 1,
+		Name:        "VK",
+		Api_config: domain.PlatformConfig{
+			Version: domain.PlatformConfigVersion,
+			VK:      &domain.VKConfig{AccessToken: "old_token", OwnerID: "-1"},
+		},
+		Config_version: 2,
+	}
+	mockRepo.On("GetPlatformByID", mock.Anything, 1, 1).Return(existingPlatform, nil)
+	mockRepo.On("UpdatePlatformByID", mock.Anything, mock.AnythingOfType("dto.PutPlatformRequest")).
+		Return(dto.PutPlatformResponce{ID_platform: 1, ID_user: "1", Config_version: 3}, nil)
+	// отчёт относится к сохранённым учётным данным, а не к прочитанным до обновления
+	stored := make(chan struct{})
+	mockRepo.On("UpdatePlatformVerification", mock.Anything, 1, 3, report).Return(nil).
+		Run(func(mock.Arguments) { close(stored) })
+
+	jsonBody, _ := json.Marshal(dto.PutPlatformRequest{ID_user: "1", VK: &domain.VKConfig{AccessToken: "new_token", OwnerID: "-1"}})
+	req, _ := http.NewRequest("PUT", "/platforms/1", bytes.NewBuffer(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	select {
+	case <-stored:
+	case <-time.After(time.Second):
+		t.Fatal("verification report is not stored")
+	}
+	mockRepo.AssertExpectations(t)
+}
+
+func TestPutPlatform_KeepsTokenWhenEmpty(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+
+	existingPlatform := domain.Platform{
+		ID_platform: 1,
+		Name:        "VK",
+		Api_config: domain.PlatformConfig{
+			Version: domain.PlatformConfigVersion,
+			VK:      &domain.VKConfig{AccessToken: "old_token", OwnerID: "-1"},
+		},
+		Is_active: true,
+	}
+
+	mockRepo.On("GetPlatformByID", mock.Anything, 1, 1).Return(existingPlatform, nil)
+	mockRepo.On("UpdatePlatformByID", mock.Anything, mock.MatchedBy(func(req dto.PutPlatformRequest) bool {
+		return req.Config.VK != nil &&
+			req.Config.VK.AccessToken == "old_token" &&
+			req.Config.VK.OwnerID == "-2" &&
+			req.Config.VK.FromGroup
+	})).Return(dto.PutPlatformResponce{ID_platform: 1, ID_user: "1"}, nil)
+
+	jsonBody, _ := json.Marshal(dto.PutPlatformRequest{ID_user: "1", VK: &domain.VKConfig{OwnerID: "-2", FromGroup: true}})
+	req, _ := http.NewRequest("PUT", "/platforms/1", bytes.NewBuffer(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestPutPlatform_WrongConfigType(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+
+	existingPlatform := domain.Platform{
+		ID_platform: 1,
+		Name:        "Telegram",
+		Api_config: domain.PlatformConfig{
+			Version:  domain.PlatformConfigVersion,
+			Telegram: &domain.TelegramConfig{BotToken: "123:old_token", ChatID: "@old_channel"},
+		},
+	}
+
+	mockRepo.On("GetPlatformByID", mock.Anything, 1, 1).Return(existingPlatform, nil)
+
+	jsonBody, _ := json.Marshal(dto.PutPlatformRequest{ID_user: "1", VK: &domain.VKConfig{AccessToken: "token", OwnerID: "-2"}})
+	req, _ := http.NewRequest("PUT", "/platforms/1", bytes.NewBuffer(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockRepo.AssertNotCalled(t, "UpdatePlatformByID")
+}
+
 func TestPutPlatform_InvalidID(t *testing.T) {
 	router, mockRepo, _ := setupTest()
 
 	reqBody := dto.PutPlatformRequest{
-		ID_user:      "1",
-		ID_platform:  1,
-		PlatformName: "Updated Platform",
-		Bot_name:     "updated_bot",
-		Config:       "updated_config",
+		ID_user:     "1",
+		ID_platform: 1,
+		Telegram: &domain.TelegramConfig{
+			BotToken: "123:updated_token",
+			ChatID:   "@updated_channel",
+		},
 	}
 
 	jsonBody, _ := json.Marshal(reqBody)
@@ -880,102 +1555,2148 @@ func TestPutPlatform_InvalidID(t *testing.T) {
 	mockRepo.AssertNotCalled(t, "UpdatePlatformByID")
 }
 
-// Тесты для DeletePlatform
-func TestDeletePlatform_Success(t *testing.T) {
-	router, mockRepo, _ := setupTest()
-
-	reqBody := dto.GetByUserIDRequest{
-		ID_user: "1",
+// Тесты для VerifyPlatform
+func TestVerifyPlatform_Success(t *testing.T) {
+	router, mockRepo, app := setupTest()
+	report := domain.VerificationReport{
+		OK:     true,
+		Checks: []domain.VerificationCheck{{Name: "bot_token", OK: true, Message: "@test_bot"}},
 	}
+	app.Verifier = &fakeVerifier{report: report}
 
 	existingPlatform := domain.Platform{
 		ID_platform: 1,
-		Name:        "Test Platform",
+		Name:        "Telegram",
+		Api_config: domain.PlatformConfig{
+			Version:  domain.PlatformConfigVersion,
+			Telegram: &domain.TelegramConfig{BotToken: "123:token", ChatID: "@channel"},
+		},
+		Config_version: 4,
 	}
+	mockRepo.On("GetPlatformByID", mock.Anything, 1, 1).Return(existingPlatform, nil)
+	mockRepo.On("UpdatePlatformVerification", mock.Anything, 1, 4, report).Return(nil)
 
-	mockRepo.On("GetPlatformByID", mock.Anything, 1, "1").Return(existingPlatform, nil)
-	mockRepo.On("DeletePlatformByID", mock.Anything, 1).Return(nil)
-
-	jsonBody, _ := json.Marshal(reqBody)
-	req, _ := http.NewRequest("DELETE", "/platforms/1", bytes.NewBuffer(jsonBody))
-	req.Header.Set("Content-Type", "application/json")
+	req, _ := http.NewRequest("POST", "/platforms/1/verify", nil)
 	req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response domain.VerificationReport
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.True(t, response.OK)
+	assert.Equal(t, "bot_token", response.Checks[0].Name)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestVerifyPlatform_CredentialsChanged(t *testing.T) {
+	router, mockRepo, app := setupTest()
+	report := domain.VerificationReport{OK: true}
+	app.Verifier = &fakeVerifier{report: report}
 
+	mockRepo.On("GetPlatformByID", mock.Anything, 1, 1).Return(domain.Platform{ID_platform: 1, Name: "Telegram", Config_version: 2}, nil)
+	mockRepo.On("UpdatePlatformVerification", mock.Anything, 1, 2, report).Return(repository.ErrPlatformChanged)
+
+	req, _ := http.NewRequest("POST", "/platforms/1/verify", nil)
+	req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
 	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
 
-	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, http.StatusConflict, w.Code)
 	mockRepo.AssertExpectations(t)
 }
 
-func TestDeletePlatform_NotFound(t *testing.T) {
-	router, mockRepo, _ := setupTest()
+func TestVerifyPlatform_NotFound(t *testing.T) {
+	router, mockRepo, app := setupTest()
+	app.Verifier = &fakeVerifier{}
 
-	reqBody := dto.GetByUserIDRequest{
-		ID_user: "1",
-	}
+	mockRepo.On("GetPlatformByID", mock.Anything, 1, 1).Return(domain.Platform{}, errors.New("not found"))
 
-	mockRepo.On("GetPlatformByID", mock.Anything, 1, "1").Return(domain.Platform{}, errors.New("not found"))
+	req, _ := http.NewRequest("POST", "/platforms/1/verify", nil)
+	req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
 
-	jsonBody, _ := json.Marshal(reqBody)
-	req, _ := http.NewRequest("DELETE", "/platforms/1", bytes.NewBuffer(jsonBody))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	mockRepo.AssertNotCalled(t, "UpdatePlatformVerification")
+}
+
+func TestCreatePlatform_RunsVerification(t *testing.T) {
+	router, mockRepo, app := setupTest()
+	report := domain.VerificationReport{OK: false, AuthFailed: true}
+	app.Verifier = &fakeVerifier{report: report}
+
+	mockRepo.On("CreatePlatform", mock.Anything, mock.AnythingOfType("dto.CreatePlatformRequest")).Return(7, time.Now(), nil)
+	stored := make(chan struct{})
+	mockRepo.On("UpdatePlatformVerification", mock.Anything, 7, 0, report).Return(nil).
+		Run(func(mock.Arguments) { close(stored) })
+
+	jsonBody, _ := json.Marshal(dto.CreatePlatformRequest{
+		PlatformName: "VK",
+		VK:           &domain.VKConfig{AccessToken: "token", OwnerID: "-1"},
+	})
+	req, _ := http.NewRequest("POST", "/platforms", bytes.NewBuffer(jsonBody))
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "verification")
+	// отчёт сохраняется в фоне
+	select {
+	case <-stored:
+	case <-time.After(time.Second):
+		t.Fatal("verification report is not stored")
+	}
+	mockRepo.AssertExpectations(t)
+}
+
+// Тесты для паузы
+func TestPausePlatform_Success(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+	mockRepo.On("GetPlatformByID", mock.Anything, 1, 1).Return(domain.Platform{ID_platform: 1, Name: "Telegram", Is_active: true}, nil)
+	mockRepo.On("PausePlatforms", mock.Anything, 1, 1).Return(dto.PauseResponce{Platforms: []int{1}, Held: 2}, nil)
 
+	req, _ := http.NewRequest("POST", "/platforms/1/pause", nil)
+	req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
 	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
 
-	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response dto.PauseResponce
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, []int{1}, response.Platforms)
+	assert.Equal(t, int64(2), response.Held)
 	mockRepo.AssertExpectations(t)
-	mockRepo.AssertNotCalled(t, "DeletePlatformByID")
 }
 
-func TestDeletePlatform_InvalidID(t *testing.T) {
+func TestPausePlatform_AlreadyPaused(t *testing.T) {
 	router, mockRepo, _ := setupTest()
+	mockRepo.On("GetPlatformByID", mock.Anything, 1, 1).Return(domain.Platform{ID_platform: 1, Name: "Telegram"}, nil)
+	mockRepo.On("PausePlatforms", mock.Anything, 1, 1).Return(dto.PauseResponce{Platforms: []int{}}, nil)
 
-	reqBody := dto.GetByUserIDRequest{
-		ID_user: "1",
-	}
+	req, _ := http.NewRequest("POST", "/platforms/1/pause", nil)
+	req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
 
-	jsonBody, _ := json.Marshal(reqBody)
-	req, _ := http.NewRequest("DELETE", "/platforms/invalid", bytes.NewBuffer(jsonBody))
+	assert.Equal(t, http.StatusConflict, w.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestResumePlatform_Shift(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+	mockRepo.On("GetPlatformByID", mock.Anything, 1, 1).Return(domain.Platform{ID_platform: 1, Name: "Telegram"}, nil)
+	mockRepo.On("ResumePlatforms", mock.Anything, 1, 1, domain.ResumeShift).Return(
+		dto.ResumeResponce{Platforms: []int{1}, Overdue: domain.ResumeShift, OverdueHeld: 1, Released: 3}, nil)
+
+	body, _ := json.Marshal(dto.ResumeRequest{Overdue: domain.ResumeShift})
+	req, _ := http.NewRequest("POST", "/platforms/1/resume", bytes.NewBuffer(body))
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response dto.ResumeResponce
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, int64(3), response.Released)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestResumeAccount_InvalidOverdue(t *testing.T) {
+	router, mockRepo, _ := setupTest()
 
+	body, _ := json.Marshal(dto.ResumeRequest{Overdue: "later"})
+	req, _ := http.NewRequest("POST", "/account/resume", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
 	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
 
 	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockRepo.AssertNotCalled(t, "ResumePlatforms")
+}
 
-	var response map[string]interface{}
-	err := json.Unmarshal(w.Body.Bytes(), &response)
-	assert.NoError(t, err)
-	assert.Equal(t, "invalid id", response["error"])
+// Тесты для /me
+func TestGetMe_Success(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+	email := "user@example.com"
+	mockRepo.On("GetUserByID", mock.Anything, "1").Return(domain.User{ID_user: "1", Email: &email, Timezone: "UTC"}, nil)
 
-	mockRepo.AssertNotCalled(t, "GetPlatformByID")
-	mockRepo.AssertNotCalled(t, "DeletePlatformByID")
-}
+	req, _ := http.NewRequest("GET", "/me", nil)
+	req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
 
-// Тест для NoRoute
-func TestNoRoute(t *testing.T) {
-	router, _, _ := setupTest()
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response domain.User
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "1", response.ID_user)
+	assert.Equal(t, email, *response.Email)
+	mockRepo.AssertExpectations(t)
+}
 
-	req, _ := http.NewRequest("GET", "/nonexistent", nil)
+func TestPatchMe_Success(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+	timezone := "Europe/Moscow"
+	expected := dto.PatchMeRequest{ID_user: "1", Timezone: &timezone}
+	mockRepo.On("UpdateUser", mock.Anything, expected).Return(domain.User{ID_user: "1", Timezone: timezone}, nil)
 
+	req, _ := http.NewRequest("PATCH", "/me", bytes.NewBufferString(`{"timezone":"Europe/Moscow"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
 	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
 
-	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response domain.User
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, timezone, response.Timezone)
+	mockRepo.AssertExpectations(t)
+}
 
-	var response map[string]interface{}
-	err := json.Unmarshal(w.Body.Bytes(), &response)
+func TestPatchMe_InvalidTimezone(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+
+	req, _ := http.NewRequest("PATCH", "/me", bytes.NewBufferString(`{"timezone":"Mars/Olympus"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockRepo.AssertNotCalled(t, "UpdateUser")
+}
+
+// Тесты для привязки провайдеров входа
+// useFauxProvider включает тестовый провайдер и хранилище сессий gothic
+func useFauxProvider(t *testing.T) {
+	goth.UseProviders(&faux.Provider{})
+	store := gothic.Store
+	gothic.Store = sessions.NewCookieStore([]byte("test session key"))
+	t.Cleanup(func() {
+		goth.ClearProviders()
+		gothic.Store = store
+	})
+}
+
+func TestLinkIdentity_ReturnsLinkURL(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+	goth.UseProviders(&faux.Provider{})
+	defer goth.ClearProviders()
+	var stored string
+	mockRepo.On("CreateAuthToken", mock.Anything, "1", domain.TokenLinkIdentity, mock.AnythingOfType("string"), mock.AnythingOfType("time.Time")).Run(func(args mock.Arguments) {
+		stored = args.String(3)
+		assert.WithinDuration(t, time.Now().Add(auth.LinkTTL), args.Get(4).(time.Time), time.Minute)
+	}).Return(nil)
+
+	req, _ := http.NewRequest("POST", "/me/identities/faux", nil)
+	req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response map[string]string
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	link, err := url.Parse(response["url"])
 	assert.NoError(t, err)
-	assert.Equal(t, "404 Not Found", response["error"])
-	assert.Equal(t, "Not Found", response["message"])
-	assert.Equal(t, "/nonexistent", response["path"])
-	assert.Equal(t, "GET", response["method"])
+	assert.Equal(t, "/auth/faux", link.Path)
+	// в ссылке одноразовый токен, в БД только его хэш
+	assert.Equal(t, auth.HashToken(link.Query().Get("link_token")), stored)
 }
 
-func stringPtr(s string) *string {
-	return &s
+func TestLinkIdentity_BeginAndCallback(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+	useFauxProvider(t)
+	mockRepo.On("UseAuthToken", mock.Anything, domain.TokenLinkIdentity, auth.HashToken("from-api")).Return("1", nil).Once()
+	var state string
+	mockRepo.On("CreateAuthToken", mock.Anything, "1", domain.TokenLinkIdentity, mock.AnythingOfType("string"), mock.AnythingOfType("time.Time")).Run(func(args mock.Arguments) {
+		state = args.String(3)
+	}).Return(nil)
+
+	req, _ := http.NewRequest("GET", "/auth/faux?link_token=from-api", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusTemporaryRedirect, w.Code)
+	location, err := url.Parse(w.Header().Get("Location"))
+	assert.NoError(t, err)
+	callbackState := location.Query().Get("state")
+	assert.Equal(t, state, auth.HashToken(callbackState), "state carries a fresh one time token")
+	assert.NotContains(t, location.String(), "from-api")
+
+	mockRepo.On("UseAuthToken", mock.Anything, domain.TokenLinkIdentity, state).Return("1", nil).Once()
+	mockRepo.On("LinkIdentity", mock.Anything, "1", mock.MatchedBy(func(identity domain.Identity) bool {
+		return identity.Provider == "faux"
+	})).Return(nil)
+	req, _ = http.NewRequest("GET", "/auth/faux/callback?code=abc&state="+url.QueryEscape(callbackState), nil)
+	for _, c := range w.Result().Cookies() {
+		req.AddCookie(c)
+	}
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"linked":"faux"}`, w.Body.String())
+	mockRepo.AssertExpectations(t)
+}
+
+func TestLinkIdentity_UsedLinkToken(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+	goth.UseProviders(&faux.Provider{})
+	defer goth.ClearProviders()
+	mockRepo.On("UseAuthToken", mock.Anything, domain.TokenLinkIdentity, auth.HashToken("used")).Return("", repository.ErrAuthTokenInvalid)
+
+	req, _ := http.NewRequest("GET", "/auth/faux?link_token=used", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	mockRepo.AssertNotCalled(t, "CreateAuthToken", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestBeginAuth_IgnoresClientState(t *testing.T) {
+	router, _, _ := setupTest()
+	useFauxProvider(t)
+
+	req, _ := http.NewRequest("GET", "/auth/faux?state=planted", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusTemporaryRedirect, w.Code)
+	location, err := url.Parse(w.Header().Get("Location"))
+	assert.NoError(t, err)
+	assert.NotEmpty(t, location.Query().Get("state"))
+	assert.NotEqual(t, "planted", location.Query().Get("state"))
+}
+
+func TestLinkIdentity_UnknownProvider(t *testing.T) {
+	router, _, _ := setupTest()
+
+	req, _ := http.NewRequest("POST", "/me/identities/myspace", nil)
+	req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestUnlinkIdentity_LastIdentity(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+	mockRepo.On("UnlinkIdentity", mock.Anything, "1", "google").Return(false, repository.ErrLastIdentity)
+
+	req, _ := http.NewRequest("DELETE", "/me/identities/google", nil)
+	req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+// Тесты для входа по email/паролю
+func TestRegister_SendsVerificationEmail(t *testing.T) {
+	router, mockRepo, app := setupTest()
+	mail := &fakeMailer{}
+	app.Mailer = mail
+	app.PublicURL = "http://localhost:8080"
+	email := "user@example.com"
+	mockRepo.On("CreateLocalUser", mock.Anything, email, "User", mock.AnythingOfType("string")).Return(domain.User{ID_user: "u-1", Email: &email}, nil)
+	mockRepo.On("CreateAuthToken", mock.Anything, "u-1", domain.TokenVerifyEmail, mock.AnythingOfType("string"), mock.AnythingOfType("time.Time")).Return(nil)
+
+	body := `{"email":"User@Example.com","password":"long enough password","name":"User"}`
+	req, _ := http.NewRequest("POST", "/auth/register", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Empty(t, w.Body.String())
+	sent := mail.waitSent(t, 1)
+	assert.Len(t, sent, 1)
+	assert.Equal(t, email, sent[0].To)
+	assert.Contains(t, sent[0].Body, "http://localhost:8080/auth/verify-email?token=")
+	// в БД уходит только хэш пароля
+	hash := mockRepo.Calls[0].Arguments.String(3)
+	assert.True(t, strings.HasPrefix(hash, "$argon2id$"))
+	mockRepo.AssertExpectations(t)
+}
+
+func TestRegister_EmailTaken(t *testing.T) {
+	router, mockRepo, app := setupTest()
+	mail := &fakeMailer{}
+	app.Mailer = mail
+	mockRepo.On("CreateLocalUser", mock.Anything, "user@example.com", "", mock.AnythingOfType("string")).Return(domain.User{}, repository.ErrEmailTaken)
+
+	body := `{"email":"user@example.com","password":"long enough password"}`
+	req, _ := http.NewRequest("POST", "/auth/register", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// тот же ответ, что и для нового email
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Empty(t, w.Body.String())
+	assert.Empty(t, mail.sent)
+	mockRepo.AssertExpectations(t)
+}
+
+func localCredentials(t *testing.T, password string, verified bool) domain.LocalCredentials {
+	hash, err := auth.HashPassword(password)
+	assert.NoError(t, err)
+	return domain.LocalCredentials{ID_user: "u-1", Email: "user@example.com", Password_hash: hash, Email_verified: verified}
+}
+
+func TestLogin_Success(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+	mockRepo.On("GetLocalCredentials", mock.Anything, "user@example.com").Return(localCredentials(t, "secret password", true), nil)
+	mockRepo.On("ResetLoginFailures", mock.Anything, "u-1").Return(nil)
+	mockRepo.On("CreateSession", mock.Anything, mock.AnythingOfType("domain.Session"), mock.AnythingOfType("domain.RefreshToken")).Return(nil)
+
+	body := `{"email":"user@example.com","password":"secret password"}`
+	req, _ := http.NewRequest("POST", "/auth/login", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response map[string]string
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.NotEmpty(t, response["access_token"])
+	assert.NotEmpty(t, refreshCookies(w)["/auth"].Value)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestLogin_WrongPasswordLocksAccount(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+	lockedUntil := time.Now().Add(15 * time.Minute)
+	mockRepo.On("GetLocalCredentials", mock.Anything, "user@example.com").Return(localCredentials(t, "secret password", true), nil)
+	mockRepo.On("RecordLoginFailure", mock.Anything, "u-1", 5, 15*time.Minute).Return(&lockedUntil, nil)
+
+	body := `{"email":"user@example.com","password":"guess"}`
+	req, _ := http.NewRequest("POST", "/auth/login", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+	mockRepo.AssertExpectations(t)
+}
+
+func TestLogin_Locked(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+	creds := localCredentials(t, "secret password", true)
+	lockedUntil := time.Now().Add(time.Minute)
+	creds.Locked_until = &lockedUntil
+	mockRepo.On("GetLocalCredentials", mock.Anything, "user@example.com").Return(creds, nil)
+
+	body := `{"email":"user@example.com","password":"secret password"}`
+	req, _ := http.NewRequest("POST", "/auth/login", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	mockRepo.AssertNotCalled(t, "CreateSession")
+}
+
+func TestLogin_EmailNotVerified(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+	mockRepo.On("GetLocalCredentials", mock.Anything, "user@example.com").Return(localCredentials(t, "secret password", false), nil)
+
+	body := `{"email":"user@example.com","password":"secret password"}`
+	req, _ := http.NewRequest("POST", "/auth/login", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	mockRepo.AssertNotCalled(t, "CreateSession")
+}
+
+func TestLogin_UnknownEmail(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+	mockRepo.On("GetLocalCredentials", mock.Anything, "nobody@example.com").Return(domain.LocalCredentials{}, pgx.ErrNoRows)
+
+	body := `{"email":"nobody@example.com","password":"secret password"}`
+	req, _ := http.NewRequest("POST", "/auth/login", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestForgotPassword_UnknownEmail(t *testing.T) {
+	router, mockRepo, app := setupTest()
+	mail := &fakeMailer{}
+	app.Mailer = mail
+	mockRepo.On("GetLocalCredentials", mock.Anything, "nobody@example.com").Return(domain.LocalCredentials{}, pgx.ErrNoRows)
+
+	req, _ := http.NewRequest("POST", "/auth/password/forgot", bytes.NewBufferString(`{"email":"nobody@example.com"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Empty(t, mail.sent)
+}
+
+func TestForgotPassword_SendsInBackground(t *testing.T) {
+	router, mockRepo, app := setupTest()
+	mail := &fakeMailer{}
+	app.Mailer = mail
+	created := make(chan struct{})
+	mockRepo.On("GetLocalCredentials", mock.Anything, "user@example.com").Return(localCredentials(t, "secret password", true), nil)
+	mockRepo.On("CreateAuthToken", mock.Anything, "u-1", domain.TokenResetPassword, mock.AnythingOfType("string"), mock.AnythingOfType("time.Time")).Return(nil).
+		Run(func(mock.Arguments) { <-created })
+
+	req, _ := http.NewRequest("POST", "/auth/password/forgot", bytes.NewBufferString(`{"email":"user@example.com"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// ответ не ждёт создания токена и отправки
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	close(created)
+	sent := mail.waitSent(t, 1)
+	assert.Equal(t, "Сброс пароля", sent[0].Subject)
+}
+
+func TestResetPassword_InvalidToken(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+	mockRepo.On("ResetPassword", mock.Anything, auth.HashToken("bad-token"), mock.AnythingOfType("string")).Return(repository.ErrAuthTokenInvalid)
+
+	body := `{"token":"bad-token","password":"new long password"}`
+	req, _ := http.NewRequest("POST", "/auth/password/reset", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+// Тесты для refresh токенов и сессий
+func TestRefreshTokens_Rotates(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+	tokens, err := testTokens.GenerateTokens("1", "session-1")
+	assert.NoError(t, err)
+	old := domain.RefreshToken{JTI: tokens.RefreshJTI, Hash: auth.HashToken(tokens.Refresh)}
+	mockRepo.On("RotateRefreshToken", mock.Anything, "session-1", "1", old, mock.AnythingOfType("domain.RefreshToken")).Return(0, nil)
+
+	req, _ := http.NewRequest("POST", "/auth/refresh", nil)
+	req.AddCookie(&http.Cookie{Name: "refresh_token", Value: tokens.Refresh})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	cookies := refreshCookies(w)
+	assert.Len(t, cookies, 2)
+	assert.NotEmpty(t, cookies["/auth"].Value)
+	assert.NotEqual(t, tokens.Refresh, cookies["/auth"].Value)
+	// cookie со старым путём стирается
+	assert.Equal(t, -1, cookies["/auth/refresh"].MaxAge)
+	mockRepo.AssertExpectations(t)
+}
+
+// refreshCookies — выставленные refresh cookie по путям
+func refreshCookies(w *httptest.ResponseRecorder) map[string]*http.Cookie {
+	res := map[string]*http.Cookie{}
+	for _, c := range w.Result().Cookies() {
+		if c.Name == "refresh_token" {
+			res[c.Path] = c
+		}
+	}
+	return res
+}
+
+func TestRefreshTokens_KeepsWorkspace(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+	tokens, err := testTokens.GenerateTokens("1", "session-1")
+	assert.NoError(t, err)
+	mockRepo.On("RotateRefreshToken", mock.Anything, "session-1", "1", mock.Anything, mock.Anything).Return(4, nil)
+
+	req, _ := http.NewRequest("POST", "/auth/refresh", nil)
+	req.AddCookie(&http.Cookie{Name: "refresh_token", Value: tokens.Refresh})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response map[string]string
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	claims, err := testTokens.ParseAccessToken(response["access_token"])
+	assert.NoError(t, err)
+	assert.Equal(t, "session-1", claims.SessionID)
+	assert.Equal(t, 4, claims.WorkspaceID)
+}
+
+func TestRefreshTokens_ReuseDetected(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+	tokens, err := testTokens.GenerateTokens("1", "session-1")
+	assert.NoError(t, err)
+	mockRepo.On("RotateRefreshToken", mock.Anything, "session-1", "1", mock.Anything, mock.Anything).Return(0, repository.ErrRefreshTokenReused)
+
+	req, _ := http.NewRequest("POST", "/auth/refresh", nil)
+	req.AddCookie(&http.Cookie{Name: "refresh_token", Value: tokens.Refresh})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	for _, path := range []string{"/auth", "/auth/refresh"} {
+		assert.Equal(t, -1, refreshCookies(w)[path].MaxAge)
+	}
+	mockRepo.AssertExpectations(t)
+}
+
+func TestLogout_RevokesSession(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+	tokens, err := testTokens.GenerateTokens("1", "session-1")
+	assert.NoError(t, err)
+	mockRepo.On("RevokeSession", mock.Anything, "session-1", "1").Return(true, nil)
+
+	req, _ := http.NewRequest("POST", "/auth/logout", nil)
+	req.AddCookie(&http.Cookie{Name: "refresh_token", Value: tokens.Refresh})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	for _, path := range []string{"/auth", "/auth/refresh"} {
+		assert.Equal(t, -1, refreshCookies(w)[path].MaxAge)
+	}
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetSessions_MarksCurrent(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+	tokens, err := testTokens.GenerateTokens("1", "session-2")
+	assert.NoError(t, err)
+	mockRepo.On("IsSessionActive", mock.Anything, "session-2", "1").Return(true, nil)
+	mockRepo.On("GetSessions", mock.Anything, "1").Return([]domain.Session{
+		{ID_session: "session-1", ID_user: "1"},
+		{ID_session: "session-2", ID_user: "1"},
+	}, nil)
+
+	req, _ := http.NewRequest("GET", "/auth/sessions", nil)
+	req.Header.Set("Authorization", "Bearer "+tokens.Access)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response []domain.Session
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.False(t, response[0].Current)
+	assert.True(t, response[1].Current)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestAuthMiddleware_RevokedSession(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+	tokens, err := testTokens.GenerateTokens("1", "session-1")
+	assert.NoError(t, err)
+	mockRepo.On("IsSessionActive", mock.Anything, "session-1", "1").Return(false, nil)
+
+	req, _ := http.NewRequest("GET", "/auth/sessions", nil)
+	req.Header.Set("Authorization", "Bearer "+tokens.Access)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	mockRepo.AssertNotCalled(t, "GetSessions", mock.Anything, mock.Anything)
+}
+
+func TestDeleteSession_NotFound(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+	mockRepo.On("RevokeSession", mock.Anything, "missing", "1").Return(false, nil)
+
+	req, _ := http.NewRequest("DELETE", "/auth/sessions/missing", nil)
+	req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+// Тесты для GetNotifications
+func TestGetNotifications_Success(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+	platformID := 1
+	notifications := []domain.Notification{
+		{
+			ID_notification: 1,
+			ID_user:         "1",
+			Kind:            domain.NotificationPlatformDeactivated,
+			Message:         "Telegram platform 1 was deactivated",
+			ID_platform:     &platformID,
+		},
+	}
+	mockRepo.On("GetNotifications", mock.Anything, "1").Return(notifications, nil)
+
+	req, _ := http.NewRequest("GET", "/notifications", nil)
+	req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response []domain.Notification
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Len(t, response, 1)
+	assert.Equal(t, domain.NotificationPlatformDeactivated, response[0].Kind)
+	mockRepo.AssertExpectations(t)
+}
+
+// Тесты для DeletePlatform
+func TestDeletePlatform_Success(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+
+	reqBody := dto.GetByUserIDRequest{
+		ID_user: "1",
+	}
+
+	existingPlatform := domain.Platform{
+		ID_platform: 1,
+		Name:        "Test Platform",
+	}
+
+	mockRepo.On("GetPlatformByID", mock.Anything, 1, 1).Return(existingPlatform, nil)
+	mockRepo.On("DeletePlatformByID", mock.Anything, 1, 1).Return(nil)
+
+	jsonBody, _ := json.Marshal(reqBody)
+	req, _ := http.NewRequest("DELETE", "/platforms/1", bytes.NewBuffer(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestDeletePlatform_NotFound(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+
+	reqBody := dto.GetByUserIDRequest{
+		ID_user: "1",
+	}
+
+	mockRepo.On("GetPlatformByID", mock.Anything, 1, 1).Return(domain.Platform{}, errors.New("not found"))
+
+	jsonBody, _ := json.Marshal(reqBody)
+	req, _ := http.NewRequest("DELETE", "/platforms/1", bytes.NewBuffer(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	mockRepo.AssertExpectations(t)
+	mockRepo.AssertNotCalled(t, "DeletePlatformByID")
+}
+
+func TestDeletePlatform_InvalidID(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+
+	reqBody := dto.GetByUserIDRequest{
+		ID_user: "1",
+	}
+
+	jsonBody, _ := json.Marshal(reqBody)
+	req, _ := http.NewRequest("DELETE", "/platforms/invalid", bytes.NewBuffer(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, "invalid id", response["error"])
+
+	mockRepo.AssertNotCalled(t, "GetPlatformByID")
+	mockRepo.AssertNotCalled(t, "DeletePlatformByID")
+}
+
+// Тест для NoRoute
+func TestNoRoute(t *testing.T) {
+	router, _, _ := setupTest()
+
+	req, _ := http.NewRequest("GET", "/nonexistent", nil)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, "404 Not Found", response["error"])
+	assert.Equal(t, "Not Found", response["message"])
+	assert.Equal(t, "/nonexistent", response["path"])
+	assert.Equal(t, "GET", response["method"])
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
+
+func TestGetJWKS(t *testing.T) {
+	router, _, _ := setupTest()
+
+	req, _ := http.NewRequest("GET", "/.well-known/jwks.json", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response auth.JWKS
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Len(t, response.Keys, 1)
+	assert.Equal(t, "test", response.Keys[0].Kid)
+	assert.Equal(t, "EdDSA", response.Keys[0].Alg)
+}
+
+// Тесты для API ключей
+func TestCreateApiKey_Success(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+	expiresAt := time.Now().Add(30 * 24 * time.Hour).UTC().Truncate(time.Second)
+	mockRepo.On("CreateApiKey", mock.Anything, mock.AnythingOfType("string"), mock.AnythingOfType("string"), mock.AnythingOfType("string"), mock.MatchedBy(func(req dto.CreateApiKeyRequest) bool {
+		return req.ID_user == "1" && req.Name == "ci" && len(req.Scopes) == 1 && req.Scopes[0] == domain.ScopePostsWrite && req.Expires_at.Equal(expiresAt)
+	})).Return(domain.ApiKey{ID_key: "key-1", Name: "ci", Scopes: []string{domain.ScopePostsWrite}}, nil)
+
+	body, _ := json.Marshal(map[string]interface{}{"name": "ci", "scopes": []string{"posts:write"}, "expires_at": expiresAt})
+	req, _ := http.NewRequest("POST", "/api-keys", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var response dto.CreateApiKeyResponce
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.True(t, strings.HasPrefix(response.Key, auth.ApiKeyPrefix))
+	assert.Equal(t, "key-1", response.Api_key.ID_key)
+
+	// в БД уходит хэш, а не сам ключ
+	call := mockRepo.Calls[0]
+	assert.Equal(t, auth.HashToken(response.Key), call.Arguments.String(3))
+	assert.True(t, strings.HasPrefix(response.Key, call.Arguments.String(2)))
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateApiKey_InvalidScope(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+
+	body := `{"name":"ci","scopes":["posts:delete"]}`
+	req, _ := http.NewRequest("POST", "/api-keys", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockRepo.AssertNotCalled(t, "CreateApiKey")
+}
+
+func TestCreateApiKey_ExpiredDate(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+
+	body, _ := json.Marshal(map[string]interface{}{"name": "ci", "scopes": []string{"posts:read"}, "expires_at": time.Now().Add(-time.Hour)})
+	req, _ := http.NewRequest("POST", "/api-keys", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockRepo.AssertNotCalled(t, "CreateApiKey")
+}
+
+func TestDeleteApiKey_NotFound(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+	mockRepo.On("RevokeApiKey", mock.Anything, "key-1", "1").Return(false, nil)
+
+	req, _ := http.NewRequest("DELETE", "/api-keys/key-1", nil)
+	req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestAuthMiddleware_ApiKey(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+	key := auth.ApiKeyPrefix + "test"
+	mockRepo.On("UseApiKey", mock.Anything, auth.HashToken(key)).Return(domain.ApiKey{ID_user: "1", Scopes: []string{domain.ScopePostsWrite}}, nil)
+
+	// posts:write включает posts:read
+	mockRepo.On("GetPost", mock.Anything, 1, dto.PostFilter{}).Return(dto.GetPostsResponce{}, nil)
+	req, _ := http.NewRequest("GET", "/posts", bytes.NewBufferString("{}"))
+	req.Header.Set("Authorization", "Bearer "+key)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// нет scope platforms:read
+	req, _ = http.NewRequest("GET", "/platforms", nil)
+	req.Header.Set("Authorization", "Bearer "+key)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// ключом нельзя создать другой ключ
+	req, _ = http.NewRequest("GET", "/api-keys", nil)
+	req.Header.Set("Authorization", "Bearer "+key)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	mockRepo.AssertNotCalled(t, "GetPlatform", mock.Anything, mock.Anything)
+	mockRepo.AssertNotCalled(t, "GetApiKeys", mock.Anything, mock.Anything)
+}
+
+func TestAuthMiddleware_RevokedApiKey(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+	key := auth.ApiKeyPrefix + "revoked"
+	mockRepo.On("UseApiKey", mock.Anything, auth.HashToken(key)).Return(domain.ApiKey{}, repository.ErrApiKeyInvalid)
+
+	req, _ := http.NewRequest("GET", "/posts", nil)
+	req.Header.Set("Authorization", "Bearer "+key)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+// Тесты для рабочих пространств
+func TestWorkspace_ViewerCannotCreatePost(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+	mockRepo.On("GetMemberRole", mock.Anything, 2, "1").Return(domain.RoleViewer, nil)
+
+	body := `{"title":"Post","content":"Content","sheduled_for":"2030-01-01T10:00:00Z"}`
+	req, _ := http.NewRequest("POST", "/posts", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
+	req.Header.Set("X-Workspace-ID", "2")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	mockRepo.AssertNotCalled(t, "CreatePost", mock.Anything, mock.Anything)
+}
+
+func TestWorkspace_NotMember(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+	mockRepo.On("GetMemberRole", mock.Anything, 5, "1").Return("", repository.ErrNotMember)
+
+	req, _ := http.NewRequest("GET", "/platforms", bytes.NewBufferString("{}"))
+	req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
+	req.Header.Set("X-Workspace-ID", "5")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	mockRepo.AssertNotCalled(t, "GetPlatform", mock.Anything, mock.Anything)
+}
+
+func TestWorkspace_TokenClaim(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+	mockRepo.On("IsSessionActive", mock.Anything, "session-1", "1").Return(true, nil)
+	mockRepo.On("GetMemberRole", mock.Anything, 3, "1").Return(domain.RoleEditor, nil)
+	mockRepo.On("GetPost", mock.Anything, 3, dto.PostFilter{}).Return(dto.GetPostsResponce{}, nil)
+	token, err := testTokens.GenerateAccessToken("1", "session-1", 3)
+	assert.NoError(t, err)
+
+	req, _ := http.NewRequest("GET", "/posts", bytes.NewBufferString("{}"))
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockRepo.AssertNotCalled(t, "GetDefaultWorkspace", mock.Anything, mock.Anything)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestWorkspace_BodyUserIgnored(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+	mockRepo.On("CreatePost", mock.Anything, mock.MatchedBy(func(req dto.CreatePostRequest) bool {
+		return req.ID_user == "1" && req.ID_workspace == 1
+	})).Return(10, time.Now(), nil)
+
+	body := `{"id_user":"2","title":"Post","content":"Content","sheduled_for":"2030-01-01T10:00:00Z"}`
+	req, _ := http.NewRequest("POST", "/posts", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestSwitchWorkspace(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+	mockRepo.On("IsSessionActive", mock.Anything, "session-1", "1").Return(true, nil)
+	mockRepo.On("GetMemberRole", mock.Anything, 4, "1").Return(domain.RoleViewer, nil)
+	mockRepo.On("SetSessionWorkspace", mock.Anything, "session-1", "1", 4).Return(nil)
+	tokens, err := testTokens.GenerateTokens("1", "session-1")
+	assert.NoError(t, err)
+
+	req, _ := http.NewRequest("POST", "/workspaces/4/switch", nil)
+	req.Header.Set("Authorization", "Bearer "+tokens.Access)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response map[string]string
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	claims, err := testTokens.ParseAccessToken(response["access_token"])
+	assert.NoError(t, err)
+	assert.Equal(t, 4, claims.WorkspaceID)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestInviteMember_AdminCannotInviteOwner(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+	mockRepo.On("GetMemberRole", mock.Anything, 2, "1").Return(domain.RoleAdmin, nil)
+
+	body := `{"email":"new@example.com","role":"owner"}`
+	req, _ := http.NewRequest("POST", "/workspaces/2/invitations", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	mockRepo.AssertNotCalled(t, "CreateInvitation", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestInviteMember_SendsEmail(t *testing.T) {
+	router, mockRepo, app := setupTest()
+	mail := &fakeMailer{}
+	app.Mailer = mail
+	mockRepo.On("GetMemberRole", mock.Anything, 2, "1").Return(domain.RoleAdmin, nil)
+	mockRepo.On("CreateInvitation", mock.Anything, mock.MatchedBy(func(inv domain.Invitation) bool {
+		return inv.ID_workspace == 2 && inv.Email == "new@example.com" && inv.Role == domain.RoleEditor && inv.Invited_by == "1"
+	}), mock.AnythingOfType("string")).Return(nil)
+
+	body := `{"email":"New@Example.com","role":"editor"}`
+	req, _ := http.NewRequest("POST", "/workspaces/2/invitations", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Len(t, mail.sent, 1)
+	assert.Equal(t, "new@example.com", mail.sent[0].To)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestUpdateMember_LastOwner(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+	mockRepo.On("UpdateMemberRole", mock.Anything, 1, "1", domain.RoleAdmin).Return(false, repository.ErrLastOwner)
+
+	body := `{"role":"admin"}`
+	req, _ := http.NewRequest("PATCH", "/workspaces/1/members/1", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestRemoveMember_AdminCannotRemoveOwner(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+	mockRepo.On("GetMemberRole", mock.Anything, 2, "1").Return(domain.RoleAdmin, nil)
+	mockRepo.On("GetMemberRole", mock.Anything, 2, "owner-user").Return(domain.RoleOwner, nil)
+
+	req, _ := http.NewRequest("DELETE", "/workspaces/2/members/owner-user", nil)
+	req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	mockRepo.AssertNotCalled(t, "RemoveMember", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestAcceptInvitation_Invalid(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+	mockRepo.On("AcceptInvitation", mock.Anything, auth.HashToken("expired"), "1").Return(domain.Workspace{}, repository.ErrInvitationInvalid)
+
+	body := `{"token":"expired"}`
+	req, _ := http.NewRequest("POST", "/workspaces/invitations/accept", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestAcceptInvitation_OtherEmail(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+	mockRepo.On("AcceptInvitation", mock.Anything, auth.HashToken("forwarded"), "1").Return(domain.Workspace{}, repository.ErrInvitationEmail)
+
+	body := `{"token":"forwarded"}`
+	req, _ := http.NewRequest("POST", "/workspaces/invitations/accept", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreatePost_Draft(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+	mockRepo.On("CreatePost", mock.Anything, mock.MatchedBy(func(req dto.CreatePostRequest) bool {
+		return req.Status == domain.PostDraft && req.Sheduled_for.IsZero() && req.ID_workspace == 1
+	})).Return(7, time.Now(), nil)
+
+	body := `{"title":"Draft post","content":"text","draft":true}`
+	req, _ := http.NewRequest("POST", "/posts", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestSubmitPost_NotDraft(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+	mockRepo.On("SubmitPost", mock.Anything, 5, 1, "1").Return("", repository.ErrInvalidTransition)
+
+	req, _ := http.NewRequest("POST", "/posts/5/submit", nil)
+	req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestApprovePost_Success(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+	mockRepo.On("ReviewPost", mock.Anything, 1, domain.PostReview{
+		ID_post: 5,
+		ID_user: "1",
+		Action:  domain.ReviewApproved,
+	}).Return(domain.PostScheduled, nil)
+
+	req, _ := http.NewRequest("POST", "/posts/5/approve", bytes.NewBufferString("{}"))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response dto.PostStatusResponce
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, domain.PostScheduled, response.Status)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestApprovePost_EditorForbidden(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+	mockRepo.On("GetDefaultWorkspace", mock.Anything, "editor").Return(3, nil)
+	mockRepo.On("GetMemberRole", mock.Anything, 3, "editor").Return(domain.RoleEditor, nil)
+
+	req, _ := http.NewRequest("POST", "/posts/5/approve", bytes.NewBufferString("{}"))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+generateTestToken("editor"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	mockRepo.AssertNotCalled(t, "ReviewPost", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestApprovePost_OwnPost(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+	mockRepo.On("ReviewPost", mock.Anything, 1, mock.Anything).Return("", repository.ErrSelfReview)
+
+	req, _ := http.NewRequest("POST", "/posts/5/approve", bytes.NewBufferString("{}"))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestRejectPost_RequiresComment(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+
+	req, _ := http.NewRequest("POST", "/posts/5/reject", bytes.NewBufferString("{}"))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockRepo.AssertNotCalled(t, "ReviewPost", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestRejectPost_Success(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+	mockRepo.On("ReviewPost", mock.Anything, 1, domain.PostReview{
+		ID_post: 5,
+		ID_user: "1",
+		Action:  domain.ReviewRejected,
+		Comment: "fix the title",
+	}).Return(domain.PostDraft, nil)
+
+	req, _ := http.NewRequest("POST", "/posts/5/reject", bytes.NewBufferString(`{"comment":"fix the title"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestUpdateWorkspaceApprovals_Missing(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+	mockRepo.On("GetMemberRole", mock.Anything, 2, "1").Return(domain.RoleOwner, nil)
+
+	req, _ := http.NewRequest("PUT", "/workspaces/2/approvals", bytes.NewBufferString(`{"required_approvals":null}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockRepo.AssertNotCalled(t, "UpdateWorkspaceApprovals", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestUpdateWorkspaceApprovals_TooFewReviewers(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+	mockRepo.On("GetMemberRole", mock.Anything, 2, "1").Return(domain.RoleOwner, nil)
+	mockRepo.On("UpdateWorkspaceApprovals", mock.Anything, 2, 1).Return(repository.ErrTooFewReviewers)
+
+	req, _ := http.NewRequest("PUT", "/workspaces/2/approvals", bytes.NewBufferString(`{"required_approvals":1}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestUpdatePlatformApprovals_Inherit(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+	mockRepo.On("UpdatePlatformApprovals", mock.Anything, 4, 1, (*int)(nil)).Return(true, nil)
+
+	req, _ := http.NewRequest("PUT", "/platforms/4/approvals", bytes.NewBufferString(`{"required_approvals":null}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetPostRevisions_Success(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+	mockRepo.On("GetPostRevisions", mock.Anything, 5, 1).Return([]domain.PostRevision{
+		{ID_post: 5, Revision: 2, ID_user: "1", Title: "New", Changed_fields: []string{domain.FieldTitle}},
+		{ID_post: 5, Revision: 1, ID_user: "1", Title: "Old", Changed_fields: []string{domain.FieldTitle, domain.FieldContent}},
+	}, nil)
+
+	req, _ := http.NewRequest("GET", "/posts/5/revisions", nil)
+	req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response []domain.PostRevision
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Len(t, response, 2)
+	assert.Equal(t, 2, response[0].Revision)
+}
+
+func TestDiffPostRevisions_Success(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+	at := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	mockRepo.On("GetPostRevision", mock.Anything, 5, 1, 1).Return(domain.PostRevision{
+		ID_post: 5, Revision: 1, Title: "Title", Content: "one\ntwo", Sheduled_for: &at,
+	}, nil)
+	mockRepo.On("GetPostRevision", mock.Anything, 5, 1, 3).Return(domain.PostRevision{
+		ID_post: 5, Revision: 3, Title: "Title", Content: "one\nthree", Sheduled_for: &at,
+	}, nil)
+
+	req, _ := http.NewRequest("GET", "/posts/5/revisions/diff?from=1&to=3", nil)
+	req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response dto.RevisionDiffResponce
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, []string{domain.FieldContent}, response.Changed_fields)
+	assert.Equal(t, []textdiff.Line{
+		{Op: textdiff.OpEqual, Text: "one"},
+		{Op: textdiff.OpDelete, Text: "two"},
+		{Op: textdiff.OpInsert, Text: "three"},
+	}, response.Content)
+}
+
+func TestDiffPostRevisions_NotFound(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+	mockRepo.On("GetPostRevision", mock.Anything, 5, 1, 1).Return(domain.PostRevision{}, repository.ErrRevisionNotFound)
+
+	req, _ := http.NewRequest("GET", "/posts/5/revisions/diff?from=1&to=2", nil)
+	req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestDiffPostRevisions_MissingQuery(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+
+	req, _ := http.NewRequest("GET", "/posts/5/revisions/diff?from=1", nil)
+	req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockRepo.AssertNotCalled(t, "GetPostRevision", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestRestorePostRevision_Success(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+	mockRepo.On("RestorePostRevision", mock.Anything, 5, 1, 2, "1").Return(4, nil)
+
+	req, _ := http.NewRequest("POST", "/posts/5/revisions/2/restore", nil)
+	req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response dto.RestoreRevisionResponce
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, 4, response.Revision)
+}
+
+func TestCreateTemplate_NameTaken(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+	mockRepo.On("CreateTemplate", mock.Anything, dto.CreateTemplateRequest{
+		ID_user:      "1",
+		ID_workspace: 1,
+		Name:         "Meetup",
+		Title:        "{{event}}",
+		Content:      "See you on {{date}}",
+	}).Return(domain.PostTemplate{}, repository.ErrTemplateNameTaken)
+
+	body := `{"name":"Meetup","title":"{{event}}","content":"See you on {{date}}"}`
+	req, _ := http.NewRequest("POST", "/templates", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreatePostFromTemplate_Unfilled(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+	mockRepo.On("GetTemplateByID", mock.Anything, 3, 1).Return(domain.PostTemplate{
+		ID_template: 3,
+		Title:       "{{event}} in {{platform}}",
+		Content:     "Join: {{link}}",
+	}, nil)
+	mockRepo.On("GetPlatform", mock.Anything, 1).Return(dto.GetPlatformResponce{Platfroms: []domain.Platform{
+		{ID_platform: 1, Name: domain.PlatformTelegram},
+		{ID_platform: 2, Name: domain.PlatformVK},
+	}}, nil)
+
+	body := `{"values":{"event":"Meetup"},"platform_values":{"Telegram":{"link":"https://t.me/meetup"}}}`
+	req, _ := http.NewRequest("POST", "/templates/3/posts", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	var response dto.UnfilledTemplateResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, map[string][]string{domain.PlatformVK: {"link"}}, response.Missing)
+	mockRepo.AssertNotCalled(t, "CreatePost", mock.Anything, mock.Anything)
+}
+
+func TestCreatePostFromTemplate_Success(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+	mockRepo.On("GetTemplateByID", mock.Anything, 3, 1).Return(domain.PostTemplate{
+		ID_template: 3,
+		Title:       "{{event}} in {{platform}}",
+		Content:     "Join: {{link}}",
+	}, nil)
+	mockRepo.On("GetPlatform", mock.Anything, 1).Return(dto.GetPlatformResponce{Platfroms: []domain.Platform{
+		{ID_platform: 1, Name: domain.PlatformTelegram},
+		{ID_platform: 2, Name: domain.PlatformVK},
+	}}, nil)
+	mockRepo.On("CreatePost", mock.Anything, mock.MatchedBy(func(req dto.CreatePostRequest) bool {
+		// общая переменная подставлена, переменные платформы ждут публикации
+		return req.Title == "Meetup in {{platform}}" &&
+			req.Content == "Join: {{link}}" &&
+			req.ID_template == 3 &&
+			req.Status == domain.PostDraft &&
+			req.Variables != nil &&
+			req.Variables.For(domain.PlatformVK)["link"] == "https://vk.com/meetup"
+	})).Return(10, time.Now(), nil)
+
+	body := `{"values":{"event":"Meetup","link":"https://vk.com/meetup"},"platform_values":{"Telegram":{"link":"https://t.me/meetup"}},"draft":true}`
+	req, _ := http.NewRequest("POST", "/templates/3/posts", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreatePostFromTemplate_ValueWithPlaceholder(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+	mockRepo.On("GetTemplateByID", mock.Anything, 3, 1).Return(domain.PostTemplate{
+		ID_template: 3,
+		Title:       "{{event}}",
+		Content:     "{{note}}",
+	}, nil)
+	mockRepo.On("GetPlatform", mock.Anything, 1).Return(dto.GetPlatformResponce{}, nil)
+	mockRepo.On("CreatePost", mock.Anything, mock.MatchedBy(func(req dto.CreatePostRequest) bool {
+		// значение с {{…}} подставится при публикации как текст
+		return req.Title == "Meetup" && req.Content == "{{note}}"
+	})).Return(10, time.Now(), nil)
+
+	body := `{"values":{"event":"Meetup","note":"write {{name}} in the form"},"draft":true}`
+	req, _ := http.NewRequest("POST", "/templates/3/posts", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestDeleteTemplate_NotFound(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+	mockRepo.On("DeleteTemplate", mock.Anything, 9, 1).Return(false, nil)
+
+	req, _ := http.NewRequest("DELETE", "/templates/9", nil)
+	req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+var importPlatforms = dto.GetPlatformResponce{Platfroms: []domain.Platform{
+	{ID_platform: 1, Name: domain.PlatformTelegram},
+	{ID_platform: 2, Name: domain.PlatformVK},
+}}
+
+func importRequest(query string, contentType string, body string) *http.Request {
+	req, _ := http.NewRequest("POST", "/posts/import"+query, strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
+	return req
+}
+
+func TestImportPosts_DryRun(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+	mockRepo.On("GetPlatform", mock.Anything, 1).Return(importPlatforms, nil)
+
+	file := "title,content,scheduled_for,platforms,timezone\n" +
+		"Launch,Big news,2099-01-01 10:00,Telegram,Europe/Moscow\n" +
+		"Go,,tomorrow,Twitter,\n"
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, importRequest("?dry_run=true", "text/csv", file))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response dto.ImportPostsResponce
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.True(t, response.Dry_run)
+	assert.Equal(t, 2, response.Total)
+	assert.Equal(t, 1, response.Valid)
+	assert.Equal(t, 0, response.Created)
+	assert.Len(t, response.Errors, 1)
+	assert.Equal(t, 3, response.Errors[0].Line)
+	assert.Len(t, response.Errors[0].Errors, 4)
+	mockRepo.AssertNotCalled(t, "CreatePost", mock.Anything, mock.Anything)
+	mockRepo.AssertNotCalled(t, "CreatePosts", mock.Anything, mock.Anything)
+}
+
+func TestImportPosts_AllOrNothingRejectsInvalidFile(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+	mockRepo.On("GetPlatform", mock.Anything, 1).Return(importPlatforms, nil)
+
+	file := `{"title":"Launch","content":"Big news"}
+{"title":"Second","content":"text","platforms":["Twitter"]}`
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, importRequest("?all_or_nothing=true", "application/x-ndjson", file))
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	mockRepo.AssertNotCalled(t, "CreatePosts", mock.Anything, mock.Anything)
+	mockRepo.AssertNotCalled(t, "CreatePost", mock.Anything, mock.Anything)
+}
+
+func TestImportPosts_AllOrNothing(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+	mockRepo.On("GetPlatform", mock.Anything, 1).Return(importPlatforms, nil)
+	mockRepo.On("CreatePosts", mock.Anything, mock.MatchedBy(func(posts []dto.CreatePostRequest) bool {
+		// 10:00 по Москве — 07:00 UTC
+		return len(posts) == 2 &&
+			posts[0].ID_user == "1" && posts[0].ID_workspace == 1 &&
+			posts[0].Status == domain.PostInReview &&
+			len(posts[0].Platforms) == 1 && posts[0].Platforms[0] == 2 &&
+			posts[0].Sheduled_for.Equal(time.Date(2099, 1, 1, 7, 0, 0, 0, time.UTC)) &&
+			posts[1].Platforms == nil && posts[1].Sheduled_for.IsZero()
+	})).Return([]int{7, 8}, nil)
+
+	file := `{"title":"Launch","content":"Big news","scheduled_for":"2099-01-01 10:00","platforms":[2],"timezone":"Europe/Moscow"}
+{"title":"Second","content":"text"}`
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, importRequest("?all_or_nothing=true", "application/x-ndjson", file))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response dto.ImportPostsResponce
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, 2, response.Created)
+	assert.Equal(t, []int{7, 8}, response.Posts)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestImportPosts_StoresInstant(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+	mockRepo.On("GetPlatform", mock.Anything, 1).Return(importPlatforms, nil)
+	var stored []time.Time
+	mockRepo.On("CreatePosts", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		for _, post := range args.Get(1).([]dto.CreatePostRequest) {
+			stored = append(stored, post.Sheduled_for)
+		}
+	}).Return([]int{1, 2, 3}, nil)
+
+	file := "title,content,scheduled_for,timezone\n" +
+		"Row zone,text,2099-01-01 10:00,Europe/Moscow\n" +
+		"Offset,text,2099-01-01T10:00:00+05:00,\n" +
+		"Query zone,text,2099-01-01 10:00,\n"
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, importRequest("?all_or_nothing=true&timezone=Asia/Tokyo", "text/csv", file))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	if assert.Len(t, stored, 3) {
+		assert.True(t, stored[0].Equal(time.Date(2099, 1, 1, 7, 0, 0, 0, time.UTC)), stored[0])
+		assert.True(t, stored[1].Equal(time.Date(2099, 1, 1, 5, 0, 0, 0, time.UTC)), stored[1])
+		assert.True(t, stored[2].Equal(time.Date(2099, 1, 1, 1, 0, 0, 0, time.UTC)), stored[2])
+	}
+}
+
+func TestImportPosts_PartialImport(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+	mockRepo.On("GetPlatform", mock.Anything, 1).Return(importPlatforms, nil)
+	mockRepo.On("CreatePost", mock.Anything, mock.MatchedBy(func(req dto.CreatePostRequest) bool {
+		return req.Title == "Launch" && req.Status == domain.PostDraft
+	})).Return(5, time.Now(), nil)
+	mockRepo.On("CreatePost", mock.Anything, mock.MatchedBy(func(req dto.CreatePostRequest) bool {
+		return req.Title == "Broken"
+	})).Return(0, time.Time{}, errors.New("db down"))
+
+	file := "title,content\nLaunch,Big news\nGo,\nBroken,text\n"
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, importRequest("?format=csv&draft=true", "application/octet-stream", file))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response dto.ImportPostsResponce
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, 1, response.Created)
+	assert.Equal(t, 2, response.Failed)
+	assert.Equal(t, 3, response.Errors[0].Line)
+	assert.Equal(t, 4, response.Errors[1].Line)
+}
+
+func TestImportPosts_LargeFileRunsInBackground(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+	mockRepo.On("GetPlatform", mock.Anything, 1).Return(importPlatforms, nil)
+	mockRepo.On("CreateImportJob", mock.Anything, 1, mock.MatchedBy(func(job domain.ImportJob) bool {
+		return job.Status == domain.ImportPending && job.Total == 101
+	})).Return(nil)
+	mockRepo.On("CreatePost", mock.Anything, mock.Anything).Return(1, time.Now(), nil)
+	done := make(chan domain.ImportJob, 1)
+	mockRepo.On("UpdateImportJob", mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		if job := args.Get(1).(domain.ImportJob); job.Status == domain.ImportDone {
+			done <- job
+		}
+	})
+
+	var file strings.Builder
+	for i := 0; i < 101; i++ {
+		file.WriteString(`{"title":"Post title","content":"text"}` + "\n")
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, importRequest("", "application/x-ndjson", file.String()))
+
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	var job domain.ImportJob
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &job))
+	assert.Equal(t, "/posts/import/"+job.ID_job, w.Header().Get("Location"))
+
+	select {
+	case finished := <-done:
+		assert.Equal(t, 101, finished.Created)
+		assert.Equal(t, 0, finished.Failed)
+	case <-time.After(5 * time.Second):
+		t.Fatal("import job did not finish")
+	}
+}
+
+func TestImportPosts_UnknownFormat(t *testing.T) {
+	router, _, _ := setupTest()
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, importRequest("", "application/octet-stream", "title,content\n"))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestGetImportJob_NotFound(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+	mockRepo.On("GetImportJob", mock.Anything, "0b5c2a3e-8a9f-4c43-9a43-4d2f8f1c2b11", 1).Return(domain.ImportJob{}, repository.ErrImportJobNotFound)
+
+	for _, id := range []string{"0b5c2a3e-8a9f-4c43-9a43-4d2f8f1c2b11", "not-a-uuid"} {
+		req, _ := http.NewRequest("GET", "/posts/import/"+id, nil)
+		req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	}
+}
+
+func TestGetPosts_Filter(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+	from := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+	mockRepo.On("GetPost", mock.Anything, 1, mock.MatchedBy(func(filter dto.PostFilter) bool {
+		return filter.Status == "failed" && filter.ID_platform == 2 && filter.From.Equal(from) && filter.To.IsZero()
+	})).Return(dto.GetPostsResponce{}, nil)
+
+	req, _ := http.NewRequest("GET", "/posts?status=failed&platform_id=2&from=2026-11-01T00:00:00Z", bytes.NewBufferString("{}"))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetPosts_InvalidFilter(t *testing.T) {
+	router, _, _ := setupTest()
+	req, _ := http.NewRequest("GET", "/posts?status=lost", bytes.NewBufferString("{}"))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestExportPosts_CSV(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+	remote := "42"
+	mockRepo.On("ExportPosts", mock.Anything, 1, dto.PostFilter{Status: "published"}).Return([]domain.ExportRow{
+		{ID_post: 1, Title: "First", Content: "text", ID_destination: 3, Status: "published", Remote_id: &remote},
+		{ID_post: 2, Title: "Second", Content: "text", ID_destination: 4, Status: "published"},
+	}, nil)
+
+	req, _ := http.NewRequest("GET", "/posts/export?status=published", nil)
+	req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Header().Get("Content-Disposition"), ".csv")
+	assert.Equal(t, "complete", w.Header().Get("X-Export-Status"))
+	assert.Equal(t, "2", w.Header().Get("X-Export-Rows"))
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	assert.Len(t, lines, 3)
+	assert.True(t, strings.HasPrefix(lines[0], "post_id,user_id,title"))
+	assert.Contains(t, lines[1], ",42,")
+}
+
+func TestExportPosts_ErrorBeforeFirstChunk(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+	mockRepo.On("ExportPosts", mock.Anything, 1, dto.PostFilter{}).Return(nil, errors.New("replica is down"))
+
+	req, _ := http.NewRequest("GET", "/posts/export?format=ndjson", nil)
+	req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Empty(t, w.Header().Get("Content-Disposition"))
+	assert.Contains(t, w.Header().Get("Content-Type"), "application/json")
+}
+
+func TestExportPosts_UnknownFormat(t *testing.T) {
+	router, _, _ := setupTest()
+	req, _ := http.NewRequest("GET", "/posts/export?format=pdf", nil)
+	req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestCreateCalendarToken(t *testing.T) {
+	router, mockRepo, app := setupTest()
+	app.PublicURL = "http://localhost:8080"
+	var stored string
+	mockRepo.On("CreateCalendarToken", mock.Anything, "1", mock.AnythingOfType("string")).
+		Run(func(args mock.Arguments) { stored = args.String(2) }).Return(nil)
+
+	req, _ := http.NewRequest("POST", "/me/calendar", nil)
+	req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var res dto.CalendarTokenResponce
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	assert.NotEmpty(t, res.Token)
+	// хранится только хеш
+	assert.Equal(t, auth.HashToken(res.Token), stored)
+	assert.True(t, strings.HasPrefix(res.Url, "http://localhost:8080/calendar.ics?token="))
+}
+
+func TestDeleteCalendarToken_NotFound(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+	mockRepo.On("DeleteCalendarToken", mock.Anything, "1").Return(false, nil)
+
+	req, _ := http.NewRequest("DELETE", "/me/calendar", nil)
+	req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestGetCalendar_UnknownToken(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+	mockRepo.On("GetCalendarEvents", mock.Anything, auth.HashToken("wrong")).Return([]domain.CalendarEvent{}, repository.ErrCalendarNotFound)
+
+	for _, target := range []string{"/calendar.ics?token=wrong", "/calendar.ics"} {
+		req, _ := http.NewRequest("GET", target, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	}
+}
+
+func TestGetCalendar_Events(t *testing.T) {
+	router, mockRepo, app := setupTest()
+	app.PublicURL = "http://localhost:8080"
+	scheduled := time.Date(2026, 11, 2, 9, 0, 0, 0, time.UTC)
+	published := scheduled.Add(2 * time.Minute)
+	failure := "chat not found"
+	mockRepo.On("GetCalendarEvents", mock.Anything, auth.HashToken("secret")).Return([]domain.CalendarEvent{
+		{ID_destination: 1, ID_post: 10, Workspace_name: "Team", Title: "Launch", PlatformName: "Telegram", Status: "published", Sheduled_for: scheduled, Published_at: &published},
+		{ID_destination: 2, ID_post: 10, Workspace_name: "Team", Title: "Launch", PlatformName: "VK", Status: "failed", Sheduled_for: scheduled, ErrorMessage: &failure},
+		{ID_destination: 3, ID_post: 11, Workspace_name: "Team", Title: "Next", PlatformName: "VK", Status: "pending", Sheduled_for: scheduled},
+	}, nil)
+
+	req, _ := http.NewRequest("GET", "/calendar.ics?token=secret", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/calendar; charset=utf-8", w.Header().Get("Content-Type"))
+	body := w.Body.String()
+	assert.Equal(t, 3, strings.Count(body, "BEGIN:VEVENT"))
+	assert.Contains(t, body, "UID:destination-1@localhost:8080\r\n")
+	assert.Contains(t, body, "DTSTART:20261102T090200Z\r\n")
+	assert.Contains(t, body, "SUMMARY:[Telegram] Launch\r\n")
+	assert.Contains(t, body, "STATUS:CONFIRMED\r\n")
+	assert.Contains(t, body, "STATUS:CANCELLED\r\n")
+	assert.Contains(t, body, "STATUS:TENTATIVE\r\n")
+	assert.Contains(t, body, "CATEGORIES:VK,failed\r\n")
+	assert.Contains(t, body, "URL:http://localhost:8080/posts/11\r\n")
+}
+
+func TestCreateWebhook(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+	mockRepo.On("CreateWebhook", mock.Anything, 1, mock.MatchedBy(func(hook domain.Webhook) bool {
+		return hook.ID_user == "1" && hook.Url == "https://crm.example.com/hook" && hook.Scope == domain.WebhookScopeWorkspace &&
+			strings.HasPrefix(hook.Secret, "whsec_") && len(hook.Events) == 2
+	})).Return(domain.Webhook{ID_webhook: 3, Secret: "whsec_test"}, nil)
+
+	body := `{"url":"https://crm.example.com/hook","events":["destination.published","destination.failed"]}`
+	req, _ := http.NewRequest("POST", "/webhooks", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Contains(t, w.Body.String(), `"secret":"whsec_test"`)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateWebhook_Invalid(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+	for _, body := range []string{
+		`{"url":"https://crm.example.com/hook","events":["post.published"]}`,
+		`{"url":"https://crm.example.com/hook","events":[]}`,
+		`{"url":"ftp://crm.example.com/hook","events":["post.created"]}`,
+		`{"url":"https://crm.example.com/hook","events":["post.created"],"scope":"team"}`,
+	} {
+		req, _ := http.NewRequest("POST", "/webhooks", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+	}
+	mockRepo.AssertNotCalled(t, "CreateWebhook", mock.Anything, mock.Anything, mock.Anything)
+}
+
+// testResolver отвечает без DNS: *.internal — частная сеть, остальные имена — публичный адрес
+type testResolver struct{}
+
+func (testResolver) LookupNetIP(ctx context.Context, network string, host string) ([]netip.Addr, error) {
+	if strings.HasSuffix(host, ".internal") {
+		return []netip.Addr{netip.MustParseAddr("10.0.0.5")}, nil
+	}
+	return []netip.Addr{netip.MustParseAddr("93.184.216.34")}, nil
+}
+
+func TestCreateWebhook_InternalAddress(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+	for _, hookURL := range []string{
+		"http://127.0.0.1:8080/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"http://kafka.internal:9092/",
+		"http://[::1]/hook",
+	} {
+		body := `{"url":"` + hookURL + `","events":["post.created"]}`
+		req, _ := http.NewRequest("POST", "/webhooks", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, hookURL)
+	}
+	mockRepo.AssertNotCalled(t, "CreateWebhook", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestPatchWebhook_InternalAddress(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+
+	body := `{"url":"http://postgres.internal:5432/"}`
+	req, _ := http.NewRequest("PATCH", "/webhooks/3", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockRepo.AssertNotCalled(t, "UpdateWebhook", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestGetWebhooks_EditorForbidden(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+	mockRepo.On("GetDefaultWorkspace", mock.Anything, "editor").Return(3, nil)
+	mockRepo.On("GetMemberRole", mock.Anything, 3, "editor").Return(domain.RoleEditor, nil)
+
+	req, _ := http.NewRequest("GET", "/webhooks", nil)
+	req.Header.Set("Authorization", "Bearer "+generateTestToken("editor"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestRedeliverWebhook(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+	mockRepo.On("RedeliverWebhook", mock.Anything, 3, 10, 1).Return(domain.WebhookDelivery{ID_delivery: 11, ID_webhook: 3, Status: domain.DeliveryPending}, nil)
+	mockRepo.On("RedeliverWebhook", mock.Anything, 3, 12, 1).Return(domain.WebhookDelivery{}, repository.ErrDeliveryNotFound)
+
+	req, _ := http.NewRequest("POST", "/webhooks/3/deliveries/10/redeliver", nil)
+	req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Contains(t, w.Body.String(), `"id_delivery":11`)
+
+	req, _ = http.NewRequest("POST", "/webhooks/3/deliveries/12/redeliver", nil)
+	req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestCreatePost_EnqueuesWebhookEvent(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+	mockRepo.On("CreatePost", mock.Anything, mock.Anything).Return(7, time.Now(), nil)
+
+	req, _ := http.NewRequest("POST", "/posts", bytes.NewBufferString(`{"title":"Webhook","content":"text"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockRepo.AssertCalled(t, "EnqueueWebhookEvent", mock.Anything, mock.MatchedBy(func(event domain.WebhookEvent) bool {
+		data, ok := event.Data.(domain.PostEventData)
+		return ok && event.Event == domain.EventPostCreated && event.ID_workspace == 1 && event.ID_post == 7 &&
+			event.ID_author == "1" && data.Status == domain.PostInReview
+	}))
+}
+
+func TestGetEvents_Unavailable(t *testing.T) {
+	router, _, _ := setupTest()
+	req, _ := http.NewRequest("GET", "/events", nil)
+	req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
+
+func TestGetEvents_Stream(t *testing.T) {
+	router, mockRepo, app := setupTest()
+	app.Events = statusfeed.NewHub(8)
+	mockRepo.On("GetWorkspaces", mock.Anything, "1").Return([]domain.Workspace{{ID_workspace: 1}, {ID_workspace: 2}}, nil)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", server.URL+"/events", nil)
+	req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	lines := bufio.NewScanner(resp.Body)
+	// после retry клиент уже подписан
+	for lines.Scan() && !strings.HasPrefix(lines.Text(), "retry:") {
+	}
+	app.Events.Publish(domain.StatusEvent{ID_destination: 9, ID_workspace: 3, Status: "published"})
+	app.Events.Publish(domain.StatusEvent{ID_destination: 7, ID_workspace: 2, Status: "failed", Previous_status: "processing"})
+
+	var got []string
+	for lines.Scan() && len(got) < 2 {
+		if lines.Text() != "" {
+			got = append(got, lines.Text())
+		}
+	}
+	assert.Equal(t, "event:destination.status", got[0])
+	assert.Contains(t, got[1], `"id_destination":7`)
+	assert.Contains(t, got[1], `"previous_status":"processing"`)
+}
+
+func TestGetEvents_ClosedOnShutdown(t *testing.T) {
+	router, mockRepo, app := setupTest()
+	app.Events = statusfeed.NewHub(8)
+	streams, closeStreams := context.WithCancel(context.Background())
+	app.Streams = streams
+	mockRepo.On("GetWorkspaces", mock.Anything, "1").Return([]domain.Workspace{{ID_workspace: 1}}, nil)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", server.URL+"/events", nil)
+	req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	lines := bufio.NewScanner(resp.Body)
+	for lines.Scan() && !strings.HasPrefix(lines.Text(), "retry:") {
+	}
+	closeStreams()
+	// поток завершается сервером, а не таймаутом клиента
+	for lines.Scan() {
+	}
+	assert.NoError(t, lines.Err())
+	assert.NoError(t, ctx.Err())
+}
+
+func TestLinkTelegram(t *testing.T) {
+	router, mockRepo, app := setupTest()
+	app.TelegramBot = "schedule_bot"
+	var stored string
+	mockRepo.On("CreateAuthToken", mock.Anything, "1", domain.TokenLinkTelegram, mock.AnythingOfType("string"), mock.AnythingOfType("time.Time")).
+		Run(func(args mock.Arguments) { stored = args.String(3) }).Return(nil)
+
+	req, _ := http.NewRequest("POST", "/me/telegram", nil)
+	req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var res dto.TelegramLinkResponce
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	assert.Equal(t, auth.HashToken(res.Code), stored)
+	assert.Equal(t, "https://t.me/schedule_bot?start="+res.Code, res.Url)
+}
+
+func TestLinkTelegram_NotConfigured(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+
+	req, _ := http.NewRequest("POST", "/me/telegram", nil)
+	req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	mockRepo.AssertNotCalled(t, "CreateAuthToken", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestUnlinkTelegram_NotFound(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+	mockRepo.On("UnlinkTelegram", mock.Anything, "1").Return(false, nil)
+
+	req, _ := http.NewRequest("DELETE", "/me/telegram", nil)
+	req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestGetNotificationSettings(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+	mockRepo.On("GetNotificationSettings", mock.Anything, "1").Return(domain.DefaultNotificationSettings(), nil)
+
+	req, _ := http.NewRequest("GET", "/me/notification-settings", nil)
+	req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var res domain.NotificationSettings
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	assert.Equal(t, domain.DefaultNotificationSettings(), res)
+}
+
+func TestPutNotificationSettings(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+	expected := domain.NotificationSettings{
+		Telegram:    true,
+		Webhook:     true,
+		Kinds:       []string{domain.NotificationPublicationFailed, domain.NotificationPublicationStuck},
+		Delivery:    domain.NotifyDaily,
+		Digest_hour: 8,
+	}
+	mockRepo.On("UpdateNotificationSettings", mock.Anything, "1", expected).Return(expected, nil)
+
+	body := `{"email":false,"telegram":true,"webhook":true,"kinds":["publication_failed","publication_stuck"],"delivery":"daily","digest_hour":8}`
+	req, _ := http.NewRequest("PUT", "/me/notification-settings", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestPutNotificationSettings_Invalid(t *testing.T) {
+	router, mockRepo, _ := setupTest()
+
+	for _, body := range []string{
+		`{"delivery":"weekly"}`,
+		`{"delivery":"daily","digest_hour":24}`,
+		`{"delivery":"immediate","kinds":["post_created"]}`,
+		`{"delivery":"immediate","batch_minutes":90}`,
+	} {
+		req, _ := http.NewRequest("PUT", "/me/notification-settings", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+generateTestToken("1"))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+	}
+	mockRepo.AssertNotCalled(t, "UpdateNotificationSettings", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestHealthz(t *testing.T) {
+	router, _, _ := setupTest()
+
+	req, _ := http.NewRequest("GET", "/healthz", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"status":"up","checks":{}}`, w.Body.String())
+}
+
+func TestReadyz(t *testing.T) {
+	router, _, app := setupTest()
+	app.Readiness = health.NewChecker(time.Second)
+	replicaLag := errors.New("replica lag 45.0s exceeds 30s")
+	app.Readiness.Add("master", func(ctx context.Context) (map[string]any, error) { return nil, nil })
+	app.Readiness.Add("replica", func(ctx context.Context) (map[string]any, error) {
+		return map[string]any{"lag_seconds": 45.0}, replicaLag
+	})
+
+	req, _ := http.NewRequest("GET", "/readyz", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	var report health.Report
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.Equal(t, health.StatusDown, report.Status)
+	assert.Equal(t, health.StatusUp, report.Checks["master"].Status)
+	assert.Equal(t, replicaLag.Error(), report.Checks["replica"].Error)
+	assert.Equal(t, 45.0, report.Checks["replica"].Details["lag_seconds"])
+}
+
+func TestReadyz_Draining(t *testing.T) {
+	router, _, app := setupTest()
+	app.Readiness = health.NewChecker(time.Second)
+	app.Readiness.Add("master", func(ctx context.Context) (map[string]any, error) { return nil, nil })
+
+	req, _ := http.NewRequest("GET", "/readyz", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	app.Readiness.Drain()
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
 }
@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"hexlet/internal/dto"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetMe godoc
+// @Summary      Get current user
+// @Description  getting profile of the authorized user
+// @Tags         users
+// @Produce      json
+// @Success      200  {object}  domain.User
+// @Failure      404  {object}  dto.ErrorResponse
+// @Failure      500  {object}  dto.ErrorResponse
+// @Router       /me [get]
+func (a *App) GetMe(rw *gin.Context) {
+	val, exists := rw.Get("currentUserID")
+	if !exists {
+		rw.JSON(500, gin.H{"error": "User not found"})
+		return
+	}
+	user, err := a.Repo.GetUserByID(rw.Request.Context(), val.(string))
+	if err != nil {
+		rw.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+	rw.JSON(http.StatusOK, user)
+}
+
+// PatchMe godoc
+// @Summary      Update current user
+// @Description  updating name, avatar and timezone of the authorized user
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param        request body dto.PatchMeRequest true "profile fields to change"
+// @Success      200  {object}  domain.User
+// @Failure      400  {object}  dto.ErrorResponse
+// @Failure      404  {object}  dto.ErrorResponse
+// @Router       /me [patch]
+func (a *App) PatchMe(rw *gin.Context) {
+	val, exists := rw.Get("currentUserID")
+	if !exists {
+		rw.JSON(500, gin.H{"error": "User not found"})
+		return
+	}
+	var request dto.PatchMeRequest
+	if err := rw.ShouldBindJSON(&request); err != nil {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validate(&request); err != nil {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	request.ID_user = val.(string)
+	user, err := a.Repo.UpdateUser(rw.Request.Context(), request)
+	if err != nil {
+		rw.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+	rw.JSON(http.StatusOK, user)
+}
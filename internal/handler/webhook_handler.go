@@ -0,0 +1,243 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"hexlet/internal/domain"
+	"hexlet/internal/dto"
+	"hexlet/internal/repository"
+	"hexlet/internal/webhook"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateWebhook godoc
+// @Summary      Create webhook
+// @Description  subscribes a URL to workspace events: post.created, post.updated, post.deleted, post.approved, post.rejected, destination.published, destination.failed, notification.created.
+// @Description  Scope user limits events to posts of the subscriber and to notifications addressed to them; notification.created is sent only for users who enabled the webhook channel. Requests are POST with JSON body and headers X-Webhook-Event, X-Webhook-Id, X-Webhook-Timestamp and X-Webhook-Signature: sha256=hex(HMAC-SHA256(secret, timestamp + "." + body)).
+// @Description  Failed deliveries are retried with backoff (1m, 5m, 30m, 2h, 6h). The secret is shown only once.
+// @Description  The URL must resolve to public addresses only: loopback, private and link-local networks are rejected.
+// @Tags         webhooks
+// @Accept       json
+// @Produce      json
+// @Param        request body dto.CreateWebhookRequest true "webhook"
+// @Success      201  {object}  domain.Webhook
+// @Failure      400  {object}  dto.ErrorResponse
+// @Failure      500  {object}  dto.ErrorResponse
+// @Router       /webhooks [post]
+func (a *App) CreateWebhook(rw *gin.Context) {
+	var request dto.CreateWebhookRequest
+	if err := rw.ShouldBindJSON(&request); err != nil {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validate(&request); err != nil {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !a.checkWebhookURL(rw, request.Url) {
+		return
+	}
+	if request.Scope == "" {
+		request.Scope = domain.WebhookScopeWorkspace
+	}
+	secret, err := webhook.NewSecret()
+	if err != nil {
+		rw.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	hook, err := a.Repo.CreateWebhook(rw.Request.Context(), rw.GetInt("currentWorkspaceID"), domain.Webhook{
+		ID_user: rw.GetString("currentUserID"),
+		Url:     request.Url,
+		Events:  request.Events,
+		Scope:   request.Scope,
+		Secret:  secret,
+	})
+	if err != nil {
+		rw.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	rw.JSON(http.StatusCreated, hook)
+}
+
+// GetWebhooks godoc
+// @Summary      List webhooks
+// @Tags         webhooks
+// @Produce      json
+// @Success      200  {array}   domain.Webhook
+// @Failure      500  {object}  dto.ErrorResponse
+// @Router       /webhooks [get]
+func (a *App) GetWebhooks(rw *gin.Context) {
+	hooks, err := a.Repo.GetWebhooks(rw.Request.Context(), rw.GetInt("currentWorkspaceID"))
+	if err != nil {
+		rw.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	rw.JSON(http.StatusOK, hooks)
+}
+
+// PatchWebhook godoc
+// @Summary      Update webhook
+// @Description  changes url, events or is_active; omitted fields are kept. The URL is checked as on creation.
+// @Tags         webhooks
+// @Accept       json
+// @Produce      json
+// @Param        id path int true "Webhook ID"
+// @Param        request body dto.PatchWebhookRequest true "changes"
+// @Success      200  {object}  domain.Webhook
+// @Failure      400  {object}  dto.ErrorResponse
+// @Failure      404  {object}  dto.ErrorResponse
+// @Failure      500  {object}  dto.ErrorResponse
+// @Router       /webhooks/{id} [patch]
+func (a *App) PatchWebhook(rw *gin.Context) {
+	id, err := strconv.Atoi(rw.Param("id"))
+	if err != nil {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	var request dto.PatchWebhookRequest
+	if err := rw.ShouldBindJSON(&request); err != nil {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validate(&request); err != nil {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if request.Url != nil && !a.checkWebhookURL(rw, *request.Url) {
+		return
+	}
+	hook, err := a.Repo.UpdateWebhook(rw.Request.Context(), id, rw.GetInt("currentWorkspaceID"), request.Url, request.Events, request.Is_active)
+	if err != nil {
+		webhookError(rw, err)
+		return
+	}
+	rw.JSON(http.StatusOK, hook)
+}
+
+// DeleteWebhook godoc
+// @Summary      Delete webhook
+// @Description  deletes the subscription with its delivery log
+// @Tags         webhooks
+// @Param        id path int true "Webhook ID"
+// @Success      204  "No Content"
+// @Failure      404  {object}  dto.ErrorResponse
+// @Failure      500  {object}  dto.ErrorResponse
+// @Router       /webhooks/{id} [delete]
+func (a *App) DeleteWebhook(rw *gin.Context) {
+	id, err := strconv.Atoi(rw.Param("id"))
+	if err != nil {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	deleted, err := a.Repo.DeleteWebhook(rw.Request.Context(), id, rw.GetInt("currentWorkspaceID"))
+	if err != nil {
+		rw.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !deleted {
+		rw.JSON(http.StatusNotFound, gin.H{"error": repository.ErrWebhookNotFound.Error()})
+		return
+	}
+	rw.Status(http.StatusNoContent)
+}
+
+// GetWebhookDeliveries godoc
+// @Summary      Webhook delivery log
+// @Description  last 100 deliveries with payload, attempts, response code and error, newest first
+// @Tags         webhooks
+// @Produce      json
+// @Param        id path int true "Webhook ID"
+// @Success      200  {array}   domain.WebhookDelivery
+// @Failure      404  {object}  dto.ErrorResponse
+// @Failure      500  {object}  dto.ErrorResponse
+// @Router       /webhooks/{id}/deliveries [get]
+func (a *App) GetWebhookDeliveries(rw *gin.Context) {
+	id, err := strconv.Atoi(rw.Param("id"))
+	if err != nil {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	deliveries, err := a.Repo.GetWebhookDeliveries(rw.Request.Context(), id, rw.GetInt("currentWorkspaceID"))
+	if err != nil {
+		webhookError(rw, err)
+		return
+	}
+	rw.JSON(http.StatusOK, deliveries)
+}
+
+// RedeliverWebhook godoc
+// @Summary      Redeliver webhook
+// @Description  queues the same payload again as a new delivery with the same event id
+// @Tags         webhooks
+// @Produce      json
+// @Param        id path int true "Webhook ID"
+// @Param        delivery_id path int true "Delivery ID"
+// @Success      202  {object}  domain.WebhookDelivery
+// @Failure      404  {object}  dto.ErrorResponse
+// @Failure      500  {object}  dto.ErrorResponse
+// @Router       /webhooks/{id}/deliveries/{delivery_id}/redeliver [post]
+func (a *App) RedeliverWebhook(rw *gin.Context) {
+	id, err := strconv.Atoi(rw.Param("id"))
+	if err != nil {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	deliveryID, err := strconv.Atoi(rw.Param("delivery_id"))
+	if err != nil {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": "invalid delivery id"})
+		return
+	}
+	delivery, err := a.Repo.RedeliverWebhook(rw.Request.Context(), id, deliveryID, rw.GetInt("currentWorkspaceID"))
+	if err != nil {
+		webhookError(rw, err)
+		return
+	}
+	rw.JSON(http.StatusAccepted, delivery)
+}
+
+func webhookError(rw *gin.Context, err error) {
+	if errors.Is(err, repository.ErrWebhookNotFound) || errors.Is(err, repository.ErrDeliveryNotFound) {
+		rw.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	rw.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
+
+// postEvent ставит событие поста в очередь подписчиков; ошибка не влияет на ответ.
+// author пустой — автор определяется по посту.
+func (a *App) postEvent(rw *gin.Context, event string, ID_post int, author string, data domain.PostEventData) {
+	a.enqueuePostEvent(rw.Request.Context(), rw.GetInt("currentWorkspaceID"), rw.GetString("currentUserID"), event, ID_post, author, data)
+}
+
+func (a *App) enqueuePostEvent(ctx context.Context, ID_workspace int, ID_user string, event string, ID_post int, author string, data domain.PostEventData) {
+	data.ID_post = ID_post
+	data.ID_user = ID_user
+	_, err := a.Repo.EnqueueWebhookEvent(ctx, domain.WebhookEvent{
+		Event:        event,
+		ID_workspace: ID_workspace,
+		ID_post:      ID_post,
+		ID_author:    author,
+		Data:         data,
+	})
+	if err != nil {
+		log.Printf("webhook event %s for post %d: %v", event, ID_post, err)
+	}
+}
+
+// checkWebhookURL: подписка не может вести во внутреннюю сеть сервиса (БД, Kafka, метаданные облака)
+func (a *App) checkWebhookURL(rw *gin.Context, rawURL string) bool {
+	var resolver webhook.Resolver = net.DefaultResolver
+	if a.Resolver != nil {
+		resolver = a.Resolver
+	}
+	if err := webhook.CheckURL(rw.Request.Context(), resolver, rawURL); err != nil {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	return true
+}
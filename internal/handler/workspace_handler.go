@@ -0,0 +1,415 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hexlet/internal/auth"
+	"hexlet/internal/domain"
+	"hexlet/internal/dto"
+	"hexlet/internal/mailer"
+	"hexlet/internal/repository"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// workspaceHeader выбирает пространство для одного запроса, важнее claim wid в токене
+	workspaceHeader = "X-Workspace-ID"
+	invitationTTL   = 7 * 24 * time.Hour
+)
+
+// WorkspaceMiddleware определяет текущее пространство: заголовок X-Workspace-ID,
+// затем claim wid access токена, затем пространство пользователя по умолчанию.
+func (a *App) WorkspaceMiddleware() gin.HandlerFunc {
+	return func(rw *gin.Context) {
+		workspaceID := rw.GetInt("currentWorkspaceClaim")
+		if header := rw.GetHeader(workspaceHeader); header != "" {
+			id, err := strconv.Atoi(header)
+			if err != nil {
+				rw.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid " + workspaceHeader})
+				return
+			}
+			workspaceID = id
+		}
+		if workspaceID == 0 {
+			id, err := a.Repo.GetDefaultWorkspace(rw.Request.Context(), rw.GetString("currentUserID"))
+			if errors.Is(err, repository.ErrNotMember) {
+				rw.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "user has no workspace"})
+				return
+			}
+			if err != nil {
+				rw.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+				return
+			}
+			workspaceID = id
+		}
+		a.enterWorkspace(rw, workspaceID)
+	}
+}
+
+// PathWorkspaceMiddleware — пространство из пути /workspaces/:id
+func (a *App) PathWorkspaceMiddleware() gin.HandlerFunc {
+	return func(rw *gin.Context) {
+		workspaceID, err := strconv.Atoi(rw.Param("id"))
+		if err != nil {
+			rw.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+			return
+		}
+		a.enterWorkspace(rw, workspaceID)
+	}
+}
+
+// enterWorkspace проверяет членство и запоминает пространство и роль для обработчиков
+func (a *App) enterWorkspace(rw *gin.Context, workspaceID int) {
+	role, err := a.Repo.GetMemberRole(rw.Request.Context(), workspaceID, rw.GetString("currentUserID"))
+	if errors.Is(err, repository.ErrNotMember) {
+		// не раскрываем, существует ли чужое пространство
+		rw.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "workspace not found"})
+		return
+	}
+	if err != nil {
+		rw.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	rw.Set("currentWorkspaceID", workspaceID)
+	rw.Set("currentRole", role)
+	rw.Next()
+}
+
+// requireRole пропускает участников с ролью не ниже role
+func requireRole(role string) gin.HandlerFunc {
+	return func(rw *gin.Context) {
+		if !domain.RoleAtLeast(rw.GetString("currentRole"), role) {
+			rw.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "role " + role + " or higher is required"})
+			return
+		}
+		rw.Next()
+	}
+}
+
+// CreateWorkspace godoc
+// @Summary      Create workspace
+// @Description  creates a workspace, the current user becomes its owner
+// @Tags         workspaces
+// @Accept       json
+// @Produce      json
+// @Param        request body dto.CreateWorkspaceRequest true "workspace name"
+// @Success      201  {object}  domain.Workspace
+// @Failure      400  {object}  dto.ErrorResponse
+// @Failure      500  {object}  dto.ErrorResponse
+// @Router       /workspaces [post]
+func (a *App) CreateWorkspace(rw *gin.Context) {
+	val, exists := rw.Get("currentUserID")
+	if !exists {
+		rw.JSON(500, gin.H{"error": "User not found"})
+		return
+	}
+	var request dto.CreateWorkspaceRequest
+	if err := rw.ShouldBindJSON(&request); err != nil {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validate(&request); err != nil {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	workspace, err := a.Repo.CreateWorkspace(rw.Request.Context(), request.Name, val.(string))
+	if err != nil {
+		rw.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	rw.JSON(http.StatusCreated, workspace)
+}
+
+// GetWorkspaces godoc
+// @Summary      Get workspaces
+// @Description  getting workspaces of the user with the user's role in each
+// @Tags         workspaces
+// @Produce      json
+// @Success      200  {array}   domain.Workspace
+// @Failure      500  {object}  dto.ErrorResponse
+// @Router       /workspaces [get]
+func (a *App) GetWorkspaces(rw *gin.Context) {
+	val, exists := rw.Get("currentUserID")
+	if !exists {
+		rw.JSON(500, gin.H{"error": "User not found"})
+		return
+	}
+	workspaces, err := a.Repo.GetWorkspaces(rw.Request.Context(), val.(string))
+	if err != nil {
+		rw.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	rw.JSON(http.StatusOK, workspaces)
+}
+
+// SwitchWorkspace godoc
+// @Summary      Switch workspace
+// @Description  issues an access token with the workspace claim, requests with it work in this workspace.
+// @Description  The choice is kept in the session, so tokens from /auth/refresh keep the workspace.
+// @Tags         workspaces
+// @Produce      json
+// @Param        id path int true "Workspace ID"
+// @Success      200  {object}  map[string]string
+// @Failure      404  {object}  dto.ErrorResponse
+// @Failure      500  {object}  dto.ErrorResponse
+// @Router       /workspaces/{id}/switch [post]
+func (a *App) SwitchWorkspace(rw *gin.Context) {
+	userID, sessionID, workspaceID := rw.GetString("currentUserID"), rw.GetString("currentSessionID"), rw.GetInt("currentWorkspaceID")
+	if sessionID != "" {
+		if err := a.Repo.SetSessionWorkspace(rw.Request.Context(), sessionID, userID, workspaceID); err != nil {
+			rw.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return
+		}
+	}
+	accessToken, err := a.Tokens.GenerateAccessToken(userID, sessionID, workspaceID)
+	if err != nil {
+		rw.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	rw.JSON(http.StatusOK, gin.H{
+		"access_token": accessToken,
+	})
+}
+
+// GetMembers godoc
+// @Summary      Get workspace members
+// @Description  getting members of the workspace with their roles
+// @Tags         workspaces
+// @Produce      json
+// @Param        id path int true "Workspace ID"
+// @Success      200  {array}   domain.WorkspaceMember
+// @Failure      404  {object}  dto.ErrorResponse
+// @Failure      500  {object}  dto.ErrorResponse
+// @Router       /workspaces/{id}/members [get]
+func (a *App) GetMembers(rw *gin.Context) {
+	members, err := a.Repo.GetMembers(rw.Request.Context(), rw.GetInt("currentWorkspaceID"))
+	if err != nil {
+		rw.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	rw.JSON(http.StatusOK, members)
+}
+
+// canManage: нельзя выдать роль выше своей и менять участника старше себя
+func (a *App) canManage(rw *gin.Context, targetUserID string, role string) bool {
+	current := rw.GetString("currentRole")
+	if role != "" && !domain.RoleAtLeast(current, role) {
+		rw.JSON(http.StatusForbidden, gin.H{"error": "cannot grant a role higher than your own"})
+		return false
+	}
+	if targetUserID == "" {
+		return true
+	}
+	targetRole, err := a.Repo.GetMemberRole(rw.Request.Context(), rw.GetInt("currentWorkspaceID"), targetUserID)
+	if errors.Is(err, repository.ErrNotMember) {
+		rw.JSON(http.StatusNotFound, gin.H{"error": "member not found"})
+		return false
+	}
+	if err != nil {
+		rw.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return false
+	}
+	if !domain.RoleAtLeast(current, targetRole) {
+		rw.JSON(http.StatusForbidden, gin.H{"error": "cannot manage a member with a higher role"})
+		return false
+	}
+	return true
+}
+
+// UpdateMember godoc
+// @Summary      Change member role
+// @Description  changes the role of a member; admins cannot grant or change the owner role
+// @Tags         workspaces
+// @Accept       json
+// @Produce      json
+// @Param        id path int true "Workspace ID"
+// @Param        user_id path string true "User ID"
+// @Param        request body dto.UpdateMemberRequest true "new role"
+// @Success      204  "No Content"
+// @Failure      400  {object}  dto.ErrorResponse
+// @Failure      403  {object}  dto.ErrorResponse
+// @Failure      404  {object}  dto.ErrorResponse
+// @Failure      409  {object}  dto.ErrorResponse
+// @Failure      500  {object}  dto.ErrorResponse
+// @Router       /workspaces/{id}/members/{user_id} [patch]
+func (a *App) UpdateMember(rw *gin.Context) {
+	var request dto.UpdateMemberRequest
+	if err := rw.ShouldBindJSON(&request); err != nil {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validate(&request); err != nil {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID := rw.Param("user_id")
+	if !a.canManage(rw, userID, request.Role) {
+		return
+	}
+	updated, err := a.Repo.UpdateMemberRole(rw.Request.Context(), rw.GetInt("currentWorkspaceID"), userID, request.Role)
+	if errors.Is(err, repository.ErrLastOwner) {
+		rw.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		rw.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !updated {
+		rw.JSON(http.StatusNotFound, gin.H{"error": "member not found"})
+		return
+	}
+	rw.Status(http.StatusNoContent)
+}
+
+// RemoveMember godoc
+// @Summary      Remove member
+// @Description  removes a member from the workspace; any member can leave by removing themselves
+// @Tags         workspaces
+// @Param        id path int true "Workspace ID"
+// @Param        user_id path string true "User ID"
+// @Success      204  "No Content"
+// @Failure      403  {object}  dto.ErrorResponse
+// @Failure      404  {object}  dto.ErrorResponse
+// @Failure      409  {object}  dto.ErrorResponse
+// @Failure      500  {object}  dto.ErrorResponse
+// @Router       /workspaces/{id}/members/{user_id} [delete]
+func (a *App) RemoveMember(rw *gin.Context) {
+	userID := rw.Param("user_id")
+	if userID != rw.GetString("currentUserID") {
+		if !domain.RoleAtLeast(rw.GetString("currentRole"), domain.RoleAdmin) {
+			rw.JSON(http.StatusForbidden, gin.H{"error": "role " + domain.RoleAdmin + " or higher is required"})
+			return
+		}
+		if !a.canManage(rw, userID, "") {
+			return
+		}
+	}
+	removed, err := a.Repo.RemoveMember(rw.Request.Context(), rw.GetInt("currentWorkspaceID"), userID)
+	if errors.Is(err, repository.ErrLastOwner) {
+		rw.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		rw.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !removed {
+		rw.JSON(http.StatusNotFound, gin.H{"error": "member not found"})
+		return
+	}
+	rw.Status(http.StatusNoContent)
+}
+
+// InviteMember godoc
+// @Summary      Invite member
+// @Description  sends an invitation to the email; the role cannot be higher than the inviter's
+// @Tags         workspaces
+// @Accept       json
+// @Param        id path int true "Workspace ID"
+// @Param        request body dto.InviteMemberRequest true "email and role"
+// @Success      202  "Accepted"
+// @Failure      400  {object}  dto.ErrorResponse
+// @Failure      403  {object}  dto.ErrorResponse
+// @Failure      404  {object}  dto.ErrorResponse
+// @Failure      500  {object}  dto.ErrorResponse
+// @Router       /workspaces/{id}/invitations [post]
+func (a *App) InviteMember(rw *gin.Context) {
+	var request dto.InviteMemberRequest
+	if err := rw.ShouldBindJSON(&request); err != nil {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	request.Email = normalizeEmail(request.Email)
+	if err := validate(&request); err != nil {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !a.canManage(rw, "", request.Role) {
+		return
+	}
+	token, err := auth.NewOpaqueToken()
+	if err != nil {
+		rw.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	invitation := domain.Invitation{
+		ID_workspace: rw.GetInt("currentWorkspaceID"),
+		Email:        request.Email,
+		Role:         request.Role,
+		Invited_by:   rw.GetString("currentUserID"),
+		Expires_at:   time.Now().Add(invitationTTL),
+	}
+	if err := a.Repo.CreateInvitation(rw.Request.Context(), invitation, auth.HashToken(token)); err != nil {
+		rw.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	a.sendInvitation(rw.Request.Context(), invitation, token)
+	rw.Status(http.StatusAccepted)
+}
+
+// sendInvitation отправляет письмо с токеном приглашения, ошибки доставки только логируются
+func (a *App) sendInvitation(ctx context.Context, invitation domain.Invitation, token string) {
+	if a.Mailer == nil {
+		log.Printf("mailer is not configured, invitation to %s is not sent", invitation.Email)
+		return
+	}
+	msg := mailer.Message{
+		To:      invitation.Email,
+		Subject: "Приглашение в рабочее пространство",
+		Body: fmt.Sprintf("Вас пригласили в рабочее пространство с ролью %s.\nЧтобы принять приглашение, войдите и отправьте токен в POST %s/workspaces/invitations/accept:\n%s\n\nПриглашение действует %v.",
+			invitation.Role, a.PublicURL, token, invitationTTL),
+	}
+	if err := a.Mailer.Send(ctx, msg); err != nil {
+		log.Printf("failed to send invitation email: %v", err)
+	}
+}
+
+// AcceptInvitation godoc
+// @Summary      Accept invitation
+// @Description  joins the workspace by the token from the invitation email; the account email must match the invited one
+// @Tags         workspaces
+// @Accept       json
+// @Produce      json
+// @Param        request body dto.AcceptInvitationRequest true "invitation token"
+// @Success      200  {object}  domain.Workspace
+// @Failure      400  {object}  dto.ErrorResponse
+// @Failure      403  {object}  dto.ErrorResponse
+// @Failure      500  {object}  dto.ErrorResponse
+// @Router       /workspaces/invitations/accept [post]
+func (a *App) AcceptInvitation(rw *gin.Context) {
+	val, exists := rw.Get("currentUserID")
+	if !exists {
+		rw.JSON(500, gin.H{"error": "User not found"})
+		return
+	}
+	var request dto.AcceptInvitationRequest
+	if err := rw.ShouldBindJSON(&request); err != nil {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validate(&request); err != nil {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	workspace, err := a.Repo.AcceptInvitation(rw.Request.Context(), auth.HashToken(request.Token), val.(string))
+	if errors.Is(err, repository.ErrInvitationInvalid) {
+		rw.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, repository.ErrInvitationEmail) {
+		rw.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		rw.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	rw.JSON(http.StatusOK, workspace)
+}
@@ -0,0 +1,104 @@
+// Package health — проверки готовности сервиса для GET /readyz.
+// Живость (GET /healthz) ничего не проверяет: процесс отвечает — значит жив.
+package health
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// CheckFunc возвращает подробности проверки; ошибка — зависимость недоступна
+type CheckFunc func(ctx context.Context) (map[string]any, error)
+
+type CheckResult struct {
+	Status      string         `json:"status"`
+	Duration_ms int64          `json:"duration_ms"`
+	Error       string         `json:"error,omitempty"`
+	Details     map[string]any `json:"details,omitempty"`
+}
+
+type Report struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+func (r Report) Ready() bool {
+	return r.Status == StatusUp
+}
+
+// Checker выполняет проверки параллельно, каждую не дольше timeout
+type Checker struct {
+	timeout  time.Duration
+	names    []string
+	checks   map[string]CheckFunc
+	draining atomic.Bool
+}
+
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{
+		timeout: timeout,
+		checks:  make(map[string]CheckFunc),
+	}
+}
+
+func (c *Checker) Add(name string, check CheckFunc) {
+	c.names = append(c.names, name)
+	c.checks[name] = check
+}
+
+// Drain переводит сервис в неготовность на время остановки, чтобы оркестратор
+// перестал направлять запросы до закрытия соединений
+func (c *Checker) Drain() {
+	c.draining.Store(true)
+}
+
+func (c *Checker) Ready(ctx context.Context) Report {
+	if c.draining.Load() {
+		return Report{
+			Status: StatusDown,
+			Checks: map[string]CheckResult{"shutdown": {Status: StatusDown, Error: "service is shutting down"}},
+		}
+	}
+	results := make([]CheckResult, len(c.names))
+	var wg sync.WaitGroup
+	for i, name := range c.names {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = c.run(ctx, c.checks[name])
+		}()
+	}
+	wg.Wait()
+	report := Report{Status: StatusUp, Checks: make(map[string]CheckResult, len(c.names))}
+	for i, name := range c.names {
+		report.Checks[name] = results[i]
+		if results[i].Status != StatusUp {
+			report.Status = StatusDown
+		}
+	}
+	return report
+}
+
+func (c *Checker) run(ctx context.Context, check CheckFunc) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	start := time.Now()
+	details, err := check(ctx)
+	res := CheckResult{
+		Status:      StatusUp,
+		Duration_ms: time.Since(start).Milliseconds(),
+		Details:     details,
+	}
+	if err != nil {
+		res.Status = StatusDown
+		res.Error = err.Error()
+	}
+	return res
+}
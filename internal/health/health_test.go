@@ -0,0 +1,67 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadyReportsEveryCheck(t *testing.T) {
+	c := NewChecker(time.Second)
+	c.Add("master", func(ctx context.Context) (map[string]any, error) {
+		return map[string]any{"max_conns": 25}, nil
+	})
+	c.Add("kafka", func(ctx context.Context) (map[string]any, error) {
+		return map[string]any{"topic": "publications.pending"}, errors.New("no kafka broker is reachable")
+	})
+
+	report := c.Ready(context.Background())
+
+	assert.False(t, report.Ready())
+	assert.Equal(t, StatusDown, report.Status)
+	assert.Equal(t, StatusUp, report.Checks["master"].Status)
+	assert.Equal(t, 25, report.Checks["master"].Details["max_conns"])
+	assert.Equal(t, StatusDown, report.Checks["kafka"].Status)
+	assert.Equal(t, "no kafka broker is reachable", report.Checks["kafka"].Error)
+	assert.Equal(t, "publications.pending", report.Checks["kafka"].Details["topic"])
+}
+
+func TestReadyLimitsCheckDuration(t *testing.T) {
+	c := NewChecker(20 * time.Millisecond)
+	c.Add("replica", func(ctx context.Context) (map[string]any, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	c.Add("master", func(ctx context.Context) (map[string]any, error) {
+		return nil, nil
+	})
+
+	start := time.Now()
+	report := c.Ready(context.Background())
+
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, StatusDown, report.Status)
+	assert.Equal(t, context.DeadlineExceeded.Error(), report.Checks["replica"].Error)
+	assert.Equal(t, StatusUp, report.Checks["master"].Status)
+}
+
+func TestDrainMakesNotReady(t *testing.T) {
+	c := NewChecker(time.Second)
+	called := false
+	c.Add("master", func(ctx context.Context) (map[string]any, error) {
+		called = true
+		return nil, nil
+	})
+	assert.True(t, c.Ready(context.Background()).Ready())
+
+	called = false
+	c.Drain()
+	report := c.Ready(context.Background())
+
+	assert.False(t, report.Ready())
+	assert.Equal(t, StatusDown, report.Checks["shutdown"].Status)
+	assert.False(t, called, "checks are skipped while shutting down")
+}
@@ -0,0 +1,80 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// KafkaCheck проверяет доступность брокеров и наличие топика. Достаточно одного
+// доступного брокера: клиент переключается на остальные сам. Топик не создаётся.
+func KafkaCheck(brokers []string, topic string) CheckFunc {
+	return func(ctx context.Context) (map[string]any, error) {
+		var dialer net.Dialer
+		states := make(map[string]string, len(brokers))
+		var reachable []string
+		for _, broker := range brokers {
+			conn, err := dialer.DialContext(ctx, "tcp", broker)
+			if err != nil {
+				states[broker] = err.Error()
+				continue
+			}
+			conn.Close()
+			states[broker] = StatusUp
+			reachable = append(reachable, broker)
+		}
+		details := map[string]any{"brokers": states, "topic": topic}
+		if len(reachable) == 0 {
+			return details, errors.New("no kafka broker is reachable")
+		}
+		client := &kafka.Client{Addr: kafka.TCP(reachable...)}
+		meta, err := client.Metadata(ctx, &kafka.MetadataRequest{Topics: []string{topic}})
+		if err != nil {
+			return details, err
+		}
+		for _, t := range meta.Topics {
+			if t.Name != topic {
+				continue
+			}
+			if t.Error != nil {
+				return details, fmt.Errorf("topic %s: %w", topic, t.Error)
+			}
+			details["partitions"] = len(t.Partitions)
+			return details, nil
+		}
+		return details, fmt.Errorf("topic %s does not exist", topic)
+	}
+}
+
+// ConsumerGroupCheck проверяет, что этот экземпляр состоит в группе потребителей.
+// Экземпляр узнаётся по clientID, с которым читатель подключается к брокеру.
+func ConsumerGroupCheck(brokers []string, group string, clientID string) CheckFunc {
+	return func(ctx context.Context) (map[string]any, error) {
+		client := &kafka.Client{Addr: kafka.TCP(brokers...)}
+		res, err := client.DescribeGroups(ctx, &kafka.DescribeGroupsRequest{GroupIDs: []string{group}})
+		if err != nil {
+			return nil, err
+		}
+		details := map[string]any{"group": group, "client_id": clientID}
+		for _, g := range res.Groups {
+			if g.GroupID != group {
+				continue
+			}
+			if g.Error != nil {
+				return details, g.Error
+			}
+			details["state"] = g.GroupState
+			details["members"] = len(g.Members)
+			for _, m := range g.Members {
+				if m.ClientID == clientID {
+					return details, nil
+				}
+			}
+			return details, fmt.Errorf("consumer %s has not joined group %s", clientID, group)
+		}
+		return details, fmt.Errorf("group %s not found", group)
+	}
+}
@@ -0,0 +1,49 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+func PostgresCheck(pool *pgxpool.Pool) CheckFunc {
+	return func(ctx context.Context) (map[string]any, error) {
+		stat := pool.Stat()
+		details := map[string]any{
+			"total_conns":    stat.TotalConns(),
+			"acquired_conns": stat.AcquiredConns(),
+			"max_conns":      stat.MaxConns(),
+		}
+		return details, pool.Ping(ctx)
+	}
+}
+
+// ReplicaCheck проверяет связь с репликой и её отставание. Реплика, применившая
+// всё полученное WAL, не отстаёт, даже если на мастере давно не было записей.
+func ReplicaCheck(pool *pgxpool.Pool, maxLag time.Duration) CheckFunc {
+	return func(ctx context.Context) (map[string]any, error) {
+		var inRecovery bool
+		var lag float64
+		err := pool.QueryRow(ctx, `
+			SELECT pg_is_in_recovery(),
+				CASE WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+				ELSE COALESCE(EXTRACT(EPOCH FROM NOW() - pg_last_xact_replay_timestamp()), 0)::FLOAT8
+				END`,
+		).Scan(&inRecovery, &lag)
+		if err != nil {
+			return nil, err
+		}
+		details := map[string]any{
+			"in_recovery":     inRecovery,
+			"lag_seconds":     lag,
+			"max_lag_seconds": maxLag.Seconds(),
+			"total_conns":     pool.Stat().TotalConns(),
+		}
+		if lag > maxLag.Seconds() {
+			return details, fmt.Errorf("replica lag %.1fs exceeds %v", lag, maxLag)
+		}
+		return details, nil
+	}
+}
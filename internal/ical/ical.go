@@ -0,0 +1,132 @@
+// Package ical пишет календарь в формате iCalendar (RFC 5545)
+package ical
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Значения STATUS события
+const (
+	StatusTentative = "TENTATIVE"
+	StatusConfirmed = "CONFIRMED"
+	StatusCancelled = "CANCELLED"
+)
+
+const (
+	stampLayout = "20060102T150405Z"
+	// строка длиннее переносится, RFC 5545 3.1
+	lineLimit = 75
+)
+
+type Calendar struct {
+	Name string
+	// как часто клиенту перечитывать календарь
+	Refresh time.Duration
+	Events  []Event
+}
+
+type Event struct {
+	UID         string
+	Start       time.Time
+	End         time.Time
+	Summary     string
+	Description string
+	URL         string
+	Status      string
+	Categories  []string
+}
+
+// Write пишет календарь; все времена выводятся в UTC
+func Write(w io.Writer, cal Calendar) error {
+	bw := bufio.NewWriter(w)
+	stamp := time.Now().UTC().Format(stampLayout)
+	line := func(name, value string) {
+		writeLine(bw, name+":"+value)
+	}
+	line("BEGIN", "VCALENDAR")
+	line("VERSION", "2.0")
+	line("PRODID", "-//hexlet//posts calendar//RU")
+	line("CALSCALE", "GREGORIAN")
+	line("METHOD", "PUBLISH")
+	if cal.Name != "" {
+		line("X-WR-CALNAME", escape(cal.Name))
+	}
+	if cal.Refresh > 0 {
+		line("REFRESH-INTERVAL;VALUE=DURATION", duration(cal.Refresh))
+		line("X-PUBLISHED-TTL", duration(cal.Refresh))
+	}
+	for _, e := range cal.Events {
+		line("BEGIN", "VEVENT")
+		line("UID", escape(e.UID))
+		line("DTSTAMP", stamp)
+		line("DTSTART", e.Start.UTC().Format(stampLayout))
+		if !e.End.IsZero() {
+			line("DTEND", e.End.UTC().Format(stampLayout))
+		}
+		line("SUMMARY", escape(e.Summary))
+		if e.Description != "" {
+			line("DESCRIPTION", escape(e.Description))
+		}
+		if e.URL != "" {
+			line("URL", e.URL)
+		}
+		if e.Status != "" {
+			line("STATUS", e.Status)
+		}
+		if len(e.Categories) > 0 {
+			categories := make([]string, len(e.Categories))
+			for i, c := range e.Categories {
+				categories[i] = escape(c)
+			}
+			line("CATEGORIES", strings.Join(categories, ","))
+		}
+		line("END", "VEVENT")
+	}
+	line("END", "VCALENDAR")
+	return bw.Flush()
+}
+
+// экранирование текстовых значений, RFC 5545 3.3.11
+var escaper = strings.NewReplacer(
+	`\`, `\\`,
+	";", `\;`,
+	",", `\,`,
+	"\r\n", `\n`,
+	"\n", `\n`,
+	"\r", `\n`,
+)
+
+func escape(s string) string {
+	return escaper.Replace(s)
+}
+
+// writeLine переносит строку по 75 байт, не разрывая символы UTF-8
+func writeLine(w *bufio.Writer, s string) {
+	limit := lineLimit
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		w.WriteString(s[:cut])
+		w.WriteString("\r\n ")
+		s = s[cut:]
+		// пробел в начале продолжения тоже считается
+		limit = lineLimit - 1
+	}
+	w.WriteString(s)
+	w.WriteString("\r\n")
+}
+
+func duration(d time.Duration) string {
+	minutes := int(d.Minutes())
+	if minutes < 1 {
+		minutes = 1
+	}
+	return "PT" + strconv.Itoa(minutes) + "M"
+}
@@ -0,0 +1,56 @@
+package ical
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWrite(t *testing.T) {
+	start := time.Date(2026, 11, 1, 10, 0, 0, 0, time.FixedZone("MSK", 3*60*60))
+	var buf bytes.Buffer
+	err := Write(&buf, Calendar{
+		Name:    "Content plan",
+		Refresh: 15 * time.Minute,
+		Events: []Event{{
+			UID:         "destination-1@example.com",
+			Start:       start,
+			End:         start.Add(15 * time.Minute),
+			Summary:     "[Telegram] Launch; part 1, draft",
+			Description: "line one\nline two",
+			URL:         "https://example.com/posts/1",
+			Status:      StatusConfirmed,
+			Categories:  []string{"Telegram", "published"},
+		}},
+	})
+	assert.NoError(t, err)
+	out := buf.String()
+	assert.True(t, strings.HasPrefix(out, "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n"))
+	assert.True(t, strings.HasSuffix(out, "END:VEVENT\r\nEND:VCALENDAR\r\n"))
+	assert.Contains(t, out, "DTSTART:20261101T070000Z\r\n")
+	assert.Contains(t, out, "DTEND:20261101T071500Z\r\n")
+	assert.Contains(t, out, `SUMMARY:[Telegram] Launch\; part 1\, draft`+"\r\n")
+	assert.Contains(t, out, `DESCRIPTION:line one\nline two`+"\r\n")
+	assert.Contains(t, out, "STATUS:CONFIRMED\r\n")
+	assert.Contains(t, out, "CATEGORIES:Telegram,published\r\n")
+	assert.Contains(t, out, "REFRESH-INTERVAL;VALUE=DURATION:PT15M\r\n")
+}
+
+func TestWriteLine_Folds(t *testing.T) {
+	var buf bytes.Buffer
+	summary := strings.Repeat("Пост ", 40)
+	assert.NoError(t, Write(&buf, Calendar{Events: []Event{{UID: "1", Start: time.Now(), Summary: summary}}}))
+	var unfolded strings.Builder
+	for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\r\n"), "\r\n") {
+		assert.LessOrEqual(t, len(line), lineLimit)
+		if strings.HasPrefix(line, " ") {
+			unfolded.WriteString(line[1:])
+			continue
+		}
+		unfolded.WriteString("\n" + line)
+	}
+	assert.Contains(t, unfolded.String(), "SUMMARY:"+summary)
+}
@@ -9,8 +9,12 @@ import (
 	"time"
 
 	"hexlet/internal/domain"
+	"hexlet/internal/metrics"
+	"hexlet/internal/tracing"
 
 	"github.com/segmentio/kafka-go"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Producer struct {
@@ -33,18 +37,33 @@ func NewProducer(cfg *Config) *Producer {
 	}
 }
 
-func (p *Producer) SendPublicationEvent(ctx context.Context, event domain.PublicationEvent) error {
+// SendPublicationEvent передаёт контекст трассы в заголовках сообщения
+func (p *Producer) SendPublicationEvent(ctx context.Context, event domain.PublicationEvent) (err error) {
+	key := strconv.Itoa(event.PostID)
+	ctx, span := tracing.Start(ctx, "send "+p.topic,
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKafka,
+			semconv.MessagingOperationTypeSend,
+			semconv.MessagingDestinationName(p.topic),
+			semconv.MessagingKafkaMessageKey(key),
+		),
+	)
+	defer func() { tracing.End(span, err) }()
+
 	eventJSON, err := json.Marshal(event)
 	if err != nil {
 		return fmt.Errorf("failed to marshal event: %w", err)
 	}
 
 	message := kafka.Message{
-		Key:   []byte(strconv.Itoa(event.PostID)),
+		Key:   []byte(key),
 		Value: eventJSON,
 		Time:  time.Now(),
 	}
+	InjectContext(ctx, &message)
 	err = p.writer.WriteMessages(ctx, message)
+	metrics.KafkaProduced.WithLabelValues(p.topic, metrics.Result(err)).Inc()
 	if err != nil {
 		return fmt.Errorf("failed to write message to kafka: %w", err)
 	}
@@ -0,0 +1,48 @@
+package kafka
+
+import (
+	"context"
+	"testing"
+
+	"hexlet/internal/tracing"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTraceContextInHeaders(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer provider.Shutdown(context.Background())
+
+	ctx, producer := tracing.Start(context.Background(), "send publications.pending")
+	msg := kafka.Message{
+		Topic:     "publications.pending",
+		Partition: 2,
+		Offset:    41,
+		Key:       []byte("7"),
+		Headers:   []kafka.Header{{Key: "traceparent", Value: []byte("stale")}},
+	}
+	InjectContext(ctx, &msg)
+	producer.End()
+	require.Len(t, msg.Headers, 1, "traceparent must be replaced, not duplicated")
+
+	_, consumer := StartConsumerSpan(context.Background(), msg)
+	consumer.End()
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	span := spans[1]
+	assert.Equal(t, "process publications.pending", span.Name())
+	assert.Equal(t, trace.SpanKindConsumer, span.SpanKind())
+	assert.Equal(t, producer.SpanContext().TraceID(), span.SpanContext().TraceID())
+	assert.Equal(t, producer.SpanContext().SpanID(), span.Parent().SpanID())
+}
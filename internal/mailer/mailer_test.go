@@ -0,0 +1,103 @@
+package mailer
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// smtpCatcher — минимальная SMTP-ловушка: принимает одно письмо и отдаёт его в канал
+func smtpCatcher(t *testing.T) (string, string, <-chan string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+	mails := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		write := func(s string) { conn.Write([]byte(s + "\r\n")) }
+		write("220 catcher")
+		var data strings.Builder
+		inData := false
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			if inData {
+				if line == ".\r\n" {
+					inData = false
+					mails <- data.String()
+					write("250 OK")
+					continue
+				}
+				data.WriteString(line)
+				continue
+			}
+			switch cmd := strings.ToUpper(strings.TrimSpace(line)); {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				write("250 catcher")
+			case cmd == "DATA":
+				inData = true
+				write("354 go ahead")
+			case cmd == "QUIT":
+				write("221 bye")
+				return
+			default:
+				write("250 OK")
+			}
+		}
+	}()
+	host, port, _ := net.SplitHostPort(ln.Addr().String())
+	return host, port, mails
+}
+
+func TestSMTPMailer_Send(t *testing.T) {
+	host, port, mails := smtpCatcher(t)
+	m := NewSMTPMailer(host, port, "noreply@example.com", "", "")
+
+	err := m.Send(context.Background(), Message{
+		To:      "user@example.com",
+		Subject: "Подтверждение email",
+		Body:    "token: abc\nbye",
+	})
+	require.NoError(t, err)
+
+	mail := <-mails
+	assert.Contains(t, mail, "To: user@example.com\r\n")
+	assert.Contains(t, mail, "Subject: =?utf-8?q?")
+	assert.Contains(t, mail, "token: abc\r\nbye")
+}
+
+func TestSMTPMailer_RejectsHeaderInjection(t *testing.T) {
+	m := NewSMTPMailer("127.0.0.1", "1", "noreply@example.com", "", "")
+	err := m.Send(context.Background(), Message{To: "user@example.com\r\nBcc: other@example.com"})
+	assert.Error(t, err)
+}
+
+func TestLogMailer_HidesBody(t *testing.T) {
+	var out bytes.Buffer
+	log.SetOutput(&out)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	msg := Message{To: "user@example.com", Subject: "Сброс пароля", Body: "token: abc"}
+
+	require.NoError(t, LogMailer{}.Send(context.Background(), msg))
+	assert.Contains(t, out.String(), "user@example.com")
+	assert.NotContains(t, out.String(), "abc")
+
+	out.Reset()
+	require.NoError(t, LogMailer{ShowBody: true}.Send(context.Background(), msg))
+	assert.Contains(t, out.String(), "token: abc")
+}
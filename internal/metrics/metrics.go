@@ -0,0 +1,139 @@
+// Package metrics — метрики Prometheus, отдаются на GET /metrics отдельного внутреннего сервера.
+// Коллекторы регистрируются в реестре по умолчанию рядом с метриками Go и процесса.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "autoposting"
+
+// исходы публикации и отправки в Kafka
+const (
+	OutcomePublished = "published"
+	OutcomeFailed    = "failed"
+	ResultOK         = "ok"
+	ResultError      = "error"
+)
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "HTTP requests by route and status code.",
+	}, []string{"method", "route", "status"})
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "HTTP request latency by route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	SchedulerTick = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "scheduler",
+		Name:      "tick_duration_seconds",
+		Help:      "Duration of a scheduler pass over due publications.",
+		Buckets:   prometheus.DefBuckets,
+	})
+	SchedulerDue = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "scheduler",
+		Name:      "due_publications",
+		Help:      "Publications found due on the last scheduler tick.",
+	})
+
+	KafkaProduced = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "kafka",
+		Name:      "produced_messages_total",
+		Help:      "Messages written to Kafka by topic and result (ok, error).",
+	}, []string{"topic", "result"})
+	KafkaConsumed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "kafka",
+		Name:      "consumed_messages_total",
+		Help:      "Messages read from Kafka by topic.",
+	}, []string{"topic"})
+	KafkaLag = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "kafka",
+		Name:      "consumer_lag",
+		Help:      "Messages behind the partition high water mark after the last read.",
+	}, []string{"topic", "partition"})
+
+	// _count по outcome — число удачных и неудачных публикаций
+	PublishDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "publisher",
+		Name:      "publish_duration_seconds",
+		Help:      "Latency of a publication to the platform by outcome (published, failed).",
+		Buckets:   []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+	}, []string{"platform", "outcome"})
+	WorkersInFlight = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "publisher",
+		Name:      "workers_in_flight",
+		Help:      "Publication workers started and not finished yet.",
+	})
+	// source: telegram — кнопка повтора в боте
+	PublicationRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "publisher",
+		Name:      "retries_total",
+		Help:      "Failed publications scheduled again by source.",
+	}, []string{"source"})
+
+	// result: delivered, retry (будет повтор), failed (попытки исчерпаны)
+	WebhookAttempts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "webhook",
+		Name:      "delivery_attempts_total",
+		Help:      "Webhook delivery attempts by result (delivered, retry, failed).",
+	}, []string{"result"})
+)
+
+// Result — метка результата по ошибке
+func Result(err error) string {
+	if err != nil {
+		return ResultError
+	}
+	return ResultOK
+}
+
+// Since — секунды с начала операции для Observe
+func Since(start time.Time) float64 {
+	return time.Since(start).Seconds()
+}
+
+// Middleware считает запросы и их длительность. Маршрут берётся шаблоном
+// (/posts/:id), чтобы число рядов не росло с числом постов.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		httpRequests.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).Inc()
+		httpDuration.WithLabelValues(c.Request.Method, route).Observe(Since(start))
+	}
+}
+
+// NewServer — сервер только с /metrics на внутреннем адресе: маршруты, коды ответов
+// и состояние пулов БД не должны быть видны на публичном порту API
+func NewServer(addr string) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", promhttp.Handler())
+	return &http.Server{Addr: addr, Handler: mux}
+}
@@ -0,0 +1,335 @@
+// Package postimport разбирает файлы импорта постов (CSV и NDJSON)
+// и проверяет каждую строку до записи в базу.
+package postimport
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hexlet/internal/domain"
+	"hexlet/internal/dto"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+)
+
+const (
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+)
+
+const (
+	ColumnTitle        = "title"
+	ColumnContent      = "content"
+	ColumnScheduledFor = "scheduled_for"
+	ColumnPlatforms    = "platforms"
+	ColumnTimezone     = "timezone"
+)
+
+var ErrUnknownFormat = errors.New("unknown import format, expected csv or ndjson")
+
+var validate = validator.New()
+
+// время без смещения читается в часовом поясе строки
+var localLayouts = []string{
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"02.01.2006 15:04",
+}
+
+// Targets — платформы строки: имена или id через запятую/точку с запятой,
+// в NDJSON также массив строк и чисел
+type Targets []string
+
+func (t *Targets) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*t = splitTargets(s)
+		return nil
+	}
+	var items []json.RawMessage
+	if err := json.Unmarshal(data, &items); err != nil {
+		return errors.New("platforms must be a string or an array")
+	}
+	res := Targets{}
+	for _, item := range items {
+		var name string
+		if err := json.Unmarshal(item, &name); err == nil {
+			res = append(res, strings.TrimSpace(name))
+			continue
+		}
+		var id int
+		if err := json.Unmarshal(item, &id); err != nil {
+			return errors.New("platforms must contain names or ids")
+		}
+		res = append(res, strconv.Itoa(id))
+	}
+	*t = res
+	return nil
+}
+
+// Record — строка файла до проверки
+type Record struct {
+	Line          int     `json:"-"`
+	Title         string  `json:"title"`
+	Content       string  `json:"content"`
+	Scheduled_for string  `json:"scheduled_for"`
+	Platforms     Targets `json:"platforms"`
+	Timezone      string  `json:"timezone"`
+	// ошибка разбора самой строки
+	Err error `json:"-"`
+}
+
+// Post — проверенная строка, готовая к созданию
+type Post struct {
+	Line    int
+	Request dto.CreatePostRequest
+}
+
+// Options — значения по умолчанию для строк файла
+type Options struct {
+	Location *time.Location
+	Now      time.Time
+}
+
+// Format определяет формат по параметру запроса, Content-Type или имени файла
+func Format(format string, contentType string, filename string) (string, error) {
+	switch strings.ToLower(format) {
+	case FormatCSV:
+		return FormatCSV, nil
+	case FormatNDJSON, "jsonl", "json":
+		return FormatNDJSON, nil
+	case "":
+	default:
+		return "", ErrUnknownFormat
+	}
+	switch {
+	case strings.HasPrefix(contentType, "text/csv"):
+		return FormatCSV, nil
+	case strings.HasPrefix(contentType, "application/x-ndjson"),
+		strings.HasPrefix(contentType, "application/jsonl"),
+		strings.HasPrefix(contentType, "application/json"):
+		return FormatNDJSON, nil
+	}
+	name := strings.ToLower(filename)
+	switch {
+	case strings.HasSuffix(name, ".csv"):
+		return FormatCSV, nil
+	case strings.HasSuffix(name, ".ndjson"), strings.HasSuffix(name, ".jsonl"):
+		return FormatNDJSON, nil
+	}
+	return "", ErrUnknownFormat
+}
+
+// Parse читает файл целиком. Ошибка возвращается, только если файл нельзя разобрать вообще,
+// ошибки отдельных строк лежат в Record.Err.
+func Parse(r io.Reader, format string) ([]Record, error) {
+	switch format {
+	case FormatCSV:
+		return parseCSV(r)
+	case FormatNDJSON:
+		return parseNDJSON(r)
+	}
+	return nil, ErrUnknownFormat
+}
+
+func parseCSV(r io.Reader) ([]Record, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("file is empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid csv header: %w", err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		// в API поле пишется sheduled_for, принимаем оба варианта
+		if name == "sheduled_for" {
+			name = ColumnScheduledFor
+		}
+		columns[name] = i
+	}
+	for _, name := range []string{ColumnTitle, ColumnContent} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("csv header has no %q column", name)
+		}
+	}
+	field := func(row []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(row) {
+			return ""
+		}
+		return row[i]
+	}
+
+	res := []Record{}
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				// после ошибки в кавычках остаток файла не читается
+				res = append(res, Record{Line: parseErr.StartLine, Err: parseErr.Err})
+				return res, nil
+			}
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		res = append(res, Record{
+			Line:          line,
+			Title:         field(row, ColumnTitle),
+			Content:       field(row, ColumnContent),
+			Scheduled_for: field(row, ColumnScheduledFor),
+			Platforms:     splitTargets(field(row, ColumnPlatforms)),
+			Timezone:      field(row, ColumnTimezone),
+		})
+	}
+	return res, nil
+}
+
+func parseNDJSON(r io.Reader) ([]Record, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	res := []Record{}
+	line := 0
+	for scanner.Scan() {
+		line++
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+		var rec Record
+		if err := json.Unmarshal(data, &rec); err != nil {
+			rec = Record{Err: fmt.Errorf("invalid json: %w", err)}
+		}
+		rec.Line = line
+		res = append(res, rec)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(res) == 0 {
+		return nil, errors.New("file is empty")
+	}
+	return res, nil
+}
+
+func splitTargets(s string) Targets {
+	res := Targets{}
+	for _, part := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ';' }) {
+		if part = strings.TrimSpace(part); part != "" {
+			res = append(res, part)
+		}
+	}
+	return res
+}
+
+// Check проверяет строку и собирает запрос на создание поста.
+// Платформы ищутся по id или имени без учёта регистра, имя выбирает все платформы с этим именем.
+// Пустой список платформ — все платформы пространства.
+func Check(rec Record, platforms []domain.Platform, opts Options) (Post, []string) {
+	if rec.Err != nil {
+		return Post{}, []string{rec.Err.Error()}
+	}
+	var problems []string
+	post := Post{Line: rec.Line, Request: dto.CreatePostRequest{
+		Title:   strings.TrimSpace(rec.Title),
+		Content: strings.TrimSpace(rec.Content),
+	}}
+	if err := validate.Struct(&post.Request); err != nil {
+		var fields validator.ValidationErrors
+		if errors.As(err, &fields) {
+			for _, f := range fields {
+				problems = append(problems, fmt.Sprintf("%s: failed on %s", strings.ToLower(f.Field()), f.Tag()))
+			}
+		} else {
+			problems = append(problems, err.Error())
+		}
+	}
+
+	loc := opts.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	if tz := strings.TrimSpace(rec.Timezone); tz != "" {
+		l, err := time.LoadLocation(tz)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("unknown timezone %q", tz))
+		} else {
+			loc = l
+		}
+	}
+	if s := strings.TrimSpace(rec.Scheduled_for); s != "" {
+		at, err := parseTime(s, loc)
+		switch {
+		case err != nil:
+			problems = append(problems, err.Error())
+		case !opts.Now.IsZero() && at.Before(opts.Now):
+			problems = append(problems, "scheduled_for is in the past")
+		default:
+			post.Request.Sheduled_for = at
+		}
+	}
+
+	for _, target := range rec.Platforms {
+		ids := resolve(target, platforms)
+		if len(ids) == 0 {
+			problems = append(problems, fmt.Sprintf("unknown platform %q", target))
+		}
+		for _, id := range ids {
+			if !slices.Contains(post.Request.Platforms, id) {
+				post.Request.Platforms = append(post.Request.Platforms, id)
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return Post{}, problems
+	}
+	return post, nil
+}
+
+func parseTime(s string, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	for _, layout := range localLayouts {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid scheduled_for %q", s)
+}
+
+func resolve(target string, platforms []domain.Platform) []int {
+	if id, err := strconv.Atoi(target); err == nil {
+		for _, p := range platforms {
+			if p.ID_platform == id {
+				return []int{id}
+			}
+		}
+		return nil
+	}
+	var res []int
+	for _, p := range platforms {
+		if strings.EqualFold(p.Name, target) {
+			res = append(res, p.ID_platform)
+		}
+	}
+	return res
+}
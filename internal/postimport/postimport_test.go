@@ -0,0 +1,117 @@
+package postimport
+
+import (
+	"hexlet/internal/domain"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var platforms = []domain.Platform{
+	{ID_platform: 1, Name: domain.PlatformTelegram},
+	{ID_platform: 2, Name: domain.PlatformVK},
+	{ID_platform: 3, Name: domain.PlatformTelegram},
+}
+
+func TestFormat(t *testing.T) {
+	cases := []struct {
+		format, contentType, filename, want string
+	}{
+		{"csv", "application/json", "", FormatCSV},
+		{"", "text/csv; charset=utf-8", "", FormatCSV},
+		{"", "application/x-ndjson", "", FormatNDJSON},
+		{"", "application/octet-stream", "plan.CSV", FormatCSV},
+		{"", "", "plan.jsonl", FormatNDJSON},
+	}
+	for _, c := range cases {
+		got, err := Format(c.format, c.contentType, c.filename)
+		assert.NoError(t, err)
+		assert.Equal(t, c.want, got)
+	}
+	_, err := Format("xlsx", "", "")
+	assert.ErrorIs(t, err, ErrUnknownFormat)
+}
+
+func TestParseCSV(t *testing.T) {
+	file := "\ufeffTitle,content,sheduled_for,platforms\n" +
+		"First post,\"multi\nline\",2026-11-01 10:00,Telegram;VK\n" +
+		"Second post,text,,\n"
+	records, err := Parse(strings.NewReader(file), FormatCSV)
+	assert.NoError(t, err)
+	assert.Len(t, records, 2)
+	assert.Equal(t, 2, records[0].Line)
+	assert.Equal(t, "multi\nline", records[0].Content)
+	assert.Equal(t, "2026-11-01 10:00", records[0].Scheduled_for)
+	assert.Equal(t, Targets{"Telegram", "VK"}, records[0].Platforms)
+	assert.Equal(t, 4, records[1].Line)
+}
+
+func TestParseCSV_MissingColumn(t *testing.T) {
+	_, err := Parse(strings.NewReader("title,scheduled_for\nPost,\n"), FormatCSV)
+	assert.Error(t, err)
+}
+
+func TestParseNDJSON(t *testing.T) {
+	file := `{"title":"First post","content":"text","platforms":["VK",3]}
+
+not json
+{"title":"Third post","content":"text","platforms":"Telegram, VK"}
+`
+	records, err := Parse(strings.NewReader(file), FormatNDJSON)
+	assert.NoError(t, err)
+	assert.Len(t, records, 3)
+	assert.Equal(t, Targets{"VK", "3"}, records[0].Platforms)
+	assert.Equal(t, 3, records[1].Line)
+	assert.Error(t, records[1].Err)
+	assert.Equal(t, 4, records[2].Line)
+	assert.Equal(t, Targets{"Telegram", "VK"}, records[2].Platforms)
+}
+
+func TestCheck(t *testing.T) {
+	now := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	post, problems := Check(Record{
+		Line:          2,
+		Title:         " Launch ",
+		Content:       "text",
+		Scheduled_for: "2026-11-01 10:00",
+		Platforms:     Targets{"telegram", "2", "1"},
+		Timezone:      "Europe/Moscow",
+	}, platforms, Options{Now: now})
+	assert.Empty(t, problems)
+	assert.Equal(t, 2, post.Line)
+	assert.Equal(t, "Launch", post.Request.Title)
+	assert.True(t, post.Request.Sheduled_for.Equal(time.Date(2026, 11, 1, 7, 0, 0, 0, time.UTC)))
+	assert.Equal(t, []int{1, 3, 2}, post.Request.Platforms)
+}
+
+func TestCheck_OffsetWinsOverTimezone(t *testing.T) {
+	post, problems := Check(Record{
+		Title:         "Launch",
+		Content:       "text",
+		Scheduled_for: "2026-11-01T10:00:00Z",
+		Timezone:      "Europe/Moscow",
+	}, platforms, Options{})
+	assert.Empty(t, problems)
+	assert.True(t, post.Request.Sheduled_for.Equal(time.Date(2026, 11, 1, 10, 0, 0, 0, time.UTC)))
+	assert.Empty(t, post.Request.Platforms)
+}
+
+func TestCheck_ReportsEveryProblem(t *testing.T) {
+	now := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	_, problems := Check(Record{
+		Title:         "Go",
+		Scheduled_for: "2026-01-01 10:00",
+		Platforms:     Targets{"Twitter", "42"},
+		Timezone:      "Mars/Olympus",
+	}, platforms, Options{Now: now})
+	assert.Equal(t, []string{
+		"title: failed on min",
+		"content: failed on required",
+		`unknown timezone "Mars/Olympus"`,
+		"scheduled_for is in the past",
+		`unknown platform "Twitter"`,
+		`unknown platform "42"`,
+	}, problems)
+}
@@ -0,0 +1,93 @@
+package posttemplate
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Встроенные переменные подставляются при публикации
+const (
+	VarDate     = "date"
+	VarTime     = "time"
+	VarPlatform = "platform"
+)
+
+var placeholder = regexp.MustCompile(`\{\{\s*([a-zA-Z_][a-zA-Z0-9_]*)\s*\}\}`)
+
+// MissingError — в тексте остались незаполненные переменные
+type MissingError struct {
+	Names []string
+}
+
+func (e *MissingError) Error() string {
+	return fmt.Sprintf("unfilled placeholders: %s", strings.Join(e.Names, ", "))
+}
+
+// IsBuiltin: переменная заполняется при публикации
+func IsBuiltin(name string) bool {
+	return name == VarDate || name == VarTime || name == VarPlatform
+}
+
+// Placeholders возвращает имена переменных из текстов без повторов, в порядке появления
+func Placeholders(texts ...string) []string {
+	res := []string{}
+	seen := map[string]bool{}
+	for _, text := range texts {
+		for _, m := range placeholder.FindAllStringSubmatch(text, -1) {
+			if !seen[m[1]] {
+				seen[m[1]] = true
+				res = append(res, m[1])
+			}
+		}
+	}
+	return res
+}
+
+// Missing — переменные текстов, которых нет в vars, кроме встроенных
+func Missing(vars map[string]string, texts ...string) []string {
+	res := []string{}
+	for _, name := range Placeholders(texts...) {
+		if _, ok := vars[name]; !ok && !IsBuiltin(name) {
+			res = append(res, name)
+		}
+	}
+	sort.Strings(res)
+	return res
+}
+
+// Fill подставляет известные переменные и оставляет остальные как есть
+func Fill(text string, vars map[string]string) string {
+	return placeholder.ReplaceAllStringFunc(text, func(m string) string {
+		name := placeholder.FindStringSubmatch(m)[1]
+		if v, ok := vars[name]; ok {
+			return v
+		}
+		return m
+	})
+}
+
+// Render заполняет все переменные, включая встроенные для платформы и момента публикации.
+// Дата и время показываются в часовом поясе автора loc.
+// Если что-то осталось незаполненным, возвращает *MissingError.
+func Render(text string, vars map[string]string, platform string, at time.Time, loc *time.Location) (string, error) {
+	all := Builtins(platform, at, loc)
+	for k, v := range vars {
+		all[k] = v
+	}
+	if missing := Missing(all, text); len(missing) > 0 {
+		return "", &MissingError{Names: missing}
+	}
+	return Fill(text, all), nil
+}
+
+func Builtins(platform string, at time.Time, loc *time.Location) map[string]string {
+	at = at.In(loc)
+	return map[string]string{
+		VarDate:     at.Format("02.01.2006"),
+		VarTime:     at.Format("15:04"),
+		VarPlatform: platform,
+	}
+}
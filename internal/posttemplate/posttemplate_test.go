@@ -0,0 +1,65 @@
+package posttemplate
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlaceholders(t *testing.T) {
+	names := Placeholders("{{ event }} on {{date}}", "{{event}} in {{city}}")
+	assert.Equal(t, []string{"event", "date", "city"}, names)
+}
+
+func TestMissing_IgnoresBuiltins(t *testing.T) {
+	missing := Missing(map[string]string{"event": "Meetup"}, "{{event}} {{date}} {{platform}} {{link}} {{city}}")
+	assert.Equal(t, []string{"city", "link"}, missing)
+}
+
+func TestFill_KeepsUnknown(t *testing.T) {
+	text := Fill("{{event}} at {{link}}", map[string]string{"event": "Meetup"})
+	assert.Equal(t, "Meetup at {{link}}", text)
+}
+
+func TestRender(t *testing.T) {
+	at := time.Date(2026, 3, 1, 21, 30, 0, 0, time.UTC)
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	assert.NoError(t, err)
+	text, err := Render("{{event}} in {{platform}}, {{date}} {{time}}", map[string]string{"event": "Meetup"}, "Telegram", at, moscow)
+	assert.NoError(t, err)
+	assert.Equal(t, "Meetup in Telegram, 02.03.2026 00:30", text)
+}
+
+func TestRender_AuthorLocation(t *testing.T) {
+	at := time.Date(2026, 3, 1, 21, 30, 0, 0, time.UTC)
+	newYork, err := time.LoadLocation("America/New_York")
+	assert.NoError(t, err)
+	text, err := Render("{{date}} {{time}}", nil, "VK", at, newYork)
+	assert.NoError(t, err)
+	assert.Equal(t, "01.03.2026 16:30", text)
+
+	text, err = Render("{{date}} {{time}}", nil, "VK", at, time.UTC)
+	assert.NoError(t, err)
+	assert.Equal(t, "01.03.2026 21:30", text)
+}
+
+func TestRender_Missing(t *testing.T) {
+	_, err := Render("{{event}} {{link}}", nil, "VK", time.Now(), time.UTC)
+	var missing *MissingError
+	assert.True(t, errors.As(err, &missing))
+	assert.Equal(t, []string{"event", "link"}, missing.Names)
+}
+
+func TestRender_CustomOverridesBuiltin(t *testing.T) {
+	text, err := Render("{{platform}}", map[string]string{"platform": "our channel"}, "VK", time.Now(), time.UTC)
+	assert.NoError(t, err)
+	assert.Equal(t, "our channel", text)
+}
+
+func TestRender_ValuesLiteral(t *testing.T) {
+	text, err := Render("{{note}}", map[string]string{"note": "write {{name}} on {{date}}"}, "VK", time.Now(), time.UTC)
+	assert.NoError(t, err)
+	assert.Equal(t, "write {{name}} on {{date}}", text)
+}
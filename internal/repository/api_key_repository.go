@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"hexlet/internal/domain"
+	"hexlet/internal/dto"
+
+	"github.com/jackc/pgx/v4"
+	"go.uber.org/zap"
+)
+
+var ErrApiKeyInvalid = errors.New("api key is invalid, expired or revoked")
+
+const apiKeyColumns = "id, user_id, name, prefix, scopes, expires_at, last_used_at, created_at"
+
+func scanApiKey(row pgx.Row) (domain.ApiKey, error) {
+	var k domain.ApiKey
+	err := row.Scan(&k.ID_key, &k.ID_user, &k.Name, &k.Prefix, &k.Scopes, &k.Expires_at, &k.Last_used_at, &k.Created_at)
+	return k, err
+}
+
+func (r *Repository) CreateApiKey(ctx context.Context, ID_key string, prefix string, keyHash string, req dto.CreateApiKeyRequest) (domain.ApiKey, error) {
+	res, err := scanApiKey(r.MasterPool.QueryRow(ctx, `
+		INSERT INTO api_keys (id, user_id, name, prefix, key_hash, scopes, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING `+apiKeyColumns,
+		ID_key, req.ID_user, req.Name, prefix, keyHash, req.Scopes, req.Expires_at,
+	))
+	if err != nil {
+		r.logger.Error("CreateApiKey failed",
+			zap.Error(err),
+			zap.String("user_id", req.ID_user),
+		)
+		return domain.ApiKey{}, err
+	}
+	return res, nil
+}
+
+// GetApiKeys возвращает действующие ключи пользователя, отозванные не показываются
+func (r *Repository) GetApiKeys(ctx context.Context, ID_user string) ([]domain.ApiKey, error) {
+	rows, err := r.SlavePool.Query(ctx, `
+		SELECT `+apiKeyColumns+`
+		FROM api_keys
+		WHERE user_id = $1 AND revoked_at IS NULL
+		ORDER BY created_at DESC`,
+		ID_user,
+	)
+	if err != nil {
+		r.logger.Error("GetApiKeys failed",
+			zap.Error(err),
+			zap.String("user_id", ID_user),
+		)
+		return nil, err
+	}
+	defer rows.Close()
+	res := []domain.ApiKey{}
+	for rows.Next() {
+		k, err := scanApiKey(rows)
+		if err != nil {
+			r.logger.Error("GetApiKeys failed in scaning",
+				zap.Error(err),
+				zap.String("user_id", ID_user),
+			)
+			return nil, err
+		}
+		res = append(res, k)
+	}
+	return res, rows.Err()
+}
+
+// RevokeApiKey отзывает ключ пользователя, false — действующего ключа с таким id нет
+func (r *Repository) RevokeApiKey(ctx context.Context, ID_key string, ID_user string) (bool, error) {
+	tag, err := r.MasterPool.Exec(ctx, `
+		UPDATE api_keys
+		SET revoked_at = NOW()
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL`,
+		ID_key, ID_user,
+	)
+	if err != nil {
+		r.logger.Error("RevokeApiKey failed",
+			zap.Error(err),
+			zap.String("key_id", ID_key),
+			zap.String("user_id", ID_user),
+		)
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// UseApiKey находит действующий ключ по хэшу и обновляет время последнего использования
+func (r *Repository) UseApiKey(ctx context.Context, keyHash string) (domain.ApiKey, error) {
+	res, err := scanApiKey(r.MasterPool.QueryRow(ctx, `
+		UPDATE api_keys
+		SET last_used_at = NOW()
+		WHERE key_hash = $1 AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > NOW())
+		RETURNING `+apiKeyColumns,
+		keyHash,
+	))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.ApiKey{}, ErrApiKeyInvalid
+	}
+	if err != nil {
+		r.logger.Error("UseApiKey failed", zap.Error(err))
+		return domain.ApiKey{}, err
+	}
+	return res, nil
+}
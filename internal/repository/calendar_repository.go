@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"hexlet/internal/domain"
+
+	"github.com/jackc/pgx/v4"
+	"go.uber.org/zap"
+)
+
+var ErrCalendarNotFound = errors.New("calendar not found")
+
+// за сколько прошлых дней публикации попадают в календарь
+const calendarHistoryDays = 90
+
+// CreateCalendarToken заменяет ссылку на календарь: прежняя перестаёт работать
+func (r *Repository) CreateCalendarToken(ctx context.Context, ID_user string, tokenHash string) error {
+	_, err := r.MasterPool.Exec(ctx, `
+		INSERT INTO calendar_feeds (user_id, token_hash)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE
+		SET token_hash = EXCLUDED.token_hash, created_at = NOW(), last_used_at = NULL`,
+		ID_user, tokenHash,
+	)
+	if err != nil {
+		r.logger.Error("CreateCalendarToken failed",
+			zap.Error(err),
+			zap.String("user_id", ID_user),
+		)
+	}
+	return err
+}
+
+func (r *Repository) DeleteCalendarToken(ctx context.Context, ID_user string) (bool, error) {
+	tag, err := r.MasterPool.Exec(ctx, "DELETE FROM calendar_feeds WHERE user_id = $1", ID_user)
+	if err != nil {
+		r.logger.Error("DeleteCalendarToken failed",
+			zap.Error(err),
+			zap.String("user_id", ID_user),
+		)
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// GetCalendarEvents возвращает публикации всех пространств, где состоит владелец токена:
+// запланированные и прошедшие за calendarHistoryDays дней. Черновики без времени не попадают.
+func (r *Repository) GetCalendarEvents(ctx context.Context, tokenHash string) ([]domain.CalendarEvent, error) {
+	var ID_user string
+	err := r.MasterPool.QueryRow(ctx, `
+		UPDATE calendar_feeds SET last_used_at = NOW()
+		WHERE token_hash = $1
+		RETURNING user_id`,
+		tokenHash,
+	).Scan(&ID_user)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrCalendarNotFound
+	}
+	if err != nil {
+		r.logger.Error("GetCalendarEvents failed in token lookup",
+			zap.Error(err),
+		)
+		return nil, err
+	}
+	rows, err := r.SlavePool.Query(ctx, `
+		SELECT d.id, d.post_id, w.id, w.name, p.title, pl.platform_name, d.status, d.scheduled_for, d.published_at, d.error_message
+		FROM post_destinations d
+		JOIN posts p ON p.id = d.post_id
+		JOIN platforms pl ON pl.id = d.platform_id
+		JOIN workspaces w ON w.id = d.workspace_id
+		JOIN workspace_members m ON m.workspace_id = d.workspace_id AND m.user_id = $1
+		WHERE d.scheduled_for IS NOT NULL
+		AND d.status <> 'draft'
+		AND d.scheduled_for >= NOW() - make_interval(days => $2)
+		ORDER BY d.scheduled_for, d.id`,
+		ID_user, calendarHistoryDays,
+	)
+	if err != nil {
+		r.logger.Error("GetCalendarEvents failed",
+			zap.Error(err),
+			zap.String("user_id", ID_user),
+		)
+		return nil, err
+	}
+	defer rows.Close()
+	res := []domain.CalendarEvent{}
+	for rows.Next() {
+		var e domain.CalendarEvent
+		err := rows.Scan(&e.ID_destination, &e.ID_post, &e.ID_workspace, &e.Workspace_name, &e.Title, &e.PlatformName,
+			&e.Status, &e.Sheduled_for, &e.Published_at, &e.ErrorMessage)
+		if err != nil {
+			r.logger.Error("GetCalendarEvents failed in scaning",
+				zap.Error(err),
+				zap.String("user_id", ID_user),
+			)
+			return nil, err
+		}
+		res = append(res, e)
+	}
+	return res, rows.Err()
+}
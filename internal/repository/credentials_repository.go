@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hexlet/internal/domain"
+
+	"go.uber.org/zap"
+)
+
+// ReencryptCredentials перешифровывает api_config всех платформ активным мастер-ключом.
+// Открытые значения, оставшиеся со старых версий, шифруются.
+// Возвращает количество обновлённых платформ.
+func (r *Repository) ReencryptCredentials(ctx context.Context) (int, error) {
+	rows, err := r.MasterPool.Query(ctx, "SELECT id, api_config FROM platforms WHERE api_config IS NOT NULL")
+	if err != nil {
+		r.logger.Error("ReencryptCredentials failed in selecting from platforms", zap.Error(err))
+		return 0, err
+	}
+	type platformConfig struct {
+		id     int
+		config domain.PlatformConfig
+	}
+	var platforms []platformConfig
+	for rows.Next() {
+		var p platformConfig
+		if err := rows.Scan(&p.id, &p.config); err != nil {
+			rows.Close()
+			r.logger.Error("ReencryptCredentials failed in scaning", zap.Error(err))
+			return 0, err
+		}
+		platforms = append(platforms, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	updated := 0
+	for _, p := range platforms {
+		changed := false
+		for _, field := range p.config.SecretFields() {
+			rewrapped, ok, err := r.keyring.Rewrap(*field)
+			if err != nil {
+				r.logger.Error("ReencryptCredentials failed in rewrapping",
+					zap.Error(err),
+					zap.Int("platform_id", p.id),
+				)
+				return updated, fmt.Errorf("platform %d: %w", p.id, err)
+			}
+			if ok {
+				*field = rewrapped
+				changed = true
+			}
+		}
+		if !changed {
+			continue
+		}
+		configBytes, err := json.Marshal(p.config)
+		if err != nil {
+			return updated, err
+		}
+		_, err = r.MasterPool.Exec(ctx, "UPDATE platforms SET api_config = $1 WHERE id = $2", configBytes, p.id)
+		if err != nil {
+			r.logger.Error("ReencryptCredentials failed in updating platforms",
+				zap.Error(err),
+				zap.Int("platform_id", p.id),
+			)
+			return updated, err
+		}
+		updated++
+	}
+	return updated, nil
+}
+
+func (r *Repository) encryptConfig(cfg domain.PlatformConfig) (domain.PlatformConfig, error) {
+	res := cfg.Clone()
+	for _, field := range res.SecretFields() {
+		enc, err := r.keyring.Encrypt(*field)
+		if err != nil {
+			return domain.PlatformConfig{}, err
+		}
+		*field = enc
+	}
+	return res, nil
+}
+
+func (r *Repository) decryptConfig(cfg domain.PlatformConfig) (domain.PlatformConfig, error) {
+	res := cfg.Clone()
+	for _, field := range res.SecretFields() {
+		dec, err := r.keyring.Decrypt(*field)
+		if err != nil {
+			return domain.PlatformConfig{}, err
+		}
+		*field = dec
+	}
+	return res, nil
+}
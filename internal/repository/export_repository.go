@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"hexlet/internal/domain"
+	"hexlet/internal/dto"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v4"
+	"go.uber.org/zap"
+)
+
+// строк за один FETCH из курсора выгрузки
+const exportBatch = 500
+
+// postFilter дописывает условия фильтра к запросу по post_destinations d и posts p
+func postFilter(filter dto.PostFilter, args []any) (string, []any) {
+	var sb strings.Builder
+	add := func(cond string, value any) {
+		args = append(args, value)
+		fmt.Fprintf(&sb, " AND "+cond, len(args))
+	}
+	if filter.Status != "" {
+		add("d.status = $%d", filter.Status)
+	}
+	if filter.Review_status != "" {
+		add("p.status = $%d", filter.Review_status)
+	}
+	if filter.ID_platform != 0 {
+		add("d.platform_id = $%d", filter.ID_platform)
+	}
+	// границы приходят во времени API
+	if !filter.From.IsZero() {
+		add("d.scheduled_for >= $%d", FromAPITime(filter.From))
+	}
+	if !filter.To.IsZero() {
+		add("d.scheduled_for < $%d", FromAPITime(filter.To))
+	}
+	return sb.String(), args
+}
+
+// ExportPosts читает публикации из курсора реплики пачками по exportBatch строк
+// и передаёт каждую в fn, поэтому выгрузка не держит в памяти все строки.
+func (r *Repository) ExportPosts(ctx context.Context, ID_workspace int, filter dto.PostFilter, fn func(domain.ExportRow) error) error {
+	where, args := postFilter(filter, []any{ID_workspace})
+	err := r.SlavePool.BeginTxFunc(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly}, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, `
+			DECLARE export_posts NO SCROLL CURSOR FOR
+			SELECT p.id, p.user_id, p.title, p.content, p.status, p.revision, p.created_at,
+				d.id, d.platform_id, pl.platform_name, d.status, d.scheduled_for, d.published_at, d.revision, d.remote_id, d.error_message
+			FROM post_destinations d
+			JOIN posts p ON p.id = d.post_id
+			JOIN platforms pl ON pl.id = d.platform_id
+			WHERE d.workspace_id = $1`+where+`
+			ORDER BY p.id, d.id`,
+			args...,
+		)
+		if err != nil {
+			return err
+		}
+		fetch := "FETCH " + strconv.Itoa(exportBatch) + " FROM export_posts"
+		for {
+			rows, err := tx.Query(ctx, fetch)
+			if err != nil {
+				return err
+			}
+			n := 0
+			for rows.Next() {
+				n++
+				var row domain.ExportRow
+				err := rows.Scan(&row.ID_post, &row.ID_user, &row.Title, &row.Content, &row.Review_status, &row.Revision, &row.Created_at,
+					&row.ID_destination, &row.ID_platform, &row.PlatformName, &row.Status, &row.Sheduled_for, &row.Published_at,
+					&row.Published_revision, &row.Remote_id, &row.ErrorMessage)
+				if err != nil {
+					rows.Close()
+					return err
+				}
+				// время отдаётся так же, как в GET /posts
+				row.Created_at = ToAPITime(row.Created_at)
+				row.Sheduled_for = toAPITimePtr(row.Sheduled_for)
+				row.Published_at = toAPITimePtr(row.Published_at)
+				if err := fn(row); err != nil {
+					rows.Close()
+					return err
+				}
+			}
+			rows.Close()
+			if err := rows.Err(); err != nil {
+				return err
+			}
+			if n < exportBatch {
+				return nil
+			}
+		}
+	})
+	if err != nil {
+		r.logger.Error("ExportPosts failed",
+			zap.Error(err),
+			zap.Int("workspace_id", ID_workspace),
+		)
+	}
+	return err
+}
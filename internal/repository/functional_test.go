@@ -2,16 +2,26 @@ package repository_test
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"hexlet/internal/domain"
 	"hexlet/internal/dto"
 	"hexlet/internal/repository"
+	"hexlet/internal/secrets"
+	"hexlet/internal/tracing"
 	"os"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/ory/dockertest/v3"
 	"github.com/ory/dockertest/v3/docker"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 	"go.uber.org/zap"
 )
 
@@ -21,8 +31,24 @@ var (
 	dockerPool *dockertest.Pool
 	resource   *dockertest.Resource
 	ctx        context.Context
+
+	databaseUrl string
+
+	testKeyring *secrets.Keyring
 )
 
+func testTelegramConfig(chat, token string) *domain.TelegramConfig {
+	return &domain.TelegramConfig{BotToken: "123:" + token, ChatID: "@" + chat}
+}
+
+func testVKConfig(owner, token string) *domain.VKConfig {
+	return &domain.VKConfig{AccessToken: token, OwnerID: owner}
+}
+
+func newTestKeyring() (*secrets.Keyring, error) {
+	return secrets.NewKeyring("test", map[string][]byte{"test": []byte("0123456789abcdef0123456789abcdef")})
+}
+
 func TestMain(m *testing.M) {
 	var err error
 	ctx = context.Background()
@@ -57,7 +83,7 @@ func TestMain(m *testing.M) {
 	}
 
 	hostAndPort := resource.GetHostPort("5432/tcp")
-	databaseUrl := fmt.Sprintf("postgres://testuser:testpass@%s/testdb?sslmode=disable", hostAndPort)
+	databaseUrl = fmt.Sprintf("postgres://testuser:testpass@%s/testdb?sslmode=disable", hostAndPort)
 
 	resource.Expire(120)
 
@@ -82,7 +108,14 @@ func TestMain(m *testing.M) {
 	}
 
 	logger := zap.NewNop()
-	testRepo = repository.NewRepository(testPool, testPool, logger)
+	testKeyring, err = newTestKeyring()
+	if err != nil {
+		fmt.Printf("Could not create keyring: %s\n", err)
+		testPool.Close()
+		dockerPool.Purge(resource)
+		os.Exit(1)
+	}
+	testRepo = repository.NewRepository(testPool, testPool, testKeyring, logger)
 
 	code := m.Run()
 
@@ -99,8 +132,15 @@ func createTables() error {
 		CREATE TABLE IF NOT EXISTS posts (
 			id SERIAL PRIMARY KEY,
 			user_id TEXT NOT NULL,
+			workspace_id INTEGER NOT NULL,
 			title VARCHAR(255) NOT NULL,
 			content TEXT NOT NULL,
+			status VARCHAR(20) NOT NULL DEFAULT 'draft',
+			submitted_at TIMESTAMP WITH TIME ZONE,
+			revision INTEGER NOT NULL DEFAULT 1,
+			template_id INTEGER,
+			variables JSONB,
+			trace_parent TEXT,
 			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
 		)
 	`)
@@ -112,9 +152,16 @@ func createTables() error {
 		CREATE TABLE IF NOT EXISTS platforms (
 			id SERIAL PRIMARY KEY,
 			user_id TEXT NOT NULL,
+			workspace_id INTEGER NOT NULL,
 			platform_name VARCHAR(50) NOT NULL,
 			api_config JSONB,
 			is_active BOOLEAN DEFAULT true,
+			health_status VARCHAR(20) NOT NULL DEFAULT 'healthy',
+			auth_failures INTEGER NOT NULL DEFAULT 0,
+			paused_at TIMESTAMP WITH TIME ZONE,
+			verification JSONB,
+			config_version INTEGER NOT NULL DEFAULT 0,
+			required_approvals INTEGER,
 			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
 			updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
 		)
@@ -127,12 +174,328 @@ func createTables() error {
 		CREATE TABLE IF NOT EXISTS post_destinations (
 			id SERIAL PRIMARY KEY,
 			user_id TEXT NOT NULL,
+			workspace_id INTEGER NOT NULL,
 			post_id INTEGER NOT NULL,
 			platform_id INTEGER NOT NULL,
 			scheduled_for TIMESTAMP WITH TIME ZONE,
 			published_at TIMESTAMP WITH TIME ZONE,
-			status VARCHAR(20) DEFAULT 'scheduled' CHECK (status IN ('scheduled', 'published', 'failed','processing')),
+			status VARCHAR(20) DEFAULT 'scheduled' CHECK (status IN ('draft', 'scheduled', 'published', 'failed','processing', 'held', 'skipped')),
 			error_message TEXT,
+			revision INTEGER,
+			remote_id VARCHAR(255),
+			processing_started_at TIMESTAMP WITH TIME ZONE,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = testPool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS users (
+			id TEXT PRIMARY KEY DEFAULT gen_random_uuid()::text,
+			email VARCHAR(255),
+			name VARCHAR(255),
+			avatar_url TEXT,
+			timezone VARCHAR(64) NOT NULL DEFAULT 'UTC',
+			password_hash TEXT,
+			email_verified_at TIMESTAMP WITH TIME ZONE,
+			failed_logins INTEGER NOT NULL DEFAULT 0,
+			locked_until TIMESTAMP WITH TIME ZONE,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = testPool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS auth_tokens (
+			token_hash CHAR(64) PRIMARY KEY,
+			user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			purpose VARCHAR(20) NOT NULL,
+			expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			used_at TIMESTAMP WITH TIME ZONE,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = testPool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS user_identities (
+			provider VARCHAR(50) NOT NULL,
+			provider_user_id VARCHAR(255) NOT NULL,
+			user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (provider, provider_user_id)
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = testPool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS sessions (
+			id VARCHAR(36) PRIMARY KEY,
+			user_id TEXT NOT NULL,
+			user_agent TEXT,
+			ip VARCHAR(64),
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+			last_used_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+			expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			revoked_at TIMESTAMP WITH TIME ZONE,
+			workspace_id INTEGER
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = testPool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS refresh_tokens (
+			jti VARCHAR(36) PRIMARY KEY,
+			session_id VARCHAR(36) NOT NULL REFERENCES sessions(id) ON DELETE CASCADE,
+			token_hash CHAR(64) NOT NULL,
+			expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			used_at TIMESTAMP WITH TIME ZONE,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = testPool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS workspaces (
+			id SERIAL PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			required_approvals INTEGER NOT NULL DEFAULT 0,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = testPool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS workspace_members (
+			workspace_id INTEGER NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+			user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			role VARCHAR(20) NOT NULL,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (workspace_id, user_id)
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = testPool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS workspace_invitations (
+			token_hash CHAR(64) PRIMARY KEY,
+			workspace_id INTEGER NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+			email VARCHAR(255) NOT NULL,
+			role VARCHAR(20) NOT NULL,
+			invited_by TEXT NOT NULL,
+			expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			accepted_at TIMESTAMP WITH TIME ZONE,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = testPool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS post_reviews (
+			id SERIAL PRIMARY KEY,
+			post_id INTEGER NOT NULL,
+			user_id TEXT NOT NULL,
+			action VARCHAR(20) NOT NULL,
+			comment TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = testPool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS post_revisions (
+			post_id INTEGER NOT NULL,
+			revision INTEGER NOT NULL,
+			user_id TEXT NOT NULL,
+			title VARCHAR(255) NOT NULL,
+			content TEXT NOT NULL,
+			scheduled_for TIMESTAMP WITH TIME ZONE,
+			changed_fields TEXT[] NOT NULL,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (post_id, revision)
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = testPool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS post_templates (
+			id SERIAL PRIMARY KEY,
+			workspace_id INTEGER NOT NULL,
+			user_id TEXT NOT NULL,
+			name VARCHAR(100) NOT NULL,
+			title VARCHAR(255) NOT NULL,
+			content TEXT NOT NULL,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (workspace_id, name)
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = testPool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS post_import_jobs (
+			id UUID PRIMARY KEY,
+			workspace_id INTEGER NOT NULL,
+			user_id TEXT NOT NULL,
+			status VARCHAR(20) NOT NULL DEFAULT 'pending',
+			all_or_nothing BOOLEAN NOT NULL DEFAULT FALSE,
+			total INTEGER NOT NULL DEFAULT 0,
+			created INTEGER NOT NULL DEFAULT 0,
+			failed INTEGER NOT NULL DEFAULT 0,
+			errors JSONB NOT NULL DEFAULT '[]',
+			post_ids INTEGER[] NOT NULL DEFAULT '{}',
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+			finished_at TIMESTAMP WITH TIME ZONE,
+			updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = testPool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS calendar_feeds (
+			user_id TEXT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+			token_hash CHAR(64) NOT NULL UNIQUE,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+			last_used_at TIMESTAMP WITH TIME ZONE
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = testPool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS telegram_chats (
+			user_id TEXT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+			chat_id BIGINT NOT NULL UNIQUE,
+			username VARCHAR(255) NOT NULL DEFAULT '',
+			linked_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = testPool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS webhooks (
+			id SERIAL PRIMARY KEY,
+			workspace_id INTEGER NOT NULL,
+			user_id TEXT NOT NULL,
+			url TEXT NOT NULL,
+			secret TEXT NOT NULL,
+			events TEXT[] NOT NULL,
+			scope VARCHAR(20) NOT NULL DEFAULT 'workspace',
+			is_active BOOLEAN NOT NULL DEFAULT TRUE,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = testPool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS webhook_deliveries (
+			id SERIAL PRIMARY KEY,
+			webhook_id INTEGER NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+			event_id UUID NOT NULL,
+			event VARCHAR(50) NOT NULL,
+			payload JSONB NOT NULL,
+			status VARCHAR(20) NOT NULL DEFAULT 'pending',
+			attempts INTEGER NOT NULL DEFAULT 0,
+			next_attempt_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+			response_code INTEGER,
+			last_error TEXT,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+			delivered_at TIMESTAMP WITH TIME ZONE
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = testPool.Exec(ctx, `
+		CREATE OR REPLACE FUNCTION notify_destination_status() RETURNS trigger AS $$
+		BEGIN
+			PERFORM pg_notify('destination_status', json_build_object(
+				'id_destination', NEW.id,
+				'id_post', NEW.post_id,
+				'id_workspace', NEW.workspace_id,
+				'id_platform', NEW.platform_id,
+				'status', NEW.status,
+				'previous_status', OLD.status,
+				'error_message', left(NEW.error_message, 1000),
+				'changed_at', NOW()
+			)::text);
+			RETURN NEW;
+		END;
+		$$ LANGUAGE plpgsql;
+		DROP TRIGGER IF EXISTS trg_destination_status ON post_destinations;
+		CREATE TRIGGER trg_destination_status
+			AFTER UPDATE OF status ON post_destinations
+			FOR EACH ROW
+			WHEN (OLD.status IS DISTINCT FROM NEW.status)
+			EXECUTE FUNCTION notify_destination_status();
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = testPool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS api_keys (
+			id VARCHAR(36) PRIMARY KEY,
+			user_id TEXT NOT NULL,
+			name VARCHAR(255) NOT NULL,
+			prefix VARCHAR(16) NOT NULL,
+			key_hash CHAR(64) NOT NULL UNIQUE,
+			scopes TEXT[] NOT NULL,
+			expires_at TIMESTAMP WITH TIME ZONE,
+			last_used_at TIMESTAMP WITH TIME ZONE,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+			revoked_at TIMESTAMP WITH TIME ZONE
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = testPool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS notifications (
+			id SERIAL PRIMARY KEY,
+			user_id TEXT NOT NULL,
+			kind VARCHAR(50) NOT NULL,
+			message TEXT NOT NULL,
+			platform_id INTEGER,
+			workspace_id INTEGER,
+			post_id INTEGER,
+			destination_id INTEGER,
+			dispatched_at TIMESTAMP WITH TIME ZONE,
 			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
 		)
 	`)
@@ -140,16 +503,33 @@ func createTables() error {
 		return err
 	}
 
+	_, err = testPool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS notification_settings (
+			user_id TEXT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+			email BOOLEAN NOT NULL,
+			telegram BOOLEAN NOT NULL,
+			webhook BOOLEAN NOT NULL,
+			kinds TEXT[] NOT NULL,
+			delivery VARCHAR(20) NOT NULL CHECK (delivery IN ('immediate', 'hourly', 'daily')),
+			batch_minutes INTEGER NOT NULL CHECK (batch_minutes BETWEEN 0 AND 60),
+			digest_hour INTEGER NOT NULL CHECK (digest_hour BETWEEN 0 AND 23),
+			updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
 func cleanupTables() {
-	testPool.Exec(ctx, "TRUNCATE posts, post_destinations, platforms CASCADE")
+	testPool.Exec(ctx, "TRUNCATE posts, post_destinations, platforms, notifications, users, user_identities, sessions, refresh_tokens, auth_tokens, api_keys, workspaces, workspace_members, workspace_invitations, post_reviews, post_revisions, post_templates, post_import_jobs, calendar_feeds, telegram_chats, webhooks, webhook_deliveries, notification_settings RESTART IDENTITY CASCADE")
 }
 
 func TestNewRepository(t *testing.T) {
 	logger := zap.NewNop()
-	repo := repository.NewRepository(testPool, testPool, logger)
+	repo := repository.NewRepository(testPool, testPool, testKeyring, logger)
 	if repo == nil {
 		t.Error("Expected non-nil repository")
 	}
@@ -158,12 +538,11 @@ func TestNewRepository(t *testing.T) {
 func TestCreatePost(t *testing.T) {
 	cleanupTables()
 
-	configJSON := `{"token":"test_token"}`
 	platformReq := dto.CreatePlatformRequest{
 		ID_user:      "1",
+		ID_workspace: 1,
 		PlatformName: "telegram",
-		Bot_name:     "test_bot",
-		Config:       configJSON,
+		Telegram:     testTelegramConfig("test_bot", "test_token"),
 	}
 
 	platformID, _, err := testRepo.CreatePlatform(ctx, platformReq)
@@ -176,6 +555,7 @@ func TestCreatePost(t *testing.T) {
 
 	post := dto.CreatePostRequest{
 		ID_user:      "1",
+		ID_workspace: 1,
 		Title:        "First Post",
 		Content:      "Content 1",
 		Sheduled_for: time.Now().Add(24 * time.Hour),
@@ -192,7 +572,7 @@ func TestCreatePost(t *testing.T) {
 		t.Error("Expected non-zero created_at")
 	}
 
-	posts, err := testRepo.GetPost(ctx, "1")
+	posts, err := testRepo.GetPost(ctx, 1, dto.PostFilter{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -208,26 +588,25 @@ func TestCreatePost(t *testing.T) {
 func TestCreatePostWithMultiplePlatforms(t *testing.T) {
 	cleanupTables()
 
-	configJSON1 := `{"token":"token1"}`
 	platformReq1 := dto.CreatePlatformRequest{
 		ID_user:      "1",
+		ID_workspace: 1,
 		PlatformName: "telegram",
-		Bot_name:     "bot1",
-		Config:       configJSON1,
+		Telegram:     testTelegramConfig("bot1", "token1"),
 	}
 	testRepo.CreatePlatform(ctx, platformReq1)
 
-	configJSON2 := `{"token":"token2"}`
 	platformReq2 := dto.CreatePlatformRequest{
 		ID_user:      "1",
+		ID_workspace: 1,
 		PlatformName: "vk",
-		Bot_name:     "bot2",
-		Config:       configJSON2,
+		VK:           testVKConfig("-2", "token2"),
 	}
 	testRepo.CreatePlatform(ctx, platformReq2)
 
 	post := dto.CreatePostRequest{
 		ID_user:      "1",
+		ID_workspace: 1,
 		Title:        "Multi Platform Post",
 		Content:      "Content for multiple platforms",
 		Sheduled_for: time.Now().Add(24 * time.Hour),
@@ -256,6 +635,7 @@ func TestCreatePostNoPlatforms(t *testing.T) {
 
 	post := dto.CreatePostRequest{
 		ID_user:      "999",
+		ID_workspace: 999,
 		Title:        "Post Without Platforms",
 		Content:      "Content",
 		Sheduled_for: time.Now().Add(24 * time.Hour),
@@ -283,6 +663,7 @@ func TestCreatePostDatabaseError(t *testing.T) {
 
 	post := dto.CreatePostRequest{
 		ID_user:      "1",
+		ID_workspace: 1,
 		Title:        "Test Post",
 		Content:      "Content",
 		Sheduled_for: time.Now(),
@@ -297,7 +678,7 @@ func TestCreatePostDatabaseError(t *testing.T) {
 func TestGetPostEmpty(t *testing.T) {
 	cleanupTables()
 
-	posts, err := testRepo.GetPost(ctx, "999")
+	posts, err := testRepo.GetPost(ctx, 999, dto.PostFilter{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -320,36 +701,36 @@ func TestGetPostByStatus(t *testing.T) {
 	cleanupTables()
 
 	_, err := testPool.Exec(ctx, `
-		INSERT INTO platforms (id, user_id, platform_name, api_config) 
-		VALUES (1, '1', 'telegram', '{}')
+		INSERT INTO platforms (id, user_id, workspace_id, platform_name, api_config) 
+		VALUES (1, '1', 1, 'telegram', '{}')
 	`)
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	_, err = testPool.Exec(ctx, `
-		INSERT INTO posts (id, user_id, title, content) VALUES 
-		(1, '1', 'Scheduled Post', 'Scheduled Content'),
-		(2, '1', 'Published Post', 'Published Content'),
-		(3, '1', 'Failed Post', 'Failed Content'),
-		(4, '1', 'Processing Post', 'Processing Content')
+		INSERT INTO posts (id, user_id, workspace_id, title, content, status) VALUES 
+		(1, '1', 1, 'Scheduled Post', 'Scheduled Content', 'scheduled'),
+		(2, '1', 1, 'Published Post', 'Published Content', 'scheduled'),
+		(3, '1', 1, 'Failed Post', 'Failed Content', 'scheduled'),
+		(4, '1', 1, 'Processing Post', 'Processing Content', 'scheduled')
 	`)
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	_, err = testPool.Exec(ctx, `
-		INSERT INTO post_destinations (user_id, post_id, platform_id, scheduled_for, status, error_message) VALUES 
-		('1', 1, 1, NOW() + INTERVAL '1 day', 'scheduled', NULL),
-		('1', 2, 1, NOW() - INTERVAL '1 day', 'published', NULL),
-		('1', 3, 1, NOW() - INTERVAL '2 day', 'failed', 'API error'),
-		('1', 4, 1, NOW() + INTERVAL '2 day', 'processing', NULL)
+		INSERT INTO post_destinations (user_id, workspace_id, post_id, platform_id, scheduled_for, status, error_message) VALUES 
+		('1', 1, 1, 1, NOW() + INTERVAL '1 day', 'scheduled', NULL),
+		('1', 1, 2, 1, NOW() - INTERVAL '1 day', 'published', NULL),
+		('1', 1, 3, 1, NOW() - INTERVAL '2 day', 'failed', 'API error'),
+		('1', 1, 4, 1, NOW() + INTERVAL '2 day', 'processing', NULL)
 	`)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	posts, err := testRepo.GetPost(ctx, "1")
+	posts, err := testRepo.GetPost(ctx, 1, dto.PostFilter{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -381,12 +762,11 @@ func TestGetPostByStatus(t *testing.T) {
 func TestGetPostByID(t *testing.T) {
 	cleanupTables()
 
-	configJSON := `{"token":"test_token"}`
 	platformReq := dto.CreatePlatformRequest{
 		ID_user:      "1",
+		ID_workspace: 1,
 		PlatformName: "telegram",
-		Bot_name:     "test_bot",
-		Config:       configJSON,
+		Telegram:     testTelegramConfig("test_bot", "test_token"),
 	}
 
 	platformID, _, err := testRepo.CreatePlatform(ctx, platformReq)
@@ -396,6 +776,7 @@ func TestGetPostByID(t *testing.T) {
 
 	post := dto.CreatePostRequest{
 		ID_user:      "1",
+		ID_workspace: 1,
 		Title:        "Test Post By ID",
 		Content:      "Test Content By ID",
 		Sheduled_for: time.Now().Add(24 * time.Hour),
@@ -406,7 +787,7 @@ func TestGetPostByID(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	result, err := testRepo.GetPostByID(ctx, postID, "1")
+	result, err := testRepo.GetPostByID(ctx, postID, 1)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -430,7 +811,7 @@ func TestGetPostByID(t *testing.T) {
 func TestGetPostByIDNotFound(t *testing.T) {
 	cleanupTables()
 
-	result, err := testRepo.GetPostByID(ctx, 99999, "1")
+	result, err := testRepo.GetPostByID(ctx, 99999, 1)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -442,24 +823,24 @@ func TestGetPostByIDNotFound(t *testing.T) {
 func TestGetPostByIDWrongUser(t *testing.T) {
 	cleanupTables()
 
-	configJSON := `{"token":"test_token"}`
 	platformReq := dto.CreatePlatformRequest{
 		ID_user:      "1",
+		ID_workspace: 1,
 		PlatformName: "telegram",
-		Bot_name:     "test_bot",
-		Config:       configJSON,
+		Telegram:     testTelegramConfig("test_bot", "test_token"),
 	}
 	testRepo.CreatePlatform(ctx, platformReq)
 
 	post := dto.CreatePostRequest{
 		ID_user:      "1",
+		ID_workspace: 1,
 		Title:        "User 1 Post",
 		Content:      "Content",
 		Sheduled_for: time.Now().Add(24 * time.Hour),
 	}
 	postID, _, _ := testRepo.CreatePost(ctx, post)
 
-	result, err := testRepo.GetPostByID(ctx, postID, "2")
+	result, err := testRepo.GetPostByID(ctx, postID, 2)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -473,12 +854,11 @@ func TestGetPostByIDWrongUser(t *testing.T) {
 func TestUpdatePostByID(t *testing.T) {
 	cleanupTables()
 
-	configJSON := `{"token":"test_token"}`
 	platformReq := dto.CreatePlatformRequest{
 		ID_user:      "1",
+		ID_workspace: 1,
 		PlatformName: "telegram",
-		Bot_name:     "test_bot",
-		Config:       configJSON,
+		Telegram:     testTelegramConfig("test_bot", "test_token"),
 	}
 
 	_, _, err := testRepo.CreatePlatform(ctx, platformReq)
@@ -488,6 +868,7 @@ func TestUpdatePostByID(t *testing.T) {
 
 	post := dto.CreatePostRequest{
 		ID_user:      "1",
+		ID_workspace: 1,
 		Title:        "Original Title",
 		Content:      "Original Content",
 		Sheduled_for: time.Now().Add(24 * time.Hour),
@@ -501,6 +882,7 @@ func TestUpdatePostByID(t *testing.T) {
 	updateReq := dto.PutPostRequest{
 		ID_post:      postID,
 		ID_user:      "1",
+		ID_workspace: 1,
 		Title:        "Updated Title",
 		Content:      "Updated Content",
 		Sheduled_for: time.Now().Add(48 * time.Hour),
@@ -518,7 +900,7 @@ func TestUpdatePostByID(t *testing.T) {
 		t.Error("Expected non-zero updated_at")
 	}
 
-	updatedPost, err := testRepo.GetPostByID(ctx, postID, "1")
+	updatedPost, err := testRepo.GetPostByID(ctx, postID, 1)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -533,20 +915,76 @@ func TestUpdatePostByID(t *testing.T) {
 	}
 }
 
+func TestUpdatePostByIDKeepsDestinationTimes(t *testing.T) {
+	cleanupTables()
+
+	for _, chat := range []string{"published", "shifted"} {
+		if _, _, err := testRepo.CreatePlatform(ctx, dto.CreatePlatformRequest{
+			ID_user:      "1",
+			ID_workspace: 1,
+			PlatformName: "Telegram",
+			Telegram:     testTelegramConfig(chat, "test_token"),
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	at := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+	postID, _, err := testRepo.CreatePost(ctx, dto.CreatePostRequest{
+		ID_user:      "1",
+		ID_workspace: 1,
+		Title:        "Title",
+		Content:      "Content",
+		Sheduled_for: at,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// первая публикация уже ушла, вторую сдвинуло возобновление после паузы
+	testPool.Exec(ctx, "UPDATE post_destinations SET status = 'published' WHERE post_id = $1 AND platform_id = 1", postID)
+	testPool.Exec(ctx, "UPDATE post_destinations SET scheduled_for = scheduled_for + INTERVAL '2 hours' WHERE post_id = $1 AND platform_id = 2", postID)
+	times := func() (published, shifted time.Time) {
+		testPool.QueryRow(ctx, "SELECT scheduled_for FROM post_destinations WHERE post_id = $1 AND platform_id = 1", postID).Scan(&published)
+		testPool.QueryRow(ctx, "SELECT scheduled_for FROM post_destinations WHERE post_id = $1 AND platform_id = 2", postID).Scan(&shifted)
+		return published, shifted
+	}
+	published, shifted := times()
+
+	// правка только заголовка не трогает время
+	if _, err := testRepo.UpdatePostByID(ctx, dto.PutPostRequest{
+		ID_post: postID, ID_user: "1", ID_workspace: 1, Title: "New title", Content: "Content", Sheduled_for: published,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if p, s := times(); !p.Equal(published) || !s.Equal(shifted) {
+		t.Errorf("Expected times to be kept on title edit, got %v %v", p, s)
+	}
+
+	// перенос меняет только неотправленные публикации
+	moved := published.Add(5 * time.Hour)
+	if _, err := testRepo.UpdatePostByID(ctx, dto.PutPostRequest{
+		ID_post: postID, ID_user: "1", ID_workspace: 1, Title: "New title", Content: "Content", Sheduled_for: moved,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if p, s := times(); !p.Equal(published) || !s.Equal(moved) {
+		t.Errorf("Expected only the unpublished destination moved, got %v %v", p, s)
+	}
+}
+
 func TestUpdatePostByIDWrongUser(t *testing.T) {
 	cleanupTables()
 
-	configJSON := `{"token":"test_token"}`
 	platformReq := dto.CreatePlatformRequest{
 		ID_user:      "1",
+		ID_workspace: 1,
 		PlatformName: "telegram",
-		Bot_name:     "test_bot",
-		Config:       configJSON,
+		Telegram:     testTelegramConfig("test_bot", "test_token"),
 	}
 	testRepo.CreatePlatform(ctx, platformReq)
 
 	post := dto.CreatePostRequest{
 		ID_user:      "1",
+		ID_workspace: 1,
 		Title:        "Original Title",
 		Content:      "Original Content",
 		Sheduled_for: time.Now().Add(24 * time.Hour),
@@ -556,6 +994,7 @@ func TestUpdatePostByIDWrongUser(t *testing.T) {
 	updateReq := dto.PutPostRequest{
 		ID_post:      postID,
 		ID_user:      "2",
+		ID_workspace: 2,
 		Title:        "Hacked Title",
 		Content:      "Hacked Content",
 		Sheduled_for: time.Now().Add(48 * time.Hour),
@@ -586,6 +1025,7 @@ func TestUpdatePostByIDDatabaseError(t *testing.T) {
 	updateReq := dto.PutPostRequest{
 		ID_post:      1,
 		ID_user:      "1",
+		ID_workspace: 1,
 		Title:        "Title",
 		Content:      "Content",
 		Sheduled_for: time.Now(),
@@ -599,12 +1039,11 @@ func TestUpdatePostByIDDatabaseError(t *testing.T) {
 func TestDeletePostByID(t *testing.T) {
 	cleanupTables()
 
-	configJSON := `{"token":"test_token"}`
 	platformReq := dto.CreatePlatformRequest{
 		ID_user:      "1",
+		ID_workspace: 1,
 		PlatformName: "telegram",
-		Bot_name:     "test_bot",
-		Config:       configJSON,
+		Telegram:     testTelegramConfig("test_bot", "test_token"),
 	}
 
 	_, _, err := testRepo.CreatePlatform(ctx, platformReq)
@@ -614,6 +1053,7 @@ func TestDeletePostByID(t *testing.T) {
 
 	post := dto.CreatePostRequest{
 		ID_user:      "1",
+		ID_workspace: 1,
 		Title:        "Post To Delete",
 		Content:      "Content To Delete",
 		Sheduled_for: time.Now().Add(24 * time.Hour),
@@ -624,12 +1064,12 @@ func TestDeletePostByID(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	err = testRepo.DeletePostByID(ctx, postID)
+	err = testRepo.DeletePostByID(ctx, postID, 1)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	result, err := testRepo.GetPostByID(ctx, postID, "1")
+	result, err := testRepo.GetPostByID(ctx, postID, 1)
 	if err == nil && len(result.Posts) > 0 {
 		t.Error("Expected no posts after deletion")
 	}
@@ -638,7 +1078,7 @@ func TestDeletePostByID(t *testing.T) {
 func TestDeletePostByIDNotFound(t *testing.T) {
 	cleanupTables()
 
-	err := testRepo.DeletePostByID(ctx, 99999)
+	err := testRepo.DeletePostByID(ctx, 99999, 1)
 	if err != nil {
 		t.Logf("Got error when deleting non-existent post: %v", err)
 	}
@@ -650,7 +1090,7 @@ func TestDeletePostByIDDatabaseError(t *testing.T) {
 	testPool.Exec(ctx, "DROP TABLE posts CASCADE")
 	defer createTables()
 
-	err := testRepo.DeletePostByID(ctx, 1)
+	err := testRepo.DeletePostByID(ctx, 1, 1)
 	if err == nil {
 		t.Error("Expected error when database fails")
 	}
@@ -659,12 +1099,11 @@ func TestDeletePostByIDDatabaseError(t *testing.T) {
 func TestCreatePlatform(t *testing.T) {
 	cleanupTables()
 
-	configJSON := `{"token":"test_token","chat_id":"12345"}`
 	platformReq := dto.CreatePlatformRequest{
 		ID_user:      "1",
+		ID_workspace: 1,
 		PlatformName: "telegram",
-		Bot_name:     "test_bot",
-		Config:       configJSON,
+		Telegram:     testTelegramConfig("test_bot", "test_token"),
 	}
 
 	platformID, createdAt, err := testRepo.CreatePlatform(ctx, platformReq)
@@ -678,7 +1117,7 @@ func TestCreatePlatform(t *testing.T) {
 		t.Error("Expected non-zero created_at")
 	}
 
-	platform, err := testRepo.GetPlatformByID(ctx, platformID, "1")
+	platform, err := testRepo.GetPlatformByID(ctx, platformID, 1)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -691,30 +1130,40 @@ func TestCreatePlatform(t *testing.T) {
 	}
 }
 
-func TestCreatePlatformInvalidJSON(t *testing.T) {
+func TestCreatePlatformEncryptsCredentials(t *testing.T) {
 	cleanupTables()
 
 	platformReq := dto.CreatePlatformRequest{
 		ID_user:      "1",
+		ID_workspace: 1,
 		PlatformName: "telegram",
-		Bot_name:     "test_bot",
-		Config:       `{"token":"test_token"`,
+		Telegram:     testTelegramConfig("test_bot", "test_token"),
 	}
 
 	id, _, err := testRepo.CreatePlatform(ctx, platformReq)
 	if err != nil {
-		t.Logf("Got error as expected: %v", err)
-		return
+		t.Fatal(err)
 	}
 
-	if id > 0 {
-		platform, getErr := testRepo.GetPlatformByID(ctx, id, "1")
-		if getErr == nil {
-			t.Logf("Platform created with ID %d despite invalid JSON", id)
-			if platform.Api_config == nil {
-				t.Error("API config is nil")
-			}
-		}
+	var raw string
+	var version int
+	err = testPool.QueryRow(ctx, "SELECT api_config->'telegram'->>'bot_token', (api_config->>'version')::int FROM platforms WHERE id=$1", id).Scan(&raw, &version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !secrets.IsEncrypted(raw) || strings.Contains(raw, "test_token") {
+		t.Errorf("Expected encrypted bot token in database, got '%s'", raw)
+	}
+	if version != domain.PlatformConfigVersion {
+		t.Errorf("Expected config version %d, got %d", domain.PlatformConfigVersion, version)
+	}
+
+	platform, err := testRepo.GetPlatformByID(ctx, id, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if platform.Api_config.Telegram == nil || platform.Api_config.Telegram.BotToken != "123:test_token" {
+		t.Errorf("Expected decrypted bot token, got %+v", platform.Api_config.Telegram)
 	}
 }
 
@@ -726,9 +1175,9 @@ func TestCreatePlatformDatabaseError(t *testing.T) {
 
 	platformReq := dto.CreatePlatformRequest{
 		ID_user:      "1",
+		ID_workspace: 1,
 		PlatformName: "telegram",
-		Bot_name:     "test_bot",
-		Config:       `{"token":"test_token"}`,
+		Telegram:     testTelegramConfig("test_bot", "test_token"),
 	}
 
 	_, _, err := testRepo.CreatePlatform(ctx, platformReq)
@@ -740,7 +1189,7 @@ func TestCreatePlatformDatabaseError(t *testing.T) {
 func TestGetPlatformEmpty(t *testing.T) {
 	cleanupTables()
 
-	platforms, err := testRepo.GetPlatform(ctx, "999")
+	platforms, err := testRepo.GetPlatform(ctx, 999)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -756,21 +1205,21 @@ func TestGetPlatformMultiple(t *testing.T) {
 	platforms := []dto.CreatePlatformRequest{
 		{
 			ID_user:      "1",
+			ID_workspace: 1,
 			PlatformName: "telegram",
-			Bot_name:     "telegram_bot",
-			Config:       `{"token":"token1"}`,
+			Telegram:     testTelegramConfig("telegram_bot", "token1"),
 		},
 		{
 			ID_user:      "1",
+			ID_workspace: 1,
 			PlatformName: "vk",
-			Bot_name:     "vk_bot",
-			Config:       `{"token":"token2"}`,
+			VK:           testVKConfig("-2", "token2"),
 		},
 		{
 			ID_user:      "1",
+			ID_workspace: 1,
 			PlatformName: "discord",
-			Bot_name:     "discord_bot",
-			Config:       `{"token":"token3"}`,
+			Telegram:     testTelegramConfig("discord_bot", "token3"),
 		},
 	}
 
@@ -781,7 +1230,7 @@ func TestGetPlatformMultiple(t *testing.T) {
 		}
 	}
 
-	result, err := testRepo.GetPlatform(ctx, "1")
+	result, err := testRepo.GetPlatform(ctx, 1)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -796,12 +1245,11 @@ func TestGetPlatformMultiple(t *testing.T) {
 func TestGetPlatformByID(t *testing.T) {
 	cleanupTables()
 
-	configJSON := `{"token":"test_token"}`
 	platformReq := dto.CreatePlatformRequest{
 		ID_user:      "1",
+		ID_workspace: 1,
 		PlatformName: "telegram",
-		Bot_name:     "test_bot",
-		Config:       configJSON,
+		Telegram:     testTelegramConfig("test_bot", "test_token"),
 	}
 
 	platformID, _, err := testRepo.CreatePlatform(ctx, platformReq)
@@ -809,7 +1257,7 @@ func TestGetPlatformByID(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	platform, err := testRepo.GetPlatformByID(ctx, platformID, "1")
+	platform, err := testRepo.GetPlatformByID(ctx, platformID, 1)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -825,7 +1273,7 @@ func TestGetPlatformByID(t *testing.T) {
 func TestGetPlatformByIDNotFound(t *testing.T) {
 	cleanupTables()
 
-	_, err := testRepo.GetPlatformByID(ctx, 99999, "1")
+	_, err := testRepo.GetPlatformByID(ctx, 99999, 1)
 	if err == nil {
 		t.Error("Expected error when getting non-existent platform")
 	}
@@ -834,12 +1282,11 @@ func TestGetPlatformByIDNotFound(t *testing.T) {
 func TestGetPlatformByIDWrongUser(t *testing.T) {
 	cleanupTables()
 
-	configJSON := `{"token":"test_token"}`
 	platformReq := dto.CreatePlatformRequest{
 		ID_user:      "1",
+		ID_workspace: 1,
 		PlatformName: "telegram",
-		Bot_name:     "test_bot",
-		Config:       configJSON,
+		Telegram:     testTelegramConfig("test_bot", "test_token"),
 	}
 
 	platformID, _, err := testRepo.CreatePlatform(ctx, platformReq)
@@ -847,7 +1294,7 @@ func TestGetPlatformByIDWrongUser(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	_, err = testRepo.GetPlatformByID(ctx, platformID, "2")
+	_, err = testRepo.GetPlatformByID(ctx, platformID, 2)
 	if err == nil {
 		t.Error("Expected error when getting platform with wrong user ID")
 	}
@@ -859,7 +1306,7 @@ func TestGetPlatformByIDDatabaseError(t *testing.T) {
 	testPool.Exec(ctx, "DROP TABLE platforms CASCADE")
 	createTables()
 
-	_, err := testRepo.GetPlatformByID(ctx, 1, "1")
+	_, err := testRepo.GetPlatformByID(ctx, 1, 1)
 	if err == nil {
 		t.Error("Expected error when database fails")
 	}
@@ -868,12 +1315,11 @@ func TestGetPlatformByIDDatabaseError(t *testing.T) {
 func TestUpdatePlatformByID(t *testing.T) {
 	cleanupTables()
 
-	configJSON := `{"token":"test_token"}`
 	platformReq := dto.CreatePlatformRequest{
 		ID_user:      "1",
+		ID_workspace: 1,
 		PlatformName: "telegram",
-		Bot_name:     "test_bot",
-		Config:       configJSON,
+		Telegram:     testTelegramConfig("test_bot", "test_token"),
 	}
 
 	platformID, _, err := testRepo.CreatePlatform(ctx, platformReq)
@@ -881,13 +1327,11 @@ func TestUpdatePlatformByID(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	updateConfigJSON := `{"token":"updated_token","chat_id":"67890"}`
 	updateReq := dto.PutPlatformRequest{
-		ID_platform:  platformID,
-		ID_user:      "1",
-		PlatformName: "telegram",
-		Bot_name:     "updated_bot",
-		Config:       updateConfigJSON,
+		ID_platform: platformID,
+		ID_user:     "1",
+		ID_workspace: 1,
+		Config:      domain.PlatformConfig{Version: domain.PlatformConfigVersion, Telegram: testTelegramConfig("updated_bot", "test_token")},
 	}
 
 	result, err := testRepo.UpdatePlatformByID(ctx, updateReq)
@@ -903,15 +1347,14 @@ func TestUpdatePlatformByID(t *testing.T) {
 	}
 }
 
-func TestUpdatePlatformByIDInvalidJSON(t *testing.T) {
+func TestUpdatePlatformByIDStoresConfig(t *testing.T) {
 	cleanupTables()
 
-	configJSON := `{"token":"test_token"}`
 	platformReq := dto.CreatePlatformRequest{
 		ID_user:      "1",
+		ID_workspace: 1,
 		PlatformName: "telegram",
-		Bot_name:     "test_bot",
-		Config:       configJSON,
+		Telegram:     testTelegramConfig("test_bot", "test_token"),
 	}
 
 	platformID, _, err := testRepo.CreatePlatform(ctx, platformReq)
@@ -919,37 +1362,79 @@ func TestUpdatePlatformByIDInvalidJSON(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	updated := testTelegramConfig("updated_bot", "updated_token")
+	updated.Silent = true
 	updateReq := dto.PutPlatformRequest{
-		ID_platform:  platformID,
-		ID_user:      "1",
-		PlatformName: "telegram",
-		Bot_name:     "updated_bot",
-		Config:       `{"token":"updated_token"`,
+		ID_platform: platformID,
+		ID_user:     "1",
+		ID_workspace: 1,
+		Config:      domain.PlatformConfig{Version: domain.PlatformConfigVersion, Telegram: updated},
 	}
 
-	result, err := testRepo.UpdatePlatformByID(ctx, updateReq)
-	if err != nil {
-		t.Logf("Got error as expected: %v", err)
-		return
+	if _, err := testRepo.UpdatePlatformByID(ctx, updateReq); err != nil {
+		t.Fatal(err)
 	}
 
-	if result.ID_platform == platformID {
-		platform, getErr := testRepo.GetPlatformByID(ctx, platformID, "1")
-		if getErr == nil && platform.Api_config != nil {
-			t.Log("Platform was updated despite invalid JSON")
-		}
+	platform, err := testRepo.GetPlatformByID(ctx, platformID, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *platform.Api_config.Telegram != *updated {
+		t.Errorf("Expected config %+v, got %+v", *updated, *platform.Api_config.Telegram)
 	}
 }
 
-func TestUpdatePlatformByIDWrongUser(t *testing.T) {
+func TestUpdatePlatformVerificationVersion(t *testing.T) {
 	cleanupTables()
 
-	configJSON := `{"token":"test_token"}`
-	platformReq := dto.CreatePlatformRequest{
+	platformID, _, err := testRepo.CreatePlatform(ctx, dto.CreatePlatformRequest{
 		ID_user:      "1",
-		PlatformName: "telegram",
-		Bot_name:     "test_bot",
-		Config:       configJSON,
+		ID_workspace: 1,
+		PlatformName: "Telegram",
+		Telegram:     testTelegramConfig("test_bot", "test_token"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	updated, err := testRepo.UpdatePlatformByID(ctx, dto.PutPlatformRequest{
+		ID_platform:  platformID,
+		ID_user:      "1",
+		ID_workspace: 1,
+		Config:       domain.PlatformConfig{Version: domain.PlatformConfigVersion, Telegram: testTelegramConfig("new_bot", "new_token")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Config_version != 1 {
+		t.Fatalf("Expected config version 1, got %d", updated.Config_version)
+	}
+
+	// проверка старых учётных данных закончилась позже проверки новых
+	if err := testRepo.UpdatePlatformVerification(ctx, platformID, updated.Config_version, domain.VerificationReport{OK: true}); err != nil {
+		t.Fatal(err)
+	}
+	err = testRepo.UpdatePlatformVerification(ctx, platformID, 0, domain.VerificationReport{AuthFailed: true})
+	if !errors.Is(err, repository.ErrPlatformChanged) {
+		t.Fatalf("Expected ErrPlatformChanged, got %v", err)
+	}
+
+	platform, err := testRepo.GetPlatformByID(ctx, platformID, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if platform.Config_version != 1 || platform.Verification == nil || !platform.Verification.OK {
+		t.Errorf("Expected the report of the new credentials, got %+v", platform)
+	}
+}
+
+func TestUpdatePlatformByIDWrongUser(t *testing.T) {
+	cleanupTables()
+
+	platformReq := dto.CreatePlatformRequest{
+		ID_user:      "1",
+		ID_workspace: 1,
+		PlatformName: "telegram",
+		Telegram:     testTelegramConfig("test_bot", "test_token"),
 	}
 
 	platformID, _, err := testRepo.CreatePlatform(ctx, platformReq)
@@ -958,11 +1443,10 @@ func TestUpdatePlatformByIDWrongUser(t *testing.T) {
 	}
 
 	updateReq := dto.PutPlatformRequest{
-		ID_platform:  platformID,
-		ID_user:      "2",
-		PlatformName: "telegram",
-		Bot_name:     "hacked_bot",
-		Config:       `{"token":"hacked_token"}`,
+		ID_platform: platformID,
+		ID_user:     "2",
+		ID_workspace: 2,
+		Config:      domain.PlatformConfig{Version: domain.PlatformConfigVersion, Telegram: testTelegramConfig("hacked_bot", "hacked_token")},
 	}
 
 	result, err := testRepo.UpdatePlatformByID(ctx, updateReq)
@@ -972,13 +1456,11 @@ func TestUpdatePlatformByIDWrongUser(t *testing.T) {
 	}
 
 	if result.ID_platform == platformID {
-		platform, getErr := testRepo.GetPlatformByID(ctx, platformID, "1")
-		if getErr == nil && platform.Api_config != nil {
-			if config, ok := platform.Api_config["hacked_bot"]; ok {
-				if config == "hacked_token" {
-					t.Error("Platform was updated with wrong user ID - security issue!")
-					return
-				}
+		platform, getErr := testRepo.GetPlatformByID(ctx, platformID, 1)
+		if getErr == nil && platform.Api_config.Telegram != nil {
+			if platform.Api_config.Telegram.BotToken == "123:hacked_token" {
+				t.Error("Platform was updated with wrong user ID - security issue!")
+				return
 			}
 		}
 		t.Log("Platform was not updated - correct behavior")
@@ -992,11 +1474,10 @@ func TestUpdatePlatformByIDDatabaseError(t *testing.T) {
 	defer createTables()
 
 	updateReq := dto.PutPlatformRequest{
-		ID_platform:  1,
-		ID_user:      "1",
-		PlatformName: "telegram",
-		Bot_name:     "test_bot",
-		Config:       `{"token":"test_token"}`,
+		ID_platform: 1,
+		ID_user:     "1",
+		ID_workspace: 1,
+		Config:      domain.PlatformConfig{Version: domain.PlatformConfigVersion, Telegram: testTelegramConfig("test_bot", "test_token")},
 	}
 
 	_, err := testRepo.UpdatePlatformByID(ctx, updateReq)
@@ -1008,12 +1489,11 @@ func TestUpdatePlatformByIDDatabaseError(t *testing.T) {
 func TestDeletePlatformByID(t *testing.T) {
 	cleanupTables()
 
-	configJSON := `{"token":"test_token"}`
 	platformReq := dto.CreatePlatformRequest{
 		ID_user:      "1",
+		ID_workspace: 1,
 		PlatformName: "telegram",
-		Bot_name:     "test_bot",
-		Config:       configJSON,
+		Telegram:     testTelegramConfig("test_bot", "test_token"),
 	}
 
 	platformID, _, err := testRepo.CreatePlatform(ctx, platformReq)
@@ -1021,12 +1501,12 @@ func TestDeletePlatformByID(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	err = testRepo.DeletePlatformByID(ctx, platformID)
+	err = testRepo.DeletePlatformByID(ctx, platformID, 1)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	_, err = testRepo.GetPlatformByID(ctx, platformID, "1")
+	_, err = testRepo.GetPlatformByID(ctx, platformID, 1)
 	if err == nil {
 		t.Error("Expected error when getting deleted platform")
 	}
@@ -1035,12 +1515,11 @@ func TestDeletePlatformByID(t *testing.T) {
 func TestDeletePlatformByIDCascade(t *testing.T) {
 	cleanupTables()
 
-	configJSON := `{"token":"test_token"}`
 	platformReq := dto.CreatePlatformRequest{
 		ID_user:      "1",
+		ID_workspace: 1,
 		PlatformName: "telegram",
-		Bot_name:     "test_bot",
-		Config:       configJSON,
+		Telegram:     testTelegramConfig("test_bot", "test_token"),
 	}
 
 	platformID, _, err := testRepo.CreatePlatform(ctx, platformReq)
@@ -1050,6 +1529,7 @@ func TestDeletePlatformByIDCascade(t *testing.T) {
 
 	post := dto.CreatePostRequest{
 		ID_user:      "1",
+		ID_workspace: 1,
 		Title:        "Post with platform",
 		Content:      "Content",
 		Sheduled_for: time.Now().Add(24 * time.Hour),
@@ -1060,7 +1540,7 @@ func TestDeletePlatformByIDCascade(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	err = testRepo.DeletePlatformByID(ctx, platformID)
+	err = testRepo.DeletePlatformByID(ctx, platformID, 1)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -1078,7 +1558,7 @@ func TestDeletePlatformByIDCascade(t *testing.T) {
 func TestDeletePlatformByIDNotFound(t *testing.T) {
 	cleanupTables()
 
-	err := testRepo.DeletePlatformByID(ctx, 99999)
+	err := testRepo.DeletePlatformByID(ctx, 99999, 1)
 	if err != nil {
 		t.Logf("Got error when deleting non-existent platform: %v", err)
 	}
@@ -1090,8 +1570,1927 @@ func TestDeletePlatformByIDDatabaseError(t *testing.T) {
 	testPool.Exec(ctx, "DROP TABLE platforms CASCADE")
 	defer createTables()
 
-	err := testRepo.DeletePlatformByID(ctx, 1)
+	err := testRepo.DeletePlatformByID(ctx, 1, 1)
 	if err == nil {
 		t.Error("Expected error when database fails")
 	}
 }
+
+func TestPauseAndResumePlatformShift(t *testing.T) {
+	cleanupTables()
+
+	platformID, _, err := testRepo.CreatePlatform(ctx, dto.CreatePlatformRequest{
+		ID_user:      "1",
+		ID_workspace: 1,
+		PlatformName: "Telegram",
+		Telegram:     testTelegramConfig("test_bot", "test_token"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = testRepo.CreatePost(ctx, dto.CreatePostRequest{
+		ID_user:      "1",
+		ID_workspace: 1,
+		Title:        "Paused post",
+		Content:      "Content",
+		Sheduled_for: time.Now().Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	paused, err := testRepo.PausePlatforms(ctx, 1, platformID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paused.Platforms) != 1 || paused.Held != 1 {
+		t.Fatalf("Expected 1 paused platform with 1 held publication, got %+v", paused)
+	}
+
+	// пауза длилась два часа, публикация просрочена на час
+	_, err = testPool.Exec(ctx, "UPDATE platforms SET paused_at = NOW() - INTERVAL '2 hours' WHERE id = $1", platformID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = testPool.Exec(ctx, "UPDATE post_destinations SET scheduled_for = NOW() - INTERVAL '1 hour' WHERE platform_id = $1", platformID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resumed, err := testRepo.ResumePlatforms(ctx, 1, platformID, domain.ResumeShift)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resumed.OverdueHeld != 1 || resumed.Released != 1 {
+		t.Errorf("Expected 1 overdue and 1 released publication, got %+v", resumed)
+	}
+
+	var status string
+	var scheduledFor time.Time
+	err = testPool.QueryRow(ctx, "SELECT status, scheduled_for FROM post_destinations WHERE platform_id = $1", platformID).Scan(&status, &scheduledFor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != "scheduled" {
+		t.Errorf("Expected status scheduled, got %s", status)
+	}
+	if !scheduledFor.After(time.Now().Add(50 * time.Minute)) {
+		t.Errorf("Expected publication shifted by the pause duration, got %v", scheduledFor)
+	}
+
+	platform, err := testRepo.GetPlatformByID(ctx, platformID, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !platform.Is_active || platform.Paused_at != nil {
+		t.Errorf("Expected platform to be active and not paused, got %+v", platform)
+	}
+}
+
+func TestGetReadyForPublication(t *testing.T) {
+	cleanupTables()
+
+	_, err := testPool.Exec(ctx, `
+		INSERT INTO platforms (id, user_id, workspace_id, platform_name, api_config) 
+		VALUES (1, '1', 1, 'telegram', '{}')
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = testPool.Exec(ctx, `
+		INSERT INTO posts (id, user_id, workspace_id, title, content, status) VALUES 
+		(1, '1', 1, 'Due', 'Content', 'scheduled'),
+		(2, '1', 1, 'Later', 'Content', 'scheduled'),
+		(3, '1', 1, 'Overdue', 'Content', 'scheduled')
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = testPool.Exec(ctx, `
+		INSERT INTO post_destinations (user_id, workspace_id, post_id, platform_id, scheduled_for, status) VALUES 
+		('1', 1, 1, 1, NOW() - INTERVAL '1 minute', 'scheduled'),
+		('1', 1, 2, 1, NOW() + INTERVAL '1 hour', 'scheduled'),
+		('1', 1, 3, 1, NOW() - INTERVAL '1 hour', 'scheduled')
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// запрос выполняется сразу и возвращает свои строки, самые старые — первыми
+	publications, err := testRepo.GetReadyForPublication(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(publications) != 2 || publications[0].Title != "Overdue" || publications[1].Title != "Due" {
+		t.Fatalf("Expected overdue and due publications, got %+v", publications)
+	}
+
+	publications, err = testRepo.GetReadyForPublication(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(publications) != 1 || publications[0].Title != "Overdue" {
+		t.Errorf("Expected batch of 1 publication, got %+v", publications)
+	}
+}
+
+func TestGetReadyForPublicationTraced(t *testing.T) {
+	cleanupTables()
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previous)
+		provider.Shutdown(context.Background())
+	})
+
+	cfg, err := pgxpool.ParseConfig(databaseUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.ConnConfig.Logger = tracing.PgxLogger{}
+	pool, err := pgxpool.ConnectConfig(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	repo := repository.NewRepository(pool, pool, testKeyring, zap.NewNop())
+
+	// запрос выполняется в контексте прохода планировщика, и его спан — дочерний для scheduler.tick
+	tickCtx, tick := tracing.Start(ctx, "scheduler.tick")
+	if _, err := repo.GetReadyForPublication(tickCtx, 10); err != nil {
+		t.Fatal(err)
+	}
+	tick.End()
+
+	for _, span := range recorder.Ended() {
+		if span.Name() == "SELECT" {
+			if span.Parent().SpanID() != tick.SpanContext().SpanID() {
+				t.Errorf("Expected query span to be a child of scheduler.tick, got parent %s", span.Parent().SpanID())
+			}
+			return
+		}
+	}
+	t.Error("Expected a span for the scheduled publications query")
+}
+
+func TestPlatformHealthRecovery(t *testing.T) {
+	cleanupTables()
+
+	var ids []int
+	for _, name := range []string{"active", "deactivated", "paused"} {
+		platformID, _, err := testRepo.CreatePlatform(ctx, dto.CreatePlatformRequest{
+			ID_user:      "1",
+			ID_workspace: 1,
+			PlatformName: "Telegram",
+			Telegram:     testTelegramConfig(name+"_bot", "test_token"),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, platformID)
+	}
+	_, _, err := testRepo.CreatePost(ctx, dto.CreatePostRequest{
+		ID_user:      "1",
+		ID_workspace: 1,
+		Title:        "Held post",
+		Content:      "Content",
+		Sheduled_for: time.Now().Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := testRepo.PausePlatforms(ctx, 1, ids[2]); err != nil {
+		t.Fatal(err)
+	}
+	check := func(id int) domain.PlatformSQL {
+		platforms, err := testRepo.GetPlatformsToCheck(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, p := range platforms {
+			if p.ID == id {
+				return p
+			}
+		}
+		t.Fatalf("Platform %d is not checked, got %+v", id, platforms)
+		return domain.PlatformSQL{}
+	}
+	authFailed := domain.VerificationReport{AuthFailed: true}
+
+	// первая ошибка — degraded, третья — отключение
+	previous, next, err := testRepo.RecordPlatformHealth(ctx, check(ids[1]), authFailed, 1, 3)
+	if err != nil || previous != domain.HealthHealthy || next.HealthStatus != domain.HealthDegraded || next.AuthFailures != 1 {
+		t.Fatalf("Expected degraded platform, got %s %+v %v", previous, next, err)
+	}
+	// сетевая ошибка счётчик не меняет
+	if _, next, _ := testRepo.RecordPlatformHealth(ctx, check(ids[1]), domain.VerificationReport{}, 1, 3); next.AuthFailures != 1 {
+		t.Errorf("Expected network error not to count, got %+v", next)
+	}
+	// две проверки одних и тех же данных с разных экземпляров не теряют ошибку
+	stale := check(ids[1])
+	testRepo.RecordPlatformHealth(ctx, stale, authFailed, 1, 3)
+	previous, next, err = testRepo.RecordPlatformHealth(ctx, stale, authFailed, 1, 3)
+	if err != nil || previous != domain.HealthDegraded || next.HealthStatus != domain.HealthInactive || next.IsActive || next.AuthFailures != 3 {
+		t.Fatalf("Expected deactivated platform, got %s %+v %v", previous, next, err)
+	}
+	if held, err := testRepo.HoldDestinations(ctx, ids[1]); err != nil || held != 1 {
+		t.Fatalf("Expected 1 held publication, got %d %v", held, err)
+	}
+	// активные платформы не откладываются
+	if held, _ := testRepo.HoldDestinations(ctx, ids[0]); held != 0 {
+		t.Errorf("Expected no hold for a healthy platform, got %d", held)
+	}
+
+	platforms, err := testRepo.GetPlatformsToCheck(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(platforms) != 2 || platforms[0].ID != ids[0] || platforms[1].ID != ids[1] {
+		t.Fatalf("Expected active and deactivated platforms, got %+v", platforms)
+	}
+
+	// проверка данных, которые успели заменить, не записывается
+	deactivated := check(ids[1])
+	if _, err := testRepo.UpdatePlatformByID(ctx, dto.PutPlatformRequest{
+		ID_platform:  ids[1],
+		ID_user:      "1",
+		ID_workspace: 1,
+		Config:       domain.PlatformConfig{Version: domain.PlatformConfigVersion, Telegram: testTelegramConfig("deactivated_bot", "new_token")},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := testRepo.RecordPlatformHealth(ctx, deactivated, domain.VerificationReport{OK: true}, 1, 3); !errors.Is(err, repository.ErrPlatformChanged) {
+		t.Fatalf("Expected ErrPlatformChanged, got %v", err)
+	}
+
+	previous, next, err = testRepo.RecordPlatformHealth(ctx, check(ids[1]), domain.VerificationReport{OK: true}, 1, 3)
+	if err != nil || previous != domain.HealthInactive || next.HealthStatus != domain.HealthHealthy || !next.IsActive || next.AuthFailures != 0 {
+		t.Fatalf("Expected recovered platform, got %s %+v %v", previous, next, err)
+	}
+	platform, err := testRepo.GetPlatformByID(ctx, ids[1], 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !platform.Is_active || platform.Health_status != domain.HealthHealthy {
+		t.Errorf("Expected recovered platform to be active and healthy, got %+v", platform)
+	}
+	var status string
+	err = testPool.QueryRow(ctx, "SELECT status FROM post_destinations WHERE platform_id = $1", ids[1]).Scan(&status)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != "scheduled" {
+		t.Errorf("Expected held publication back in schedule, got %s", status)
+	}
+}
+
+func TestPauseAndResumeAccountSkip(t *testing.T) {
+	cleanupTables()
+
+	for _, chat := range []string{"first", "second"} {
+		_, _, err := testRepo.CreatePlatform(ctx, dto.CreatePlatformRequest{
+			ID_user:      "1",
+			ID_workspace: 1,
+			PlatformName: "Telegram",
+			Telegram:     testTelegramConfig(chat, "test_token"),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, _, err := testRepo.CreatePost(ctx, dto.CreatePostRequest{
+		ID_user:      "1",
+		ID_workspace: 1,
+		Title:        "Paused post",
+		Content:      "Content",
+		Sheduled_for: time.Now().Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	paused, err := testRepo.PausePlatforms(ctx, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paused.Platforms) != 2 || paused.Held != 2 {
+		t.Fatalf("Expected 2 paused platforms with 2 held publications, got %+v", paused)
+	}
+
+	_, err = testPool.Exec(ctx, "UPDATE post_destinations SET scheduled_for = NOW() - INTERVAL '1 minute'")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resumed, err := testRepo.ResumePlatforms(ctx, 1, 0, domain.ResumeSkip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resumed.Platforms) != 2 || resumed.OverdueHeld != 2 || resumed.Released != 0 {
+		t.Errorf("Expected 2 skipped publications, got %+v", resumed)
+	}
+
+	posts, err := testRepo.GetPost(ctx, 1, dto.PostFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(posts.Skipped) != 2 {
+		t.Errorf("Expected 2 skipped publications, got %d", len(posts.Skipped))
+	}
+}
+
+func TestFindOrCreateUser(t *testing.T) {
+	cleanupTables()
+
+	identity := domain.Identity{
+		Provider:       "google",
+		ProviderUserID: "google-123",
+		Email:          "user@example.com",
+		Name:           "Test User",
+	}
+	created, err := testRepo.FindOrCreateUser(ctx, identity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.ID_user == "" || created.Email == nil || *created.Email != identity.Email {
+		t.Fatalf("Unexpected user: %+v", created)
+	}
+	if created.Timezone != "UTC" {
+		t.Errorf("Expected default timezone UTC, got %s", created.Timezone)
+	}
+
+	// повторный вход не создаёт новый аккаунт и не перезаписывает изменённое имя
+	name := "Renamed"
+	if _, err := testRepo.UpdateUser(ctx, dto.PatchMeRequest{ID_user: created.ID_user, Name: &name}); err != nil {
+		t.Fatal(err)
+	}
+	again, err := testRepo.FindOrCreateUser(ctx, identity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.ID_user != created.ID_user {
+		t.Errorf("Expected the same user %s, got %s", created.ID_user, again.ID_user)
+	}
+	if again.Name == nil || *again.Name != name {
+		t.Errorf("Expected name %q to be kept, got %v", name, again.Name)
+	}
+
+	var count int
+	if err := testPool.QueryRow(ctx, "SELECT COUNT(*) FROM users").Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("Expected 1 user, got %d", count)
+	}
+}
+
+func TestFindOrCreateUserConcurrent(t *testing.T) {
+	cleanupTables()
+
+	identity := domain.Identity{Provider: "google", ProviderUserID: "google-race", Email: "race@example.com"}
+	var wg sync.WaitGroup
+	users := make([]domain.User, 5)
+	errs := make([]error, len(users))
+	for i := range users {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			users[i], errs[i] = testRepo.FindOrCreateUser(ctx, identity)
+		}()
+	}
+	wg.Wait()
+
+	for i := range users {
+		if errs[i] != nil {
+			t.Fatalf("Concurrent login %d failed: %v", i, errs[i])
+		}
+		if users[i].ID_user != users[0].ID_user {
+			t.Errorf("Expected the same user %s, got %s", users[0].ID_user, users[i].ID_user)
+		}
+	}
+	var count int
+	if err := testPool.QueryRow(ctx, "SELECT COUNT(*) FROM users").Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("Expected 1 user, got %d", count)
+	}
+	if err := testPool.QueryRow(ctx, "SELECT COUNT(*) FROM workspaces").Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("Expected 1 personal workspace, got %d", count)
+	}
+}
+
+func TestUpdateUserTimezone(t *testing.T) {
+	cleanupTables()
+
+	user, err := testRepo.FindOrCreateUser(ctx, domain.Identity{Provider: "google", ProviderUserID: "google-456"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	timezone := "Europe/Moscow"
+	updated, err := testRepo.UpdateUser(ctx, dto.PatchMeRequest{ID_user: user.ID_user, Timezone: &timezone})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Timezone != timezone {
+		t.Errorf("Expected timezone %s, got %s", timezone, updated.Timezone)
+	}
+	if updated.Email != nil {
+		t.Errorf("Expected empty email, got %v", *updated.Email)
+	}
+
+	_, err = testRepo.GetUserByID(ctx, "missing")
+	if err == nil {
+		t.Error("Expected error for unknown user")
+	}
+}
+
+func TestRotateRefreshTokenReuseRevokesSession(t *testing.T) {
+	cleanupTables()
+
+	expires := time.Now().Add(time.Hour)
+	first := domain.RefreshToken{JTI: "jti-1", Hash: strings.Repeat("a", 64), Expires_at: expires}
+	second := domain.RefreshToken{JTI: "jti-2", Hash: strings.Repeat("b", 64), Expires_at: expires}
+	third := domain.RefreshToken{JTI: "jti-3", Hash: strings.Repeat("c", 64), Expires_at: expires}
+
+	err := testRepo.CreateSession(ctx, domain.Session{ID_session: "session-1", ID_user: "1", User_agent: "test"}, first)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := testRepo.SetSessionWorkspace(ctx, "session-1", "1", 4); err != nil {
+		t.Fatal(err)
+	}
+	workspaceID, err := testRepo.RotateRefreshToken(ctx, "session-1", "1", first, second)
+	if err != nil {
+		t.Fatalf("Expected rotation to succeed, got %v", err)
+	}
+	if workspaceID != 4 {
+		t.Errorf("Expected workspace 4 kept in the session, got %d", workspaceID)
+	}
+
+	// старый токен предъявлен повторно — сессия отзывается целиком
+	_, err = testRepo.RotateRefreshToken(ctx, "session-1", "1", first, third)
+	if !errors.Is(err, repository.ErrRefreshTokenReused) {
+		t.Fatalf("Expected ErrRefreshTokenReused, got %v", err)
+	}
+	_, err = testRepo.RotateRefreshToken(ctx, "session-1", "1", second, third)
+	if !errors.Is(err, repository.ErrRefreshTokenInvalid) {
+		t.Errorf("Expected ErrRefreshTokenInvalid after revocation, got %v", err)
+	}
+
+	sessions, err := testRepo.GetSessions(ctx, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 0 {
+		t.Errorf("Expected no active sessions, got %d", len(sessions))
+	}
+}
+
+func TestRotateRefreshTokenWrongHash(t *testing.T) {
+	cleanupTables()
+
+	token := domain.RefreshToken{JTI: "jti-1", Hash: strings.Repeat("a", 64), Expires_at: time.Now().Add(time.Hour)}
+	if err := testRepo.CreateSession(ctx, domain.Session{ID_session: "session-1", ID_user: "1"}, token); err != nil {
+		t.Fatal(err)
+	}
+
+	forged := domain.RefreshToken{JTI: "jti-1", Hash: strings.Repeat("f", 64)}
+	next := domain.RefreshToken{JTI: "jti-2", Hash: strings.Repeat("b", 64), Expires_at: time.Now().Add(time.Hour)}
+	_, err := testRepo.RotateRefreshToken(ctx, "session-1", "1", forged, next)
+	if !errors.Is(err, repository.ErrRefreshTokenInvalid) {
+		t.Errorf("Expected ErrRefreshTokenInvalid, got %v", err)
+	}
+
+	if active, err := testRepo.IsSessionActive(ctx, "session-1", "1"); err != nil || !active {
+		t.Errorf("Expected active session, got %v %v", active, err)
+	}
+	revoked, err := testRepo.RevokeSession(ctx, "session-1", "2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if revoked {
+		t.Error("Expected session of another user not to be revoked")
+	}
+	revoked, err = testRepo.RevokeSession(ctx, "session-1", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !revoked {
+		t.Error("Expected session to be revoked")
+	}
+	if active, _ := testRepo.IsSessionActive(ctx, "session-1", "1"); active {
+		t.Error("Expected revoked session to be inactive")
+	}
+}
+
+func TestLinkAndUnlinkIdentity(t *testing.T) {
+	cleanupTables()
+
+	first, err := testRepo.FindOrCreateUser(ctx, domain.Identity{Provider: "google", ProviderUserID: "g-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := testRepo.FindOrCreateUser(ctx, domain.Identity{Provider: "google", ProviderUserID: "g-2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// токен привязки одноразовый
+	linkHash := strings.Repeat("d", 64)
+	if err := testRepo.CreateAuthToken(ctx, first.ID_user, domain.TokenLinkIdentity, linkHash, time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if owner, err := testRepo.UseAuthToken(ctx, domain.TokenLinkIdentity, linkHash); err != nil || owner != first.ID_user {
+		t.Fatalf("Expected link token of %s, got %q, %v", first.ID_user, owner, err)
+	}
+	if _, err := testRepo.UseAuthToken(ctx, domain.TokenLinkIdentity, linkHash); !errors.Is(err, repository.ErrAuthTokenInvalid) {
+		t.Errorf("Expected ErrAuthTokenInvalid on reuse, got %v", err)
+	}
+
+	github := domain.Identity{Provider: "github", ProviderUserID: "gh-1"}
+	if err := testRepo.LinkIdentity(ctx, first.ID_user, github); err != nil {
+		t.Fatal(err)
+	}
+	// повторная привязка к тому же аккаунту не ошибка
+	if err := testRepo.LinkIdentity(ctx, first.ID_user, github); err != nil {
+		t.Errorf("Expected relinking to the same user to succeed, got %v", err)
+	}
+	if err := testRepo.LinkIdentity(ctx, second.ID_user, github); !errors.Is(err, repository.ErrIdentityTaken) {
+		t.Errorf("Expected ErrIdentityTaken, got %v", err)
+	}
+
+	// вход через GitHub попадает в тот же аккаунт
+	viaGithub, err := testRepo.FindOrCreateUser(ctx, github)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if viaGithub.ID_user != first.ID_user {
+		t.Errorf("Expected user %s, got %s", first.ID_user, viaGithub.ID_user)
+	}
+
+	identities, err := testRepo.GetIdentities(ctx, first.ID_user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(identities) != 2 {
+		t.Fatalf("Expected 2 identities, got %d", len(identities))
+	}
+
+	deleted, err := testRepo.UnlinkIdentity(ctx, first.ID_user, "google")
+	if err != nil || !deleted {
+		t.Fatalf("Expected google to be unlinked, got %v, %v", deleted, err)
+	}
+	_, err = testRepo.UnlinkIdentity(ctx, first.ID_user, "github")
+	if !errors.Is(err, repository.ErrLastIdentity) {
+		t.Errorf("Expected ErrLastIdentity, got %v", err)
+	}
+}
+
+func TestLocalUserLockout(t *testing.T) {
+	cleanupTables()
+
+	user, err := testRepo.CreateLocalUser(ctx, "user@example.com", "", "$argon2id$hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := testRepo.CreateLocalUser(ctx, "user@example.com", "", "$argon2id$other"); !errors.Is(err, repository.ErrEmailTaken) {
+		t.Errorf("Expected ErrEmailTaken, got %v", err)
+	}
+
+	creds, err := testRepo.GetLocalCredentials(ctx, "USER@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if creds.ID_user != user.ID_user || creds.Email_verified {
+		t.Errorf("Unexpected credentials: %+v", creds)
+	}
+
+	for i := 1; i <= 3; i++ {
+		lockedUntil, err := testRepo.RecordLoginFailure(ctx, user.ID_user, 3, time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if locked := lockedUntil != nil && lockedUntil.After(time.Now()); locked != (i == 3) {
+			t.Errorf("Attempt %d: unexpected lock state %v", i, lockedUntil)
+		}
+	}
+
+	if err := testRepo.ResetLoginFailures(ctx, user.ID_user); err != nil {
+		t.Fatal(err)
+	}
+	creds, err = testRepo.GetLocalCredentials(ctx, "user@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if creds.Locked_until != nil {
+		t.Errorf("Expected lock to be cleared, got %v", creds.Locked_until)
+	}
+}
+
+func TestVerifyEmailAndResetPassword(t *testing.T) {
+	cleanupTables()
+
+	user, err := testRepo.CreateLocalUser(ctx, "user@example.com", "User", "$argon2id$old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expires := time.Now().Add(time.Hour)
+	verifyHash := strings.Repeat("1", 64)
+	resetHash := strings.Repeat("2", 64)
+	if err := testRepo.CreateAuthToken(ctx, user.ID_user, domain.TokenVerifyEmail, verifyHash, expires); err != nil {
+		t.Fatal(err)
+	}
+	if err := testRepo.CreateAuthToken(ctx, user.ID_user, domain.TokenResetPassword, resetHash, expires); err != nil {
+		t.Fatal(err)
+	}
+
+	// токен одного назначения не подходит для другого
+	if err := testRepo.VerifyEmail(ctx, resetHash); !errors.Is(err, repository.ErrAuthTokenInvalid) {
+		t.Errorf("Expected ErrAuthTokenInvalid, got %v", err)
+	}
+	if err := testRepo.VerifyEmail(ctx, verifyHash); err != nil {
+		t.Fatal(err)
+	}
+	if err := testRepo.VerifyEmail(ctx, verifyHash); !errors.Is(err, repository.ErrAuthTokenInvalid) {
+		t.Errorf("Expected used token to be rejected, got %v", err)
+	}
+
+	err = testRepo.CreateSession(ctx, domain.Session{ID_session: "session-1", ID_user: user.ID_user},
+		domain.RefreshToken{JTI: "jti-1", Hash: strings.Repeat("a", 64), Expires_at: expires})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := testRepo.ResetPassword(ctx, resetHash, "$argon2id$new"); err != nil {
+		t.Fatal(err)
+	}
+
+	creds, err := testRepo.GetLocalCredentials(ctx, "user@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if creds.Password_hash != "$argon2id$new" || !creds.Email_verified {
+		t.Errorf("Unexpected credentials after reset: %+v", creds)
+	}
+	sessions, err := testRepo.GetSessions(ctx, user.ID_user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 0 {
+		t.Errorf("Expected sessions to be revoked after password reset, got %d", len(sessions))
+	}
+}
+
+func TestApiKeyLifecycle(t *testing.T) {
+	cleanupTables()
+
+	activeHash := strings.Repeat("a", 64)
+	expiredHash := strings.Repeat("b", 64)
+	key, err := testRepo.CreateApiKey(ctx, "key-1", "hxk_aaaaaaaa", activeHash, dto.CreateApiKeyRequest{
+		ID_user: "1",
+		Name:    "ci",
+		Scopes:  []string{domain.ScopePostsWrite, domain.ScopePlatformsRead},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(key.Scopes) != 2 || key.Last_used_at != nil {
+		t.Errorf("Unexpected created key: %+v", key)
+	}
+	expired := time.Now().Add(-time.Minute)
+	_, err = testRepo.CreateApiKey(ctx, "key-2", "hxk_bbbbbbbb", expiredHash, dto.CreateApiKeyRequest{
+		ID_user:    "1",
+		Name:       "old",
+		Scopes:     []string{domain.ScopePostsRead},
+		Expires_at: &expired,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	used, err := testRepo.UseApiKey(ctx, activeHash)
+	if err != nil {
+		t.Fatalf("Expected active key to be accepted, got %v", err)
+	}
+	if used.ID_user != "1" || used.Last_used_at == nil {
+		t.Errorf("Expected last_used_at to be set, got %+v", used)
+	}
+	if _, err := testRepo.UseApiKey(ctx, expiredHash); !errors.Is(err, repository.ErrApiKeyInvalid) {
+		t.Errorf("Expected ErrApiKeyInvalid for expired key, got %v", err)
+	}
+
+	// чужой ключ отозвать нельзя
+	revoked, err := testRepo.RevokeApiKey(ctx, "key-1", "2")
+	if err != nil || revoked {
+		t.Fatalf("Expected no revoke for another user, got %v, %v", revoked, err)
+	}
+	revoked, err = testRepo.RevokeApiKey(ctx, "key-1", "1")
+	if err != nil || !revoked {
+		t.Fatalf("Expected key to be revoked, got %v, %v", revoked, err)
+	}
+	if _, err := testRepo.UseApiKey(ctx, activeHash); !errors.Is(err, repository.ErrApiKeyInvalid) {
+		t.Errorf("Expected ErrApiKeyInvalid for revoked key, got %v", err)
+	}
+
+	keys, err := testRepo.GetApiKeys(ctx, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || keys[0].ID_key != "key-2" {
+		t.Errorf("Expected only the not revoked key, got %+v", keys)
+	}
+}
+
+func TestWorkspaceMembersAndInvitations(t *testing.T) {
+	cleanupTables()
+
+	owner, err := testRepo.CreateLocalUser(ctx, "owner@example.com", "Owner", "$argon2id$hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	member, err := testRepo.CreateLocalUser(ctx, "member@example.com", "Member", "$argon2id$hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stranger, err := testRepo.CreateLocalUser(ctx, "stranger@example.com", "Stranger", "$argon2id$hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// новому пользователю создаётся личное пространство
+	personal, err := testRepo.GetDefaultWorkspace(ctx, owner.ID_user)
+	if err != nil {
+		t.Fatalf("Expected personal workspace, got %v", err)
+	}
+
+	team, err := testRepo.CreateWorkspace(ctx, "Team", owner.ID_user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if team.ID_workspace == personal || team.Role != domain.RoleOwner {
+		t.Errorf("Unexpected workspace: %+v", team)
+	}
+	if _, err := testRepo.GetMemberRole(ctx, team.ID_workspace, member.ID_user); !errors.Is(err, repository.ErrNotMember) {
+		t.Errorf("Expected ErrNotMember, got %v", err)
+	}
+
+	tokenHash := strings.Repeat("c", 64)
+	err = testRepo.CreateInvitation(ctx, domain.Invitation{
+		ID_workspace: team.ID_workspace,
+		Email:        "Member@Example.com",
+		Role:         domain.RoleEditor,
+		Invited_by:   owner.ID_user,
+		Expires_at:   time.Now().Add(time.Hour),
+	}, tokenHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// чужой токен не принимается и не расходуется
+	if _, err := testRepo.AcceptInvitation(ctx, tokenHash, stranger.ID_user); !errors.Is(err, repository.ErrInvitationEmail) {
+		t.Errorf("Expected ErrInvitationEmail, got %v", err)
+	}
+	joined, err := testRepo.AcceptInvitation(ctx, tokenHash, member.ID_user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if joined.ID_workspace != team.ID_workspace || joined.Role != domain.RoleEditor {
+		t.Errorf("Unexpected joined workspace: %+v", joined)
+	}
+	// приглашение одноразовое
+	if _, err := testRepo.AcceptInvitation(ctx, tokenHash, member.ID_user); !errors.Is(err, repository.ErrInvitationInvalid) {
+		t.Errorf("Expected ErrInvitationInvalid on second accept, got %v", err)
+	}
+
+	members, err := testRepo.GetMembers(ctx, team.ID_workspace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(members) != 2 {
+		t.Errorf("Expected 2 members, got %+v", members)
+	}
+
+	// единственного владельца нельзя понизить или удалить
+	if _, err := testRepo.UpdateMemberRole(ctx, team.ID_workspace, owner.ID_user, domain.RoleAdmin); !errors.Is(err, repository.ErrLastOwner) {
+		t.Errorf("Expected ErrLastOwner on demote, got %v", err)
+	}
+	if _, err := testRepo.RemoveMember(ctx, team.ID_workspace, owner.ID_user); !errors.Is(err, repository.ErrLastOwner) {
+		t.Errorf("Expected ErrLastOwner on remove, got %v", err)
+	}
+
+	updated, err := testRepo.UpdateMemberRole(ctx, team.ID_workspace, member.ID_user, domain.RoleOwner)
+	if err != nil || !updated {
+		t.Fatalf("Expected member to be promoted, got %v, %v", updated, err)
+	}
+	removed, err := testRepo.RemoveMember(ctx, team.ID_workspace, owner.ID_user)
+	if err != nil || !removed {
+		t.Fatalf("Expected owner to leave, got %v, %v", removed, err)
+	}
+	workspaces, err := testRepo.GetWorkspaces(ctx, owner.ID_user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(workspaces) != 1 || workspaces[0].ID_workspace != personal {
+		t.Errorf("Expected only the personal workspace, got %+v", workspaces)
+	}
+}
+
+func TestPostApprovalWorkflow(t *testing.T) {
+	cleanupTables()
+
+	author, err := testRepo.CreateLocalUser(ctx, "author@example.com", "Author", "$argon2id$hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws, err := testRepo.CreateWorkspace(ctx, "Editorial", author.ID_user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// единственный проверяющий не может одобрить свой пост
+	if err := testRepo.UpdateWorkspaceApprovals(ctx, ws.ID_workspace, 1); !errors.Is(err, repository.ErrTooFewReviewers) {
+		t.Fatalf("Expected ErrTooFewReviewers, got %v", err)
+	}
+	reviewer, err := testRepo.CreateLocalUser(ctx, "reviewer@example.com", "Reviewer", "$argon2id$hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testPool.Exec(ctx, "INSERT INTO workspace_members (workspace_id, user_id, role) VALUES ($1, $2, 'admin')", ws.ID_workspace, reviewer.ID_user)
+	if err := testRepo.UpdateWorkspaceApprovals(ctx, ws.ID_workspace, 1); err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = testRepo.CreatePlatform(ctx, dto.CreatePlatformRequest{
+		ID_user:      author.ID_user,
+		ID_workspace: ws.ID_workspace,
+		PlatformName: "telegram",
+		Telegram:     testTelegramConfig("test_bot", "test_token"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// черновик без времени публикации
+	postID, _, err := testRepo.CreatePost(ctx, dto.CreatePostRequest{
+		ID_user:      author.ID_user,
+		ID_workspace: ws.ID_workspace,
+		Title:        "Draft",
+		Content:      "Content",
+		Status:       domain.PostDraft,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := testRepo.ReviewPost(ctx, ws.ID_workspace, domain.PostReview{ID_post: postID, ID_user: "editor-id", Action: domain.ReviewApproved}); !errors.Is(err, repository.ErrInvalidTransition) {
+		t.Errorf("Expected draft to be not reviewable, got %v", err)
+	}
+
+	status, err := testRepo.SubmitPost(ctx, postID, ws.ID_workspace, author.ID_user)
+	if err != nil || status != domain.PostInReview {
+		t.Fatalf("Expected in_review, got %q, %v", status, err)
+	}
+	if _, err := testRepo.ReviewPost(ctx, ws.ID_workspace, domain.PostReview{ID_post: postID, ID_user: author.ID_user, Action: domain.ReviewApproved}); !errors.Is(err, repository.ErrSelfReview) {
+		t.Errorf("Expected ErrSelfReview, got %v", err)
+	}
+	status, err = testRepo.ReviewPost(ctx, ws.ID_workspace, domain.PostReview{ID_post: postID, ID_user: "editor-id", Action: domain.ReviewRejected, Comment: "too short"})
+	if err != nil || status != domain.PostDraft {
+		t.Fatalf("Expected rejected post to become a draft, got %q, %v", status, err)
+	}
+
+	// одобрение до повторной отправки не засчитывается
+	if _, err := testRepo.SubmitPost(ctx, postID, ws.ID_workspace, author.ID_user); err != nil {
+		t.Fatal(err)
+	}
+	status, err = testRepo.ReviewPost(ctx, ws.ID_workspace, domain.PostReview{ID_post: postID, ID_user: "editor-id", Action: domain.ReviewApproved})
+	if err != nil || status != domain.PostApproved {
+		t.Fatalf("Expected approved post without time, got %q, %v", status, err)
+	}
+	var destStatus string
+	testPool.QueryRow(ctx, "SELECT status FROM post_destinations WHERE post_id = $1", postID).Scan(&destStatus)
+	if destStatus != "draft" {
+		t.Errorf("Expected destination to wait for time, got %s", destStatus)
+	}
+
+	// после назначения времени одобренный пост попадает в очередь
+	_, err = testRepo.UpdatePostByID(ctx, dto.PutPostRequest{
+		ID_user:      author.ID_user,
+		ID_workspace: ws.ID_workspace,
+		ID_post:      postID,
+		Title:        "Draft",
+		Content:      "Content",
+		Sheduled_for: time.Now().Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testPool.QueryRow(ctx, "SELECT p.status, pd.status FROM posts p JOIN post_destinations pd ON pd.post_id = p.id WHERE p.id = $1", postID).Scan(&status, &destStatus)
+	if status != domain.PostScheduled || destStatus != "scheduled" {
+		t.Errorf("Expected scheduled post, got %s/%s", status, destStatus)
+	}
+
+	// правка текста отправляет пост на повторную проверку
+	_, err = testRepo.UpdatePostByID(ctx, dto.PutPostRequest{
+		ID_user:      author.ID_user,
+		ID_workspace: ws.ID_workspace,
+		ID_post:      postID,
+		Title:        "Draft",
+		Content:      "New content",
+		Sheduled_for: time.Now().Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testPool.QueryRow(ctx, "SELECT p.status, pd.status FROM posts p JOIN post_destinations pd ON pd.post_id = p.id WHERE p.id = $1", postID).Scan(&status, &destStatus)
+	if status != domain.PostInReview || destStatus != "draft" {
+		t.Errorf("Expected post back in review, got %s/%s", status, destStatus)
+	}
+
+	// снижение требования пропускает посты на проверке
+	if err := testRepo.UpdateWorkspaceApprovals(ctx, ws.ID_workspace, 0); err != nil {
+		t.Fatal(err)
+	}
+	testPool.QueryRow(ctx, "SELECT status FROM posts WHERE id = $1", postID).Scan(&status)
+	if status != domain.PostScheduled {
+		t.Errorf("Expected post to be scheduled after lowering the rule, got %s", status)
+	}
+
+	reviews, err := testRepo.GetPostReviews(ctx, postID, ws.ID_workspace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reviews) != 5 || reviews[1].Comment != "too short" {
+		t.Errorf("Unexpected review history: %+v", reviews)
+	}
+}
+
+func TestPostRevisions(t *testing.T) {
+	cleanupTables()
+
+	postID, _, err := testRepo.CreatePost(ctx, dto.CreatePostRequest{
+		ID_user:      "1",
+		ID_workspace: 1,
+		Title:        "First title",
+		Content:      "Line one",
+		Status:       domain.PostDraft,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = testRepo.UpdatePostByID(ctx, dto.PutPostRequest{
+		ID_user:      "2",
+		ID_workspace: 1,
+		ID_post:      postID,
+		Title:        "First title",
+		Content:      "Line one\nLine two",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// изменений нет — новой ревизии нет
+	_, err = testRepo.UpdatePostByID(ctx, dto.PutPostRequest{
+		ID_user:      "2",
+		ID_workspace: 1,
+		ID_post:      postID,
+		Title:        "First title",
+		Content:      "Line one\nLine two",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	revisions, err := testRepo.GetPostRevisions(ctx, postID, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revisions) != 2 {
+		t.Fatalf("Expected 2 revisions, got %+v", revisions)
+	}
+	if revisions[0].Revision != 2 || revisions[0].ID_user != "2" || len(revisions[0].Changed_fields) != 1 || revisions[0].Changed_fields[0] != domain.FieldContent {
+		t.Errorf("Unexpected latest revision: %+v", revisions[0])
+	}
+	if _, err := testRepo.GetPostRevisions(ctx, postID, 2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := testRepo.GetPostRevision(ctx, postID, 2, 1); !errors.Is(err, repository.ErrRevisionNotFound) {
+		t.Errorf("Expected ErrRevisionNotFound for another workspace, got %v", err)
+	}
+
+	current, err := testRepo.RestorePostRevision(ctx, postID, 1, 1, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current != 3 {
+		t.Errorf("Expected restore to create revision 3, got %d", current)
+	}
+	restored, err := testRepo.GetPostRevision(ctx, postID, 1, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.Content != "Line one" || restored.ID_user != "1" {
+		t.Errorf("Unexpected restored revision: %+v", restored)
+	}
+	if _, err := testRepo.RestorePostRevision(ctx, postID, 1, 42, "1"); !errors.Is(err, repository.ErrRevisionNotFound) {
+		t.Errorf("Expected ErrRevisionNotFound, got %v", err)
+	}
+}
+
+func TestPostTemplates(t *testing.T) {
+	cleanupTables()
+
+	template, err := testRepo.CreateTemplate(ctx, dto.CreateTemplateRequest{
+		ID_user:      "1",
+		ID_workspace: 1,
+		Name:         "Meetup",
+		Title:        "{{event}} on {{date}}",
+		Content:      "Join: {{link}}",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(template.Variables) != 2 || template.Variables[0] != "event" || template.Variables[1] != "link" {
+		t.Errorf("Unexpected variables: %v", template.Variables)
+	}
+	_, err = testRepo.CreateTemplate(ctx, dto.CreateTemplateRequest{
+		ID_user:      "1",
+		ID_workspace: 1,
+		Name:         "Meetup",
+		Title:        "Other",
+		Content:      "Other",
+	})
+	if !errors.Is(err, repository.ErrTemplateNameTaken) {
+		t.Errorf("Expected ErrTemplateNameTaken, got %v", err)
+	}
+	// в другом пространстве имя свободно
+	if _, err := testRepo.CreateTemplate(ctx, dto.CreateTemplateRequest{
+		ID_user:      "2",
+		ID_workspace: 2,
+		Name:         "Meetup",
+		Title:        "Other",
+		Content:      "Other",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := testRepo.GetTemplateByID(ctx, template.ID_template, 2); !errors.Is(err, repository.ErrTemplateNotFound) {
+		t.Errorf("Expected ErrTemplateNotFound for another workspace, got %v", err)
+	}
+
+	updated, err := testRepo.UpdateTemplate(ctx, dto.PutTemplateRequest{
+		ID_template:  template.ID_template,
+		ID_workspace: 1,
+		Name:         "Meetup v2",
+		Title:        "{{event}}",
+		Content:      "Join us",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Name != "Meetup v2" || len(updated.Variables) != 1 {
+		t.Errorf("Unexpected template after update: %+v", updated)
+	}
+
+	vars := &domain.PostVariables{
+		Values:    map[string]string{"link": "https://example.com"},
+		Platforms: map[string]map[string]string{domain.PlatformTelegram: {"link": "https://t.me/meetup"}},
+	}
+	postID, _, err := testRepo.CreatePost(ctx, dto.CreatePostRequest{
+		ID_user:      "1",
+		ID_workspace: 1,
+		Title:        "Go meetup",
+		Content:      "Join: {{link}}",
+		Status:       domain.PostDraft,
+		ID_template:  template.ID_template,
+		Variables:    vars,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	message, err := testRepo.GetTitleANDContent(ctx, postID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if message.Variables == nil || message.Variables.For(domain.PlatformTelegram)["link"] != "https://t.me/meetup" {
+		t.Errorf("Variables were not stored: %+v", message.Variables)
+	}
+	if message.Timezone != "UTC" {
+		t.Errorf("Expected UTC for an author without a profile, got %q", message.Timezone)
+	}
+	testPool.Exec(ctx, "INSERT INTO users (id, email, timezone) VALUES ('1', 'author@example.com', 'Asia/Tokyo')")
+	if message, err := testRepo.GetTitleANDContent(ctx, postID); err != nil || message.Timezone != "Asia/Tokyo" {
+		t.Errorf("Expected the author timezone, got %q %v", message.Timezone, err)
+	}
+	if stored, err := testRepo.GetPostVariables(ctx, postID, 1); err != nil || stored == nil || stored.Values["link"] != "https://example.com" {
+		t.Errorf("Unexpected post variables: %+v %v", stored, err)
+	}
+	if _, err := testRepo.GetPostVariables(ctx, postID, 2); !errors.Is(err, repository.ErrPostNotFound) {
+		t.Errorf("Expected ErrPostNotFound for another workspace, got %v", err)
+	}
+
+	deleted, err := testRepo.DeleteTemplate(ctx, template.ID_template, 1)
+	if err != nil || !deleted {
+		t.Fatalf("Expected template to be deleted, got %v %v", deleted, err)
+	}
+	// пост, созданный из шаблона, остаётся
+	if _, err := testRepo.GetTitleANDContent(ctx, postID); err != nil {
+		t.Errorf("Post was removed with template: %v", err)
+	}
+}
+
+func TestCreatePostsTargetsPlatforms(t *testing.T) {
+	cleanupTables()
+
+	_, err := testPool.Exec(ctx, `
+		INSERT INTO platforms (id, user_id, workspace_id, platform_name, api_config) VALUES
+		(1, '1', 1, 'Telegram', '{}'),
+		(2, '1', 1, 'VK', '{}')
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids, err := testRepo.CreatePosts(ctx, []dto.CreatePostRequest{
+		{ID_user: "1", ID_workspace: 1, Title: "Only VK", Content: "text", Status: domain.PostDraft, Platforms: []int{2}},
+		{ID_user: "1", ID_workspace: 1, Title: "Everywhere", Content: "text", Status: domain.PostDraft},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 2 {
+		t.Fatalf("Expected 2 posts, got %v", ids)
+	}
+	for i, want := range []int{1, 2} {
+		var count int
+		if err := testPool.QueryRow(ctx, "SELECT COUNT(*) FROM post_destinations WHERE post_id = $1", ids[i]).Scan(&count); err != nil {
+			t.Fatal(err)
+		}
+		if count != want {
+			t.Errorf("Post %d: expected %d destinations, got %d", ids[i], want, count)
+		}
+	}
+
+	// одна ошибка откатывает весь пакет
+	_, err = testRepo.CreatePosts(ctx, []dto.CreatePostRequest{
+		{ID_user: "1", ID_workspace: 1, Title: "Fine", Content: "text", Status: domain.PostDraft},
+		{ID_user: "1", ID_workspace: 1, Title: strings.Repeat("x", 300), Content: "text", Status: domain.PostDraft},
+	})
+	if err == nil {
+		t.Fatal("Expected error for too long title")
+	}
+	var count int
+	if err := testPool.QueryRow(ctx, "SELECT COUNT(*) FROM posts").Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("Expected rollback to keep 2 posts, got %d", count)
+	}
+}
+
+func TestImportJobs(t *testing.T) {
+	cleanupTables()
+
+	job := domain.ImportJob{
+		ID_job:  "0b5c2a3e-8a9f-4c43-9a43-4d2f8f1c2b11",
+		ID_user: "1",
+		Status:  domain.ImportPending,
+		Total:   3,
+	}
+	if err := testRepo.CreateImportJob(ctx, 1, job); err != nil {
+		t.Fatal(err)
+	}
+	job.Status = domain.ImportDone
+	job.Created = 2
+	job.Failed = 1
+	job.Posts = []int{4, 5}
+	job.Errors = []domain.ImportLineError{{Line: 3, Errors: []string{"unknown platform \"X\""}}}
+	if err := testRepo.UpdateImportJob(ctx, job); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := testRepo.GetImportJob(ctx, job.ID_job, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != domain.ImportDone || got.Created != 2 || len(got.Posts) != 2 || got.Finished_at == nil {
+		t.Errorf("Unexpected job: %+v", got)
+	}
+	if len(got.Errors) != 1 || got.Errors[0].Line != 3 {
+		t.Errorf("Unexpected errors: %+v", got.Errors)
+	}
+	if _, err := testRepo.GetImportJob(ctx, job.ID_job, 2); !errors.Is(err, repository.ErrImportJobNotFound) {
+		t.Errorf("Expected ErrImportJobNotFound for another workspace, got %v", err)
+	}
+
+	// задача, оборванная перезапуском, и живая задача
+	stale := domain.ImportJob{ID_job: "6f1d7a52-3c1e-4d57-9d0a-2b8c1f0e9a21", ID_user: "1", Status: domain.ImportPending, Total: 5}
+	live := domain.ImportJob{ID_job: "9a3e5b17-0c4d-4b8e-a6f2-7d1c3e5f8b40", ID_user: "1", Status: domain.ImportPending, Total: 5}
+	for _, j := range []domain.ImportJob{stale, live} {
+		if err := testRepo.CreateImportJob(ctx, 1, j); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stale.Status = domain.ImportRunning
+	stale.Created = 2
+	stale.Posts = []int{6, 7}
+	testRepo.UpdateImportJob(ctx, stale)
+	testPool.Exec(ctx, "UPDATE post_import_jobs SET updated_at = NOW() - INTERVAL '1 hour' WHERE id = $1", stale.ID_job)
+
+	if n, err := testRepo.FailStaleImportJobs(ctx, 30*time.Minute); err != nil || n != 1 {
+		t.Fatalf("Expected one stale job, got %d %v", n, err)
+	}
+	got, _ = testRepo.GetImportJob(ctx, stale.ID_job, 1)
+	if got.Status != domain.ImportFailed || got.Failed != 3 || len(got.Posts) != 2 || got.Finished_at == nil || len(got.Errors) != 1 {
+		t.Errorf("Unexpected stale job: %+v", got)
+	}
+	if got, _ := testRepo.GetImportJob(ctx, live.ID_job, 1); got.Status != domain.ImportPending {
+		t.Errorf("Live job must stay pending, got %s", got.Status)
+	}
+	if got, _ := testRepo.GetImportJob(ctx, job.ID_job, 1); got.Status != domain.ImportDone {
+		t.Errorf("Finished job must stay done, got %s", got.Status)
+	}
+}
+
+func TestExportPosts(t *testing.T) {
+	cleanupTables()
+
+	_, err := testPool.Exec(ctx, `
+		INSERT INTO platforms (id, user_id, workspace_id, platform_name, api_config) VALUES
+		(1, '1', 1, 'Telegram', '{}'),
+		(2, '1', 1, 'VK', '{}')
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// больше одной пачки курсора
+	_, err = testPool.Exec(ctx, `
+		INSERT INTO posts (id, user_id, workspace_id, title, content, status)
+		SELECT i, '1', 1, 'Post ' || i, 'Content', 'scheduled' FROM generate_series(1, 600) i
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = testPool.Exec(ctx, `
+		INSERT INTO post_destinations (user_id, workspace_id, post_id, platform_id, scheduled_for, status)
+		SELECT '1', 1, i, 1, NOW(), 'scheduled' FROM generate_series(1, 600) i
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = testPool.Exec(ctx, `
+		INSERT INTO post_destinations (id, user_id, workspace_id, post_id, platform_id, scheduled_for, status)
+		VALUES (1000, '1', 1, 1, 2, NOW(), 'processing')
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := testRepo.MarkAsSent(ctx, 1000, 1, "-1_77"); err != nil {
+		t.Fatal(err)
+	}
+
+	var rows []domain.ExportRow
+	err = testRepo.ExportPosts(ctx, 1, dto.PostFilter{}, func(row domain.ExportRow) error {
+		rows = append(rows, row)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 601 {
+		t.Fatalf("Expected 601 rows, got %d", len(rows))
+	}
+	if rows[0].ID_post != 1 || rows[len(rows)-1].ID_post != 600 {
+		t.Errorf("Rows are not ordered by post: first %d, last %d", rows[0].ID_post, rows[len(rows)-1].ID_post)
+	}
+
+	var published []domain.ExportRow
+	err = testRepo.ExportPosts(ctx, 1, dto.PostFilter{Status: "published", ID_platform: 2}, func(row domain.ExportRow) error {
+		published = append(published, row)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(published) != 1 || published[0].Remote_id == nil || *published[0].Remote_id != "-1_77" || published[0].Published_at == nil {
+		t.Fatalf("Unexpected published rows: %+v", published)
+	}
+	// все времена строки в одной шкале
+	if d := published[0].Published_at.Sub(*published[0].Sheduled_for); d < -time.Minute || d > time.Minute {
+		t.Errorf("Published_at is %v away from scheduled_for", d)
+	}
+
+	posts, err := testRepo.GetPost(ctx, 1, dto.PostFilter{Status: "published"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(posts.Published) != 1 || len(posts.Scheduled) != 0 || posts.Published[0].Remote_id == nil {
+		t.Errorf("Filter was not applied to listing: %+v", posts)
+	}
+
+	// ошибка получателя прерывает выгрузку
+	stop := errors.New("client gone")
+	err = testRepo.ExportPosts(ctx, 1, dto.PostFilter{}, func(row domain.ExportRow) error {
+		return stop
+	})
+	if !errors.Is(err, stop) {
+		t.Errorf("Expected callback error, got %v", err)
+	}
+}
+
+func TestCalendarEvents(t *testing.T) {
+	cleanupTables()
+
+	owner, err := testRepo.FindOrCreateUser(ctx, domain.Identity{Provider: "google", ProviderUserID: "calendar-owner"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stranger, err := testRepo.FindOrCreateUser(ctx, domain.Identity{Provider: "google", ProviderUserID: "calendar-stranger"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mine, err := testRepo.GetDefaultWorkspace(ctx, owner.ID_user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := testRepo.GetDefaultWorkspace(ctx, stranger.ID_user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = testPool.Exec(ctx, `
+		INSERT INTO platforms (id, user_id, workspace_id, platform_name, api_config) VALUES
+		(1, $1, $2, 'Telegram', '{}'),
+		(2, $3, $4, 'VK', '{}')
+	`, owner.ID_user, mine, stranger.ID_user, other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = testPool.Exec(ctx, `
+		INSERT INTO posts (id, user_id, workspace_id, title, content, status) VALUES
+		(1, $1, $2, 'Upcoming', 'Content', 'scheduled'),
+		(2, $1, $2, 'Published', 'Content', 'published'),
+		(3, $1, $2, 'Draft', 'Content', 'draft'),
+		(4, $1, $2, 'Old', 'Content', 'published'),
+		(5, $3, $4, 'Foreign', 'Content', 'scheduled')
+	`, owner.ID_user, mine, stranger.ID_user, other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = testPool.Exec(ctx, `
+		INSERT INTO post_destinations (id, user_id, workspace_id, post_id, platform_id, scheduled_for, status, published_at) VALUES
+		(1, $1, $2, 1, 1, NOW() + INTERVAL '1 day', 'scheduled', NULL),
+		(2, $1, $2, 2, 1, NOW() - INTERVAL '1 day', 'published', NOW() - INTERVAL '1 day'),
+		(3, $1, $2, 3, 1, NULL, 'draft', NULL),
+		(4, $1, $2, 4, 1, NOW() - INTERVAL '200 days', 'published', NOW() - INTERVAL '200 days'),
+		(5, $3, $4, 5, 2, NOW() + INTERVAL '1 day', 'scheduled', NULL)
+	`, owner.ID_user, mine, stranger.ID_user, other)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := testRepo.GetCalendarEvents(ctx, strings.Repeat("d", 64)); !errors.Is(err, repository.ErrCalendarNotFound) {
+		t.Errorf("Expected ErrCalendarNotFound, got %v", err)
+	}
+	if err := testRepo.CreateCalendarToken(ctx, owner.ID_user, strings.Repeat("d", 64)); err != nil {
+		t.Fatal(err)
+	}
+	events, err := testRepo.GetCalendarEvents(ctx, strings.Repeat("d", 64))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].ID_destination != 2 || events[1].ID_destination != 1 {
+		t.Fatalf("Unexpected events: %+v", events)
+	}
+	if events[0].Published_at == nil || events[0].PlatformName != "Telegram" || events[1].Title != "Upcoming" {
+		t.Errorf("Unexpected event fields: %+v", events)
+	}
+
+	// новая ссылка заменяет старую
+	if err := testRepo.CreateCalendarToken(ctx, owner.ID_user, strings.Repeat("e", 64)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := testRepo.GetCalendarEvents(ctx, strings.Repeat("d", 64)); !errors.Is(err, repository.ErrCalendarNotFound) {
+		t.Errorf("Expected old token to be revoked, got %v", err)
+	}
+	deleted, err := testRepo.DeleteCalendarToken(ctx, owner.ID_user)
+	if err != nil || !deleted {
+		t.Errorf("Expected token to be deleted, got %v %v", deleted, err)
+	}
+	if deleted, _ := testRepo.DeleteCalendarToken(ctx, owner.ID_user); deleted {
+		t.Error("Expected nothing to delete")
+	}
+}
+
+func TestWebhookDeliveries(t *testing.T) {
+	cleanupTables()
+
+	_, err := testPool.Exec(ctx, `
+		INSERT INTO platforms (id, user_id, workspace_id, platform_name, api_config) VALUES (1, '1', 1, 'Telegram', '{}');
+		INSERT INTO posts (id, user_id, workspace_id, title, content, status) VALUES
+		(1, '1', 1, 'Mine', 'Content', 'scheduled'),
+		(2, '2', 1, 'Theirs', 'Content', 'scheduled');
+		INSERT INTO post_destinations (id, user_id, workspace_id, post_id, platform_id, scheduled_for, status) VALUES
+		(1, '1', 1, 1, 1, NOW(), 'processing'),
+		(2, '2', 1, 2, 1, NOW(), 'processing');
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	all, err := testRepo.CreateWebhook(ctx, 1, domain.Webhook{
+		ID_user: "1", Url: "https://example.com/all", Events: []string{domain.EventDestinationPublished, domain.EventDestinationFailed},
+		Scope: domain.WebhookScopeWorkspace, Secret: "whsec_all",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if all.Secret != "whsec_all" {
+		t.Errorf("Expected secret in create response, got %q", all.Secret)
+	}
+	var stored string
+	testPool.QueryRow(ctx, "SELECT secret FROM webhooks WHERE id = $1", all.ID_webhook).Scan(&stored)
+	if stored == "whsec_all" {
+		t.Error("Secret must be stored encrypted")
+	}
+	own, err := testRepo.CreateWebhook(ctx, 1, domain.Webhook{
+		ID_user: "1", Url: "https://example.com/own", Events: []string{domain.EventDestinationFailed},
+		Scope: domain.WebhookScopeUser, Secret: "whsec_own",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := testRepo.MarkAsSent(ctx, 1, 1, "77"); err != nil {
+		t.Fatal(err)
+	}
+	published, err := testRepo.GetDestinationEvent(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if published.Status != "published" || published.ID_user != "1" || published.Remote_id == nil || *published.Remote_id != "77" {
+		t.Errorf("Unexpected destination event: %+v", published)
+	}
+	n, err := testRepo.EnqueueWebhookEvent(ctx, domain.WebhookEvent{
+		Event: domain.EventDestinationPublished, ID_workspace: 1, ID_post: 1, ID_author: "1", Data: published,
+	})
+	if err != nil || n != 1 {
+		t.Fatalf("Expected 1 subscriber for published, got %d %v", n, err)
+	}
+
+	if err := testRepo.ErrorMessage(ctx, 2, errors.New("chat not found")); err != nil {
+		t.Fatal(err)
+	}
+	failed, err := testRepo.GetDestinationEvent(ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if failed.Status != "failed" || failed.ErrorMessage == nil || *failed.ErrorMessage != "chat not found" {
+		t.Errorf("Unexpected failed destination: %+v", failed)
+	}
+	// подписка со scope user не получает события чужих постов, автор берётся из поста
+	n, err = testRepo.EnqueueWebhookEvent(ctx, domain.WebhookEvent{
+		Event: domain.EventDestinationFailed, ID_workspace: 1, ID_post: 2, Data: failed,
+	})
+	if err != nil || n != 1 {
+		t.Fatalf("Expected 1 subscriber for a foreign post, got %d %v", n, err)
+	}
+	n, _ = testRepo.EnqueueWebhookEvent(ctx, domain.WebhookEvent{
+		Event: domain.EventDestinationFailed, ID_workspace: 1, ID_post: 1, Data: failed,
+	})
+	if n != 2 {
+		t.Errorf("Expected 2 subscribers for own post, got %d", n)
+	}
+
+	claimed, err := testRepo.ClaimWebhookDeliveries(ctx, 10, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(claimed) != 4 {
+		t.Fatalf("Expected 4 deliveries, got %d", len(claimed))
+	}
+	for _, d := range claimed {
+		if (d.ID_webhook == all.ID_webhook && d.Secret != "whsec_all") || (d.ID_webhook == own.ID_webhook && d.Secret != "whsec_own") || d.Url == "" {
+			t.Errorf("Unexpected claimed delivery: %+v", d)
+		}
+	}
+	// взятые доставки не выдаются повторно до конца lease
+	if again, _ := testRepo.ClaimWebhookDeliveries(ctx, 10, time.Minute); len(again) != 0 {
+		t.Errorf("Expected leased deliveries to be skipped, got %d", len(again))
+	}
+
+	code := 500
+	message := "unexpected status 500"
+	first := claimed[0]
+	first.Status = domain.DeliveryFailed
+	first.Attempts = 6
+	first.Response_code = &code
+	first.Last_error = &message
+	first.Next_attempt_at = nil
+	if err := testRepo.RecordWebhookAttempt(ctx, first); err != nil {
+		t.Fatal(err)
+	}
+	log, err := testRepo.GetWebhookDeliveries(ctx, first.ID_webhook, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var recorded *domain.WebhookDelivery
+	for i := range log {
+		if log[i].ID_delivery == first.ID_delivery {
+			recorded = &log[i]
+		}
+	}
+	if recorded == nil || recorded.Status != domain.DeliveryFailed || recorded.Attempts != 6 || *recorded.Response_code != 500 || len(recorded.Payload) == 0 {
+		t.Fatalf("Unexpected delivery log: %+v", log)
+	}
+
+	again, err := testRepo.RedeliverWebhook(ctx, first.ID_webhook, first.ID_delivery, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.ID_delivery == first.ID_delivery || again.ID_event != first.ID_event || again.Status != domain.DeliveryPending || again.Attempts != 0 {
+		t.Errorf("Unexpected redelivery: %+v", again)
+	}
+	if _, err := testRepo.RedeliverWebhook(ctx, first.ID_webhook, first.ID_delivery, 2); !errors.Is(err, repository.ErrDeliveryNotFound) {
+		t.Errorf("Expected ErrDeliveryNotFound for another workspace, got %v", err)
+	}
+	if _, err := testRepo.GetWebhookDeliveries(ctx, first.ID_webhook, 2); !errors.Is(err, repository.ErrWebhookNotFound) {
+		t.Errorf("Expected ErrWebhookNotFound for another workspace, got %v", err)
+	}
+
+	inactive := false
+	updated, err := testRepo.UpdateWebhook(ctx, all.ID_webhook, 1, nil, nil, &inactive)
+	if err != nil || updated.Is_active || updated.Url != "https://example.com/all" {
+		t.Errorf("Unexpected update: %+v %v", updated, err)
+	}
+	if n, _ := testRepo.EnqueueWebhookEvent(ctx, domain.WebhookEvent{Event: domain.EventDestinationPublished, ID_workspace: 1, ID_post: 1}); n != 0 {
+		t.Errorf("Inactive webhook must not get events, got %d", n)
+	}
+	deleted, err := testRepo.DeleteWebhook(ctx, own.ID_webhook, 1)
+	if err != nil || !deleted {
+		t.Errorf("Expected webhook to be deleted, got %v %v", deleted, err)
+	}
+}
+
+func TestClaimWebhookDeliveries_UndecryptableSecret(t *testing.T) {
+	cleanupTables()
+
+	hook, err := testRepo.CreateWebhook(ctx, 1, domain.Webhook{
+		ID_user: "1", Url: "https://example.com/broken", Events: []string{domain.EventDestinationPublished},
+		Scope: domain.WebhookScopeWorkspace, Secret: "whsec_broken",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// секрет зашифрован неизвестным ключом
+	if _, err := testPool.Exec(ctx, "UPDATE webhooks SET secret = 'enc:v1:missing:AAAA:AAAA' WHERE id = $1", hook.ID_webhook); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := testRepo.EnqueueWebhookEvent(ctx, domain.WebhookEvent{
+		Event: domain.EventDestinationPublished, ID_workspace: 1, ID_post: 1, ID_author: "1",
+	}); err != nil || n != 1 {
+		t.Fatalf("Expected 1 subscriber, got %d %v", n, err)
+	}
+
+	claimed, err := testRepo.ClaimWebhookDeliveries(ctx, 10, time.Minute)
+	if err != nil {
+		t.Fatalf("Undecryptable secret must not fail the batch, got %v", err)
+	}
+	if len(claimed) != 0 {
+		t.Errorf("Expected undecryptable delivery to be skipped, got %+v", claimed)
+	}
+	var status, lastError string
+	err = testPool.QueryRow(ctx, "SELECT status, last_error FROM webhook_deliveries WHERE webhook_id = $1", hook.ID_webhook).Scan(&status, &lastError)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != domain.DeliveryFailed || lastError != "cannot decrypt webhook secret" {
+		t.Errorf("Expected failed delivery, got %s %q", status, lastError)
+	}
+}
+
+func TestListenStatusChanges(t *testing.T) {
+	cleanupTables()
+
+	_, err := testPool.Exec(ctx, `
+		INSERT INTO post_destinations (id, user_id, workspace_id, post_id, platform_id, scheduled_for, status)
+		VALUES (1, '1', 4, 2, 3, NOW(), 'processing')
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	listenCtx, cancel := context.WithCancel(ctx)
+	events := make(chan domain.StatusEvent, 10)
+	done := make(chan error, 1)
+	go func() {
+		done <- testRepo.ListenStatusChanges(listenCtx, func(e domain.StatusEvent) { events <- e })
+	}()
+
+	// LISTEN выполняется в горутине: статус меняется, пока событие не придёт
+	var got domain.StatusEvent
+	deadline := time.After(5 * time.Second)
+wait:
+	for {
+		if err := testRepo.ErrorMessage(ctx, 1, errors.New("chat not found")); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case got = <-events:
+			break wait
+		case <-time.After(200 * time.Millisecond):
+			testPool.Exec(ctx, "UPDATE post_destinations SET status = 'processing' WHERE id = 1")
+		case <-deadline:
+			t.Fatal("No status event received")
+		}
+	}
+	if got.ID_destination != 1 || got.ID_workspace != 4 || got.ID_post != 2 || got.ID_platform != 3 || got.Status != "failed" ||
+		got.Previous_status != "processing" || got.ErrorMessage == nil || *got.ErrorMessage != "chat not found" {
+		t.Errorf("Unexpected event: %+v", got)
+	}
+
+	// без смены статуса уведомления нет
+	testPool.Exec(ctx, "UPDATE post_destinations SET error_message = 'again' WHERE id = 1")
+	select {
+	case e := <-events:
+		t.Errorf("Unexpected event without status change: %+v", e)
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	cancel()
+	if err := <-done; err == nil {
+		t.Error("Expected listener to stop with an error after cancel")
+	}
+}
+
+func TestTelegramLink(t *testing.T) {
+	cleanupTables()
+
+	alice, err := testRepo.FindOrCreateUser(ctx, domain.Identity{Provider: "google", ProviderUserID: "telegram-alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := testRepo.FindOrCreateUser(ctx, domain.Identity{Provider: "google", ProviderUserID: "telegram-bob"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	aliceCode, bobCode := strings.Repeat("a", 64), strings.Repeat("b", 64)
+	if err := testRepo.CreateAuthToken(ctx, alice.ID_user, domain.TokenLinkTelegram, aliceCode, time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if err := testRepo.CreateAuthToken(ctx, bob.ID_user, domain.TokenLinkTelegram, bobCode, time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	userID, err := testRepo.LinkTelegramChat(ctx, aliceCode, 100, "alice")
+	if err != nil || userID != alice.ID_user {
+		t.Fatalf("Expected chat linked to alice, got %q %v", userID, err)
+	}
+	// код одноразовый
+	if _, err := testRepo.LinkTelegramChat(ctx, aliceCode, 100, "alice"); !errors.Is(err, repository.ErrAuthTokenInvalid) {
+		t.Errorf("Expected ErrAuthTokenInvalid, got %v", err)
+	}
+	if userID, err := testRepo.GetTelegramUser(ctx, 100); err != nil || userID != alice.ID_user {
+		t.Errorf("Expected chat of alice, got %q %v", userID, err)
+	}
+	if chat, err := testRepo.GetTelegramChat(ctx, alice.ID_user); err != nil || chat != 100 {
+		t.Errorf("Unexpected chat %d %v", chat, err)
+	}
+
+	// тот же чат переходит к bob
+	if _, err := testRepo.LinkTelegramChat(ctx, bobCode, 100, "bob"); err != nil {
+		t.Fatal(err)
+	}
+	if userID, err := testRepo.GetTelegramUser(ctx, 100); err != nil || userID != bob.ID_user {
+		t.Errorf("Expected chat of bob, got %q %v", userID, err)
+	}
+	if _, err := testRepo.GetTelegramChat(ctx, alice.ID_user); !errors.Is(err, repository.ErrTelegramNotLinked) {
+		t.Errorf("Expected ErrTelegramNotLinked, got %v", err)
+	}
+	if ok, _ := testRepo.UnlinkTelegram(ctx, alice.ID_user); ok {
+		t.Error("Expected link of alice to be replaced")
+	}
+
+	if ok, err := testRepo.UnlinkTelegram(ctx, bob.ID_user); err != nil || !ok {
+		t.Errorf("Expected unlink, got %v %v", ok, err)
+	}
+	if _, err := testRepo.GetTelegramUser(ctx, 100); !errors.Is(err, repository.ErrTelegramNotLinked) {
+		t.Errorf("Expected ErrTelegramNotLinked, got %v", err)
+	}
+}
+
+func TestBotScheduleChanges(t *testing.T) {
+	cleanupTables()
+
+	_, err := testPool.Exec(ctx, `
+		INSERT INTO platforms (id, user_id, workspace_id, platform_name, api_config) VALUES
+		(1, '1', 1, 'Telegram', '{}'),
+		(2, '1', 1, 'VK', '{}')
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = testPool.Exec(ctx, `
+		INSERT INTO posts (id, user_id, workspace_id, title, content, status) VALUES
+		(1, '1', 1, 'Launch', 'Content', 'scheduled'),
+		(2, '1', 2, 'Foreign', 'Content', 'scheduled')
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = testPool.Exec(ctx, `
+		INSERT INTO post_destinations (id, user_id, workspace_id, post_id, platform_id, scheduled_for, status, error_message) VALUES
+		(1, '1', 1, 1, 1, NOW() + INTERVAL '1 day', 'scheduled', NULL),
+		(2, '1', 1, 1, 2, NOW() - INTERVAL '1 hour', 'failed', 'chat not found'),
+		(3, '1', 2, 2, 1, NOW() + INTERVAL '2 days', 'scheduled', NULL)
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	upcoming, err := testRepo.GetUpcomingDestinations(ctx, 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(upcoming) != 1 || upcoming[0].ID_destination != 1 || upcoming[0].Title != "Launch" || upcoming[0].PlatformName != "Telegram" {
+		t.Errorf("Unexpected upcoming: %+v", upcoming)
+	}
+
+	if n, err := testRepo.CancelPost(ctx, 1, 1); err != nil || n != 1 {
+		t.Fatalf("Expected one cancelled destination, got %d %v", n, err)
+	}
+	if _, err := testRepo.CancelPost(ctx, 2, 1); !errors.Is(err, repository.ErrPostNotFound) {
+		t.Errorf("Expected ErrPostNotFound for foreign post, got %v", err)
+	}
+
+	at := time.Now().Add(48 * time.Hour).Truncate(time.Second)
+	n, err := testRepo.RescheduleDestinations(ctx, 1, 1, "1", at)
+	if err != nil || n != 1 {
+		t.Fatalf("Expected one rescheduled destination, got %d %v", n, err)
+	}
+	var status string
+	var sheduledFor time.Time
+	testPool.QueryRow(ctx, "SELECT status, scheduled_for FROM post_destinations WHERE id = 1").Scan(&status, &sheduledFor)
+	if status != "scheduled" || !sheduledFor.Equal(at) {
+		t.Errorf("Expected cancelled destination back in schedule, got %s %v", status, sheduledFor)
+	}
+	// неудачная публикация переносом не трогается
+	testPool.QueryRow(ctx, "SELECT status FROM post_destinations WHERE id = 2").Scan(&status)
+	if status != "failed" {
+		t.Errorf("Expected failed destination untouched, got %s", status)
+	}
+	var changed []string
+	testPool.QueryRow(ctx, "SELECT changed_fields FROM post_revisions WHERE post_id = 1 ORDER BY revision DESC LIMIT 1").Scan(&changed)
+	if len(changed) != 1 || changed[0] != domain.FieldSheduledFor {
+		t.Errorf("Expected revision of scheduled time, got %v", changed)
+	}
+
+	if ok, err := testRepo.RetryDestination(ctx, 2, 2); err != nil || ok {
+		t.Errorf("Expected no retry in another workspace, got %v %v", ok, err)
+	}
+	if ok, err := testRepo.RetryDestination(ctx, 2, 1); err != nil || !ok {
+		t.Fatalf("Expected retry, got %v %v", ok, err)
+	}
+	var errorMessage *string
+	testPool.QueryRow(ctx, "SELECT status, error_message FROM post_destinations WHERE id = 2").Scan(&status, &errorMessage)
+	if status != "scheduled" || errorMessage != nil {
+		t.Errorf("Expected scheduled destination without error, got %s %v", status, errorMessage)
+	}
+	if ok, _ := testRepo.RetryDestination(ctx, 2, 1); ok {
+		t.Error("Expected second retry to do nothing")
+	}
+
+	// processing повторяется, только если публикация зависла
+	testPool.Exec(ctx, "UPDATE post_destinations SET status = 'processing', processing_started_at = NOW() WHERE id = 2")
+	if ok, _ := testRepo.RetryDestination(ctx, 2, 1); ok {
+		t.Error("Expected no retry of a publication in progress")
+	}
+	testPool.Exec(ctx, "UPDATE post_destinations SET processing_started_at = NOW() - INTERVAL '1 hour' WHERE id = 2")
+	if ok, err := testRepo.RetryDestination(ctx, 2, 1); err != nil || !ok {
+		t.Errorf("Expected retry of a stuck publication, got %v %v", ok, err)
+	}
+}
+
+func TestNotificationSettings(t *testing.T) {
+	cleanupTables()
+
+	user, err := testRepo.FindOrCreateUser(ctx, domain.Identity{Provider: "google", ProviderUserID: "settings"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	settings, err := testRepo.GetNotificationSettings(ctx, user.ID_user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(settings, domain.DefaultNotificationSettings()) {
+		t.Errorf("Expected default settings, got %+v", settings)
+	}
+
+	updated := domain.NotificationSettings{
+		Telegram:    true,
+		Kinds:       []string{domain.NotificationPublicationFailed},
+		Delivery:    domain.NotifyDaily,
+		Digest_hour: 8,
+	}
+	if _, err := testRepo.UpdateNotificationSettings(ctx, user.ID_user, updated); err != nil {
+		t.Fatal(err)
+	}
+	updated.Webhook = true
+	if _, err := testRepo.UpdateNotificationSettings(ctx, user.ID_user, updated); err != nil {
+		t.Fatal(err)
+	}
+	settings, err = testRepo.GetNotificationSettings(ctx, user.ID_user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(settings, updated) {
+		t.Errorf("Expected %+v, got %+v", updated, settings)
+	}
+}
+
+func TestPendingNotifications(t *testing.T) {
+	cleanupTables()
+
+	_, err := testPool.Exec(ctx, `
+		INSERT INTO users (id, email, password_hash) VALUES
+		('1', 'local@example.com', 'hash'),
+		('2', 'oauth@example.com', NULL)
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testPool.Exec(ctx, "INSERT INTO platforms (id, user_id, workspace_id, platform_name, api_config) VALUES (1, '1', 1, 'Telegram', '{}')")
+	testPool.Exec(ctx, "INSERT INTO posts (id, user_id, workspace_id, title, content, status) VALUES (1, '1', 1, 'Launch', 'Content', 'scheduled')")
+	testPool.Exec(ctx, `
+		INSERT INTO post_destinations (id, user_id, workspace_id, post_id, platform_id, scheduled_for, status, processing_started_at) VALUES
+		(1, '1', 1, 1, 1, NOW() - INTERVAL '1 hour', 'processing', NOW() - INTERVAL '1 hour'),
+		(2, '1', 1, 1, 1, NOW(), 'processing', NOW()),
+		(3, '1', 1, 1, 1, NOW(), 'scheduled', NULL)
+	`)
+
+	postID, destinationID := 1, 3
+	if err := testRepo.CreateNotification(ctx, domain.Notification{ID_user: "1", Kind: domain.NotificationPublicationFailed, Message: "failed", ID_platform: &postID, ID_post: &postID, ID_destination: &destinationID}); err != nil {
+		t.Fatal(err)
+	}
+	if err := testRepo.CreateNotification(ctx, domain.Notification{ID_user: "2", Kind: domain.NotificationPlatformDegraded, Message: "degraded"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// зависшая публикация попадает в уведомления один раз
+	if n, err := testRepo.NotifyStuckDestinations(ctx, 30*time.Minute); err != nil || n != 1 {
+		t.Fatalf("Expected one stuck publication, got %d %v", n, err)
+	}
+	if n, _ := testRepo.NotifyStuckDestinations(ctx, 30*time.Minute); n != 0 {
+		t.Errorf("Expected stuck publication notified once, got %d", n)
+	}
+
+	// окно пакетирования по умолчанию (5 минут) уже прошло
+	testPool.Exec(ctx, "UPDATE notifications SET created_at = created_at - INTERVAL '10 minutes'")
+	// у третьего пользователя уведомление старше, но окно в час ещё не закрылось:
+	// он не занимает место в выборке
+	testPool.Exec(ctx, "INSERT INTO users (id, email) VALUES ('3', 'batch@example.com')")
+	if _, err := testRepo.UpdateNotificationSettings(ctx, "3", domain.NotificationSettings{
+		Email: true, Delivery: domain.NotifyImmediate, Batch_minutes: 60, Digest_hour: 9,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	testPool.Exec(ctx, "INSERT INTO notifications (user_id, kind, message, created_at) VALUES ('3', $1, 'degraded', NOW() - INTERVAL '30 minutes')", domain.NotificationPlatformDegraded)
+	if first, err := testRepo.GetPendingNotifications(ctx, 1); err != nil || len(first) != 1 || first[0].ID_user != "1" {
+		t.Fatalf("Expected the oldest due user first, got %+v %v", first, err)
+	}
+
+	batches, err := testRepo.GetPendingNotifications(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batches) != 2 {
+		t.Fatalf("Expected batches of two users, got %+v", batches)
+	}
+	local, oauth := batches[0], batches[1]
+	if local.ID_user != "1" || len(local.Items) != 2 || local.Email != nil {
+		t.Errorf("Unexpected batch of local user: %+v", local)
+	}
+	if local.Items[0].ID_workspace == nil || *local.Items[0].ID_workspace != 1 {
+		t.Errorf("Expected workspace taken from platform, got %v", local.Items[0].ID_workspace)
+	}
+	if local.Items[1].Kind != domain.NotificationPublicationStuck || local.Items[1].ID_destination == nil || *local.Items[1].ID_destination != 1 {
+		t.Errorf("Expected stuck notification of destination 1, got %+v", local.Items[1])
+	}
+	if oauth.Email == nil || *oauth.Email != "oauth@example.com" {
+		t.Errorf("Unexpected batch of oauth user: %+v", oauth)
+	}
+
+	claimed, err := testRepo.ClaimNotifications(ctx, "1", []int{local.Items[0].ID_notification, oauth.Items[0].ID_notification})
+	if err != nil || len(claimed) != 1 || claimed[0] != local.Items[0].ID_notification {
+		t.Fatalf("Expected only own notification claimed, got %v %v", claimed, err)
+	}
+	if claimed, _ := testRepo.ClaimNotifications(ctx, "1", claimed); len(claimed) != 0 {
+		t.Errorf("Expected notification claimed once, got %v", claimed)
+	}
+
+	if ok, err := testRepo.StartProcessing(ctx, 3); err != nil || !ok {
+		t.Errorf("Expected processing started, got %v %v", ok, err)
+	}
+	if ok, _ := testRepo.StartProcessing(ctx, 3); ok {
+		t.Error("Expected destination taken once")
+	}
+}
@@ -0,0 +1,238 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"hexlet/internal/domain"
+
+	"github.com/jackc/pgx/v4"
+	"go.uber.org/zap"
+)
+
+var ErrPlatformChanged = errors.New("platform credentials changed since the check")
+
+// GetPlatformsToCheck возвращает платформы для фоновой проверки: активные
+// и отключённые монитором, чтобы они включились, когда доступ восстановится.
+// Читает с мастера: на реплике могут быть ещё старые учётные данные.
+func (r *Repository) GetPlatformsToCheck(ctx context.Context) ([]domain.PlatformSQL, error) {
+	rows, err := r.MasterPool.Query(ctx, `
+		SELECT id, user_id, platform_name, api_config, is_active, health_status, auth_failures, config_version
+		FROM platforms
+		WHERE is_active OR health_status = 'inactive'
+		ORDER BY id`)
+	if err != nil {
+		r.logger.Error("GetPlatformsToCheck failed in query", zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+	var res []domain.PlatformSQL
+	for rows.Next() {
+		var p domain.PlatformSQL
+		err := rows.Scan(&p.ID, &p.UserID, &p.PlatformName, &p.Config, &p.IsActive, &p.HealthStatus, &p.AuthFailures, &p.ConfigVersion)
+		if err != nil {
+			r.logger.Error("GetPlatformsToCheck failed in scaning", zap.Error(err))
+			return nil, err
+		}
+		p.Config, err = r.decryptConfig(p.Config)
+		if err != nil {
+			r.logger.Error("GetPlatformsToCheck failed in decrypting",
+				zap.Error(err),
+				zap.Int("platform_id", p.ID),
+			)
+			continue
+		}
+		res = append(res, p)
+	}
+	return res, rows.Err()
+}
+
+// RecordPlatformHealth сохраняет результат фоновой проверки платформы и возвращает
+// прежний и новый статус. Счётчик ошибок считается в БД: монитор работает на каждом
+// экземпляре. После degradeAfter подряд ошибок авторизации платформа degraded,
+// после deactivateAfter — inactive и отключается. Успешная проверка сбрасывает счётчик,
+// отключённую монитором платформу включает (если не на паузе) и возвращает её
+// отложенные публикации в расписание. Сетевые ошибки и отсутствие прав не считаются.
+// Если учётные данные изменились после чтения p — ErrPlatformChanged.
+func (r *Repository) RecordPlatformHealth(ctx context.Context, p domain.PlatformSQL, report domain.VerificationReport, degradeAfter int, deactivateAfter int) (string, domain.PlatformSQL, error) {
+	var previous string
+	next := p
+	err := r.MasterPool.BeginFunc(ctx, func(tx pgx.Tx) error {
+		err := tx.QueryRow(ctx, `
+			UPDATE platforms p
+			SET verification = $1,
+				auth_failures = n.auth_failures,
+				health_status = CASE
+					WHEN $2 THEN 'healthy'
+					WHEN NOT $3 THEN p.health_status
+					WHEN n.auth_failures >= $5 THEN 'inactive'
+					WHEN n.auth_failures >= $4 THEN 'degraded'
+					ELSE p.health_status
+				END,
+				is_active = CASE
+					WHEN $2 THEN p.is_active OR (p.health_status = 'inactive' AND p.paused_at IS NULL)
+					WHEN $3 AND n.auth_failures >= $5 THEN false
+					ELSE p.is_active
+				END
+			FROM (
+				SELECT id, health_status,
+					CASE WHEN $2 THEN 0 WHEN $3 THEN auth_failures + 1 ELSE auth_failures END AS auth_failures
+				FROM platforms
+				WHERE id = $6 AND config_version = $7
+				FOR UPDATE
+			) n
+			WHERE p.id = n.id
+			RETURNING n.health_status, p.health_status, p.auth_failures, p.is_active`,
+			report, report.OK, report.AuthFailed, degradeAfter, deactivateAfter, p.ID, p.ConfigVersion,
+		).Scan(&previous, &next.HealthStatus, &next.AuthFailures, &next.IsActive)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrPlatformChanged
+		}
+		if err != nil {
+			return err
+		}
+		if previous != domain.HealthInactive || !next.IsActive {
+			return nil
+		}
+		_, err = tx.Exec(ctx, `
+			UPDATE post_destinations
+			SET status = 'scheduled'
+			WHERE platform_id = $1 AND status = 'held'`,
+			p.ID,
+		)
+		return err
+	})
+	if err != nil {
+		if !errors.Is(err, ErrPlatformChanged) {
+			r.logger.Error("RecordPlatformHealth failed",
+				zap.Error(err),
+				zap.Int("platform_id", p.ID),
+			)
+		}
+		return "", domain.PlatformSQL{}, err
+	}
+	return previous, next, nil
+}
+
+// HoldDestinations откладывает запланированные публикации платформы,
+// если она всё ещё отключена монитором
+func (r *Repository) HoldDestinations(ctx context.Context, ID_platform int) (int64, error) {
+	tag, err := r.MasterPool.Exec(ctx, `
+		UPDATE post_destinations
+		SET status = 'held'
+		WHERE platform_id = $1 AND status = 'scheduled'
+		AND EXISTS (SELECT 1 FROM platforms WHERE id = $1 AND health_status = 'inactive')`,
+		ID_platform,
+	)
+	if err != nil {
+		r.logger.Error("HoldDestinations failed",
+			zap.Error(err),
+			zap.Int("platform_id", ID_platform),
+		)
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+func (r *Repository) HoldDestination(ctx context.Context, ID_destination int) error {
+	_, err := r.MasterPool.Exec(ctx, "UPDATE post_destinations SET status = 'held' WHERE id = $1", ID_destination)
+	if err != nil {
+		r.logger.Error("HoldDestination failed",
+			zap.Error(err),
+			zap.Int("post_destinations_id", ID_destination),
+		)
+		return err
+	}
+	return nil
+}
+
+// restorePlatformHealth сбрасывает счётчик ошибок после успешной проверки учётных данных версии configVersion.
+// Платформа, отключённая монитором, включается обратно (если не стоит на паузе),
+// отложенные публикации возвращаются в расписание.
+func (r *Repository) restorePlatformHealth(ctx context.Context, ID_platform int, configVersion int) error {
+	var wasDeactivated bool
+	err := r.MasterPool.QueryRow(ctx, `
+		UPDATE platforms p
+		SET auth_failures = 0, health_status = 'healthy', is_active = p.is_active OR (old.health_status = 'inactive' AND p.paused_at IS NULL)
+		FROM (SELECT id, health_status FROM platforms WHERE id = $1 AND config_version = $2 FOR UPDATE) old
+		WHERE p.id = old.id
+		RETURNING old.health_status = 'inactive' AND p.paused_at IS NULL`,
+		ID_platform, configVersion,
+	).Scan(&wasDeactivated)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrPlatformChanged
+	}
+	if err != nil {
+		r.logger.Error("restorePlatformHealth failed in updating platforms",
+			zap.Error(err),
+			zap.Int("platform_id", ID_platform),
+		)
+		return err
+	}
+	if !wasDeactivated {
+		return nil
+	}
+	_, err = r.MasterPool.Exec(ctx, `
+		UPDATE post_destinations
+		SET status = 'scheduled'
+		WHERE platform_id = $1 AND status = 'held'`,
+		ID_platform,
+	)
+	if err != nil {
+		r.logger.Error("restorePlatformHealth failed in updating post_destinations",
+			zap.Error(err),
+			zap.Int("platform_id", ID_platform),
+		)
+		return err
+	}
+	return nil
+}
+
+// CreateNotification сохраняет уведомление и ставит его в очередь рассылки по каналам.
+// Пространство без явного указания берётся у платформы.
+func (r *Repository) CreateNotification(ctx context.Context, n domain.Notification) error {
+	_, err := r.MasterPool.Exec(ctx, `
+		INSERT INTO notifications (user_id, kind, message, platform_id, workspace_id, post_id, destination_id)
+		VALUES ($1, $2, $3, $4, COALESCE($5, (SELECT workspace_id FROM platforms WHERE id = $4)), $6, $7)`,
+		n.ID_user, n.Kind, n.Message, n.ID_platform, n.ID_workspace, n.ID_post, n.ID_destination,
+	)
+	if err != nil {
+		r.logger.Error("CreateNotification failed",
+			zap.Error(err),
+			zap.String("user_id", n.ID_user),
+		)
+		return err
+	}
+	return nil
+}
+
+func (r *Repository) GetNotifications(ctx context.Context, ID_user string) ([]domain.Notification, error) {
+	rows, err := r.SlavePool.Query(ctx, `
+		SELECT id, user_id, kind, message, platform_id, workspace_id, post_id, destination_id, created_at
+		FROM notifications
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+		LIMIT 100`,
+		ID_user,
+	)
+	if err != nil {
+		r.logger.Error("GetNotifications failed",
+			zap.Error(err),
+			zap.String("user_id", ID_user),
+		)
+		return nil, err
+	}
+	defer rows.Close()
+	res := []domain.Notification{}
+	for rows.Next() {
+		var n domain.Notification
+		if err := rows.Scan(&n.ID_notification, &n.ID_user, &n.Kind, &n.Message, &n.ID_platform, &n.ID_workspace, &n.ID_post, &n.ID_destination, &n.Created_at); err != nil {
+			r.logger.Error("GetNotifications failed in scaning",
+				zap.Error(err),
+				zap.String("user_id", ID_user),
+			)
+			return nil, err
+		}
+		res = append(res, n)
+	}
+	return res, rows.Err()
+}
@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"hexlet/internal/domain"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"go.uber.org/zap"
+)
+
+var ErrImportJobNotFound = errors.New("import job not found")
+
+func (r *Repository) CreateImportJob(ctx context.Context, ID_workspace int, job domain.ImportJob) error {
+	_, err := r.MasterPool.Exec(ctx, `
+		INSERT INTO post_import_jobs (id, workspace_id, user_id, status, all_or_nothing, total)
+		VALUES ($1, $2, $3, $4, $5, $6)`,
+		job.ID_job, ID_workspace, job.ID_user, job.Status, job.All_or_nothing, job.Total,
+	)
+	if err != nil {
+		r.logger.Error("CreateImportJob failed",
+			zap.Error(err),
+			zap.Int("workspace_id", ID_workspace),
+		)
+	}
+	return err
+}
+
+// UpdateImportJob сохраняет прогресс; finished_at ставится, когда задача завершена
+func (r *Repository) UpdateImportJob(ctx context.Context, job domain.ImportJob) error {
+	if job.Errors == nil {
+		job.Errors = []domain.ImportLineError{}
+	}
+	if job.Posts == nil {
+		job.Posts = []int{}
+	}
+	_, err := r.MasterPool.Exec(ctx, `
+		UPDATE post_import_jobs
+		SET status = $2, created = $3, failed = $4, errors = $5, post_ids = $6, updated_at = NOW(),
+			finished_at = CASE WHEN $2 IN ('done', 'failed') THEN NOW() END
+		WHERE id = $1`,
+		job.ID_job, job.Status, job.Created, job.Failed, job.Errors, job.Posts,
+	)
+	if err != nil {
+		r.logger.Error("UpdateImportJob failed",
+			zap.Error(err),
+			zap.String("job_id", job.ID_job),
+		)
+	}
+	return err
+}
+
+func (r *Repository) GetImportJob(ctx context.Context, ID_job string, ID_workspace int) (domain.ImportJob, error) {
+	var job domain.ImportJob
+	err := r.SlavePool.QueryRow(ctx, `
+		SELECT id::TEXT, user_id, status, all_or_nothing, total, created, failed, errors, post_ids, created_at, finished_at
+		FROM post_import_jobs
+		WHERE id = $1 AND workspace_id = $2`,
+		ID_job, ID_workspace,
+	).Scan(&job.ID_job, &job.ID_user, &job.Status, &job.All_or_nothing, &job.Total, &job.Created, &job.Failed,
+		&job.Errors, &job.Posts, &job.Created_at, &job.Finished_at)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.ImportJob{}, ErrImportJobNotFound
+	}
+	if err != nil {
+		r.logger.Error("GetImportJob failed",
+			zap.Error(err),
+			zap.String("job_id", ID_job),
+		)
+		return domain.ImportJob{}, err
+	}
+	return job, nil
+}
+
+// FailStaleImportJobs помечает failed задачи, которые не сохраняли прогресс дольше idle:
+// их экземпляр остановился посреди импорта. Уже созданные посты остаются в задаче.
+func (r *Repository) FailStaleImportJobs(ctx context.Context, idle time.Duration) (int64, error) {
+	tag, err := r.MasterPool.Exec(ctx, `
+		UPDATE post_import_jobs
+		SET status = 'failed', failed = total - created, finished_at = NOW(), updated_at = NOW(),
+			errors = errors || jsonb_build_array(jsonb_build_object('line', 0, 'errors', jsonb_build_array('import interrupted')))
+		WHERE status IN ('pending', 'running') AND updated_at < NOW() - $1 * INTERVAL '1 second'`,
+		idle.Seconds(),
+	)
+	if err != nil {
+		r.logger.Error("FailStaleImportJobs failed", zap.Error(err))
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
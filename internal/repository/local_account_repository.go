@@ -0,0 +1,212 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"hexlet/internal/domain"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrEmailTaken       = errors.New("email is already registered")
+	ErrAuthTokenInvalid = errors.New("token is invalid or expired")
+)
+
+// код ошибки PostgreSQL unique_violation
+const uniqueViolation = "23505"
+
+func (r *Repository) CreateLocalUser(ctx context.Context, email string, name string, passwordHash string) (domain.User, error) {
+	var res domain.User
+	err := r.MasterPool.BeginFunc(ctx, func(tx pgx.Tx) error {
+		var err error
+		res, err = scanUser(tx.QueryRow(ctx, `
+			INSERT INTO users (email, name, password_hash)
+			VALUES ($1, NULLIF($2, ''), $3)
+			RETURNING `+userColumns,
+			email, name, passwordHash,
+		))
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx,
+			"INSERT INTO user_identities (provider, provider_user_id, user_id) VALUES ($1, $2, $3)",
+			domain.ProviderLocal, strings.ToLower(email), res.ID_user,
+		)
+		if err != nil {
+			return err
+		}
+		_, err = createWorkspace(ctx, tx, personalWorkspace, res.ID_user)
+		return err
+	})
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+		return domain.User{}, ErrEmailTaken
+	}
+	if err != nil {
+		r.logger.Error("CreateLocalUser failed", zap.Error(err))
+		return domain.User{}, err
+	}
+	return res, nil
+}
+
+// GetLocalCredentials читает с мастера: счётчик неудачных попыток должен быть актуальным
+func (r *Repository) GetLocalCredentials(ctx context.Context, email string) (domain.LocalCredentials, error) {
+	var res domain.LocalCredentials
+	err := r.MasterPool.QueryRow(ctx, `
+		SELECT u.id, u.email, u.password_hash, u.email_verified_at IS NOT NULL, u.locked_until
+		FROM user_identities i
+		JOIN users u ON u.id = i.user_id
+		WHERE i.provider = $1 AND i.provider_user_id = $2 AND u.password_hash IS NOT NULL`,
+		domain.ProviderLocal, strings.ToLower(email),
+	).Scan(&res.ID_user, &res.Email, &res.Password_hash, &res.Email_verified, &res.Locked_until)
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			r.logger.Error("GetLocalCredentials failed", zap.Error(err))
+		}
+		return domain.LocalCredentials{}, err
+	}
+	return res, nil
+}
+
+// RecordLoginFailure увеличивает счётчик неудачных входов. После maxFailures подряд
+// вход блокируется на lockFor, счётчик начинается заново. Возвращает время окончания блокировки.
+func (r *Repository) RecordLoginFailure(ctx context.Context, ID_user string, maxFailures int, lockFor time.Duration) (*time.Time, error) {
+	var lockedUntil *time.Time
+	err := r.MasterPool.QueryRow(ctx, `
+		UPDATE users
+		SET failed_logins = CASE WHEN failed_logins + 1 >= $2 THEN 0 ELSE failed_logins + 1 END,
+			locked_until = CASE WHEN failed_logins + 1 >= $2 THEN NOW() + $3 * INTERVAL '1 second' ELSE locked_until END
+		WHERE id = $1
+		RETURNING locked_until`,
+		ID_user, maxFailures, int(lockFor.Seconds()),
+	).Scan(&lockedUntil)
+	if err != nil {
+		r.logger.Error("RecordLoginFailure failed",
+			zap.Error(err),
+			zap.String("user_id", ID_user),
+		)
+		return nil, err
+	}
+	return lockedUntil, nil
+}
+
+func (r *Repository) ResetLoginFailures(ctx context.Context, ID_user string) error {
+	_, err := r.MasterPool.Exec(ctx, "UPDATE users SET failed_logins = 0, locked_until = NULL WHERE id = $1", ID_user)
+	if err != nil {
+		r.logger.Error("ResetLoginFailures failed",
+			zap.Error(err),
+			zap.String("user_id", ID_user),
+		)
+		return err
+	}
+	return nil
+}
+
+func (r *Repository) CreateAuthToken(ctx context.Context, ID_user string, purpose string, tokenHash string, expiresAt time.Time) error {
+	_, err := r.MasterPool.Exec(ctx, `
+		INSERT INTO auth_tokens (token_hash, user_id, purpose, expires_at)
+		VALUES ($1, $2, $3, $4)`,
+		tokenHash, ID_user, purpose, expiresAt,
+	)
+	if err != nil {
+		r.logger.Error("CreateAuthToken failed",
+			zap.Error(err),
+			zap.String("user_id", ID_user),
+			zap.String("purpose", purpose),
+		)
+		return err
+	}
+	return nil
+}
+
+// useAuthToken гасит одноразовый токен и возвращает его владельца
+func useAuthToken(ctx context.Context, tx pgx.Tx, purpose string, tokenHash string) (string, error) {
+	var userID string
+	err := tx.QueryRow(ctx, `
+		UPDATE auth_tokens
+		SET used_at = NOW()
+		WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > NOW()
+		RETURNING user_id`,
+		tokenHash, purpose,
+	).Scan(&userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", ErrAuthTokenInvalid
+	}
+	return userID, err
+}
+
+// UseAuthToken гасит одноразовый токен, которому не нужны другие изменения в той же транзакции
+func (r *Repository) UseAuthToken(ctx context.Context, purpose string, tokenHash string) (string, error) {
+	var userID string
+	err := r.MasterPool.BeginFunc(ctx, func(tx pgx.Tx) error {
+		var err error
+		userID, err = useAuthToken(ctx, tx, purpose, tokenHash)
+		return err
+	})
+	if err != nil && !errors.Is(err, ErrAuthTokenInvalid) {
+		r.logger.Error("UseAuthToken failed",
+			zap.Error(err),
+			zap.String("purpose", purpose),
+		)
+	}
+	if err != nil {
+		return "", err
+	}
+	return userID, nil
+}
+
+func (r *Repository) VerifyEmail(ctx context.Context, tokenHash string) error {
+	err := r.MasterPool.BeginFunc(ctx, func(tx pgx.Tx) error {
+		userID, err := useAuthToken(ctx, tx, domain.TokenVerifyEmail, tokenHash)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, "UPDATE users SET email_verified_at = COALESCE(email_verified_at, NOW()) WHERE id = $1", userID)
+		return err
+	})
+	if err != nil && !errors.Is(err, ErrAuthTokenInvalid) {
+		r.logger.Error("VerifyEmail failed", zap.Error(err))
+	}
+	return err
+}
+
+// ResetPassword меняет пароль по токену из письма, снимает блокировку
+// и отзывает все сессии пользователя.
+func (r *Repository) ResetPassword(ctx context.Context, tokenHash string, passwordHash string) error {
+	err := r.MasterPool.BeginFunc(ctx, func(tx pgx.Tx) error {
+		userID, err := useAuthToken(ctx, tx, domain.TokenResetPassword, tokenHash)
+		if err != nil {
+			return err
+		}
+		// письмо со ссылкой сброса дошло, значит email рабочий
+		_, err = tx.Exec(ctx, `
+			UPDATE users
+			SET password_hash = $2, failed_logins = 0, locked_until = NULL,
+				email_verified_at = COALESCE(email_verified_at, NOW()), updated_at = NOW()
+			WHERE id = $1`,
+			userID, passwordHash,
+		)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, `
+			UPDATE auth_tokens SET used_at = NOW()
+			WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL`,
+			userID, domain.TokenResetPassword,
+		)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, "UPDATE sessions SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL", userID)
+		return err
+	})
+	if err != nil && !errors.Is(err, ErrAuthTokenInvalid) {
+		r.logger.Error("ResetPassword failed", zap.Error(err))
+	}
+	return err
+}
@@ -0,0 +1,193 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"hexlet/internal/domain"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"go.uber.org/zap"
+)
+
+const notificationSettingsColumns = "email, telegram, webhook, kinds, delivery, batch_minutes, digest_hour"
+
+func (r *Repository) GetNotificationSettings(ctx context.Context, ID_user string) (domain.NotificationSettings, error) {
+	var s domain.NotificationSettings
+	err := r.SlavePool.QueryRow(ctx, "SELECT "+notificationSettingsColumns+" FROM notification_settings WHERE user_id = $1", ID_user).
+		Scan(&s.Email, &s.Telegram, &s.Webhook, &s.Kinds, &s.Delivery, &s.Batch_minutes, &s.Digest_hour)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.DefaultNotificationSettings(), nil
+	}
+	if err != nil {
+		r.logger.Error("GetNotificationSettings failed",
+			zap.Error(err),
+			zap.String("user_id", ID_user),
+		)
+		return domain.NotificationSettings{}, err
+	}
+	return s, nil
+}
+
+func (r *Repository) UpdateNotificationSettings(ctx context.Context, ID_user string, s domain.NotificationSettings) (domain.NotificationSettings, error) {
+	if s.Kinds == nil {
+		s.Kinds = []string{}
+	}
+	_, err := r.MasterPool.Exec(ctx, `
+		INSERT INTO notification_settings (user_id, `+notificationSettingsColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (user_id) DO UPDATE
+		SET email = EXCLUDED.email, telegram = EXCLUDED.telegram, webhook = EXCLUDED.webhook, kinds = EXCLUDED.kinds,
+			delivery = EXCLUDED.delivery, batch_minutes = EXCLUDED.batch_minutes, digest_hour = EXCLUDED.digest_hour,
+			updated_at = NOW()`,
+		ID_user, s.Email, s.Telegram, s.Webhook, s.Kinds, s.Delivery, s.Batch_minutes, s.Digest_hour,
+	)
+	if err != nil {
+		r.logger.Error("UpdateNotificationSettings failed",
+			zap.Error(err),
+			zap.String("user_id", ID_user),
+		)
+		return domain.NotificationSettings{}, err
+	}
+	return s, nil
+}
+
+// GetPendingNotifications — неразосланные уведомления не более чем users пользователей,
+// которым пора рассылать (срок считается как в service.dueAt), вместе с их настройками
+// и адресом email. Первыми идут пользователи с самыми старыми уведомлениями, поэтому ждущие
+// сводку не вытесняют остальных. Email локальной учётной записи используется только
+// после подтверждения.
+func (r *Repository) GetPendingNotifications(ctx context.Context, users int) ([]domain.NotificationBatch, error) {
+	defaults := domain.DefaultNotificationSettings()
+	rows, err := r.MasterPool.Query(ctx, `
+		WITH pending AS (
+			SELECT user_id, MIN(created_at) AS oldest
+			FROM notifications
+			WHERE dispatched_at IS NULL
+			GROUP BY user_id
+		), due AS (
+			SELECT p.user_id, p.oldest
+			FROM pending p
+			JOIN users u ON u.id = p.user_id
+			LEFT JOIN notification_settings s ON s.user_id = p.user_id
+			CROSS JOIN LATERAL (SELECT p.oldest AT TIME ZONE u.timezone AS oldest) l
+			CROSS JOIN LATERAL (SELECT date_trunc('day', l.oldest) + make_interval(hours => COALESCE(s.digest_hour, $4)) AS digest) d
+			WHERE CASE COALESCE(s.delivery, $2)
+				WHEN 'hourly' THEN (date_trunc('hour', l.oldest) + INTERVAL '1 hour') AT TIME ZONE u.timezone
+				WHEN 'daily' THEN (CASE WHEN d.digest < l.oldest THEN d.digest + INTERVAL '1 day' ELSE d.digest END) AT TIME ZONE u.timezone
+				ELSE p.oldest + make_interval(mins => COALESCE(s.batch_minutes, $3))
+			END <= NOW()
+			ORDER BY p.oldest
+			LIMIT $1
+		)
+		SELECT n.id, n.user_id, n.kind, n.message, n.platform_id, n.workspace_id, n.post_id, n.destination_id, n.created_at,
+			CASE WHEN u.password_hash IS NULL OR u.email_verified_at IS NOT NULL THEN u.email END,
+			u.timezone, s.user_id IS NOT NULL,
+			s.email, s.telegram, s.webhook, s.kinds, s.delivery, s.batch_minutes, s.digest_hour
+		FROM notifications n
+		JOIN users u ON u.id = n.user_id
+		LEFT JOIN notification_settings s ON s.user_id = n.user_id
+		JOIN due ON due.user_id = n.user_id
+		WHERE n.dispatched_at IS NULL
+		ORDER BY due.oldest, n.user_id, n.created_at, n.id`,
+		users, defaults.Delivery, defaults.Batch_minutes, defaults.Digest_hour,
+	)
+	if err != nil {
+		r.logger.Error("GetPendingNotifications failed", zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+	res := []domain.NotificationBatch{}
+	for rows.Next() {
+		var n domain.Notification
+		var b domain.NotificationBatch
+		var hasSettings bool
+		var email, telegram, webhook *bool
+		var kinds []string
+		var delivery *string
+		var batchMinutes, digestHour *int
+		err := rows.Scan(&n.ID_notification, &n.ID_user, &n.Kind, &n.Message, &n.ID_platform, &n.ID_workspace, &n.ID_post, &n.ID_destination, &n.Created_at,
+			&b.Email, &b.Timezone, &hasSettings,
+			&email, &telegram, &webhook, &kinds, &delivery, &batchMinutes, &digestHour)
+		if err != nil {
+			r.logger.Error("GetPendingNotifications failed in scaning", zap.Error(err))
+			return nil, err
+		}
+		if len(res) > 0 && res[len(res)-1].ID_user == n.ID_user {
+			last := &res[len(res)-1]
+			last.Items = append(last.Items, n)
+			continue
+		}
+		b.ID_user = n.ID_user
+		b.Settings = domain.DefaultNotificationSettings()
+		if hasSettings {
+			b.Settings = domain.NotificationSettings{
+				Email:         *email,
+				Telegram:      *telegram,
+				Webhook:       *webhook,
+				Kinds:         kinds,
+				Delivery:      *delivery,
+				Batch_minutes: *batchMinutes,
+				Digest_hour:   *digestHour,
+			}
+		}
+		b.Items = []domain.Notification{n}
+		res = append(res, b)
+	}
+	return res, rows.Err()
+}
+
+// ClaimNotifications отмечает уведомления разосланными и возвращает те,
+// что не успел забрать другой экземпляр
+func (r *Repository) ClaimNotifications(ctx context.Context, ID_user string, ids []int) ([]int, error) {
+	rows, err := r.MasterPool.Query(ctx, `
+		UPDATE notifications
+		SET dispatched_at = NOW()
+		WHERE user_id = $1 AND id = ANY($2) AND dispatched_at IS NULL
+		RETURNING id`,
+		ID_user, ids,
+	)
+	if err != nil {
+		r.logger.Error("ClaimNotifications failed",
+			zap.Error(err),
+			zap.String("user_id", ID_user),
+		)
+		return nil, err
+	}
+	defer rows.Close()
+	claimed := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		claimed = append(claimed, id)
+	}
+	return claimed, rows.Err()
+}
+
+// NotifyStuckDestinations создаёт уведомления о публикациях, которые дольше after
+// остаются в processing. О каждом зависании автор узнаёт один раз.
+func (r *Repository) NotifyStuckDestinations(ctx context.Context, after time.Duration) (int, error) {
+	tag, err := r.MasterPool.Exec(ctx, `
+		INSERT INTO notifications (user_id, kind, message, platform_id, workspace_id, post_id, destination_id)
+		SELECT p.user_id, 'publication_stuck',
+			format('%s publication of post %s "%s" is processing for more than %s minutes', pl.platform_name, p.id, p.title, $1::INTEGER),
+			d.platform_id, d.workspace_id, d.post_id, d.id
+		FROM post_destinations d
+		JOIN posts p ON p.id = d.post_id
+		JOIN platforms pl ON pl.id = d.platform_id
+		WHERE d.status = 'processing'
+		AND d.processing_started_at < NOW() - make_interval(mins => $1::INTEGER)
+		AND NOT EXISTS (
+			SELECT 1 FROM notifications n
+			WHERE n.destination_id = d.id AND n.kind = 'publication_stuck' AND n.created_at >= d.processing_started_at
+		)`,
+		int(after/time.Minute),
+	)
+	if err != nil {
+		r.logger.Error("NotifyStuckDestinations failed", zap.Error(err))
+		return 0, err
+	}
+	return int(tag.RowsAffected()), nil
+}
@@ -0,0 +1,155 @@
+package repository
+
+import (
+	"context"
+	"hexlet/internal/domain"
+	"hexlet/internal/dto"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"go.uber.org/zap"
+)
+
+// PausePlatforms ставит на паузу платформу ID_platform или, если ID_platform = 0,
+// все платформы пространства. Запланированные публикации откладываются (held).
+func (r *Repository) PausePlatforms(ctx context.Context, ID_workspace int, ID_platform int) (dto.PauseResponce, error) {
+	res := dto.PauseResponce{Platforms: []int{}}
+	err := r.MasterPool.BeginFunc(ctx, func(tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, `
+			UPDATE platforms
+			SET is_active = false, paused_at = NOW()
+			WHERE workspace_id = $1 AND ($2::int = 0 OR id = $2) AND paused_at IS NULL
+			RETURNING id`,
+			ID_workspace, ID_platform,
+		)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var id int
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return err
+			}
+			res.Platforms = append(res.Platforms, id)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		tag, err := tx.Exec(ctx, `
+			UPDATE post_destinations
+			SET status = 'held'
+			WHERE platform_id = ANY($1) AND status = 'scheduled'`,
+			res.Platforms,
+		)
+		if err != nil {
+			return err
+		}
+		res.Held = tag.RowsAffected()
+		return nil
+	})
+	if err != nil {
+		r.logger.Error("PausePlatforms failed",
+			zap.Error(err),
+			zap.Int("platform_id", ID_platform),
+			zap.Int("workspace_id", ID_workspace),
+		)
+		return dto.PauseResponce{}, err
+	}
+	return res, nil
+}
+
+// ResumePlatforms снимает паузу с платформы ID_platform или со всех платформ пространства.
+// Просроченные за время паузы публикации обрабатываются согласно overdue.
+// Платформа, отключённая монитором из-за ошибок авторизации, остаётся неактивной.
+func (r *Repository) ResumePlatforms(ctx context.Context, ID_workspace int, ID_platform int, overdue string) (dto.ResumeResponce, error) {
+	res := dto.ResumeResponce{Platforms: []int{}, Overdue: overdue}
+	err := r.MasterPool.BeginFunc(ctx, func(tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, `
+			UPDATE platforms p
+			SET paused_at = NULL, is_active = p.health_status <> 'inactive'
+			FROM (SELECT id, paused_at FROM platforms WHERE workspace_id = $1 AND ($2::int = 0 OR id = $2) AND paused_at IS NOT NULL FOR UPDATE) old
+			WHERE p.id = old.id
+			RETURNING p.id, old.paused_at, p.is_active`,
+			ID_workspace, ID_platform,
+		)
+		if err != nil {
+			return err
+		}
+		pausedAt := map[int]time.Time{}
+		for rows.Next() {
+			var id int
+			var at time.Time
+			var active bool
+			if err := rows.Scan(&id, &at, &active); err != nil {
+				rows.Close()
+				return err
+			}
+			res.Platforms = append(res.Platforms, id)
+			if active {
+				pausedAt[id] = at
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		for id, at := range pausedAt {
+			overdueHeld, err := r.applyOverdue(ctx, tx, id, at, overdue)
+			if err != nil {
+				return err
+			}
+			res.OverdueHeld += overdueHeld
+			tag, err := tx.Exec(ctx, `
+				UPDATE post_destinations
+				SET status = 'scheduled'
+				WHERE platform_id = $1 AND status = 'held'`,
+				id,
+			)
+			if err != nil {
+				return err
+			}
+			res.Released += tag.RowsAffected()
+		}
+		return nil
+	})
+	if err != nil {
+		r.logger.Error("ResumePlatforms failed",
+			zap.Error(err),
+			zap.Int("platform_id", ID_platform),
+			zap.Int("workspace_id", ID_workspace),
+		)
+		return dto.ResumeResponce{}, err
+	}
+	return res, nil
+}
+
+// applyOverdue обрабатывает отложенные публикации, время которых уже наступило
+func (r *Repository) applyOverdue(ctx context.Context, tx pgx.Tx, ID_platform int, pausedAt time.Time, overdue string) (int64, error) {
+	switch overdue {
+	case domain.ResumeShift:
+		tag, err := tx.Exec(ctx, `
+			UPDATE post_destinations
+			SET scheduled_for = GREATEST(scheduled_for + (NOW() - $2::timestamptz), NOW())
+			WHERE platform_id = $1 AND status = 'held' AND scheduled_for <= NOW()`,
+			ID_platform, pausedAt,
+		)
+		return tag.RowsAffected(), err
+	case domain.ResumeSkip:
+		tag, err := tx.Exec(ctx, `
+			UPDATE post_destinations
+			SET status = 'skipped', error_message = 'skipped: platform was paused'
+			WHERE platform_id = $1 AND status = 'held' AND scheduled_for <= NOW()`,
+			ID_platform,
+		)
+		return tag.RowsAffected(), err
+	}
+	var count int64
+	err := tx.QueryRow(ctx, `
+		SELECT count(*) FROM post_destinations
+		WHERE platform_id = $1 AND status = 'held' AND scheduled_for <= NOW()`,
+		ID_platform,
+	).Scan(&count)
+	return count, err
+}
@@ -3,37 +3,124 @@ package repository
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"hexlet/internal/domain"
 	"hexlet/internal/dto"
+	"hexlet/internal/secrets"
+	"hexlet/internal/tracing"
 	"time"
 
+	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
 	"go.uber.org/zap"
 )
 
 type PostRepository interface {
 	CreatePost(ctx context.Context, post dto.CreatePostRequest) (int, time.Time, error)
-	GetPost(ctx context.Context, ID_user string) (dto.GetPostsResponce, error)
-	GetPostByID(ctx context.Context, ID_post int, ID_user string) (dto.GetPostResponce, error)
-	DeletePostByID(ctx context.Context, ID_post int) error
+	CreatePosts(ctx context.Context, posts []dto.CreatePostRequest) ([]int, error)
+	GetPost(ctx context.Context, ID_workspace int, filter dto.PostFilter) (dto.GetPostsResponce, error)
+	ExportPosts(ctx context.Context, ID_workspace int, filter dto.PostFilter, fn func(domain.ExportRow) error) error
+	GetPostByID(ctx context.Context, ID_post int, ID_workspace int) (dto.GetPostResponce, error)
+	DeletePostByID(ctx context.Context, ID_post int, ID_workspace int) error
 	UpdatePostByID(ctx context.Context, req dto.PutPostRequest) (dto.PutPostResponce, error)
 
 	CreatePlatform(ctx context.Context, platform dto.CreatePlatformRequest) (int, time.Time, error)
-	GetPlatform(ctx context.Context, ID_user string) (dto.GetPlatformResponce, error)
-	GetPlatformByID(ctx context.Context, ID_platform int, ID_user string) (domain.Platform, error)
-	DeletePlatformByID(ctx context.Context, ID_platform int) error
+	GetPlatform(ctx context.Context, ID_workspace int) (dto.GetPlatformResponce, error)
+	GetPlatformByID(ctx context.Context, ID_platform int, ID_workspace int) (domain.Platform, error)
+	DeletePlatformByID(ctx context.Context, ID_platform int, ID_workspace int) error
 	UpdatePlatformByID(ctx context.Context, req dto.PutPlatformRequest) (dto.PutPlatformResponce, error)
+	UpdatePlatformVerification(ctx context.Context, ID_platform int, configVersion int, report domain.VerificationReport) error
+	PausePlatforms(ctx context.Context, ID_workspace int, ID_platform int) (dto.PauseResponce, error)
+	ResumePlatforms(ctx context.Context, ID_workspace int, ID_platform int, overdue string) (dto.ResumeResponce, error)
+
+	GetNotifications(ctx context.Context, ID_user string) ([]domain.Notification, error)
+
+	FindOrCreateUser(ctx context.Context, identity domain.Identity) (domain.User, error)
+	GetUserByID(ctx context.Context, ID_user string) (domain.User, error)
+	UpdateUser(ctx context.Context, req dto.PatchMeRequest) (domain.User, error)
+	LinkIdentity(ctx context.Context, ID_user string, identity domain.Identity) error
+	GetIdentities(ctx context.Context, ID_user string) ([]domain.UserIdentity, error)
+	UnlinkIdentity(ctx context.Context, ID_user string, provider string) (bool, error)
+
+	CreateLocalUser(ctx context.Context, email string, name string, passwordHash string) (domain.User, error)
+	GetLocalCredentials(ctx context.Context, email string) (domain.LocalCredentials, error)
+	RecordLoginFailure(ctx context.Context, ID_user string, maxFailures int, lockFor time.Duration) (*time.Time, error)
+	ResetLoginFailures(ctx context.Context, ID_user string) error
+	CreateAuthToken(ctx context.Context, ID_user string, purpose string, tokenHash string, expiresAt time.Time) error
+	VerifyEmail(ctx context.Context, tokenHash string) error
+	UseAuthToken(ctx context.Context, purpose string, tokenHash string) (string, error)
+	ResetPassword(ctx context.Context, tokenHash string, passwordHash string) error
+
+	CreateSession(ctx context.Context, s domain.Session, token domain.RefreshToken) error
+	RotateRefreshToken(ctx context.Context, ID_session string, ID_user string, old domain.RefreshToken, next domain.RefreshToken) (int, error)
+	SetSessionWorkspace(ctx context.Context, ID_session string, ID_user string, ID_workspace int) error
+	GetSessions(ctx context.Context, ID_user string) ([]domain.Session, error)
+	IsSessionActive(ctx context.Context, ID_session string, ID_user string) (bool, error)
+	RevokeSession(ctx context.Context, ID_session string, ID_user string) (bool, error)
+
+	CreateApiKey(ctx context.Context, ID_key string, prefix string, keyHash string, req dto.CreateApiKeyRequest) (domain.ApiKey, error)
+	GetApiKeys(ctx context.Context, ID_user string) ([]domain.ApiKey, error)
+	RevokeApiKey(ctx context.Context, ID_key string, ID_user string) (bool, error)
+	UseApiKey(ctx context.Context, keyHash string) (domain.ApiKey, error)
+
+	CreateWorkspace(ctx context.Context, name string, ID_user string) (domain.Workspace, error)
+	GetWorkspaces(ctx context.Context, ID_user string) ([]domain.Workspace, error)
+	GetDefaultWorkspace(ctx context.Context, ID_user string) (int, error)
+	GetMemberRole(ctx context.Context, ID_workspace int, ID_user string) (string, error)
+	GetMembers(ctx context.Context, ID_workspace int) ([]domain.WorkspaceMember, error)
+	UpdateMemberRole(ctx context.Context, ID_workspace int, ID_user string, role string) (bool, error)
+	RemoveMember(ctx context.Context, ID_workspace int, ID_user string) (bool, error)
+	CreateInvitation(ctx context.Context, inv domain.Invitation, tokenHash string) error
+	AcceptInvitation(ctx context.Context, tokenHash string, ID_user string) (domain.Workspace, error)
+
+	SubmitPost(ctx context.Context, ID_post int, ID_workspace int, ID_user string) (string, error)
+	ReviewPost(ctx context.Context, ID_workspace int, review domain.PostReview) (string, error)
+	GetPostReviews(ctx context.Context, ID_post int, ID_workspace int) ([]domain.PostReview, error)
+	UpdateWorkspaceApprovals(ctx context.Context, ID_workspace int, required int) error
+	UpdatePlatformApprovals(ctx context.Context, ID_platform int, ID_workspace int, required *int) (bool, error)
+
+	CreateTemplate(ctx context.Context, req dto.CreateTemplateRequest) (domain.PostTemplate, error)
+	GetTemplates(ctx context.Context, ID_workspace int) ([]domain.PostTemplate, error)
+	GetTemplateByID(ctx context.Context, ID_template int, ID_workspace int) (domain.PostTemplate, error)
+	UpdateTemplate(ctx context.Context, req dto.PutTemplateRequest) (domain.PostTemplate, error)
+	DeleteTemplate(ctx context.Context, ID_template int, ID_workspace int) (bool, error)
+	GetPostVariables(ctx context.Context, ID_post int, ID_workspace int) (*domain.PostVariables, error)
+
+	GetPostRevisions(ctx context.Context, ID_post int, ID_workspace int) ([]domain.PostRevision, error)
+	GetPostRevision(ctx context.Context, ID_post int, ID_workspace int, revision int) (domain.PostRevision, error)
+	RestorePostRevision(ctx context.Context, ID_post int, ID_workspace int, revision int, ID_user string) (int, error)
+
+	CreateImportJob(ctx context.Context, ID_workspace int, job domain.ImportJob) error
+	UpdateImportJob(ctx context.Context, job domain.ImportJob) error
+	GetImportJob(ctx context.Context, ID_job string, ID_workspace int) (domain.ImportJob, error)
+
+	CreateCalendarToken(ctx context.Context, ID_user string, tokenHash string) error
+	DeleteCalendarToken(ctx context.Context, ID_user string) (bool, error)
+	GetCalendarEvents(ctx context.Context, tokenHash string) ([]domain.CalendarEvent, error)
+
+	CreateWebhook(ctx context.Context, ID_workspace int, hook domain.Webhook) (domain.Webhook, error)
+	GetWebhooks(ctx context.Context, ID_workspace int) ([]domain.Webhook, error)
+	UpdateWebhook(ctx context.Context, ID_webhook int, ID_workspace int, url *string, events []string, isActive *bool) (domain.Webhook, error)
+	DeleteWebhook(ctx context.Context, ID_webhook int, ID_workspace int) (bool, error)
+	GetWebhookDeliveries(ctx context.Context, ID_webhook int, ID_workspace int) ([]domain.WebhookDelivery, error)
+	RedeliverWebhook(ctx context.Context, ID_webhook int, ID_delivery int, ID_workspace int) (domain.WebhookDelivery, error)
+	EnqueueWebhookEvent(ctx context.Context, event domain.WebhookEvent) (int, error)
+	UnlinkTelegram(ctx context.Context, ID_user string) (bool, error)
+	GetNotificationSettings(ctx context.Context, ID_user string) (domain.NotificationSettings, error)
+	UpdateNotificationSettings(ctx context.Context, ID_user string, s domain.NotificationSettings) (domain.NotificationSettings, error)
 }
 type Repository struct {
 	MasterPool *pgxpool.Pool
 	SlavePool  *pgxpool.Pool
+	keyring    *secrets.Keyring
 	logger     *zap.Logger
 }
 
-func NewRepository(masterpool *pgxpool.Pool, slavepool *pgxpool.Pool, logger *zap.Logger) *Repository {
+func NewRepository(masterpool *pgxpool.Pool, slavepool *pgxpool.Pool, keyring *secrets.Keyring, logger *zap.Logger) *Repository {
 	return &Repository{
 		MasterPool: masterpool,
 		SlavePool:  slavepool,
+		keyring:    keyring,
 		logger:     logger,
 	}
 }
@@ -60,13 +147,13 @@ CREATE TABLE post_destinations (
 );
 
 */
-func (r *Repository) GetPostByID(ctx context.Context, ID_post int, ID_user string) (dto.GetPostResponce, error) {
-	rows, err := r.SlavePool.Query(ctx, "SELECT user_id, platform_id, scheduled_for, status, error_message FROM post_destinations WHERE post_id=$1 AND user_id=$2 ", ID_post, ID_user)
+func (r *Repository) GetPostByID(ctx context.Context, ID_post int, ID_workspace int) (dto.GetPostResponce, error) {
+	rows, err := r.SlavePool.Query(ctx, "SELECT user_id, platform_id, scheduled_for, status, error_message, revision FROM post_destinations WHERE post_id=$1 AND workspace_id=$2 ", ID_post, ID_workspace)
 	if err != nil {
 		r.logger.Error("GetPostByID failed in selecting from post_destinations",
 			zap.Error(err),
 			zap.Int("post_id", ID_post),
-			zap.String("user_id", ID_user),
+			zap.Int("workspace_id", ID_workspace),
 		)
 		return dto.GetPostResponce{}, err
 	}
@@ -76,22 +163,23 @@ func (r *Repository) GetPostByID(ctx context.Context, ID_post int, ID_user strin
 	for rows.Next() {
 		p1 := domain.Post{}
 		p1.ID_post = ID_post
-		err := rows.Scan(&p1.ID_user, &p1.ID_platform, &p1.Sheduled_for, &p1.Status, &p1.ErrorMessage)
+		var sheduledFor *time.Time
+		err := rows.Scan(&p1.ID_user, &p1.ID_platform, &sheduledFor, &p1.Status, &p1.ErrorMessage, &p1.Published_revision)
 		if err != nil {
 			r.logger.Error("GetPostByID failed in scaning",
 				zap.Error(err),
 				zap.Int("post_id", ID_post),
-				zap.String("user_id", ID_user),
+				zap.Int("workspace_id", ID_workspace),
 			)
 			return res, err
 		}
-		err = r.SlavePool.QueryRow(ctx, "SELECT title, content, created_at FROM posts WHERE id=$1", p1.ID_post).Scan(
-			&p1.Title, &p1.Content, &p1.Created_at)
+		err = r.SlavePool.QueryRow(ctx, "SELECT title, content, status, revision, created_at FROM posts WHERE id=$1", p1.ID_post).Scan(
+			&p1.Title, &p1.Content, &p1.Review_status, &p1.Revision, &p1.Created_at)
 		if err != nil {
 			r.logger.Error("GetPostByID failed in selecting from posts",
 				zap.Error(err),
 				zap.Int("post_id", ID_post),
-				zap.String("user_id", ID_user),
+				zap.Int("workspace_id", ID_workspace),
 			)
 			return res, err
 		}
@@ -101,43 +189,58 @@ func (r *Repository) GetPostByID(ctx context.Context, ID_post int, ID_user strin
 			r.logger.Error("GetPostByID failed in selecting from platforms",
 				zap.Error(err),
 				zap.Int("post_id", ID_post),
-				zap.String("user_id", ID_user),
+				zap.Int("workspace_id", ID_workspace),
 			)
 			return res, err
 		}
-		p1.Sheduled_for = p1.Sheduled_for.Add(3 * time.Hour)
-		p1.Created_at = p1.Created_at.Add(3 * time.Hour)
+		// у черновика время публикации может быть не задано
+		if sheduledFor != nil {
+			p1.Sheduled_for = ToAPITime(*sheduledFor)
+		}
+		p1.Created_at = ToAPITime(p1.Created_at)
 		res.Posts = append(res.Posts, p1)
 	}
 	return res, nil
 }
 
-func (r *Repository) DeletePostByID(ctx context.Context, ID_post int) error {
-	_, err := r.MasterPool.Exec(ctx, "DELETE FROM posts WHERE id=$1", ID_post)
+func (r *Repository) DeletePostByID(ctx context.Context, ID_post int, ID_workspace int) error {
+	_, err := r.MasterPool.Exec(ctx, "DELETE FROM posts WHERE id=$1 AND workspace_id=$2", ID_post, ID_workspace)
 	if err != nil {
 		r.logger.Error("DeletePostByID failed in deleting in posts",
 			zap.Error(err),
 			zap.Int("post_id", ID_post),
+			zap.Int("workspace_id", ID_workspace),
 		)
 		return err
 	}
-	_, err = r.MasterPool.Exec(ctx, "DELETE FROM post_destinations WHERE post_id=$1", ID_post)
+	_, err = r.MasterPool.Exec(ctx, "DELETE FROM post_destinations WHERE post_id=$1 AND workspace_id=$2", ID_post, ID_workspace)
 	if err != nil {
 		r.logger.Error("DeletePostByID failed in deleting in post_destinations",
 			zap.Error(err),
 			zap.Int("post_id", ID_post),
+			zap.Int("workspace_id", ID_workspace),
 		)
 		return err
 	}
 	return nil
 }
 
-func (r *Repository) GetPost(ctx context.Context, ID_user string) (dto.GetPostsResponce, error) {
-	rows, err := r.SlavePool.Query(ctx, "SELECT post_id, platform_id, scheduled_for, status, error_message FROM post_destinations WHERE user_id=$1", ID_user)
+func (r *Repository) GetPost(ctx context.Context, ID_workspace int, filter dto.PostFilter) (dto.GetPostsResponce, error) {
+	where, args := postFilter(filter, []any{ID_workspace})
+	rows, err := r.SlavePool.Query(ctx, `
+		SELECT d.post_id, d.user_id, d.platform_id, d.scheduled_for, d.status, d.error_message, d.revision, d.remote_id,
+			p.title, p.content, p.status, p.revision, p.created_at, pl.platform_name
+		FROM post_destinations d
+		JOIN posts p ON p.id = d.post_id
+		JOIN platforms pl ON pl.id = d.platform_id
+		WHERE d.workspace_id = $1`+where+`
+		ORDER BY d.id`,
+		args...,
+	)
 	if err != nil {
 		r.logger.Error("GetPost failed in selecting from post_destinations",
 			zap.Error(err),
-			zap.String("user_id", ID_user),
+			zap.Int("workspace_id", ID_workspace),
 		)
 		return dto.GetPostsResponce{}, err
 	}
@@ -147,40 +250,27 @@ func (r *Repository) GetPost(ctx context.Context, ID_user string) (dto.GetPostsR
 	res.Scheduled = []domain.Post{}
 	res.Published = []domain.Post{}
 	res.Failed = []domain.Post{}
+	res.Held = []domain.Post{}
+	res.Skipped = []domain.Post{}
+	res.Draft = []domain.Post{}
 	for rows.Next() {
 		p1 := domain.Post{}
-		p1.ID_user = ID_user
-		err := rows.Scan(&p1.ID_post, &p1.ID_platform, &p1.Sheduled_for, &p1.Status, &p1.ErrorMessage)
+		var sheduledFor *time.Time
+		err := rows.Scan(&p1.ID_post, &p1.ID_user, &p1.ID_platform, &sheduledFor, &p1.Status, &p1.ErrorMessage, &p1.Published_revision, &p1.Remote_id,
+			&p1.Title, &p1.Content, &p1.Review_status, &p1.Revision, &p1.Created_at, &p1.PlatformName)
 		if err != nil {
 			r.logger.Error("GetPost failed in scaning",
 				zap.Error(err),
 				zap.Int("post_id", p1.ID_post),
-				zap.String("user_id", ID_user),
+				zap.Int("workspace_id", ID_workspace),
 			)
 			return dto.GetPostsResponce{}, err
 		}
-		err = r.SlavePool.QueryRow(ctx, "SELECT title, content, created_at FROM posts WHERE id=$1", p1.ID_post).Scan(
-			&p1.Title, &p1.Content, &p1.Created_at)
-		if err != nil {
-			r.logger.Error("GetPost failed in selecting from posts",
-				zap.Error(err),
-				zap.Int("post_id", p1.ID_post),
-				zap.String("user_id", ID_user),
-			)
-			return dto.GetPostsResponce{}, err
+		// у черновика время публикации может быть не задано
+		if sheduledFor != nil {
+			p1.Sheduled_for = ToAPITime(*sheduledFor)
 		}
-		err = r.SlavePool.QueryRow(ctx, "SELECT platform_name FROM platforms WHERE id=$1", p1.ID_platform).Scan(
-			&p1.PlatformName)
-		if err != nil {
-			r.logger.Error("GetPost failed in selecting from platforms",
-				zap.Error(err),
-				zap.Int("platform_id", p1.ID_platform),
-				zap.String("user_id", ID_user),
-			)
-			return dto.GetPostsResponce{}, err
-		}
-		p1.Sheduled_for = p1.Sheduled_for.Add(3 * time.Hour)
-		p1.Created_at = p1.Created_at.Add(3 * time.Hour)
+		p1.Created_at = ToAPITime(p1.Created_at)
 		switch p1.Status {
 		case "processing":
 			res.Processing = append(res.Processing, p1)
@@ -188,88 +278,220 @@ func (r *Repository) GetPost(ctx context.Context, ID_user string) (dto.GetPostsR
 			res.Scheduled = append(res.Scheduled, p1)
 		case "published":
 			res.Published = append(res.Published, p1)
+		case "held":
+			res.Held = append(res.Held, p1)
+		case "skipped":
+			res.Skipped = append(res.Skipped, p1)
+		case "draft":
+			res.Draft = append(res.Draft, p1)
 		default:
 			res.Failed = append(res.Failed, p1)
 		}
 	}
-	return res, nil
+	return res, rows.Err()
+}
+
+// UpdatePostByID сохраняет изменения новой ревизией.
+// Правка текста уже отправленного поста — повторная отправка на проверку,
+// прежние одобрения не засчитываются. Перенос времени одобрение не сбрасывает.
+// apiOffset — API принимает и отдаёт время по Москве, в базе хранится UTC
+const apiOffset = 3 * time.Hour
+
+// ToAPITime переводит время из базы во время API
+func ToAPITime(t time.Time) time.Time {
+	return t.Add(apiOffset)
+}
+
+// FromAPITime переводит время из запроса во время базы; нулевое время не меняется
+func FromAPITime(t time.Time) time.Time {
+	if t.IsZero() {
+		return t
+	}
+	return t.Add(-apiOffset)
+}
+
+// toAPITimePtr — ToAPITime для необязательного времени
+func toAPITimePtr(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	res := ToAPITime(*t)
+	return &res
 }
 
 func (r *Repository) UpdatePostByID(ctx context.Context, req dto.PutPostRequest) (dto.PutPostResponce, error) {
-	_, err := r.MasterPool.Exec(ctx, `
-		UPDATE posts 
-		SET title = $1, content = $2 
-		WHERE id = $3 AND user_id = $4`,
-		req.Title, req.Content, req.ID_post, req.ID_user,
-	)
+	err := r.MasterPool.BeginFunc(ctx, func(tx pgx.Tx) error {
+		return updatePost(ctx, tx, req)
+	})
 	if err != nil {
-		r.logger.Error("UpdatePostByID failed in updating in posts",
-			zap.Error(err),
-			zap.String("user_id", req.ID_user),
-			zap.Int("post_id", req.ID_post),
-		)
+		if !errors.Is(err, ErrPostNotFound) {
+			r.logger.Error("UpdatePostByID failed",
+				zap.Error(err),
+				zap.String("user_id", req.ID_user),
+				zap.Int("post_id", req.ID_post),
+			)
+		}
 		return dto.PutPostResponce{}, err
 	}
-	_, err = r.MasterPool.Exec(ctx, `
-		UPDATE post_destinations
-		SET scheduled_for = $1
-		WHERE id = $2 AND user_id = $3`,
-		req.Sheduled_for, req.ID_post, req.ID_user,
+	id_post := req.ID_post
+	return dto.PutPostResponce{ID_post: id_post, ID_user: req.ID_user, Updated_at: time.Now()}, nil
+}
+
+func updatePost(ctx context.Context, tx pgx.Tx, req dto.PutPostRequest) error {
+	var status, title, content string
+	var sheduledFor *time.Time
+	err := tx.QueryRow(ctx, `
+		SELECT status, title, content,
+			(SELECT MIN(scheduled_for) FROM post_destinations WHERE post_id = posts.id)
+		FROM posts WHERE id = $1 AND workspace_id = $2 FOR UPDATE`,
+		req.ID_post, req.ID_workspace,
+	).Scan(&status, &title, &content, &sheduledFor)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrPostNotFound
+	}
+	if err != nil {
+		return err
+	}
+	changed := []string{}
+	if title != req.Title {
+		changed = append(changed, domain.FieldTitle)
+	}
+	if content != req.Content {
+		changed = append(changed, domain.FieldContent)
+	}
+	next := nullTime(req.Sheduled_for)
+	moved := !sameTime(sheduledFor, next)
+	if moved {
+		changed = append(changed, domain.FieldSheduledFor)
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+	_, err = tx.Exec(ctx, `
+		UPDATE posts 
+		SET title = $1, content = $2 
+		WHERE id = $3 AND workspace_id = $4`,
+		req.Title, req.Content, req.ID_post, req.ID_workspace,
 	)
 	if err != nil {
-		r.logger.Error("UpdatePostByID failed in updating in post_destinations",
-			zap.Error(err),
-			zap.String("user_id", req.ID_user),
-			zap.Int("post_id", req.ID_post),
+		return err
+	}
+	if moved {
+		// отправленные и снятые публикации хранят своё время
+		_, err = tx.Exec(ctx, `
+			UPDATE post_destinations
+			SET scheduled_for = $1
+			WHERE post_id = $2 AND workspace_id = $3 AND status IN ('draft', 'scheduled', 'held')`,
+			next, req.ID_post, req.ID_workspace,
 		)
-		return dto.PutPostResponce{}, err
+		if err != nil {
+			return err
+		}
 	}
-	id_post := req.ID_post
-	return dto.PutPostResponce{ID_post: id_post, ID_user: req.ID_user, Updated_at: time.Now()}, nil
+	if _, err := createRevision(ctx, tx, req.ID_post, req.ID_user, req.Title, req.Content, next, changed); err != nil {
+		return err
+	}
+	if status == domain.PostDraft {
+		return nil
+	}
+	if title != req.Title || content != req.Content {
+		_, err = submitPost(ctx, tx, req.ID_post, req.ID_user)
+		return err
+	}
+	if status == domain.PostApproved {
+		_, err = advancePost(ctx, tx, req.ID_post)
+	}
+	return err
 }
+
+// CreatePost создаёт пост с направлениями на все платформы пространства.
+// Направления ждут в статусе draft, пока пост не будет одобрен.
 func (r *Repository) CreatePost(ctx context.Context, post dto.CreatePostRequest) (int, time.Time, error) {
 	var ID int
 	var createdAt time.Time
-	err := r.MasterPool.QueryRow(ctx, `INSERT INTO posts (user_id, title, content) VALUES ($1, $2, $3) RETURNING id, created_at;`,
-		post.ID_user, post.Title, post.Content).Scan(&ID, &createdAt)
+	err := r.MasterPool.BeginFunc(ctx, func(tx pgx.Tx) error {
+		var err error
+		ID, createdAt, err = createPost(ctx, tx, post)
+		return err
+	})
 	if err != nil {
-		r.logger.Error("CreatePost failed in inserting to posts",
+		r.logger.Error("CreatePost failed",
 			zap.Error(err),
 			zap.String("user_id", post.ID_user),
 		)
-		return ID, createdAt, err
+		return 0, createdAt, err
 	}
-	platforms_ids, err := r.SlavePool.Query(ctx, "SELECT id FROM platforms WHERE user_id=$1", post.ID_user)
+	return ID, createdAt, nil
+}
+
+// CreatePosts создаёт посты одной транзакцией: при ошибке не создаётся ни один
+func (r *Repository) CreatePosts(ctx context.Context, posts []dto.CreatePostRequest) ([]int, error) {
+	ids := make([]int, 0, len(posts))
+	err := r.MasterPool.BeginFunc(ctx, func(tx pgx.Tx) error {
+		for _, post := range posts {
+			ID, _, err := createPost(ctx, tx, post)
+			if err != nil {
+				return err
+			}
+			ids = append(ids, ID)
+		}
+		return nil
+	})
 	if err != nil {
-		r.logger.Error("CreatePost failed in selecting",
+		r.logger.Error("CreatePosts failed",
 			zap.Error(err),
-			zap.String("user_id", post.ID_user),
+			zap.Int("posts", len(posts)),
 		)
-		return ID, createdAt, err
+		return nil, err
 	}
-	defer platforms_ids.Close()
-	var platform_id int
-	for platforms_ids.Next() {
-		err := platforms_ids.Scan(&platform_id)
-		if err != nil {
-			r.logger.Error("CreatePost failed in scaning",
-				zap.Error(err),
-				zap.String("user_id", post.ID_user),
-			)
-			return 0, createdAt, err
-		}
-		_, err1 := r.MasterPool.Exec(ctx,
-			`INSERT INTO post_destinations (user_id, post_id, platform_id,status, scheduled_for) VALUES ($1, $2, $3, $4,$5)`,
-			post.ID_user, ID, platform_id, "scheduled", post.Sheduled_for)
-		if err1 != nil {
-			r.logger.Error("CreatePost failed in inserting to post_destinations",
-				zap.Error(err1),
-				zap.String("user_id", post.ID_user),
-			)
-			return ID, createdAt, err1
-		}
+	return ids, nil
+}
+
+func createPost(ctx context.Context, tx pgx.Tx, post dto.CreatePostRequest) (int, time.Time, error) {
+	var ID int
+	var createdAt time.Time
+	err := tx.QueryRow(ctx, `INSERT INTO posts (user_id, workspace_id, title, content, status, template_id, variables, trace_parent) VALUES ($1, $2, $3, $4, $5, NULLIF($6, 0), $7, $8) RETURNING id, created_at;`,
+		post.ID_user, post.ID_workspace, post.Title, post.Content, domain.PostDraft, post.ID_template, post.Variables, tracing.TraceParent(ctx)).Scan(&ID, &createdAt)
+	if err != nil {
+		return 0, createdAt, err
 	}
-	return ID, createdAt, nil
+	// без списка платформ пост уходит на все платформы пространства
+	_, err = tx.Exec(ctx, `
+		INSERT INTO post_destinations (user_id, workspace_id, post_id, platform_id, status, scheduled_for)
+		SELECT $1, $2, $3, id, 'draft', $4 FROM platforms
+		WHERE workspace_id = $2 AND ($5::INTEGER[] IS NULL OR id = ANY($5))`,
+		post.ID_user, post.ID_workspace, ID, nullTime(post.Sheduled_for), post.Platforms)
+	if err != nil {
+		return 0, createdAt, err
+	}
+	changed := []string{domain.FieldTitle, domain.FieldContent}
+	if !post.Sheduled_for.IsZero() {
+		changed = append(changed, domain.FieldSheduledFor)
+	}
+	_, err = createRevision(ctx, tx, ID, post.ID_user, post.Title, post.Content, nullTime(post.Sheduled_for), changed)
+	if err != nil {
+		return 0, createdAt, err
+	}
+	if post.Status == domain.PostDraft {
+		return ID, createdAt, nil
+	}
+	_, err = submitPost(ctx, tx, ID, post.ID_user)
+	return ID, createdAt, err
+}
+
+// nullTime: нулевое время — не задано
+func nullTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
 }
 
 // --- PLATFORMS REPOSITORY METHODS ---
@@ -288,13 +510,24 @@ CREATE TABLE platforms (
 func (r *Repository) CreatePlatform(ctx context.Context, platform dto.CreatePlatformRequest) (int, time.Time, error) {
 	var ID int
 	var createdAt time.Time
-	APIConfig := make(map[string]interface{})
-	APIConfig[platform.Bot_name] = platform.Config
-	err := r.MasterPool.QueryRow(ctx, `
-        INSERT INTO platforms (user_id, platform_name, api_config, is_active) 
-        VALUES ($1, $2, $3, $4) 
+	APIConfig, err := r.encryptConfig(domain.PlatformConfig{
+		Version:  domain.PlatformConfigVersion,
+		Telegram: platform.Telegram,
+		VK:       platform.VK,
+	})
+	if err != nil {
+		r.logger.Error("CreatePlatform failed in encrypting",
+			zap.Error(err),
+			zap.String("user_id", platform.ID_user),
+		)
+		return ID, createdAt, err
+	}
+	err = r.MasterPool.QueryRow(ctx, `
+        INSERT INTO platforms (user_id, workspace_id, platform_name, api_config, is_active) 
+        VALUES ($1, $2, $3, $4, $5) 
         RETURNING id, created_at;`,
 		platform.ID_user,
+		platform.ID_workspace,
 		platform.PlatformName,
 		APIConfig,
 		true,
@@ -309,12 +542,12 @@ func (r *Repository) CreatePlatform(ctx context.Context, platform dto.CreatePlat
 	return ID, createdAt, nil
 }
 
-func (r *Repository) GetPlatform(ctx context.Context, ID_user string) (dto.GetPlatformResponce, error) {
-	rows, err := r.SlavePool.Query(ctx, "SELECT id, platform_name, api_config, is_active, created_at, updated_at FROM platforms WHERE user_id=$1", ID_user)
+func (r *Repository) GetPlatform(ctx context.Context, ID_workspace int) (dto.GetPlatformResponce, error) {
+	rows, err := r.SlavePool.Query(ctx, "SELECT id, platform_name, api_config, is_active, health_status, paused_at, verification, required_approvals, created_at, updated_at FROM platforms WHERE workspace_id=$1", ID_workspace)
 	if err != nil {
 		r.logger.Error("GetPlatform failed",
 			zap.Error(err),
-			zap.String("user_id", ID_user),
+			zap.Int("workspace_id", ID_workspace),
 		)
 		return dto.GetPlatformResponce{}, err
 	}
@@ -323,11 +556,20 @@ func (r *Repository) GetPlatform(ctx context.Context, ID_user string) (dto.GetPl
 	res.Platfroms = []domain.Platform{}
 	for rows.Next() {
 		p1 := domain.Platform{}
-		err := rows.Scan(&p1.ID_platform, &p1.Name, &p1.Api_config, &p1.Is_active, &p1.Created_at, &p1.Updated_at)
+		err := rows.Scan(&p1.ID_platform, &p1.Name, &p1.Api_config, &p1.Is_active, &p1.Health_status, &p1.Paused_at, &p1.Verification, &p1.Required_approvals, &p1.Created_at, &p1.Updated_at)
 		if err != nil {
 			r.logger.Error("GetPlatform failed in scaning",
 				zap.Error(err),
-				zap.String("user_id", ID_user),
+				zap.Int("workspace_id", ID_workspace),
+			)
+			return res, err
+		}
+		p1.Api_config, err = r.decryptConfig(p1.Api_config)
+		if err != nil {
+			r.logger.Error("GetPlatform failed in decrypting",
+				zap.Error(err),
+				zap.Int("platform_id", p1.ID_platform),
+				zap.Int("workspace_id", ID_workspace),
 			)
 			return res, err
 		}
@@ -336,35 +578,46 @@ func (r *Repository) GetPlatform(ctx context.Context, ID_user string) (dto.GetPl
 	return res, nil
 }
 
-func (r *Repository) GetPlatformByID(ctx context.Context, ID_platform int, ID_user string) (domain.Platform, error) {
+func (r *Repository) GetPlatformByID(ctx context.Context, ID_platform int, ID_workspace int) (domain.Platform, error) {
 	res := domain.Platform{}
-	err := r.SlavePool.QueryRow(ctx, "SELECT id, platform_name, api_config, is_active, created_at, updated_at FROM platforms WHERE user_id=$1 AND id=$2", ID_user, ID_platform).Scan(
-		&res.ID_platform, &res.Name, &res.Api_config, &res.Is_active, &res.Created_at, &res.Updated_at)
+	err := r.SlavePool.QueryRow(ctx, "SELECT id, platform_name, api_config, is_active, health_status, paused_at, verification, required_approvals, config_version, created_at, updated_at FROM platforms WHERE workspace_id=$1 AND id=$2", ID_workspace, ID_platform).Scan(
+		&res.ID_platform, &res.Name, &res.Api_config, &res.Is_active, &res.Health_status, &res.Paused_at, &res.Verification, &res.Required_approvals, &res.Config_version, &res.Created_at, &res.Updated_at)
 	if err != nil {
 		r.logger.Error("GetPlatformByID failed",
 			zap.Error(err),
 			zap.Int("platform_id", ID_platform),
-			zap.String("user_id", ID_user),
+			zap.Int("workspace_id", ID_workspace),
+		)
+		return domain.Platform{}, err
+	}
+	res.Api_config, err = r.decryptConfig(res.Api_config)
+	if err != nil {
+		r.logger.Error("GetPlatformByID failed in decrypting",
+			zap.Error(err),
+			zap.Int("platform_id", ID_platform),
+			zap.Int("workspace_id", ID_workspace),
 		)
 		return domain.Platform{}, err
 	}
 	return res, nil
 }
 
-func (r *Repository) DeletePlatformByID(ctx context.Context, ID_platform int) error {
-	_, err := r.MasterPool.Exec(ctx, "DELETE FROM platforms WHERE id=$1", ID_platform)
+func (r *Repository) DeletePlatformByID(ctx context.Context, ID_platform int, ID_workspace int) error {
+	_, err := r.MasterPool.Exec(ctx, "DELETE FROM platforms WHERE id=$1 AND workspace_id=$2", ID_platform, ID_workspace)
 	if err != nil {
 		r.logger.Error("DeletePlatformByID failed in deleting from platforms",
 			zap.Error(err),
 			zap.Int("platform_id", ID_platform),
+			zap.Int("workspace_id", ID_workspace),
 		)
 		return err
 	}
-	_, err = r.MasterPool.Exec(ctx, "DELETE FROM post_destinations WHERE platform_id=$1", ID_platform)
+	_, err = r.MasterPool.Exec(ctx, "DELETE FROM post_destinations WHERE platform_id=$1 AND workspace_id=$2", ID_platform, ID_workspace)
 	if err != nil {
 		r.logger.Error("DeletePlatformByID failed in deleting from post_destinations",
 			zap.Error(err),
 			zap.Int("platform_id", ID_platform),
+			zap.Int("workspace_id", ID_workspace),
 		)
 		return err
 	}
@@ -372,8 +625,15 @@ func (r *Repository) DeletePlatformByID(ctx context.Context, ID_platform int) er
 }
 
 func (r *Repository) UpdatePlatformByID(ctx context.Context, req dto.PutPlatformRequest) (dto.PutPlatformResponce, error) {
-	APIConfig := make(map[string]interface{})
-	APIConfig[req.Bot_name] = req.Config
+	APIConfig, err := r.encryptConfig(req.Config)
+	if err != nil {
+		r.logger.Error("UpdatePlatformByID failed in encrypting",
+			zap.Error(err),
+			zap.Int("platform_id", req.ID_platform),
+			zap.String("user_id", req.ID_user),
+		)
+		return dto.PutPlatformResponce{}, err
+	}
 	configBytes, err := json.Marshal(APIConfig)
 	if err != nil {
 		r.logger.Error("UpdatePlatformByID failed in marshal",
@@ -383,12 +643,14 @@ func (r *Repository) UpdatePlatformByID(ctx context.Context, req dto.PutPlatform
 		)
 		return dto.PutPlatformResponce{}, err
 	}
-	_, err = r.MasterPool.Exec(ctx, `
+	var configVersion int
+	err = r.MasterPool.QueryRow(ctx, `
 		UPDATE platforms
-		SET  api_config = $1
-		WHERE id = $2 AND user_id = $3`,
-		configBytes, req.ID_platform, req.ID_user,
-	)
+		SET  api_config = $1, config_version = config_version + 1
+		WHERE id = $2 AND workspace_id = $3
+		RETURNING config_version`,
+		configBytes, req.ID_platform, req.ID_workspace,
+	).Scan(&configVersion)
 	if err != nil {
 		r.logger.Error("UpdatePlatformByID failed in query",
 			zap.Error(err),
@@ -397,5 +659,29 @@ func (r *Repository) UpdatePlatformByID(ctx context.Context, req dto.PutPlatform
 		)
 		return dto.PutPlatformResponce{}, err
 	}
-	return dto.PutPlatformResponce{ID_platform: req.ID_platform, ID_user: req.ID_user, Updated_at: time.Now()}, nil
+	return dto.PutPlatformResponce{ID_platform: req.ID_platform, ID_user: req.ID_user, Updated_at: time.Now(), Config_version: configVersion}, nil
+}
+
+// UpdatePlatformVerification сохраняет отчёт проверки учётных данных версии configVersion.
+// Если их успели заменить, отчёт устарел и не сохраняется — ErrPlatformChanged.
+func (r *Repository) UpdatePlatformVerification(ctx context.Context, ID_platform int, configVersion int, report domain.VerificationReport) error {
+	reportBytes, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	tag, err := r.MasterPool.Exec(ctx, "UPDATE platforms SET verification = $1 WHERE id = $2 AND config_version = $3", reportBytes, ID_platform, configVersion)
+	if err != nil {
+		r.logger.Error("UpdatePlatformVerification failed",
+			zap.Error(err),
+			zap.Int("platform_id", ID_platform),
+		)
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrPlatformChanged
+	}
+	if report.OK {
+		return r.restorePlatformHealth(ctx, ID_platform, configVersion)
+	}
+	return nil
 }
@@ -0,0 +1,321 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"hexlet/internal/domain"
+
+	"github.com/jackc/pgx/v4"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrPostNotFound      = errors.New("post not found")
+	ErrInvalidTransition = errors.New("post status does not allow this action")
+	ErrSelfReview        = errors.New("author cannot review their own post")
+	ErrTooFewReviewers   = errors.New("required approvals exceed the number of reviewers besides the author")
+)
+
+// submitPost отправляет пост на проверку. Одобрения, полученные до этого момента, не засчитываются.
+func submitPost(ctx context.Context, tx pgx.Tx, ID_post int, ID_user string) (string, error) {
+	_, err := tx.Exec(ctx,
+		"UPDATE posts SET status = $2, submitted_at = NOW() WHERE id = $1",
+		ID_post, domain.PostInReview,
+	)
+	if err != nil {
+		return "", err
+	}
+	// уже запланированные направления снова ждут одобрения
+	_, err = tx.Exec(ctx, `
+		UPDATE post_destinations SET status = 'draft'
+		WHERE post_id = $1 AND status IN ('scheduled', 'held')`,
+		ID_post,
+	)
+	if err != nil {
+		return "", err
+	}
+	_, err = tx.Exec(ctx,
+		"INSERT INTO post_reviews (post_id, user_id, action) VALUES ($1, $2, $3)",
+		ID_post, ID_user, domain.ReviewSubmitted,
+	)
+	if err != nil {
+		return "", err
+	}
+	return advancePost(ctx, tx, ID_post)
+}
+
+// advancePost переводит пост на проверке дальше, если набрано нужное число одобрений:
+// в approved, а при заданном времени публикации — в scheduled с постановкой направлений в очередь.
+// Нужное число — максимум из требования пространства и платформ поста.
+func advancePost(ctx context.Context, tx pgx.Tx, ID_post int) (string, error) {
+	var required, approvals int
+	var hasTime bool
+	err := tx.QueryRow(ctx, `
+		SELECT
+			GREATEST(
+				COALESCE((SELECT required_approvals FROM workspaces WHERE id = p.workspace_id), 0),
+				(SELECT MAX(pl.required_approvals) FROM post_destinations pd
+					JOIN platforms pl ON pl.id = pd.platform_id
+					WHERE pd.post_id = p.id)
+			),
+			(SELECT count(DISTINCT r.user_id) FROM post_reviews r
+				WHERE r.post_id = p.id AND r.action = 'approved' AND r.created_at >= p.submitted_at),
+			EXISTS (SELECT 1 FROM post_destinations pd WHERE pd.post_id = p.id AND pd.scheduled_for IS NOT NULL)
+		FROM posts p
+		WHERE p.id = $1`,
+		ID_post,
+	).Scan(&required, &approvals, &hasTime)
+	if err != nil {
+		return "", err
+	}
+	if approvals < required {
+		return domain.PostInReview, nil
+	}
+	status := domain.PostApproved
+	if hasTime {
+		status = domain.PostScheduled
+	}
+	_, err = tx.Exec(ctx, "UPDATE posts SET status = $2 WHERE id = $1", ID_post, status)
+	if err != nil {
+		return "", err
+	}
+	if status != domain.PostScheduled {
+		return status, nil
+	}
+	// публикации на неактивную платформу откладываются до её включения
+	_, err = tx.Exec(ctx, `
+		UPDATE post_destinations pd
+		SET status = CASE WHEN pl.is_active THEN 'scheduled' ELSE 'held' END
+		FROM platforms pl
+		WHERE pl.id = pd.platform_id AND pd.post_id = $1 AND pd.status = 'draft'`,
+		ID_post,
+	)
+	return status, err
+}
+
+// advanceInReview пересчитывает посты на проверке после смены требований
+func advanceInReview(ctx context.Context, tx pgx.Tx, ID_workspace int) error {
+	rows, err := tx.Query(ctx,
+		"SELECT id FROM posts WHERE workspace_id = $1 AND status = $2 FOR UPDATE",
+		ID_workspace, domain.PostInReview,
+	)
+	if err != nil {
+		return err
+	}
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if _, err := advancePost(ctx, tx, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkReviewers не даёт требовать больше одобрений, чем есть проверяющих (owner, admin)
+// помимо автора: иначе его посты навсегда останутся на проверке
+func checkReviewers(ctx context.Context, tx pgx.Tx, ID_workspace int, required int) error {
+	var reviewers int
+	err := tx.QueryRow(ctx,
+		"SELECT count(*) FROM workspace_members WHERE workspace_id = $1 AND role IN ($2, $3)",
+		ID_workspace, domain.RoleOwner, domain.RoleAdmin,
+	).Scan(&reviewers)
+	if err != nil {
+		return err
+	}
+	if required > reviewers-1 {
+		return ErrTooFewReviewers
+	}
+	return nil
+}
+
+// lockPost блокирует пост пространства и возвращает его статус и автора
+func lockPost(ctx context.Context, tx pgx.Tx, ID_post int, ID_workspace int) (string, string, error) {
+	var status, author string
+	err := tx.QueryRow(ctx,
+		"SELECT status, user_id FROM posts WHERE id = $1 AND workspace_id = $2 FOR UPDATE",
+		ID_post, ID_workspace,
+	).Scan(&status, &author)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", "", ErrPostNotFound
+	}
+	return status, author, err
+}
+
+// SubmitPost отправляет черновик на проверку и возвращает новый статус поста
+func (r *Repository) SubmitPost(ctx context.Context, ID_post int, ID_workspace int, ID_user string) (string, error) {
+	var res string
+	err := r.MasterPool.BeginFunc(ctx, func(tx pgx.Tx) error {
+		status, _, err := lockPost(ctx, tx, ID_post, ID_workspace)
+		if err != nil {
+			return err
+		}
+		if status != domain.PostDraft {
+			return ErrInvalidTransition
+		}
+		res, err = submitPost(ctx, tx, ID_post, ID_user)
+		return err
+	})
+	if err != nil {
+		if !errors.Is(err, ErrPostNotFound) && !errors.Is(err, ErrInvalidTransition) {
+			r.logger.Error("SubmitPost failed",
+				zap.Error(err),
+				zap.Int("post_id", ID_post),
+				zap.Int("workspace_id", ID_workspace),
+			)
+		}
+		return "", err
+	}
+	return res, nil
+}
+
+// ReviewPost записывает одобрение или отклонение поста на проверке.
+// Отклонённый пост возвращается в черновики.
+func (r *Repository) ReviewPost(ctx context.Context, ID_workspace int, review domain.PostReview) (string, error) {
+	var res string
+	err := r.MasterPool.BeginFunc(ctx, func(tx pgx.Tx) error {
+		status, author, err := lockPost(ctx, tx, review.ID_post, ID_workspace)
+		if err != nil {
+			return err
+		}
+		if status != domain.PostInReview {
+			return ErrInvalidTransition
+		}
+		if author == review.ID_user {
+			return ErrSelfReview
+		}
+		_, err = tx.Exec(ctx,
+			"INSERT INTO post_reviews (post_id, user_id, action, comment) VALUES ($1, $2, $3, $4)",
+			review.ID_post, review.ID_user, review.Action, review.Comment,
+		)
+		if err != nil {
+			return err
+		}
+		if review.Action == domain.ReviewApproved {
+			res, err = advancePost(ctx, tx, review.ID_post)
+			return err
+		}
+		res = domain.PostDraft
+		_, err = tx.Exec(ctx, "UPDATE posts SET status = $2 WHERE id = $1", review.ID_post, res)
+		return err
+	})
+	if err != nil {
+		if !errors.Is(err, ErrPostNotFound) && !errors.Is(err, ErrInvalidTransition) && !errors.Is(err, ErrSelfReview) {
+			r.logger.Error("ReviewPost failed",
+				zap.Error(err),
+				zap.Int("post_id", review.ID_post),
+				zap.String("user_id", review.ID_user),
+			)
+		}
+		return "", err
+	}
+	return res, nil
+}
+
+func (r *Repository) GetPostReviews(ctx context.Context, ID_post int, ID_workspace int) ([]domain.PostReview, error) {
+	rows, err := r.SlavePool.Query(ctx, `
+		SELECT r.id, r.post_id, r.user_id, r.action, r.comment, r.created_at
+		FROM post_reviews r
+		JOIN posts p ON p.id = r.post_id
+		WHERE r.post_id = $1 AND p.workspace_id = $2
+		ORDER BY r.created_at, r.id`,
+		ID_post, ID_workspace,
+	)
+	if err != nil {
+		r.logger.Error("GetPostReviews failed",
+			zap.Error(err),
+			zap.Int("post_id", ID_post),
+			zap.Int("workspace_id", ID_workspace),
+		)
+		return nil, err
+	}
+	defer rows.Close()
+	res := []domain.PostReview{}
+	for rows.Next() {
+		var rv domain.PostReview
+		if err := rows.Scan(&rv.ID_review, &rv.ID_post, &rv.ID_user, &rv.Action, &rv.Comment, &rv.Created_at); err != nil {
+			r.logger.Error("GetPostReviews failed in scaning",
+				zap.Error(err),
+				zap.Int("post_id", ID_post),
+			)
+			return nil, err
+		}
+		res = append(res, rv)
+	}
+	return res, rows.Err()
+}
+
+// UpdateWorkspaceApprovals меняет требование пространства. Посты на проверке,
+// которым теперь хватает одобрений, сразу идут дальше.
+func (r *Repository) UpdateWorkspaceApprovals(ctx context.Context, ID_workspace int, required int) error {
+	err := r.MasterPool.BeginFunc(ctx, func(tx pgx.Tx) error {
+		if err := checkReviewers(ctx, tx, ID_workspace, required); err != nil {
+			return err
+		}
+		_, err := tx.Exec(ctx,
+			"UPDATE workspaces SET required_approvals = $2, updated_at = NOW() WHERE id = $1",
+			ID_workspace, required,
+		)
+		if err != nil {
+			return err
+		}
+		return advanceInReview(ctx, tx, ID_workspace)
+	})
+	if errors.Is(err, ErrTooFewReviewers) {
+		return err
+	}
+	if err != nil {
+		r.logger.Error("UpdateWorkspaceApprovals failed",
+			zap.Error(err),
+			zap.Int("workspace_id", ID_workspace),
+		)
+	}
+	return err
+}
+
+// UpdatePlatformApprovals меняет требование платформы, nil — как в пространстве
+func (r *Repository) UpdatePlatformApprovals(ctx context.Context, ID_platform int, ID_workspace int, required *int) (bool, error) {
+	var updated bool
+	err := r.MasterPool.BeginFunc(ctx, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx,
+			"UPDATE platforms SET required_approvals = $3, updated_at = NOW() WHERE id = $1 AND workspace_id = $2",
+			ID_platform, ID_workspace, required,
+		)
+		if err != nil {
+			return err
+		}
+		updated = tag.RowsAffected() > 0
+		if !updated {
+			return nil
+		}
+		if required != nil {
+			if err := checkReviewers(ctx, tx, ID_workspace, *required); err != nil {
+				return err
+			}
+		}
+		return advanceInReview(ctx, tx, ID_workspace)
+	})
+	if errors.Is(err, ErrTooFewReviewers) {
+		return false, err
+	}
+	if err != nil {
+		r.logger.Error("UpdatePlatformApprovals failed",
+			zap.Error(err),
+			zap.Int("platform_id", ID_platform),
+			zap.Int("workspace_id", ID_workspace),
+		)
+		return false, err
+	}
+	return updated, nil
+}
@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"hexlet/internal/domain"
+	"hexlet/internal/dto"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"go.uber.org/zap"
+)
+
+var ErrRevisionNotFound = errors.New("revision not found")
+
+// createRevision сохраняет снимок поста следующим номером и делает его текущим.
+// Вызывается под блокировкой строки поста.
+func createRevision(ctx context.Context, tx pgx.Tx, ID_post int, ID_user string, title string, content string, sheduledFor *time.Time, changed []string) (int, error) {
+	var revision int
+	err := tx.QueryRow(ctx, `
+		INSERT INTO post_revisions (post_id, revision, user_id, title, content, scheduled_for, changed_fields)
+		SELECT $1, COALESCE(MAX(revision), 0) + 1, $2, $3, $4, $5, $6
+		FROM post_revisions WHERE post_id = $1
+		RETURNING revision`,
+		ID_post, ID_user, title, content, sheduledFor, changed,
+	).Scan(&revision)
+	if err != nil {
+		return 0, err
+	}
+	_, err = tx.Exec(ctx, "UPDATE posts SET revision = $2 WHERE id = $1", ID_post, revision)
+	return revision, err
+}
+
+func scanRevision(row pgx.Row) (domain.PostRevision, error) {
+	var rv domain.PostRevision
+	err := row.Scan(&rv.ID_post, &rv.Revision, &rv.ID_user, &rv.Title, &rv.Content, &rv.Sheduled_for, &rv.Changed_fields, &rv.Created_at)
+	// время публикации отдаётся так же, как в постах
+	rv.Sheduled_for = toAPITimePtr(rv.Sheduled_for)
+	return rv, err
+}
+
+func (r *Repository) GetPostRevisions(ctx context.Context, ID_post int, ID_workspace int) ([]domain.PostRevision, error) {
+	rows, err := r.SlavePool.Query(ctx, `
+		SELECT rv.post_id, rv.revision, rv.user_id, rv.title, rv.content, rv.scheduled_for, rv.changed_fields, rv.created_at
+		FROM post_revisions rv
+		JOIN posts p ON p.id = rv.post_id
+		WHERE rv.post_id = $1 AND p.workspace_id = $2
+		ORDER BY rv.revision DESC`,
+		ID_post, ID_workspace,
+	)
+	if err != nil {
+		r.logger.Error("GetPostRevisions failed",
+			zap.Error(err),
+			zap.Int("post_id", ID_post),
+			zap.Int("workspace_id", ID_workspace),
+		)
+		return nil, err
+	}
+	defer rows.Close()
+	res := []domain.PostRevision{}
+	for rows.Next() {
+		rv, err := scanRevision(rows)
+		if err != nil {
+			r.logger.Error("GetPostRevisions failed in scaning",
+				zap.Error(err),
+				zap.Int("post_id", ID_post),
+			)
+			return nil, err
+		}
+		res = append(res, rv)
+	}
+	return res, rows.Err()
+}
+
+func (r *Repository) GetPostRevision(ctx context.Context, ID_post int, ID_workspace int, revision int) (domain.PostRevision, error) {
+	rv, err := scanRevision(r.SlavePool.QueryRow(ctx, `
+		SELECT rv.post_id, rv.revision, rv.user_id, rv.title, rv.content, rv.scheduled_for, rv.changed_fields, rv.created_at
+		FROM post_revisions rv
+		JOIN posts p ON p.id = rv.post_id
+		WHERE rv.post_id = $1 AND p.workspace_id = $2 AND rv.revision = $3`,
+		ID_post, ID_workspace, revision,
+	))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.PostRevision{}, ErrRevisionNotFound
+	}
+	if err != nil {
+		r.logger.Error("GetPostRevision failed",
+			zap.Error(err),
+			zap.Int("post_id", ID_post),
+			zap.Int("revision", revision),
+		)
+		return domain.PostRevision{}, err
+	}
+	return rv, nil
+}
+
+// RestorePostRevision возвращает заголовок и текст ревизии новой ревизией.
+// Время публикации не меняется: у старой ревизии оно обычно уже в прошлом.
+func (r *Repository) RestorePostRevision(ctx context.Context, ID_post int, ID_workspace int, revision int, ID_user string) (int, error) {
+	var current int
+	err := r.MasterPool.BeginFunc(ctx, func(tx pgx.Tx) error {
+		req := dto.PutPostRequest{ID_user: ID_user, ID_workspace: ID_workspace, ID_post: ID_post}
+		var sheduledFor *time.Time
+		err := tx.QueryRow(ctx, `
+			SELECT rv.title, rv.content,
+				(SELECT MIN(scheduled_for) FROM post_destinations WHERE post_id = p.id)
+			FROM post_revisions rv
+			JOIN posts p ON p.id = rv.post_id
+			WHERE rv.post_id = $1 AND p.workspace_id = $2 AND rv.revision = $3`,
+			ID_post, ID_workspace, revision,
+		).Scan(&req.Title, &req.Content, &sheduledFor)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrRevisionNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if sheduledFor != nil {
+			req.Sheduled_for = *sheduledFor
+		}
+		if err := updatePost(ctx, tx, req); err != nil {
+			return err
+		}
+		return tx.QueryRow(ctx, "SELECT revision FROM posts WHERE id = $1", ID_post).Scan(&current)
+	})
+	if err != nil {
+		if !errors.Is(err, ErrRevisionNotFound) {
+			r.logger.Error("RestorePostRevision failed",
+				zap.Error(err),
+				zap.Int("post_id", ID_post),
+				zap.Int("revision", revision),
+			)
+		}
+		return 0, err
+	}
+	return current, nil
+}
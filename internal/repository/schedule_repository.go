@@ -2,22 +2,16 @@ package repository
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"hexlet/internal/domain"
 	"time"
 
-	"github.com/robfig/cron/v3"
 	"go.uber.org/zap"
 )
 
-var publications []domain.ScheduledPublication
-
+// GetReadyForPublication возвращает наступившие публикации для текущего прохода планировщика
 func (r *Repository) GetReadyForPublication(ctx context.Context, batchSize int) ([]domain.ScheduledPublication, error) {
-	c := cron.New()
-	_, err := c.AddFunc("@every 1m", func() {
-		taskCtx := context.Background()
-		query := `
+	query := `
         SELECT 
             pd.id as id_destination,
             pd.post_id as id_post,
@@ -25,99 +19,70 @@ func (r *Repository) GetReadyForPublication(ctx context.Context, batchSize int)
             p.title,
             p.content, 
             pd.platform_id as id_platform,
-            pl.platform_name
+            pl.platform_name,
+            p.trace_parent
         FROM post_destinations pd
         JOIN posts p ON p.id = pd.post_id
         JOIN platforms pl ON pl.id = pd.platform_id
         WHERE pd.status = 'scheduled' 
+        AND p.status = 'scheduled'
+        AND pl.is_active
         AND pd.scheduled_for <= NOW()
         ORDER BY pd.scheduled_for ASC
         LIMIT $1`
-		rows, err := r.SlavePool.Query(taskCtx, query, batchSize)
+	rows, err := r.SlavePool.Query(ctx, query, batchSize)
+	if err != nil {
+		r.logger.Error("GetReadyForPublication failed in query",
+			zap.Error(err),
+			zap.Int("batch_size", batchSize),
+		)
+		return nil, fmt.Errorf("failed to query scheduled publications: %w", err)
+	}
+	defer rows.Close()
+
+	var publications []domain.ScheduledPublication
+	for rows.Next() {
+		var pub domain.ScheduledPublication
+		err := rows.Scan(
+			&pub.ID_destination,
+			&pub.ID_post,
+			&pub.ID_user,
+			&pub.Title,
+			&pub.Content,
+			&pub.ID_platform,
+			&pub.Platform_name,
+			&pub.Trace_parent,
+		)
 		if err != nil {
-			fmt.Printf("CRON: failed to query scheduled publications: %v\n", err)
-			return
+			r.logger.Error("GetReadyForPublication failed in scan", zap.Error(err))
+			return nil, fmt.Errorf("failed to scan publication: %w", err)
 		}
-		defer rows.Close()
-		publications = []domain.ScheduledPublication{}
-		for rows.Next() {
-			var pub domain.ScheduledPublication
-			err := rows.Scan(
-				&pub.ID_destination,
-				&pub.ID_post,
-				&pub.ID_user,
-				&pub.Title,
-				&pub.Content,
-				&pub.ID_platform,
-				&pub.Platform_name,
-			)
-			if err != nil {
-				fmt.Printf("CRON: failed to scan publication: %v\n", err)
-				continue
-			}
-			publications = append(publications, pub)
-		}
-		for _, pub := range publications {
-			fmt.Printf("Отправляем в Kafka пост %d для публикации в %s\n",
-				pub.ID_post, pub.Platform_name)
-		}
-
-		fmt.Printf("Найдено %d постов для публикации в %v\n",
-			len(publications), time.Now())
-	})
-	if err != nil {
-		return nil, fmt.Errorf("failed to add cron job: %w", err)
+		publications = append(publications, pub)
+	}
+	if err := rows.Err(); err != nil {
+		r.logger.Error("GetReadyForPublication failed in rows", zap.Error(err))
+		return nil, fmt.Errorf("failed to read scheduled publications: %w", err)
 	}
-	c.Start()
-	fmt.Println("Автопостинг запущен, проверка каждую минуту")
 	return publications, nil
 }
 
-func (r *Repository) GetPlatformsByUserID(ctx context.Context, platform_name string, userID string) (domain.PlatformSQL, error) {
-	query := "SELECT platform_name, api_config, is_active FROM platforms WHERE user_id = $1"
-	rows, err := r.SlavePool.Query(ctx, query, userID)
+func (r *Repository) GetPlatformConfigByID(ctx context.Context, ID_platform int) (domain.PlatformSQL, error) {
+	query := "SELECT id, platform_name, api_config, is_active FROM platforms WHERE id = $1"
+	var res domain.PlatformSQL
+	err := r.SlavePool.QueryRow(ctx, query, ID_platform).Scan(&res.ID, &res.PlatformName, &res.Config, &res.IsActive)
 	if err != nil {
-		r.logger.Error("GetPlatformsByUserID failed in query",
+		r.logger.Error("GetPlatformConfigByID failed in query",
 			zap.Error(err),
-			zap.String("user_id", userID),
+			zap.Int("platform_id", ID_platform),
 		)
 		return domain.PlatformSQL{}, err
 	}
-	var res domain.PlatformSQL
-	defer rows.Close()
-	for rows.Next() {
-		var platform domain.PlatformSQL
-		var configData []byte
-		err := rows.Scan(&platform.PlatformName, &configData, &platform.IsActive)
-		if err != nil {
-			r.logger.Error("GetPlatformsByUserID failed in scaning",
-				zap.Error(err),
-				zap.String("user_id", userID),
-			)
-			return domain.PlatformSQL{}, err
-		}
-		if !platform.IsActive || platform.PlatformName != platform_name {
-			r.logger.Error("GetPlatformsByUserID not active",
-				zap.Error(err),
-				zap.String("user_id", userID),
-			)
-			return domain.PlatformSQL{}, nil
-		}
-		if len(configData) > 0 {
-			var configMap map[string]string
-			if err := json.Unmarshal(configData, &configMap); err != nil {
-				r.logger.Error("GetPlatformsByUserID in unmarshaling",
-					zap.Error(err),
-					zap.String("user_id", userID),
-				)
-				continue
-			}
-			platform.APIConfig = configMap
-		}
-		res = platform
-
-	}
-	if err := rows.Err(); err != nil {
+	res.Config, err = r.decryptConfig(res.Config)
+	if err != nil {
+		r.logger.Error("GetPlatformConfigByID failed in decrypting",
+			zap.Error(err),
+			zap.Int("platform_id", ID_platform),
+		)
 		return domain.PlatformSQL{}, err
 	}
 	return res, nil
@@ -125,10 +90,13 @@ func (r *Repository) GetPlatformsByUserID(ctx context.Context, platform_name str
 
 func (r *Repository) GetTitleANDContent(ctx context.Context, id int) (domain.Message, error) {
 	query := `
-        SELECT title, content FROM posts WHERE id = $1
+        SELECT p.title, p.content, p.revision, p.variables, COALESCE(u.timezone, 'UTC')
+        FROM posts p
+        LEFT JOIN users u ON u.id = p.user_id
+        WHERE p.id = $1
     `
 	var res domain.Message
-	err := r.SlavePool.QueryRow(ctx, query, id).Scan(&res.Title, &res.Content)
+	err := r.SlavePool.QueryRow(ctx, query, id).Scan(&res.Title, &res.Content, &res.Revision, &res.Variables, &res.Timezone)
 	if err != nil {
 		r.logger.Error("GetTitleANDContent failed",
 			zap.Error(err),
@@ -139,14 +107,34 @@ func (r *Repository) GetTitleANDContent(ctx context.Context, id int) (domain.Mes
 	return res, nil
 }
 
-func (r *Repository) MarkAsSent(ctx context.Context, ID int) error {
+// StartProcessing переводит запланированную публикацию в processing.
+// false — публикацию уже забрали, сняли или перенесли в ожидание, отправлять её не нужно.
+func (r *Repository) StartProcessing(ctx context.Context, ID_destination int) (bool, error) {
+	tag, err := r.MasterPool.Exec(ctx, `
+		UPDATE post_destinations
+		SET status = 'processing', processing_started_at = NOW()
+		WHERE id = $1 AND status = 'scheduled'`,
+		ID_destination,
+	)
+	if err != nil {
+		r.logger.Error("StartProcessing failed",
+			zap.Error(err),
+			zap.Int("post_destinations_id", ID_destination),
+		)
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// MarkAsSent отмечает публикацию, ревизию поста, которая ушла на платформу, и id публикации на платформе
+func (r *Repository) MarkAsSent(ctx context.Context, ID int, revision int, remoteID string) error {
 	query := `
 		UPDATE post_destinations
 		SET 
-			status= 'published', published_at = $1
+			status= 'published', published_at = $1, revision = $3, remote_id = NULLIF($4, '')
 		WHERE id = $2
 	`
-	_, err := r.MasterPool.Exec(ctx, query, time.Now(), ID)
+	_, err := r.MasterPool.Exec(ctx, query, time.Now(), ID, revision, remoteID)
 	if err != nil {
 		r.logger.Error("MarkAsSent failed",
 			zap.Error(err),
@@ -157,14 +145,15 @@ func (r *Repository) MarkAsSent(ctx context.Context, ID int) error {
 	return nil
 }
 
+// ErrorMessage отмечает публикацию неудачной и сохраняет текст ошибки
 func (r *Repository) ErrorMessage(ctx context.Context, destination_id int, err error) error {
 	query := `
 		UPDATE post_destinations
 		SET 
-			error_message = $1
+			status = 'failed', error_message = $1
 		WHERE id = $2
 	`
-	_, err1 := r.MasterPool.Exec(ctx, query, err, destination_id)
+	_, err1 := r.MasterPool.Exec(ctx, query, err.Error(), destination_id)
 	if err1 != nil {
 		r.logger.Error("ErrorMessage failed",
 			zap.Error(err1),
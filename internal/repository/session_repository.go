@@ -0,0 +1,199 @@
+package repository
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"hexlet/internal/domain"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrRefreshTokenInvalid = errors.New("refresh token is invalid or expired")
+	// Повторное использование уже обменянного токена: вероятно, токен украден
+	ErrRefreshTokenReused = errors.New("refresh token reuse detected, session revoked")
+)
+
+func (r *Repository) CreateSession(ctx context.Context, s domain.Session, token domain.RefreshToken) error {
+	err := r.MasterPool.BeginFunc(ctx, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, `
+			INSERT INTO sessions (id, user_id, user_agent, ip, expires_at)
+			VALUES ($1, $2, $3, $4, $5)`,
+			s.ID_session, s.ID_user, s.User_agent, s.Ip, token.Expires_at,
+		)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, `
+			INSERT INTO refresh_tokens (jti, session_id, token_hash, expires_at)
+			VALUES ($1, $2, $3, $4)`,
+			token.JTI, s.ID_session, token.Hash, token.Expires_at,
+		)
+		return err
+	})
+	if err != nil {
+		r.logger.Error("CreateSession failed",
+			zap.Error(err),
+			zap.String("user_id", s.ID_user),
+		)
+		return err
+	}
+	return nil
+}
+
+// RotateRefreshToken помечает предъявленный токен использованным и сохраняет следующий.
+// Повторное предъявление использованного токена отзывает всю сессию.
+// Возвращает пространство, выбранное в сессии (0 — по умолчанию).
+func (r *Repository) RotateRefreshToken(ctx context.Context, ID_session string, ID_user string, old domain.RefreshToken, next domain.RefreshToken) (int, error) {
+	reused := false
+	var workspaceID int
+	err := r.MasterPool.BeginFunc(ctx, func(tx pgx.Tx) error {
+		var hash string
+		var usedAt, revokedAt *time.Time
+		var expiresAt time.Time
+		err := tx.QueryRow(ctx, `
+			SELECT rt.token_hash, rt.used_at, rt.expires_at, s.revoked_at, COALESCE(s.workspace_id, 0)
+			FROM refresh_tokens rt
+			JOIN sessions s ON s.id = rt.session_id
+			WHERE rt.jti = $1 AND rt.session_id = $2 AND s.user_id = $3
+			FOR UPDATE`,
+			old.JTI, ID_session, ID_user,
+		).Scan(&hash, &usedAt, &expiresAt, &revokedAt, &workspaceID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrRefreshTokenInvalid
+		}
+		if err != nil {
+			return err
+		}
+		if subtle.ConstantTimeCompare([]byte(hash), []byte(old.Hash)) != 1 || revokedAt != nil || time.Now().After(expiresAt) {
+			return ErrRefreshTokenInvalid
+		}
+		if usedAt != nil {
+			reused = true
+			_, err := tx.Exec(ctx, "UPDATE sessions SET revoked_at = NOW() WHERE id = $1", ID_session)
+			return err
+		}
+		_, err = tx.Exec(ctx, "UPDATE refresh_tokens SET used_at = NOW() WHERE jti = $1", old.JTI)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, `
+			INSERT INTO refresh_tokens (jti, session_id, token_hash, expires_at)
+			VALUES ($1, $2, $3, $4)`,
+			next.JTI, ID_session, next.Hash, next.Expires_at,
+		)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, "UPDATE sessions SET last_used_at = NOW(), expires_at = $2 WHERE id = $1", ID_session, next.Expires_at)
+		return err
+	})
+	if err == nil && reused {
+		err = ErrRefreshTokenReused
+	}
+	if err != nil && !errors.Is(err, ErrRefreshTokenInvalid) {
+		r.logger.Error("RotateRefreshToken failed",
+			zap.Error(err),
+			zap.String("session_id", ID_session),
+			zap.String("user_id", ID_user),
+		)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return workspaceID, nil
+}
+
+// SetSessionWorkspace запоминает выбранное пространство, его получат следующие access токены сессии
+func (r *Repository) SetSessionWorkspace(ctx context.Context, ID_session string, ID_user string, ID_workspace int) error {
+	_, err := r.MasterPool.Exec(ctx, `
+		UPDATE sessions
+		SET workspace_id = $3
+		WHERE id = $1 AND user_id = $2`,
+		ID_session, ID_user, ID_workspace,
+	)
+	if err != nil {
+		r.logger.Error("SetSessionWorkspace failed",
+			zap.Error(err),
+			zap.String("session_id", ID_session),
+			zap.Int("workspace_id", ID_workspace),
+		)
+		return err
+	}
+	return nil
+}
+
+func (r *Repository) GetSessions(ctx context.Context, ID_user string) ([]domain.Session, error) {
+	rows, err := r.SlavePool.Query(ctx, `
+		SELECT id, user_id, COALESCE(user_agent, ''), COALESCE(ip, ''), created_at, last_used_at, expires_at
+		FROM sessions
+		WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > NOW()
+		ORDER BY last_used_at DESC`,
+		ID_user,
+	)
+	if err != nil {
+		r.logger.Error("GetSessions failed",
+			zap.Error(err),
+			zap.String("user_id", ID_user),
+		)
+		return nil, err
+	}
+	defer rows.Close()
+	res := []domain.Session{}
+	for rows.Next() {
+		var s domain.Session
+		if err := rows.Scan(&s.ID_session, &s.ID_user, &s.User_agent, &s.Ip, &s.Created_at, &s.Last_used_at, &s.Expires_at); err != nil {
+			r.logger.Error("GetSessions failed in scaning",
+				zap.Error(err),
+				zap.String("user_id", ID_user),
+			)
+			return nil, err
+		}
+		res = append(res, s)
+	}
+	return res, rows.Err()
+}
+
+// IsSessionActive — сессия не отозвана и не истекла. Читает с мастера:
+// отзыв сессии должен сразу отключать её access токены.
+func (r *Repository) IsSessionActive(ctx context.Context, ID_session string, ID_user string) (bool, error) {
+	var active bool
+	err := r.MasterPool.QueryRow(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM sessions
+			WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL AND expires_at > NOW()
+		)`,
+		ID_session, ID_user,
+	).Scan(&active)
+	if err != nil {
+		r.logger.Error("IsSessionActive failed",
+			zap.Error(err),
+			zap.String("session_id", ID_session),
+			zap.String("user_id", ID_user),
+		)
+		return false, err
+	}
+	return active, nil
+}
+
+// RevokeSession отзывает сессию пользователя, false — активной сессии с таким id нет
+func (r *Repository) RevokeSession(ctx context.Context, ID_session string, ID_user string) (bool, error) {
+	tag, err := r.MasterPool.Exec(ctx, `
+		UPDATE sessions
+		SET revoked_at = NOW()
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL`,
+		ID_session, ID_user,
+	)
+	if err != nil {
+		r.logger.Error("RevokeSession failed",
+			zap.Error(err),
+			zap.String("session_id", ID_session),
+			zap.String("user_id", ID_user),
+		)
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"hexlet/internal/domain"
+
+	"go.uber.org/zap"
+)
+
+// канал NOTIFY, в который пишет триггер trg_destination_status
+const statusChannel = "destination_status"
+
+// ListenStatusChanges держит отдельное соединение с мастером и вызывает fn на каждую смену статуса.
+// Возвращается при отмене ctx или обрыве соединения; переподключение — забота вызывающего.
+func (r *Repository) ListenStatusChanges(ctx context.Context, fn func(domain.StatusEvent)) error {
+	pooled, err := r.MasterPool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	// соединение с LISTEN не возвращается в пул
+	conn := pooled.Hijack()
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+statusChannel); err != nil {
+		r.logger.Error("ListenStatusChanges failed in listen",
+			zap.Error(err),
+		)
+		return err
+	}
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		var e domain.StatusEvent
+		if err := json.Unmarshal([]byte(n.Payload), &e); err != nil {
+			r.logger.Error("ListenStatusChanges failed in decoding",
+				zap.Error(err),
+				zap.String("payload", n.Payload),
+			)
+			continue
+		}
+		fn(e)
+	}
+}
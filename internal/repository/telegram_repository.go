@@ -0,0 +1,225 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"hexlet/internal/domain"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"go.uber.org/zap"
+)
+
+var ErrTelegramNotLinked = errors.New("telegram chat is not linked")
+
+// LinkTelegramChat гасит код привязки и привязывает чат к его владельцу.
+// Чат, привязанный к другому пользователю, перепривязывается.
+func (r *Repository) LinkTelegramChat(ctx context.Context, tokenHash string, chatID int64, username string) (string, error) {
+	var userID string
+	err := r.MasterPool.BeginFunc(ctx, func(tx pgx.Tx) error {
+		var err error
+		userID, err = useAuthToken(ctx, tx, domain.TokenLinkTelegram, tokenHash)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, "DELETE FROM telegram_chats WHERE chat_id = $1 AND user_id <> $2", chatID, userID); err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, `
+			INSERT INTO telegram_chats (user_id, chat_id, username)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (user_id) DO UPDATE
+			SET chat_id = EXCLUDED.chat_id, username = EXCLUDED.username, linked_at = NOW()`,
+			userID, chatID, username,
+		)
+		return err
+	})
+	if err != nil {
+		if !errors.Is(err, ErrAuthTokenInvalid) {
+			r.logger.Error("LinkTelegramChat failed",
+				zap.Error(err),
+				zap.Int64("chat_id", chatID),
+			)
+		}
+		return "", err
+	}
+	return userID, nil
+}
+
+func (r *Repository) UnlinkTelegram(ctx context.Context, ID_user string) (bool, error) {
+	tag, err := r.MasterPool.Exec(ctx, "DELETE FROM telegram_chats WHERE user_id = $1", ID_user)
+	if err != nil {
+		r.logger.Error("UnlinkTelegram failed",
+			zap.Error(err),
+			zap.String("user_id", ID_user),
+		)
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// GetTelegramUser — пользователь, к которому привязан чат
+func (r *Repository) GetTelegramUser(ctx context.Context, chatID int64) (string, error) {
+	var userID string
+	err := r.SlavePool.QueryRow(ctx, "SELECT user_id FROM telegram_chats WHERE chat_id = $1", chatID).Scan(&userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", ErrTelegramNotLinked
+	}
+	if err != nil {
+		r.logger.Error("GetTelegramUser failed",
+			zap.Error(err),
+			zap.Int64("chat_id", chatID),
+		)
+		return "", err
+	}
+	return userID, nil
+}
+
+func (r *Repository) GetTelegramChat(ctx context.Context, ID_user string) (int64, error) {
+	var chatID int64
+	err := r.SlavePool.QueryRow(ctx, "SELECT chat_id FROM telegram_chats WHERE user_id = $1", ID_user).Scan(&chatID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, ErrTelegramNotLinked
+	}
+	if err != nil {
+		r.logger.Error("GetTelegramChat failed",
+			zap.Error(err),
+			zap.String("user_id", ID_user),
+		)
+		return 0, err
+	}
+	return chatID, nil
+}
+
+// GetUpcomingDestinations — ближайшие публикации пространства, время без сдвига
+func (r *Repository) GetUpcomingDestinations(ctx context.Context, ID_workspace int, limit int) ([]domain.CalendarEvent, error) {
+	rows, err := r.SlavePool.Query(ctx, `
+		SELECT d.id, d.post_id, d.workspace_id, p.title, pl.platform_name, d.status, d.scheduled_for
+		FROM post_destinations d
+		JOIN posts p ON p.id = d.post_id
+		JOIN platforms pl ON pl.id = d.platform_id
+		WHERE d.workspace_id = $1
+		AND d.status IN ('scheduled', 'held')
+		AND d.scheduled_for IS NOT NULL
+		ORDER BY d.scheduled_for, d.id
+		LIMIT $2`,
+		ID_workspace, limit,
+	)
+	if err != nil {
+		r.logger.Error("GetUpcomingDestinations failed",
+			zap.Error(err),
+			zap.Int("workspace_id", ID_workspace),
+		)
+		return nil, err
+	}
+	defer rows.Close()
+	res := []domain.CalendarEvent{}
+	for rows.Next() {
+		var e domain.CalendarEvent
+		if err := rows.Scan(&e.ID_destination, &e.ID_post, &e.ID_workspace, &e.Title, &e.PlatformName, &e.Status, &e.Sheduled_for); err != nil {
+			r.logger.Error("GetUpcomingDestinations failed in scaning",
+				zap.Error(err),
+				zap.Int("workspace_id", ID_workspace),
+			)
+			return nil, err
+		}
+		res = append(res, e)
+	}
+	return res, rows.Err()
+}
+
+// RescheduleDestinations переносит неопубликованные публикации поста на at
+// и записывает перенос ревизией. Отменённые (skipped) снова ставятся в расписание.
+// Возвращает число перенесённых публикаций.
+func (r *Repository) RescheduleDestinations(ctx context.Context, ID_post int, ID_workspace int, ID_user string, at time.Time) (int, error) {
+	var moved int
+	err := r.MasterPool.BeginFunc(ctx, func(tx pgx.Tx) error {
+		var title, content string
+		err := tx.QueryRow(ctx, "SELECT title, content FROM posts WHERE id = $1 AND workspace_id = $2 FOR UPDATE",
+			ID_post, ID_workspace,
+		).Scan(&title, &content)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrPostNotFound
+		}
+		if err != nil {
+			return err
+		}
+		tag, err := tx.Exec(ctx, `
+			UPDATE post_destinations
+			SET scheduled_for = $3,
+				status = CASE WHEN status = 'skipped' THEN 'scheduled' ELSE status END
+			WHERE post_id = $1 AND workspace_id = $2
+			AND status IN ('draft', 'scheduled', 'held', 'skipped')`,
+			ID_post, ID_workspace, at,
+		)
+		if err != nil {
+			return err
+		}
+		moved = int(tag.RowsAffected())
+		if moved == 0 {
+			return nil
+		}
+		_, err = createRevision(ctx, tx, ID_post, ID_user, title, content, &at, []string{domain.FieldSheduledFor})
+		return err
+	})
+	if err != nil {
+		if !errors.Is(err, ErrPostNotFound) {
+			r.logger.Error("RescheduleDestinations failed",
+				zap.Error(err),
+				zap.Int("post_id", ID_post),
+			)
+		}
+		return 0, err
+	}
+	return moved, nil
+}
+
+// CancelPost снимает запланированные публикации поста (skipped), опубликованные не трогаются
+func (r *Repository) CancelPost(ctx context.Context, ID_post int, ID_workspace int) (int, error) {
+	var exists bool
+	err := r.MasterPool.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM posts WHERE id = $1 AND workspace_id = $2)",
+		ID_post, ID_workspace,
+	).Scan(&exists)
+	if err == nil && !exists {
+		return 0, ErrPostNotFound
+	}
+	var tag pgconn.CommandTag
+	if err == nil {
+		tag, err = r.MasterPool.Exec(ctx, `
+			UPDATE post_destinations
+			SET status = 'skipped'
+			WHERE post_id = $1 AND workspace_id = $2
+			AND status IN ('scheduled', 'held')`,
+			ID_post, ID_workspace,
+		)
+	}
+	if err != nil {
+		r.logger.Error("CancelPost failed",
+			zap.Error(err),
+			zap.Int("post_id", ID_post),
+		)
+		return 0, err
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+// RetryDestination ставит неудачную или зависшую в processing публикацию
+// в очередь на ближайший проход планировщика
+func (r *Repository) RetryDestination(ctx context.Context, ID_destination int, ID_workspace int) (bool, error) {
+	tag, err := r.MasterPool.Exec(ctx, `
+		UPDATE post_destinations
+		SET status = 'scheduled', error_message = NULL, scheduled_for = NOW()
+		WHERE id = $1 AND workspace_id = $2
+		AND (status = 'failed' OR (status = 'processing' AND processing_started_at < NOW() - $3 * INTERVAL '1 second'))`,
+		ID_destination, ID_workspace, domain.StuckProcessingAfter.Seconds(),
+	)
+	if err != nil {
+		r.logger.Error("RetryDestination failed",
+			zap.Error(err),
+			zap.Int("post_destinations_id", ID_destination),
+		)
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
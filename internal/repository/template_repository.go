@@ -0,0 +1,166 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"hexlet/internal/domain"
+	"hexlet/internal/dto"
+	"hexlet/internal/posttemplate"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrTemplateNotFound  = errors.New("template not found")
+	ErrTemplateNameTaken = errors.New("template with this name already exists")
+)
+
+func scanTemplate(row pgx.Row) (domain.PostTemplate, error) {
+	var t domain.PostTemplate
+	err := row.Scan(&t.ID_template, &t.Name, &t.Title, &t.Content, &t.Created_at, &t.Updated_at)
+	t.Variables = []string{}
+	for _, name := range posttemplate.Placeholders(t.Title, t.Content) {
+		if !posttemplate.IsBuiltin(name) {
+			t.Variables = append(t.Variables, name)
+		}
+	}
+	return t, err
+}
+
+func templateError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+		return ErrTemplateNameTaken
+	}
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrTemplateNotFound
+	}
+	return err
+}
+
+func (r *Repository) CreateTemplate(ctx context.Context, req dto.CreateTemplateRequest) (domain.PostTemplate, error) {
+	t, err := scanTemplate(r.MasterPool.QueryRow(ctx, `
+		INSERT INTO post_templates (workspace_id, user_id, name, title, content)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, name, title, content, created_at, updated_at`,
+		req.ID_workspace, req.ID_user, req.Name, req.Title, req.Content,
+	))
+	if err = templateError(err); err != nil {
+		if !errors.Is(err, ErrTemplateNameTaken) {
+			r.logger.Error("CreateTemplate failed",
+				zap.Error(err),
+				zap.Int("workspace_id", req.ID_workspace),
+			)
+		}
+		return domain.PostTemplate{}, err
+	}
+	return t, nil
+}
+
+func (r *Repository) GetTemplates(ctx context.Context, ID_workspace int) ([]domain.PostTemplate, error) {
+	rows, err := r.SlavePool.Query(ctx, `
+		SELECT id, name, title, content, created_at, updated_at
+		FROM post_templates
+		WHERE workspace_id = $1
+		ORDER BY name`,
+		ID_workspace,
+	)
+	if err != nil {
+		r.logger.Error("GetTemplates failed",
+			zap.Error(err),
+			zap.Int("workspace_id", ID_workspace),
+		)
+		return nil, err
+	}
+	defer rows.Close()
+	res := []domain.PostTemplate{}
+	for rows.Next() {
+		t, err := scanTemplate(rows)
+		if err != nil {
+			r.logger.Error("GetTemplates failed in scaning",
+				zap.Error(err),
+				zap.Int("workspace_id", ID_workspace),
+			)
+			return nil, err
+		}
+		res = append(res, t)
+	}
+	return res, rows.Err()
+}
+
+func (r *Repository) GetTemplateByID(ctx context.Context, ID_template int, ID_workspace int) (domain.PostTemplate, error) {
+	t, err := scanTemplate(r.SlavePool.QueryRow(ctx, `
+		SELECT id, name, title, content, created_at, updated_at
+		FROM post_templates
+		WHERE id = $1 AND workspace_id = $2`,
+		ID_template, ID_workspace,
+	))
+	if err = templateError(err); err != nil {
+		if !errors.Is(err, ErrTemplateNotFound) {
+			r.logger.Error("GetTemplateByID failed",
+				zap.Error(err),
+				zap.Int("template_id", ID_template),
+			)
+		}
+		return domain.PostTemplate{}, err
+	}
+	return t, nil
+}
+
+func (r *Repository) UpdateTemplate(ctx context.Context, req dto.PutTemplateRequest) (domain.PostTemplate, error) {
+	t, err := scanTemplate(r.MasterPool.QueryRow(ctx, `
+		UPDATE post_templates
+		SET name = $3, title = $4, content = $5, updated_at = NOW()
+		WHERE id = $1 AND workspace_id = $2
+		RETURNING id, name, title, content, created_at, updated_at`,
+		req.ID_template, req.ID_workspace, req.Name, req.Title, req.Content,
+	))
+	if err = templateError(err); err != nil {
+		if !errors.Is(err, ErrTemplateNotFound) && !errors.Is(err, ErrTemplateNameTaken) {
+			r.logger.Error("UpdateTemplate failed",
+				zap.Error(err),
+				zap.Int("template_id", req.ID_template),
+			)
+		}
+		return domain.PostTemplate{}, err
+	}
+	return t, nil
+}
+
+// DeleteTemplate не трогает созданные из шаблона посты
+func (r *Repository) DeleteTemplate(ctx context.Context, ID_template int, ID_workspace int) (bool, error) {
+	tag, err := r.MasterPool.Exec(ctx,
+		"DELETE FROM post_templates WHERE id = $1 AND workspace_id = $2",
+		ID_template, ID_workspace,
+	)
+	if err != nil {
+		r.logger.Error("DeleteTemplate failed",
+			zap.Error(err),
+			zap.Int("template_id", ID_template),
+		)
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// GetPostVariables — значения переменных поста из шаблона, nil — пост создан не из шаблона
+func (r *Repository) GetPostVariables(ctx context.Context, ID_post int, ID_workspace int) (*domain.PostVariables, error) {
+	var vars *domain.PostVariables
+	err := r.SlavePool.QueryRow(ctx,
+		"SELECT variables FROM posts WHERE id = $1 AND workspace_id = $2",
+		ID_post, ID_workspace,
+	).Scan(&vars)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrPostNotFound
+	}
+	if err != nil {
+		r.logger.Error("GetPostVariables failed",
+			zap.Error(err),
+			zap.Int("post_id", ID_post),
+		)
+		return nil, err
+	}
+	return vars, nil
+}
@@ -0,0 +1,222 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"hexlet/internal/domain"
+	"hexlet/internal/dto"
+
+	"github.com/jackc/pgx/v4"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrIdentityTaken = errors.New("identity is already linked to another account")
+	ErrLastIdentity  = errors.New("cannot unlink the only login method of the account")
+)
+
+const userColumns = "id, email, name, avatar_url, timezone, created_at, updated_at"
+
+func scanUser(row pgx.Row) (domain.User, error) {
+	var u domain.User
+	err := row.Scan(&u.ID_user, &u.Email, &u.Name, &u.Avatar_url, &u.Timezone, &u.Created_at, &u.Updated_at)
+	return u, err
+}
+
+// FindOrCreateUser возвращает аккаунт, привязанный к способу входа, или создаёт новый.
+// Пустые поля профиля заполняются данными провайдера, изменённые пользователем не трогаются.
+func (r *Repository) FindOrCreateUser(ctx context.Context, identity domain.Identity) (domain.User, error) {
+	var res domain.User
+	err := r.MasterPool.BeginFunc(ctx, func(tx pgx.Tx) error {
+		var userID string
+		err := tx.QueryRow(ctx,
+			"SELECT user_id FROM user_identities WHERE provider = $1 AND provider_user_id = $2",
+			identity.Provider, identity.ProviderUserID,
+		).Scan(&userID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			var created bool
+			res, created, err = createIdentityUser(ctx, tx, identity)
+			if err != nil || created {
+				return err
+			}
+			userID = res.ID_user
+		} else if err != nil {
+			return err
+		}
+		res, err = scanUser(tx.QueryRow(ctx, `
+			UPDATE users
+			SET email = COALESCE(email, NULLIF($2, '')),
+				name = COALESCE(name, NULLIF($3, '')),
+				avatar_url = COALESCE(avatar_url, NULLIF($4, ''))
+			WHERE id = $1
+			RETURNING `+userColumns,
+			userID, identity.Email, identity.Name, identity.AvatarURL,
+		))
+		return err
+	})
+	if err != nil {
+		r.logger.Error("FindOrCreateUser failed",
+			zap.Error(err),
+			zap.String("provider", identity.Provider),
+			zap.String("provider_user_id", identity.ProviderUserID),
+		)
+		return domain.User{}, err
+	}
+	return res, nil
+}
+
+// createIdentityUser создаёт аккаунт для нового способа входа. При одновременном
+// первом входе вставка способа входа дожидается параллельной транзакции и возвращает
+// её аккаунт; свой аккаунт тогда удаляется, created = false.
+func createIdentityUser(ctx context.Context, tx pgx.Tx, identity domain.Identity) (user domain.User, created bool, err error) {
+	user, err = scanUser(tx.QueryRow(ctx, `
+		INSERT INTO users (email, name, avatar_url)
+		VALUES (NULLIF($1, ''), NULLIF($2, ''), NULLIF($3, ''))
+		RETURNING `+userColumns,
+		identity.Email, identity.Name, identity.AvatarURL,
+	))
+	if err != nil {
+		return domain.User{}, false, err
+	}
+	var owner string
+	err = tx.QueryRow(ctx, `
+		INSERT INTO user_identities (provider, provider_user_id, user_id)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (provider, provider_user_id) DO UPDATE SET provider = EXCLUDED.provider
+		RETURNING user_id`,
+		identity.Provider, identity.ProviderUserID, user.ID_user,
+	).Scan(&owner)
+	if err != nil {
+		return domain.User{}, false, err
+	}
+	if owner != user.ID_user {
+		_, err = tx.Exec(ctx, "DELETE FROM users WHERE id = $1", user.ID_user)
+		return domain.User{ID_user: owner}, false, err
+	}
+	_, err = createWorkspace(ctx, tx, personalWorkspace, user.ID_user)
+	return user, true, err
+}
+
+// GetUserByID читает с мастера: профиль запрашивают сразу после первого входа,
+// когда реплика может ещё не получить новый аккаунт
+func (r *Repository) GetUserByID(ctx context.Context, ID_user string) (domain.User, error) {
+	res, err := scanUser(r.MasterPool.QueryRow(ctx, "SELECT "+userColumns+" FROM users WHERE id = $1", ID_user))
+	if err != nil {
+		r.logger.Error("GetUserByID failed",
+			zap.Error(err),
+			zap.String("user_id", ID_user),
+		)
+		return domain.User{}, err
+	}
+	return res, nil
+}
+
+func (r *Repository) UpdateUser(ctx context.Context, req dto.PatchMeRequest) (domain.User, error) {
+	res, err := scanUser(r.MasterPool.QueryRow(ctx, `
+		UPDATE users
+		SET name = COALESCE($2, name),
+			avatar_url = COALESCE($3, avatar_url),
+			timezone = COALESCE($4, timezone),
+			updated_at = NOW()
+		WHERE id = $1
+		RETURNING `+userColumns,
+		req.ID_user, req.Name, req.Avatar_url, req.Timezone,
+	))
+	if err != nil {
+		r.logger.Error("UpdateUser failed",
+			zap.Error(err),
+			zap.String("user_id", req.ID_user),
+		)
+		return domain.User{}, err
+	}
+	return res, nil
+}
+
+// LinkIdentity привязывает ещё один способ входа к существующему аккаунту
+func (r *Repository) LinkIdentity(ctx context.Context, ID_user string, identity domain.Identity) error {
+	var owner string
+	err := r.MasterPool.QueryRow(ctx, `
+		INSERT INTO user_identities (provider, provider_user_id, user_id)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (provider, provider_user_id) DO UPDATE SET provider = EXCLUDED.provider
+		RETURNING user_id`,
+		identity.Provider, identity.ProviderUserID, ID_user,
+	).Scan(&owner)
+	if err != nil {
+		r.logger.Error("LinkIdentity failed",
+			zap.Error(err),
+			zap.String("user_id", ID_user),
+			zap.String("provider", identity.Provider),
+		)
+		return err
+	}
+	if owner != ID_user {
+		return ErrIdentityTaken
+	}
+	return nil
+}
+
+func (r *Repository) GetIdentities(ctx context.Context, ID_user string) ([]domain.UserIdentity, error) {
+	rows, err := r.SlavePool.Query(ctx, `
+		SELECT provider, provider_user_id, created_at
+		FROM user_identities
+		WHERE user_id = $1
+		ORDER BY created_at`,
+		ID_user,
+	)
+	if err != nil {
+		r.logger.Error("GetIdentities failed",
+			zap.Error(err),
+			zap.String("user_id", ID_user),
+		)
+		return nil, err
+	}
+	defer rows.Close()
+	res := []domain.UserIdentity{}
+	for rows.Next() {
+		var i domain.UserIdentity
+		if err := rows.Scan(&i.Provider, &i.Provider_user_id, &i.Created_at); err != nil {
+			r.logger.Error("GetIdentities failed in scaning",
+				zap.Error(err),
+				zap.String("user_id", ID_user),
+			)
+			return nil, err
+		}
+		res = append(res, i)
+	}
+	return res, rows.Err()
+}
+
+// UnlinkIdentity отвязывает провайдера, false — такой привязки нет.
+// Последний способ входа отвязать нельзя.
+func (r *Repository) UnlinkIdentity(ctx context.Context, ID_user string, provider string) (bool, error) {
+	var deleted int64
+	err := r.MasterPool.BeginFunc(ctx, func(tx pgx.Tx) error {
+		var total, linked int
+		err := tx.QueryRow(ctx, `
+			SELECT COUNT(*), COUNT(*) FILTER (WHERE provider = $2)
+			FROM (SELECT provider FROM user_identities WHERE user_id = $1 FOR UPDATE) i`,
+			ID_user, provider,
+		).Scan(&total, &linked)
+		if err != nil {
+			return err
+		}
+		if linked == 0 {
+			return nil
+		}
+		if total == linked {
+			return ErrLastIdentity
+		}
+		tag, err := tx.Exec(ctx, "DELETE FROM user_identities WHERE user_id = $1 AND provider = $2", ID_user, provider)
+		deleted = tag.RowsAffected()
+		return err
+	})
+	if err != nil && !errors.Is(err, ErrLastIdentity) {
+		r.logger.Error("UnlinkIdentity failed",
+			zap.Error(err),
+			zap.String("user_id", ID_user),
+			zap.String("provider", provider),
+		)
+	}
+	return deleted > 0, err
+}
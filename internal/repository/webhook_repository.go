@@ -0,0 +1,351 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"hexlet/internal/domain"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrWebhookNotFound  = errors.New("webhook not found")
+	ErrDeliveryNotFound = errors.New("delivery not found")
+)
+
+// сколько последних доставок показывается в журнале
+const webhookDeliveriesLimit = 100
+
+const webhookColumns = "id, user_id, url, events, scope, is_active, created_at"
+
+func scanWebhook(row pgx.Row) (domain.Webhook, error) {
+	var w domain.Webhook
+	err := row.Scan(&w.ID_webhook, &w.ID_user, &w.Url, &w.Events, &w.Scope, &w.Is_active, &w.Created_at)
+	return w, err
+}
+
+const deliveryColumns = "d.id, d.webhook_id, d.event_id, d.event, d.payload, d.status, d.attempts, d.next_attempt_at, d.response_code, d.last_error, d.created_at, d.delivered_at"
+
+func scanDelivery(row pgx.Row, dest ...any) (domain.WebhookDelivery, error) {
+	var d domain.WebhookDelivery
+	var payload []byte
+	err := row.Scan(append([]any{&d.ID_delivery, &d.ID_webhook, &d.ID_event, &d.Event, &payload, &d.Status, &d.Attempts,
+		&d.Next_attempt_at, &d.Response_code, &d.Last_error, &d.Created_at, &d.Delivered_at}, dest...)...)
+	d.Payload = payload
+	return d, err
+}
+
+// CreateWebhook сохраняет подписку, секрет шифруется
+func (r *Repository) CreateWebhook(ctx context.Context, ID_workspace int, hook domain.Webhook) (domain.Webhook, error) {
+	secret, err := r.keyring.Encrypt(hook.Secret)
+	if err != nil {
+		r.logger.Error("CreateWebhook failed in encrypting",
+			zap.Error(err),
+			zap.Int("workspace_id", ID_workspace),
+		)
+		return domain.Webhook{}, err
+	}
+	res, err := scanWebhook(r.MasterPool.QueryRow(ctx, `
+		INSERT INTO webhooks (workspace_id, user_id, url, secret, events, scope)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING `+webhookColumns,
+		ID_workspace, hook.ID_user, hook.Url, secret, hook.Events, hook.Scope,
+	))
+	if err != nil {
+		r.logger.Error("CreateWebhook failed",
+			zap.Error(err),
+			zap.Int("workspace_id", ID_workspace),
+		)
+		return domain.Webhook{}, err
+	}
+	res.Secret = hook.Secret
+	return res, nil
+}
+
+func (r *Repository) GetWebhooks(ctx context.Context, ID_workspace int) ([]domain.Webhook, error) {
+	rows, err := r.SlavePool.Query(ctx, `
+		SELECT `+webhookColumns+`
+		FROM webhooks
+		WHERE workspace_id = $1
+		ORDER BY id`,
+		ID_workspace,
+	)
+	if err != nil {
+		r.logger.Error("GetWebhooks failed",
+			zap.Error(err),
+			zap.Int("workspace_id", ID_workspace),
+		)
+		return nil, err
+	}
+	defer rows.Close()
+	res := []domain.Webhook{}
+	for rows.Next() {
+		w, err := scanWebhook(rows)
+		if err != nil {
+			r.logger.Error("GetWebhooks failed in scaning",
+				zap.Error(err),
+				zap.Int("workspace_id", ID_workspace),
+			)
+			return nil, err
+		}
+		res = append(res, w)
+	}
+	return res, rows.Err()
+}
+
+// UpdateWebhook меняет переданные поля, nil — оставить как есть
+func (r *Repository) UpdateWebhook(ctx context.Context, ID_webhook int, ID_workspace int, url *string, events []string, isActive *bool) (domain.Webhook, error) {
+	res, err := scanWebhook(r.MasterPool.QueryRow(ctx, `
+		UPDATE webhooks
+		SET url = COALESCE($3, url), events = COALESCE($4, events), is_active = COALESCE($5, is_active)
+		WHERE id = $1 AND workspace_id = $2
+		RETURNING `+webhookColumns,
+		ID_webhook, ID_workspace, url, events, isActive,
+	))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.Webhook{}, ErrWebhookNotFound
+	}
+	if err != nil {
+		r.logger.Error("UpdateWebhook failed",
+			zap.Error(err),
+			zap.Int("webhook_id", ID_webhook),
+		)
+		return domain.Webhook{}, err
+	}
+	return res, nil
+}
+
+func (r *Repository) DeleteWebhook(ctx context.Context, ID_webhook int, ID_workspace int) (bool, error) {
+	tag, err := r.MasterPool.Exec(ctx, "DELETE FROM webhooks WHERE id = $1 AND workspace_id = $2", ID_webhook, ID_workspace)
+	if err != nil {
+		r.logger.Error("DeleteWebhook failed",
+			zap.Error(err),
+			zap.Int("webhook_id", ID_webhook),
+		)
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// GetWebhookDeliveries — журнал доставок подписки, новые первыми
+func (r *Repository) GetWebhookDeliveries(ctx context.Context, ID_webhook int, ID_workspace int) ([]domain.WebhookDelivery, error) {
+	var found bool
+	err := r.SlavePool.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM webhooks WHERE id = $1 AND workspace_id = $2)", ID_webhook, ID_workspace).Scan(&found)
+	if err != nil {
+		r.logger.Error("GetWebhookDeliveries failed in webhook lookup",
+			zap.Error(err),
+			zap.Int("webhook_id", ID_webhook),
+		)
+		return nil, err
+	}
+	if !found {
+		return nil, ErrWebhookNotFound
+	}
+	rows, err := r.SlavePool.Query(ctx, `
+		SELECT `+deliveryColumns+`
+		FROM webhook_deliveries d
+		WHERE d.webhook_id = $1
+		ORDER BY d.id DESC
+		LIMIT $2`,
+		ID_webhook, webhookDeliveriesLimit,
+	)
+	if err != nil {
+		r.logger.Error("GetWebhookDeliveries failed",
+			zap.Error(err),
+			zap.Int("webhook_id", ID_webhook),
+		)
+		return nil, err
+	}
+	defer rows.Close()
+	res := []domain.WebhookDelivery{}
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			r.logger.Error("GetWebhookDeliveries failed in scaning",
+				zap.Error(err),
+				zap.Int("webhook_id", ID_webhook),
+			)
+			return nil, err
+		}
+		res = append(res, d)
+	}
+	return res, rows.Err()
+}
+
+// RedeliverWebhook ставит копию доставки в очередь; исходная запись журнала не меняется
+func (r *Repository) RedeliverWebhook(ctx context.Context, ID_webhook int, ID_delivery int, ID_workspace int) (domain.WebhookDelivery, error) {
+	res, err := scanDelivery(r.MasterPool.QueryRow(ctx, `
+		INSERT INTO webhook_deliveries AS d (webhook_id, event_id, event, payload)
+		SELECT src.webhook_id, src.event_id, src.event, src.payload
+		FROM webhook_deliveries src
+		JOIN webhooks w ON w.id = src.webhook_id
+		WHERE src.id = $1 AND src.webhook_id = $2 AND w.workspace_id = $3
+		RETURNING `+deliveryColumns,
+		ID_delivery, ID_webhook, ID_workspace,
+	))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.WebhookDelivery{}, ErrDeliveryNotFound
+	}
+	if err != nil {
+		r.logger.Error("RedeliverWebhook failed",
+			zap.Error(err),
+			zap.Int("delivery_id", ID_delivery),
+		)
+		return domain.WebhookDelivery{}, err
+	}
+	return res, nil
+}
+
+// EnqueueWebhookEvent создаёт доставки для активных подписок пространства на это событие.
+// Возвращает число подписчиков.
+func (r *Repository) EnqueueWebhookEvent(ctx context.Context, event domain.WebhookEvent) (int, error) {
+	if event.ID_event == "" {
+		event.ID_event = uuid.NewString()
+	}
+	payload, err := json.Marshal(domain.WebhookPayload{
+		ID_event:     event.ID_event,
+		Event:        event.Event,
+		ID_workspace: event.ID_workspace,
+		Created_at:   time.Now().UTC(),
+		Data:         event.Data,
+	})
+	if err != nil {
+		return 0, err
+	}
+	tag, err := r.MasterPool.Exec(ctx, `
+		INSERT INTO webhook_deliveries (webhook_id, event_id, event, payload)
+		SELECT w.id, $1, $2, $3
+		FROM webhooks w
+		WHERE w.workspace_id = $4
+		AND w.is_active
+		AND $2 = ANY(w.events)
+		AND (w.scope = 'workspace' OR w.user_id = COALESCE(NULLIF($5, ''), (SELECT user_id FROM posts WHERE id = $6)))`,
+		event.ID_event, event.Event, payload, event.ID_workspace, event.ID_author, event.ID_post,
+	)
+	if err != nil {
+		r.logger.Error("EnqueueWebhookEvent failed",
+			zap.Error(err),
+			zap.String("event", event.Event),
+			zap.Int("workspace_id", event.ID_workspace),
+		)
+		return 0, err
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+// ClaimWebhookDeliveries выбирает доставки, которым пора уходить, и откладывает их на lease,
+// чтобы другой экземпляр не взял их одновременно. Если процесс упадёт, доставка
+// повторится после lease. Доставки, секрет которых не расшифровывается (ключ убран
+// из KEYRING), сразу помечаются failed: повторы ничего не изменят.
+func (r *Repository) ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]domain.WebhookDelivery, error) {
+	rows, err := r.MasterPool.Query(ctx, `
+		UPDATE webhook_deliveries d
+		SET next_attempt_at = NOW() + $2 * INTERVAL '1 second'
+		FROM webhooks w
+		WHERE w.id = d.webhook_id
+		AND d.id IN (
+			SELECT q.id FROM webhook_deliveries q
+			JOIN webhooks qw ON qw.id = q.webhook_id
+			WHERE q.status = 'pending' AND q.next_attempt_at <= NOW() AND qw.is_active
+			ORDER BY q.next_attempt_at
+			LIMIT $1
+			FOR UPDATE OF q SKIP LOCKED
+		)
+		RETURNING `+deliveryColumns+`, w.url, w.secret`,
+		limit, lease.Seconds(),
+	)
+	if err != nil {
+		r.logger.Error("ClaimWebhookDeliveries failed",
+			zap.Error(err),
+		)
+		return nil, err
+	}
+	defer rows.Close()
+	res := []domain.WebhookDelivery{}
+	var broken []int
+	for rows.Next() {
+		var url, secret string
+		d, err := scanDelivery(rows, &url, &secret)
+		if err != nil {
+			r.logger.Error("ClaimWebhookDeliveries failed in scaning",
+				zap.Error(err),
+			)
+			return nil, err
+		}
+		d.Url = url
+		if d.Secret, err = r.keyring.Decrypt(secret); err != nil {
+			r.logger.Error("ClaimWebhookDeliveries failed in decrypting",
+				zap.Error(err),
+				zap.Int("webhook_id", d.ID_webhook),
+				zap.Int("delivery_id", d.ID_delivery),
+			)
+			broken = append(broken, d.ID_delivery)
+			continue
+		}
+		res = append(res, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+	if len(broken) > 0 {
+		_, err := r.MasterPool.Exec(ctx, `
+			UPDATE webhook_deliveries
+			SET status = 'failed', attempts = attempts + 1, next_attempt_at = NULL,
+				response_code = NULL, last_error = 'cannot decrypt webhook secret'
+			WHERE id = ANY($1)`,
+			broken,
+		)
+		if err != nil {
+			r.logger.Error("ClaimWebhookDeliveries failed in marking undecryptable deliveries",
+				zap.Error(err),
+			)
+		}
+	}
+	return res, nil
+}
+
+// RecordWebhookAttempt сохраняет результат попытки: статус, код ответа, ошибку и время следующей попытки
+func (r *Repository) RecordWebhookAttempt(ctx context.Context, d domain.WebhookDelivery) error {
+	_, err := r.MasterPool.Exec(ctx, `
+		UPDATE webhook_deliveries
+		SET status = $2, attempts = $3, next_attempt_at = $4, response_code = $5, last_error = $6,
+			delivered_at = CASE WHEN $2 = 'delivered' THEN NOW() END
+		WHERE id = $1`,
+		d.ID_delivery, d.Status, d.Attempts, d.Next_attempt_at, d.Response_code, d.Last_error,
+	)
+	if err != nil {
+		r.logger.Error("RecordWebhookAttempt failed",
+			zap.Error(err),
+			zap.Int("delivery_id", d.ID_delivery),
+		)
+	}
+	return err
+}
+
+// GetDestinationEvent — данные публикации для событий destination.*
+func (r *Repository) GetDestinationEvent(ctx context.Context, ID_destination int) (domain.DestinationEventData, error) {
+	var res domain.DestinationEventData
+	err := r.MasterPool.QueryRow(ctx, `
+		SELECT d.id, d.post_id, p.user_id, d.workspace_id, d.platform_id, pl.platform_name, d.status,
+			d.revision, d.remote_id, d.published_at, d.error_message
+		FROM post_destinations d
+		JOIN posts p ON p.id = d.post_id
+		JOIN platforms pl ON pl.id = d.platform_id
+		WHERE d.id = $1`,
+		ID_destination,
+	).Scan(&res.ID_destination, &res.ID_post, &res.ID_user, &res.ID_workspace, &res.ID_platform, &res.PlatformName, &res.Status,
+		&res.Revision, &res.Remote_id, &res.Published_at, &res.ErrorMessage)
+	if err != nil {
+		r.logger.Error("GetDestinationEvent failed",
+			zap.Error(err),
+			zap.Int("post_destinations_id", ID_destination),
+		)
+		return domain.DestinationEventData{}, err
+	}
+	return res, nil
+}
@@ -0,0 +1,319 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"hexlet/internal/domain"
+
+	"github.com/jackc/pgx/v4"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrNotMember         = errors.New("user is not a member of the workspace")
+	ErrLastOwner         = errors.New("workspace must have at least one owner")
+	ErrInvitationInvalid = errors.New("invitation is invalid or expired")
+	ErrInvitationEmail   = errors.New("invitation was sent to another email")
+)
+
+// Личное пространство создаётся каждому новому пользователю
+const personalWorkspace = "Personal"
+
+// createWorkspace создаёт пространство, пользователь становится его владельцем
+func createWorkspace(ctx context.Context, tx pgx.Tx, name string, ID_user string) (domain.Workspace, error) {
+	res := domain.Workspace{Name: name, Role: domain.RoleOwner}
+	err := tx.QueryRow(ctx,
+		"INSERT INTO workspaces (name) VALUES ($1) RETURNING id, created_at",
+		name,
+	).Scan(&res.ID_workspace, &res.Created_at)
+	if err != nil {
+		return domain.Workspace{}, err
+	}
+	_, err = tx.Exec(ctx,
+		"INSERT INTO workspace_members (workspace_id, user_id, role) VALUES ($1, $2, $3)",
+		res.ID_workspace, ID_user, domain.RoleOwner,
+	)
+	return res, err
+}
+
+func (r *Repository) CreateWorkspace(ctx context.Context, name string, ID_user string) (domain.Workspace, error) {
+	var res domain.Workspace
+	err := r.MasterPool.BeginFunc(ctx, func(tx pgx.Tx) error {
+		var err error
+		res, err = createWorkspace(ctx, tx, name, ID_user)
+		return err
+	})
+	if err != nil {
+		r.logger.Error("CreateWorkspace failed",
+			zap.Error(err),
+			zap.String("user_id", ID_user),
+		)
+		return domain.Workspace{}, err
+	}
+	return res, nil
+}
+
+func (r *Repository) GetWorkspaces(ctx context.Context, ID_user string) ([]domain.Workspace, error) {
+	rows, err := r.SlavePool.Query(ctx, `
+		SELECT w.id, w.name, m.role, w.required_approvals, w.created_at
+		FROM workspace_members m
+		JOIN workspaces w ON w.id = m.workspace_id
+		WHERE m.user_id = $1
+		ORDER BY m.created_at, w.id`,
+		ID_user,
+	)
+	if err != nil {
+		r.logger.Error("GetWorkspaces failed",
+			zap.Error(err),
+			zap.String("user_id", ID_user),
+		)
+		return nil, err
+	}
+	defer rows.Close()
+	res := []domain.Workspace{}
+	for rows.Next() {
+		var w domain.Workspace
+		if err := rows.Scan(&w.ID_workspace, &w.Name, &w.Role, &w.Required_approvals, &w.Created_at); err != nil {
+			r.logger.Error("GetWorkspaces failed in scaning",
+				zap.Error(err),
+				zap.String("user_id", ID_user),
+			)
+			return nil, err
+		}
+		res = append(res, w)
+	}
+	return res, rows.Err()
+}
+
+// GetDefaultWorkspace — пространство, в которое пользователь вступил первым (обычно личное)
+func (r *Repository) GetDefaultWorkspace(ctx context.Context, ID_user string) (int, error) {
+	var ID_workspace int
+	err := r.SlavePool.QueryRow(ctx, `
+		SELECT workspace_id FROM workspace_members
+		WHERE user_id = $1
+		ORDER BY created_at, workspace_id
+		LIMIT 1`,
+		ID_user,
+	).Scan(&ID_workspace)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, ErrNotMember
+	}
+	if err != nil {
+		r.logger.Error("GetDefaultWorkspace failed",
+			zap.Error(err),
+			zap.String("user_id", ID_user),
+		)
+		return 0, err
+	}
+	return ID_workspace, nil
+}
+
+// GetMemberRole читает с мастера: снятая роль должна действовать сразу
+func (r *Repository) GetMemberRole(ctx context.Context, ID_workspace int, ID_user string) (string, error) {
+	var role string
+	err := r.MasterPool.QueryRow(ctx,
+		"SELECT role FROM workspace_members WHERE workspace_id = $1 AND user_id = $2",
+		ID_workspace, ID_user,
+	).Scan(&role)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", ErrNotMember
+	}
+	if err != nil {
+		r.logger.Error("GetMemberRole failed",
+			zap.Error(err),
+			zap.Int("workspace_id", ID_workspace),
+			zap.String("user_id", ID_user),
+		)
+		return "", err
+	}
+	return role, nil
+}
+
+func (r *Repository) GetMembers(ctx context.Context, ID_workspace int) ([]domain.WorkspaceMember, error) {
+	rows, err := r.SlavePool.Query(ctx, `
+		SELECT m.workspace_id, m.user_id, u.email, u.name, m.role, m.created_at
+		FROM workspace_members m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.workspace_id = $1
+		ORDER BY m.created_at`,
+		ID_workspace,
+	)
+	if err != nil {
+		r.logger.Error("GetMembers failed",
+			zap.Error(err),
+			zap.Int("workspace_id", ID_workspace),
+		)
+		return nil, err
+	}
+	defer rows.Close()
+	res := []domain.WorkspaceMember{}
+	for rows.Next() {
+		var m domain.WorkspaceMember
+		if err := rows.Scan(&m.ID_workspace, &m.ID_user, &m.Email, &m.Name, &m.Role, &m.Created_at); err != nil {
+			r.logger.Error("GetMembers failed in scaning",
+				zap.Error(err),
+				zap.Int("workspace_id", ID_workspace),
+			)
+			return nil, err
+		}
+		res = append(res, m)
+	}
+	return res, rows.Err()
+}
+
+// ensureOwnerLeft проверяет, что после изменения в пространстве останется владелец
+func ensureOwnerLeft(ctx context.Context, tx pgx.Tx, ID_workspace int) error {
+	var owners int
+	err := tx.QueryRow(ctx,
+		"SELECT COUNT(*) FROM workspace_members WHERE workspace_id = $1 AND role = $2",
+		ID_workspace, domain.RoleOwner,
+	).Scan(&owners)
+	if err != nil {
+		return err
+	}
+	if owners == 0 {
+		return ErrLastOwner
+	}
+	return nil
+}
+
+// UpdateMemberRole меняет роль участника, false — такого участника нет
+func (r *Repository) UpdateMemberRole(ctx context.Context, ID_workspace int, ID_user string, role string) (bool, error) {
+	updated := false
+	err := r.MasterPool.BeginFunc(ctx, func(tx pgx.Tx) error {
+		// блокируем участников, чтобы два параллельных понижения не оставили пространство без владельца
+		_, err := tx.Exec(ctx, "SELECT 1 FROM workspace_members WHERE workspace_id = $1 FOR UPDATE", ID_workspace)
+		if err != nil {
+			return err
+		}
+		tag, err := tx.Exec(ctx,
+			"UPDATE workspace_members SET role = $3 WHERE workspace_id = $1 AND user_id = $2",
+			ID_workspace, ID_user, role,
+		)
+		if err != nil {
+			return err
+		}
+		updated = tag.RowsAffected() > 0
+		return ensureOwnerLeft(ctx, tx, ID_workspace)
+	})
+	if err != nil && !errors.Is(err, ErrLastOwner) {
+		r.logger.Error("UpdateMemberRole failed",
+			zap.Error(err),
+			zap.Int("workspace_id", ID_workspace),
+			zap.String("user_id", ID_user),
+		)
+	}
+	if err != nil {
+		return false, err
+	}
+	return updated, nil
+}
+
+// RemoveMember исключает участника, false — такого участника нет
+func (r *Repository) RemoveMember(ctx context.Context, ID_workspace int, ID_user string) (bool, error) {
+	removed := false
+	err := r.MasterPool.BeginFunc(ctx, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, "SELECT 1 FROM workspace_members WHERE workspace_id = $1 FOR UPDATE", ID_workspace)
+		if err != nil {
+			return err
+		}
+		tag, err := tx.Exec(ctx,
+			"DELETE FROM workspace_members WHERE workspace_id = $1 AND user_id = $2",
+			ID_workspace, ID_user,
+		)
+		if err != nil {
+			return err
+		}
+		removed = tag.RowsAffected() > 0
+		return ensureOwnerLeft(ctx, tx, ID_workspace)
+	})
+	if err != nil && !errors.Is(err, ErrLastOwner) {
+		r.logger.Error("RemoveMember failed",
+			zap.Error(err),
+			zap.Int("workspace_id", ID_workspace),
+			zap.String("user_id", ID_user),
+		)
+	}
+	if err != nil {
+		return false, err
+	}
+	return removed, nil
+}
+
+func (r *Repository) CreateInvitation(ctx context.Context, inv domain.Invitation, tokenHash string) error {
+	_, err := r.MasterPool.Exec(ctx, `
+		INSERT INTO workspace_invitations (token_hash, workspace_id, email, role, invited_by, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)`,
+		tokenHash, inv.ID_workspace, inv.Email, inv.Role, inv.Invited_by, inv.Expires_at,
+	)
+	if err != nil {
+		r.logger.Error("CreateInvitation failed",
+			zap.Error(err),
+			zap.Int("workspace_id", inv.ID_workspace),
+		)
+		return err
+	}
+	return nil
+}
+
+// AcceptInvitation добавляет пользователя в пространство по токену из письма.
+// Принять приглашение может только владелец адреса, на который оно отправлено.
+// Если пользователь уже участник, его роль не меняется.
+func (r *Repository) AcceptInvitation(ctx context.Context, tokenHash string, ID_user string) (domain.Workspace, error) {
+	var res domain.Workspace
+	err := r.MasterPool.BeginFunc(ctx, func(tx pgx.Tx) error {
+		var role string
+		var sameEmail bool
+		err := tx.QueryRow(ctx, `
+			SELECT i.workspace_id, i.role,
+				COALESCE(LOWER(u.email) = LOWER(i.email), FALSE)
+			FROM workspace_invitations i
+			LEFT JOIN users u ON u.id = $2
+			WHERE i.token_hash = $1 AND i.accepted_at IS NULL AND i.expires_at > NOW()
+			FOR UPDATE OF i`,
+			tokenHash, ID_user,
+		).Scan(&res.ID_workspace, &role, &sameEmail)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrInvitationInvalid
+		}
+		if err != nil {
+			return err
+		}
+		if !sameEmail {
+			return ErrInvitationEmail
+		}
+		_, err = tx.Exec(ctx,
+			"UPDATE workspace_invitations SET accepted_at = NOW() WHERE token_hash = $1",
+			tokenHash,
+		)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, `
+			INSERT INTO workspace_members (workspace_id, user_id, role)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (workspace_id, user_id) DO NOTHING`,
+			res.ID_workspace, ID_user, role,
+		)
+		if err != nil {
+			return err
+		}
+		return tx.QueryRow(ctx, `
+			SELECT w.name, m.role, w.required_approvals, w.created_at
+			FROM workspaces w
+			JOIN workspace_members m ON m.workspace_id = w.id AND m.user_id = $2
+			WHERE w.id = $1`,
+			res.ID_workspace, ID_user,
+		).Scan(&res.Name, &res.Role, &res.Required_approvals, &res.Created_at)
+	})
+	if err != nil && !errors.Is(err, ErrInvitationInvalid) && !errors.Is(err, ErrInvitationEmail) {
+		r.logger.Error("AcceptInvitation failed",
+			zap.Error(err),
+			zap.String("user_id", ID_user),
+		)
+	}
+	if err != nil {
+		return domain.Workspace{}, err
+	}
+	return res, nil
+}
@@ -0,0 +1,218 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Формат зашифрованного значения:
+//
+//	enc:v1:<key id>:<base64 data key, зашифрованный мастер-ключом>:<base64 nonce+ciphertext>
+//
+// Каждое значение шифруется собственным data key (AES-256-GCM), а сам data key
+// шифруется мастер-ключом (envelope encryption). При ротации мастер-ключа
+// достаточно перешифровать data key, не трогая сами данные.
+const (
+	prefix  = "enc"
+	version = "v1"
+	keySize = 32
+)
+
+var (
+	ErrUnknownKey    = errors.New("secrets: unknown master key id")
+	ErrMalformed     = errors.New("secrets: malformed encrypted value")
+	ErrNoActiveKey   = errors.New("secrets: active master key is not configured")
+	ErrInvalidKeyLen = errors.New("secrets: master key must be 32 bytes")
+)
+
+type Keyring struct {
+	activeID string
+	keys     map[string][]byte
+}
+
+func NewKeyring(activeID string, keys map[string][]byte) (*Keyring, error) {
+	for id, k := range keys {
+		if len(k) != keySize {
+			return nil, fmt.Errorf("%w: key %q", ErrInvalidKeyLen, id)
+		}
+	}
+	if _, ok := keys[activeID]; !ok {
+		return nil, ErrNoActiveKey
+	}
+	return &Keyring{activeID: activeID, keys: keys}, nil
+}
+
+// ParseKeys разбирает строку вида "k2:<base64>,k1:<base64>".
+// Первый ключ в списке считается активным.
+func ParseKeys(spec string) (string, map[string][]byte, error) {
+	keys := make(map[string][]byte)
+	activeID := ""
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		id, encoded, ok := strings.Cut(part, ":")
+		if !ok || id == "" {
+			return "", nil, fmt.Errorf("secrets: invalid key entry %q", part)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return "", nil, fmt.Errorf("secrets: key %q is not valid base64: %w", id, err)
+		}
+		keys[id] = key
+		if activeID == "" {
+			activeID = id
+		}
+	}
+	if activeID == "" {
+		return "", nil, ErrNoActiveKey
+	}
+	return activeID, keys, nil
+}
+
+func (k *Keyring) ActiveKeyID() string {
+	return k.activeID
+}
+
+// IsEncrypted сообщает, зашифровано ли значение (старые записи хранятся открытым текстом).
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix+":"+version+":")
+}
+
+func (k *Keyring) Encrypt(plaintext string) (string, error) {
+	dataKey := make([]byte, keySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", err
+	}
+	wrapped, err := seal(k.keys[k.activeID], dataKey)
+	if err != nil {
+		return "", err
+	}
+	ciphertext, err := seal(dataKey, []byte(plaintext))
+	if err != nil {
+		return "", err
+	}
+	return strings.Join([]string{
+		prefix,
+		version,
+		k.activeID,
+		base64.StdEncoding.EncodeToString(wrapped),
+		base64.StdEncoding.EncodeToString(ciphertext),
+	}, ":"), nil
+}
+
+func (k *Keyring) Decrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	keyID, wrapped, ciphertext, err := split(value)
+	if err != nil {
+		return "", err
+	}
+	master, ok := k.keys[keyID]
+	if !ok {
+		return "", fmt.Errorf("%w: %q", ErrUnknownKey, keyID)
+	}
+	dataKey, err := open(master, wrapped)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := open(dataKey, ciphertext)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// Rewrap перешифровывает data key активным мастер-ключом.
+// Открытые значения шифруются, уже актуальные возвращаются без изменений.
+func (k *Keyring) Rewrap(value string) (string, bool, error) {
+	if !IsEncrypted(value) {
+		res, err := k.Encrypt(value)
+		return res, err == nil, err
+	}
+	keyID, wrapped, ciphertext, err := split(value)
+	if err != nil {
+		return "", false, err
+	}
+	if keyID == k.activeID {
+		return value, false, nil
+	}
+	master, ok := k.keys[keyID]
+	if !ok {
+		return "", false, fmt.Errorf("%w: %q", ErrUnknownKey, keyID)
+	}
+	dataKey, err := open(master, wrapped)
+	if err != nil {
+		return "", false, err
+	}
+	rewrapped, err := seal(k.keys[k.activeID], dataKey)
+	if err != nil {
+		return "", false, err
+	}
+	return strings.Join([]string{
+		prefix,
+		version,
+		k.activeID,
+		base64.StdEncoding.EncodeToString(rewrapped),
+		base64.StdEncoding.EncodeToString(ciphertext),
+	}, ":"), true, nil
+}
+
+func split(value string) (string, []byte, []byte, error) {
+	parts := strings.Split(value, ":")
+	if len(parts) != 5 {
+		return "", nil, nil, ErrMalformed
+	}
+	wrapped, err := base64.StdEncoding.DecodeString(parts[3])
+	if err != nil {
+		return "", nil, nil, ErrMalformed
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return "", nil, nil, ErrMalformed
+	}
+	return parts[2], wrapped, ciphertext, nil
+}
+
+func seal(key, plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func open(key, data []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, ErrMalformed
+	}
+	nonce, ciphertext := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("secrets: decryption failed: %w", err)
+	}
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
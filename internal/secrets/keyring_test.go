@@ -0,0 +1,87 @@
+package secrets
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	oldKey = []byte("0123456789abcdef0123456789abcdef")
+	newKey = []byte("fedcba9876543210fedcba9876543210")
+)
+
+func TestEncryptDecrypt(t *testing.T) {
+	k, err := NewKeyring("k1", map[string][]byte{"k1": oldKey})
+	require.NoError(t, err)
+
+	enc, err := k.Encrypt("bot-token")
+	require.NoError(t, err)
+	assert.True(t, IsEncrypted(enc))
+	assert.NotContains(t, enc, "bot-token")
+
+	dec, err := k.Decrypt(enc)
+	require.NoError(t, err)
+	assert.Equal(t, "bot-token", dec)
+}
+
+func TestDecrypt_Plaintext(t *testing.T) {
+	k, err := NewKeyring("k1", map[string][]byte{"k1": oldKey})
+	require.NoError(t, err)
+
+	dec, err := k.Decrypt("legacy-token")
+	require.NoError(t, err)
+	assert.Equal(t, "legacy-token", dec)
+}
+
+func TestDecrypt_UnknownKey(t *testing.T) {
+	k1, _ := NewKeyring("k1", map[string][]byte{"k1": oldKey})
+	k2, _ := NewKeyring("k2", map[string][]byte{"k2": newKey})
+
+	enc, err := k1.Encrypt("bot-token")
+	require.NoError(t, err)
+
+	_, err = k2.Decrypt(enc)
+	assert.ErrorIs(t, err, ErrUnknownKey)
+}
+
+func TestRewrap(t *testing.T) {
+	k1, _ := NewKeyring("k1", map[string][]byte{"k1": oldKey})
+	enc, err := k1.Encrypt("bot-token")
+	require.NoError(t, err)
+
+	rotated, err := NewKeyring("k2", map[string][]byte{"k1": oldKey, "k2": newKey})
+	require.NoError(t, err)
+
+	rewrapped, changed, err := rotated.Rewrap(enc)
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.True(t, strings.HasPrefix(rewrapped, "enc:v1:k2:"))
+
+	_, changed, err = rotated.Rewrap(rewrapped)
+	require.NoError(t, err)
+	assert.False(t, changed)
+
+	onlyNew, _ := NewKeyring("k2", map[string][]byte{"k2": newKey})
+	dec, err := onlyNew.Decrypt(rewrapped)
+	require.NoError(t, err)
+	assert.Equal(t, "bot-token", dec)
+}
+
+func TestParseKeys(t *testing.T) {
+	active, keys, err := ParseKeys("k2:ZmVkY2JhOTg3NjU0MzIxMGZlZGNiYTk4NzY1NDMyMTA=, k1:MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=")
+	require.NoError(t, err)
+	assert.Equal(t, "k2", active)
+	assert.Equal(t, newKey, keys["k2"])
+	assert.Equal(t, oldKey, keys["k1"])
+
+	_, _, err = ParseKeys("")
+	assert.ErrorIs(t, err, ErrNoActiveKey)
+}
+
+func TestMask(t *testing.T) {
+	assert.Equal(t, "123***xyz", Mask("1234567890:ABCxyz"))
+	assert.Equal(t, "***", Mask("short"))
+}
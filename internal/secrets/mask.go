@@ -0,0 +1,14 @@
+package secrets
+
+import "strings"
+
+const visibleChars = 3
+
+// Mask скрывает середину секрета: "1234567890:ABCxyz" -> "123***xyz".
+// Короткие значения скрываются полностью.
+func Mask(value string) string {
+	if len(value) <= visibleChars*3 {
+		return strings.Repeat("*", 3)
+	}
+	return value[:visibleChars] + "***" + value[len(value)-visibleChars:]
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"hexlet/internal/domain"
+	"hexlet/internal/repository"
+	"hexlet/internal/verification"
+)
+
+// HealthMonitor периодически перепроверяет учётные данные активных платформ.
+// После degradeAfter подряд ошибок авторизации платформа помечается degraded,
+// после deactivateAfter — отключается, её публикации откладываются (held).
+// Отключённые платформы тоже проверяются и включаются после успешной проверки.
+type HealthMonitor struct {
+	repo            *repository.Repository
+	verifier        verification.Verifier
+	interval        time.Duration
+	degradeAfter    int
+	deactivateAfter int
+}
+
+func NewHealthMonitor(
+	repo *repository.Repository,
+	verifier verification.Verifier,
+	interval time.Duration,
+	degradeAfter int,
+	deactivateAfter int,
+) *HealthMonitor {
+	return &HealthMonitor{
+		repo:            repo,
+		verifier:        verifier,
+		interval:        interval,
+		degradeAfter:    degradeAfter,
+		deactivateAfter: deactivateAfter,
+	}
+}
+
+func (m *HealthMonitor) Start(ctx context.Context) {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	log.Printf("Health monitor started with interval: %v", m.interval)
+
+	m.checkPlatforms(ctx)
+	for {
+		select {
+		case <-ticker.C:
+			m.checkPlatforms(ctx)
+		case <-ctx.Done():
+			log.Println("Health monitor stopped")
+			return
+		}
+	}
+}
+
+func (m *HealthMonitor) checkPlatforms(ctx context.Context) {
+	platforms, err := m.repo.GetPlatformsToCheck(ctx)
+	if err != nil {
+		log.Printf("Error getting platforms to check: %v", err)
+		return
+	}
+	for _, p := range platforms {
+		if err := m.checkPlatform(ctx, p); err != nil {
+			log.Printf("Error checking platform %d: %v", p.ID, err)
+		}
+	}
+}
+
+func (m *HealthMonitor) checkPlatform(ctx context.Context, p domain.PlatformSQL) error {
+	report := m.verifier.Verify(ctx, p.PlatformName, p.Config)
+	previous, next, err := m.repo.RecordPlatformHealth(ctx, p, report, m.degradeAfter, m.deactivateAfter)
+	if errors.Is(err, repository.ErrPlatformChanged) {
+		// проверены старые учётные данные, новые проверит следующий проход
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if next.HealthStatus == previous {
+		return nil
+	}
+	log.Printf("Platform %d health: %s -> %s", p.ID, previous, next.HealthStatus)
+
+	switch next.HealthStatus {
+	case domain.HealthDegraded:
+		return m.notify(ctx, p, domain.NotificationPlatformDegraded,
+			fmt.Sprintf("%s platform %d failed authorization %d time(s), check its credentials", p.PlatformName, p.ID, next.AuthFailures))
+	case domain.HealthInactive:
+		held, err := m.repo.HoldDestinations(ctx, p.ID)
+		if err != nil {
+			return err
+		}
+		return m.notify(ctx, p, domain.NotificationPlatformDeactivated,
+			fmt.Sprintf("%s platform %d was deactivated after %d failed authorizations, %d scheduled publication(s) are on hold until its credentials are fixed", p.PlatformName, p.ID, next.AuthFailures, held))
+	}
+	return nil
+}
+
+func (m *HealthMonitor) notify(ctx context.Context, p domain.PlatformSQL, kind string, message string) error {
+	platformID := p.ID
+	return m.repo.CreateNotification(ctx, domain.Notification{
+		ID_user:     p.UserID,
+		Kind:        kind,
+		Message:     message,
+		ID_platform: &platformID,
+	})
+}
@@ -0,0 +1,231 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"hexlet/internal/domain"
+	"hexlet/internal/mailer"
+	"hexlet/internal/repository"
+	"hexlet/internal/statusfeed"
+)
+
+// TelegramNotifier отправляет уведомления в чат пользователя, если он привязан;
+// failed — неудачные и зависшие публикации для кнопок повтора
+type TelegramNotifier interface {
+	Notify(ctx context.Context, ID_user string, text string, failed []domain.Notification) error
+}
+
+// NotificationDispatcher рассылает уведомления по каналам владельца (email, Telegram, webhook)
+// по его настройкам: сразу с окном пакетирования или сводкой раз в час / раз в день.
+// Заодно создаёт уведомления о публикациях, зависших в processing дольше stuckAfter.
+// Отправка не повторяется: уведомление в любом случае остаётся в GET /notifications.
+// Кроме тика рассылка запускается сбоем публикации из hub, чтобы уведомления
+// без окна пакетирования приходили сразу.
+type NotificationDispatcher struct {
+	repo       *repository.Repository
+	mailer     mailer.Mailer
+	telegram   TelegramNotifier
+	hub        *statusfeed.Hub
+	interval   time.Duration
+	stuckAfter time.Duration
+	users      int
+}
+
+// telegram nil — канал недоступен (бот не настроен)
+func NewNotificationDispatcher(
+	repo *repository.Repository,
+	mail mailer.Mailer,
+	telegram TelegramNotifier,
+	hub *statusfeed.Hub,
+	interval time.Duration,
+	stuckAfter time.Duration,
+	users int,
+) *NotificationDispatcher {
+	return &NotificationDispatcher{
+		repo:       repo,
+		mailer:     mail,
+		telegram:   telegram,
+		hub:        hub,
+		interval:   interval,
+		stuckAfter: stuckAfter,
+		users:      users,
+	}
+}
+
+func (d *NotificationDispatcher) Start(ctx context.Context) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	log.Printf("Notification dispatcher started with interval: %v", d.interval)
+
+	// подписка на hub восстанавливается, если рассылка не успевала читать события
+	for ctx.Err() == nil {
+		events, cancel := d.hub.SubscribeAll()
+		d.loop(ctx, ticker.C, events)
+		cancel()
+	}
+	log.Println("Notification dispatcher stopped")
+}
+
+func (d *NotificationDispatcher) loop(ctx context.Context, tick <-chan time.Time, events <-chan domain.StatusEvent) {
+	for {
+		select {
+		case <-tick:
+			d.dispatch(ctx)
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+			if e.Status == "failed" {
+				d.dispatch(ctx)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (d *NotificationDispatcher) dispatch(ctx context.Context) {
+	stuck, err := d.repo.NotifyStuckDestinations(ctx, d.stuckAfter)
+	if err != nil {
+		log.Printf("Error checking stuck publications: %v", err)
+	} else if stuck > 0 {
+		log.Printf("Found %d publication(s) stuck in processing", stuck)
+	}
+	batches, err := d.repo.GetPendingNotifications(ctx, d.users)
+	if err != nil {
+		log.Printf("Error getting pending notifications: %v", err)
+		return
+	}
+	now := time.Now()
+	for _, b := range batches {
+		if now.Before(dueAt(b)) {
+			continue
+		}
+		if err := d.send(ctx, b); err != nil {
+			log.Printf("Error sending notifications to user %s: %v", b.ID_user, err)
+		}
+	}
+}
+
+func (d *NotificationDispatcher) send(ctx context.Context, b domain.NotificationBatch) error {
+	ids := make([]int, 0, len(b.Items))
+	for _, n := range b.Items {
+		ids = append(ids, n.ID_notification)
+	}
+	claimed, err := d.repo.ClaimNotifications(ctx, b.ID_user, ids)
+	if err != nil {
+		return err
+	}
+	items := wantedNotifications(b, claimed)
+	if len(items) == 0 {
+		return nil
+	}
+	var errs []error
+	if b.Settings.Email && b.Email != nil && d.mailer != nil {
+		errs = append(errs, d.mailer.Send(ctx, notificationEmail(*b.Email, items)))
+	}
+	if b.Settings.Telegram && d.telegram != nil {
+		errs = append(errs, d.telegram.Notify(ctx, b.ID_user, notificationText(items), failedPublications(items)))
+	}
+	if b.Settings.Webhook {
+		for _, n := range items {
+			if n.ID_workspace == nil {
+				continue
+			}
+			event := domain.WebhookEvent{
+				Event:        domain.EventNotificationCreated,
+				ID_workspace: *n.ID_workspace,
+				ID_author:    n.ID_user,
+				Data:         n,
+			}
+			if n.ID_post != nil {
+				event.ID_post = *n.ID_post
+			}
+			_, err := d.repo.EnqueueWebhookEvent(ctx, event)
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// dueAt — когда рассылать накопленные уведомления пользователя.
+// Отсчёт идёт от самого раннего неразосланного уведомления.
+// Тот же срок считает GetPendingNotifications при выборе пользователей.
+func dueAt(b domain.NotificationBatch) time.Time {
+	loc, err := time.LoadLocation(b.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	oldest := b.Items[0].Created_at.In(loc)
+	switch b.Settings.Delivery {
+	case domain.NotifyHourly:
+		return time.Date(oldest.Year(), oldest.Month(), oldest.Day(), oldest.Hour()+1, 0, 0, 0, loc)
+	case domain.NotifyDaily:
+		digest := time.Date(oldest.Year(), oldest.Month(), oldest.Day(), b.Settings.Digest_hour, 0, 0, 0, loc)
+		if digest.Before(oldest) {
+			digest = digest.AddDate(0, 0, 1)
+		}
+		return digest
+	default:
+		return oldest.Add(time.Duration(b.Settings.Batch_minutes) * time.Minute)
+	}
+}
+
+// wantedNotifications — забранные этим экземпляром уведомления тех видов, что выбрал пользователь
+func wantedNotifications(b domain.NotificationBatch, claimed []int) []domain.Notification {
+	ok := make(map[int]bool, len(claimed))
+	for _, id := range claimed {
+		ok[id] = true
+	}
+	var res []domain.Notification
+	for _, n := range b.Items {
+		if ok[n.ID_notification] && b.Settings.Wants(n.Kind) {
+			res = append(res, n)
+		}
+	}
+	return res
+}
+
+func failedPublications(items []domain.Notification) []domain.Notification {
+	var res []domain.Notification
+	for _, n := range items {
+		retryable := n.Kind == domain.NotificationPublicationFailed || n.Kind == domain.NotificationPublicationStuck
+		if retryable && n.ID_destination != nil {
+			res = append(res, n)
+		}
+	}
+	return res
+}
+
+func notificationText(items []domain.Notification) string {
+	if len(items) == 1 {
+		return items[0].Message
+	}
+	lines := make([]string, 0, len(items))
+	for _, n := range items {
+		lines = append(lines, "• "+n.Message)
+	}
+	return fmt.Sprintf("%d notifications:\n\n%s", len(items), strings.Join(lines, "\n"))
+}
+
+func notificationEmail(to string, items []domain.Notification) mailer.Message {
+	subject := "Уведомление автопостинга"
+	if len(items) > 1 {
+		subject = fmt.Sprintf("Уведомления автопостинга (%d)", len(items))
+	}
+	lines := make([]string, 0, len(items))
+	for _, n := range items {
+		lines = append(lines, n.Created_at.UTC().Format("2006-01-02 15:04 UTC")+"  "+n.Message)
+	}
+	return mailer.Message{
+		To:      to,
+		Subject: subject,
+		Body:    strings.Join(lines, "\n") + "\n\nКаналы и расписание уведомлений настраиваются в PUT /me/notification-settings.",
+	}
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"hexlet/internal/domain"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func batchAt(created time.Time, settings domain.NotificationSettings) domain.NotificationBatch {
+	return domain.NotificationBatch{
+		ID_user:  "1",
+		Timezone: "Europe/Moscow",
+		Settings: settings,
+		Items:    []domain.Notification{{ID_notification: 1, Created_at: created}},
+	}
+}
+
+func TestDueAt_Immediate(t *testing.T) {
+	created := time.Date(2026, 11, 1, 12, 7, 0, 0, time.UTC)
+	s := domain.DefaultNotificationSettings()
+	assert.True(t, created.Add(5*time.Minute).Equal(dueAt(batchAt(created, s))))
+
+	s.Batch_minutes = 0
+	assert.True(t, created.Equal(dueAt(batchAt(created, s))))
+}
+
+func TestDueAt_Hourly(t *testing.T) {
+	created := time.Date(2026, 11, 1, 12, 7, 0, 0, time.UTC)
+	s := domain.DefaultNotificationSettings()
+	s.Delivery = domain.NotifyHourly
+	assert.True(t, time.Date(2026, 11, 1, 13, 0, 0, 0, time.UTC).Equal(dueAt(batchAt(created, s))))
+}
+
+func TestDueAt_DailyInUserTimezone(t *testing.T) {
+	s := domain.DefaultNotificationSettings()
+	s.Delivery = domain.NotifyDaily
+	s.Digest_hour = 9
+	// 05:00 UTC = 08:00 МСК: сводка в 09:00 МСК того же дня
+	due := dueAt(batchAt(time.Date(2026, 11, 1, 5, 0, 0, 0, time.UTC), s))
+	assert.True(t, time.Date(2026, 11, 1, 6, 0, 0, 0, time.UTC).Equal(due), due)
+	// 07:00 UTC = 10:00 МСК: сводка на следующий день
+	due = dueAt(batchAt(time.Date(2026, 11, 1, 7, 0, 0, 0, time.UTC), s))
+	assert.True(t, time.Date(2026, 11, 2, 6, 0, 0, 0, time.UTC).Equal(due), due)
+}
+
+func TestWantedNotifications(t *testing.T) {
+	s := domain.DefaultNotificationSettings()
+	s.Kinds = []string{domain.NotificationPublicationFailed}
+	b := domain.NotificationBatch{Settings: s, Items: []domain.Notification{
+		{ID_notification: 1, Kind: domain.NotificationPublicationFailed},
+		{ID_notification: 2, Kind: domain.NotificationPlatformDegraded},
+		{ID_notification: 3, Kind: domain.NotificationPublicationFailed},
+	}}
+
+	// 3 забрал другой экземпляр, 2 — вид не выбран
+	got := wantedNotifications(b, []int{1, 2})
+	assert.Len(t, got, 1)
+	assert.Equal(t, 1, got[0].ID_notification)
+}
+
+func TestNotificationMessages(t *testing.T) {
+	destination := 7
+	created := time.Date(2026, 11, 1, 12, 0, 0, 0, time.UTC)
+	items := []domain.Notification{
+		{Kind: domain.NotificationPublicationFailed, Message: "Telegram publication of post 5 failed", ID_destination: &destination, Created_at: created},
+		{Kind: domain.NotificationPlatformDegraded, Message: "VK platform 2 failed authorization", Created_at: created},
+	}
+
+	assert.Equal(t, "Telegram publication of post 5 failed", notificationText(items[:1]))
+	text := notificationText(items)
+	assert.True(t, strings.HasPrefix(text, "2 notifications:"))
+	assert.Contains(t, text, "• VK platform 2 failed authorization")
+
+	msg := notificationEmail("owner@example.com", items)
+	assert.Equal(t, "owner@example.com", msg.To)
+	assert.Contains(t, msg.Subject, "(2)")
+	assert.Contains(t, msg.Body, "2026-11-01 12:00 UTC  Telegram publication of post 5 failed")
+
+	failed := failedPublications(items)
+	assert.Len(t, failed, 1)
+	assert.Equal(t, 7, *failed[0].ID_destination)
+}
+
+func TestFailedPublicationsIncludeStuck(t *testing.T) {
+	failed, stuck := 7, 8
+	items := []domain.Notification{
+		{Kind: domain.NotificationPublicationFailed, ID_destination: &failed},
+		{Kind: domain.NotificationPublicationStuck, ID_destination: &stuck},
+		{Kind: domain.NotificationPlatformDegraded},
+	}
+
+	got := failedPublications(items)
+	assert.Len(t, got, 2)
+	assert.Equal(t, 8, *got[1].ID_destination)
+}
@@ -8,7 +8,12 @@ import (
 
 	"hexlet/internal/domain"
 	"hexlet/internal/kafka"
+	"hexlet/internal/metrics"
 	"hexlet/internal/repository"
+	"hexlet/internal/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type SchedulerService struct {
@@ -53,12 +58,18 @@ func (s *SchedulerService) Start(ctx context.Context) {
 
 func (s *SchedulerService) processScheduledPublications(ctx context.Context) {
 	log.Println("Checking for scheduled publications...")
+	start := time.Now()
+	defer func() { metrics.SchedulerTick.Observe(metrics.Since(start)) }()
+	ctx, span := tracing.Start(ctx, "scheduler.tick")
+	defer span.End()
 
 	publications, err := s.repo.GetReadyForPublication(ctx, s.batchSize)
 	if err != nil {
 		log.Printf("Error getting scheduled publications: %v", err)
 		return
 	}
+	metrics.SchedulerDue.Set(float64(len(publications)))
+	span.SetAttributes(attribute.Int("scheduler.due_publications", len(publications)))
 
 	if len(publications) == 0 {
 		log.Println("No scheduled publications found")
@@ -80,7 +91,18 @@ func (s *SchedulerService) processScheduledPublications(ctx context.Context) {
 	log.Printf("Successfully processed %d publications", successfulCount)
 }
 
-func (s *SchedulerService) processPublication(ctx context.Context, pub domain.ScheduledPublication) error {
+// processPublication начинает трассу публикации со ссылками на запрос, создавший пост, и на проход планировщика
+func (s *SchedulerService) processPublication(ctx context.Context, pub domain.ScheduledPublication) (err error) {
+	ctx, span := tracing.Start(ctx, "schedule publication",
+		trace.WithNewRoot(),
+		trace.WithLinks(tracing.LinkTo(pub.Trace_parent), trace.LinkFromContext(ctx)),
+		trace.WithAttributes(
+			attribute.Int("publication.destination_id", pub.ID_destination),
+			attribute.Int("publication.post_id", pub.ID_post),
+			attribute.String("publication.platform", pub.Platform_name),
+		),
+	)
+	defer func() { tracing.End(span, err) }()
 	event := domain.PublicationEvent{
 		DestinationID: pub.ID_destination,
 		PostID:        pub.ID_post,
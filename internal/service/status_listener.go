@@ -0,0 +1,44 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"hexlet/internal/repository"
+	"hexlet/internal/statusfeed"
+)
+
+// StatusListener передаёт смены статусов из Postgres NOTIFY в hub этого экземпляра.
+// После обрыва соединения переподключается через retry.
+type StatusListener struct {
+	repo  *repository.Repository
+	hub   *statusfeed.Hub
+	retry time.Duration
+}
+
+func NewStatusListener(repo *repository.Repository, hub *statusfeed.Hub, retry time.Duration) *StatusListener {
+	return &StatusListener{
+		repo:  repo,
+		hub:   hub,
+		retry: retry,
+	}
+}
+
+func (l *StatusListener) Start(ctx context.Context) {
+	log.Println("Status listener started")
+	for {
+		err := l.repo.ListenStatusChanges(ctx, l.hub.Publish)
+		if ctx.Err() != nil {
+			log.Println("Status listener stopped")
+			return
+		}
+		log.Printf("Status listener disconnected: %v, retrying in %v", err, l.retry)
+		select {
+		case <-time.After(l.retry):
+		case <-ctx.Done():
+			log.Println("Status listener stopped")
+			return
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package service
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"hexlet/internal/domain"
+	"hexlet/internal/metrics"
+	"hexlet/internal/repository"
+	"hexlet/internal/webhook"
+)
+
+// WebhookDispatcher отправляет доставки из очереди webhook_deliveries.
+// Неудачная попытка повторяется с растущей паузой, после webhook.MaxAttempts
+// доставка помечается failed и остаётся в журнале для ручной повторной отправки.
+type WebhookDispatcher struct {
+	repo      *repository.Repository
+	client    *webhook.Client
+	interval  time.Duration
+	batchSize int
+	lease     time.Duration
+}
+
+const (
+	// одновременных отправок из одной пачки
+	webhookWorkers = 10
+	// запас lease на работу с БД и паузы планировщика
+	webhookLeaseMargin = time.Minute
+)
+
+func NewWebhookDispatcher(repo *repository.Repository, client *webhook.Client, interval time.Duration, batchSize int) *WebhookDispatcher {
+	return &WebhookDispatcher{
+		repo:      repo,
+		client:    client,
+		interval:  interval,
+		batchSize: batchSize,
+		lease:     webhookLease(batchSize, client.HTTP.Timeout),
+	}
+}
+
+// webhookLease — на сколько доставки пачки остаются за экземпляром: дольше самой медленной
+// отправки всей пачки, иначе другой экземпляр взял бы их повторно, пока эта ещё идёт
+func webhookLease(batchSize int, timeout time.Duration) time.Duration {
+	rounds := (batchSize + webhookWorkers - 1) / webhookWorkers
+	return time.Duration(rounds)*timeout + webhookLeaseMargin
+}
+
+func (d *WebhookDispatcher) Start(ctx context.Context) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	log.Printf("Webhook dispatcher started with interval: %v", d.interval)
+
+	for {
+		select {
+		case <-ticker.C:
+			d.dispatch(ctx)
+		case <-ctx.Done():
+			log.Println("Webhook dispatcher stopped")
+			return
+		}
+	}
+}
+
+func (d *WebhookDispatcher) dispatch(ctx context.Context) {
+	deliveries, err := d.repo.ClaimWebhookDeliveries(ctx, d.batchSize, d.lease)
+	if err != nil {
+		log.Printf("Error claiming webhook deliveries: %v", err)
+		return
+	}
+	queue := make(chan domain.WebhookDelivery)
+	var wg sync.WaitGroup
+	for i := 0; i < webhookWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for delivery := range queue {
+				d.deliver(ctx, delivery)
+			}
+		}()
+	}
+	for _, delivery := range deliveries {
+		queue <- delivery
+	}
+	close(queue)
+	wg.Wait()
+}
+
+func (d *WebhookDispatcher) deliver(ctx context.Context, delivery domain.WebhookDelivery) {
+	code, err := d.client.Send(ctx, webhook.Request{
+		Url:      delivery.Url,
+		Secret:   delivery.Secret,
+		Event:    delivery.Event,
+		ID_event: delivery.ID_event,
+		Delivery: delivery.ID_delivery,
+		Body:     delivery.Payload,
+	})
+	if err != nil {
+		log.Printf("Webhook delivery %d to %s failed: %v", delivery.ID_delivery, delivery.Url, err)
+	}
+	next := nextDelivery(delivery, code, err, time.Now())
+	metrics.WebhookAttempts.WithLabelValues(attemptResult(next)).Inc()
+	if err := d.repo.RecordWebhookAttempt(ctx, next); err != nil {
+		log.Printf("Error saving webhook delivery %d: %v", delivery.ID_delivery, err)
+	}
+}
+
+// nextDelivery — состояние доставки после попытки
+func nextDelivery(d domain.WebhookDelivery, code int, sendErr error, now time.Time) domain.WebhookDelivery {
+	d.Attempts++
+	d.Response_code = nil
+	if code != 0 {
+		d.Response_code = &code
+	}
+	if sendErr == nil {
+		d.Status = domain.DeliveryDelivered
+		d.Last_error = nil
+		d.Next_attempt_at = nil
+		return d
+	}
+	message := sendErr.Error()
+	d.Last_error = &message
+	delay, ok := webhook.Backoff(d.Attempts)
+	if !ok {
+		d.Status = domain.DeliveryFailed
+		d.Next_attempt_at = nil
+		return d
+	}
+	next := now.Add(delay)
+	d.Status = domain.DeliveryPending
+	d.Next_attempt_at = &next
+	return d
+}
+
+// attemptResult — метка попытки: неудача с оставшимися попытками считается повтором
+func attemptResult(d domain.WebhookDelivery) string {
+	if d.Status == domain.DeliveryPending {
+		return "retry"
+	}
+	return d.Status
+}
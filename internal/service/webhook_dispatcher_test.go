@@ -0,0 +1,64 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"hexlet/internal/domain"
+	"hexlet/internal/webhook"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNextDelivery_Delivered(t *testing.T) {
+	failure := "timeout"
+	d := nextDelivery(domain.WebhookDelivery{Status: domain.DeliveryPending, Attempts: 1, Last_error: &failure}, http.StatusOK, nil, time.Now())
+
+	assert.Equal(t, domain.DeliveryDelivered, d.Status)
+	assert.Equal(t, 2, d.Attempts)
+	assert.Equal(t, http.StatusOK, *d.Response_code)
+	assert.Nil(t, d.Last_error)
+	assert.Nil(t, d.Next_attempt_at)
+	assert.Equal(t, domain.DeliveryDelivered, attemptResult(d))
+}
+
+func TestNextDelivery_RetriesWithBackoff(t *testing.T) {
+	now := time.Date(2026, 11, 1, 12, 0, 0, 0, time.UTC)
+	d := nextDelivery(domain.WebhookDelivery{Status: domain.DeliveryPending}, 0, errors.New("connection refused"), now)
+
+	assert.Equal(t, domain.DeliveryPending, d.Status)
+	assert.Equal(t, 1, d.Attempts)
+	assert.Nil(t, d.Response_code)
+	assert.Equal(t, "connection refused", *d.Last_error)
+	assert.Equal(t, "retry", attemptResult(d))
+	delay, _ := webhook.Backoff(1)
+	assert.Equal(t, now.Add(delay), *d.Next_attempt_at)
+
+	d = nextDelivery(d, http.StatusBadGateway, errors.New("unexpected status 502"), now)
+	assert.Equal(t, 2, d.Attempts)
+	assert.Equal(t, http.StatusBadGateway, *d.Response_code)
+	delay, _ = webhook.Backoff(2)
+	assert.Equal(t, now.Add(delay), *d.Next_attempt_at)
+}
+
+func TestNextDelivery_GivesUp(t *testing.T) {
+	d := domain.WebhookDelivery{Status: domain.DeliveryPending, Attempts: webhook.MaxAttempts - 1}
+	d = nextDelivery(d, http.StatusInternalServerError, errors.New("unexpected status 500"), time.Now())
+
+	assert.Equal(t, domain.DeliveryFailed, d.Status)
+	assert.Equal(t, webhook.MaxAttempts, d.Attempts)
+	assert.Nil(t, d.Next_attempt_at)
+	assert.Equal(t, domain.DeliveryFailed, attemptResult(d))
+}
+
+func TestWebhookLease_CoversSlowestBatch(t *testing.T) {
+	timeout := 10 * time.Second
+	lease := webhookLease(50, timeout)
+
+	// все отправки упираются в таймаут: пачка идёт раундами по webhookWorkers
+	slowest := time.Duration((50+webhookWorkers-1)/webhookWorkers) * timeout
+	assert.Greater(t, lease, slowest)
+	assert.Equal(t, webhookLeaseMargin+timeout, webhookLease(1, timeout))
+}
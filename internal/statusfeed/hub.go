@@ -0,0 +1,81 @@
+// Package statusfeed раздаёт смены статусов публикаций подключённым клиентам
+// одного экземпляра API. Между экземплярами события расходятся через Postgres NOTIFY.
+package statusfeed
+
+import (
+	"hexlet/internal/domain"
+	"sync"
+)
+
+type subscriber struct {
+	ch         chan domain.StatusEvent
+	workspaces map[int]bool
+	// события всех пространств
+	all bool
+}
+
+type Hub struct {
+	mu     sync.Mutex
+	subs   map[*subscriber]struct{}
+	buffer int
+}
+
+// buffer — сколько событий может ждать медленный клиент
+func NewHub(buffer int) *Hub {
+	return &Hub{subs: map[*subscriber]struct{}{}, buffer: buffer}
+}
+
+// Subscribe возвращает канал событий пространств workspaces и функцию отписки.
+// Канал закрывается, если клиент не успевает читать: пропущенные статусы
+// он получает заново, перечитав GET /posts после переподключения.
+func (h *Hub) Subscribe(workspaces []int) (<-chan domain.StatusEvent, func()) {
+	s := &subscriber{ch: make(chan domain.StatusEvent, h.buffer), workspaces: map[int]bool{}}
+	for _, id := range workspaces {
+		s.workspaces[id] = true
+	}
+	return h.add(s)
+}
+
+// SubscribeAll — подписка на события всех пространств для фоновых обработчиков
+func (h *Hub) SubscribeAll() (<-chan domain.StatusEvent, func()) {
+	return h.add(&subscriber{ch: make(chan domain.StatusEvent, h.buffer), all: true})
+}
+
+func (h *Hub) add(s *subscriber) (<-chan domain.StatusEvent, func()) {
+	h.mu.Lock()
+	h.subs[s] = struct{}{}
+	h.mu.Unlock()
+	return s.ch, func() { h.remove(s) }
+}
+
+func (h *Hub) Publish(e domain.StatusEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for s := range h.subs {
+		if !s.all && !s.workspaces[e.ID_workspace] {
+			continue
+		}
+		select {
+		case s.ch <- e:
+		default:
+			delete(h.subs, s)
+			close(s.ch)
+		}
+	}
+}
+
+// Len — число подключённых клиентов
+func (h *Hub) Len() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subs)
+}
+
+func (h *Hub) remove(s *subscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.subs[s]; ok {
+		delete(h.subs, s)
+		close(s.ch)
+	}
+}
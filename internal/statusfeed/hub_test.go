@@ -0,0 +1,74 @@
+package statusfeed
+
+import (
+	"hexlet/internal/domain"
+	"testing"
+)
+
+func TestHubFiltersByWorkspace(t *testing.T) {
+	h := NewHub(4)
+	mine, cancelMine := h.Subscribe([]int{1, 2})
+	defer cancelMine()
+	other, cancelOther := h.Subscribe([]int{3})
+	defer cancelOther()
+
+	h.Publish(domain.StatusEvent{ID_destination: 10, ID_workspace: 2, Status: "published"})
+	h.Publish(domain.StatusEvent{ID_destination: 11, ID_workspace: 3, Status: "failed"})
+
+	if e := <-mine; e.ID_destination != 10 {
+		t.Errorf("Unexpected event %+v", e)
+	}
+	if e := <-other; e.ID_destination != 11 {
+		t.Errorf("Unexpected event %+v", e)
+	}
+	select {
+	case e := <-mine:
+		t.Errorf("Event of another workspace delivered: %+v", e)
+	default:
+	}
+}
+
+func TestHubDropsSlowSubscriber(t *testing.T) {
+	h := NewHub(1)
+	ch, cancel := h.Subscribe([]int{1})
+	h.Publish(domain.StatusEvent{ID_destination: 1, ID_workspace: 1})
+	h.Publish(domain.StatusEvent{ID_destination: 2, ID_workspace: 1})
+
+	if e, ok := <-ch; !ok || e.ID_destination != 1 {
+		t.Fatalf("Expected buffered event, got %+v %v", e, ok)
+	}
+	if _, ok := <-ch; ok {
+		t.Error("Expected channel of slow subscriber to be closed")
+	}
+	if h.Len() != 0 {
+		t.Errorf("Expected no subscribers, got %d", h.Len())
+	}
+	// повторная отписка после закрытия безопасна
+	cancel()
+}
+
+func TestHubUnsubscribe(t *testing.T) {
+	h := NewHub(1)
+	ch, cancel := h.Subscribe([]int{1})
+	cancel()
+	if _, ok := <-ch; ok {
+		t.Error("Expected closed channel after unsubscribe")
+	}
+	h.Publish(domain.StatusEvent{ID_workspace: 1})
+	cancel()
+}
+
+func TestHubSubscribeAll(t *testing.T) {
+	h := NewHub(2)
+	ch, cancel := h.SubscribeAll()
+	defer cancel()
+	h.Publish(domain.StatusEvent{ID_destination: 1, ID_workspace: 1})
+	h.Publish(domain.StatusEvent{ID_destination: 2, ID_workspace: 7})
+
+	if e := <-ch; e.ID_destination != 1 {
+		t.Errorf("Unexpected event %+v", e)
+	}
+	if e := <-ch; e.ID_destination != 2 {
+		t.Errorf("Unexpected event %+v", e)
+	}
+}
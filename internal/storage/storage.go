@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"hexlet/internal/config"
+	"hexlet/internal/tracing"
 	"log"
 	"net"
 	"time"
@@ -44,6 +45,10 @@ func InitDBConn(ctx context.Context, cfgenv *config.Config) (dbpool *pgxpool.Poo
 		KeepAlive: cfg.HealthCheckPeriod,
 		Timeout:   cfg.ConnConfig.ConnectTimeout,
 	}).DialContext
+	if tracing.Enabled() {
+		// спаны запросов; логгер pgx вызывается только при включённой трассировке
+		cfg.ConnConfig.Logger = tracing.PgxLogger{}
+	}
 	for i := 0; i < 10; i++ {
 		dbpool, err = pgxpool.ConnectConfig(ctx, cfg)
 		if err != nil {
@@ -0,0 +1,76 @@
+package textdiff
+
+import "strings"
+
+// Операции построчного сравнения
+const (
+	OpEqual  = "equal"
+	OpInsert = "insert"
+	OpDelete = "delete"
+)
+
+type Line struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// Diff сравнивает тексты построчно по наибольшей общей подпоследовательности.
+// Тексты постов небольшие, поэтому квадратичная таблица допустима.
+func Diff(a, b string) []Line {
+	x := split(a)
+	y := split(b)
+	// lcs[i][j] — длина общей подпоследовательности x[i:] и y[j:]
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	res := []Line{}
+	i, j := 0, 0
+	for i < len(x) && j < len(y) {
+		switch {
+		case x[i] == y[j]:
+			res = append(res, Line{Op: OpEqual, Text: x[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			res = append(res, Line{Op: OpDelete, Text: x[i]})
+			i++
+		default:
+			res = append(res, Line{Op: OpInsert, Text: y[j]})
+			j++
+		}
+	}
+	for ; i < len(x); i++ {
+		res = append(res, Line{Op: OpDelete, Text: x[i]})
+	}
+	for ; j < len(y); j++ {
+		res = append(res, Line{Op: OpInsert, Text: y[j]})
+	}
+	return res
+}
+
+// Changed: в результате сравнения есть отличия
+func Changed(lines []Line) bool {
+	for _, l := range lines {
+		if l.Op != OpEqual {
+			return true
+		}
+	}
+	return false
+}
+
+func split(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
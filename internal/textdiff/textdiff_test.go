@@ -0,0 +1,30 @@
+package textdiff
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff(t *testing.T) {
+	lines := Diff("a\nb\nc", "a\nc\nd")
+	assert.Equal(t, []Line{
+		{Op: OpEqual, Text: "a"},
+		{Op: OpDelete, Text: "b"},
+		{Op: OpEqual, Text: "c"},
+		{Op: OpInsert, Text: "d"},
+	}, lines)
+	assert.True(t, Changed(lines))
+}
+
+func TestDiff_Equal(t *testing.T) {
+	lines := Diff("same\ntext", "same\ntext")
+	assert.Len(t, lines, 2)
+	assert.False(t, Changed(lines))
+}
+
+func TestDiff_Empty(t *testing.T) {
+	assert.Equal(t, []Line{{Op: OpInsert, Text: "new"}}, Diff("", "new"))
+	assert.Equal(t, []Line{{Op: OpDelete, Text: "old"}}, Diff("old", ""))
+	assert.Empty(t, Diff("", ""))
+}
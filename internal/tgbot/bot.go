@@ -0,0 +1,531 @@
+// Package tgbot — бот управления расписанием в Telegram (long polling).
+// Привязанный пользователь смотрит ближайшие публикации, создаёт пост,
+// переносит и снимает публикации и получает уведомления (канал telegram) с кнопкой повтора.
+package tgbot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hexlet/internal/auth"
+	"hexlet/internal/domain"
+	"hexlet/internal/dto"
+	"hexlet/internal/metrics"
+	"hexlet/internal/repository"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Формат даты в командах и ответах бота, в часовом поясе пользователя
+const TimeLayout = "2006-01-02 15:04"
+
+// сколько публикаций показывает /upcoming
+const upcomingLimit = 10
+
+// Store — методы репозитория, нужные боту
+type Store interface {
+	LinkTelegramChat(ctx context.Context, tokenHash string, chatID int64, username string) (string, error)
+	GetTelegramUser(ctx context.Context, chatID int64) (string, error)
+	GetTelegramChat(ctx context.Context, ID_user string) (int64, error)
+	GetUserByID(ctx context.Context, ID_user string) (domain.User, error)
+	GetDefaultWorkspace(ctx context.Context, ID_user string) (int, error)
+	GetMemberRole(ctx context.Context, ID_workspace int, ID_user string) (string, error)
+	GetUpcomingDestinations(ctx context.Context, ID_workspace int, limit int) ([]domain.CalendarEvent, error)
+	CreatePost(ctx context.Context, post dto.CreatePostRequest) (int, time.Time, error)
+	RescheduleDestinations(ctx context.Context, ID_post int, ID_workspace int, ID_user string, at time.Time) (int, error)
+	CancelPost(ctx context.Context, ID_post int, ID_workspace int) (int, error)
+	RetryDestination(ctx context.Context, ID_destination int, ID_workspace int) (bool, error)
+	GetDestinationEvent(ctx context.Context, ID_destination int) (domain.DestinationEventData, error)
+	EnqueueWebhookEvent(ctx context.Context, event domain.WebhookEvent) (int, error)
+}
+
+type Bot struct {
+	api   *tgbotapi.BotAPI
+	store Store
+	// секунды ожидания getUpdates
+	PollTimeout int
+}
+
+// endpoint — адрес Bot API в формате tgbotapi.APIEndpoint, его можно направить на локальный сервер
+func New(token string, endpoint string, client tgbotapi.HTTPClient, store Store) (*Bot, error) {
+	api, err := tgbotapi.NewBotAPIWithClient(token, endpoint, client)
+	if err != nil {
+		return nil, err
+	}
+	return &Bot{api: api, store: store, PollTimeout: 30}, nil
+}
+
+// Username — имя бота для ссылки привязки t.me/<username>
+func (b *Bot) Username() string {
+	return b.api.Self.UserName
+}
+
+// Run читает обновления до отмены ctx
+func (b *Bot) Run(ctx context.Context) {
+	log.Printf("Telegram bot @%s started", b.Username())
+	offset := 0
+	for ctx.Err() == nil {
+		updates, err := b.api.GetUpdates(tgbotapi.UpdateConfig{Offset: offset, Timeout: b.PollTimeout})
+		if err != nil {
+			log.Printf("Telegram bot getUpdates: %v", err)
+			select {
+			case <-time.After(3 * time.Second):
+			case <-ctx.Done():
+			}
+			continue
+		}
+		for _, u := range updates {
+			if u.UpdateID >= offset {
+				offset = u.UpdateID + 1
+			}
+			b.HandleUpdate(ctx, u)
+		}
+	}
+	log.Println("Telegram bot stopped")
+}
+
+func (b *Bot) HandleUpdate(ctx context.Context, u tgbotapi.Update) {
+	switch {
+	case u.CallbackQuery != nil:
+		b.handleCallback(ctx, u.CallbackQuery)
+	case u.Message != nil && u.Message.IsCommand():
+		b.handleCommand(ctx, u.Message)
+	case u.Message != nil:
+		b.reply(u.Message.Chat.ID, "Unknown command, see /help")
+	}
+}
+
+const privateOnly = "The bot works only in a private chat, open it from your profile link."
+
+const helpText = `Commands:
+/upcoming — next scheduled publications
+/post [YYYY-MM-DD HH:MM] title
+content — create a post and send it for review
+/reschedule <post id> YYYY-MM-DD HH:MM — move unpublished destinations of a post
+/cancel <post id> — cancel scheduled destinations of a post
+Times are in your profile timezone.`
+
+func (b *Bot) handleCommand(ctx context.Context, m *tgbotapi.Message) {
+	chatID := m.Chat.ID
+	// в группе привязку и команды получили бы все её участники
+	if !m.Chat.IsPrivate() {
+		b.reply(chatID, privateOnly)
+		return
+	}
+	args := strings.TrimSpace(m.CommandArguments())
+	if m.Command() == "start" {
+		b.start(ctx, m, args)
+		return
+	}
+	if m.Command() == "help" {
+		b.reply(chatID, helpText)
+		return
+	}
+	u, err := b.session(ctx, chatID)
+	if err != nil {
+		b.reply(chatID, sessionError(err))
+		return
+	}
+	switch m.Command() {
+	case "upcoming":
+		b.upcoming(ctx, u)
+	case "post":
+		b.post(ctx, u, args)
+	case "reschedule":
+		b.reschedule(ctx, u, args)
+	case "cancel":
+		b.cancel(ctx, u, args)
+	default:
+		b.reply(chatID, "Unknown command, see /help")
+	}
+}
+
+// start привязывает чат по коду из POST /me/telegram
+func (b *Bot) start(ctx context.Context, m *tgbotapi.Message, code string) {
+	chatID := m.Chat.ID
+	if code == "" {
+		if _, err := b.store.GetTelegramUser(ctx, chatID); err == nil {
+			b.reply(chatID, "This chat is linked.\n\n"+helpText)
+			return
+		}
+		b.reply(chatID, "This chat is not linked. Open the link from your profile to connect it.")
+		return
+	}
+	username := ""
+	if m.From != nil {
+		username = m.From.UserName
+	}
+	_, err := b.store.LinkTelegramChat(ctx, auth.HashToken(code), chatID, username)
+	if errors.Is(err, repository.ErrAuthTokenInvalid) {
+		b.reply(chatID, "The link code is invalid or expired, request a new one.")
+		return
+	}
+	if err != nil {
+		b.reply(chatID, "Failed to link this chat, try again later.")
+		return
+	}
+	b.reply(chatID, "Chat linked.\n\n"+helpText)
+}
+
+// session — привязанный пользователь чата и его пространство по умолчанию
+type session struct {
+	chatID       int64
+	ID_user      string
+	ID_workspace int
+	role         string
+	loc          *time.Location
+}
+
+var errReadOnly = errors.New("read only")
+
+func (b *Bot) session(ctx context.Context, chatID int64) (session, error) {
+	s := session{chatID: chatID, loc: time.UTC}
+	var err error
+	if s.ID_user, err = b.store.GetTelegramUser(ctx, chatID); err != nil {
+		return s, err
+	}
+	if s.ID_workspace, err = b.store.GetDefaultWorkspace(ctx, s.ID_user); err != nil {
+		return s, err
+	}
+	if s.role, err = b.store.GetMemberRole(ctx, s.ID_workspace, s.ID_user); err != nil {
+		return s, err
+	}
+	user, err := b.store.GetUserByID(ctx, s.ID_user)
+	if err != nil {
+		return s, err
+	}
+	if loc, err := time.LoadLocation(user.Timezone); err == nil {
+		s.loc = loc
+	}
+	return s, nil
+}
+
+// canWrite: изменять расписание может редактор и выше
+func (s session) canWrite() error {
+	if !domain.RoleAtLeast(s.role, domain.RoleEditor) {
+		return errReadOnly
+	}
+	return nil
+}
+
+func sessionError(err error) string {
+	switch {
+	case errors.Is(err, repository.ErrTelegramNotLinked):
+		return "This chat is not linked. Open the link from your profile to connect it."
+	case errors.Is(err, repository.ErrNotMember):
+		return "You are not a member of any workspace."
+	case errors.Is(err, errReadOnly):
+		return "Your role does not allow changing the schedule."
+	default:
+		return "Something went wrong, try again later."
+	}
+}
+
+func (b *Bot) upcoming(ctx context.Context, s session) {
+	events, err := b.store.GetUpcomingDestinations(ctx, s.ID_workspace, upcomingLimit)
+	if err != nil {
+		b.reply(s.chatID, sessionError(err))
+		return
+	}
+	if len(events) == 0 {
+		b.reply(s.chatID, "Nothing is scheduled.")
+		return
+	}
+	var text strings.Builder
+	var rows [][]tgbotapi.InlineKeyboardButton
+	seen := map[int]bool{}
+	for _, e := range events {
+		fmt.Fprintf(&text, "#%d %s [%s] %s", e.ID_post, e.Sheduled_for.In(s.loc).Format(TimeLayout), e.PlatformName, e.Title)
+		if e.Status != "scheduled" {
+			fmt.Fprintf(&text, " (%s)", e.Status)
+		}
+		text.WriteString("\n")
+		// кнопки по одной строке на пост: перенос отсчитывается от текущего времени публикации
+		if seen[e.ID_post] || s.canWrite() != nil {
+			continue
+		}
+		seen[e.ID_post] = true
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("#%d +1h", e.ID_post), moveData(e.ID_post, e.Sheduled_for.Add(time.Hour))),
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("#%d +1d", e.ID_post), moveData(e.ID_post, e.Sheduled_for.AddDate(0, 0, 1))),
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("#%d cancel", e.ID_post), fmt.Sprintf("cancel:%d", e.ID_post)),
+		))
+	}
+	msg := tgbotapi.NewMessage(s.chatID, text.String())
+	if len(rows) > 0 {
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	}
+	b.send(msg)
+}
+
+var datePrefix = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2} \d{2}:\d{2})\s+`)
+
+var validate = validator.New()
+
+// post: первая строка — [дата] заголовок, остальное — текст
+func (b *Bot) post(ctx context.Context, s session, args string) {
+	if err := s.canWrite(); err != nil {
+		b.reply(s.chatID, sessionError(err))
+		return
+	}
+	head, content, _ := strings.Cut(args, "\n")
+	request := dto.CreatePostRequest{
+		ID_user:      s.ID_user,
+		ID_workspace: s.ID_workspace,
+		Content:      strings.TrimSpace(content),
+		Status:       domain.PostInReview,
+	}
+	if m := datePrefix.FindStringSubmatch(head); m != nil {
+		at, err := time.ParseInLocation(TimeLayout, m[1], s.loc)
+		if err != nil {
+			b.reply(s.chatID, "Invalid date, use YYYY-MM-DD HH:MM")
+			return
+		}
+		request.Sheduled_for = at
+		head = head[len(m[0]):]
+	}
+	request.Title = strings.TrimSpace(head)
+	if err := validate.Struct(&request); err != nil {
+		b.reply(s.chatID, "Usage: /post [YYYY-MM-DD HH:MM] title, then the post text on the next lines. The title needs 3 to 255 characters.")
+		return
+	}
+	ID_post, _, err := b.store.CreatePost(ctx, request)
+	if err != nil {
+		b.reply(s.chatID, sessionError(err))
+		return
+	}
+	b.postEvent(ctx, s, domain.EventPostCreated, ID_post, domain.PostEventData{Status: request.Status})
+	text := fmt.Sprintf("Post #%d created and sent for review.", ID_post)
+	if !request.Sheduled_for.IsZero() {
+		text += " Scheduled for " + request.Sheduled_for.In(s.loc).Format(TimeLayout) + "."
+	}
+	b.reply(s.chatID, text)
+}
+
+func (b *Bot) reschedule(ctx context.Context, s session, args string) {
+	id, rest, _ := strings.Cut(args, " ")
+	ID_post, err := strconv.Atoi(strings.TrimPrefix(id, "#"))
+	if err != nil {
+		b.reply(s.chatID, "Usage: /reschedule <post id> YYYY-MM-DD HH:MM")
+		return
+	}
+	at, err := time.ParseInLocation(TimeLayout, strings.TrimSpace(rest), s.loc)
+	if err != nil {
+		b.reply(s.chatID, "Usage: /reschedule <post id> YYYY-MM-DD HH:MM")
+		return
+	}
+	b.reply(s.chatID, b.move(ctx, s, ID_post, at))
+}
+
+func (b *Bot) move(ctx context.Context, s session, ID_post int, at time.Time) string {
+	if err := s.canWrite(); err != nil {
+		return sessionError(err)
+	}
+	moved, err := b.store.RescheduleDestinations(ctx, ID_post, s.ID_workspace, s.ID_user, at)
+	if errors.Is(err, repository.ErrPostNotFound) {
+		return fmt.Sprintf("Post #%d not found.", ID_post)
+	}
+	if err != nil {
+		return sessionError(err)
+	}
+	if moved == 0 {
+		return fmt.Sprintf("Post #%d has nothing left to reschedule.", ID_post)
+	}
+	b.postEvent(ctx, s, domain.EventPostUpdated, ID_post, domain.PostEventData{})
+	return fmt.Sprintf("Post #%d rescheduled to %s.", ID_post, at.In(s.loc).Format(TimeLayout))
+}
+
+func (b *Bot) cancel(ctx context.Context, s session, args string) {
+	ID_post, err := strconv.Atoi(strings.TrimPrefix(args, "#"))
+	if err != nil {
+		b.reply(s.chatID, "Usage: /cancel <post id>")
+		return
+	}
+	b.reply(s.chatID, b.cancelPost(ctx, s, ID_post))
+}
+
+func (b *Bot) cancelPost(ctx context.Context, s session, ID_post int) string {
+	if err := s.canWrite(); err != nil {
+		return sessionError(err)
+	}
+	cancelled, err := b.store.CancelPost(ctx, ID_post, s.ID_workspace)
+	if errors.Is(err, repository.ErrPostNotFound) {
+		return fmt.Sprintf("Post #%d not found.", ID_post)
+	}
+	if err != nil {
+		return sessionError(err)
+	}
+	if cancelled == 0 {
+		return fmt.Sprintf("Post #%d has no scheduled destinations.", ID_post)
+	}
+	return fmt.Sprintf("Post #%d cancelled, destinations: %d. Reschedule it to publish again.", ID_post, cancelled)
+}
+
+func (b *Bot) postEvent(ctx context.Context, s session, event string, ID_post int, data domain.PostEventData) {
+	data.ID_post = ID_post
+	data.ID_user = s.ID_user
+	_, err := b.store.EnqueueWebhookEvent(ctx, domain.WebhookEvent{
+		Event:        event,
+		ID_workspace: s.ID_workspace,
+		ID_post:      ID_post,
+		Data:         data,
+	})
+	if err != nil {
+		log.Printf("webhook event %s for post %d: %v", event, ID_post, err)
+	}
+}
+
+// Данные кнопок: move:<post>:<unix>, cancel:<post>, retry:<destination>
+func moveData(ID_post int, at time.Time) string {
+	return fmt.Sprintf("move:%d:%d", ID_post, at.Unix())
+}
+
+func (b *Bot) handleCallback(ctx context.Context, cb *tgbotapi.CallbackQuery) {
+	if cb.Message == nil {
+		b.answer(cb.ID, "This button is no longer available.")
+		return
+	}
+	// кнопки нажимает только владелец личного чата, к которому привязан пользователь
+	if !cb.Message.Chat.IsPrivate() || cb.From == nil || cb.From.ID != cb.Message.Chat.ID {
+		b.answer(cb.ID, privateOnly)
+		return
+	}
+	s, err := b.session(ctx, cb.Message.Chat.ID)
+	if err != nil {
+		b.answer(cb.ID, sessionError(err))
+		return
+	}
+	parts := strings.Split(cb.Data, ":")
+	ids := make([]int64, 0, len(parts)-1)
+	for _, p := range parts[1:] {
+		id, err := strconv.ParseInt(p, 10, 64)
+		if err != nil {
+			b.answer(cb.ID, "Unknown action.")
+			return
+		}
+		ids = append(ids, id)
+	}
+	var text string
+	switch {
+	case parts[0] == "move" && len(ids) == 2:
+		text = b.move(ctx, s, int(ids[0]), time.Unix(ids[1], 0))
+	case parts[0] == "cancel" && len(ids) == 1:
+		text = b.cancelPost(ctx, s, int(ids[0]))
+	case parts[0] == "retry" && len(ids) == 1:
+		var done bool
+		text, done = b.retry(ctx, s, int(ids[0]))
+		if done {
+			// нажатая кнопка больше не нужна, остальные остаются
+			edit := tgbotapi.NewEditMessageText(s.chatID, cb.Message.MessageID, cb.Message.Text+"\n\n"+text)
+			edit.ReplyMarkup = withoutButton(cb.Message.ReplyMarkup, cb.Data)
+			b.send(edit)
+		}
+	default:
+		text = "Unknown action."
+	}
+	b.answer(cb.ID, text)
+}
+
+// retry — повтор неудачной публикации; пространство берётся из самой публикации
+func (b *Bot) retry(ctx context.Context, s session, ID_destination int) (string, bool) {
+	data, err := b.store.GetDestinationEvent(ctx, ID_destination)
+	if err != nil {
+		return "Publication not found.", false
+	}
+	role, err := b.store.GetMemberRole(ctx, data.ID_workspace, s.ID_user)
+	if err != nil {
+		return sessionError(err), false
+	}
+	s.role = role
+	if err := s.canWrite(); err != nil {
+		return sessionError(err), false
+	}
+	ok, err := b.store.RetryDestination(ctx, ID_destination, data.ID_workspace)
+	if err != nil {
+		return sessionError(err), false
+	}
+	if !ok {
+		return fmt.Sprintf("Post #%d: the publication is no longer failed.", data.ID_post), true
+	}
+	metrics.PublicationRetries.WithLabelValues("telegram").Inc()
+	return fmt.Sprintf("Post #%d: retry scheduled.", data.ID_post), true
+}
+
+// withoutButton — клавиатура без кнопки data; nil, если кнопок не осталось
+func withoutButton(markup *tgbotapi.InlineKeyboardMarkup, data string) *tgbotapi.InlineKeyboardMarkup {
+	if markup == nil {
+		return nil
+	}
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, row := range markup.InlineKeyboard {
+		var kept []tgbotapi.InlineKeyboardButton
+		for _, button := range row {
+			if button.CallbackData == nil || *button.CallbackData != data {
+				kept = append(kept, button)
+			}
+		}
+		if len(kept) > 0 {
+			rows = append(rows, kept)
+		}
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	return &tgbotapi.InlineKeyboardMarkup{InlineKeyboard: rows}
+}
+
+// не больше кнопок повтора в одном уведомлении
+const maxRetryButtons = 10
+
+// лимит Bot API на длину сообщения
+const maxMessageLength = 4096
+
+// Notify отправляет уведомления в чат пользователя, если он его привязал.
+// У неудачных публикаций failed появляются кнопки повтора.
+func (b *Bot) Notify(ctx context.Context, ID_user string, text string, failed []domain.Notification) error {
+	chatID, err := b.store.GetTelegramChat(ctx, ID_user)
+	if errors.Is(err, repository.ErrTelegramNotLinked) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if runes := []rune(text); len(runes) > maxMessageLength {
+		text = string(runes[:maxMessageLength-1]) + "…"
+	}
+	msg := tgbotapi.NewMessage(chatID, text)
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, n := range failed {
+		if n.ID_destination == nil || n.ID_post == nil || len(rows) == maxRetryButtons {
+			continue
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("Retry post #%d", *n.ID_post), fmt.Sprintf("retry:%d", *n.ID_destination)),
+		))
+	}
+	if len(rows) > 0 {
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	}
+	_, err = b.api.Send(msg)
+	return err
+}
+
+func (b *Bot) reply(chatID int64, text string) {
+	b.send(tgbotapi.NewMessage(chatID, text))
+}
+
+func (b *Bot) send(c tgbotapi.Chattable) {
+	if _, err := b.api.Request(c); err != nil {
+		log.Printf("Telegram bot send: %v", err)
+	}
+}
+
+func (b *Bot) answer(callbackID string, text string) {
+	b.send(tgbotapi.NewCallback(callbackID, text))
+}
@@ -0,0 +1,451 @@
+package tgbot
+
+import (
+	"context"
+	"fmt"
+	"hexlet/internal/auth"
+	"hexlet/internal/domain"
+	"hexlet/internal/dto"
+	"hexlet/internal/repository"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// fakeTelegram — локальный Bot API: запоминает вызовы и отвечает как Telegram
+type fakeTelegram struct {
+	mu      sync.Mutex
+	calls   []call
+	updates []string
+	// вызывается на пустом getUpdates
+	idle func()
+}
+
+type call struct {
+	method string
+	form   url.Values
+}
+
+func (f *fakeTelegram) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	method := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+	f.mu.Lock()
+	f.calls = append(f.calls, call{method: method, form: r.Form})
+	updates := f.updates
+	f.updates = nil
+	f.mu.Unlock()
+	switch method {
+	case "getMe":
+		fmt.Fprint(w, `{"ok":true,"result":{"id":1,"is_bot":true,"first_name":"Schedule","username":"schedule_bot"}}`)
+	case "getUpdates":
+		if len(updates) == 0 && f.idle != nil {
+			f.idle()
+		}
+		fmt.Fprintf(w, `{"ok":true,"result":[%s]}`, strings.Join(updates, ","))
+	case "answerCallbackQuery":
+		fmt.Fprint(w, `{"ok":true,"result":true}`)
+	default:
+		fmt.Fprintf(w, `{"ok":true,"result":{"message_id":100,"chat":{"id":%s},"date":0}}`, r.Form.Get("chat_id"))
+	}
+}
+
+func (f *fakeTelegram) sent(method string) []url.Values {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var res []url.Values
+	for _, c := range f.calls {
+		if c.method == method {
+			res = append(res, c.form)
+		}
+	}
+	return res
+}
+
+func (f *fakeTelegram) lastText(t *testing.T) string {
+	t.Helper()
+	msgs := f.sent("sendMessage")
+	if len(msgs) == 0 {
+		t.Fatal("Expected a message")
+	}
+	return msgs[len(msgs)-1].Get("text")
+}
+
+type fakeStore struct {
+	chats       map[int64]string
+	role        string
+	timezone    string
+	linkHash    string
+	upcoming    []domain.CalendarEvent
+	created     []dto.CreatePostRequest
+	rescheduled []time.Time
+	cancelled   []int
+	retried     []int
+	events      []string
+}
+
+func (s *fakeStore) LinkTelegramChat(ctx context.Context, tokenHash string, chatID int64, username string) (string, error) {
+	if tokenHash != s.linkHash {
+		return "", repository.ErrAuthTokenInvalid
+	}
+	s.chats[chatID] = "1"
+	return "1", nil
+}
+
+func (s *fakeStore) GetTelegramUser(ctx context.Context, chatID int64) (string, error) {
+	if u, ok := s.chats[chatID]; ok {
+		return u, nil
+	}
+	return "", repository.ErrTelegramNotLinked
+}
+
+func (s *fakeStore) GetTelegramChat(ctx context.Context, ID_user string) (int64, error) {
+	for chat, u := range s.chats {
+		if u == ID_user {
+			return chat, nil
+		}
+	}
+	return 0, repository.ErrTelegramNotLinked
+}
+
+func (s *fakeStore) GetUserByID(ctx context.Context, ID_user string) (domain.User, error) {
+	return domain.User{ID_user: ID_user, Timezone: s.timezone}, nil
+}
+
+func (s *fakeStore) GetDefaultWorkspace(ctx context.Context, ID_user string) (int, error) {
+	return 1, nil
+}
+
+func (s *fakeStore) GetMemberRole(ctx context.Context, ID_workspace int, ID_user string) (string, error) {
+	return s.role, nil
+}
+
+func (s *fakeStore) GetUpcomingDestinations(ctx context.Context, ID_workspace int, limit int) ([]domain.CalendarEvent, error) {
+	return s.upcoming, nil
+}
+
+func (s *fakeStore) CreatePost(ctx context.Context, post dto.CreatePostRequest) (int, time.Time, error) {
+	s.created = append(s.created, post)
+	return 42, time.Now(), nil
+}
+
+func (s *fakeStore) RescheduleDestinations(ctx context.Context, ID_post int, ID_workspace int, ID_user string, at time.Time) (int, error) {
+	if ID_post != 5 {
+		return 0, repository.ErrPostNotFound
+	}
+	s.rescheduled = append(s.rescheduled, at)
+	return 2, nil
+}
+
+func (s *fakeStore) CancelPost(ctx context.Context, ID_post int, ID_workspace int) (int, error) {
+	s.cancelled = append(s.cancelled, ID_post)
+	return 1, nil
+}
+
+func (s *fakeStore) RetryDestination(ctx context.Context, ID_destination int, ID_workspace int) (bool, error) {
+	s.retried = append(s.retried, ID_destination)
+	return true, nil
+}
+
+func (s *fakeStore) GetDestinationEvent(ctx context.Context, ID_destination int) (domain.DestinationEventData, error) {
+	msg := "chat not found"
+	return domain.DestinationEventData{
+		ID_destination: ID_destination,
+		ID_post:        5,
+		ID_user:        "1",
+		ID_workspace:   2,
+		PlatformName:   "Telegram",
+		Status:         "failed",
+		ErrorMessage:   &msg,
+	}, nil
+}
+
+func (s *fakeStore) EnqueueWebhookEvent(ctx context.Context, event domain.WebhookEvent) (int, error) {
+	s.events = append(s.events, event.Event)
+	return 0, nil
+}
+
+func setupBot(t *testing.T) (*Bot, *fakeTelegram, *fakeStore) {
+	t.Helper()
+	tg := &fakeTelegram{}
+	server := httptest.NewServer(tg)
+	t.Cleanup(server.Close)
+	store := &fakeStore{
+		chats:    map[int64]string{},
+		role:     domain.RoleEditor,
+		timezone: "Europe/Moscow",
+		linkHash: auth.HashToken("code"),
+	}
+	b, err := New("TOKEN", server.URL+"/bot%s/%s", server.Client(), store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b, tg, store
+}
+
+func command(chatID int64, text string) tgbotapi.Update {
+	name, _, _ := strings.Cut(text, " ")
+	name, _, _ = strings.Cut(name, "\n")
+	return tgbotapi.Update{Message: &tgbotapi.Message{
+		MessageID: 1,
+		From:      &tgbotapi.User{ID: chatID, UserName: "alice"},
+		Chat:      &tgbotapi.Chat{ID: chatID, Type: "private"},
+		Text:      text,
+		Entities:  []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: len(name)}},
+	}}
+}
+
+func callback(chatID int64, data string) tgbotapi.Update {
+	return tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{
+		ID:      "cb1",
+		From:    &tgbotapi.User{ID: chatID},
+		Message: &tgbotapi.Message{MessageID: 100, Chat: &tgbotapi.Chat{ID: chatID, Type: "private"}, Text: "Publication failed."},
+		Data:    data,
+	}}
+}
+
+func TestNewUsesEndpoint(t *testing.T) {
+	b, tg, _ := setupBot(t)
+	if b.Username() != "schedule_bot" {
+		t.Errorf("Unexpected username %q", b.Username())
+	}
+	if len(tg.sent("getMe")) != 1 {
+		t.Error("Expected getMe on the fake endpoint")
+	}
+}
+
+func TestStartLinksChat(t *testing.T) {
+	b, tg, store := setupBot(t)
+	ctx := context.Background()
+
+	b.HandleUpdate(ctx, command(10, "/start wrong"))
+	if !strings.Contains(tg.lastText(t), "invalid or expired") {
+		t.Errorf("Unexpected reply %q", tg.lastText(t))
+	}
+	b.HandleUpdate(ctx, command(10, "/start code"))
+	if !strings.HasPrefix(tg.lastText(t), "Chat linked") || store.chats[10] != "1" {
+		t.Errorf("Expected linked chat, got %q %v", tg.lastText(t), store.chats)
+	}
+}
+
+func TestGroupChatRefused(t *testing.T) {
+	b, tg, store := setupBot(t)
+	ctx := context.Background()
+
+	start := command(-20, "/start code")
+	start.Message.From.ID = 10
+	start.Message.Chat.Type = "group"
+	b.HandleUpdate(ctx, start)
+	if tg.lastText(t) != privateOnly || len(store.chats) != 0 {
+		t.Errorf("Expected group link to be refused, got %q %v", tg.lastText(t), store.chats)
+	}
+
+	// чат уже привязан, но кнопку нажал другой участник
+	store.chats[-20] = "1"
+	press := callback(-20, "cancel:5")
+	press.CallbackQuery.From.ID = 11
+	press.CallbackQuery.Message.Chat.Type = "group"
+	b.HandleUpdate(ctx, press)
+	answers := tg.sent("answerCallbackQuery")
+	if len(answers) != 1 || answers[0].Get("text") != privateOnly {
+		t.Errorf("Expected refused callback, got %v", answers)
+	}
+}
+
+func TestCommandsRequireLink(t *testing.T) {
+	b, tg, _ := setupBot(t)
+	b.HandleUpdate(context.Background(), command(10, "/upcoming"))
+	if !strings.Contains(tg.lastText(t), "not linked") {
+		t.Errorf("Unexpected reply %q", tg.lastText(t))
+	}
+}
+
+func TestUpcoming(t *testing.T) {
+	b, tg, store := setupBot(t)
+	store.chats[10] = "1"
+	at := time.Date(2026, 10, 20, 9, 0, 0, 0, time.UTC)
+	store.upcoming = []domain.CalendarEvent{
+		{ID_destination: 1, ID_post: 5, Title: "Launch", PlatformName: "Telegram", Status: "scheduled", Sheduled_for: at},
+		{ID_destination: 2, ID_post: 5, Title: "Launch", PlatformName: "VK", Status: "held", Sheduled_for: at},
+	}
+
+	b.HandleUpdate(context.Background(), command(10, "/upcoming"))
+	msg := tg.sent("sendMessage")[0]
+	if text := msg.Get("text"); !strings.Contains(text, "#5 2026-10-20 12:00 [Telegram] Launch\n") || !strings.Contains(text, "[VK] Launch (held)") {
+		t.Errorf("Unexpected text %q", text)
+	}
+	markup := msg.Get("reply_markup")
+	if !strings.Contains(markup, moveData(5, at.Add(time.Hour))) || !strings.Contains(markup, `"cancel:5"`) {
+		t.Errorf("Unexpected keyboard %s", markup)
+	}
+	if strings.Count(markup, `"cancel:5"`) != 1 {
+		t.Errorf("Expected one row per post, got %s", markup)
+	}
+}
+
+func TestPostCommand(t *testing.T) {
+	b, tg, store := setupBot(t)
+	store.chats[10] = "1"
+
+	b.HandleUpdate(context.Background(), command(10, "/post 2026-10-20 12:00 Hello world\nFirst line\nSecond line"))
+	if len(store.created) != 1 {
+		t.Fatalf("Expected post to be created, reply %q", tg.lastText(t))
+	}
+	got := store.created[0]
+	if got.Title != "Hello world" || got.Content != "First line\nSecond line" || got.Status != domain.PostInReview || got.ID_workspace != 1 {
+		t.Errorf("Unexpected request %+v", got)
+	}
+	if !got.Sheduled_for.Equal(time.Date(2026, 10, 20, 9, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected time in user timezone, got %v", got.Sheduled_for)
+	}
+	if len(store.events) != 1 || store.events[0] != domain.EventPostCreated {
+		t.Errorf("Expected post.created event, got %v", store.events)
+	}
+	if !strings.Contains(tg.lastText(t), "Post #42 created") {
+		t.Errorf("Unexpected reply %q", tg.lastText(t))
+	}
+
+	b.HandleUpdate(context.Background(), command(10, "/post No content"))
+	if len(store.created) != 1 || !strings.HasPrefix(tg.lastText(t), "Usage") {
+		t.Errorf("Expected usage, got %q", tg.lastText(t))
+	}
+}
+
+func TestRescheduleAndCancel(t *testing.T) {
+	b, tg, store := setupBot(t)
+	store.chats[10] = "1"
+	ctx := context.Background()
+
+	b.HandleUpdate(ctx, command(10, "/reschedule 5 2026-10-21 08:30"))
+	if len(store.rescheduled) != 1 || !store.rescheduled[0].Equal(time.Date(2026, 10, 21, 5, 30, 0, 0, time.UTC)) {
+		t.Fatalf("Unexpected reschedule %v, reply %q", store.rescheduled, tg.lastText(t))
+	}
+	if !strings.Contains(tg.lastText(t), "rescheduled to 2026-10-21 08:30") {
+		t.Errorf("Unexpected reply %q", tg.lastText(t))
+	}
+	b.HandleUpdate(ctx, command(10, "/reschedule 6 2026-10-21 08:30"))
+	if !strings.Contains(tg.lastText(t), "#6 not found") {
+		t.Errorf("Unexpected reply %q", tg.lastText(t))
+	}
+	b.HandleUpdate(ctx, command(10, "/cancel 5"))
+	if len(store.cancelled) != 1 || !strings.Contains(tg.lastText(t), "cancelled") {
+		t.Errorf("Unexpected cancel %v, reply %q", store.cancelled, tg.lastText(t))
+	}
+}
+
+func TestViewerCannotChangeSchedule(t *testing.T) {
+	b, tg, store := setupBot(t)
+	store.chats[10] = "1"
+	store.role = domain.RoleViewer
+
+	b.HandleUpdate(context.Background(), command(10, "/cancel 5"))
+	if len(store.cancelled) != 0 || !strings.Contains(tg.lastText(t), "does not allow") {
+		t.Errorf("Expected refusal, got %v %q", store.cancelled, tg.lastText(t))
+	}
+}
+
+func TestMoveButton(t *testing.T) {
+	b, tg, store := setupBot(t)
+	store.chats[10] = "1"
+	at := time.Date(2026, 10, 20, 10, 0, 0, 0, time.UTC)
+
+	b.HandleUpdate(context.Background(), callback(10, moveData(5, at)))
+	if len(store.rescheduled) != 1 || !store.rescheduled[0].Equal(at) {
+		t.Fatalf("Unexpected reschedule %v", store.rescheduled)
+	}
+	answers := tg.sent("answerCallbackQuery")
+	if len(answers) != 1 || answers[0].Get("callback_query_id") != "cb1" || !strings.Contains(answers[0].Get("text"), "rescheduled") {
+		t.Errorf("Unexpected answers %v", answers)
+	}
+}
+
+func TestNotifyAndRetry(t *testing.T) {
+	b, tg, store := setupBot(t)
+	store.chats[10] = "1"
+	ctx := context.Background()
+	post, first, second := 5, 7, 8
+	failed := []domain.Notification{
+		{Kind: domain.NotificationPublicationFailed, ID_post: &post, ID_destination: &first},
+		{Kind: domain.NotificationPublicationFailed, ID_post: &post, ID_destination: &second},
+	}
+
+	if err := b.Notify(ctx, "1", "Telegram publication of post 5 failed: chat not found", failed); err != nil {
+		t.Fatal(err)
+	}
+	msg := tg.sent("sendMessage")[0]
+	markup := msg.Get("reply_markup")
+	if msg.Get("chat_id") != "10" || !strings.Contains(msg.Get("text"), "chat not found") || !strings.Contains(markup, `"retry:7"`) || !strings.Contains(markup, `"retry:8"`) {
+		t.Errorf("Unexpected notification %v", msg)
+	}
+
+	update := callback(10, "retry:7")
+	update.CallbackQuery.Message.ReplyMarkup = &tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("Retry post #5", "retry:7")),
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("Retry post #5", "retry:8")),
+	}}
+	b.HandleUpdate(ctx, update)
+	if len(store.retried) != 1 || store.retried[0] != 7 {
+		t.Fatalf("Expected retry, got %v", store.retried)
+	}
+	edits := tg.sent("editMessageText")
+	if len(edits) != 1 || !strings.Contains(edits[0].Get("text"), "retry scheduled") {
+		t.Fatalf("Expected edited notification, got %v", edits)
+	}
+	if markup := edits[0].Get("reply_markup"); strings.Contains(markup, `"retry:7"`) || !strings.Contains(markup, `"retry:8"`) {
+		t.Errorf("Expected only the pressed button removed, got %s", markup)
+	}
+}
+
+func TestNotifyTruncatesLongText(t *testing.T) {
+	b, tg, store := setupBot(t)
+	store.chats[10] = "1"
+	if err := b.Notify(context.Background(), "1", strings.Repeat("x", 5000), nil); err != nil {
+		t.Fatal(err)
+	}
+	msg := tg.sent("sendMessage")[0]
+	if n := len([]rune(msg.Get("text"))); n != maxMessageLength || msg.Get("reply_markup") != "" {
+		t.Errorf("Unexpected message of %d runes, markup %q", n, msg.Get("reply_markup"))
+	}
+}
+
+func TestRunPollsUpdates(t *testing.T) {
+	b, tg, _ := setupBot(t)
+	b.PollTimeout = 0
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tg.updates = []string{`{"update_id":3,"message":{"message_id":1,"chat":{"id":10,"type":"private"},"date":0,"text":"/help","entities":[{"type":"bot_command","offset":0,"length":5}]}}`}
+	tg.idle = cancel
+
+	done := make(chan struct{})
+	go func() {
+		b.Run(ctx)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not stop")
+	}
+	polls := tg.sent("getUpdates")
+	if len(polls) < 2 || polls[1].Get("offset") != "4" {
+		t.Errorf("Expected offset after update, got %v", polls)
+	}
+	if !strings.HasPrefix(tg.lastText(t), "Commands:") {
+		t.Errorf("Unexpected reply %q", tg.lastText(t))
+	}
+}
+
+func TestNotifySkipsUnlinkedUser(t *testing.T) {
+	b, tg, _ := setupBot(t)
+	if err := b.Notify(context.Background(), "1", "failed", nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(tg.sent("sendMessage")) != 0 {
+		t.Error("Expected no notification for unlinked user")
+	}
+}
@@ -0,0 +1,51 @@
+package tracing
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// PgxLogger превращает записи pgx о выполненных запросах в клиентские спаны: в pgx v4
+// нет трассировщика, а логгер получает контекст запроса, текст SQL и длительность.
+// Спан создаётся только внутри трассы — фоновые опросы без родителя не засоряют коллектор.
+// Аргументы запроса в спан не попадают.
+type PgxLogger struct{}
+
+func (PgxLogger) Log(ctx context.Context, level pgx.LogLevel, msg string, data map[string]interface{}) {
+	elapsed, ok := data["time"].(time.Duration)
+	if !ok || !trace.SpanContextFromContext(ctx).IsValid() {
+		return
+	}
+	end := time.Now()
+	sql, _ := data["sql"].(string)
+	operation := msg
+	if fields := strings.Fields(sql); len(fields) > 0 {
+		operation = strings.ToUpper(fields[0])
+	}
+	_, span := Start(ctx, operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithTimestamp(end.Add(-elapsed)),
+		trace.WithAttributes(semconv.DBSystemNamePostgreSQL, semconv.DBOperationName(operation)),
+	)
+	if sql != "" {
+		span.SetAttributes(semconv.DBQueryText(sql))
+	}
+	if rows, ok := data["rowCount"].(int); ok {
+		span.SetAttributes(semconv.DBResponseReturnedRows(rows))
+	}
+	if tag, ok := data["commandTag"].(pgconn.CommandTag); ok {
+		span.SetAttributes(semconv.DBResponseReturnedRows(int(tag.RowsAffected())))
+	}
+	if err, ok := data["err"].(error); ok {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End(trace.WithTimestamp(end))
+}
@@ -0,0 +1,93 @@
+// Package tracing — трассировка OpenTelemetry. Путь публикации: HTTP-запрос → репозиторий (pgx)
+// → планировщик → Kafka (контекст в заголовках сообщения) → обработчик → Telegram/VK.
+// Планировщик запускает публикацию в отдельной трассе со ссылкой на запрос, создавший пост.
+package tracing
+
+import (
+	"context"
+	"hexlet/internal/config"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "hexlet"
+
+// enabled — спаны экспортируются; без этого не создаются спаны запросов pgx
+var enabled bool
+
+// Setup настраивает экспорт спанов в OTLP/HTTP коллектор. Выключенная трассировка
+// оставляет no-op провайдер: спаны не записываются, контекст по-прежнему передаётся.
+// shutdown отправляет накопленные спаны.
+func Setup(ctx context.Context, cfg *config.TracingConfig) (shutdown func(context.Context) error, err error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	if cfg.Disabled || cfg.Endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(cfg.Endpoint))
+	if err != nil {
+		return nil, err
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(cfg.ServiceName)))
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	enabled = true
+	return provider.Shutdown, nil
+}
+
+func Enabled() bool {
+	return enabled
+}
+
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, opts...)
+}
+
+// End завершает спан, отмечая ошибку
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Detach — контекст parent с трассой из from: фоновая работа продолжает трассу запроса,
+// но не отменяется вместе с ним
+func Detach(parent context.Context, from context.Context) context.Context {
+	return trace.ContextWithSpanContext(parent, trace.SpanContextFromContext(from))
+}
+
+// TraceParent — текущий контекст трассы в формате W3C traceparent для хранения в БД;
+// nil, если трассы нет
+func TraceParent(ctx context.Context) *string {
+	carrier := propagation.MapCarrier{}
+	propagation.TraceContext{}.Inject(ctx, carrier)
+	value, ok := carrier["traceparent"]
+	if !ok {
+		return nil
+	}
+	return &value
+}
+
+// LinkTo — ссылка на трассу, сохранённую TraceParent; без трассы ссылка пустая и SDK её отбрасывает
+func LinkTo(traceParent *string) trace.Link {
+	if traceParent == nil {
+		return trace.Link{}
+	}
+	ctx := propagation.TraceContext{}.Extract(context.Background(), propagation.MapCarrier{"traceparent": *traceParent})
+	return trace.Link{SpanContext: trace.SpanContextFromContext(ctx)}
+}
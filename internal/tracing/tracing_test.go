@@ -0,0 +1,131 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const incoming = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func setupRecorder(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { provider.Shutdown(context.Background()) })
+	return recorder
+}
+
+func attr(span sdktrace.ReadOnlySpan, key string) attribute.Value {
+	for _, kv := range span.Attributes() {
+		if string(kv.Key) == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestMiddlewareContinuesIncomingTrace(t *testing.T) {
+	recorder := setupRecorder(t)
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Middleware())
+	var handlerSpan trace.SpanContext
+	r.GET("/posts/:id", func(c *gin.Context) {
+		handlerSpan = trace.SpanContextFromContext(c.Request.Context())
+		c.Status(http.StatusInternalServerError)
+	})
+
+	req := httptest.NewRequest("GET", "/posts/7", nil)
+	req.Header.Set("traceparent", incoming)
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	span := spans[0]
+	assert.Equal(t, "GET /posts/:id", span.Name())
+	assert.Equal(t, trace.SpanKindServer, span.SpanKind())
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.SpanContext().TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", span.Parent().SpanID().String())
+	assert.Equal(t, span.SpanContext().SpanID(), handlerSpan.SpanID())
+	assert.Equal(t, "/posts/:id", attr(span, "http.route").AsString())
+	assert.Equal(t, int64(500), attr(span, "http.response.status_code").AsInt64())
+	assert.Equal(t, codes.Error, span.Status().Code)
+}
+
+func TestPgxLoggerSpans(t *testing.T) {
+	recorder := setupRecorder(t)
+	logger := PgxLogger{}
+
+	// вне трассы спан не создаётся
+	logger.Log(context.Background(), pgx.LogLevelInfo, "Query", map[string]interface{}{"sql": "SELECT 1", "time": time.Millisecond})
+	assert.Empty(t, recorder.Ended())
+
+	ctx, parent := Start(context.Background(), "request")
+	logger.Log(ctx, pgx.LogLevelInfo, "Query", map[string]interface{}{
+		"sql": "  select id from posts where user_id = $1", "args": []interface{}{"secret"}, "time": 20 * time.Millisecond, "rowCount": 3,
+	})
+	logger.Log(ctx, pgx.LogLevelError, "Exec", map[string]interface{}{
+		"sql": "UPDATE posts SET title = $1", "err": errors.New("deadlock detected"), "time": time.Millisecond,
+	})
+	// служебные записи без длительности пропускаются
+	logger.Log(ctx, pgx.LogLevelInfo, "closed connection", nil)
+	parent.End()
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 3)
+	query, exec := spans[0], spans[1]
+	assert.Equal(t, "SELECT", query.Name())
+	assert.Equal(t, parent.SpanContext().SpanID(), query.Parent().SpanID())
+	assert.Equal(t, trace.SpanKindClient, query.SpanKind())
+	assert.Equal(t, 20*time.Millisecond, query.EndTime().Sub(query.StartTime()))
+	assert.Equal(t, "postgresql", attr(query, "db.system.name").AsString())
+	assert.Equal(t, int64(3), attr(query, "db.response.returned_rows").AsInt64())
+	for _, kv := range query.Attributes() {
+		assert.NotContains(t, kv.Value.Emit(), "secret")
+	}
+	assert.Equal(t, "UPDATE", exec.Name())
+	assert.Equal(t, codes.Error, exec.Status().Code)
+}
+
+func TestTraceParentLink(t *testing.T) {
+	setupRecorder(t)
+	assert.Nil(t, TraceParent(context.Background()))
+	assert.False(t, LinkTo(nil).SpanContext.IsValid())
+
+	ctx, span := Start(context.Background(), "request")
+	defer span.End()
+	traceParent := TraceParent(ctx)
+	require.NotNil(t, traceParent)
+	link := LinkTo(traceParent)
+	assert.Equal(t, span.SpanContext().TraceID(), link.SpanContext.TraceID())
+	assert.Equal(t, span.SpanContext().SpanID(), link.SpanContext.SpanID())
+}
+
+func TestDetachKeepsTraceWithoutCancel(t *testing.T) {
+	setupRecorder(t)
+	ctx, span := Start(context.Background(), "request")
+	defer span.End()
+	requestCtx, cancel := context.WithCancel(ctx)
+	cancel()
+
+	detached := Detach(context.Background(), requestCtx)
+	assert.NoError(t, detached.Err())
+	assert.Equal(t, span.SpanContext().SpanID(), trace.SpanContextFromContext(detached).SpanID())
+}
@@ -0,0 +1,244 @@
+package verification
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hexlet/internal/domain"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultTelegramAPI = "https://api.telegram.org"
+	DefaultVKAPI       = "https://api.vk.com/method"
+
+	vkAPIVersion = "5.131"
+	// Бит доступа к стене в account.getAppPermissions
+	vkWallPermission = 8192
+	// VK: "User authorization failed"
+	vkAuthErrorCode = 5
+)
+
+// Verifier проверяет, что учётные данные платформы рабочие
+type Verifier interface {
+	Verify(ctx context.Context, platformName string, cfg domain.PlatformConfig) domain.VerificationReport
+}
+
+type Client struct {
+	http        *http.Client
+	telegramAPI string
+	vkAPI       string
+}
+
+func NewClient(httpClient *http.Client, telegramAPI string, vkAPI string) *Client {
+	return &Client{
+		http:        httpClient,
+		telegramAPI: strings.TrimRight(telegramAPI, "/"),
+		vkAPI:       strings.TrimRight(vkAPI, "/"),
+	}
+}
+
+func (c *Client) Verify(ctx context.Context, platformName string, cfg domain.PlatformConfig) domain.VerificationReport {
+	report := domain.VerificationReport{CheckedAt: time.Now()}
+	switch {
+	case platformName == domain.PlatformTelegram && cfg.Telegram != nil:
+		c.verifyTelegram(ctx, *cfg.Telegram, &report)
+	case platformName == domain.PlatformVK && cfg.VK != nil:
+		c.verifyVK(ctx, *cfg.VK, &report)
+	default:
+		report.Add("config", false, fmt.Sprintf("no %s config", platformName))
+	}
+	report.OK = len(report.Checks) > 0
+	for _, check := range report.Checks {
+		if !check.OK {
+			report.OK = false
+		}
+	}
+	return report
+}
+
+// --- Telegram ---
+
+type telegramResponse struct {
+	OK          bool            `json:"ok"`
+	ErrorCode   int             `json:"error_code"`
+	Description string          `json:"description"`
+	Result      json.RawMessage `json:"result"`
+}
+
+func (c *Client) verifyTelegram(ctx context.Context, cfg domain.TelegramConfig, report *domain.VerificationReport) {
+	var bot struct {
+		ID       int64  `json:"id"`
+		Username string `json:"username"`
+	}
+	resp, err := c.telegramCall(ctx, cfg.BotToken, "getMe", nil, &bot)
+	if err != nil {
+		report.Add("bot_token", false, err.Error())
+		report.AuthFailed = resp.ErrorCode == http.StatusUnauthorized
+		return
+	}
+	report.Add("bot_token", true, "@"+bot.Username)
+
+	var chat struct {
+		Type  string `json:"type"`
+		Title string `json:"title"`
+	}
+	if _, err := c.telegramCall(ctx, cfg.BotToken, "getChat", url.Values{"chat_id": {cfg.ChatID}}, &chat); err != nil {
+		report.Add("chat", false, err.Error())
+		return
+	}
+	report.Add("chat", true, chat.Title)
+
+	var member struct {
+		Status          string `json:"status"`
+		CanPostMessages bool   `json:"can_post_messages"`
+	}
+	params := url.Values{"chat_id": {cfg.ChatID}, "user_id": {strconv.FormatInt(bot.ID, 10)}}
+	if _, err := c.telegramCall(ctx, cfg.BotToken, "getChatMember", params, &member); err != nil {
+		report.Add("admin_rights", false, err.Error())
+		return
+	}
+	switch {
+	case member.Status == "creator":
+		report.Add("admin_rights", true, member.Status)
+	case member.Status == "administrator" && (chat.Type != "channel" || member.CanPostMessages):
+		report.Add("admin_rights", true, member.Status)
+	case member.Status == "administrator":
+		report.Add("admin_rights", false, "bot is administrator without can_post_messages")
+	default:
+		report.Add("admin_rights", false, "bot is not an administrator: "+member.Status)
+	}
+}
+
+func (c *Client) telegramCall(ctx context.Context, token string, method string, params url.Values, result interface{}) (telegramResponse, error) {
+	var resp telegramResponse
+	endpoint := fmt.Sprintf("%s/bot%s/%s", c.telegramAPI, token, method)
+	if len(params) > 0 {
+		endpoint += "?" + params.Encode()
+	}
+	if err := c.getJSON(ctx, endpoint, &resp); err != nil {
+		return resp, err
+	}
+	if !resp.OK {
+		return resp, fmt.Errorf("%s: %d %s", method, resp.ErrorCode, resp.Description)
+	}
+	if err := json.Unmarshal(resp.Result, result); err != nil {
+		return resp, fmt.Errorf("%s: %w", method, err)
+	}
+	return resp, nil
+}
+
+// --- VK ---
+
+type vkError struct {
+	Code    int    `json:"error_code"`
+	Message string `json:"error_msg"`
+}
+
+func (e *vkError) Error() string {
+	return fmt.Sprintf("%d %s", e.Code, e.Message)
+}
+
+type vkResponse struct {
+	Response json.RawMessage `json:"response"`
+	Error    *vkError        `json:"error"`
+}
+
+func (c *Client) verifyVK(ctx context.Context, cfg domain.VKConfig, report *domain.VerificationReport) {
+	if strings.HasPrefix(cfg.OwnerID, "-") {
+		var groups []struct {
+			ID   int64  `json:"id"`
+			Name string `json:"name"`
+		}
+		params := url.Values{"group_id": {strings.TrimPrefix(cfg.OwnerID, "-")}}
+		if err := c.vkCall(ctx, cfg.AccessToken, "groups.getById", params, &groups); err != nil {
+			report.Add("group", false, err.Error())
+			report.AuthFailed = isVKAuthError(err)
+			return
+		}
+		if len(groups) == 0 {
+			report.Add("group", false, "group not found")
+			return
+		}
+		report.Add("group", true, groups[0].Name)
+	}
+
+	// Ключ сообщества: groups.getTokenPermissions, пользовательский токен: account.getAppPermissions
+	var perms struct {
+		Permissions []struct {
+			Name string `json:"name"`
+		} `json:"permissions"`
+	}
+	err := c.vkCall(ctx, cfg.AccessToken, "groups.getTokenPermissions", nil, &perms)
+	if err == nil {
+		for _, p := range perms.Permissions {
+			if p.Name == "wall" {
+				report.Add("wall_permission", true, "community token")
+				return
+			}
+		}
+		report.Add("wall_permission", false, "community token has no wall permission")
+		return
+	}
+	var mask int
+	if err := c.vkCall(ctx, cfg.AccessToken, "account.getAppPermissions", nil, &mask); err != nil {
+		report.Add("wall_permission", false, err.Error())
+		report.AuthFailed = isVKAuthError(err)
+		return
+	}
+	if mask&vkWallPermission == 0 {
+		report.Add("wall_permission", false, "user token has no wall permission")
+		return
+	}
+	report.Add("wall_permission", true, "user token")
+}
+
+func (c *Client) vkCall(ctx context.Context, token string, method string, params url.Values, result interface{}) error {
+	if params == nil {
+		params = url.Values{}
+	}
+	params.Set("access_token", token)
+	params.Set("v", vkAPIVersion)
+	var resp vkResponse
+	if err := c.getJSON(ctx, c.vkAPI+"/"+method+"?"+params.Encode(), &resp); err != nil {
+		return err
+	}
+	if resp.Error != nil {
+		return fmt.Errorf("%s: %w", method, resp.Error)
+	}
+	if err := json.Unmarshal(resp.Response, result); err != nil {
+		return fmt.Errorf("%s: %w", method, err)
+	}
+	return nil
+}
+
+func isVKAuthError(err error) bool {
+	var vkErr *vkError
+	return errors.As(err, &vkErr) && vkErr.Code == vkAuthErrorCode
+}
+
+func (c *Client) getJSON(ctx context.Context, endpoint string, dst interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		// url.Error содержит адрес с токеном, наружу отдаём только причину
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(dst); err != nil {
+		return fmt.Errorf("invalid response (HTTP %d): %w", resp.StatusCode, err)
+	}
+	return nil
+}
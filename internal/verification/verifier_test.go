@@ -0,0 +1,114 @@
+package verification
+
+import (
+	"context"
+	"encoding/json"
+	"hexlet/internal/domain"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func fakeTelegram(t *testing.T, memberStatus string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/bot123:good/getMe":
+			w.Write([]byte(`{"ok":true,"result":{"id":42,"username":"test_bot"}}`))
+		case "/bot123:good/getChat":
+			assert.Equal(t, "@channel", r.URL.Query().Get("chat_id"))
+			w.Write([]byte(`{"ok":true,"result":{"type":"channel","title":"Channel"}}`))
+		case "/bot123:good/getChatMember":
+			assert.Equal(t, "42", r.URL.Query().Get("user_id"))
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"ok":     true,
+				"result": map[string]interface{}{"status": memberStatus, "can_post_messages": true},
+			})
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"ok":false,"error_code":401,"description":"Unauthorized"}`))
+		}
+	}))
+}
+
+func TestVerifyTelegram_OK(t *testing.T) {
+	srv := fakeTelegram(t, "administrator")
+	defer srv.Close()
+	c := NewClient(srv.Client(), srv.URL, srv.URL)
+
+	report := c.Verify(context.Background(), domain.PlatformTelegram, domain.PlatformConfig{
+		Telegram: &domain.TelegramConfig{BotToken: "123:good", ChatID: "@channel"},
+	})
+
+	assert.True(t, report.OK)
+	assert.False(t, report.AuthFailed)
+	assert.Len(t, report.Checks, 3)
+	assert.Equal(t, "@test_bot", report.Checks[0].Message)
+}
+
+func TestVerifyTelegram_NotAdmin(t *testing.T) {
+	srv := fakeTelegram(t, "member")
+	defer srv.Close()
+	c := NewClient(srv.Client(), srv.URL, srv.URL)
+
+	report := c.Verify(context.Background(), domain.PlatformTelegram, domain.PlatformConfig{
+		Telegram: &domain.TelegramConfig{BotToken: "123:good", ChatID: "@channel"},
+	})
+
+	assert.False(t, report.OK)
+	assert.Equal(t, "admin_rights", report.Checks[2].Name)
+	assert.False(t, report.Checks[2].OK)
+}
+
+func TestVerifyTelegram_Revoked(t *testing.T) {
+	srv := fakeTelegram(t, "administrator")
+	defer srv.Close()
+	c := NewClient(srv.Client(), srv.URL, srv.URL)
+
+	report := c.Verify(context.Background(), domain.PlatformTelegram, domain.PlatformConfig{
+		Telegram: &domain.TelegramConfig{BotToken: "123:revoked", ChatID: "@channel"},
+	})
+
+	assert.False(t, report.OK)
+	assert.True(t, report.AuthFailed)
+}
+
+func TestVerifyVK(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("access_token") != "good" {
+			w.Write([]byte(`{"error":{"error_code":5,"error_msg":"User authorization failed"}}`))
+			return
+		}
+		switch r.URL.Path {
+		case "/groups.getById":
+			assert.Equal(t, "100", r.URL.Query().Get("group_id"))
+			w.Write([]byte(`{"response":[{"id":100,"name":"Group"}]}`))
+		case "/groups.getTokenPermissions":
+			w.Write([]byte(`{"error":{"error_code":27,"error_msg":"Group authorization failed"}}`))
+		case "/account.getAppPermissions":
+			w.Write([]byte(`{"response":8192}`))
+		}
+	}))
+	defer srv.Close()
+	c := NewClient(srv.Client(), srv.URL, srv.URL)
+
+	report := c.Verify(context.Background(), domain.PlatformVK, domain.PlatformConfig{
+		VK: &domain.VKConfig{AccessToken: "good", OwnerID: "-100"},
+	})
+	assert.True(t, report.OK)
+	assert.Equal(t, "user token", report.Checks[1].Message)
+
+	report = c.Verify(context.Background(), domain.PlatformVK, domain.PlatformConfig{
+		VK: &domain.VKConfig{AccessToken: "expired", OwnerID: "-100"},
+	})
+	assert.False(t, report.OK)
+	assert.True(t, report.AuthFailed)
+}
+
+func TestVerify_MissingConfig(t *testing.T) {
+	c := NewClient(http.DefaultClient, DefaultTelegramAPI, DefaultVKAPI)
+
+	report := c.Verify(context.Background(), domain.PlatformVK, domain.PlatformConfig{})
+	assert.False(t, report.OK)
+}
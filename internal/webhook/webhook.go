@@ -0,0 +1,217 @@
+// Package webhook подписывает и отправляет события подписчикам.
+//
+// Подпись: X-Webhook-Signature: sha256=<hex HMAC-SHA256(secret, "<timestamp>.<body>")>,
+// timestamp (unix, секунды) передаётся в X-Webhook-Timestamp. Получатель должен
+// сверить подпись и отбросить запросы со слишком старым timestamp.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderEventID   = "X-Webhook-Id"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+
+	// ответ получателя в журнал не пишется, читается только начало для текста ошибки
+	responseLimit = 512
+)
+
+// паузы перед повторными попытками; после последней доставка считается неудачной
+var backoff = []time.Duration{
+	time.Minute,
+	5 * time.Minute,
+	30 * time.Minute,
+	2 * time.Hour,
+	6 * time.Hour,
+}
+
+// MaxAttempts — число попыток доставки, включая первую
+var MaxAttempts = len(backoff) + 1
+
+// Backoff — пауза после неудачной попытки attempt (с 1); false, если попыток больше нет
+func Backoff(attempt int) (time.Duration, bool) {
+	if attempt < 1 || attempt > len(backoff) {
+		return 0, false
+	}
+	return backoff[attempt-1], true
+}
+
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify — проверка подписи на стороне получателя
+func Verify(secret string, timestamp int64, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
+
+type Request struct {
+	Url      string
+	Secret   string
+	Event    string
+	ID_event string
+	Delivery int
+	Body     []byte
+}
+
+// ErrAddressNotAllowed — адрес подписки ведёт во внутреннюю сеть сервиса
+var ErrAddressNotAllowed = errors.New("webhook url must resolve to a public address")
+
+// диапазоны, не покрытые методами netip.Addr: "этот" хост, CGNAT, сеть бенчмарков, зарезервированные
+var reserved = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+}
+
+// PublicAddr: false для loopback, частных, link-local (в том числе 169.254.169.254) и зарезервированных адресов
+func PublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsValid() || addr.IsUnspecified() || addr.IsLoopback() || addr.IsPrivate() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() || addr.IsMulticast() {
+		return false
+	}
+	for _, prefix := range reserved {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+type Resolver interface {
+	LookupNetIP(ctx context.Context, network string, host string) ([]netip.Addr, error)
+}
+
+// CheckURL проверяет адрес подписки при сохранении: все адреса хоста должны быть публичными.
+// DNS может измениться позже, поэтому Client проверяет адрес ещё раз при соединении.
+func CheckURL(ctx context.Context, resolver Resolver, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("webhook url must be http or https")
+	}
+	host := u.Hostname()
+	addrs := []netip.Addr{}
+	if addr, err := netip.ParseAddr(host); err == nil {
+		addrs = append(addrs, addr)
+	} else if addrs, err = resolver.LookupNetIP(ctx, "ip", host); err != nil {
+		return fmt.Errorf("cannot resolve %s", host)
+	}
+	if len(addrs) == 0 {
+		return fmt.Errorf("cannot resolve %s", host)
+	}
+	for _, addr := range addrs {
+		if !PublicAddr(addr) {
+			return ErrAddressNotAllowed
+		}
+	}
+	return nil
+}
+
+// publicOnly вызывается после разрешения имени, address — ip:port, к которому идёт соединение
+func publicOnly(network string, address string, _ syscall.RawConn) error {
+	addr, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if !PublicAddr(addr.Addr()) {
+		return ErrAddressNotAllowed
+	}
+	return nil
+}
+
+type Client struct {
+	HTTP *http.Client
+	Now  func() time.Time
+}
+
+// NewClient соединяется только с публичными адресами
+func NewClient(timeout time.Duration) *Client {
+	return newClient(timeout, publicOnly)
+}
+
+func newClient(timeout time.Duration, control func(network string, address string, c syscall.RawConn) error) *Client {
+	dialer := &net.Dialer{Timeout: timeout, Control: control}
+	return &Client{
+		HTTP: &http.Client{
+			Timeout: timeout,
+			// без прокси: соединение идёт прямо к проверенному адресу
+			Transport: &http.Transport{
+				DialContext:         dialer.DialContext,
+				TLSHandshakeTimeout: timeout,
+				MaxIdleConns:        100,
+				IdleConnTimeout:     90 * time.Second,
+			},
+			// перенаправления не выполняются: подпись предназначена конкретному адресу
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		Now: time.Now,
+	}
+}
+
+// Send отправляет событие. Код ответа возвращается, если сервер ответил;
+// ошибка — для сетевых сбоев и ответов вне 2xx.
+func (c *Client) Send(ctx context.Context, req Request) (int, error) {
+	timestamp := c.Now().Unix()
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, req.Url, bytes.NewReader(req.Body))
+	if err != nil {
+		return 0, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("User-Agent", "hexlet-webhooks/1.0")
+	httpReq.Header.Set(HeaderEvent, req.Event)
+	httpReq.Header.Set(HeaderEventID, req.ID_event)
+	httpReq.Header.Set(HeaderDelivery, strconv.Itoa(req.Delivery))
+	httpReq.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	httpReq.Header.Set(HeaderSignature, Sign(req.Secret, timestamp, req.Body))
+	resp, err := c.HTTP.Do(httpReq)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, responseLimit))
+		return resp.StatusCode, nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, responseLimit))
+	return resp.StatusCode, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, bytes.TrimSpace(body))
+}
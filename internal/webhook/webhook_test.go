@@ -0,0 +1,162 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	body := []byte(`{"event":"post.created"}`)
+	sig := Sign("secret", 1700000000, body)
+	if !strings.HasPrefix(sig, "sha256=") || len(sig) != len("sha256=")+64 {
+		t.Fatalf("Unexpected signature %q", sig)
+	}
+	if !Verify("secret", 1700000000, body, sig) {
+		t.Error("Expected signature to verify")
+	}
+	if Verify("other", 1700000000, body, sig) || Verify("secret", 1700000001, body, sig) || Verify("secret", 1700000000, []byte("{}"), sig) {
+		t.Error("Signature must depend on secret, timestamp and body")
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	prev := time.Duration(0)
+	for attempt := 1; attempt < MaxAttempts; attempt++ {
+		d, ok := Backoff(attempt)
+		if !ok || d <= prev {
+			t.Fatalf("Attempt %d: expected growing delay, got %v %v", attempt, d, ok)
+		}
+		prev = d
+	}
+	if _, ok := Backoff(MaxAttempts); ok {
+		t.Error("Expected no retry after the last attempt")
+	}
+}
+
+func TestNewSecret(t *testing.T) {
+	a, err := NewSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := NewSecret()
+	if a == b || !strings.HasPrefix(a, "whsec_") {
+		t.Errorf("Unexpected secrets %q %q", a, b)
+	}
+}
+
+func TestSend(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	var got *http.Request
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	// тестовый сервер слушает loopback
+	c := newClient(time.Second, nil)
+	c.Now = func() time.Time { return now }
+	code, err := c.Send(context.Background(), Request{
+		Url: server.URL, Secret: "secret", Event: "destination.published", ID_event: "ev-1", Delivery: 7, Body: []byte(`{"a":1}`),
+	})
+	if err != nil || code != http.StatusNoContent {
+		t.Fatalf("Expected success, got %d %v", code, err)
+	}
+	if got.Header.Get(HeaderEvent) != "destination.published" || got.Header.Get(HeaderDelivery) != "7" || got.Header.Get(HeaderEventID) != "ev-1" {
+		t.Errorf("Unexpected headers %v", got.Header)
+	}
+	timestamp, _ := strconv.ParseInt(got.Header.Get(HeaderTimestamp), 10, 64)
+	if timestamp != now.Unix() || !Verify("secret", timestamp, body, got.Header.Get(HeaderSignature)) {
+		t.Errorf("Signature does not verify: %v", got.Header)
+	}
+}
+
+func TestSendFailures(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "/", http.StatusFound)
+			return
+		}
+		http.Error(w, "boom", http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	c := newClient(time.Second, nil)
+	code, err := c.Send(context.Background(), Request{Url: server.URL, Body: []byte("{}")})
+	if code != http.StatusInternalServerError || err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("Expected 500 with body in error, got %d %v", code, err)
+	}
+	code, err = c.Send(context.Background(), Request{Url: server.URL + "/redirect", Body: []byte("{}")})
+	if code != http.StatusFound || err == nil {
+		t.Errorf("Redirects must not be followed, got %d %v", code, err)
+	}
+	server.Close()
+	if code, err := c.Send(context.Background(), Request{Url: server.URL, Body: []byte("{}")}); code != 0 || err == nil {
+		t.Errorf("Expected network error, got %d %v", code, err)
+	}
+}
+
+type staticResolver map[string][]netip.Addr
+
+func (r staticResolver) LookupNetIP(ctx context.Context, network string, host string) ([]netip.Addr, error) {
+	if addrs, ok := r[host]; ok {
+		return addrs, nil
+	}
+	return nil, errors.New("no such host")
+}
+
+func TestCheckURL(t *testing.T) {
+	resolver := staticResolver{
+		"crm.example.com": {netip.MustParseAddr("93.184.216.34")},
+		"postgres":        {netip.MustParseAddr("172.18.0.3")},
+		// хотя бы один внутренний адрес — отказ
+		"mixed.example.com": {netip.MustParseAddr("93.184.216.34"), netip.MustParseAddr("10.0.0.5")},
+	}
+	cases := map[string]bool{
+		"https://crm.example.com/hook":            true,
+		"http://93.184.216.34:8080/hook":          true,
+		"http://postgres:5432/":                   false,
+		"http://mixed.example.com/":               false,
+		"http://127.0.0.1:9092/":                  false,
+		"http://169.254.169.254/latest/meta-data": false,
+		"http://[::1]/":                           false,
+		"http://[::ffff:192.168.1.1]/":            false,
+		"http://100.64.0.1/":                      false,
+		"http://0.0.0.0/":                         false,
+		"http://unknown.example.com/":             false,
+		"ftp://crm.example.com/":                  false,
+	}
+	for rawURL, allowed := range cases {
+		err := CheckURL(context.Background(), resolver, rawURL)
+		if allowed != (err == nil) {
+			t.Errorf("%s: expected allowed=%v, got %v", rawURL, allowed, err)
+		}
+	}
+}
+
+func TestClientRefusesPrivateAddress(t *testing.T) {
+	called := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer server.Close()
+
+	// имя могло указывать на публичный адрес при сохранении, проверяется и само соединение
+	code, err := NewClient(time.Second).Send(context.Background(), Request{Url: server.URL, Body: []byte("{}")})
+	if code != 0 || !errors.Is(err, ErrAddressNotAllowed) {
+		t.Errorf("Expected ErrAddressNotAllowed, got %d %v", code, err)
+	}
+	if called {
+		t.Error("Request must not reach a loopback address")
+	}
+}
@@ -2,15 +2,21 @@ package main
 
 import (
 	"context"
+	"errors"
 	"hexlet/internal/app"
 	"hexlet/internal/auth"
 	"hexlet/internal/config"
+	"hexlet/internal/metrics"
+	"hexlet/internal/repository"
+	"hexlet/internal/secrets"
 	storage "hexlet/internal/storage"
+	"hexlet/internal/tracing"
 	"log"
-	"time"
+	"net/http"
+	"os"
+	_ "time/tzdata" // в alpine-образе нет базы часовых поясов, нужна для users.timezone
 
 	"github.com/gin-gonic/gin"
-	"github.com/segmentio/kafka-go"
 	"go.uber.org/zap"
 )
 
@@ -27,6 +33,16 @@ func main() {
 
 	r := gin.Default()
 	ctx := context.Background()
+	tracingConfig, err := config.LoadTracingConfig()
+	if err != nil {
+		log.Fatal("Cannot load tracing config:", err)
+	}
+	// до подключения к БД: от трассировки зависит логгер пулов pgx
+	shutdownTracing, err := tracing.Setup(ctx, tracingConfig)
+	if err != nil {
+		log.Fatalf("failed to init tracing: %v", err)
+	}
+	defer shutdownTracing(context.Background())
 	mastercfg, err := config.LoadConfigMaster()
 	if err != nil {
 		log.Fatal("Cannot load master config:", err)
@@ -53,47 +69,69 @@ func main() {
 		log.Fatalf("failed to init logger: %v", err)
 	}
 	defer logger.Sync()
-	a := app.NewApp(ctx, dbpoolmaster, dbpoolslave, logger)
-	a.StartScheduler()
-	auth.NewAuth()
-	go func() {
-		time.Sleep(30 * time.Second)
-		readerConfig := kafka.ReaderConfig{
-			Brokers:     []string{"kafka:9092"},
-			Topic:       "publications.pending",
-			GroupID:     "hexlet-publications-worker",
-			MinBytes:    10e3,
-			MaxBytes:    10e6,
-			MaxWait:     5 * time.Second,
-			StartOffset: kafka.FirstOffset,
-		}
-		reader := kafka.NewReader(readerConfig)
-		defer reader.Close()
-		log.Println("Kafka consumer started. Waiting for messages...")
-		for {
-			msg, err := reader.ReadMessage(ctx)
-			if err != nil {
-				if err == context.Canceled {
-					log.Println("Kafka consumer stopped")
-					return
-				}
-				log.Printf("Error reading from Kafka: %v", err)
-				time.Sleep(2 * time.Second)
-				continue
-			}
-			log.Printf("Received Kafka message: %s", string(msg.Value))
-			a.StartBackgroundWorker(msg)
+	credcfg, err := config.LoadCredentialsConfig()
+	if err != nil {
+		log.Fatal("Cannot load credentials config:", err)
+	}
+	activeKeyID, masterKeys, err := secrets.ParseKeys(credcfg.MasterKeys)
+	if err != nil {
+		log.Fatalf("failed to parse credentials master keys: %v", err)
+	}
+	keyring, err := secrets.NewKeyring(activeKeyID, masterKeys)
+	if err != nil {
+		log.Fatalf("failed to init credentials keyring: %v", err)
+	}
+	// ./main reencrypt-credentials — перешифровать api_config активным ключом и выйти
+	if len(os.Args) > 1 && os.Args[1] == "reencrypt-credentials" {
+		repo := repository.NewRepository(dbpoolmaster, dbpoolslave, keyring, logger)
+		updated, err := repo.ReencryptCredentials(ctx)
+		if err != nil {
+			log.Fatalf("failed to reencrypt credentials: %v", err)
 		}
-	}()
+		log.Printf("Reencrypted credentials of %d platforms with key %q", updated, activeKeyID)
+		return
+	}
+	authConfig, err := config.LoadAuthConfig()
+	if err != nil {
+		log.Fatalf("Failed to load auth config: %v", err)
+	}
+	if err := auth.NewAuth(authConfig); err != nil {
+		log.Fatalf("Failed to configure auth providers: %v", err)
+	}
+	jwtConfig, err := config.LoadJWTConfig(authConfig.PublicURL)
+	if err != nil {
+		log.Fatal("Cannot load jwt config:", err)
+	}
+	activeJWTKey, jwtKeys, err := auth.ParseSigningKeys(jwtConfig.SigningKeys)
+	if err != nil {
+		log.Fatalf("failed to parse jwt signing keys: %v", err)
+	}
+	tokens, err := auth.NewTokenIssuer(activeJWTKey, jwtKeys, jwtConfig.Issuer, jwtConfig.Audience)
+	if err != nil {
+		log.Fatalf("failed to init jwt issuer: %v", err)
+	}
+	a := app.NewApp(ctx, dbpoolmaster, dbpoolslave, keyring, tokens, authConfig, config.LoadMailConfig(), config.LoadBotConfig(), logger)
+	a.FailStaleImports()
+	a.StartScheduler()
+	a.StartHealthMonitor()
+	a.StartWebhookDispatcher()
+	a.StartStatusListener()
+	a.StartTelegramBot()
+	a.StartNotificationDispatcher()
+	a.StartConsumer()
 
 	log.Println("Kafka scheduler started")
 	a.Routes(r)
-	go func() {
-		log.Println("HTTP server starting on :8080")
-		err := r.Run(":8080")
-		if err != nil {
-			log.Fatal(err)
-		}
-	}()
-	a.WaitForShutdown()
+	srv := &http.Server{Addr: ":8080", Handler: r}
+	metricsSrv := metrics.NewServer(config.LoadMetricsAddr())
+	for _, s := range []*http.Server{srv, metricsSrv} {
+		go func() {
+			log.Printf("HTTP server starting on %s", s.Addr)
+			err := s.ListenAndServe()
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Fatal(err)
+			}
+		}()
+	}
+	a.WaitForShutdown(srv, metricsSrv)
 }